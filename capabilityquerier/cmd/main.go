@@ -6,15 +6,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/onc-healthit/lantern-back-end/capabilityquerier/pkg/capabilityquerier"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/quarantine"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/workers"
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/metrics"
 	aq "github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 	"github.com/spf13/viper"
 
@@ -28,6 +36,7 @@ type queryArgs struct {
 	workers     *workers.Workers
 	ctx         context.Context
 	client      *http.Client
+	limiter     *capabilityquerier.HostLimiter
 	jobDuration time.Duration
 	mq          *lanternmq.MessageQueue
 	ch          *lanternmq.ChannelID
@@ -57,18 +66,21 @@ func queryEndpointsCapabilityStatement(message []byte, args *map[string]interfac
 	urlString := msgJSON["url"]
 	requestVersion := msgJSON["requestVersion"]
 	defaultVersion := msgJSON["defaultVersion"]
-
-	if urlString == "FINISHED" {
-		return nil
+	cycleID, err := parseCycleID(msgJSON["cycleID"])
+	if err != nil {
+		return err
 	}
 
 	jobArgs := make(map[string]interface{})
+	jobArgs["message"] = message
 
 	jobArgs["querierArgs"] = capabilityquerier.QuerierArgs{
 		FhirURL:        urlString,
 		RequestVersion: requestVersion,
 		DefaultVersion: defaultVersion,
+		CycleID:        cycleID,
 		Client:         qa.client,
+		Limiter:        qa.limiter,
 		MessageQueue:   qa.mq,
 		ChannelID:      qa.ch,
 		QueueName:      qa.qName,
@@ -83,7 +95,7 @@ func queryEndpointsCapabilityStatement(message []byte, args *map[string]interfac
 		HandlerArgs: &jobArgs,
 	}
 
-	err = qa.workers.Add(&job)
+	_, err = qa.workers.Add(qa.ctx, &job)
 	if err != nil {
 		return fmt.Errorf("error adding job to workers: %s", err.Error())
 	}
@@ -93,7 +105,8 @@ func queryEndpointsCapabilityStatement(message []byte, args *map[string]interfac
 
 // queryEndpointsVersionsOperation gets an endpoint from the queue message and queries it to get supported versions
 // This function is expected to be called by the lanternmq ProcessMessages function.
-// parameter message:  the queue message that is being processed by this function, which is just an endpoint.
+// parameter message:  the queue message that is being processed by this function, which is the endpoint, the ID
+// of the query cycle it was sent in, and optionally the priority it was requested with.
 // parameter args:     expected to be a map of the string "queryArgs" to the above queryArgs struct. It is formatted
 // this way because queue processing is generalized.
 func queryEndpointsVersionsOperation(message []byte, args *map[string]interface{}) error {
@@ -103,13 +116,27 @@ func queryEndpointsVersionsOperation(message []byte, args *map[string]interface{
 		return fmt.Errorf("unable to cast queryArgs from arguments")
 	}
 
-	urlString := string(message)
+	var msgJSON map[string]string
+	err := json.Unmarshal(message, &msgJSON)
+	if err != nil {
+		return fmt.Errorf("Error parsing queryEndpointsVersionsOperation message JSON: %s", err.Error())
+	}
+
+	urlString := msgJSON["url"]
+	cycleID, err := parseCycleID(msgJSON["cycleID"])
+	if err != nil {
+		return err
+	}
 
 	jobArgs := make(map[string]interface{})
+	jobArgs["message"] = message
 
 	jobArgs["querierArgs"] = capabilityquerier.QuerierArgs{
 		FhirURL:      urlString,
+		CycleID:      cycleID,
+		Priority:     msgJSON["priority"],
 		Client:       qa.client,
+		Limiter:      qa.limiter,
 		MessageQueue: qa.mq,
 		ChannelID:    qa.ch,
 		QueueName:    qa.qName,
@@ -124,7 +151,7 @@ func queryEndpointsVersionsOperation(message []byte, args *map[string]interface{
 		HandlerArgs: &jobArgs,
 	}
 
-	err := qa.workers.Add(&job)
+	_, err = qa.workers.Add(qa.ctx, &job)
 	if err != nil {
 		return fmt.Errorf("error adding job to workers: %s", err.Error())
 	}
@@ -132,7 +159,29 @@ func queryEndpointsVersionsOperation(message []byte, args *map[string]interface{
 	return nil
 }
 
-func setupQueue(store *postgresql.Store, userAgent string, client *http.Client, ctx context.Context, qName string, endptQName string, processFunc lanternmq.MessageHandler) {
+// jobMessage returns the queue message a job was created for, to be quarantined if the job panics.
+func jobMessage(job *workers.Job) []byte {
+	message, _ := (*job.HandlerArgs)["message"].([]byte)
+	return message
+}
+
+// parseCycleID parses the query cycle ID from a queue message. Messages that are not part of a query cycle have
+// an ID of 0.
+func parseCycleID(cycleID string) (int, error) {
+	if cycleID == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(cycleID)
+	if err != nil {
+		return 0, fmt.Errorf("Error parsing query cycle ID %s: %s", cycleID, err.Error())
+	}
+	return id, nil
+}
+
+// setupQueue consumes the endpoints from the 'endptQName' queue and queries them with workers, sending the
+// responses to the 'qName' queue, until 'ctx' ends. Once it ends, the jobs that have already been added to the
+// workers are run to completion with 'jobCtx', and then the queue connection is closed.
+func setupQueue(store *postgresql.Store, userAgent string, client *http.Client, limiter *capabilityquerier.HostLimiter, ctx context.Context, jobCtx context.Context, qName string, endptQName string, processFunc lanternmq.MessageHandler) {
 	// Set up the queue for sending messages
 	qUser := viper.GetString("quser")
 	qPassword := viper.GetString("qpassword")
@@ -143,23 +192,38 @@ func setupQueue(store *postgresql.Store, userAgent string, client *http.Client,
 
 	mq, ch, err = aq.ConnectToQueue(mq, ch, endptQName)
 	helpers.FailOnError("", err)
-
-	defer mq.Close()
+	err = aq.ConfirmPublishes(mq, ch, viper.GetInt("qconfirm_timeout"))
+	helpers.FailOnError("", err)
+	hooks := metrics.QueueHooks()
+	hooks.Panicked = quarantine.MessageHook(jobCtx, store)
+	mq = lanternmq.Instrument(mq, hooks)
+	metrics.WatchQueue(mq, ch, endptQName)
 
 	errs := make(chan error)
+	errsDone := make(chan struct{})
+	go func() {
+		for elem := range errs {
+			log.Warn(elem)
+		}
+		close(errsDone)
+	}()
 
 	numWorkers := viper.GetInt("query_numworkers")
 	workers := workers.NewWorkers()
+	workers.JobDone = metrics.JobObserver(endptQName)
+	workers.JobPanicked = quarantine.JobHook(jobCtx, store, endptQName, jobMessage)
+	metrics.WatchWorkers(endptQName, workers)
 
 	// Start workers and have them always running
-	err = workers.Start(ctx, numWorkers, errs)
+	err = workers.Start(jobCtx, numWorkers, errs)
 	helpers.FailOnError("", err)
 
 	args := make(map[string]interface{})
 	args["queryArgs"] = queryArgs{
 		workers:     workers,
-		ctx:         ctx,
+		ctx:         jobCtx,
 		client:      client,
+		limiter:     limiter,
 		jobDuration: 30 * time.Second,
 		mq:          &mq,
 		ch:          &ch,
@@ -171,13 +235,64 @@ func setupQueue(store *postgresql.Store, userAgent string, client *http.Client,
 	messages, err := mq.ConsumeFromQueue(ch, endptQName)
 	helpers.FailOnError("", err)
 
-	go mq.ProcessMessages(ctx, messages, processFunc, &args, errs)
+	mq.ProcessMessages(ctx, messages, processFunc, &args, errs)
+
+	// the jobs still send their responses to the queue, so it is only closed once they are done
+	log.Infof("stopped consuming from %s, waiting for the queued jobs to finish", endptQName)
+	err = workers.Stop()
+	if err != nil {
+		log.Warn(err)
+	}
+	mq.Close()
+	close(errs)
+	<-errsDone
+}
+
+// startHeartbeat registers this querier instance in the database and keeps its heartbeat up to date until the
+// returned function is called, which removes the instance and waits for it to be removed.
+func startHeartbeat(store *postgresql.Store) func() {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warnf("unable to get the hostname for the querier instance: %s", err)
+		hostname = "unknown"
+	}
+	instance := &endpointmanager.QuerierInstance{
+		ID:         fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		Hostname:   hostname,
+		NumWorkers: viper.GetInt("query_numworkers"),
+	}
+	interval := time.Duration(viper.GetInt("capquery_heartbeat_intvl")) * time.Second
+	if interval <= 0 {
+		log.Fatalf("the heartbeat interval must be positive, got %s", interval)
+	}
 
-	for elem := range errs {
-		log.Warn(elem)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	heartbeatDone := make(chan struct{})
+	go func() {
+		capabilityquerier.Heartbeat(heartbeatCtx, store, instance, interval)
+		close(heartbeatDone)
+	}()
+
+	return func() {
+		log.Infof("removing querier instance %s", instance.ID)
+		stopHeartbeat()
+		<-heartbeatDone
 	}
 }
 
+// cancelOnSignal cancels the querier's context when it receives SIGINT or SIGTERM. A second signal stops the
+// querier right away.
+func cancelOnSignal(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		log.Infof("received %s, stopping the querier", sig)
+		cancel()
+	}()
+}
+
 func main() {
 	err := config.SetupConfig()
 	helpers.FailOnError("", err)
@@ -185,6 +300,12 @@ func main() {
 	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to DB!")
+	metrics.AddHealthCheck("database", store.DB.Ping)
+
+	go func() {
+		err := metrics.Serve(viper.GetString("metrics_addr"))
+		log.Warnf("stopped serving metrics: %s", err.Error())
+	}()
 
 	// Read version file that is mounted
 	version, err := ioutil.ReadFile("/etc/lantern/VERSION")
@@ -194,17 +315,38 @@ func main() {
 	userAgent := "LANTERN/" + versionNum[1]
 	userAgent = strings.TrimSuffix(userAgent, "\n")
 
+	// the requests to each host are spaced out across all of the querier instances. The requests wait for
+	// the limiter before they are sent with the client, so the wait doesn't count towards the client's timeout.
+	limiter := capabilityquerier.NewHostLimiter(store, time.Duration(viper.GetInt("capquery_host_intvl"))*time.Millisecond)
 	client := &http.Client{
-		Timeout: time.Second * 35,
+		Timeout:       time.Second * 35,
+		CheckRedirect: capabilityquerier.RedirectPolicy(viper.GetInt("capquery_maxredirects")),
 	}
 
-	ctx := context.Background()
+	// stopping the querier cancels ctx, which stops consuming from the queues. The jobs that were already
+	// consumed are run with jobCtx, which is not canceled, so that they can finish before the querier exits.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobCtx := context.Background()
+	cancelOnSignal(cancel)
+	stopHeartbeat := startHeartbeat(store)
 
+	var queues sync.WaitGroup
+	queues.Add(2)
 	versionResponseQName := viper.GetString("versionsquery_response_qname")
 	versionEndptQName := viper.GetString("versionsquery_qname")
-	go setupQueue(store, userAgent, client, ctx, versionResponseQName, versionEndptQName, queryEndpointsVersionsOperation)
+	go func() {
+		setupQueue(store, userAgent, client, limiter, ctx, jobCtx, versionResponseQName, versionEndptQName, queryEndpointsVersionsOperation)
+		queues.Done()
+	}()
 	capQName := viper.GetString("capquery_qname")
 	capQueryEndptQName := viper.GetString("endptinfo_capquery_qname")
-	setupQueue(store, userAgent, client, ctx, capQName, capQueryEndptQName, queryEndpointsCapabilityStatement)
-
+	go func() {
+		setupQueue(store, userAgent, client, limiter, ctx, jobCtx, capQName, capQueryEndptQName, queryEndpointsCapabilityStatement)
+		queues.Done()
+	}()
+	queues.Wait()
+
+	// the instance is removed last, once it is no longer querying any endpoints
+	stopHeartbeat()
 }
@@ -10,3 +10,7 @@ require (
 	github.com/spf13/viper v1.10.1
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 )
+
+replace github.com/onc-healthit/lantern-back-end/endpointmanager => ../endpointmanager
+
+replace github.com/onc-healthit/lantern-back-end/lanternmq => ../lanternmq
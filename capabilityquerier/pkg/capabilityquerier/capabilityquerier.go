@@ -2,8 +2,10 @@ package capabilityquerier
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -42,39 +44,49 @@ var tlsUnknown = "TLS version unknown"
 var tlsNone = "No TLS"
 
 // Message is the structure that gets sent on the queue with capability statement inforation. It includes the URL of
-// the FHIR API, any errors from making the FHIR API request, the MIME type, the TLS version, and the capability
-// statement itself.
+// the FHIR API, any errors from making the FHIR API request, the MIME type, the TLS version, the redirects that were
+// followed, the capability statement itself, and the ID of the query cycle the request was made in.
 type Message struct {
-	URL                      string      `json:"url"`
-	Err                      string      `json:"err"`
-	MIMETypes                []string    `json:"mimeTypes"`
-	TLSVersion               string      `json:"tlsVersion"`
-	HTTPResponse             int         `json:"httpResponse"`
-	CapabilityStatement      interface{} `json:"capabilityStatement"`
-	CapabilityStatementBytes []byte      `json:"capabilityStatementBytes"`
-	SMARTHTTPResponse        int         `json:"smarthttpResponse"`
-	SMARTResp                interface{} `json:"smartResp"`
-	SMARTRespBytes           []byte      `json:"smartRespBytes"`
-	ResponseTime             float64     `json:"responseTime"`
-	RequestedFhirVersion     string      `json:"requestedFhirVersion"`
-	DefaultFhirVersion       string      `json:"defaultFhirVersion"`
+	URL                       string                     `json:"url"`
+	Err                       string                     `json:"err"`
+	MIMETypes                 []string                   `json:"mimeTypes"`
+	TLSVersion                string                     `json:"tlsVersion"`
+	TLSCertificateFingerprint string                     `json:"tlsCertificateFingerprint"`
+	FinalURL                  string                     `json:"finalURL"`
+	Redirects                 []endpointmanager.Redirect `json:"redirects"`
+	HTTPResponse              int                        `json:"httpResponse"`
+	CapabilityStatement       interface{}                `json:"capabilityStatement"`
+	CapabilityStatementBytes  []byte                     `json:"capabilityStatementBytes"`
+	SMARTHTTPResponse         int                        `json:"smarthttpResponse"`
+	SMARTResp                 interface{}                `json:"smartResp"`
+	SMARTRespBytes            []byte                     `json:"smartRespBytes"`
+	ResponseTime              float64                    `json:"responseTime"`
+	RequestedFhirVersion      string                     `json:"requestedFhirVersion"`
+	DefaultFhirVersion        string                     `json:"defaultFhirVersion"`
+	CycleID                   int                        `json:"cycleID"`
 }
 
 // VersionMessage is the structure that gets sent on the queue with $versions response inforation. It includes the URL of
-// the FHIR API, any errors from making the FHIR $versions request, and the $versions response itself.
+// the FHIR API, any errors from making the FHIR $versions request, the $versions response itself, the ID of the
+// query cycle the request was made in, and the priority the endpoint was requested with.
 type VersionsMessage struct {
 	URL              string      `json:"url"`
 	Err              string      `json:"err"`
 	VersionsResponse interface{} `json:"versionsResponse"`
+	CycleID          int         `json:"cycleID"`
+	Priority         string      `json:"priority,omitempty"`
 }
 
 // QuerierArgs is a struct of the queue connection information (MessageQueue, ChannelID, and QueueName) as well as
-// the Client and FhirURL for querying
+// the Client, Limiter and FhirURL for querying. If Limiter is nil, the requests to a host are not spaced out.
 type QuerierArgs struct {
 	FhirURL        string
 	RequestVersion string
 	DefaultVersion string
+	CycleID        int
+	Priority       string
 	Client         *http.Client
+	Limiter        *HostLimiter
 	MessageQueue   *lanternmq.MessageQueue
 	ChannelID      *lanternmq.ChannelID
 	QueueName      string
@@ -93,43 +105,42 @@ func GetAndSendVersionsResponse(ctx context.Context, args *map[string]interface{
 	}
 
 	message := VersionsMessage{
-		URL: qa.FhirURL,
+		URL:      qa.FhirURL,
+		CycleID:  qa.CycleID,
+		Priority: qa.Priority,
 	}
 
-	// If Finished message, pass on to versions response queue
-	if qa.FhirURL != "FINISHED" {
-		// Cast string url to type url then cast back to string to ensure url string in correct url format
-		castURL, err := url.Parse(qa.FhirURL)
-		if err != nil {
-			return fmt.Errorf("endpoint URL parsing error: %s", err.Error())
-		}
-		versionsURL := endpointmanager.NormalizeVersionsURL(castURL.String())
-		// Add a short time buffer before sending HTTP request to reduce burden on servers hosting multiple endpoints
-		time.Sleep(time.Duration(500 * time.Millisecond))
-		req, err := http.NewRequest("GET", versionsURL, nil)
+	// Cast string url to type url then cast back to string to ensure url string in correct url format
+	castURL, err := url.Parse(qa.FhirURL)
+	if err != nil {
+		return fmt.Errorf("endpoint URL parsing error: %s", err.Error())
+	}
+	versionsURL := endpointmanager.NormalizeVersionsURL(castURL.String())
+	// Add a short time buffer before sending HTTP request to reduce burden on servers hosting multiple endpoints
+	time.Sleep(time.Duration(500 * time.Millisecond))
+	req, err := http.NewRequest("GET", versionsURL, nil)
+	if err != nil {
+		log.Errorf("unable to create new GET request from URL: " + versionsURL)
+	} else {
+		req.Header.Set("User-Agent", qa.UserAgent)
+		trace := &httptrace.ClientTrace{}
+		req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+		result, err := requestWithMimeType(req, "application/json", qa.Client, qa.Limiter)
+		// If an error occurs with the version request we still want to proceed with the capability request
 		if err != nil {
-			log.Errorf("unable to create new GET request from URL: " + versionsURL)
+			log.Infof("Error requesting versions response: %s", err.Error())
 		} else {
-			req.Header.Set("User-Agent", qa.UserAgent)
-			trace := &httptrace.ClientTrace{}
-			req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
-
-			httpResponseCode, _, _, versionsResponse, _, err := requestWithMimeType(req, "application/json", qa.Client)
-			// If an error occurs with the version request we still want to proceed with the capability request
-			if err != nil {
-				log.Infof("Error requesting versions response: %s", err.Error())
-			} else {
-				if httpResponseCode == 200 && versionsResponse != nil {
-					err = json.Unmarshal(versionsResponse, &(jsonResponse))
-					if err != nil {
-						log.Errorf("Error unmarshalling versions response: %s", err.Error())
-					}
+			if result.httpResponseCode == 200 && result.body != nil {
+				err = json.Unmarshal(result.body, &(jsonResponse))
+				if err != nil {
+					log.Errorf("Error unmarshalling versions response: %s", err.Error())
 				}
 			}
 		}
-
-		message.VersionsResponse = jsonResponse
 	}
+
+	message.VersionsResponse = jsonResponse
 	msgBytes, err := json.Marshal(message)
 	if err != nil {
 		return errors.Wrapf(err, "error marshalling json message for request to %s", qa.FhirURL)
@@ -137,7 +148,7 @@ func GetAndSendVersionsResponse(ctx context.Context, args *map[string]interface{
 	msgStr := string(msgBytes)
 	// Blank context passed in to SendToQueue to prevent terminating error due to an endpoint timeout
 	tempCtx := context.Background()
-	err = aq.SendToQueue(tempCtx, msgStr, qa.MessageQueue, qa.ChannelID, qa.QueueName)
+	err = aq.SendToQueue(tempCtx, msgStr, qa.MessageQueue, qa.ChannelID, qa.QueueName, 0)
 	if err != nil {
 		return errors.Wrapf(err, "error sending versions response for FHIR endpoint %s to queue '%s'", qa.FhirURL, qa.QueueName)
 	}
@@ -178,6 +189,7 @@ func GetAndSendCapabilityStatement(ctx context.Context, args *map[string]interfa
 	userAgent := qa.UserAgent
 	message := Message{
 		URL:                  qa.FhirURL,
+		CycleID:              qa.CycleID,
 		RequestedFhirVersion: qa.RequestVersion,
 		DefaultFhirVersion:   qa.DefaultVersion,
 		MIMETypes:            mimeTypes,
@@ -189,7 +201,7 @@ func GetAndSendCapabilityStatement(ctx context.Context, args *map[string]interfa
 	}
 	metadataURL := endpointmanager.NormalizeEndpointURL(castURL.String())
 	// Query fhir endpoint
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, metadata, qa.Client, userAgent, &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, metadata, qa.Client, qa.Limiter, userAgent, &message)
 	if err != nil {
 		select {
 		case <-ctx.Done():
@@ -203,7 +215,7 @@ func GetAndSendCapabilityStatement(ctx context.Context, args *map[string]interfa
 
 	wellKnownURL := endpointmanager.NormalizeWellKnownURL(castURL.String())
 	// Query well known endpoint
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, wellKnownURL, wellknown, qa.Client, userAgent, &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, wellKnownURL, wellknown, qa.Client, qa.Limiter, userAgent, &message)
 	if err != nil {
 		log.Warnf("Got error:\n%s\n\nfrom wellknown URL: %s", err.Error(), wellKnownURL)
 	}
@@ -215,7 +227,7 @@ func GetAndSendCapabilityStatement(ctx context.Context, args *map[string]interfa
 	msgStr := string(msgBytes)
 	// Blank context passed in to SendToQueue to prevent terminating error due to an endpoint timeout
 	tempCtx := context.Background()
-	err = aq.SendToQueue(tempCtx, msgStr, qa.MessageQueue, qa.ChannelID, qa.QueueName)
+	err = aq.SendToQueue(tempCtx, msgStr, qa.MessageQueue, qa.ChannelID, qa.QueueName, 0)
 	if err != nil {
 		return errors.Wrapf(err, "error sending capability statement for FHIR endpoint %s to queue '%s'", qa.FhirURL, qa.QueueName)
 	}
@@ -224,15 +236,11 @@ func GetAndSendCapabilityStatement(ctx context.Context, args *map[string]interfa
 }
 
 // fills out message with http response code, tls version, capability statement, and supported mime types
-func requestCapabilityStatementAndSmartOnFhir(ctx context.Context, fhirURL string, endptType EndpointType, client *http.Client, userAgent string, message *Message) error {
+func requestCapabilityStatementAndSmartOnFhir(ctx context.Context, fhirURL string, endptType EndpointType, client *http.Client, limiter *HostLimiter, userAgent string, message *Message) error {
 	var err error
 	var httpErr error
-	var httpResponseCode int
-	var mimeTypeWorked bool
-	var tlsVersion string
-	var capResp []byte
+	var result mimeTypeResult
 	var jsonResponse interface{}
-	var responseTime float64
 	var triedMIMEType string
 
 	// Add a short time buffer before sending HTTP request to reduce burden on servers hosting multiple endpoints
@@ -253,19 +261,19 @@ func requestCapabilityStatementAndSmartOnFhir(ctx context.Context, fhirURL strin
 	// If there is a mime type saved in the database for this URL, try those ones first when requesting the capability statement
 	if len(message.MIMETypes) == 1 {
 		savedMIME := message.MIMETypes[0]
-		httpResponseCode, tlsVersion, mimeTypeWorked, capResp, responseTime, httpErr = requestWithMimeType(req, savedMIME, client)
-		if httpErr != nil && httpResponseCode != 0 {
+		result, httpErr = requestWithMimeType(req, savedMIME, client, limiter)
+		if httpErr != nil && result.httpResponseCode != 0 {
 			return err
 		}
 	}
 
 	// If there was no MIME type saved in the database, or the saved MIME type did not work, go through process of trying others
-	if len(message.MIMETypes) != 1 || httpResponseCode != http.StatusOK || !mimeTypeWorked {
+	if len(message.MIMETypes) != 1 || result.httpResponseCode != http.StatusOK || !result.mimeMatches {
 		// If the endpoint is a well known endpoint and it did not already have MIME type saved, try the fhir3PlusJSONMIMEType
 		if endptType == wellknown {
 			if len(message.MIMETypes) == 0 {
-				httpResponseCode, _, _, capResp, _, httpErr = requestWithMimeType(req, fhir3PlusJSONMIMEType, client)
-				if httpErr != nil && httpResponseCode != 0 {
+				result, httpErr = requestWithMimeType(req, fhir3PlusJSONMIMEType, client, limiter)
+				if httpErr != nil && result.httpResponseCode != 0 {
 					return err
 				}
 			}
@@ -282,51 +290,51 @@ func requestCapabilityStatementAndSmartOnFhir(ctx context.Context, fhirURL strin
 
 			// Try fhir3PlusJSONMIMEType first if it was not the MIME type saved in the database
 			if oldMIMEType != fhir3PlusJSONMIMEType {
-				httpResponseCode, tlsVersion, mimeTypeWorked, capResp, responseTime, httpErr = requestWithMimeType(req, fhir3PlusJSONMIMEType, client)
-				if httpErr != nil && httpResponseCode != 0 {
+				result, httpErr = requestWithMimeType(req, fhir3PlusJSONMIMEType, client, limiter)
+				if httpErr != nil && result.httpResponseCode != 0 {
 					return err
 				}
 				triedMIMEType = fhir3PlusJSONMIMEType
 			}
 			// Try fhir2LessJSONMIMEType second if it was not the MIME type saved in the database and the first MIME type did not work
-			if oldMIMEType != fhir2LessJSONMIMEType && (!mimeTypeWorked || httpResponseCode != http.StatusOK) {
-				httpResponseCode, tlsVersion, mimeTypeWorked, capResp, responseTime, httpErr = requestWithMimeType(req, fhir2LessJSONMIMEType, client)
-				if httpErr != nil && httpResponseCode != 0 {
+			if oldMIMEType != fhir2LessJSONMIMEType && (!result.mimeMatches || result.httpResponseCode != http.StatusOK) {
+				result, httpErr = requestWithMimeType(req, fhir2LessJSONMIMEType, client, limiter)
+				if httpErr != nil && result.httpResponseCode != 0 {
 					return err
 				}
 				triedMIMEType = fhir2LessJSONMIMEType
 			}
 			// Try fhir3PlusXMLMIMEType third if it was not the MIME type saved in the database and the first two MIME types did not work
-			if oldMIMEType != fhir3PlusXMLMIMEType && (!mimeTypeWorked || httpResponseCode != http.StatusOK) {
-				httpResponseCode, tlsVersion, mimeTypeWorked, capResp, responseTime, httpErr = requestWithMimeType(req, fhir3PlusXMLMIMEType, client)
-				if httpErr != nil && httpResponseCode != 0 {
+			if oldMIMEType != fhir3PlusXMLMIMEType && (!result.mimeMatches || result.httpResponseCode != http.StatusOK) {
+				result, httpErr = requestWithMimeType(req, fhir3PlusXMLMIMEType, client, limiter)
+				if httpErr != nil && result.httpResponseCode != 0 {
 					return err
 				}
 				triedMIMEType = fhir3PlusXMLMIMEType
 			}
 			// Try fhir2LessXMLMIMEType last if it was not the MIME type saved in the database and the first three MIME types did not work
-			if oldMIMEType != fhir2LessXMLMIMEType && (!mimeTypeWorked || httpResponseCode != http.StatusOK) {
-				httpResponseCode, tlsVersion, mimeTypeWorked, capResp, responseTime, httpErr = requestWithMimeType(req, fhir2LessXMLMIMEType, client)
-				if httpErr != nil && httpResponseCode != 0 {
+			if oldMIMEType != fhir2LessXMLMIMEType && (!result.mimeMatches || result.httpResponseCode != http.StatusOK) {
+				result, httpErr = requestWithMimeType(req, fhir2LessXMLMIMEType, client, limiter)
+				if httpErr != nil && result.httpResponseCode != 0 {
 					return err
 				}
 				triedMIMEType = fhir2LessXMLMIMEType
 			}
 
 			// If there are no MIME types saved, and a new MIME type worked and had a valid HTTP response, save it in the db
-			if len(message.MIMETypes) != 1 && mimeTypeWorked && httpResponseCode == http.StatusOK {
+			if len(message.MIMETypes) != 1 && result.mimeMatches && result.httpResponseCode == http.StatusOK {
 				message.MIMETypes = append(message.MIMETypes, triedMIMEType)
 			}
 		}
 	}
 
-	if capResp != nil {
+	if result.body != nil {
 		if endptType == metadata {
-			message.CapabilityStatementBytes = capResp
+			message.CapabilityStatementBytes = result.body
 		} else if endptType == wellknown {
-			message.SMARTRespBytes = capResp
+			message.SMARTRespBytes = result.body
 		}
-		err := json.Unmarshal(capResp, &jsonResponse)
+		err := json.Unmarshal(result.body, &jsonResponse)
 		if err == nil {
 			if endptType == metadata {
 				message.CapabilityStatement = jsonResponse
@@ -342,11 +350,14 @@ func requestCapabilityStatementAndSmartOnFhir(ctx context.Context, fhirURL strin
 
 	switch endptType {
 	case metadata:
-		message.TLSVersion = tlsVersion
-		message.HTTPResponse = httpResponseCode
-		message.ResponseTime = responseTime
+		message.TLSVersion = result.tlsVersion
+		message.TLSCertificateFingerprint = result.tlsCertificateFingerprint
+		message.HTTPResponse = result.httpResponseCode
+		message.ResponseTime = result.responseTime
+		message.FinalURL = result.finalURL
+		message.Redirects = result.redirects
 	case wellknown:
-		message.SMARTHTTPResponse = httpResponseCode
+		message.SMARTHTTPResponse = result.httpResponseCode
 	}
 
 	return httpErr
@@ -372,6 +383,49 @@ func getTLSVersion(resp *http.Response) string {
 	return tlsNone
 }
 
+// getTLSCertificateFingerprint returns the hex encoded SHA-256 hash of the certificate the server presented, or
+// an empty string if the response was not made over TLS
+func getTLSCertificateFingerprint(resp *http.Response) string {
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	fingerprint := sha256.Sum256(resp.TLS.PeerCertificates[0].Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// RedirectPolicy returns a CheckRedirect function for an http.Client that stops following redirects after
+// maxRedirects hops. When the limit is reached, the request fails with an error.
+func RedirectPolicy(maxRedirects int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+}
+
+// getRedirects returns the redirects that were followed to get the given response, in the order they were followed.
+// If the given response is itself a redirect that was not followed, it is included as the last redirect.
+func getRedirects(resp *http.Response) []endpointmanager.Redirect {
+	var redirects []endpointmanager.Redirect
+	for r := resp; r != nil && r.Request != nil; r = r.Request.Response {
+		if r.StatusCode < 300 || r.StatusCode >= 400 {
+			continue
+		}
+		location, err := r.Location()
+		if err != nil {
+			continue
+		}
+		redirect := endpointmanager.Redirect{
+			URL:        r.Request.URL.String(),
+			StatusCode: r.StatusCode,
+			Location:   location.String(),
+		}
+		redirects = append([]endpointmanager.Redirect{redirect}, redirects...)
+	}
+	return redirects
+}
+
 func isJSONMIMEType(mimeType string) bool {
 	return strings.Contains(mimeType, "json")
 }
@@ -386,33 +440,55 @@ func mimeTypesMatch(reqMimeType string, respMimeType string) bool {
 	return false
 }
 
-// responds with:
-// http status code
-// tls version
-// mime type match
-// capability statement
-// error
-func requestWithMimeType(req *http.Request, mimeType string, client *http.Client) (int, string, bool, []byte, float64, error) {
-	var httpResponseCode int
-	var tlsVersion string
-	var capStat []byte
+// mimeTypeResult holds the results of requesting a URL with a MIME type
+type mimeTypeResult struct {
+	httpResponseCode          int
+	tlsVersion                string
+	tlsCertificateFingerprint string
+	mimeMatches               bool
+	body                      []byte
+	responseTime              float64
+	// finalURL is the URL the response came from after any redirects were followed, without the
+	// metadata, well-known or $versions suffix
+	finalURL string
+	// redirects are the redirects that were followed, in order. They are also set when the request
+	// fails because the redirect limit was reached.
+	redirects []endpointmanager.Redirect
+}
 
-	mimeMatches := false
+// requestWithMimeType requests the given URL with the given MIME type in the Accept header. The body is only
+// read if the response has a JSON MIME type. When the request fails, the HTTP response code is 0 and the
+// response time is -1. The request waits for the limiter before it is timed and sent, so the wait doesn't count
+// towards the response time or the client's timeout. The redirects the client follows are not waited for.
+func requestWithMimeType(req *http.Request, mimeType string, client *http.Client, limiter *HostLimiter) (mimeTypeResult, error) {
+	var result mimeTypeResult
+	var err error
 
 	req.Header.Set("Accept", mimeType)
 
+	err = limiter.Wait(req.Context(), strings.ToLower(req.URL.Hostname()))
+	if err != nil {
+		result.responseTime = -1
+		return result, errors.Wrapf(err, "waiting to make the GET request to %s failed", req.URL.String())
+	}
+
 	start := time.Now()
 
 	resp, err := client.Do(req)
 	if err != nil {
 		// Return http status code 0 on failure
-		return 0, "", false, nil, -1, errors.Wrapf(err, "making the GET request to %s failed", req.URL.String())
+		result.responseTime = -1
+		// the client returns the last redirect response when a redirect policy stops the request
+		if resp != nil {
+			result.redirects = getRedirects(resp)
+		}
+		return result, errors.Wrapf(err, "making the GET request to %s failed", req.URL.String())
 	}
 
-	var responseTime = float64(time.Since(start).Seconds())
+	result.responseTime = float64(time.Since(start).Seconds())
 
-	httpResponseCode = resp.StatusCode
-	if httpResponseCode == http.StatusOK {
+	result.httpResponseCode = resp.StatusCode
+	if result.httpResponseCode == http.StatusOK {
 		respMimeType := resp.Header.Get("Content-Type")
 		// endpoints generally return an xml mime type by default.
 		// checking that it's a json mime type confirms that it processes the JSON type request.
@@ -420,16 +496,21 @@ func requestWithMimeType(req *http.Request, mimeType string, client *http.Client
 		// first JSON request type it receives and continues to respond with that.
 		if isJSONMIMEType(respMimeType) {
 			defer resp.Body.Close()
-			mimeMatches = true
+			result.mimeMatches = true
 
-			capStat, err = ioutil.ReadAll(resp.Body)
+			result.body, err = ioutil.ReadAll(resp.Body)
 			if err != nil {
-				return -1, "", false, nil, -1, errors.Wrapf(err, "reading the response from %s failed", req.URL.String())
+				return mimeTypeResult{httpResponseCode: -1, responseTime: -1}, errors.Wrapf(err, "reading the response from %s failed", req.URL.String())
 			}
 		}
 	}
 
-	tlsVersion = getTLSVersion(resp)
+	result.tlsVersion = getTLSVersion(resp)
+	result.tlsCertificateFingerprint = getTLSCertificateFingerprint(resp)
+	if resp.Request != nil && resp.Request.URL != nil {
+		result.finalURL = endpointmanager.TrimEndpointURLSuffix(resp.Request.URL.String())
+	}
+	result.redirects = getRedirects(resp)
 
-	return httpResponseCode, tlsVersion, mimeMatches, capStat, responseTime, nil
+	return result, nil
 }
@@ -1,4 +1,3 @@
-//go:build integration
 // +build integration
 
 package capabilityquerier
@@ -20,11 +19,11 @@ import (
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
 	log "github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/mock"
 	aq "github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/fetcher"
@@ -36,8 +35,8 @@ var mq *lanternmq.MessageQueue
 var chID *lanternmq.ChannelID
 var endpoints fetcher.ListOfEndpoints
 
-var conn *amqp.Connection
-var channel *amqp.Channel
+var conn lanternmq.MessageQueue
+var channel lanternmq.ChannelID
 
 func TestMain(m *testing.M) {
 	var err error
@@ -76,7 +75,7 @@ func Test_Integration_GetAndSendCapabilityStatement(t *testing.T) {
 
 	queueName := viper.GetString("qname")
 	queueIsEmpty(t, queueName)
-	defer checkCleanQueue(t, queueName, channel)
+	defer checkCleanQueue(t, queueName)
 
 	var err error
 
@@ -114,7 +113,7 @@ func Test_Integration_GetAndSendCapabilityStatement(t *testing.T) {
 			th.Assert(t, err == nil, err)
 		}
 	}
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 	// need to pause to ensure all messages are on the queue before we count them
 	time.Sleep(10 * time.Second)
@@ -234,13 +233,13 @@ func Test_Integration_GetAndSendCapabilityStatement2(t *testing.T) {
 }
 
 func queueIsEmpty(t *testing.T, queueName string) {
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 0, "should be no messages in queue.")
 }
 
-func checkCleanQueue(t *testing.T, queueName string, channel *amqp.Channel) {
-	err := aq.CleanQueue(queueName, channel)
+func checkCleanQueue(t *testing.T, queueName string) {
+	err := aq.CleanQueue(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 }
 
@@ -273,13 +272,13 @@ func setup() error {
 	}
 
 	// setup specific queue info so we can test what's in the queue
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
-	conn, err = amqp.Dial(s)
+	conn = &rabbitmq.MessageQueue{}
+	err = conn.Connect(qUser, qPassword, qHost, qPort)
 	if err != nil {
 		return err
 	}
 
-	channel, err = conn.Channel()
+	channel, err = conn.CreateChannel()
 	if err != nil {
 		return err
 	}
@@ -302,8 +301,6 @@ func capabilityStatementOriginalBytes() ([]byte, error) {
 
 func teardown() {
 	(*mq).Close()
-	channel.Close()
-	conn.Close()
 	conn.Close()
 	store.Close()
 }
@@ -3,15 +3,20 @@ package capabilityquerier
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
@@ -49,7 +54,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	capStat, err = json.Marshal(message.CapabilityStatement)
 	th.Assert(t, err == nil, err)
@@ -64,7 +69,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 
 	// check that response from well known endpt is null and that MIME type is not affected
 	wellKnownURL := endpointmanager.NormalizeWellKnownURL(sampleURL)
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, wellKnownURL, "well-known", client, "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, wellKnownURL, "well-known", client, nil, "", &message)
 	th.Assert(t, err == nil, err)
 	smartResp, err = json.Marshal(message.SMARTResp)
 	th.Assert(t, err == nil, err)
@@ -90,7 +95,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	capStat, err = json.Marshal(message.CapabilityStatement)
 	th.Assert(t, err == nil, err)
@@ -107,7 +112,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	tc.Close() // makes request fail
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	switch errors.Cause(err).(type) {
 	case *url.Error:
 		// expect url.Error because we closed the connection that we're querying.
@@ -123,7 +128,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, len(message.MIMETypes) == 0, "expected no matched mime types")
 
@@ -139,7 +144,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	capStat, err = json.Marshal(message.CapabilityStatement)
 	th.Assert(t, err == nil, err)
@@ -161,7 +166,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	capStat, err = json.Marshal(message.CapabilityStatement)
 	th.Assert(t, err == nil, err)
@@ -184,7 +189,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	capStat, err = json.Marshal(message.CapabilityStatement)
 	th.Assert(t, err == nil, err)
@@ -203,7 +208,7 @@ func Test_requestCapabilityStatementAndSmartOnFhir(t *testing.T) {
 	defer tc.Close()
 	ctx = context.Background()
 
-	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), "", &message)
+	err = requestCapabilityStatementAndSmartOnFhir(ctx, metadataURL, "metadata", &(tc.Client), nil, "", &message)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, len(message.MIMETypes) == 1, fmt.Sprintf("expected one matched mime types, got %d", len(message.MIMETypes)))
 	th.Assert(t, message.MIMETypes[0] == expectedMimeType, fmt.Sprintf("mismatched: expected mimeType %s; received mimeType %s", expectedMimeType, message.MIMETypes[0]))
@@ -273,6 +278,26 @@ func Test_getTLSVersion(t *testing.T) {
 
 }
 
+func Test_getTLSCertificateFingerprint(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("test certificate")}
+	hash := sha256.Sum256(cert.Raw)
+	expected := hex.EncodeToString(hash[:])
+
+	resp := &http.Response{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+	fingerprint := getTLSCertificateFingerprint(resp)
+	th.Assert(t, fingerprint == expected, fmt.Sprintf("expected fingerprint %s; received %s", expected, fingerprint))
+
+	// no certificates
+	resp = &http.Response{TLS: &tls.ConnectionState{}}
+	fingerprint = getTLSCertificateFingerprint(resp)
+	th.Assert(t, fingerprint == "", fmt.Sprintf("expected no fingerprint; received %s", fingerprint))
+
+	// no TLS
+	resp = &http.Response{}
+	fingerprint = getTLSCertificateFingerprint(resp)
+	th.Assert(t, fingerprint == "", fmt.Sprintf("expected no fingerprint; received %s", fingerprint))
+}
+
 func Test_mimeTypesMatch(t *testing.T) {
 	var reqMimeType, respMimeType string
 	var match bool
@@ -320,12 +345,13 @@ func Test_requestWithMimeType(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	defer tc.Close()
 
-	httpCode, tlsVersion, mimeMatch, capStat, _, err := requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client))
+	result, err := requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client), nil)
 	th.Assert(t, err == nil, err)
-	th.Assert(t, httpCode == 200, "expected 200 response")
-	th.Assert(t, tlsVersion == "TLS 1.0", fmt.Sprintf("expected TLS 1.0. got %s", tlsVersion))
-	th.Assert(t, mimeMatch, "expected the mime types to match")
-	th.Assert(t, capStat != nil, "expected to receive a capability statement")
+	th.Assert(t, result.httpResponseCode == 200, "expected 200 response")
+	th.Assert(t, result.tlsVersion == "TLS 1.0", fmt.Sprintf("expected TLS 1.0. got %s", result.tlsVersion))
+	th.Assert(t, result.mimeMatches, "expected the mime types to match")
+	th.Assert(t, result.body != nil, "expected to receive a capability statement")
+	th.Assert(t, result.finalURL == "https://fhir-myrecord.cerner.com/dstu2/sqiH60CNKO9o0PByEO9XAxX0dZX5s5b2", fmt.Sprintf("unexpected final URL %s", result.finalURL))
 
 	// test http request error
 
@@ -333,7 +359,7 @@ func Test_requestWithMimeType(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	tc.Close() // makes request fail
 
-	_, _, _, _, _, err = requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client))
+	_, err = requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client), nil)
 	switch errors.Cause(err).(type) {
 	case *url.Error:
 		// expect url.Error because we closed the connection that we're querying.
@@ -345,9 +371,62 @@ func Test_requestWithMimeType(t *testing.T) {
 	tc = th.NewTestClientWith404()
 	defer tc.Close()
 
-	httpCode, _, _, _, _, err = requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client))
+	result, err = requestWithMimeType(req, fhir2LessJSONMIMEType, &(tc.Client), nil)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, result.httpResponseCode == 404, fmt.Sprintf("expected 404 response code. Got %d", result.httpResponseCode))
+}
+
+func Test_requestWithMimeTypeRedirects(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old/metadata":
+			http.Redirect(w, r, "/moved/metadata", http.StatusMovedPermanently)
+		case "/moved/metadata":
+			http.Redirect(w, r, "/fhir/metadata", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", fhir3PlusJSONMIMEType)
+			_, _ = w.Write([]byte("{}"))
+		}
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	expected := []endpointmanager.Redirect{
+		{URL: server.URL + "/old/metadata", StatusCode: http.StatusMovedPermanently, Location: server.URL + "/moved/metadata"},
+		{URL: server.URL + "/moved/metadata", StatusCode: http.StatusFound, Location: server.URL + "/fhir/metadata"},
+	}
+
+	// redirects are followed and recorded
+
+	client := &http.Client{CheckRedirect: RedirectPolicy(2)}
+	req, err := http.NewRequest("GET", server.URL+"/old/metadata", nil)
+	th.Assert(t, err == nil, err)
+
+	result, err := requestWithMimeType(req, fhir3PlusJSONMIMEType, client, nil)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, result.httpResponseCode == 200, fmt.Sprintf("expected 200 response. Got %d", result.httpResponseCode))
+	th.Assert(t, result.finalURL == server.URL+"/fhir", fmt.Sprintf("unexpected final URL %s", result.finalURL))
+	th.Assert(t, reflect.DeepEqual(result.redirects, expected), fmt.Sprintf("expected redirects %v. Got %v", expected, result.redirects))
+
+	// no redirects
+
+	req, err = http.NewRequest("GET", server.URL+"/fhir/metadata", nil)
 	th.Assert(t, err == nil, err)
-	th.Assert(t, httpCode == 404, fmt.Sprintf("expected 404 response code. Got %d", httpCode))
+
+	result, err = requestWithMimeType(req, fhir3PlusJSONMIMEType, client, nil)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(result.redirects) == 0, fmt.Sprintf("expected no redirects. Got %v", result.redirects))
+
+	// the request fails once the redirect limit is reached, but the redirects are still recorded
+
+	client = &http.Client{CheckRedirect: RedirectPolicy(1)}
+	req, err = http.NewRequest("GET", server.URL+"/old/metadata", nil)
+	th.Assert(t, err == nil, err)
+
+	result, err = requestWithMimeType(req, fhir3PlusJSONMIMEType, client, nil)
+	th.Assert(t, err != nil, "expected an error due to the redirect limit")
+	th.Assert(t, result.httpResponseCode == 0, fmt.Sprintf("expected 0 response code. Got %d", result.httpResponseCode))
+	th.Assert(t, reflect.DeepEqual(result.redirects, expected), fmt.Sprintf("expected redirects %v. Got %v", expected, result.redirects))
 }
 
 func basicTestClient() (*th.TestClient, error) {
@@ -0,0 +1,59 @@
+package capabilityquerier
+
+import (
+	"context"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	log "github.com/sirupsen/logrus"
+)
+
+// staleHeartbeats is how many heartbeat intervals an instance can miss before the other instances remove it.
+const staleHeartbeats = 3
+
+// HeartbeatStore stores the capability querier instances that are running.
+type HeartbeatStore interface {
+	UpdateQuerierHeartbeat(ctx context.Context, instance *endpointmanager.QuerierInstance) error
+	DeleteQuerierInstance(ctx context.Context, id string) error
+	DeleteStaleQuerierInstances(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Heartbeat registers the given capability querier instance in the store and updates its heartbeat every
+// interval until the context ends, at which point the instance is removed from the store. Each heartbeat also
+// removes the instances that stopped without removing themselves, once they have missed three heartbeats.
+func Heartbeat(ctx context.Context, store HeartbeatStore, instance *endpointmanager.QuerierInstance, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		beat(ctx, store, instance, interval)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// the context has ended, so removing the instance needs a context of its own
+			removeCtx, cancel := context.WithTimeout(context.Background(), interval)
+			err := store.DeleteQuerierInstance(removeCtx, instance.ID)
+			cancel()
+			if err != nil {
+				log.Warnf("unable to remove querier instance %s: %s", instance.ID, err)
+			}
+			return
+		}
+	}
+}
+
+func beat(ctx context.Context, store HeartbeatStore, instance *endpointmanager.QuerierInstance, interval time.Duration) {
+	err := store.UpdateQuerierHeartbeat(ctx, instance)
+	if err != nil {
+		log.Warnf("unable to update the heartbeat of querier instance %s: %s", instance.ID, err)
+		return
+	}
+
+	removed, err := store.DeleteStaleQuerierInstances(ctx, instance.LastHeartbeat.Add(-staleHeartbeats*interval))
+	if err != nil {
+		log.Warnf("unable to remove stale querier instances: %s", err)
+	} else if removed > 0 {
+		log.Infof("removed %d stale querier instances", removed)
+	}
+}
@@ -0,0 +1,100 @@
+package capabilityquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+type fakeHeartbeatStore struct {
+	mu          sync.Mutex
+	heartbeats  int
+	staleBefore []time.Time
+	deleted     []string
+	updateErr   error
+	beat        chan struct{}
+}
+
+func (s *fakeHeartbeatStore) UpdateQuerierHeartbeat(ctx context.Context, instance *endpointmanager.QuerierInstance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeats++
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	instance.LastHeartbeat = time.Date(2021, 1, 1, 12, 0, s.heartbeats, 0, time.UTC)
+	return nil
+}
+
+func (s *fakeHeartbeatStore) DeleteQuerierInstance(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func (s *fakeHeartbeatStore) DeleteStaleQuerierInstances(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	s.staleBefore = append(s.staleBefore, before)
+	s.mu.Unlock()
+	s.beat <- struct{}{}
+	return 0, nil
+}
+
+func Test_Heartbeat(t *testing.T) {
+	store := &fakeHeartbeatStore{beat: make(chan struct{})}
+	instance := &endpointmanager.QuerierInstance{ID: "querier-1", Hostname: "querier", NumWorkers: 10}
+	interval := 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Heartbeat(ctx, store, instance, interval)
+		close(done)
+	}()
+
+	// the instance beats right away and then every interval
+	for i := 0; i < 3; i++ {
+		select {
+		case <-store.beat:
+		case <-time.After(time.Second):
+			t.Fatalf("expected heartbeat %d", i+1)
+		}
+	}
+	cancel()
+	for stopped := false; !stopped; {
+		select {
+		case <-done:
+			stopped = true
+		case <-store.beat:
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	th.Assert(t, store.heartbeats >= 3, fmt.Sprintf("expected at least 3 heartbeats, got %d", store.heartbeats))
+	expected := time.Date(2021, 1, 1, 12, 0, 1, 0, time.UTC).Add(-3 * interval)
+	th.Assert(t, store.staleBefore[0].Equal(expected), fmt.Sprintf("expected instances stale before %s to be removed, got %s", expected, store.staleBefore[0]))
+	th.Assert(t, len(store.deleted) == 1 && store.deleted[0] == "querier-1", fmt.Sprintf("expected the instance to remove itself, got %v", store.deleted))
+}
+
+func Test_HeartbeatUpdateError(t *testing.T) {
+	store := &fakeHeartbeatStore{beat: make(chan struct{}), updateErr: errors.New("database is down")}
+	instance := &endpointmanager.QuerierInstance{ID: "querier-1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	Heartbeat(ctx, store, instance, 10*time.Millisecond)
+
+	// stale instances aren't removed without a heartbeat of our own to compare against, and the instance
+	// still tries to remove itself
+	th.Assert(t, store.heartbeats > 1, fmt.Sprintf("expected the heartbeat to be retried, got %d heartbeats", store.heartbeats))
+	th.Assert(t, len(store.staleBefore) == 0, "expected no stale instances to be removed")
+	th.Assert(t, len(store.deleted) == 1, fmt.Sprintf("expected the instance to remove itself, got %v", store.deleted))
+}
@@ -0,0 +1,78 @@
+package capabilityquerier
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// releaseTimeout is how long giving back the reservation of an abandoned request can take. The reservation is
+// given back after the request's context has ended, so it can't use that context.
+var releaseTimeout = 5 * time.Second
+
+// HostReserver reserves the next time a request can be made to a host, eg. in the database so that the
+// reservations are shared by every capability querier instance, and gives back the reservations of requests
+// that won't be made.
+type HostReserver interface {
+	ReserveHostRequest(ctx context.Context, host string, interval time.Duration) (time.Duration, time.Time, error)
+	ReleaseHostRequest(ctx context.Context, host string, interval time.Duration, nextRequestAt time.Time) (bool, error)
+}
+
+// HostLimiter keeps the requests made to each host at least an interval apart.
+type HostLimiter struct {
+	reserver HostReserver
+	interval time.Duration
+}
+
+// NewHostLimiter creates a HostLimiter that reserves the requests to each host with the given reserver. If the
+// interval is not positive, requests are not spaced out.
+func NewHostLimiter(reserver HostReserver, interval time.Duration) *HostLimiter {
+	return &HostLimiter{
+		reserver: reserver,
+		interval: interval,
+	}
+}
+
+// Wait waits until a request can be made to the given host. If the request can't be reserved, the error is
+// logged and Wait returns right away so that the querier keeps working without the limit. Wait throws an error
+// if the context ends first, and gives back the reservation so that the following requests to the host don't
+// wait for a request that won't be made. A nil HostLimiter doesn't wait.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil || l.interval <= 0 {
+		return nil
+	}
+	// a request that has already been abandoned doesn't take up a slot
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	wait, nextRequestAt, err := l.reserver.ReserveHostRequest(ctx, host, l.interval)
+	if err != nil {
+		log.Warnf("unable to reserve a request to host %s, making the request without waiting: %s", host, err)
+		return nil
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release(host, nextRequestAt)
+		return ctx.Err()
+	}
+}
+
+// release gives back the reservation for a request to the given host that was abandoned
+func (l *HostLimiter) release(host string, nextRequestAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	_, err := l.reserver.ReleaseHostRequest(ctx, host, l.interval, nextRequestAt)
+	if err != nil {
+		log.Warnf("unable to release the abandoned request to host %s: %s", host, err)
+	}
+}
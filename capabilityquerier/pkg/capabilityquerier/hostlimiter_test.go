@@ -0,0 +1,117 @@
+package capabilityquerier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+type fakeReserver struct {
+	mu       sync.Mutex
+	hosts    []string
+	released []string
+	wait     time.Duration
+	err      error
+}
+
+func (r *fakeReserver) ReserveHostRequest(ctx context.Context, host string, interval time.Duration) (time.Duration, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts = append(r.hosts, host)
+	return r.wait, time.Now().Add(r.wait + interval), r.err
+}
+
+func (r *fakeReserver) ReleaseHostRequest(ctx context.Context, host string, interval time.Duration, nextRequestAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.released = append(r.released, host)
+	return true, nil
+}
+
+func Test_HostLimiterWait(t *testing.T) {
+	ctx := context.Background()
+
+	// waits for the reserved slot
+	reserver := &fakeReserver{wait: 50 * time.Millisecond}
+	limiter := NewHostLimiter(reserver, time.Second)
+	start := time.Now()
+	err := limiter.Wait(ctx, "example.com")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, time.Since(start) >= 50*time.Millisecond, "expected Wait to wait for the reserved slot")
+	th.Assert(t, len(reserver.hosts) == 1 && reserver.hosts[0] == "example.com", fmt.Sprintf("expected a reservation for example.com, got %v", reserver.hosts))
+
+	// no reservation is made when the limit is disabled
+	reserver = &fakeReserver{wait: time.Hour}
+	limiter = NewHostLimiter(reserver, 0)
+	err = limiter.Wait(ctx, "example.com")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(reserver.hosts) == 0, "expected no reservation when the interval is 0")
+
+	// a nil limiter doesn't wait
+	var nilLimiter *HostLimiter
+	err = nilLimiter.Wait(ctx, "example.com")
+	th.Assert(t, err == nil, err)
+
+	// requests aren't held up when the reservation fails
+	reserver = &fakeReserver{wait: time.Hour, err: errors.New("database is down")}
+	limiter = NewHostLimiter(reserver, time.Second)
+	err = limiter.Wait(ctx, "example.com")
+	th.Assert(t, err == nil, err)
+
+	// the context ending stops the wait
+	reserver = &fakeReserver{wait: time.Hour}
+	limiter = NewHostLimiter(reserver, time.Second)
+	canceledCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = limiter.Wait(canceledCtx, "example.com")
+	th.Assert(t, err == context.DeadlineExceeded, fmt.Sprintf("expected a deadline exceeded error, got %v", err))
+	th.Assert(t, len(reserver.released) == 1 && reserver.released[0] == "example.com", fmt.Sprintf("expected the abandoned reservation for example.com to be released, got %v", reserver.released))
+
+	// a request that has already been abandoned doesn't reserve a slot
+	reserver = &fakeReserver{}
+	limiter = NewHostLimiter(reserver, time.Second)
+	err = limiter.Wait(canceledCtx, "example.com")
+	th.Assert(t, err == context.DeadlineExceeded, fmt.Sprintf("expected a deadline exceeded error, got %v", err))
+	th.Assert(t, len(reserver.hosts) == 0, "expected no reservation for an abandoned request")
+}
+
+func Test_requestWithMimeTypeHostLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", fhir3PlusJSONMIMEType)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	wait := 200 * time.Millisecond
+	reserver := &fakeReserver{wait: wait}
+	limiter := NewHostLimiter(reserver, time.Second)
+	client := &http.Client{Timeout: wait / 2}
+
+	// the wait for the limiter counts towards neither the response time nor the client's timeout
+	req, err := http.NewRequest("GET", server.URL+"/metadata", nil)
+	th.Assert(t, err == nil, err)
+	start := time.Now()
+	result, err := requestWithMimeType(req, fhir3PlusJSONMIMEType, client, limiter)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, time.Since(start) >= wait, "expected the request to wait for the limiter")
+	th.Assert(t, result.responseTime >= 0 && result.responseTime < wait.Seconds(), fmt.Sprintf("expected a response time without the wait, got %f", result.responseTime))
+	th.Assert(t, len(reserver.hosts) == 1 && reserver.hosts[0] == "127.0.0.1", fmt.Sprintf("expected a reservation for 127.0.0.1, got %v", reserver.hosts))
+
+	// the request isn't sent once its context ends while it waits
+	reserver.wait = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err = http.NewRequestWithContext(ctx, "GET", server.URL+"/metadata", nil)
+	th.Assert(t, err == nil, err)
+	result, err = requestWithMimeType(req, fhir3PlusJSONMIMEType, client, limiter)
+	th.Assert(t, errors.Is(err, context.DeadlineExceeded), fmt.Sprintf("expected a deadline exceeded error, got %v", err))
+	th.Assert(t, result.responseTime == -1, fmt.Sprintf("expected a response time of -1, got %f", result.responseTime))
+	th.Assert(t, len(reserver.released) == 1, fmt.Sprintf("expected the abandoned reservation to be released, got %v", reserver.released))
+}
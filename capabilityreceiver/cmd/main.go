@@ -2,28 +2,57 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
 
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/metrics"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
 	"github.com/onc-healthit/lantern-back-end/capabilityreceiver/pkg/capabilityhandler"
+	"github.com/onc-healthit/lantern-back-end/capabilityreceiver/pkg/capabilityhandler/validation"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/quarantine"
 )
 
+// queueHooks returns the hooks that record the metrics of a queue whose messages are received, and quarantine the
+// messages that the handler panics on.
+func queueHooks(ctx context.Context, store *postgresql.Store) lanternmq.Hooks {
+	hooks := metrics.QueueHooks()
+	hooks.Panicked = quarantine.MessageHook(ctx, store)
+	return hooks
+}
+
 func setupCapStatReception(ctx context.Context, store *postgresql.Store) {
 	// Set up the queue for sending messages
 	qName := viper.GetString("capquery_qname")
 	messageQueue, channelID, err := accessqueue.ConnectToServerAndQueue(viper.GetString("quser"), viper.GetString("qpassword"), viper.GetString("qhost"), viper.GetString("qport"), qName)
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to Capability Statements Queue!")
+	messageQueue = lanternmq.Instrument(messageQueue, queueHooks(ctx, store))
+	metrics.WatchQueue(messageQueue, channelID, qName)
 	defer messageQueue.Close()
 
-	err = capabilityhandler.ReceiveCapabilityStatements(ctx, store, messageQueue, channelID, qName)
+	// Set up a channel for publishing capability statement changes to the changes exchange
+	changesExchange := viper.GetString("capchanges_exchange")
+	changesChannelID, err := messageQueue.CreateChannel()
+	helpers.FailOnError("", err)
+	// change records are only marked as published once RabbitMQ has confirmed their changes
+	err = accessqueue.ConfirmPublishes(messageQueue, changesChannelID, viper.GetInt("qconfirm_timeout"))
+	helpers.FailOnError("", err)
+
+	retryPolicy := lanternmq.RetryPolicy{
+		MaxAttempts:     viper.GetInt("qretry_maxattempts"),
+		Delay:           time.Duration(viper.GetInt("qretry_delay")) * time.Second,
+		RetryQueue:      viper.GetString("capquery_retry_qname"),
+		DeadLetterQueue: viper.GetString("capquery_deadletter_qname"),
+	}
+	err = capabilityhandler.ReceiveCapabilityStatements(ctx, store, messageQueue, channelID, qName, messageQueue, changesChannelID, changesExchange, retryPolicy)
 	helpers.FailOnError("", err)
 }
 
@@ -33,15 +62,27 @@ func setupVersionsReception(ctx context.Context, store *postgresql.Store) {
 	messageQueue, channelID, err := accessqueue.ConnectToServerAndQueue(viper.GetString("quser"), viper.GetString("qpassword"), viper.GetString("qhost"), viper.GetString("qport"), qName)
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to Versions Response Queue!")
+	messageQueue = lanternmq.Instrument(messageQueue, queueHooks(ctx, store))
+	metrics.WatchQueue(messageQueue, channelID, qName)
 	defer messageQueue.Close()
 
 	capQname := viper.GetString("endptinfo_capquery_qname")
 	capQueryQueue, capQueryChannelID, err := accessqueue.ConnectToServerAndQueue(viper.GetString("quser"), viper.GetString("qpassword"), viper.GetString("qhost"), viper.GetString("qport"), capQname)
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to capabilityquerier Queue!")
+	capQueryQueue = lanternmq.Instrument(capQueryQueue, metrics.QueueHooks())
 	defer capQueryQueue.Close()
 
-	err = capabilityhandler.ReceiveVersionResponses(ctx, store, messageQueue, channelID, qName, capQueryQueue, capQueryChannelID)
+	err = accessqueue.ConfirmPublishes(capQueryQueue, capQueryChannelID, viper.GetInt("qconfirm_timeout"))
+	helpers.FailOnError("", err)
+
+	retryPolicy := lanternmq.RetryPolicy{
+		MaxAttempts:     viper.GetInt("qretry_maxattempts"),
+		Delay:           time.Duration(viper.GetInt("qretry_delay")) * time.Second,
+		RetryQueue:      viper.GetString("versionsquery_response_retry_qname"),
+		DeadLetterQueue: viper.GetString("versionsquery_response_deadletter_qname"),
+	}
+	err = capabilityhandler.ReceiveVersionResponses(ctx, store, messageQueue, channelID, qName, capQueryQueue, capQueryChannelID, retryPolicy)
 	helpers.FailOnError("", err)
 }
 
@@ -52,6 +93,16 @@ func main() {
 	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to DB!")
+	metrics.AddHealthCheck("database", store.DB.Ping)
+
+	go func() {
+		err := metrics.Serve(viper.GetString("metrics_addr"))
+		log.Warnf("stopped serving metrics: %s", err.Error())
+	}()
+
+	// Add any declarative validation rules to the rule registry before receiving messages
+	err = validation.LoadResourceDir("/etc/lantern/resources")
+	helpers.FailOnError("", err)
 
 	ctx := context.Background()
 
@@ -26,25 +26,23 @@ type Result struct {
 type historyArgs struct {
 	fhirURL   string
 	store     *postgresql.Store
-	result    chan Result
 	isHistory bool
 }
 
 // creates jobs for the workers so that each worker updates the correct field based
 // on the given migrateDirection
 func createJobs(ctx context.Context,
-	ch chan Result,
 	urls []string,
 	store *postgresql.Store,
 	allWorkers *workers.Workers,
 	migrateDirection string,
-	isHistory bool) {
+	isHistory bool) []*workers.Result {
+	var results []*workers.Result
 	for index := range urls {
 		jobArgs := make(map[string]interface{})
 		jobArgs["historyArgs"] = historyArgs{
 			fhirURL:   urls[index],
 			store:     store,
-			result:    ch,
 			isHistory: isHistory,
 		}
 
@@ -54,30 +52,42 @@ func createJobs(ctx context.Context,
 		}
 
 		job := workers.Job{
-			Context:     ctx,
-			Duration:    time.Duration(480) * time.Second,
-			Handler:     handlerFunction,
-			HandlerArgs: &jobArgs,
+			Context:      ctx,
+			Duration:     time.Duration(480) * time.Second,
+			ValueHandler: handlerFunction,
+			HandlerArgs:  &jobArgs,
 		}
 
-		err := allWorkers.Add(&job)
+		result, err := allWorkers.Add(ctx, &job)
 		if err != nil {
 			log.Warnf("Error while adding job for getting history for URL %s, %s", urls[index], err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// waitForJobs waits for each of the given jobs to finish, logging the jobs that failed
+func waitForJobs(ctx context.Context, results []*workers.Result) {
+	for _, result := range results {
+		err := result.Wait(ctx)
+		if err != nil {
+			log.Warnf("Error while migrating a URL: %s", err)
 		}
 	}
 }
 
 // updateOperationResource gets the history data for a given URL and creates the
-// operation_resource field data based on each row's capability statement
-func updateOperationResource(ctx context.Context, args *map[string]interface{}) error {
+// operation_resource and client_operation_resource field data based on each row's capability statement
+func updateOperationResource(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	ha, ok := (*args)["historyArgs"].(historyArgs)
 	if !ok {
 		log.Warnf("unable to cast arguments to type historyArgs")
-		result := Result{
+		return Result{
 			URL: "unknown",
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	databaseTable := "fhir_endpoints_info"
@@ -88,15 +98,14 @@ func updateOperationResource(ctx context.Context, args *map[string]interface{})
 	updateFHIREndpointInfoHistoryStatement, err := ha.store.DB.Prepare(`
 		UPDATE ` + databaseTable + `
 		SET
-			operation_resource = $1
-		WHERE updated_at = $2 AND url = $3;`)
+			operation_resource = $1,
+			client_operation_resource = $2
+		WHERE updated_at = $3 AND url = $4;`)
 	if err != nil {
 		log.Warnf("unable to prepare FHIR Endpoint History Update statement %s. Error: %s", ha.fhirURL, err)
-		result := Result{
+		return Result{
 			URL: ha.fhirURL,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 	defer updateFHIREndpointInfoHistoryStatement.Close()
 
@@ -107,11 +116,9 @@ func updateOperationResource(ctx context.Context, args *map[string]interface{})
 	historyRows, err := ha.store.DB.QueryContext(ctx, selectHistory, ha.fhirURL)
 	if err != nil {
 		log.Warnf("Failed getting the history rows for URL %s. Error: %s", ha.fhirURL, err)
-		result := Result{
+		return Result{
 			URL: ha.fhirURL,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	defer historyRows.Close()
@@ -134,35 +141,36 @@ func updateOperationResource(ctx context.Context, args *map[string]interface{})
 				log.Warnf("Error while unmarshalling the rows of the history table for URL %s. Error: %s", ha.fhirURL, err)
 			}
 		}
-		operationResource := capabilityhandler.RunSupportedResourcesChecks(capInt)
+		operationResource, clientOperationResource := capabilityhandler.RunSupportedResourcesChecks(capInt)
 		operResourceJSON, err := json.Marshal(operationResource)
 		if err != nil {
 			log.Warnf("Error while convering operationResource to JSON, %+v, Error: %s", operationResource, err)
 			continue
 		}
-		_, err = updateFHIREndpointInfoHistoryStatement.ExecContext(ctx, operResourceJSON, updatedTime, ha.fhirURL)
+		clientOperResourceJSON, err := json.Marshal(clientOperationResource)
+		if err != nil {
+			log.Warnf("Error while convering clientOperationResource to JSON, %+v, Error: %s", clientOperationResource, err)
+			continue
+		}
+		_, err = updateFHIREndpointInfoHistoryStatement.ExecContext(ctx, operResourceJSON, clientOperResourceJSON, updatedTime, ha.fhirURL)
 		if err != nil {
 			log.Warnf("Error while updating the row of the history table for URL %s at %s. Error: %s", ha.fhirURL, updatedTime.String(), err)
 		}
 	}
-	result := Result{
+	return Result{
 		URL: ha.fhirURL,
-	}
-	ha.result <- result
-	return nil
+	}, nil
 }
 
 // updateSupportedResources gets the history data for a given URL and creates the
 // supported_resources field data based on each row's capability statement
-func updateSupportedResources(ctx context.Context, args *map[string]interface{}) error {
+func updateSupportedResources(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	ha, ok := (*args)["historyArgs"].(historyArgs)
 	if !ok {
 		log.Warnf("unable to cast arguments to type historyArgs")
-		result := Result{
+		return Result{
 			URL: "unknown",
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	databaseTable := "fhir_endpoints_info"
@@ -177,11 +185,9 @@ func updateSupportedResources(ctx context.Context, args *map[string]interface{})
 		WHERE updated_at = $2 AND url = $3;`)
 	if err != nil {
 		log.Warnf("unable to prepare FHIR Endpoint History Update statement %s. Error: %s", ha.fhirURL, err)
-		result := Result{
+		return Result{
 			URL: ha.fhirURL,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 	defer updateFHIREndpointInfoHistoryStatement.Close()
 
@@ -192,11 +198,9 @@ func updateSupportedResources(ctx context.Context, args *map[string]interface{})
 	historyRows, err := ha.store.DB.QueryContext(ctx, selectHistory, ha.fhirURL)
 	if err != nil {
 		log.Warnf("Failed getting the history rows for URL %s. Error: %s", ha.fhirURL, err)
-		result := Result{
+		return Result{
 			URL: ha.fhirURL,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	defer historyRows.Close()
@@ -225,11 +229,9 @@ func updateSupportedResources(ctx context.Context, args *map[string]interface{})
 			log.Warnf("Error while updating the row of the history table for URL %s at %s. Error: %s", ha.fhirURL, updatedTime.String(), err)
 		}
 	}
-	result := Result{
+	return Result{
 		URL: ha.fhirURL,
-	}
-	ha.result <- result
-	return nil
+	}, nil
 }
 
 // createSupportedResources creates the supported_resources field data based on the
@@ -298,25 +300,15 @@ func main() {
 		urls = append(urls, currURL)
 	}
 
-	errs := make(chan error)
 	numWorkers := 25
 	allWorkers := workers.NewWorkers()
 
 	// Start workers
-	err = allWorkers.Start(ctx, numWorkers, errs)
+	err = allWorkers.Start(ctx, numWorkers, nil)
 	helpers.FailOnError("Error from starting workers. Error:", err)
+	defer allWorkers.Stop()
 
-	resultCh := make(chan Result)
-	go createJobs(ctx, resultCh, urls, store, allWorkers, migrateDirection, true)
-
-	// Close the channel once we've received all results
-	count := 0
-	for range resultCh {
-		if count == len(urls)-1 {
-			close(resultCh)
-		}
-		count++
-	}
+	waitForJobs(ctx, createJobs(ctx, urls, store, allWorkers, migrateDirection, true))
 
 	// Disable the add_fhir_endpoint_info_history_trigger so updating the fhir_endpoints_info
 	// data does not add another entry in the fhir_endpoints_info_history table
@@ -348,17 +340,7 @@ func main() {
 		urls2 = append(urls2, currURL)
 	}
 
-	resultCh2 := make(chan Result)
-	go createJobs(ctx, resultCh2, urls2, store, allWorkers, migrateDirection, false)
-
-	// Close the channel once we've received all results
-	count = 0
-	for range resultCh2 {
-		if count == len(urls2)-1 {
-			close(resultCh2)
-		}
-		count++
-	}
+	waitForJobs(ctx, createJobs(ctx, urls2, store, allWorkers, migrateDirection, false))
 
 	infoHistoryTriggerEnable := `
 	ALTER TABLE fhir_endpoints_info
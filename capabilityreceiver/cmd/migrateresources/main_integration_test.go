@@ -81,22 +81,18 @@ func Test_updateOperationResource(t *testing.T) {
 	_, err = store.DB.ExecContext(ctx, addFHIREndpointInfoHistoryStatement, url2, capStat2, "I", secondTime)
 	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database again %s", err))
 
-	resultCh := make(chan Result)
-
 	// Check that data only updates the first URL
 	defaultArgs := make(map[string]interface{})
 	defaultArgs["historyArgs"] = historyArgs{
 		fhirURL:   url1,
 		store:     store,
-		result:    resultCh,
 		isHistory: true,
 	}
 
-	go updateOperationResource(ctx, &defaultArgs)
-	for res := range resultCh {
-		th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
-		close(resultCh)
-	}
+	value, err := updateOperationResource(ctx, &defaultArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
 
 	historyRows, err := store.DB.QueryContext(ctx, getFHIREndpointInfoHistoryStatement, url1)
 	th.Assert(t, err == nil, fmt.Sprintf("error getting data from fhir_endpoints_info_history: %s", err))
@@ -128,22 +124,18 @@ func Test_updateOperationResource(t *testing.T) {
 	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database third time %s", err))
 
 	// Make sure all instances of that are updated
-	resultCh2 := make(chan Result)
-
 	// Check that data only updates the first URL
 	defaultArgs2 := make(map[string]interface{})
 	defaultArgs2["historyArgs"] = historyArgs{
 		fhirURL:   url2,
 		store:     store,
-		result:    resultCh2,
 		isHistory: true,
 	}
 
-	go updateOperationResource(ctx, &defaultArgs2)
-	for res := range resultCh2 {
-		th.Assert(t, res.URL == url2, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
-		close(resultCh2)
-	}
+	value2, err := updateOperationResource(ctx, &defaultArgs2)
+	th.Assert(t, err == nil, err)
+	res2 := value2.(Result)
+	th.Assert(t, res2.URL == url2, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res2.URL))
 	historyRows, err = store.DB.QueryContext(ctx, getFHIREndpointInfoHistoryStatement, url2)
 	th.Assert(t, err == nil, fmt.Sprintf("error getting data from fhir_endpoints_info_history: %s", err))
 	// Loop through the rows
@@ -33,7 +33,6 @@ type Result struct {
 type workerArgs struct {
 	fhirURL   string
 	store     *postgresql.Store
-	result    chan Result
 	isHistory bool
 }
 
@@ -69,29 +68,26 @@ func prepareUpStatements(s *postgresql.Store) error {
 	return nil
 }
 
-func returnResult(wa workerArgs) error {
-	result := Result{
+func returnResult(wa workerArgs) (interface{}, error) {
+	return Result{
 		URL: wa.fhirURL,
-	}
-	wa.result <- result
-	return nil
+	}, nil
 }
 
 // creates jobs for the workers so that each worker updates the correct object based
 // on the given migrateDirection
 func createJobs(ctx context.Context,
-	ch chan Result,
 	urls []string,
 	store *postgresql.Store,
 	allWorkers *workers.Workers,
 	migrateDirection string,
-	isHistory bool) {
+	isHistory bool) []*workers.Result {
+	var results []*workers.Result
 	for index := range urls {
 		jobArgs := make(map[string]interface{})
 		jobArgs["workerArgs"] = workerArgs{
 			fhirURL:   urls[index],
 			store:     store,
-			result:    ch,
 			isHistory: isHistory,
 		}
 
@@ -104,30 +100,42 @@ func createJobs(ctx context.Context,
 		}
 
 		job := workers.Job{
-			Context:     ctx,
-			Duration:    time.Duration(480) * time.Second,
-			Handler:     handlerFunction,
-			HandlerArgs: &jobArgs,
+			Context:      ctx,
+			Duration:     time.Duration(480) * time.Second,
+			ValueHandler: handlerFunction,
+			HandlerArgs:  &jobArgs,
 		}
 
-		err := allWorkers.Add(&job)
+		result, err := allWorkers.Add(ctx, &job)
 		if err != nil {
 			log.Warnf("Error while adding job for getting history for URL %s, %s", urls[index], err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// waitForJobs waits for each of the given jobs to finish, logging the jobs that failed
+func waitForJobs(ctx context.Context, results []*workers.Result) {
+	for _, result := range results {
+		err := result.Wait(ctx)
+		if err != nil {
+			log.Warnf("Error while migrating a URL: %s", err)
 		}
 	}
 }
 
 // addToValidationTableHistory gets the history table data for a given URL and creates the
 // validation table rows based on each row's capability statement
-func addToValidationTableHistory(ctx context.Context, args *map[string]interface{}) error {
+func addToValidationTableHistory(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	wa, ok := (*args)["workerArgs"].(workerArgs)
 	if !ok {
 		log.Warnf("unable to cast arguments to type workerArgs")
-		result := Result{
+		return Result{
 			URL: "unknown",
-		}
-		wa.result <- result
-		return nil
+		}, nil
 	}
 
 	// Get validation information from the specified table table for the given URL
@@ -175,7 +183,7 @@ func addToValidationTableHistory(ctx context.Context, args *map[string]interface
 		}
 
 		validator := validation.ValidatorForFHIRVersion(fhirVersion)
-		validationObj := validator.RunValidation(capStat, fhirVersion, val.tlsVersion, smartResp, "None", "None")
+		validationObj := validator.RunValidation(capStat, fhirVersion, val.tlsVersion, smartResp, "None", "None", nil)
 		valResID, err := wa.store.AddValidationResult(ctx)
 		if err != nil {
 			log.Warnf("Failed to add a new ID. Error: %s", err)
@@ -201,15 +209,13 @@ func addToValidationTableHistory(ctx context.Context, args *map[string]interface
 // since the current data in info table is also in the history table, get the ID
 // that was generated for the associated history table row and use that for the
 // info table
-func addToValidationTableInfo(ctx context.Context, args *map[string]interface{}) error {
+func addToValidationTableInfo(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	wa, ok := (*args)["workerArgs"].(workerArgs)
 	if !ok {
 		log.Warnf("unable to cast arguments to type workerArgs")
-		result := Result{
+		return Result{
 			URL: "unknown",
-		}
-		wa.result <- result
-		return nil
+		}, nil
 	}
 
 	selectHistory := `SELECT validation_result_id FROM fhir_endpoints_info_history
@@ -232,15 +238,13 @@ func addToValidationTableInfo(ctx context.Context, args *map[string]interface{})
 
 // addToValidationField gets the table data for a given URL and creates the
 // validation field data based on each row's capability statement
-func addToValidationField(ctx context.Context, args *map[string]interface{}) error {
+func addToValidationField(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	wa, ok := (*args)["workerArgs"].(workerArgs)
 	if !ok {
 		log.Warnf("unable to cast arguments to type workerArgs")
-		result := Result{
+		return Result{
 			URL: "unknown",
-		}
-		wa.result <- result
-		return nil
+		}, nil
 	}
 
 	databaseTable := "fhir_endpoints_info"
@@ -300,7 +304,7 @@ func addToValidationField(ctx context.Context, args *map[string]interface{}) err
 			fhirVersion, _ = capStat.GetFHIRVersion()
 		}
 		validator := validation.ValidatorForFHIRVersion(fhirVersion)
-		validationObj := validator.RunValidation(capStat, fhirVersion, val.tlsVersion, smartResp, "None", "None")
+		validationObj := validator.RunValidation(capStat, fhirVersion, val.tlsVersion, smartResp, "None", "None", nil)
 		validationJSON, err := json.Marshal(validationObj)
 		if err != nil {
 			log.Warnf("Error marshalling object to JSON. Error: %s", err)
@@ -362,25 +366,15 @@ func main() {
 		urls = append(urls, currURL)
 	}
 
-	errs := make(chan error)
 	numWorkers := 10
 	allWorkers := workers.NewWorkers()
 
 	// Start workers
-	err = allWorkers.Start(ctx, numWorkers, errs)
+	err = allWorkers.Start(ctx, numWorkers, nil)
 	helpers.FailOnError("Error from starting workers. Error:", err)
+	defer allWorkers.Stop()
 
-	resultCh := make(chan Result)
-	go createJobs(ctx, resultCh, urls, store, allWorkers, migrateDirection, true)
-
-	// Close the channel once we've received all results
-	count := 0
-	for range resultCh {
-		if count == len(urls)-1 {
-			close(resultCh)
-		}
-		count++
-	}
+	waitForJobs(ctx, createJobs(ctx, urls, store, allWorkers, migrateDirection, true))
 
 	// Disable the add_fhir_endpoint_info_history_trigger so updating the fhir_endpoints_info
 	// data does not add another entry in the fhir_endpoints_info_history table
@@ -412,17 +406,7 @@ func main() {
 		urls2 = append(urls2, currURL)
 	}
 
-	resultCh2 := make(chan Result)
-	go createJobs(ctx, resultCh2, urls2, store, allWorkers, migrateDirection, false)
-
-	// Close the channel once we've received all results
-	count = 0
-	for range resultCh2 {
-		if count == len(urls2)-1 {
-			close(resultCh2)
-		}
-		count++
-	}
+	waitForJobs(ctx, createJobs(ctx, urls2, store, allWorkers, migrateDirection, false))
 
 	infoHistoryTriggerEnable := `
 	ALTER TABLE fhir_endpoints_info
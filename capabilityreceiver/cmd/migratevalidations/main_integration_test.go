@@ -117,22 +117,18 @@ func Test_addToValidationTableHistory(t *testing.T) {
 	_, err = store.DB.ExecContext(ctx, addFHIREndpointInfoStatement, url2, "I", capStat2, tlsVersion, pq.Array(mimeTypes), metadataID2, secondTime)
 	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database again %s", err))
 
-	resultCh := make(chan Result)
-
 	// Check that data only updates the first URL
 	defaultArgs := make(map[string]interface{})
 	defaultArgs["workerArgs"] = workerArgs{
 		fhirURL:   url1,
 		store:     store,
-		result:    resultCh,
 		isHistory: true,
 	}
 
-	go addToValidationTableHistory(ctx, &defaultArgs)
-	for res := range resultCh {
-		th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
-		close(resultCh)
-	}
+	value, err := addToValidationTableHistory(ctx, &defaultArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
 
 	historyRows, err := store.DB.QueryContext(ctx, getFHIREndpointInfoStatement, url1)
 	th.Assert(t, err == nil, fmt.Sprintf("error getting data from fhir_endpoints_info: %s", err))
@@ -169,22 +165,18 @@ func Test_addToValidationTableHistory(t *testing.T) {
 	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database third time %s", err))
 
 	// Make sure all instances of that are updated
-	resultCh2 := make(chan Result)
-
 	// Check that data only updates the second URL
 	defaultArgs2 := make(map[string]interface{})
 	defaultArgs2["workerArgs"] = workerArgs{
 		fhirURL:   url2,
 		store:     store,
-		result:    resultCh2,
 		isHistory: true,
 	}
 
-	go addToValidationTableHistory(ctx, &defaultArgs2)
-	for res := range resultCh2 {
-		th.Assert(t, res.URL == url2, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
-		close(resultCh2)
-	}
+	value2, err := addToValidationTableHistory(ctx, &defaultArgs2)
+	th.Assert(t, err == nil, err)
+	res2 := value2.(Result)
+	th.Assert(t, res2.URL == url2, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res2.URL))
 	historyRows, err = store.DB.QueryContext(ctx, getFHIREndpointInfoStatement, url2)
 	th.Assert(t, err == nil, fmt.Sprintf("error getting data from fhir_endpoints_info: %s", err))
 	// Check that both entries with url2 have been updated and that they don't have the same validation result ID
@@ -294,20 +286,17 @@ func Test_addToValidationTableInfo(t *testing.T) {
 
 	// Check that the info entry is updated to the same ID as the second history entry
 
-	resultCh := make(chan Result)
 	defaultArgs := make(map[string]interface{})
 	defaultArgs["workerArgs"] = workerArgs{
 		fhirURL:   url1,
 		store:     store,
-		result:    resultCh,
 		isHistory: false,
 	}
 
-	go addToValidationTableInfo(ctx, &defaultArgs)
-	for res := range resultCh {
-		th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
-		close(resultCh)
-	}
+	value, err := addToValidationTableInfo(ctx, &defaultArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, res.URL == url1, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url1, res.URL))
 
 	infoRows, err := store.DB.QueryContext(ctx, getFHIREndpointInfoStatement, url1)
 	th.Assert(t, err == nil, fmt.Sprintf("error getting data from fhir_endpoints_info: %s", err))
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/onc-healthit/lantern-back-end/capabilityreceiver/pkg/capabilityhandler/validation"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/smartparser"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/workers"
+)
+
+var tables = []string{"fhir_endpoints_info", "fhir_endpoints_info_history"}
+
+// Result is the value that is returned from revalidating the stored data for the given URL
+type Result struct {
+	URL   string
+	Count int
+}
+
+// filter limits which stored rows are revalidated. Empty fields are not filtered on.
+type filter struct {
+	start        string
+	end          string
+	fhirVersions []string
+}
+
+type workerArgs struct {
+	fhirURL        string
+	store          *postgresql.Store
+	filter         filter
+	rulesetVersion string
+}
+
+type revalidationRow struct {
+	capStatByte          []byte
+	tlsVersion           string
+	smartResponseByte    []byte
+	requestedFhirVersion string
+	redirects            []endpointmanager.Redirect
+	validationResultID   int
+}
+
+// selectRowsQuery returns the query and arguments that select the rows of the given table for the given URL
+// that match the filter and have not already been revalidated with the given ruleset version. Rows without a
+// validation result are not selected, since their new result could not be linked to anything to compare it with.
+func selectRowsQuery(table string, fhirURL string, f filter, rulesetVersion string) (string, []interface{}) {
+	query := `SELECT capability_statement, tls_version, smart_response, requested_fhir_version,
+		(SELECT redirects FROM fhir_endpoints_metadata WHERE fhir_endpoints_metadata.id = ` + table + `.metadata_id),
+		validation_result_id
+		FROM ` + table + `
+		WHERE url = $1
+		AND validation_result_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM validation_results
+			WHERE validation_results.revalidated_from = ` + table + `.validation_result_id
+			AND validation_results.ruleset_version = $2)`
+	args := []interface{}{fhirURL, rulesetVersion}
+
+	if f.start != "" {
+		args = append(args, f.start)
+		query += fmt.Sprintf(" AND updated_at >= $%d::date", len(args))
+	}
+	if f.end != "" {
+		args = append(args, f.end)
+		query += fmt.Sprintf(" AND updated_at < $%d::date + 1", len(args))
+	}
+	if len(f.fhirVersions) > 0 {
+		args = append(args, pq.Array(f.fhirVersions))
+		query += fmt.Sprintf(" AND capability_fhir_version = ANY($%d)", len(args))
+	}
+
+	return query + ";", args
+}
+
+// splitList splits a comma separated command line value into its trimmed, non empty parts
+func splitList(value string) []string {
+	var list []string
+	for _, elem := range strings.Split(value, ",") {
+		elem = strings.TrimSpace(elem)
+		if elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+func returnResult(wa workerArgs, count int) (interface{}, error) {
+	return Result{
+		URL:   wa.fhirURL,
+		Count: count,
+	}, nil
+}
+
+// creates jobs for the workers so that each worker revalidates the stored data for one url, and returns
+// the workers' results for the jobs
+func createJobs(ctx context.Context,
+	urls []string,
+	store *postgresql.Store,
+	allWorkers *workers.Workers,
+	f filter,
+	rulesetVersion string) []*workers.Result {
+	var results []*workers.Result
+	for index := range urls {
+		jobArgs := make(map[string]interface{})
+		jobArgs["workerArgs"] = workerArgs{
+			fhirURL:        urls[index],
+			store:          store,
+			filter:         f,
+			rulesetVersion: rulesetVersion,
+		}
+
+		job := workers.Job{
+			Context:      ctx,
+			Duration:     time.Duration(480) * time.Second,
+			ValueHandler: revalidateURL,
+			HandlerArgs:  &jobArgs,
+		}
+
+		result, err := allWorkers.Add(ctx, &job)
+		if err != nil {
+			log.Warnf("Error while adding job for revalidating URL %s, %s", urls[index], err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// revalidateURL runs the current validation rules over the capability statements and SMART responses
+// stored for the given URL in the fhir_endpoints_info and fhir_endpoints_info_history tables, and stores
+// each result as a new validation result tagged with the ruleset version
+func revalidateURL(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
+	wa, ok := (*args)["workerArgs"].(workerArgs)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast arguments to type workerArgs")
+	}
+
+	// the info table row shares its validation result with its latest history table row, so only
+	// revalidate each stored validation result once
+	revalidated := make(map[int]bool)
+	count := 0
+
+	for _, table := range tables {
+		query, queryArgs := selectRowsQuery(table, wa.fhirURL, wa.filter, wa.rulesetVersion)
+		rows, err := wa.store.DB.QueryContext(ctx, query, queryArgs...)
+		if err != nil {
+			log.Warnf("Failed getting the %s rows for URL %s. Error: %s", table, wa.fhirURL, err)
+			return returnResult(wa, count)
+		}
+
+		var revalidationRows []revalidationRow
+		for rows.Next() {
+			var row revalidationRow
+			var tlsVersion sql.NullString
+			var requestedFhirVersion sql.NullString
+			var redirectsJSON []byte
+			err = rows.Scan(&row.capStatByte,
+				&tlsVersion,
+				&row.smartResponseByte,
+				&requestedFhirVersion,
+				&redirectsJSON,
+				&row.validationResultID)
+			if err != nil {
+				log.Warnf("Error while scanning the rows of the %s table for URL %s. Error: %s", table, wa.fhirURL, err)
+				continue
+			}
+			row.tlsVersion = tlsVersion.String
+			row.requestedFhirVersion = requestedFhirVersion.String
+			if redirectsJSON != nil {
+				err = json.Unmarshal(redirectsJSON, &row.redirects)
+				if err != nil {
+					log.Warnf("Error while unmarshalling the redirects for URL %s. Error: %s", wa.fhirURL, err)
+				}
+			}
+			revalidationRows = append(revalidationRows, row)
+		}
+		rows.Close()
+
+		for _, row := range revalidationRows {
+			if revalidated[row.validationResultID] {
+				continue
+			}
+			revalidated[row.validationResultID] = true
+
+			// Create the capability statement object
+			capStat, err := capabilityparser.NewCapabilityStatement(row.capStatByte)
+			if err != nil {
+				log.Warnf("unable to parse CapabilityStatement for url %s. Error: %s", wa.fhirURL, err)
+			}
+
+			// Create smart response object
+			smartResp, err := smartparser.NewSMARTResp(row.smartResponseByte)
+			if err != nil {
+				log.Warnf("Error while unmarshalling the smart response for URL %s. Error: %s", wa.fhirURL, err)
+			}
+
+			fhirVersion := ""
+			if capStat != nil {
+				fhirVersion, _ = capStat.GetFHIRVersion()
+			}
+
+			// The $versions default is not stored with the endpoint info, so the versions response rule is not rerun
+			validator := validation.ValidatorForFHIRVersion(fhirVersion)
+			validationObj := validator.RunValidation(capStat, fhirVersion, row.tlsVersion, smartResp, row.requestedFhirVersion, "", row.redirects)
+
+			valResID, err := wa.store.AddRevalidationResult(ctx, wa.rulesetVersion, row.validationResultID)
+			if err != nil {
+				log.Warnf("Failed to add a new validation result ID for URL %s. Error: %s", wa.fhirURL, err)
+				return returnResult(wa, count)
+			}
+			err = wa.store.AddValidation(ctx, &validationObj, valResID)
+			if err != nil {
+				log.Warnf("Failed to add validation for URL %s. Error: %s", wa.fhirURL, err)
+				return returnResult(wa, count)
+			}
+			count++
+		}
+	}
+
+	return returnResult(wa, count)
+}
+
+// Revalidate the capability statements and SMART responses stored in the fhir_endpoints_info and
+// fhir_endpoints_info_history tables with the current validation rules. The new validation results are
+// tagged with the given ruleset version and linked to the validation results they were recreated from,
+// so the results of the two rulesets can be compared.
+func main() {
+	rulesetVersion := flag.String("ruleset", "", "version of the validation rules being run (required)")
+	start := flag.String("start", "", "only revalidate data updated on or after this date, formatted as 2021-01-31")
+	end := flag.String("end", "", "only revalidate data updated on or before this date, formatted as 2021-01-31")
+	fhirVersions := flag.String("versions", "", "comma separated list of capability statement FHIR versions to revalidate")
+	urlList := flag.String("urls", "", "comma separated list of URLs to revalidate")
+	flag.Parse()
+
+	if *rulesetVersion == "" {
+		log.Fatalf("ERROR: Missing the ruleset version. Usage: revalidate -ruleset=<version> [-start=<date>] [-end=<date>] [-versions=<versions>] [-urls=<urls>]")
+	}
+	for _, date := range []string{*start, *end} {
+		if date == "" {
+			continue
+		}
+		_, err := time.Parse("2006-01-02", date)
+		helpers.FailOnError(fmt.Sprintf("Invalid date %s. Error:", date), err)
+	}
+
+	err := config.SetupConfig()
+	helpers.FailOnError("", err)
+
+	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	helpers.FailOnError("", err)
+	log.Info("Successfully connected to DB!")
+
+	err = validation.LoadResourceDir("/etc/lantern/resources")
+	helpers.FailOnError("", err)
+
+	ctx := context.Background()
+
+	urls := splitList(*urlList)
+	if len(urls) == 0 {
+		sqlQuery := "SELECT url FROM fhir_endpoints_info UNION SELECT url FROM fhir_endpoints_info_history;"
+		rows, err := store.DB.QueryContext(ctx, sqlQuery)
+		helpers.FailOnError("Make sure that the database is not empty. Error:", err)
+
+		defer rows.Close()
+		for rows.Next() {
+			var currURL string
+			err = rows.Scan(&currURL)
+			helpers.FailOnError("Error scanning the row. Error:", err)
+
+			urls = append(urls, currURL)
+		}
+	}
+	if len(urls) == 0 {
+		log.Info("No URLs to revalidate")
+		return
+	}
+
+	f := filter{
+		start:        *start,
+		end:          *end,
+		fhirVersions: splitList(*fhirVersions),
+	}
+
+	numWorkers := 10
+	allWorkers := workers.NewWorkers()
+
+	// Start workers
+	err = allWorkers.Start(ctx, numWorkers, nil)
+	helpers.FailOnError("Error from starting workers. Error:", err)
+	defer allWorkers.Stop()
+
+	results := createJobs(ctx, urls, store, allWorkers, f, *rulesetVersion)
+
+	total := 0
+	for _, result := range results {
+		err = result.Wait(ctx)
+		if err != nil {
+			log.Warnf("Error while revalidating a URL: %s", err)
+			continue
+		}
+		if res, ok := result.Value().(Result); ok {
+			total += res.Count
+		}
+	}
+
+	log.Infof("Successfully revalidated %d stored results for %d URLs with ruleset version %s", total, len(urls), *rulesetVersion)
+}
@@ -0,0 +1,202 @@
+// +build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/spf13/viper"
+)
+
+var store *postgresql.Store
+
+var capStat []byte
+
+var testMetadata = endpointmanager.FHIREndpointMetadata{
+	HTTPResponse:      200,
+	SMARTHTTPResponse: 200,
+}
+
+func TestMain(m *testing.M) {
+	var err error
+
+	err = config.SetupConfigForTests()
+	if err != nil {
+		panic(err)
+	}
+
+	err = setup()
+	if err != nil {
+		panic(err)
+	}
+
+	hap := th.HostAndPort{Host: viper.GetString("dbhost"), Port: viper.GetString("dbport")}
+	err = th.CheckResources(hap)
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	teardown()
+	os.Exit(code)
+}
+
+func Test_revalidateURL(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	setupCapabilityStatement(t, filepath.Join("../../testdata", "cerner_capability_dstu2.json"))
+	ctx := context.Background()
+
+	addFHIREndpointInfoStatement := `
+		INSERT INTO fhir_endpoints_info_history (
+			url,
+			operation,
+			capability_statement,
+			tls_version,
+			mime_types,
+			metadata_id,
+			updated_at,
+			validation_result_id,
+			capability_fhir_version
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	getRevalidationResultsStatement := `
+		SELECT id, revalidated_from
+		FROM validation_results
+		WHERE ruleset_version=$1
+		ORDER BY revalidated_from`
+
+	getValidationStatement := `
+		SELECT COUNT(*)
+		FROM validations
+		WHERE validation_result_id=$1`
+
+	metadataID, err := store.AddFHIREndpointMetadata(ctx, &testMetadata)
+	th.Assert(t, err == nil, fmt.Sprintf("Error while adding metadata object: %s", err))
+
+	valResID1, err := store.AddValidationResult(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding validation result ID: %s", err))
+	valResID2, err := store.AddValidationResult(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding validation result ID: %s", err))
+
+	// Put two entries for the URL in the history table, one stored before the filter start date
+	tlsVersion := "TLS 1.2"
+	mimeTypes := []string{"application/json+fhir"}
+	url := "www.testurl.com/cerner/DSTU2"
+	oldTime := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err = store.DB.ExecContext(ctx, addFHIREndpointInfoStatement, url, "I", capStat, tlsVersion, pq.Array(mimeTypes), metadataID, oldTime, valResID1, "1.0.2")
+	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database %s", err))
+
+	newTime := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err = store.DB.ExecContext(ctx, addFHIREndpointInfoStatement, url, "U", capStat, tlsVersion, pq.Array(mimeTypes), metadataID, newTime, valResID2, "1.0.2")
+	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database again %s", err))
+
+	// An entry stored without a validation result has nothing to revalidate from, so it is skipped
+	_, err = store.DB.ExecContext(ctx, addFHIREndpointInfoStatement, url, "U", capStat, tlsVersion, pq.Array(mimeTypes), metadataID, newTime.Add(time.Hour), nil, "1.0.2")
+	th.Assert(t, err == nil, fmt.Sprintf("Error when adding to the database again %s", err))
+
+	runRevalidation := func(f filter, rulesetVersion string) Result {
+		args := make(map[string]interface{})
+		args["workerArgs"] = workerArgs{
+			fhirURL:        url,
+			store:          store,
+			filter:         f,
+			rulesetVersion: rulesetVersion,
+		}
+
+		value, err := revalidateURL(ctx, &args)
+		th.Assert(t, err == nil, err)
+		return value.(Result)
+	}
+
+	// Only the entry after the start date should be revalidated
+	res := runRevalidation(filter{start: "2021-01-01"}, "test-1")
+	th.Assert(t, res.URL == url, fmt.Sprintf("Returned result URL is not equal to %s, is instead %s", url, res.URL))
+	th.Assert(t, res.Count == 1, fmt.Sprintf("Expected 1 revalidated entry, got %d", res.Count))
+
+	rows, err := store.DB.QueryContext(ctx, getRevalidationResultsStatement, "test-1")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting the revalidation results: %s", err))
+	var newIDs []int
+	for rows.Next() {
+		var id int
+		var sourceID int
+		err = rows.Scan(&id, &sourceID)
+		th.Assert(t, err == nil, fmt.Sprintf("Error scanning the revalidation results: %s", err))
+		th.Assert(t, sourceID == valResID2, fmt.Sprintf("Revalidation result should come from %d, comes from %d", valResID2, sourceID))
+		newIDs = append(newIDs, id)
+	}
+	rows.Close()
+	th.Assert(t, len(newIDs) == 1, fmt.Sprintf("Expected 1 revalidation result, got %d", len(newIDs)))
+
+	valCount := 0
+	err = store.DB.QueryRowContext(ctx, getValidationStatement, newIDs[0]).Scan(&valCount)
+	th.Assert(t, err == nil, fmt.Sprintf("Err should be nil, is instead %s", err))
+	th.Assert(t, valCount > 0, fmt.Sprintf("There should be entries in the validations table with id %d", newIDs[0]))
+
+	// Rerunning the same ruleset should only revalidate the entry that was not already revalidated
+	res = runRevalidation(filter{}, "test-1")
+	th.Assert(t, res.Count == 1, fmt.Sprintf("Expected 1 revalidated entry on the second run, got %d", res.Count))
+	res = runRevalidation(filter{}, "test-1")
+	th.Assert(t, res.Count == 0, fmt.Sprintf("Expected no revalidated entries on the third run, got %d", res.Count))
+
+	// Filtering on a different FHIR version should not revalidate anything
+	res = runRevalidation(filter{fhirVersions: []string{"4.0.1"}}, "test-2")
+	th.Assert(t, res.Count == 0, fmt.Sprintf("Expected no revalidated entries for FHIR version 4.0.1, got %d", res.Count))
+
+	// The stored entries should keep their original validation results
+	var historyValResID int
+	err = store.DB.QueryRowContext(ctx, "SELECT validation_result_id FROM fhir_endpoints_info_history WHERE url=$1 AND updated_at=$2", url, newTime).Scan(&historyValResID)
+	th.Assert(t, err == nil, fmt.Sprintf("Err should be nil, is instead %s", err))
+	th.Assert(t, historyValResID == valResID2, fmt.Sprintf("The history entry validation result ID should be %d, is instead %d", valResID2, historyValResID))
+}
+
+func Test_revalidateURLArgs(t *testing.T) {
+	args := make(map[string]interface{})
+	args["workerArgs"] = "not workerArgs"
+
+	_, err := revalidateURL(context.Background(), &args)
+	th.Assert(t, err != nil, "Expected an error when the arguments are not workerArgs")
+}
+
+func Test_splitList(t *testing.T) {
+	list := splitList(" 4.0.1, ,1.0.2,")
+	th.Assert(t, len(list) == 2, fmt.Sprintf("Expected 2 elements, got %d", len(list)))
+	th.Assert(t, list[0] == "4.0.1" && list[1] == "1.0.2", fmt.Sprintf("Expected [4.0.1 1.0.2], got %v", list))
+
+	list = splitList("")
+	th.Assert(t, len(list) == 0, fmt.Sprintf("Expected no elements, got %d", len(list)))
+}
+
+func setupCapabilityStatement(t *testing.T, path string) {
+	csJSON, err := ioutil.ReadFile(path)
+	th.Assert(t, err == nil, err)
+	cs, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+	capStat, err = cs.GetJSON()
+	th.Assert(t, err == nil, err)
+}
+
+func setup() error {
+	var err error
+	store, err = postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	return err
+}
+
+func teardown() {
+	store.Close()
+}
@@ -10,3 +10,7 @@ require (
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/viper v1.10.1
 )
+
+replace github.com/onc-healthit/lantern-back-end/endpointmanager => ../endpointmanager
+
+replace github.com/onc-healthit/lantern-back-end/lanternmq => ../lanternmq
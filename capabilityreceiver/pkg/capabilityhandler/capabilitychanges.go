@@ -0,0 +1,119 @@
+package capabilityhandler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// capabilityChangesBatchSize is the number of unpublished capability change records that are read from the
+// database at a time
+const capabilityChangesBatchSize = 100
+
+// capabilityChangeMessage is the message published to the capability changes exchange for each change found
+// between an endpoint's stored capability statement and the capability statement it returned
+type capabilityChangeMessage struct {
+	URL                  string                `json:"url"`
+	RequestedFhirVersion string                `json:"requestedFhirVersion"`
+	VendorID             int                   `json:"vendorID"`
+	ChangeID             int                   `json:"changeID"`
+	Change               capabilitydiff.Change `json:"change"`
+}
+
+// newCapabilityChange returns the change record of the given changes of the given endpoint, or nil if there are
+// no changes.
+func newCapabilityChange(endpt *endpointmanager.FHIREndpointInfo, changes []capabilitydiff.Change) *endpointmanager.CapabilityChange {
+	if len(changes) == 0 {
+		return nil
+	}
+	return &endpointmanager.CapabilityChange{
+		URL:                  endpt.URL,
+		RequestedFhirVersion: endpt.RequestedFhirVersion,
+		VendorID:             endpt.VendorID,
+		Changes:              changes,
+	}
+}
+
+// notifyCapabilityChanges lets the capability changes publisher know that a change record was stored, without
+// waiting for it. Nothing is done if there is no publisher.
+func notifyCapabilityChanges(newChanges chan<- struct{}) {
+	if newChanges == nil {
+		return
+	}
+	select {
+	case newChanges <- struct{}{}:
+	default:
+		// the publisher has already been notified
+	}
+}
+
+// relayCapabilityChanges publishes the capability change records that have not been published yet each time
+// 'newChanges' receives, and every 'interval' so that the records that could not be published are retried,
+// until the context ends.
+func relayCapabilityChanges(ctx context.Context, store *postgresql.Store, mq lanternmq.MessageQueue, chID lanternmq.ChannelID, exchange string, interval time.Duration, newChanges <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := publishCapabilityChanges(ctx, store, mq, chID, exchange)
+		if err != nil {
+			log.Warnf("unable to publish capability changes, retrying in %s: %s", interval, err)
+		}
+
+		select {
+		case <-newChanges:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishCapabilityChanges publishes each change of the capability change records that have not been published
+// yet on the capability changes exchange, using the change's routing key, and records that the records were
+// published. The records are published from oldest to newest, and publishing stops at the first error, so that
+// the record is published again the next time.
+func publishCapabilityChanges(ctx context.Context, store *postgresql.Store, mq lanternmq.MessageQueue, chID lanternmq.ChannelID, exchange string) error {
+	for {
+		changeRecords, err := store.GetUnpublishedCapabilityChanges(ctx, capabilityChangesBatchSize)
+		if err != nil {
+			return errors.Wrap(err, "getting unpublished capability changes failed")
+		}
+		if len(changeRecords) == 0 {
+			return nil
+		}
+
+		for _, changeRecord := range changeRecords {
+			for _, change := range changeRecord.Changes {
+				msg := capabilityChangeMessage{
+					URL:                  changeRecord.URL,
+					RequestedFhirVersion: changeRecord.RequestedFhirVersion,
+					VendorID:             changeRecord.VendorID,
+					ChangeID:             changeRecord.ID,
+					Change:               change,
+				}
+				msgBytes, err := json.Marshal(msg)
+				if err != nil {
+					return err
+				}
+				err = accessqueue.SendToExchange(ctx, string(msgBytes), &mq, &chID, exchange, change.RoutingKey())
+				if err != nil {
+					return errors.Wrapf(err, "publishing capability change %s of change record %d failed", change.RoutingKey(), changeRecord.ID)
+				}
+			}
+
+			err = store.SetCapabilityChangePublished(ctx, changeRecord)
+			if err != nil {
+				return errors.Wrapf(err, "recording that change record %d was published failed", changeRecord.ID)
+			}
+		}
+	}
+}
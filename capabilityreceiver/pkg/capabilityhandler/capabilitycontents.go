@@ -0,0 +1,186 @@
+package capabilityhandler
+
+import (
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// maxFieldLength is the length of the VARCHAR columns of the capability contents tables
+const maxFieldLength = 500
+
+// ParseCapabilityContents takes the given capability statement and returns the contents of each of its
+// rest entries, including client mode entries, so they can be stored in the normalized capability
+// contents tables. It handles DSTU2, STU3 and R4 capability statements, and skips over any elements
+// that do not have the expected type.
+func ParseCapabilityContents(capInt map[string]interface{}) *endpointmanager.CapabilityContents {
+	var contents endpointmanager.CapabilityContents
+	if capInt == nil {
+		return &contents
+	}
+
+	restArr, _ := capInt["rest"].([]interface{})
+	for _, rest := range restArr {
+		restInt, ok := rest.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		restContents := endpointmanager.CapabilityRest{
+			Mode:             stringField(restInt["mode"]),
+			Interactions:     interactionCodes(restInt["interaction"]),
+			SearchParams:     searchParams(restInt["searchParam"]),
+			Operations:       operations(restInt["operation"]),
+			SecurityServices: securityServices(restInt["security"]),
+		}
+
+		resourceArr, _ := restInt["resource"].([]interface{})
+		for _, resource := range resourceArr {
+			resourceInt, ok := resource.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			resourceType := stringField(resourceInt["type"])
+			if resourceType == "" {
+				continue
+			}
+
+			restContents.Resources = append(restContents.Resources, endpointmanager.CapabilityResource{
+				Type:              resourceType,
+				Profile:           stringField(resourceInt["profile"]),
+				SupportedProfiles: stringList(resourceInt["supportedProfile"]),
+				Interactions:      interactionCodes(resourceInt["interaction"]),
+				SearchParams:      searchParams(resourceInt["searchParam"]),
+				SearchIncludes:    stringList(resourceInt["searchInclude"]),
+				SearchRevIncludes: stringList(resourceInt["searchRevInclude"]),
+				Operations:        operations(resourceInt["operation"]),
+			})
+		}
+
+		contents.Rest = append(contents.Rest, restContents)
+	}
+
+	return &contents
+}
+
+// interactionCodes returns the codes of the given list of interactions
+func interactionCodes(interactions interface{}) []string {
+	var codes []string
+	interactionArr, _ := interactions.([]interface{})
+	for _, interaction := range interactionArr {
+		interactionInt, ok := interaction.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		code := stringField(interactionInt["code"])
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// searchParams returns the name, type and definition of each of the given search parameters
+func searchParams(params interface{}) []endpointmanager.CapabilitySearchParam {
+	var searchParams []endpointmanager.CapabilitySearchParam
+	paramArr, _ := params.([]interface{})
+	for _, param := range paramArr {
+		paramInt, ok := param.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := stringField(paramInt["name"])
+		if name == "" {
+			continue
+		}
+		searchParams = append(searchParams, endpointmanager.CapabilitySearchParam{
+			Name:       name,
+			Type:       stringField(paramInt["type"]),
+			Definition: stringField(paramInt["definition"]),
+		})
+	}
+	return searchParams
+}
+
+// operations returns the name and definition of each of the given operations
+func operations(ops interface{}) []endpointmanager.CapabilityOperation {
+	var operations []endpointmanager.CapabilityOperation
+	opArr, _ := ops.([]interface{})
+	for _, op := range opArr {
+		opInt, ok := op.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := stringField(opInt["name"])
+		if name == "" {
+			continue
+		}
+		operations = append(operations, endpointmanager.CapabilityOperation{
+			Name:       name,
+			Definition: stringField(opInt["definition"]),
+		})
+	}
+	return operations
+}
+
+// securityServices returns the codings of the services in the given rest security element
+func securityServices(security interface{}) []endpointmanager.CapabilitySecurityService {
+	var services []endpointmanager.CapabilitySecurityService
+	securityInt, ok := security.(map[string]interface{})
+	if !ok {
+		return services
+	}
+	serviceArr, _ := securityInt["service"].([]interface{})
+	for _, service := range serviceArr {
+		serviceInt, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		codingArr, _ := serviceInt["coding"].([]interface{})
+		if len(codingArr) == 0 && serviceInt["text"] != nil {
+			// services that only have text are stored with the text as their display
+			services = append(services, endpointmanager.CapabilitySecurityService{
+				Display: stringField(serviceInt["text"]),
+			})
+			continue
+		}
+		for _, coding := range codingArr {
+			codingInt, ok := coding.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			services = append(services, endpointmanager.CapabilitySecurityService{
+				System:  stringField(codingInt["system"]),
+				Code:    stringField(codingInt["code"]),
+				Display: stringField(codingInt["display"]),
+			})
+		}
+	}
+	return services
+}
+
+// stringList returns the string elements of the given list
+func stringList(list interface{}) []string {
+	var strs []string
+	listArr, _ := list.([]interface{})
+	for _, elem := range listArr {
+		str := stringField(elem)
+		if str != "" {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// stringField returns the given value if it is a string, or its reference if it is a DSTU2 or STU3
+// Reference, truncated to fit in the capability contents tables
+func stringField(value interface{}) string {
+	str, ok := value.(string)
+	if !ok {
+		if valueInt, isMap := value.(map[string]interface{}); isMap {
+			str, _ = valueInt["reference"].(string)
+		}
+	}
+	if len(str) > maxFieldLength {
+		return str[:maxFieldLength]
+	}
+	return str
+}
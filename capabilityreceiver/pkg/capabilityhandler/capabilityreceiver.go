@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 	"github.com/spf13/viper"
@@ -13,11 +15,13 @@ import (
 	"github.com/onc-healthit/lantern-back-end/capabilityreceiver/pkg/capabilityhandler/validation"
 	"github.com/onc-healthit/lantern-back-end/capabilityreceiver/pkg/chplmapper"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	se "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/sendendpoints"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/versionsoperatorparser"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	"github.com/pkg/errors"
 
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/smartparser"
@@ -40,6 +44,7 @@ type capStatQueryArgs struct {
 	ctx                      context.Context
 	chplMatchFile            string
 	chplEndpointListInfoFile string
+	newChanges               chan<- struct{}
 }
 
 func formatMessage(message []byte) (*endpointmanager.FHIREndpointInfo, *endpointmanager.Validation, error) {
@@ -65,6 +70,37 @@ func formatMessage(message []byte) (*endpointmanager.FHIREndpointInfo, *endpoint
 		return nil, nil, fmt.Errorf("%s: unable to cast TLS Version to string", url)
 	}
 
+	// messages from queriers that do not send the TLS certificate fingerprint are treated as having none
+	var tlsCertificateFingerprint string
+	if msgJSON["tlsCertificateFingerprint"] != nil {
+		tlsCertificateFingerprint, ok = msgJSON["tlsCertificateFingerprint"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: unable to cast TLS Certificate Fingerprint to string", url)
+		}
+	}
+
+	// messages from queriers that do not send the final URL are treated as not having been redirected
+	var finalURL string
+	if msgJSON["finalURL"] != nil {
+		finalURL, ok = msgJSON["finalURL"].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: unable to cast Final URL to string", url)
+		}
+	}
+
+	// the redirects are re-marshalled so they can be unmarshalled into the Redirect struct
+	var redirects []endpointmanager.Redirect
+	if msgJSON["redirects"] != nil {
+		redirectsJSON, err := json.Marshal(msgJSON["redirects"])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: unable to marshal Redirects", url)
+		}
+		err = json.Unmarshal(redirectsJSON, &redirects)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: unable to parse Redirects out of message", url)
+		}
+	}
+
 	requestedFhirVersion, ok := msgJSON["requestedFhirVersion"].(string)
 	if !ok {
 		return nil, nil, fmt.Errorf("%s: unable to cast Requested Fhir Version to string", url)
@@ -171,18 +207,22 @@ func formatMessage(message []byte) (*endpointmanager.FHIREndpointInfo, *endpoint
 
 	validator := validation.ValidatorForFHIRVersion(fhirVersion)
 
-	validationObj := validator.RunValidation(capStat, fhirVersion, tlsVersion, smartResponse, requestedFhirVersion, defaultFhirVersion)
+	validationObj := validator.RunValidation(capStat, fhirVersion, tlsVersion, smartResponse, requestedFhirVersion, defaultFhirVersion, redirects)
 	includedFields := RunIncludedFieldsAndExtensionsChecks(capInt, fhirVersion)
-	operationResource := RunSupportedResourcesChecks(capInt)
+	operationResource, clientOperationResource := RunSupportedResourcesChecks(capInt)
 	supportedProfiles := RunSupportedProfilesCheck(capInt, fhirVersion)
+	capabilityContents := ParseCapabilityContents(capInt)
 
 	FHIREndpointMetadata := &endpointmanager.FHIREndpointMetadata{
-		URL:                  url,
-		HTTPResponse:         httpResponse,
-		Errors:               errs,
-		SMARTHTTPResponse:    smarthttpResponse,
-		ResponseTime:         responseTime,
-		RequestedFhirVersion: requestedFhirVersion,
+		URL:                       url,
+		HTTPResponse:              httpResponse,
+		Errors:                    errs,
+		SMARTHTTPResponse:         smarthttpResponse,
+		ResponseTime:              responseTime,
+		RequestedFhirVersion:      requestedFhirVersion,
+		TLSCertificateFingerprint: tlsCertificateFingerprint,
+		FinalURL:                  finalURL,
+		Redirects:                 redirects,
 	}
 
 	fhirEndpoint := endpointmanager.FHIREndpointInfo{
@@ -193,10 +233,12 @@ func formatMessage(message []byte) (*endpointmanager.FHIREndpointInfo, *endpoint
 		SMARTResponse:            smartResponse,
 		IncludedFields:           includedFields,
 		OperationResource:        operationResource,
+		ClientOperationResource:  clientOperationResource,
 		Metadata:                 FHIREndpointMetadata,
 		RequestedFhirVersion:     requestedFhirVersion,
 		CapabilityFhirVersion:    fhirVersion,
 		SupportedProfiles:        supportedProfiles,
+		CapabilityContents:       capabilityContents,
 		CapabilityStatementBytes: capStatBytes,
 		SMARTResponseBytes:       smartResponseBytes,
 	}
@@ -205,19 +247,40 @@ func formatMessage(message []byte) (*endpointmanager.FHIREndpointInfo, *endpoint
 }
 
 // saveMsgInDB formats the message data for the database and either adds a new entry to the database or
-// updates a current one
+// updates a current one. If the message is part of a query cycle, whether it was stored is recorded for the cycle.
 func saveMsgInDB(message []byte, args *map[string]interface{}) error {
-	var err error
-	var fhirEndpoint *endpointmanager.FHIREndpointInfo
-	var existingEndpt *endpointmanager.FHIREndpointInfo
-	var validation *endpointmanager.Validation
-
 	// Get arguments
 	qa, ok := (*args)["queryArgs"].(capStatQueryArgs)
 	if !ok {
 		return fmt.Errorf("unable to parse args into capStatQueryArgs")
 	}
 
+	err := storeCapabilityStatementMsg(message, qa)
+	if lanternmq.IsRetry(err) {
+		// recorded for the query cycle when the message is processed again
+		return err
+	}
+
+	cycleID := messageCycleID(message)
+	if cycleID != 0 {
+		cycleErr := qa.store.RecordQueryCycleCapabilityStatement(qa.ctx, cycleID, err == nil)
+		if cycleErr != nil {
+			log.Warnf("unable to record capability statement for query cycle %d: %s", cycleID, cycleErr)
+		}
+	}
+
+	return err
+}
+
+// storeCapabilityStatementMsg formats the message data for the database and either adds a new entry to the
+// database or updates a current one. Errors from the database are wrapped with lanternmq.Retry, so that the
+// capability statement is stored when the message is processed again rather than being dropped.
+func storeCapabilityStatementMsg(message []byte, qa capStatQueryArgs) error {
+	var err error
+	var fhirEndpoint *endpointmanager.FHIREndpointInfo
+	var existingEndpt *endpointmanager.FHIREndpointInfo
+	var validation *endpointmanager.Validation
+
 	fhirEndpoint, validation, err = formatMessage(message)
 	if err != nil {
 		return err
@@ -243,36 +306,47 @@ func saveMsgInDB(message []byte, args *map[string]interface{}) error {
 		// If the endpoint info entry doesn't exist, add it to the DB
 		err = chplmapper.MatchEndpointToVendor(ctx, fhirEndpoint, store, softwareListMap)
 		if err != nil {
-			return fmt.Errorf("doesn't exist, match endpoint to vendor failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("doesn't exist, match endpoint to vendor failed, %s", err))
 		}
 
 		err = chplmapper.MatchEndpointToProduct(ctx, fhirEndpoint, store, fmt.Sprintf("%v", qa.chplMatchFile), softwareListMap)
 		if err != nil {
-			return fmt.Errorf("doesn't exist, match endpoint to product failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("doesn't exist, match endpoint to product failed, %s", err))
 		}
 
 		metadataID, err := store.AddFHIREndpointMetadata(ctx, fhirEndpoint.Metadata)
 		if err != nil {
-			return fmt.Errorf("doesn't exist, add endpoint metadata failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("doesn't exist, add endpoint metadata failed, %s", err))
 		}
 
 		valResID, err := store.AddValidationResult(ctx)
 		if err != nil {
-			return fmt.Errorf("adding new validation result ID failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("adding new validation result ID failed, %s", err))
 		}
 		fhirEndpoint.ValidationID = valResID
 
 		err = store.AddValidation(ctx, validation, valResID)
 		if err != nil {
-			return fmt.Errorf("error adding validation rows to table, %s", err)
+			return lanternmq.Retry(fmt.Errorf("error adding validation rows to table, %s", err))
+		}
+
+		contentsID, err := store.AddCapabilityContents(ctx, fhirEndpoint.CapabilityContents)
+		if err != nil {
+			return lanternmq.Retry(fmt.Errorf("adding capability contents failed, %s", err))
 		}
+		fhirEndpoint.CapabilityContentsID = contentsID
 
 		err = store.AddFHIREndpointInfo(ctx, fhirEndpoint, metadataID)
 		if err != nil {
-			return fmt.Errorf("doesn't exist, add to fhir_endpoints_info failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("doesn't exist, add to fhir_endpoints_info failed, %s", err))
+		}
+
+		err = recordSoftwareVersion(ctx, store, fhirEndpoint)
+		if err != nil {
+			return lanternmq.Retry(err)
 		}
 	} else if err != nil {
-		return err
+		return lanternmq.Retry(err)
 	} else {
 		fhirEndpoint.VendorID = existingEndpt.VendorID
 		fhirEndpoint.HealthITProductID = existingEndpt.HealthITProductID
@@ -283,23 +357,30 @@ func saveMsgInDB(message []byte, args *map[string]interface{}) error {
 		existingEndpt.Metadata.ResponseTime = fhirEndpoint.Metadata.ResponseTime
 		existingEndpt.Metadata.SMARTHTTPResponse = fhirEndpoint.Metadata.SMARTHTTPResponse
 		existingEndpt.Metadata.RequestedFhirVersion = fhirEndpoint.Metadata.RequestedFhirVersion
+		existingEndpt.Metadata.TLSCertificateFingerprint = fhirEndpoint.Metadata.TLSCertificateFingerprint
+		existingEndpt.Metadata.FinalURL = fhirEndpoint.Metadata.FinalURL
+		existingEndpt.Metadata.Redirects = fhirEndpoint.Metadata.Redirects
 
-		// Set fhirEndpoint.ValidationID to existingEndpt value because they should have the same ValidationID
-		// until there's a reason to update it
+		// Set fhirEndpoint.ValidationID and CapabilityContentsID to existingEndpt values because they should have
+		// the same ValidationID and CapabilityContentsID until there's a reason to update them
 		fhirEndpoint.ValidationID = existingEndpt.ValidationID
+		fhirEndpoint.CapabilityContentsID = existingEndpt.CapabilityContentsID
 
 		err = chplmapper.MatchEndpointToVendor(ctx, existingEndpt, store, softwareListMap)
 		if err != nil {
-			return fmt.Errorf("does exist, match endpoint to vendor failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("does exist, match endpoint to vendor failed, %s", err))
 		}
 
 		err = chplmapper.MatchEndpointToProduct(ctx, existingEndpt, store, fmt.Sprintf("%v", qa.chplMatchFile), softwareListMap)
 		if err != nil {
-			return fmt.Errorf("does exist, match endpoint to product failed, %s", err)
+			return lanternmq.Retry(fmt.Errorf("does exist, match endpoint to product failed, %s", err))
 		}
 
 		// If the existing endpoint info does not equal the stored endpoint info, update it with the new information, otherwise only update metadata.
 		if !existingEndpt.EqualExcludeMetadata(fhirEndpoint) {
+			// Find what changed in the capability statement before it is overwritten
+			changes := capabilitydiff.Diff(existingEndpt.CapabilityStatement, fhirEndpoint.CapabilityStatement)
+
 			existingEndpt.CapabilityStatement = fhirEndpoint.CapabilityStatement
 			existingEndpt.CapabilityStatementBytes = fhirEndpoint.CapabilityStatementBytes
 			existingEndpt.SMARTResponseBytes = fhirEndpoint.SMARTResponseBytes
@@ -308,42 +389,83 @@ func saveMsgInDB(message []byte, args *map[string]interface{}) error {
 			existingEndpt.SMARTResponse = fhirEndpoint.SMARTResponse
 			existingEndpt.IncludedFields = fhirEndpoint.IncludedFields
 			existingEndpt.OperationResource = fhirEndpoint.OperationResource
+			existingEndpt.ClientOperationResource = fhirEndpoint.ClientOperationResource
 			existingEndpt.SupportedProfiles = fhirEndpoint.SupportedProfiles
 			existingEndpt.CapabilityFhirVersion = fhirEndpoint.CapabilityFhirVersion
 
 			metadataID, err := store.AddFHIREndpointMetadata(ctx, existingEndpt.Metadata)
 			if err != nil {
-				return fmt.Errorf("does exist, add endpoint metadata failed, %s", err)
+				return lanternmq.Retry(fmt.Errorf("does exist, add endpoint metadata failed, %s", err))
 			}
 
 			valResID, err := store.AddValidationResult(ctx)
 			if err != nil {
-				return fmt.Errorf("adding new validation result ID failed, %s", err)
+				return lanternmq.Retry(fmt.Errorf("adding new validation result ID failed, %s", err))
 			}
 			existingEndpt.ValidationID = valResID
 
 			err = store.AddValidation(ctx, validation, valResID)
 			if err != nil {
-				return fmt.Errorf("error adding validation rows to table, %s", err)
+				return lanternmq.Retry(fmt.Errorf("error adding validation rows to table, %s", err))
 			}
 
-			err = store.UpdateFHIREndpointInfo(ctx, existingEndpt, metadataID)
+			contentsID, err := store.AddCapabilityContents(ctx, fhirEndpoint.CapabilityContents)
 			if err != nil {
-				return fmt.Errorf("does exist, add to fhir_endpoints_info failed, %s", err)
+				return lanternmq.Retry(fmt.Errorf("adding capability contents failed, %s", err))
+			}
+			existingEndpt.CapabilityContentsID = contentsID
+
+			// the change record is stored with the update so that it is not lost if the message is redelivered
+			// after the update, and it is published from the database once the update is committed
+			changeRecord := newCapabilityChange(existingEndpt, changes)
+			if changeRecord != nil {
+				err = store.UpdateFHIREndpointInfoAndAddCapabilityChange(ctx, existingEndpt, metadataID, changeRecord)
+			} else {
+				err = store.UpdateFHIREndpointInfo(ctx, existingEndpt, metadataID)
+			}
+			if err != nil {
+				return lanternmq.Retry(fmt.Errorf("does exist, add to fhir_endpoints_info failed, %s", err))
+			}
+			if changeRecord != nil {
+				notifyCapabilityChanges(qa.newChanges)
 			}
 		} else {
 			metadataID, err := store.AddFHIREndpointMetadata(ctx, existingEndpt.Metadata)
 			if err != nil {
-				return fmt.Errorf("just adding endpoint metadata failed, %s", err)
+				return lanternmq.Retry(fmt.Errorf("just adding endpoint metadata failed, %s", err))
 			}
 
 			err = store.UpdateMetadataIDInfo(ctx, metadataID, existingEndpt.ID)
 			if err != nil {
-				return fmt.Errorf("just adding the Metadata ID failed, %s", err)
+				return lanternmq.Retry(fmt.Errorf("just adding the Metadata ID failed, %s", err))
 			}
 		}
+
+		err = recordSoftwareVersion(ctx, store, existingEndpt)
+		if err != nil {
+			return lanternmq.Retry(err)
+		}
 	}
 
+	err = recordFinalURL(ctx, store, fhirEndpoint)
+	if err != nil {
+		return lanternmq.Retry(err)
+	}
+
+	return nil
+}
+
+// recordFinalURL links the given endpoint to the canonical form of the URL its capability statement was returned
+// from after following any redirects. Nothing is recorded if the endpoint was queried for a specific FHIR version
+// or if the querier did not get a response.
+func recordFinalURL(ctx context.Context, store *postgresql.Store, endpt *endpointmanager.FHIREndpointInfo) error {
+	if endpt.RequestedFhirVersion != "None" || endpt.Metadata == nil || endpt.Metadata.FinalURL == "" {
+		return nil
+	}
+	err := store.UpdateFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(endpt.URL, endpt.Metadata.FinalURL))
+	if err != nil {
+		return fmt.Errorf("updating endpoint alias failed, %s", err)
+	}
 	return nil
 }
 
@@ -364,27 +486,50 @@ func removeNoLongerExistingVersionsInfos(ctx context.Context, store *postgresql.
 }
 
 func saveVersionResponseMsgInDB(message []byte, args *map[string]interface{}) error {
-	var err error
-	var existingEndpts []*endpointmanager.FHIREndpoint
-	var msgJSON map[string]interface{}
 	// Get arguments
 	qa, ok := (*args)["queryArgs"].(versionsQueryArgs)
 	if !ok {
 		return fmt.Errorf("unable to parse args into versionsQueryArgs")
 	}
 
+	enqueued, err := storeVersionResponseMsg(message, qa)
+	if lanternmq.IsRetry(err) {
+		// recorded for the query cycle when the message is processed again
+		return err
+	}
+
+	cycleID := messageCycleID(message)
+	if cycleID != 0 {
+		cycleErr := qa.store.RecordQueryCycleVersionsResponse(qa.ctx, cycleID, enqueued)
+		if cycleErr != nil {
+			log.Warnf("unable to record versions response for query cycle %d: %s", cycleID, cycleErr)
+		}
+	}
+
+	return err
+}
+
+// storeVersionResponseMsg stores the versions response from the message and sends a capability statement query
+// for each supported version to the capability querier. It returns the number of queries that were sent. Errors
+// from the database, or from sending the first query, are wrapped with lanternmq.Retry, since nothing has been
+// sent yet and the message can be processed again.
+func storeVersionResponseMsg(message []byte, qa versionsQueryArgs) (int, error) {
+	var err error
+	var existingEndpts []*endpointmanager.FHIREndpoint
+	var msgJSON map[string]interface{}
+
 	err = json.Unmarshal(message, &msgJSON)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	url, ok := msgJSON["url"].(string)
 	if !ok {
-		return fmt.Errorf("unable to cast message URL to string")
+		return 0, fmt.Errorf("unable to cast message URL to string")
 	}
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	store := qa.store
@@ -392,7 +537,7 @@ func saveVersionResponseMsgInDB(message []byte, args *map[string]interface{}) er
 
 	existingEndpts, err = store.GetFHIREndpointUsingURL(ctx, url)
 	if err != nil {
-		return err
+		return 0, lanternmq.Retry(err)
 	}
 
 	resp, _ := msgJSON["versionsResponse"].(map[string]interface{})
@@ -404,7 +549,7 @@ func saveVersionResponseMsgInDB(message []byte, args *map[string]interface{}) er
 			endpt.VersionsResponse = vsr
 			err = store.UpdateFHIREndpoint(ctx, endpt)
 			if err != nil {
-				return err
+				return 0, lanternmq.Retry(err)
 			}
 		}
 	}
@@ -423,46 +568,93 @@ func saveVersionResponseMsgInDB(message []byte, args *map[string]interface{}) er
 
 	err = removeNoLongerExistingVersionsInfos(ctx, store, url, supportedVersions)
 	if err != nil {
-		return err
+		return 0, lanternmq.Retry(err)
 	}
 
+	// on demand requests and new endpoints keep their priority when the capability statement is queried
+	priority, _ := msgJSON["priority"].(string)
+	cycleID := messageCycleID(message)
+	enqueued := 0
 	for _, version := range supportedVersions {
 		// send URL and version of FHIR version to request
 		var message map[string]string = make(map[string]string)
 		message["url"] = url
 		message["requestVersion"] = version
 		message["defaultVersion"] = defaultVersion
+		if cycleID != 0 {
+			message["cycleID"] = strconv.Itoa(cycleID)
+		}
+		if priority != "" {
+			message["priority"] = priority
+		}
 		var msgBytes []byte
 		msgBytes, err = json.Marshal(message)
 		if err != nil {
-			return err
+			return enqueued, err
 		}
-		err = accessqueue.SendToQueue(ctx, string(msgBytes), &mq, &channelID, capQueryEndptQName)
+		err = accessqueue.SendToQueue(ctx, string(msgBytes), &mq, &channelID, capQueryEndptQName, se.QueuePriority(priority))
 		if err != nil {
-			return err
+			if enqueued == 0 {
+				return 0, lanternmq.Retry(err)
+			}
+			return enqueued, err
 		}
+		enqueued++
 	}
 
-	return nil
+	return enqueued, nil
+}
+
+// messageCycleID returns the ID of the query cycle the given queue message was sent in, or 0 if the message is
+// not part of a query cycle
+func messageCycleID(message []byte) int {
+	var msg struct {
+		CycleID int `json:"cycleID"`
+	}
+	err := json.Unmarshal(message, &msg)
+	if err != nil {
+		return 0
+	}
+	return msg.CycleID
 }
 
 // ReceiveCapabilityStatements connects to the given message queue channel and receives the capability
-// statements from it. It then adds the capability statements to the given store.
+// statements from it. It then adds the capability statements to the given store. Capability statements that
+// can't be stored because of a database error are retried according to retryPolicy. The changes found when an
+// endpoint's capability statement is updated are stored, and then published from the database to the exchange
+// with the name changesExchange on the changes message queue channel. If changesQueue is nil, the changes are
+// stored but not published.
 func ReceiveCapabilityStatements(ctx context.Context,
 	store *postgresql.Store,
 	messageQueue lanternmq.MessageQueue,
 	channelID lanternmq.ChannelID,
-	qName string) error {
+	qName string,
+	changesQueue lanternmq.MessageQueue,
+	changesChannelID lanternmq.ChannelID,
+	changesExchange string,
+	retryPolicy lanternmq.RetryPolicy) error {
 
-	args := make(map[string]interface{})
-	args["queryArgs"] = capStatQueryArgs{
+	qa := capStatQueryArgs{
 		store:                    store,
 		ctx:                      ctx,
 		chplMatchFile:            "/etc/lantern/resources/CHPLProductMapping.json",
 		chplEndpointListInfoFile: "/etc/lantern/resources/CHPLProductsInfo.json",
 	}
 
-	messages, err := messageQueue.ConsumeFromQueue(channelID, qName)
+	if changesQueue != nil {
+		interval := time.Duration(viper.GetInt("capchanges_publish_intvl")) * time.Second
+		if interval <= 0 {
+			return fmt.Errorf("the capability changes publish interval must be positive, got %s", interval)
+		}
+		newChanges := make(chan struct{}, 1)
+		qa.newChanges = newChanges
+		go relayCapabilityChanges(ctx, store, changesQueue, changesChannelID, changesExchange, interval, newChanges)
+	}
+
+	args := make(map[string]interface{})
+	args["queryArgs"] = qa
+
+	messages, err := messageQueue.ConsumeFromQueueWithRetry(channelID, qName, retryPolicy)
 	if err != nil {
 		return err
 	}
@@ -478,14 +670,17 @@ func ReceiveCapabilityStatements(ctx context.Context,
 }
 
 // ReceiveVersionResponses connects to the given message queue channel (qname) and receives the
-// versions response from it. It then saves the versions response and queries the versions advertized
+// versions response from it. It then saves the versions response and queries the versions advertized.
+// Versions responses that can't be saved because of a database or queue error are retried according to
+// retryPolicy.
 func ReceiveVersionResponses(ctx context.Context,
 	store *postgresql.Store,
 	messageQueue lanternmq.MessageQueue,
 	channelID lanternmq.ChannelID,
 	qName string,
 	capQueryQueue lanternmq.MessageQueue,
-	capQueryChannelID lanternmq.ChannelID) error {
+	capQueryChannelID lanternmq.ChannelID,
+	retryPolicy lanternmq.RetryPolicy) error {
 	args := make(map[string]interface{})
 
 	args["queryArgs"] = versionsQueryArgs{
@@ -495,7 +690,7 @@ func ReceiveVersionResponses(ctx context.Context,
 		store:             store,
 	}
 
-	messages, err := messageQueue.ConsumeFromQueue(channelID, qName)
+	messages, err := messageQueue.ConsumeFromQueueWithRetry(channelID, qName, retryPolicy)
 	if err != nil {
 		return err
 	}
@@ -4,6 +4,7 @@ package capabilityhandler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,10 +12,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/mock"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
@@ -271,7 +275,8 @@ func Test_saveMsgInDB(t *testing.T) {
 	queueMsg, err := convertInterfaceToBytes(queueTmp)
 	th.Assert(t, err == nil, err)
 
-	// check that nothing is stored and that saveMsgInDB throws an error if the context is canceled
+	// check that nothing is stored and that saveMsgInDB throws an error to retry the message if the context is
+	// canceled
 	testCtx, cancel := context.WithCancel(context.Background())
 	args["queryArgs"] = capStatQueryArgs{
 		store:                    store,
@@ -281,7 +286,8 @@ func Test_saveMsgInDB(t *testing.T) {
 	}
 	cancel()
 	err = saveMsgInDB(queueMsg, &args)
-	th.Assert(t, errors.Cause(err) == context.Canceled, fmt.Sprintf("should have errored out with root cause that the context was canceled, instead was %s and %s", err, errors.Cause(err)))
+	th.Assert(t, errors.Is(err, context.Canceled), fmt.Sprintf("should have errored out with root cause that the context was canceled, instead was %s", err))
+	th.Assert(t, lanternmq.IsRetry(err), "expected the database error to be retried")
 
 	err = ctStmt.QueryRow().Scan(&ct)
 	th.Assert(t, err == nil, err)
@@ -309,6 +315,13 @@ func Test_saveMsgInDB(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	expectedEndpt.ValidationID = valID1
 
+	// Get capability contents ID, there should only be one ID
+	var contentsID1 int
+	contentsRows := store.DB.QueryRow("SELECT id FROM capability_contents")
+	err = contentsRows.Scan(&contentsID1)
+	th.Assert(t, err == nil, err)
+	expectedEndpt.CapabilityContentsID = contentsID1
+
 	storedEndpt, err := store.GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx, testFhirEndpoint1.URL, "None")
 	th.Assert(t, err == nil, err)
 	th.Assert(t, expectedEndpt.Equal(storedEndpt), "stored data does not equal expected store data")
@@ -335,6 +348,15 @@ func Test_saveMsgInDB(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, validationCount == 7, fmt.Sprintf("Should be 7 validation entries for ID %d, is instead %d", valID1, validationCount))
 
+	// check that the capability contents entries exist
+	var resourceCount int
+	contentsRows = store.DB.QueryRow(`SELECT COUNT(*) FROM capability_resources AS res
+		JOIN capability_rest AS rest ON res.capability_rest_id = rest.id
+		WHERE rest.capability_contents_id=$1`, contentsID1)
+	err = contentsRows.Scan(&resourceCount)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, resourceCount == 27, fmt.Sprintf("Should be 27 capability resource entries for ID %d, is instead %d", contentsID1, resourceCount))
+
 	// check that a second new item is stored
 	queueTmp["url"] = "https://test-two.com"
 	expectedEndpt.URL = testFhirEndpoint2.URL
@@ -355,6 +377,13 @@ func Test_saveMsgInDB(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	expectedEndpt.ValidationID = valID2
 
+	// Get capability contents ID for second item
+	var contentsID2 int
+	contentsRows = store.DB.QueryRow("SELECT id FROM capability_contents ORDER BY id DESC LIMIT 1")
+	err = contentsRows.Scan(&contentsID2)
+	th.Assert(t, err == nil, err)
+	expectedEndpt.CapabilityContentsID = contentsID2
+
 	storedEndpt, err = store.GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx, testFhirEndpoint2.URL, "None")
 	th.Assert(t, err == nil, err)
 	th.Assert(t, expectedEndpt.Equal(storedEndpt), "the second endpoint data does not equal expected store data")
@@ -405,10 +434,77 @@ func Test_saveMsgInDB(t *testing.T) {
 	err = valResRows.Scan(&valID3)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, valID2 != valID3, "No new validation ID was added to the validation_results table")
+	th.Assert(t, storedEndpt.CapabilityContentsID != contentsID1, "No new capability contents ID was set on the updated endpoint")
 
 	queueTmp["tlsVersion"] = "TLS 1.2" // resetting value
 	queueTmp["httpResponse"] = 200
 
+	// check that nothing is recorded for a capability statement that does not advertise its software
+	softwareVersions, err := store.GetEndpointSoftwareVersions(ctx, testFhirEndpoint1.URL, "None")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(softwareVersions) == 0, fmt.Sprintf("Expected no software versions, got %d", len(softwareVersions)))
+
+	// check that an update that does not change the capability statement does not store a change record
+	changeRecords, err := store.GetCapabilityChangesUsingURL(ctx, testFhirEndpoint1.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(changeRecords) == 0, fmt.Sprintf("Expected no capability change records, got %d", len(changeRecords)))
+
+	// check that a changed capability statement stores an unpublished change record and notifies the publisher
+	newChanges := make(chan struct{}, 1)
+	args["queryArgs"] = capStatQueryArgs{
+		store:                    store,
+		ctx:                      context.Background(),
+		chplMatchFile:            "../../testdata/test_chpl_product_mapping.json",
+		chplEndpointListInfoFile: "../../testdata/test_chpl_products_info.json",
+		newChanges:               newChanges,
+	}
+	capStat := queueTmp["capabilityStatement"].(map[string]interface{})
+	capStat["fhirVersion"] = "1.0.1"
+	queueMsg, err = convertInterfaceToBytes(queueTmp)
+	th.Assert(t, err == nil, err)
+	err = saveMsgInDB(queueMsg, &args)
+	th.Assert(t, err == nil, err)
+
+	changeRecords, err = store.GetCapabilityChangesUsingURL(ctx, testFhirEndpoint1.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(changeRecords) == 1, fmt.Sprintf("Expected 1 capability change record, got %d", len(changeRecords)))
+	expectedChange := capabilitydiff.Change{Type: capabilitydiff.FHIRVersionChange, Action: capabilitydiff.Changed, OldValue: "1.0.2", NewValue: "1.0.1"}
+	th.Assert(t, len(changeRecords[0].Changes) == 1 && changeRecords[0].Changes[0] == expectedChange, fmt.Sprintf("Expected the change %+v, got %+v", expectedChange, changeRecords[0].Changes))
+	th.Assert(t, changeRecords[0].VendorID == vendors[1].ID, fmt.Sprintf("Expected the change record vendor ID to be %d, got %d", vendors[1].ID, changeRecords[0].VendorID))
+	th.Assert(t, changeRecords[0].PublishedAt.IsZero(), "Expected the change record to be unpublished")
+	th.Assert(t, len(newChanges) == 1, "Expected the publisher to be notified of the change record")
+
+	// check that the change record is published from the database
+	changesQueue := mock.NewBasicMockMessageQueue()
+	err = publishCapabilityChanges(ctx, store, changesQueue, 1, "test-capability-changes")
+	th.Assert(t, err == nil, err)
+
+	changeRecords, err = store.GetCapabilityChangesUsingURL(ctx, testFhirEndpoint1.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, !changeRecords[0].PublishedAt.IsZero(), "Expected the change record to be published")
+
+	publishedQueue := changesQueue.(*mock.BasicMockMessageQueue).Queue
+	th.Assert(t, len(publishedQueue) == 1, fmt.Sprintf("Expected 1 published change, got %d", len(publishedQueue)))
+	var publishedMsg capabilityChangeMessage
+	err = json.Unmarshal(<-publishedQueue, &publishedMsg)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, publishedMsg.URL == testFhirEndpoint1.URL, fmt.Sprintf("Expected the published change URL to be %s, got %s", testFhirEndpoint1.URL, publishedMsg.URL))
+	th.Assert(t, publishedMsg.ChangeID == changeRecords[0].ID, fmt.Sprintf("Expected the published change ID to be %d, got %d", changeRecords[0].ID, publishedMsg.ChangeID))
+	th.Assert(t, publishedMsg.Change == expectedChange, fmt.Sprintf("Expected the published change %+v, got %+v", expectedChange, publishedMsg.Change))
+
+	// check that published change records are not published again
+	err = publishCapabilityChanges(ctx, store, changesQueue, 1, "test-capability-changes")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(publishedQueue) == 0, fmt.Sprintf("Expected no more published changes, got %d", len(publishedQueue)))
+
+	capStat["fhirVersion"] = "1.0.2" // resetting value
+	args["queryArgs"] = capStatQueryArgs{
+		store:                    store,
+		ctx:                      context.Background(),
+		chplMatchFile:            "../../testdata/test_chpl_product_mapping.json",
+		chplEndpointListInfoFile: "../../testdata/test_chpl_products_info.json",
+	}
+
 	// check that error adding to store throws error
 	queueTmp["url"] = "https://a-new-url.com"
 	queueTmp["tlsVersion"] = strings.Repeat("a", 510) // too long. causes db error
@@ -445,6 +541,7 @@ func Test_saveMsgInDB(t *testing.T) {
 	oldMetadataID := storedEndpt.Metadata.ID
 	oldMetadataUpdatedAt := storedEndpt.Metadata.UpdatedAt
 	oldValidationID := storedEndpt.ValidationID
+	oldContentsID := storedEndpt.CapabilityContentsID
 
 	// Try to update with exact same values
 	queueMsg, err = convertInterfaceToBytes(queueTmp)
@@ -459,6 +556,7 @@ func Test_saveMsgInDB(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, storedEndpt.Metadata.ID != oldMetadataID, "The selective update should have still updated the old endpoint info metadata id")
 	th.Assert(t, storedEndpt.ValidationID == oldValidationID, fmt.Sprintf("The selective update should not have updated the old endpoint validation id for same values, %+v, %d", storedEndpt, oldValidationID))
+	th.Assert(t, storedEndpt.CapabilityContentsID == oldContentsID, "The selective update should not have updated the old endpoint capability contents id for same values")
 	th.Assert(t, !storedEndpt.Metadata.UpdatedAt.Equal(oldMetadataUpdatedAt), "The selective update should have still updated the old endpoint metadata updated at time")
 
 	store.DB.QueryRow(historySQLStatement, storedEndpt.URL).Scan(&updatedAt)
@@ -491,6 +589,63 @@ func Test_saveMsgInDB(t *testing.T) {
 
 }
 
+func Test_saveVersionResponseMsgInDBRetry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	ctx := context.Background()
+
+	err := store.AddFHIREndpoint(ctx, testFhirEndpoint1)
+	th.Assert(t, err == nil, err)
+
+	var cycle endpointmanager.QueryCycle
+	err = store.AddQueryCycle(ctx, &cycle)
+	th.Assert(t, err == nil, err)
+
+	// the capability statement queries can't be sent
+	mq := mock.NewBasicMockMessageQueue()
+	mq.(*mock.BasicMockMessageQueue).PublishToQueueFn = func(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+		return errors.New("queue unavailable")
+	}
+	args := make(map[string]interface{})
+	args["queryArgs"] = versionsQueryArgs{
+		store:             store,
+		ctx:               ctx,
+		capQueryQueue:     mq,
+		capQueryChannelID: 1,
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"url":              testFhirEndpoint1.URL,
+		"versionsResponse": map[string]interface{}{"default": "4.0", "versions": []string{"4.0"}},
+		"cycleID":          cycle.ID,
+	})
+	th.Assert(t, err == nil, err)
+
+	err = saveVersionResponseMsgInDB(message, &args)
+	th.Assert(t, lanternmq.IsRetry(err), fmt.Sprintf("expected the versions response to be retried, got %v", err))
+
+	// the versions response is recorded for the query cycle once it's processed successfully
+	progress, err := store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, progress.VersionsReceived == 0, "expected the retried versions response not to be recorded for the query cycle")
+
+	mq.(*mock.BasicMockMessageQueue).PublishToQueueFn = func(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+		return nil
+	}
+	err = saveVersionResponseMsgInDB(message, &args)
+	th.Assert(t, err == nil, err)
+
+	progress, err = store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, progress.VersionsReceived == 1, "expected the versions response to be recorded for the query cycle")
+	th.Assert(t, progress.Enqueued == 2, fmt.Sprintf("expected queries for version 4.0 and None to be enqueued, got %d", progress.Enqueued))
+}
+
 func setup() error {
 	var err error
 	store, err = postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
@@ -321,6 +321,48 @@ func Test_formatMessage(t *testing.T) {
 	th.Assert(t, returnErr != nil, "Expected an error to be thrown due to an incorrect TLS Version")
 	tmpMessage["tlsVersion"] = "TLS 1.2"
 
+	// test TLS Certificate Fingerprint
+	tmpMessage["tlsCertificateFingerprint"] = "abc123"
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	endpt, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr == nil, returnErr)
+	th.Assert(t, endpt.Metadata.TLSCertificateFingerprint == "abc123", fmt.Sprintf("Expected TLS certificate fingerprint abc123, got %s", endpt.Metadata.TLSCertificateFingerprint))
+	tmpMessage["tlsCertificateFingerprint"] = 1
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	_, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr != nil, "Expected an error to be thrown due to an incorrect TLS Certificate Fingerprint")
+	delete(tmpMessage, "tlsCertificateFingerprint")
+
+	// test Final URL
+	tmpMessage["finalURL"] = "https://example.com/fhir"
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	endpt, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr == nil, returnErr)
+	th.Assert(t, endpt.Metadata.FinalURL == "https://example.com/fhir", fmt.Sprintf("Expected final URL https://example.com/fhir, got %s", endpt.Metadata.FinalURL))
+	tmpMessage["finalURL"] = 1
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	_, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr != nil, "Expected an error to be thrown due to an incorrect Final URL")
+	delete(tmpMessage, "finalURL")
+
+	// test Redirects
+	tmpMessage["redirects"] = []endpointmanager.Redirect{{URL: "http://example.com/fhir/metadata", StatusCode: 301, Location: "https://example.com/fhir/metadata"}}
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	endpt, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr == nil, returnErr)
+	th.Assert(t, len(endpt.Metadata.Redirects) == 1 && endpt.Metadata.Redirects[0].StatusCode == 301, fmt.Sprintf("Expected one 301 redirect, got %+v", endpt.Metadata.Redirects))
+	tmpMessage["redirects"] = "redirects"
+	message, err = convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+	_, _, returnErr = formatMessage(message)
+	th.Assert(t, returnErr != nil, "Expected an error to be thrown due to incorrect Redirects")
+	delete(tmpMessage, "redirects")
+
 	// test incorrect MIME Type
 	tmpMessage["mimeTypes"] = 1
 	message, err = convertInterfaceToBytes(tmpMessage)
@@ -431,6 +473,46 @@ func Test_formatMessage(t *testing.T) {
 	tmpMessage["defaultFhirVersion"] = "4.0"
 }
 
+func Test_formatMessageMalformedRest(t *testing.T) {
+	setupCapabilityStatement(t, filepath.Join("../../testdata", "supported_profiles_r4.json"))
+	tmpMessage := testQueueMsg
+	capStat := tmpMessage["capabilityStatement"].(map[string]interface{})
+	capStat["rest"] = []interface{}{"server", map[string]interface{}{"mode": 1, "resource": "Patient"}}
+	tmpMessage["capabilityStatement"] = capStat
+
+	message, err := convertInterfaceToBytes(tmpMessage)
+	th.Assert(t, err == nil, err)
+
+	// a malformed capability statement produces validation findings instead of panicking
+	endpt, validation, returnErr := formatMessage(message)
+	th.Assert(t, returnErr == nil, returnErr)
+	th.Assert(t, len(endpt.OperationResource) == 0, fmt.Sprintf("Expected no operation resources, got %d", len(endpt.OperationResource)))
+	th.Assert(t, len(endpt.SupportedProfiles) == 0, fmt.Sprintf("Expected no supported profiles, got %d", len(endpt.SupportedProfiles)))
+
+	found := false
+	for _, rule := range validation.Results {
+		if rule.RuleName == endpointmanager.PatResourceExists {
+			found = true
+			th.Assert(t, !rule.Valid, "Expected the patient resource rule to be invalid for the malformed rest field")
+		}
+	}
+	th.Assert(t, found, "Expected the patient resource rule to be included in the validation results")
+
+	setupCapabilityStatement(t, filepath.Join("../../testdata", "cerner_capability_dstu2.json"))
+}
+
+func Test_messageCycleID(t *testing.T) {
+	cycleID := messageCycleID([]byte(`{"url": "http://example.com/DTSU2/", "cycleID": 12}`))
+	th.Assert(t, cycleID == 12, fmt.Sprintf("Expected query cycle 12, got %d", cycleID))
+
+	// messages sent outside of a query cycle do not have an ID
+	cycleID = messageCycleID([]byte(`{"url": "http://example.com/DTSU2/"}`))
+	th.Assert(t, cycleID == 0, fmt.Sprintf("Expected no query cycle, got %d", cycleID))
+
+	cycleID = messageCycleID([]byte(`not json`))
+	th.Assert(t, cycleID == 0, fmt.Sprintf("Expected no query cycle for a malformed message, got %d", cycleID))
+}
+
 func Test_RunIncludedFieldsAndExtensionsChecks(t *testing.T) {
 	setupCapabilityStatement(t, filepath.Join("../../testdata", "cerner_capability_dstu2.json"))
 	capInt := testQueueMsg["capabilityStatement"].(map[string]interface{})
@@ -522,7 +604,7 @@ func Test_RunIncludedFieldsAndExtensionsChecks(t *testing.T) {
 func Test_RunSupportedResourcesChecks(t *testing.T) {
 	setupCapabilityStatement(t, filepath.Join("../../testdata", "cerner_capability_dstu2.json"))
 	capInt := testQueueMsg["capabilityStatement"].(map[string]interface{})
-	operationResource := RunSupportedResourcesChecks(capInt)
+	operationResource, _ := RunSupportedResourcesChecks(capInt)
 	th.Assert(t, len(operationResource) == 2, fmt.Sprintf("Expected there to be 2 operation resources in map, were %d", len(operationResource)))
 	th.Assert(t, operationResource["read"] != nil, "Expected the Operation to include read, is instead nil")
 	th.Assert(t, operationResource["search-type"] != nil, "Expected the Operation to include search-type, is instead nil")
@@ -533,7 +615,7 @@ func Test_RunSupportedResourcesChecks(t *testing.T) {
 	// one returned value with "not specified"
 	capStat1, err := generateTestCapStat("noInteraction")
 	th.Assert(t, capStat1 != nil, fmt.Sprintf("Error generating noInteraction capability statement, %s", err))
-	operationResource = RunSupportedResourcesChecks(capStat1)
+	operationResource, _ = RunSupportedResourcesChecks(capStat1)
 	th.Assert(t, len(operationResource) == 1, fmt.Sprintf("Expected there to be 1 operation resource in OperationAndResource array, were %d", len(operationResource)))
 	th.Assert(t, operationResource["not specified"] != nil, "Expected the Operation to include 'not specified', is instead nil")
 	th.Assert(t, operationResource["not specified"][0] == "AllergyIntolerance", fmt.Sprintf("Expected the Resource to equal 'AllergyIntolerance', is instead %s", operationResource["not specified"][0]))
@@ -542,7 +624,7 @@ func Test_RunSupportedResourcesChecks(t *testing.T) {
 	// should have one returned value with "not specified"
 	capStat2, _ := generateTestCapStat("emptyInteraction")
 	th.Assert(t, capStat2 != nil, "Error generating emptyInteraction capability statement")
-	operationResource = RunSupportedResourcesChecks(capStat2)
+	operationResource, _ = RunSupportedResourcesChecks(capStat2)
 	th.Assert(t, len(operationResource) == 1, fmt.Sprintf("Expected there to be 1 operation resource in OperationAndResource array, were %d", len(operationResource)))
 	th.Assert(t, operationResource["not specified"] != nil, "Expected the Operation to include 'not specified', is instead nil")
 	th.Assert(t, operationResource["not specified"][0] == "AllergyIntolerance", fmt.Sprintf("Expected the Resource to equal 'AllergyIntolerance', is instead %s", operationResource["not specified"][0]))
@@ -551,7 +633,7 @@ func Test_RunSupportedResourcesChecks(t *testing.T) {
 	// should have one returned value with "not specified"
 	capStat3, _ := generateTestCapStat("noCode")
 	th.Assert(t, capStat3 != nil, "Error generating noCode capability statement")
-	operationResource = RunSupportedResourcesChecks(capStat3)
+	operationResource, _ = RunSupportedResourcesChecks(capStat3)
 	th.Assert(t, len(operationResource) == 1, fmt.Sprintf("Expected there to be 1 operation resource in OperationAndResource array, were %d", len(operationResource)))
 	th.Assert(t, operationResource["not specified"] != nil, "Expected the Operation to include 'not specified', is instead nil")
 	th.Assert(t, operationResource["not specified"][0] == "AllergyIntolerance", fmt.Sprintf("Expected the Resource to equal 'AllergyIntolerance', is instead %s", operationResource["not specified"][0]))
@@ -560,7 +642,7 @@ func Test_RunSupportedResourcesChecks(t *testing.T) {
 	// operationresource will only include values that have the valid code
 	capStat4, _ := generateTestCapStat("manyCode")
 	th.Assert(t, capStat3 != nil, "Error generating manyCode capability statement")
-	operationResource = RunSupportedResourcesChecks(capStat4)
+	operationResource, _ = RunSupportedResourcesChecks(capStat4)
 	th.Assert(t, len(operationResource) == 1, fmt.Sprintf("Expected there to be 1 operation resource in OperationAndResource array, were %d", len(operationResource)))
 	th.Assert(t, operationResource["search-type"] != nil, "Expected the Operation to include 'search-type', is instead nil")
 	th.Assert(t, operationResource["search-type"][0] == "AllergyIntolerance", fmt.Sprintf("Expected the Resource to equal 'AllergyIntolerance', is instead %s", operationResource["search-type"][0]))
@@ -569,12 +651,34 @@ func Test_RunSupportedResourcesChecks(t *testing.T) {
 	// If one of the resources is missing a type, it just skips over it
 	capStat5, _ := generateTestCapStat("missingType")
 	th.Assert(t, capStat5 != nil, "Error generating missingType capability statement")
-	operationResource = RunSupportedResourcesChecks(capStat5)
+	operationResource, _ = RunSupportedResourcesChecks(capStat5)
 	th.Assert(t, len(operationResource) == 2, fmt.Sprintf("Expected there to be 2 operation resources in OperationAndResource array, were %d", len(operationResource)))
 	th.Assert(t, operationResource["read"] != nil, "Expected the Operation to include 'read', is instead nil")
 	th.Assert(t, operationResource["search-type"] != nil, "Expected the Operation to include 'search-type', is instead nil")
 	th.Assert(t, operationResource["read"][0] == "DocumentReference", fmt.Sprintf("Expected the Resource to equal 'DocumentReference', is instead %s", operationResource["read"][0]))
 	th.Assert(t, operationResource["search-type"][0] == "DocumentReference", fmt.Sprintf("Expected the Resource to equal 'DocumentReference', is instead %s", operationResource["search-type"][0]))
+
+	// Every rest entry is included, and the operations of client mode entries are returned separately
+	capStat6, _ := generateTestCapStat("multipleRest")
+	th.Assert(t, capStat6 != nil, "Error generating multipleRest capability statement")
+	operationResource, clientOperationResource := RunSupportedResourcesChecks(capStat6)
+	th.Assert(t, len(operationResource) == 2, fmt.Sprintf("Expected there to be 2 operation resources in OperationAndResource array, were %d", len(operationResource)))
+	th.Assert(t, len(operationResource["read"]) == 2, fmt.Sprintf("Expected there to be 2 resources with read operation, were %d", len(operationResource["read"])))
+	th.Assert(t, operationResource["read"][1] == "Observation", fmt.Sprintf("Expected the Resource to equal 'Observation', is instead %s", operationResource["read"][1]))
+	th.Assert(t, len(clientOperationResource) == 2, fmt.Sprintf("Expected there to be 2 client operation resources, were %d", len(clientOperationResource)))
+	th.Assert(t, len(clientOperationResource["read"]) == 1, fmt.Sprintf("Expected there to be 1 resource with client read operation, were %d", len(clientOperationResource["read"])))
+	th.Assert(t, clientOperationResource["read"][0] == "Patient", fmt.Sprintf("Expected the Resource to equal 'Patient', is instead %s", clientOperationResource["read"][0]))
+	th.Assert(t, clientOperationResource["not specified"][0] == "Encounter", fmt.Sprintf("Expected the Resource to equal 'Encounter', is instead %s", clientOperationResource["not specified"]))
+
+	// If the rest entries or resources are malformed, they are skipped over instead of panicking
+	capStat7, _ := generateTestCapStat("malformedRest")
+	th.Assert(t, capStat7 != nil, "Error generating malformedRest capability statement")
+	operationResource, _ = RunSupportedResourcesChecks(capStat7)
+	th.Assert(t, len(operationResource) == 1, fmt.Sprintf("Expected there to be 1 operation resource in OperationAndResource array, were %d", len(operationResource)))
+	th.Assert(t, operationResource["read"][0] == "Patient", fmt.Sprintf("Expected the Resource to equal 'Patient', is instead %s", operationResource["read"]))
+
+	operationResource, _ = RunSupportedResourcesChecks(map[string]interface{}{"rest": "server"})
+	th.Assert(t, len(operationResource) == 0, fmt.Sprintf("Expected there to be no operation resources when rest is not a list, were %d", len(operationResource)))
 }
 
 func Test_RunSupportedProfilesCheck(t *testing.T) {
@@ -615,6 +719,82 @@ func Test_RunSupportedProfilesCheck(t *testing.T) {
 	th.Assert(t, supportedProfiles[19].ProfileURL == expectedURL, fmt.Sprintf("Expected ProfileURL to be %s, was %s", supportedProfiles[19].ProfileURL, expectedURL))
 	th.Assert(t, supportedProfiles[19].Resource == expectedResource, fmt.Sprintf("Expected Resource to be %s, was %s", supportedProfiles[19].Resource, expectedResource))
 	th.Assert(t, supportedProfiles[19].ProfileName == expectedName, fmt.Sprintf("Expected ProfileName to be an empty string, was %s", supportedProfiles[19].ProfileName))
+	th.Assert(t, supportedProfiles[19].Mode == "server", fmt.Sprintf("Expected Mode to be server, was %s", supportedProfiles[19].Mode))
+
+	// Test profiles of multiple rest entries
+	capInt, _ = generateTestCapStat("multipleRest")
+	th.Assert(t, capInt != nil, "Error generating multipleRest capability statement")
+	supportedProfiles = RunSupportedProfilesCheck(capInt, fhirVersion)
+	th.Assert(t, len(supportedProfiles) == 2, fmt.Sprintf("Expected supportedProfiles length to be 2 entries, was %d", len(supportedProfiles)))
+	th.Assert(t, supportedProfiles[0].Mode == "server", fmt.Sprintf("Expected Mode to be server, was %s", supportedProfiles[0].Mode))
+	th.Assert(t, supportedProfiles[1].Mode == "client", fmt.Sprintf("Expected Mode to be client, was %s", supportedProfiles[1].Mode))
+	th.Assert(t, supportedProfiles[1].Resource == "Patient", fmt.Sprintf("Expected Resource to be Patient, was %s", supportedProfiles[1].Resource))
+
+	// Malformed rest entries, resources and profiles are skipped over
+	capInt, _ = generateTestCapStat("malformedRest")
+	th.Assert(t, capInt != nil, "Error generating malformedRest capability statement")
+	supportedProfiles = RunSupportedProfilesCheck(capInt, fhirVersion)
+	th.Assert(t, len(supportedProfiles) == 1, fmt.Sprintf("Expected supportedProfiles length to be 1 entry, was %d", len(supportedProfiles)))
+	supportedProfiles = RunSupportedProfilesCheck(map[string]interface{}{"profile": "bad"}, "1.0.2")
+	th.Assert(t, len(supportedProfiles) == 0, fmt.Sprintf("Expected supportedProfiles length to be 0 entries, was %d", len(supportedProfiles)))
+}
+
+func Test_ParseCapabilityContents(t *testing.T) {
+	// Test DSTU2 Conformance Resource, where operation definitions are References
+	setupCapabilityStatement(t, filepath.Join("../../testdata", "cerner_capability_dstu2.json"))
+	capInt := testQueueMsg["capabilityStatement"].(map[string]interface{})
+	contents := ParseCapabilityContents(capInt)
+	th.Assert(t, len(contents.Rest) == 1, fmt.Sprintf("Expected 1 rest entry, got %d", len(contents.Rest)))
+	rest := contents.Rest[0]
+	th.Assert(t, rest.Mode == "server", fmt.Sprintf("Expected rest mode to be server, was %s", rest.Mode))
+	th.Assert(t, len(rest.Resources) == 27, fmt.Sprintf("Expected 27 resources, got %d", len(rest.Resources)))
+	th.Assert(t, rest.Resources[0].Type == "Conformance", fmt.Sprintf("Expected the first resource to be Conformance, was %s", rest.Resources[0].Type))
+	th.Assert(t, len(rest.Operations) == 2, fmt.Sprintf("Expected 2 operations, got %d", len(rest.Operations)))
+	expectedDefinition := "http://fhir.org/guides/argonaut/OperationDefinition/docref"
+	th.Assert(t, rest.Operations[1].Definition == expectedDefinition, fmt.Sprintf("Expected operation definition to be %s, was %s", expectedDefinition, rest.Operations[1].Definition))
+
+	// Test R4 Capability Statement
+	setupCapabilityStatement(t, filepath.Join("../../testdata", "supported_profiles_r4.json"))
+	capInt = testQueueMsg["capabilityStatement"].(map[string]interface{})
+	contents = ParseCapabilityContents(capInt)
+	th.Assert(t, len(contents.Rest) == 1, fmt.Sprintf("Expected 1 rest entry, got %d", len(contents.Rest)))
+	rest = contents.Rest[0]
+	th.Assert(t, len(rest.Interactions) == 2, fmt.Sprintf("Expected 2 system interactions, got %d", len(rest.Interactions)))
+	th.Assert(t, len(rest.Operations) == 11, fmt.Sprintf("Expected 11 operations, got %d", len(rest.Operations)))
+	th.Assert(t, len(rest.SecurityServices) == 1, fmt.Sprintf("Expected 1 security service, got %d", len(rest.SecurityServices)))
+	th.Assert(t, rest.SecurityServices[0].Code == "OAuth", fmt.Sprintf("Expected security service code to be OAuth, was %s", rest.SecurityServices[0].Code))
+	account := rest.Resources[0]
+	th.Assert(t, account.Type == "Account", fmt.Sprintf("Expected the first resource to be Account, was %s", account.Type))
+	th.Assert(t, len(account.SupportedProfiles) == 1, fmt.Sprintf("Expected 1 supported profile, got %d", len(account.SupportedProfiles)))
+	th.Assert(t, len(account.Interactions) == 9, fmt.Sprintf("Expected 9 interactions, got %d", len(account.Interactions)))
+	th.Assert(t, len(account.SearchParams) == 13, fmt.Sprintf("Expected 13 search parameters, got %d", len(account.SearchParams)))
+	expectedParam := endpointmanager.CapabilitySearchParam{Name: "subject", Type: "reference", Definition: "http://hl7.org/fhir/SearchParameter/Account-subject"}
+	th.Assert(t, account.SearchParams[0] == expectedParam, fmt.Sprintf("Expected search parameter %+v, got %+v", expectedParam, account.SearchParams[0]))
+	th.Assert(t, len(account.SearchIncludes) == 4, fmt.Sprintf("Expected 4 search includes, got %d", len(account.SearchIncludes)))
+	th.Assert(t, len(account.SearchRevIncludes) == 38, fmt.Sprintf("Expected 38 search revincludes, got %d", len(account.SearchRevIncludes)))
+
+	// Every rest entry is kept, including client mode entries, and malformed elements are skipped
+	capStatBytes := []byte(`{
+		"rest": [
+			{"mode": "server", "resource": [{"type": "Patient", "interaction": [{"code": "read"}, {"nocode": "x"}]}]},
+			"not a rest entry",
+			{"mode": "client", "resource": [{"interaction": [{"code": "read"}]}, {"type": "Observation", "searchParam": "wrong"}],
+				"security": {"service": [{"text": "OAuth2 using SMART-on-FHIR profile"}]}}
+		]}`)
+	var capStat map[string]interface{}
+	err := json.Unmarshal(capStatBytes, &capStat)
+	th.Assert(t, err == nil, err)
+	contents = ParseCapabilityContents(capStat)
+	th.Assert(t, len(contents.Rest) == 2, fmt.Sprintf("Expected 2 rest entries, got %d", len(contents.Rest)))
+	th.Assert(t, contents.Rest[1].Mode == "client", fmt.Sprintf("Expected the second rest mode to be client, was %s", contents.Rest[1].Mode))
+	th.Assert(t, len(contents.Rest[0].Resources[0].Interactions) == 1, fmt.Sprintf("Expected 1 interaction, got %d", len(contents.Rest[0].Resources[0].Interactions)))
+	th.Assert(t, len(contents.Rest[1].Resources) == 1, fmt.Sprintf("Expected 1 client resource, got %d", len(contents.Rest[1].Resources)))
+	th.Assert(t, len(contents.Rest[1].Resources[0].SearchParams) == 0, fmt.Sprintf("Expected no search parameters, got %d", len(contents.Rest[1].Resources[0].SearchParams)))
+	th.Assert(t, contents.Rest[1].SecurityServices[0].Display == "OAuth2 using SMART-on-FHIR profile", fmt.Sprintf("Expected the security service text to be stored as the display, got %+v", contents.Rest[1].SecurityServices))
+
+	// A nil capability statement has no contents
+	contents = ParseCapabilityContents(nil)
+	th.Assert(t, len(contents.Rest) == 0, fmt.Sprintf("Expected no rest entries, got %d", len(contents.Rest)))
 }
 
 func generateTestCapStat(whichCapStat string) (map[string]interface{}, error) {
@@ -669,6 +849,53 @@ func generateTestCapStat(whichCapStat string) (map[string]interface{}, error) {
 				}]
 			}]
 		}]}`)
+	} else if whichCapStat == "multipleRest" {
+		capStatBytes = []byte(`{
+		"rest": [{
+			"mode": "server",
+			"resource": [{
+				"type": "Patient",
+				"supportedProfile": ["http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient"],
+				"interaction": [{
+					"code": "read"
+				}, {
+					"code": "search-type"
+				}]
+			}, {
+				"type": "Observation",
+				"interaction": [{
+					"code": "read"
+				}]
+			}]
+		}, {
+			"mode": "client",
+			"resource": [{
+				"type": "Patient",
+				"supportedProfile": ["http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient"],
+				"interaction": [{
+					"code": "read"
+				}]
+			}, {
+				"type": "Encounter"
+			}]
+		}]}`)
+	} else if whichCapStat == "malformedRest" {
+		capStatBytes = []byte(`{
+		"rest": ["server", {
+			"mode": "server",
+			"resource": "Patient"
+		}, {
+			"mode": 1,
+			"resource": [1, {
+				"type": ["Observation"]
+			}, {
+				"type": "Patient",
+				"supportedProfile": [1, "http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient"],
+				"interaction": [{
+					"code": "read"
+				}]
+			}]
+		}]}`)
 	}
 
 	if len(capStatBytes) == 0 {
@@ -681,3 +908,33 @@ func generateTestCapStat(whichCapStat string) (map[string]interface{}, error) {
 	}
 	return capInt, fmt.Errorf("somehow skipped over everything")
 }
+
+func Test_softwareVersion(t *testing.T) {
+	path := filepath.Join("../../testdata", "allscripts_capability_dstu2.json")
+	csJSON, err := ioutil.ReadFile(path)
+	th.Assert(t, err == nil, err)
+	capStat, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+
+	sv := softwareVersion(capStat)
+	th.Assert(t, sv != nil, "Expected the allscripts capability statement to advertise a software version")
+	th.Assert(t, sv.ReleaseDate == "2019-11-22", fmt.Sprintf("Expected release date 2019-11-22, got %s", sv.ReleaseDate))
+	expectedName, _ := capStat.GetSoftwareName()
+	th.Assert(t, sv.Name == expectedName, fmt.Sprintf("Expected software name %s, got %s", expectedName, sv.Name))
+
+	// a capability statement without software has no software version
+	capStat, err = capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{"fhirVersion": "4.0.1"})
+	th.Assert(t, err == nil, err)
+	th.Assert(t, softwareVersion(capStat) == nil, "Expected no software version for a capability statement without software")
+
+	// values are trimmed
+	capStat, err = capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{
+		"fhirVersion": "4.0.1",
+		"software":    map[string]interface{}{"name": " EHR ", "version": "1.0 "},
+	})
+	th.Assert(t, err == nil, err)
+	sv = softwareVersion(capStat)
+	th.Assert(t, sv != nil && sv.Name == "EHR" && sv.Version == "1.0" && sv.ReleaseDate == "", fmt.Sprintf("Unexpected software version %+v", sv))
+
+	th.Assert(t, softwareVersion(nil) == nil, "Expected no software version for a nil capability statement")
+}
@@ -72,11 +72,18 @@ func checkField(capInt map[string]interface{}, fieldNames []string) bool {
 		if index == (len(fieldNames) - 1) {
 			return field != nil
 		} else if arrContains(arrayFields, name) {
-			fieldArr := field.([]interface{})
+			fieldArr, ok := field.([]interface{})
+			if !ok {
+				return false
+			}
 			nextIndex := index + 1
 			return checkFieldArr(fieldNames[nextIndex:length], fieldArr)
 		} else {
-			capInt = field.(map[string]interface{})
+			fieldMap, ok := field.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			capInt = fieldMap
 		}
 	}
 
@@ -92,21 +99,30 @@ func checkFieldArr(fieldNames []string, fieldArr []interface{}) bool {
 	// Loop through the array of interface objects
 	for _, obj := range fieldArr {
 		// For each object in interface array, get desired field using name in fieldNames
-		objMap := obj.(map[string]interface{})
+		objMap, ok := obj.(map[string]interface{})
+		if !ok {
+			continue
+		}
 		field := objMap[name]
 		if field == nil {
 			// If the desired field does not exist in that object, continue to the next object within the array of interface objects
 			continue
 		} else if length != 1 && interfaceArrTrue {
 			// If the desired field is is not the last field to check and an array of interface objects, call checkFieldArr with this new array
-			fieldArr := field.([]interface{})
+			fieldArr, ok := field.([]interface{})
+			if !ok {
+				continue
+			}
 			found = checkFieldArr(fieldNames[1:length], fieldArr)
 			if found {
 				return found
 			}
 		} else if length != 1 && !interfaceArrTrue {
 			// If the desired field is not the last field to check and not an array of interface objects, call checkField with this field map[string]interface
-			field := field.(map[string]interface{})
+			field, ok := field.(map[string]interface{})
+			if !ok {
+				continue
+			}
 			found = checkField(field, fieldNames[1:length])
 			if found {
 				return found
@@ -160,14 +176,24 @@ func checkExtension(capInt map[string]interface{}, fieldNames []string, url stri
 		length := len(fieldNames)
 		// Check if at an extension field in fieldNames
 		if index == length-3 {
-			extensionArr := field.([]interface{})
+			extensionArr, ok := field.([]interface{})
+			if !ok {
+				return false
+			}
 			return checkExtensionURL(extensionArr, url)
 		} else if arrContains(arrayFields, name) {
-			fieldArr := field.([]interface{})
+			fieldArr, ok := field.([]interface{})
+			if !ok {
+				return false
+			}
 			nextIndex := index + 1
 			return checkArrFieldExtension(fieldNames[nextIndex:length], fieldArr, url)
 		} else {
-			capInt = field.(map[string]interface{})
+			fieldMap, ok := field.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			capInt = fieldMap
 		}
 	}
 
@@ -183,28 +209,40 @@ func checkArrFieldExtension(fieldNames []string, fieldArr []interface{}, url str
 	// Loop through the array of interface objects
 	for _, obj := range fieldArr {
 		// For each object in interface array, get desired field using name in fieldNames
-		objMap := obj.(map[string]interface{})
+		objMap, ok := obj.(map[string]interface{})
+		if !ok {
+			continue
+		}
 		extensionField := objMap[name]
 		if extensionField == nil {
 			// If the desired field does not exist in that object, continue to the next object within the array of interface objects
 			continue
 		} else if length-3 != 0 && interfaceArrTrue {
 			// If the desired field is not extension or modifierExtension and it is also an array of interface objects, call checkArrFieldExtension with this new array
-			fieldArr := extensionField.([]interface{})
+			fieldArr, ok := extensionField.([]interface{})
+			if !ok {
+				continue
+			}
 			found = checkArrFieldExtension(fieldNames[1:length], fieldArr, url)
 			if found {
 				return found
 			}
 		} else if length-3 != 0 && !interfaceArrTrue {
 			// If the desired field is not extension or modifierExtension and it is not an array of interface objects, call checkExtension with this field map[string]interface
-			extensionField := extensionField.(map[string]interface{})
+			extensionField, ok := extensionField.(map[string]interface{})
+			if !ok {
+				continue
+			}
 			found = checkExtension(extensionField, fieldNames[1:length], url)
 			if found {
 				return found
 			}
 		} else {
 			// If the desired field is extension or modifierExtension, check array of extension interface objects for correct url
-			extensionArr := extensionField.([]interface{})
+			extensionArr, ok := extensionField.([]interface{})
+			if !ok {
+				continue
+			}
 			found = checkExtensionURL(extensionArr, url)
 			if found {
 				return found
@@ -218,7 +256,10 @@ func checkArrFieldExtension(fieldNames []string, fieldArr []interface{}, url str
 func checkExtensionURL(extensionArr []interface{}, url string) bool {
 	found := false
 	for _, extension := range extensionArr {
-		extensionMap := extension.(map[string]interface{})
+		extensionMap, ok := extension.(map[string]interface{})
+		if !ok {
+			continue
+		}
 		urlField := extensionMap["url"]
 		if urlField == url {
 			found = true
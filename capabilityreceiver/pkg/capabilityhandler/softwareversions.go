@@ -0,0 +1,52 @@
+package capabilityhandler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/pkg/errors"
+)
+
+// softwareVersion returns the software name, version and release date advertised in the given capability
+// statement, or nil if the capability statement does not advertise any software
+func softwareVersion(capStat capabilityparser.CapabilityStatement) *endpointmanager.EndpointSoftwareVersion {
+	if capStat == nil {
+		return nil
+	}
+
+	name, _ := capStat.GetSoftwareName()
+	version, _ := capStat.GetSoftwareVersion()
+	releaseDate, _ := capStat.GetSoftwareReleaseDate()
+
+	sv := endpointmanager.EndpointSoftwareVersion{
+		Name:        strings.TrimSpace(name),
+		Version:     strings.TrimSpace(version),
+		ReleaseDate: strings.TrimSpace(releaseDate),
+	}
+	if sv.Name == "" && sv.Version == "" && sv.ReleaseDate == "" {
+		return nil
+	}
+	return &sv
+}
+
+// recordSoftwareVersion records the software version advertised in the capability statement of the given
+// endpoint. Nothing is recorded if the capability statement does not advertise any software.
+func recordSoftwareVersion(ctx context.Context, store *postgresql.Store, endpt *endpointmanager.FHIREndpointInfo) error {
+	sv := softwareVersion(endpt.CapabilityStatement)
+	if sv == nil {
+		return nil
+	}
+
+	sv.URL = endpt.URL
+	sv.RequestedFhirVersion = endpt.RequestedFhirVersion
+	sv.VendorID = endpt.VendorID
+
+	err := store.RecordEndpointSoftwareVersion(ctx, sv)
+	if err != nil {
+		return errors.Wrap(err, "recording endpoint software version failed")
+	}
+	return nil
+}
@@ -23,65 +23,68 @@ func RunSupportedProfilesCheck(capInt map[string]interface{}, fhirVersion string
 
 // getConformanceProfiles stores all the profiles found in the Conformance statement profile array
 func getConformanceProfiles(capInt map[string]interface{}, supportedProfiles []endpointmanager.SupportedProfile) []endpointmanager.SupportedProfile {
-
-	if capInt["profile"] == nil {
+	supportedProfilesList, ok := capInt["profile"].([]interface{})
+	if !ok {
 		return supportedProfiles
 	}
 
-	supportedProfilesList := capInt["profile"].([]interface{})
-
 	for _, profile := range supportedProfilesList {
-		profileInt := profile.(map[string]interface{})
-		var profileInfo endpointmanager.SupportedProfile
-
-		if profileInt["display"] != nil {
-			profileInfo.ProfileName = profileInt["display"].(string)
-		}
-
-		if profileInt["reference"] != nil {
-			profileInfo.ProfileURL = profileInt["reference"].(string)
+		profileInt, ok := profile.(map[string]interface{})
+		if !ok {
+			continue
 		}
+		var profileInfo endpointmanager.SupportedProfile
+		profileInfo.ProfileName, _ = profileInt["display"].(string)
+		profileInfo.ProfileURL, _ = profileInt["reference"].(string)
 
 		supportedProfiles = append(supportedProfiles, profileInfo)
-
 	}
 
 	return supportedProfiles
 }
 
-// getCapabilityStatementProfiles stores all the profiles found in the Capability statement rest->resource->supportedProfile field
+// getCapabilityStatementProfiles stores all the profiles found in the Capability statement rest->resource->supportedProfile
+// field of each rest entry, along with the mode of the rest entry
 func getCapabilityStatementProfiles(capInt map[string]interface{}, supportedProfiles []endpointmanager.SupportedProfile) []endpointmanager.SupportedProfile {
-
-	if capInt["rest"] == nil {
+	restArr, ok := capInt["rest"].([]interface{})
+	if !ok {
 		return supportedProfiles
 	}
 
-	restArr := capInt["rest"].([]interface{})
-	restInt := restArr[0].(map[string]interface{})
-	if restInt["resource"] == nil {
-		return supportedProfiles
-	}
-	resourceArr := restInt["resource"].([]interface{})
-
-	for _, resource := range resourceArr {
-		resourceInt := resource.(map[string]interface{})
-
-		resourceType := ""
-		if resourceInt["type"] != nil {
-			resourceType = resourceInt["type"].(string)
+	for _, rest := range restArr {
+		restInt, ok := rest.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mode, _ := restInt["mode"].(string)
+		resourceArr, ok := restInt["resource"].([]interface{})
+		if !ok {
+			continue
 		}
 
-		if resourceInt["supportedProfile"] != nil {
-			supportedProfileArr := resourceInt["supportedProfile"].([]interface{})
+		for _, resource := range resourceArr {
+			resourceInt, ok := resource.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			resourceType, _ := resourceInt["type"].(string)
+
+			supportedProfileArr, ok := resourceInt["supportedProfile"].([]interface{})
+			if !ok {
+				continue
+			}
 			for _, profileEntry := range supportedProfileArr {
-				profileURL := profileEntry.(string)
+				profileURL, ok := profileEntry.(string)
+				if !ok {
+					continue
+				}
 				var profileInfo endpointmanager.SupportedProfile
 				profileInfo.ProfileURL = profileURL
 				profileInfo.Resource = resourceType
+				profileInfo.Mode = mode
 
 				supportedProfiles = append(supportedProfiles, profileInfo)
 			}
-
 		}
 	}
 
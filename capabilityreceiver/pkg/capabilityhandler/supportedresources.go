@@ -1,33 +1,58 @@
 package capabilityhandler
 
-// RunSupportedResourcesChecks takes the given capability statement and creates a map
+// RunSupportedResourcesChecks takes the given capability statement and creates maps
 // of the operations to the endpoint's resources that specified that operation. Example:
 // { "read": ["AllergyInformation", "Medication"...],
 //
 //	"search-type": ["Medication", "Document"...], ...}
-func RunSupportedResourcesChecks(capInt map[string]interface{}) map[string][]string {
+//
+// Every rest entry of the capability statement is included. The operations of server mode rest
+// entries are returned in the first map and those of client mode rest entries in the second, so
+// the operations the server supports are kept apart from the client ones. Elements that do not
+// have the expected type are skipped.
+func RunSupportedResourcesChecks(capInt map[string]interface{}) (map[string][]string, map[string][]string) {
 	var mapOpToResList = make(map[string][]string)
+	var clientMapOpToResList = make(map[string][]string)
 	if capInt == nil {
-		return mapOpToResList
+		return mapOpToResList, clientMapOpToResList
 	}
 
-	// Get the resource field from the Capability Statement, which is a list of resources
-	if capInt["rest"] == nil {
-		return mapOpToResList
+	// Get the resource field from each rest entry of the Capability Statement, which is a list of resources
+	restArr, ok := capInt["rest"].([]interface{})
+	if !ok {
+		return mapOpToResList, clientMapOpToResList
 	}
-	restArr := capInt["rest"].([]interface{})
-	restInt := restArr[0].(map[string]interface{})
-	if restInt["resource"] == nil {
-		return mapOpToResList
+	for _, rest := range restArr {
+		restInt, ok := rest.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceArr, ok := restInt["resource"].([]interface{})
+		if !ok {
+			continue
+		}
+		if mode, _ := restInt["mode"].(string); mode == "client" {
+			addResourceOperations(clientMapOpToResList, resourceArr)
+		} else {
+			addResourceOperations(mapOpToResList, resourceArr)
+		}
 	}
-	resourceArr := restInt["resource"].([]interface{})
 
+	return mapOpToResList, clientMapOpToResList
+}
+
+// addResourceOperations adds each of the operations of the given resources to the given map of operations
+// to resources
+func addResourceOperations(mapOpToResList map[string][]string, resourceArr []interface{}) {
 	for _, resource := range resourceArr {
-		resourceInt := resource.(map[string]interface{})
-		if resourceInt["type"] == nil {
+		resourceInt, ok := resource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, ok := resourceInt["type"].(string)
+		if !ok {
 			continue
 		}
-		resourceType := resourceInt["type"].(string)
 
 		// Keep track of the operations defined by each resource
 		notSpec := false
@@ -50,22 +75,23 @@ func RunSupportedResourcesChecks(capInt map[string]interface{}) map[string][]str
 					continue
 				}
 				hasCodes = true
-				if mapOpToResList[code] == nil {
-					mapOpToResList[code] = []string{resourceType}
-				} else {
-					mapOpToResList[code] = append(mapOpToResList[code], resourceType)
-				}
+				addOperationResource(mapOpToResList, code, resourceType)
 			}
 		}
 		// If the interaction field was not specified or it has no valid operations
 		if notSpec || !hasCodes {
-			if mapOpToResList["not specified"] == nil {
-				mapOpToResList["not specified"] = []string{resourceType}
-			} else {
-				mapOpToResList["not specified"] = append(mapOpToResList["not specified"], resourceType)
-			}
+			addOperationResource(mapOpToResList, "not specified", resourceType)
 		}
 	}
+}
 
-	return mapOpToResList
+// addOperationResource adds the given resource to the list of resources of the given operation if it
+// is not already in it. The same resource can appear in more than one rest entry.
+func addOperationResource(mapOpToResList map[string][]string, op string, resourceType string) {
+	for _, res := range mapOpToResList[op] {
+		if res == resourceType {
+			return
+		}
+	}
+	mapOpToResList[op] = append(mapOpToResList[op], resourceType)
 }
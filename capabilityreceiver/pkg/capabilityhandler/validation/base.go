@@ -1,56 +1,15 @@
 package validation
 
 import (
+	"net/url"
 	"strings"
 
-	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/smartparser"
-
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 )
 
-type baseVal struct {
-}
-
-// RunValidation runs all of the defined validation checks
-func (bv *baseVal) RunValidation(capStat capabilityparser.CapabilityStatement,
-	fhirVersion string,
-	tlsVersion string,
-	smartRsp smartparser.SMARTResponse,
-	requestedFhirVersion string,
-	defaultFhirVersion string) endpointmanager.Validation {
-	var validationResults []endpointmanager.Rule
-
-	returnedRule := bv.CapStatExists(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRules := bv.KindValid(capStat)
-	validationResults = append(validationResults, returnedRules[0])
-
-	returnedRule = bv.DescribeEndpointValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = bv.DocumentSetValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = bv.EndpointFunctionValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = bv.MessagingEndpointValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = bv.UniqueResources(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	validations := endpointmanager.Validation{
-		Results: validationResults,
-	}
-
-	return validations
-}
-
-// CapStatExists checks if the capability statement exists
-func (bv *baseVal) CapStatExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+// capStatExists checks if the capability statement exists
+func capStatExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "Servers SHALL provide a Conformance Resource that specifies which interactions and resources are supported."
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.CapStatExistRule,
@@ -71,29 +30,9 @@ func (bv *baseVal) CapStatExists(capStat capabilityparser.CapabilityStatement) e
 	return ruleError
 }
 
-func (bv *baseVal) TLSVersion(tlsVersion string) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
-	return ruleError
-}
-
-func (bv *baseVal) PatientResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
-	return ruleError
-}
-
-func (bv *baseVal) OtherResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
-	return ruleError
-}
-
-func (bv *baseVal) SmartResponseExists(smartRsp smartparser.SMARTResponse) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
-	return ruleError
-}
-
-// KindValid checks the rule that kind = instance since all of the endpoints we are looking
+// kindValid checks the rule that kind = instance since all of the endpoints we are looking
 // at are for server instances.
-func (bv *baseVal) KindValid(capStat capabilityparser.CapabilityStatement) []endpointmanager.Rule {
+func kindValid(capStat capabilityparser.CapabilityStatement) []endpointmanager.Rule {
 	baseComment := "Kind value should be set to 'instance' because this is a specific system instance."
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.KindRule,
@@ -125,7 +64,7 @@ func (bv *baseVal) KindValid(capStat capabilityparser.CapabilityStatement) []end
 	return returnVal
 }
 
-func (bv *baseVal) MessagingEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func messagingEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "Messaging end-point is required (and is only permitted) when a statement is for an implementation. This endpoint must be an implementation."
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.MessagingEndptRule,
@@ -137,7 +76,7 @@ func (bv *baseVal) MessagingEndpointValid(capStat capabilityparser.CapabilitySta
 		ImplGuide: "USCore 3.1",
 	}
 
-	kindRule := bv.KindValid(capStat)
+	kindRule := kindValid(capStat)
 	if !kindRule[0].Valid {
 		ruleError.Comment = kindRule[0].Comment + " " + baseComment
 		return ruleError
@@ -160,7 +99,7 @@ func (bv *baseVal) MessagingEndpointValid(capStat capabilityparser.CapabilitySta
 	return ruleError
 }
 
-func (bv *baseVal) EndpointFunctionValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func endpointFunctionValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	var actualVal []string
 	baseComment := "A Conformance Resource SHALL have at least one of REST, messaging or document element."
 	ruleError := endpointmanager.Rule{
@@ -203,9 +142,9 @@ func (bv *baseVal) EndpointFunctionValid(capStat capabilityparser.CapabilityStat
 	return ruleError
 }
 
-// DescribeEndpointValid checks the requirement: "A Conformance Resource/Capability Statement SHALL have at least one of description,
+// describeEndpointValid checks the requirement: "A Conformance Resource/Capability Statement SHALL have at least one of description,
 // software, or implementation element."
-func (bv *baseVal) DescribeEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func describeEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	var actualVal []string
 	baseComment := "A Conformance Resource SHALL have at least one of description, software, or implementation element."
 	ruleError := endpointmanager.Rule{
@@ -248,8 +187,8 @@ func (bv *baseVal) DescribeEndpointValid(capStat capabilityparser.CapabilityStat
 	return ruleError
 }
 
-// DocumentSetValid checks the requirement: "The set of documents must be unique by the combination of profile and mode."
-func (bv *baseVal) DocumentSetValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+// documentSetValid checks the requirement: "The set of documents must be unique by the combination of profile and mode."
+func documentSetValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "The set of documents must be unique by the combination of profile and mode."
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.DocumentValidRule,
@@ -317,7 +256,7 @@ func (bv *baseVal) DocumentSetValid(capStat capabilityparser.CapabilityStatement
 	return ruleError
 }
 
-func (bv *baseVal) UniqueResources(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func uniqueResources(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "A given resource can only be described once per RESTful mode."
 	returnVal := checkResourceList(capStat, endpointmanager.UniqueResourcesRule)
 	returnVal.Comment = returnVal.Comment + baseComment
@@ -325,12 +264,69 @@ func (bv *baseVal) UniqueResources(capStat capabilityparser.CapabilityStatement)
 	return returnVal
 }
 
-func (bv *baseVal) SearchParamsUnique(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
+// redirectsSecure checks that none of the redirects followed when requesting the endpoint went from an
+// HTTPS URL to an HTTP URL
+func redirectsSecure(redirects []endpointmanager.Redirect) endpointmanager.Rule {
+	baseComment := "Redirects from a secure URL SHALL NOT downgrade the request to an insecure URL."
+	ruleError := endpointmanager.Rule{
+		RuleName:  endpointmanager.RedirectSecureRule,
+		Valid:     true,
+		Expected:  "true",
+		Actual:    "true",
+		Comment:   baseComment,
+		Reference: "http://hl7.org/fhir/security.html",
+	}
+
+	for _, redirect := range redirects {
+		from, fromErr := url.Parse(redirect.URL)
+		to, toErr := url.Parse(redirect.Location)
+		if fromErr != nil || toErr != nil {
+			continue
+		}
+		if strings.EqualFold(from.Scheme, "https") && strings.EqualFold(to.Scheme, "http") {
+			ruleError.Valid = false
+			ruleError.Actual = "false"
+			ruleError.Comment = "The endpoint redirected from " + redirect.URL + " to " + redirect.Location + ". " + baseComment
+			return ruleError
+		}
+	}
+
 	return ruleError
 }
 
-func (bv *baseVal) VersionResponseValid(fhirVersion string, defaultFhirVersion string) endpointmanager.Rule {
-	var ruleError endpointmanager.Rule
+// redirectsSameDomain checks that all of the redirects followed when requesting the endpoint stayed on the
+// host of the endpoint
+func redirectsSameDomain(redirects []endpointmanager.Redirect) endpointmanager.Rule {
+	baseComment := "Redirects should not send requests for the endpoint to a different domain."
+	ruleError := endpointmanager.Rule{
+		RuleName:  endpointmanager.RedirectDomainRule,
+		Valid:     true,
+		Expected:  "true",
+		Actual:    "true",
+		Comment:   baseComment,
+		Reference: "http://hl7.org/fhir/http.html",
+	}
+
+	if len(redirects) == 0 {
+		return ruleError
+	}
+	endpointURL, err := url.Parse(redirects[0].URL)
+	if err != nil {
+		return ruleError
+	}
+
+	for _, redirect := range redirects {
+		to, err := url.Parse(redirect.Location)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(endpointURL.Hostname(), to.Hostname()) {
+			ruleError.Valid = false
+			ruleError.Actual = "false"
+			ruleError.Comment = "The endpoint redirected from " + redirect.URL + " to " + redirect.Location + ". " + baseComment
+			return ruleError
+		}
+	}
+
 	return ruleError
 }
@@ -3,7 +3,6 @@ package validation
 import (
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
-	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/smartparser"
 )
 
@@ -13,40 +12,39 @@ var dstu2 = []string{"0.4.0", "0.5.0", "1.0.0", "1.0.1", "1.0.2"}
 var stu3 = []string{"1.1.0", "1.2.0", "1.4.0", "1.6.0", "1.8.0", "3.0.0", "3.0.1", "3.0.2"}
 var r4 = []string{"3.2.0", "3.3.0", "3.5.0", "3.5a.0", "4.0.0", "4.0.1"}
 
-// Validator is an interface that can be implemented for each FHIR Version to run the correct
-// version's validation checks
+// Validator runs the validation checks registered for an endpoint's FHIR version
 type Validator interface {
-	RunValidation(capabilityparser.CapabilityStatement, string, string, smartparser.SMARTResponse, string, string) endpointmanager.Validation
-	CapStatExists(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	VersionResponseValid(string, string) endpointmanager.Rule
-	TLSVersion(string) endpointmanager.Rule
-	PatientResourceExists(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	OtherResourceExists(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	SmartResponseExists(smartparser.SMARTResponse) endpointmanager.Rule
-	KindValid(capabilityparser.CapabilityStatement) []endpointmanager.Rule
-	MessagingEndpointValid(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	EndpointFunctionValid(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	DescribeEndpointValid(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	DocumentSetValid(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	UniqueResources(capabilityparser.CapabilityStatement) endpointmanager.Rule
-	SearchParamsUnique(capabilityparser.CapabilityStatement) endpointmanager.Rule
+	RunValidation(capabilityparser.CapabilityStatement, string, string, smartparser.SMARTResponse, string, string, []endpointmanager.Redirect) endpointmanager.Validation
 }
 
-// ValidatorForFHIRVersion checks the given fhir version and returns the specific validator
-// for that version, which can be used for running the Validation checks.
-// To note: All but the newR4Val() function returns the base validation currently
-func ValidatorForFHIRVersion(fhirVersion string) Validator {
-	if fhirVersion == "" {
-		return newUnknownVal()
-	}
+// familyValidator runs the rules registered for a FHIR version family
+type familyValidator struct {
+	family string
+}
 
-	if helpers.StringArrayContains(dstu2, fhirVersion) {
-		return newDSTU2Val()
-	} else if helpers.StringArrayContains(stu3, fhirVersion) {
-		return newSTU3Val()
-	} else if helpers.StringArrayContains(r4, fhirVersion) {
-		return newR4Val()
+// RunValidation runs all of the rules registered for the validator's FHIR version family
+func (v *familyValidator) RunValidation(capStat capabilityparser.CapabilityStatement,
+	fhirVersion string,
+	tlsVersion string,
+	smartRsp smartparser.SMARTResponse,
+	requestedFhirVersion string,
+	defaultFhirVersion string,
+	redirects []endpointmanager.Redirect) endpointmanager.Validation {
+	input := Input{
+		Family:               v.family,
+		CapStat:              capStat,
+		FHIRVersion:          fhirVersion,
+		TLSVersion:           tlsVersion,
+		SMARTResponse:        smartRsp,
+		RequestedFhirVersion: requestedFhirVersion,
+		DefaultFhirVersion:   defaultFhirVersion,
+		Redirects:            redirects,
 	}
+	return runRegisteredRules(&input)
+}
 
-	return newUnknownVal()
+// ValidatorForFHIRVersion checks the given fhir version and returns the validator for its FHIR version family,
+// which can be used for running the Validation checks.
+func ValidatorForFHIRVersion(fhirVersion string) Validator {
+	return &familyValidator{family: familyForVersion(fhirVersion)}
 }
@@ -18,79 +18,12 @@ var usCoreProfiles = []string{"AllergyIntolerance", "CarePlan", "CareTeam",
 	"MedicationRequest", "Organization", "Practitioner", "PractitionerRole",
 	"Procedure", "Provenance"}
 
-type r4Validation struct {
-	baseVal
-}
-
-func newR4Val() *r4Validation {
-	return &r4Validation{
-		baseVal: baseVal{},
-	}
-}
-
-// RunValidation runs all of the defined validation checks
-func (v *r4Validation) RunValidation(capStat capabilityparser.CapabilityStatement,
-	fhirVersion string,
-	tlsVersion string,
-	smartRsp smartparser.SMARTResponse,
-	requestedFhirVersion string,
-	defaultFhirVersion string) endpointmanager.Validation {
-	var validationResults []endpointmanager.Rule
-
-	returnedRule := v.CapStatExists(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	if requestedFhirVersion == "None" && defaultFhirVersion != "" {
-		returnedRule = v.VersionResponseValid(fhirVersion, defaultFhirVersion)
-		validationResults = append(validationResults, returnedRule)
-	}
-
-	returnedRule = v.TLSVersion(tlsVersion)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.PatientResourceExists(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.OtherResourceExists(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.SmartResponseExists(smartRsp)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRules := v.KindValid(capStat)
-	validationResults = append(validationResults, returnedRules[0], returnedRules[1])
-
-	returnedRule = v.MessagingEndpointValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.EndpointFunctionValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.DescribeEndpointValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.DocumentSetValid(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.UniqueResources(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	returnedRule = v.SearchParamsUnique(capStat)
-	validationResults = append(validationResults, returnedRule)
-
-	validations := endpointmanager.Validation{
-		Results: validationResults,
-	}
-
-	return validations
-}
-
-// CapStatExists checks if the capability statement exists using the base function, and then
+// r4CapStatExists checks if the capability statement exists using the base function, and then
 // adds specific R4 reference information
-func (v *r4Validation) CapStatExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func r4CapStatExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "Servers SHALL provide a Capability Statement that specifies which interactions and resources are supported."
 
-	baseRule := v.baseVal.CapStatExists(capStat)
+	baseRule := capStatExists(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/http.html"
 	baseRule.ImplGuide = "USCore 3.1"
 
@@ -103,9 +36,9 @@ func (v *r4Validation) CapStatExists(capStat capabilityparser.CapabilityStatemen
 	return baseRule
 }
 
-// TLSVersion checks if the given TLS version string is version 1.2 or higher, which is a
+// r4TLSVersion checks if the given TLS version string is version 1.2 or higher, which is a
 // USCore security requirement
-func (v *r4Validation) TLSVersion(tlsVersion string) endpointmanager.Rule {
+func r4TLSVersion(tlsVersion string) endpointmanager.Rule {
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.TLSVersion,
 		Valid:     true,
@@ -123,9 +56,9 @@ func (v *r4Validation) TLSVersion(tlsVersion string) endpointmanager.Rule {
 	return ruleError
 }
 
-// PatientResourceExists checks to see if the Patient resource is included in the resource list,
+// r4PatientResourceExists checks to see if the Patient resource is included in the resource list,
 // which is a USCore requirement
-func (v *r4Validation) PatientResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func r4PatientResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "The US Core Server SHALL support the US Core Patient resource profile."
 	returnVal := checkResourceList(capStat, endpointmanager.PatResourceExists)
 	returnVal.Comment = returnVal.Comment + baseComment
@@ -133,9 +66,9 @@ func (v *r4Validation) PatientResourceExists(capStat capabilityparser.Capability
 	return returnVal
 }
 
-// OtherResourceExists checks to see if there is another resource besides Patient included
+// r4OtherResourceExists checks to see if there is another resource besides Patient included
 // in the resource list, which is a USCore requirement
-func (v *r4Validation) OtherResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func r4OtherResourceExists(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "The US Core Server SHALL support at least one additional resource profile (besides Patient) from the list of US Core Profiles."
 	returnVal := checkResourceList(capStat, endpointmanager.OtherResourceExists)
 	returnVal.Comment = returnVal.Comment + baseComment
@@ -167,52 +100,56 @@ func checkResourceList(capStat capabilityparser.CapabilityStatement, rule endpoi
 
 	var uniqueRecs []string
 	areParamsValid := true
-	for _, restElem := range rest {
-		resourceList, err := capStat.GetResourceList(restElem)
-		if err != nil || len(resourceList) == 0 {
-			ruleError.Comment = "The Resource Profiles do not exist. "
+	// only the resources the server supports are checked, client mode rest entries describe what the
+	// system does when it acts as a client
+	resourceList, err := capStat.GetResourceListByMode(capabilityparser.RestModeServer)
+	if err != nil {
+		ruleError.Comment = "The Rest field is not properly formatted. "
+		return ruleError
+	}
+	if len(resourceList) == 0 {
+		ruleError.Comment = "The Resource Profiles do not exist. "
+		return ruleError
+	}
+	for _, resource := range resourceList {
+		typeVal := resource["type"]
+		if typeVal == nil {
+			ruleError.Comment = "The Resource Profiles are not properly formatted. "
+			return ruleError
+		}
+		typeStr, ok := typeVal.(string)
+		if !ok {
+			ruleError.Comment = "The Resource Profiles are not properly formatted. "
 			return ruleError
 		}
-		for _, resource := range resourceList {
-			typeVal := resource["type"]
-			if typeVal == nil {
-				ruleError.Comment = "The Resource Profiles are not properly formatted. "
+		if rule == endpointmanager.OtherResourceExists {
+			if stringInList(typeStr, usCoreProfiles) {
+				ruleError.Valid = true
+				ruleError.Actual = "true"
+				return ruleError
+			}
+		} else if rule == endpointmanager.PatResourceExists {
+			if typeStr == "Patient" {
+				ruleError.Valid = true
+				ruleError.Actual = "true"
 				return ruleError
 			}
-			typeStr, ok := typeVal.(string)
-			if !ok {
-				ruleError.Comment = "The Resource Profiles are not properly formatted. "
+		} else if rule == endpointmanager.UniqueResourcesRule {
+			if stringInList(typeStr, uniqueRecs) {
+				ruleError.Comment = fmt.Sprintf("The resource type %s is not unique. ", typeStr)
 				return ruleError
 			}
-			if rule == endpointmanager.OtherResourceExists {
-				if stringInList(typeStr, usCoreProfiles) {
-					ruleError.Valid = true
-					ruleError.Actual = "true"
-					return ruleError
-				}
-			} else if rule == endpointmanager.PatResourceExists {
-				if typeStr == "Patient" {
-					ruleError.Valid = true
-					ruleError.Actual = "true"
-					return ruleError
-				}
-			} else if rule == endpointmanager.UniqueResourcesRule {
-				if stringInList(typeStr, uniqueRecs) {
-					ruleError.Comment = fmt.Sprintf("The resource type %s is not unique. ", typeStr)
-					return ruleError
-				}
-				uniqueRecs = append(uniqueRecs, typeStr)
-			} else if rule == endpointmanager.SearchParamsRule {
-				check, err := areSearchParamsValid(resource)
-				if err != nil {
-					areParamsValid = false
-					ruleError.Comment = ruleError.Comment + fmt.Sprintf("The resource type %s is not formatted properly. ", typeStr)
-					continue
-				}
-				if !check {
-					areParamsValid = false
-					ruleError.Comment = ruleError.Comment + fmt.Sprintf("The resource type %s does not have unique searchParams. ", typeStr)
-				}
+			uniqueRecs = append(uniqueRecs, typeStr)
+		} else if rule == endpointmanager.SearchParamsRule {
+			check, err := areSearchParamsValid(resource)
+			if err != nil {
+				areParamsValid = false
+				ruleError.Comment = ruleError.Comment + fmt.Sprintf("The resource type %s is not formatted properly. ", typeStr)
+				continue
+			}
+			if !check {
+				areParamsValid = false
+				ruleError.Comment = ruleError.Comment + fmt.Sprintf("The resource type %s does not have unique searchParams. ", typeStr)
 			}
 		}
 	}
@@ -225,8 +162,8 @@ func checkResourceList(capStat capabilityparser.CapabilityStatement, rule endpoi
 	return ruleError
 }
 
-// SmartResponseExists checks if the SMART-on-FHIR response exists
-func (v *r4Validation) SmartResponseExists(smartRsp smartparser.SMARTResponse) endpointmanager.Rule {
+// r4SmartResponseExists checks if the SMART-on-FHIR response exists
+func r4SmartResponseExists(smartRsp smartparser.SMARTResponse) endpointmanager.Rule {
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.SmartRespExistsRule,
 		Valid:     true,
@@ -247,14 +184,14 @@ func (v *r4Validation) SmartResponseExists(smartRsp smartparser.SMARTResponse) e
 	return ruleError
 }
 
-// KindValid checks 2 Rules: The first, which is the baseVal rule, is that kind = instance since all of the
+// r4KindValid checks 2 Rules: The first, which is the base rule, is that kind = instance since all of the
 // endpoints we are looking at are for server instances. It then checks the rule: "If kind = instance,
 // implementation should be present."
-func (v *r4Validation) KindValid(capStat capabilityparser.CapabilityStatement) []endpointmanager.Rule {
+func r4KindValid(capStat capabilityparser.CapabilityStatement) []endpointmanager.Rule {
 	baseComment := "Kind value should be set to 'instance' because this is a specific system instance."
 
 	var rules []endpointmanager.Rule
-	baseRule := v.baseVal.KindValid(capStat)
+	baseRule := kindValid(capStat)
 	baseRule[0].Reference = "http://hl7.org/fhir/capabilitystatement.html"
 	baseRule[0].ImplGuide = "USCore 3.1"
 	rules = append(rules, baseRule[0])
@@ -282,13 +219,13 @@ func (v *r4Validation) KindValid(capStat capabilityparser.CapabilityStatement) [
 	return rules
 }
 
-// MessagingEndpointValid checks the requirement "Messaging endpoint is required (and is only permitted) when a statement is for an implementation."
+// r4MessagingEndpointValid checks the requirement "Messaging endpoint is required (and is only permitted) when a statement is for an implementation."
 // Every endpoint we are testing should be an implementation, which means the endpoint field should be there.
-func (v *r4Validation) MessagingEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+func r4MessagingEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseKindComment := "Kind value should be set to 'instance' because this is a specific system instance."
 	baseMessagingComment := "Messaging end-point is required (and is only permitted) when a statement is for an implementation. This endpoint must be an implementation."
 
-	baseRule := v.baseVal.MessagingEndpointValid(capStat)
+	baseRule := messagingEndpointValid(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/capabilitystatement.html"
 
 	if capStat == nil {
@@ -298,10 +235,10 @@ func (v *r4Validation) MessagingEndpointValid(capStat capabilityparser.Capabilit
 	return baseRule
 }
 
-// EndpointFunctionValid checks the requirement "A Capability Statement SHALL have at least one of REST,
+// r4EndpointFunctionValid checks the requirement "A Capability Statement SHALL have at least one of REST,
 // messaging or document element."
-func (v *r4Validation) EndpointFunctionValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	baseRule := v.baseVal.EndpointFunctionValid(capStat)
+func r4EndpointFunctionValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+	baseRule := endpointFunctionValid(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/capabilitystatement.html"
 	baseRule.Comment = "A Capability Statement SHALL have at least one of REST, messaging or document element."
 
@@ -312,10 +249,10 @@ func (v *r4Validation) EndpointFunctionValid(capStat capabilityparser.Capability
 	return baseRule
 }
 
-// DescribeEndpointValid checks the requirement: "A Capability Statement SHALL have at least one of description,
+// r4DescribeEndpointValid checks the requirement: "A Capability Statement SHALL have at least one of description,
 // software, or implementation element."
-func (v *r4Validation) DescribeEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	baseRule := v.baseVal.DescribeEndpointValid(capStat)
+func r4DescribeEndpointValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+	baseRule := describeEndpointValid(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/capabilitystatement.html"
 	baseRule.Comment = "A Capability Statement SHALL have at least one of description, software, or implementation element."
 
@@ -326,9 +263,9 @@ func (v *r4Validation) DescribeEndpointValid(capStat capabilityparser.Capability
 	return baseRule
 }
 
-// DocumentSetValid checks the requirement: "The set of documents must be unique by the combination of profile and mode."
-func (v *r4Validation) DocumentSetValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	baseRule := v.baseVal.DocumentSetValid(capStat)
+// r4DocumentSetValid checks the requirement: "The set of documents must be unique by the combination of profile and mode."
+func r4DocumentSetValid(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+	baseRule := documentSetValid(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/capabilitystatement.html"
 
 	if capStat == nil {
@@ -338,15 +275,15 @@ func (v *r4Validation) DocumentSetValid(capStat capabilityparser.CapabilityState
 	return baseRule
 }
 
-// UniqueResources checks the requirement: "A given resource can only be described once per RESTful mode."
-func (v *r4Validation) UniqueResources(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
-	baseRule := v.baseVal.UniqueResources(capStat)
+// r4UniqueResources checks the requirement: "A given resource can only be described once per RESTful mode."
+func r4UniqueResources(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+	baseRule := uniqueResources(capStat)
 	baseRule.Reference = "http://hl7.org/fhir/capabilitystatement.html"
 	return baseRule
 }
 
-// SearchParamsUnique checks the requirement: "Search parameter names must be unique in the context of a resource."
-func (v *r4Validation) SearchParamsUnique(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
+// r4SearchParamsUnique checks the requirement: "Search parameter names must be unique in the context of a resource."
+func r4SearchParamsUnique(capStat capabilityparser.CapabilityStatement) endpointmanager.Rule {
 	baseComment := "Search parameter names must be unique in the context of a resource."
 	returnVal := checkResourceList(capStat, endpointmanager.SearchParamsRule)
 	returnVal.Comment = returnVal.Comment + baseComment
@@ -387,8 +324,8 @@ func areSearchParamsValid(resource map[string]interface{}) (bool, error) {
 	return true, nil
 }
 
-// VersionResponseValid checks if $versions operation is supported and that the default version is returned when no version requested
-func (v *r4Validation) VersionResponseValid(fhirVersion string, defaultFhirVersion string) endpointmanager.Rule {
+// r4VersionResponseValid checks if $versions operation is supported and that the default version is returned when no version requested
+func r4VersionResponseValid(fhirVersion string, defaultFhirVersion string) endpointmanager.Rule {
 	ruleError := endpointmanager.Rule{
 		RuleName:  endpointmanager.VersionsResponseRule,
 		Valid:     true,
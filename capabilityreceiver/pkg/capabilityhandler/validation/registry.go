@@ -0,0 +1,380 @@
+package validation
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/smartparser"
+)
+
+// The FHIR version families a rule can be registered against. These line up with the
+// validators returned by ValidatorForFHIRVersion.
+const (
+	DSTU2Family   = "dstu2"
+	STU3Family    = "stu3"
+	R4Family      = "r4"
+	UnknownFamily = "unknown"
+)
+
+var allFamilies = []string{DSTU2Family, STU3Family, R4Family, UnknownFamily}
+
+// Input holds all of the information about an endpoint that a rule can check. Family is the FHIR version
+// family of the validator running the rule.
+type Input struct {
+	Family               string
+	CapStat              capabilityparser.CapabilityStatement
+	FHIRVersion          string
+	TLSVersion           string
+	SMARTResponse        smartparser.SMARTResponse
+	RequestedFhirVersion string
+	DefaultFhirVersion   string
+	Redirects            []endpointmanager.Redirect
+}
+
+// RuleCheck runs a rule against the given input. A check can return more than one rule result, or none if the
+// rule does not apply to the input.
+type RuleCheck func(*Input) []endpointmanager.Rule
+
+// RuleDefinition is an entry in the rule registry. The Reference, ImplGuide and Severity are only applied to
+// results that do not already set them. The Weight is split evenly between the results a check returns, so a
+// rule that returns a result per resource counts the same towards the conformance score as a rule that returns
+// a single result. If the Weight is not set, the default weight for the rule's severity is used.
+type RuleDefinition struct {
+	Name         endpointmanager.RuleOption
+	FHIRVersions []string
+	Severity     endpointmanager.Severity
+	Weight       float64
+	Reference    string
+	ImplGuide    string
+	Check        RuleCheck
+}
+
+// ruleRegistry holds the registered rules and, for each FHIR version family, the order its rules are run in.
+// The order is the order the results are returned in.
+type ruleRegistry struct {
+	mu    sync.RWMutex
+	rules map[endpointmanager.RuleOption]RuleDefinition
+	order map[string][]endpointmanager.RuleOption
+}
+
+// registry holds the rules that RunValidation runs
+var registry = newRegistry()
+
+// newRegistry returns a registry holding the built-in rules in the order each family has always run them in
+func newRegistry() *ruleRegistry {
+	r := &ruleRegistry{
+		rules: map[endpointmanager.RuleOption]RuleDefinition{},
+		order: map[string][]endpointmanager.RuleOption{},
+	}
+	for _, rule := range builtInRules() {
+		r.rules[rule.Name] = rule
+	}
+	order := builtInOrder()
+	for _, family := range allFamilies {
+		r.order[family] = order[family]
+		for _, name := range order[family] {
+			rule := r.rules[name]
+			rule.FHIRVersions = append(rule.FHIRVersions, family)
+			r.rules[name] = rule
+		}
+	}
+	return r
+}
+
+// RegisterRule adds a rule to the registry, to be run after the rules already registered for each of its FHIR
+// versions. Rules should be registered at startup before any validation is run.
+func RegisterRule(rule RuleDefinition) error {
+	if rule.Name == "" {
+		return fmt.Errorf("rule must have a name")
+	}
+	if rule.Check == nil {
+		return fmt.Errorf("rule %s must have a check function", rule.Name)
+	}
+	if len(rule.FHIRVersions) == 0 {
+		return fmt.Errorf("rule %s must apply to at least one FHIR version", rule.Name)
+	}
+	for _, family := range rule.FHIRVersions {
+		if !helpers.StringArrayContains(allFamilies, family) {
+			return fmt.Errorf("rule %s has unknown FHIR version %s", rule.Name, family)
+		}
+	}
+	if rule.Severity == "" {
+		rule.Severity = endpointmanager.SeverityError
+	}
+	if rule.Severity.DefaultWeight() == 0 {
+		return fmt.Errorf("rule %s has unknown severity %s", rule.Name, rule.Severity)
+	}
+	if rule.Weight < 0 {
+		return fmt.Errorf("rule %s has negative weight %f", rule.Name, rule.Weight)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.rules[rule.Name]; ok {
+		return fmt.Errorf("rule %s is already registered", rule.Name)
+	}
+	registry.rules[rule.Name] = rule
+	for _, family := range rule.FHIRVersions {
+		registry.order[family] = append(registry.order[family], rule.Name)
+	}
+	return nil
+}
+
+// RegisteredRules returns the rules registered for the given FHIR version family in the order
+// they are run
+func RegisteredRules(family string) []RuleDefinition {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	var rules []RuleDefinition
+	for _, name := range registry.order[family] {
+		rules = append(rules, registry.rules[name])
+	}
+	return rules
+}
+
+// runRegisteredRules runs every rule registered for the input's family, computes the conformance score from the
+// results and structurally validates the capability statement
+func runRegisteredRules(input *Input) endpointmanager.Validation {
+	var validationResults []endpointmanager.Rule
+
+	for _, rule := range RegisteredRules(input.Family) {
+		weight := rule.Weight
+		if weight == 0 {
+			weight = rule.Severity.DefaultWeight()
+		}
+		results := rule.Check(input)
+		for _, result := range results {
+			if result.Reference == "" {
+				result.Reference = rule.Reference
+			}
+			if result.ImplGuide == "" {
+				result.ImplGuide = rule.ImplGuide
+			}
+			if result.Severity == "" {
+				result.Severity = rule.Severity
+			}
+			if result.Weight == 0 {
+				result.Weight = weight / float64(len(results))
+			}
+			validationResults = append(validationResults, result)
+		}
+	}
+
+	validation := endpointmanager.Validation{
+		Results: validationResults,
+	}
+	validation.Score = validation.ComputeScore()
+	validation.Issues = validateStructure(input.Family, input.CapStat)
+	return validation
+}
+
+// builtInOrder returns the order each FHIR version family runs the built-in rules in. The DSTU2, STU3 and unknown
+// version validators have always returned their results in a different order than the R4 validator, and the
+// order is kept so that the stored results stay in the same order as before.
+func builtInOrder() map[string][]endpointmanager.RuleOption {
+	base := func() []endpointmanager.RuleOption {
+		return []endpointmanager.RuleOption{
+			endpointmanager.CapStatExistRule,
+			endpointmanager.KindRule,
+			endpointmanager.DescribeEndptRule,
+			endpointmanager.DocumentValidRule,
+			endpointmanager.EndptFunctionRule,
+			endpointmanager.MessagingEndptRule,
+			endpointmanager.UniqueResourcesRule,
+			endpointmanager.RedirectSecureRule,
+			endpointmanager.RedirectDomainRule,
+		}
+	}
+	return map[string][]endpointmanager.RuleOption{
+		DSTU2Family:   base(),
+		STU3Family:    base(),
+		UnknownFamily: base(),
+		R4Family: {
+			endpointmanager.CapStatExistRule,
+			endpointmanager.VersionsResponseRule,
+			endpointmanager.TLSVersion,
+			endpointmanager.PatResourceExists,
+			endpointmanager.OtherResourceExists,
+			endpointmanager.SmartRespExistsRule,
+			endpointmanager.KindRule,
+			endpointmanager.MessagingEndptRule,
+			endpointmanager.EndptFunctionRule,
+			endpointmanager.DescribeEndptRule,
+			endpointmanager.DocumentValidRule,
+			endpointmanager.UniqueResourcesRule,
+			endpointmanager.SearchParamsRule,
+			endpointmanager.RedirectSecureRule,
+			endpointmanager.RedirectDomainRule,
+		},
+	}
+}
+
+// builtInRules returns the built-in rules. Their checks use the implementation for the input's FHIR version
+// family, and the families each rule applies to are given by builtInOrder.
+func builtInRules() []RuleDefinition {
+	return []RuleDefinition{
+		{
+			Name:     endpointmanager.CapStatExistRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4CapStatExists(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3CapStatExists(in.CapStat)}
+				}
+				return []endpointmanager.Rule{capStatExists(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.VersionsResponseRule,
+			Severity: endpointmanager.SeverityWarning,
+			Check: func(in *Input) []endpointmanager.Rule {
+				// only check the $versions default when no specific version was requested
+				if in.RequestedFhirVersion != "None" || in.DefaultFhirVersion == "" {
+					return nil
+				}
+				return []endpointmanager.Rule{r4VersionResponseValid(in.FHIRVersion, in.DefaultFhirVersion)}
+			},
+		},
+		{
+			Name:     endpointmanager.TLSVersion,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				return []endpointmanager.Rule{r4TLSVersion(in.TLSVersion)}
+			},
+		},
+		{
+			Name:     endpointmanager.PatResourceExists,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				return []endpointmanager.Rule{r4PatientResourceExists(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.OtherResourceExists,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				return []endpointmanager.Rule{r4OtherResourceExists(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.SmartRespExistsRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				return []endpointmanager.Rule{r4SmartResponseExists(in.SMARTResponse)}
+			},
+		},
+		{
+			// the R4 check also returns the instanceRule result
+			Name:     endpointmanager.KindRule,
+			Severity: endpointmanager.SeverityWarning,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return r4KindValid(in.CapStat)
+				case STU3Family:
+					return stu3KindValid(in.CapStat)
+				}
+				return kindValid(in.CapStat)
+			},
+		},
+		{
+			Name:     endpointmanager.MessagingEndptRule,
+			Severity: endpointmanager.SeverityInfo,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4MessagingEndpointValid(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3MessagingEndpointValid(in.CapStat)}
+				}
+				return []endpointmanager.Rule{messagingEndpointValid(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.EndptFunctionRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4EndpointFunctionValid(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3EndpointFunctionValid(in.CapStat)}
+				}
+				return []endpointmanager.Rule{endpointFunctionValid(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.DescribeEndptRule,
+			Severity: endpointmanager.SeverityWarning,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4DescribeEndpointValid(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3DescribeEndpointValid(in.CapStat)}
+				}
+				return []endpointmanager.Rule{describeEndpointValid(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.DocumentValidRule,
+			Severity: endpointmanager.SeverityWarning,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4DocumentSetValid(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3DocumentSetValid(in.CapStat)}
+				}
+				return []endpointmanager.Rule{documentSetValid(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.UniqueResourcesRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				switch in.Family {
+				case R4Family:
+					return []endpointmanager.Rule{r4UniqueResources(in.CapStat)}
+				case STU3Family:
+					return []endpointmanager.Rule{stu3UniqueResources(in.CapStat)}
+				}
+				return []endpointmanager.Rule{uniqueResources(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.SearchParamsRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				return []endpointmanager.Rule{r4SearchParamsUnique(in.CapStat)}
+			},
+		},
+		{
+			Name:     endpointmanager.RedirectSecureRule,
+			Severity: endpointmanager.SeverityError,
+			Check: func(in *Input) []endpointmanager.Rule {
+				// only check redirects when the endpoint redirected the request
+				if len(in.Redirects) == 0 {
+					return nil
+				}
+				return []endpointmanager.Rule{redirectsSecure(in.Redirects)}
+			},
+		},
+		{
+			Name:     endpointmanager.RedirectDomainRule,
+			Severity: endpointmanager.SeverityWarning,
+			Check: func(in *Input) []endpointmanager.Rule {
+				if len(in.Redirects) == 0 {
+					return nil
+				}
+				return []endpointmanager.Rule{redirectsSameDomain(in.Redirects)}
+			},
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LoadResourceDir loads the declarative validation rules, US Core requirements and StructureDefinitions
+// from the given resource directory. Any of them that do not exist are skipped with a warning.
+func LoadResourceDir(dir string) error {
+	ruleFile := filepath.Join(dir, "ValidationRules.json")
+	if _, err := os.Stat(ruleFile); err == nil {
+		err = LoadRuleFile(ruleFile)
+		if err != nil {
+			return err
+		}
+		log.Info("Successfully loaded validation rules!")
+	} else {
+		log.Warnf("No validation rule file found at %s, only running built in validation rules", ruleFile)
+	}
+
+	usCoreFile := filepath.Join(dir, "USCoreRequirements.json")
+	if _, err := os.Stat(usCoreFile); err == nil {
+		err = LoadUSCoreRequirementsFile(usCoreFile)
+		if err != nil {
+			return err
+		}
+		log.Info("Successfully loaded US Core requirements!")
+	} else {
+		log.Warnf("No US Core requirements file found at %s, not running US Core resource checks", usCoreFile)
+	}
+
+	structureDefDir := filepath.Join(dir, "structuredefinitions")
+	if _, err := os.Stat(structureDefDir); err == nil {
+		err = LoadStructureDefinitionDir(structureDefDir)
+		if err != nil {
+			return err
+		}
+		log.Info("Successfully loaded StructureDefinitions!")
+	} else {
+		log.Warnf("No StructureDefinition directory found at %s, not structurally validating capability statements", structureDefDir)
+	}
+
+	return nil
+}
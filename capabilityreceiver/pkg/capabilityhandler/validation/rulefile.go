@@ -0,0 +1,152 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/pkg/errors"
+)
+
+// declarativeRule is a simple field presence or field value rule as defined in a rule file.
+// If Value is empty, the rule checks that the field at Path exists. Otherwise the rule checks
+// that the field at Path is equal to Value. If a field in the path is an array, each
+// element of the array is checked and the rule passes if any of them match.
+type declarativeRule struct {
+	Name         string                   `json:"name"`
+	FHIRVersions []string                 `json:"fhirVersions"`
+	Severity     endpointmanager.Severity `json:"severity"`
+	Weight       float64                  `json:"weight"`
+	Path         []string                 `json:"path"`
+	Value        string                   `json:"value"`
+	Comment      string                   `json:"comment"`
+	Reference    string                   `json:"reference"`
+	ImplGuide    string                   `json:"implGuide"`
+}
+
+type ruleFile struct {
+	Rules []declarativeRule `json:"rules"`
+}
+
+// LoadRuleFile reads the declarative rules in the given JSON file and adds them to the rule registry
+func LoadRuleFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read rule file %s", path)
+	}
+
+	return LoadRules(contents)
+}
+
+// LoadRules parses the given JSON rule definitions and adds them to the rule registry
+func LoadRules(contents []byte) error {
+	var rf ruleFile
+	err := json.Unmarshal(contents, &rf)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse rule definitions")
+	}
+
+	for _, dr := range rf.Rules {
+		if len(dr.Path) == 0 {
+			return fmt.Errorf("rule %s must have a path", dr.Name)
+		}
+		err = RegisterRule(RuleDefinition{
+			Name:         endpointmanager.RuleOption(dr.Name),
+			FHIRVersions: dr.FHIRVersions,
+			Severity:     dr.Severity,
+			Weight:       dr.Weight,
+			Reference:    dr.Reference,
+			ImplGuide:    dr.ImplGuide,
+			Check:        dr.check,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check runs the declarative rule against the input's capability statement
+func (dr declarativeRule) check(in *Input) []endpointmanager.Rule {
+	fieldName := strings.Join(dr.Path, ".")
+	ruleError := endpointmanager.Rule{
+		RuleName:  endpointmanager.RuleOption(dr.Name),
+		Valid:     false,
+		Expected:  "true",
+		Actual:    "false",
+		Comment:   dr.Comment,
+		Reference: dr.Reference,
+		ImplGuide: dr.ImplGuide,
+	}
+	if dr.Value != "" {
+		ruleError.Expected = dr.Value
+		ruleError.Actual = ""
+	}
+
+	if in.CapStat == nil {
+		ruleError.Comment = fmt.Sprintf("The Capability Statement does not exist; cannot check %s. %s", fieldName, dr.Comment)
+		return []endpointmanager.Rule{ruleError}
+	}
+
+	capJSON, err := in.CapStat.GetJSON()
+	if err != nil {
+		ruleError.Comment = fmt.Sprintf("The Capability Statement is not formatted correctly; cannot check %s. %s", fieldName, dr.Comment)
+		return []endpointmanager.Rule{ruleError}
+	}
+	var capInt map[string]interface{}
+	err = json.Unmarshal(capJSON, &capInt)
+	if err != nil {
+		ruleError.Comment = fmt.Sprintf("The Capability Statement is not formatted correctly; cannot check %s. %s", fieldName, dr.Comment)
+		return []endpointmanager.Rule{ruleError}
+	}
+
+	values := valuesAtPath(capInt, dr.Path)
+
+	if dr.Value == "" {
+		if len(values) > 0 {
+			ruleError.Valid = true
+			ruleError.Actual = "true"
+		}
+		return []endpointmanager.Rule{ruleError}
+	}
+
+	var actualVals []string
+	for _, value := range values {
+		valueStr, ok := value.(string)
+		if !ok {
+			valueStr = fmt.Sprintf("%v", value)
+		}
+		if valueStr == dr.Value {
+			ruleError.Valid = true
+		}
+		actualVals = append(actualVals, valueStr)
+	}
+	ruleError.Actual = strings.Join(actualVals, ",")
+	return []endpointmanager.Rule{ruleError}
+}
+
+// valuesAtPath returns every non-nil value found at the given path. Arrays found along the path
+// are expanded so that each of their elements is followed.
+func valuesAtPath(value interface{}, path []string) []interface{} {
+	if value == nil {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		var values []interface{}
+		for _, elem := range list {
+			values = append(values, valuesAtPath(elem, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	valueMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return valuesAtPath(valueMap[path[0]], path[1:])
+}
@@ -0,0 +1,333 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+// maxIssues limits how many structural issues are stored for a single capability statement
+var maxIssues = 100
+
+// regular expressions for the primitive data types from http://hl7.org/fhir/datatypes.html
+var codeRegex = regexp.MustCompile(`^[^\s]+( [^\s]+)*$`)
+var idRegex = regexp.MustCompile(`^[A-Za-z0-9\-\.]{1,64}$`)
+var dateTimeRegex = regexp.MustCompile(`^([0-9]([0-9]([0-9][1-9]|[1-9]0)|[1-9]00)|[1-9]000)(-(0[1-9]|1[0-2])(-(0[1-9]|[1-2][0-9]|3[0-1])(T([01][0-9]|2[0-3]):[0-5][0-9]:([0-5][0-9]|60)(\.[0-9]+)?(Z|(\+|-)((0[0-9]|1[0-3]):[0-5][0-9]|14:00)))?)?)?$`)
+
+var stringTypes = []string{"string", "code", "id", "uri", "url", "canonical", "markdown", "dateTime", "date",
+	"instant", "time", "base64Binary", "oid", "uuid"}
+var numberTypes = []string{"integer", "unsignedInt", "positiveInt", "decimal"}
+
+// capStatTypes are the resource types of capability statements across FHIR versions
+var capStatTypes = []string{"CapabilityStatement", "Conformance"}
+
+type elementType struct {
+	Code string `json:"code"`
+}
+
+// elementDefinition is the part of a StructureDefinition snapshot element used for structural validation
+type elementDefinition struct {
+	Path             string        `json:"path"`
+	Min              int           `json:"min"`
+	Max              string        `json:"max"`
+	Type             []elementType `json:"type"`
+	ContentReference string        `json:"contentReference"`
+}
+
+// structureDefinition is a FHIR StructureDefinition along with an index of each element's children
+type structureDefinition struct {
+	ResourceType string `json:"resourceType"`
+	FHIRVersion  string `json:"fhirVersion"`
+	Type         string `json:"type"`
+	Snapshot     struct {
+		Element []elementDefinition `json:"element"`
+	} `json:"snapshot"`
+	children map[string][]elementDefinition
+}
+
+// structureDefinitions holds the capability statement StructureDefinition for each FHIR version family
+var structureDefinitions = make(map[string]*structureDefinition)
+
+// LoadStructureDefinitionDir loads the capability statement StructureDefinitions from the JSON files in the
+// given directory. Each file can hold a single StructureDefinition or a Bundle of them, such as the
+// profiles-resources.json file from the FHIR specification. Definitions for other resources are ignored.
+func LoadStructureDefinitionDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read StructureDefinition directory %s", dir)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "unable to read StructureDefinition file %s", file.Name())
+		}
+		err = LoadStructureDefinitions(contents)
+		if err != nil {
+			return errors.Wrapf(err, "unable to load StructureDefinition file %s", file.Name())
+		}
+	}
+
+	return nil
+}
+
+// LoadStructureDefinitions loads the capability statement StructureDefinitions from the given JSON, which is
+// either a StructureDefinition or a Bundle of them
+func LoadStructureDefinitions(contents []byte) error {
+	var resource struct {
+		ResourceType string `json:"resourceType"`
+		Entry        []struct {
+			Resource json.RawMessage `json:"resource"`
+		} `json:"entry"`
+	}
+	err := json.Unmarshal(contents, &resource)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse StructureDefinition")
+	}
+
+	if resource.ResourceType == "Bundle" {
+		for _, entry := range resource.Entry {
+			err = loadStructureDefinition(entry.Resource)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return loadStructureDefinition(contents)
+}
+
+// loadStructureDefinition indexes the given StructureDefinition and stores it for its FHIR version family
+// if it defines a capability statement
+func loadStructureDefinition(contents []byte) error {
+	var sd structureDefinition
+	err := json.Unmarshal(contents, &sd)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse StructureDefinition")
+	}
+	if sd.ResourceType != "StructureDefinition" || !helpers.StringArrayContains(capStatTypes, sd.Type) {
+		return nil
+	}
+
+	family := familyForVersion(sd.FHIRVersion)
+	if family == UnknownFamily {
+		return fmt.Errorf("StructureDefinition for %s has unknown FHIR version %s", sd.Type, sd.FHIRVersion)
+	}
+	if len(sd.Snapshot.Element) == 0 {
+		return fmt.Errorf("StructureDefinition for %s version %s has no snapshot elements", sd.Type, sd.FHIRVersion)
+	}
+
+	sd.children = make(map[string][]elementDefinition)
+	for _, element := range sd.Snapshot.Element {
+		lastDot := strings.LastIndex(element.Path, ".")
+		if lastDot == -1 {
+			continue
+		}
+		parent := element.Path[:lastDot]
+		sd.children[parent] = append(sd.children[parent], element)
+	}
+
+	structureDefinitions[family] = &sd
+	return nil
+}
+
+// familyForVersion returns the FHIR version family of the given FHIR version
+func familyForVersion(fhirVersion string) string {
+	if helpers.StringArrayContains(dstu2, fhirVersion) {
+		return DSTU2Family
+	} else if helpers.StringArrayContains(stu3, fhirVersion) {
+		return STU3Family
+	} else if helpers.StringArrayContains(r4, fhirVersion) {
+		return R4Family
+	}
+	return UnknownFamily
+}
+
+// validateStructure checks the capability statement against the StructureDefinition loaded for the given
+// family for cardinality, data type and required element errors. If no StructureDefinition is loaded for
+// the family or the capability statement does not exist, no issues are returned.
+func validateStructure(family string, capStat capabilityparser.CapabilityStatement) []endpointmanager.ValidationIssue {
+	sd, ok := structureDefinitions[family]
+	if !ok || capStat == nil {
+		return nil
+	}
+
+	capInt, err := capStatMap(capStat)
+	if err != nil {
+		return []endpointmanager.ValidationIssue{
+			newIssue("structure", sd.Type, "The Capability Statement is not valid JSON"),
+		}
+	}
+
+	var issues []endpointmanager.ValidationIssue
+	if capInt["resourceType"] != sd.Type {
+		issues = append(issues, newIssue("structure", sd.Type,
+			fmt.Sprintf("resourceType must be %s, but found %v", sd.Type, capInt["resourceType"])))
+	}
+	issues = append(issues, sd.validateObject(capInt, sd.Type, sd.Type)...)
+
+	if len(issues) > maxIssues {
+		issues = issues[:maxIssues]
+	}
+	return issues
+}
+
+// validateObject checks each child element of the object defined at defPath. expression is the path of the
+// object in the capability statement, which includes array indices.
+func (sd *structureDefinition) validateObject(obj map[string]interface{}, defPath string, expression string) []endpointmanager.ValidationIssue {
+	var issues []endpointmanager.ValidationIssue
+
+	knownElements := make(map[string]bool)
+	for _, child := range sd.children[defPath] {
+		name := child.Path[strings.LastIndex(child.Path, ".")+1:]
+		knownElements[name] = true
+		childExpression := expression + "." + name
+
+		value, ok := obj[name]
+		if !ok || value == nil {
+			if child.Min > 0 {
+				issues = append(issues, newIssue("required", childExpression,
+					fmt.Sprintf("minimum required = %d, but only found 0", child.Min)))
+			}
+			continue
+		}
+
+		var items []interface{}
+		valueList, isList := value.([]interface{})
+		if isList {
+			if child.Max == "1" {
+				issues = append(issues, newIssue("structure", childExpression, "expected a single value, but found an array"))
+			}
+			items = valueList
+		} else {
+			if child.Max != "1" {
+				issues = append(issues, newIssue("structure", childExpression, "expected an array, but found a single value"))
+			}
+			items = []interface{}{value}
+		}
+		if len(items) < child.Min {
+			issues = append(issues, newIssue("required", childExpression,
+				fmt.Sprintf("minimum required = %d, but only found %d", child.Min, len(items))))
+		}
+
+		for i, item := range items {
+			itemExpression := childExpression
+			if isList {
+				itemExpression = fmt.Sprintf("%s[%d]", childExpression, i)
+			}
+			issues = append(issues, sd.validateValue(child, item, itemExpression)...)
+		}
+	}
+
+	var unknownElements []string
+	for key := range obj {
+		if knownElements[strings.TrimPrefix(key, "_")] || (defPath == sd.Type && key == "resourceType") {
+			continue
+		}
+		unknownElements = append(unknownElements, key)
+	}
+	sort.Strings(unknownElements)
+	for _, key := range unknownElements {
+		issues = append(issues, newIssue("structure", expression+"."+key, "unrecognized element"))
+	}
+
+	return issues
+}
+
+// validateValue checks that the value matches the data type of its element definition, and validates the
+// children of backbone elements
+func (sd *structureDefinition) validateValue(element elementDefinition, value interface{}, expression string) []endpointmanager.ValidationIssue {
+	typeCode := ""
+	if len(element.Type) > 0 {
+		typeCode = element.Type[0].Code
+	}
+
+	if element.ContentReference != "" || typeCode == "BackboneElement" || typeCode == "Element" {
+		defPath := element.Path
+		if element.ContentReference != "" {
+			defPath = strings.TrimPrefix(element.ContentReference, "#")
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []endpointmanager.ValidationIssue{newIssue("structure", expression, "expected an object")}
+		}
+		return sd.validateObject(obj, defPath, expression)
+	}
+
+	if typeCode == "boolean" {
+		if _, ok := value.(bool); !ok {
+			return []endpointmanager.ValidationIssue{newIssue("structure", expression, "expected a boolean")}
+		}
+		return nil
+	}
+
+	if helpers.StringArrayContains(numberTypes, typeCode) {
+		number, ok := value.(float64)
+		if !ok {
+			return []endpointmanager.ValidationIssue{newIssue("structure", expression, fmt.Sprintf("expected a number for %s", typeCode))}
+		}
+		if typeCode != "decimal" && number != math.Trunc(number) {
+			return []endpointmanager.ValidationIssue{newIssue("value", expression, fmt.Sprintf("%v is not a valid %s", number, typeCode))}
+		}
+		if (typeCode == "unsignedInt" && number < 0) || (typeCode == "positiveInt" && number < 1) {
+			return []endpointmanager.ValidationIssue{newIssue("value", expression, fmt.Sprintf("%v is not a valid %s", number, typeCode))}
+		}
+		return nil
+	}
+
+	if helpers.StringArrayContains(stringTypes, typeCode) {
+		str, ok := value.(string)
+		if !ok {
+			return []endpointmanager.ValidationIssue{newIssue("structure", expression, fmt.Sprintf("expected a string for %s", typeCode))}
+		}
+		if !primitiveStringValid(typeCode, str) {
+			return []endpointmanager.ValidationIssue{newIssue("value", expression, fmt.Sprintf("\"%s\" is not a valid %s", str, typeCode))}
+		}
+		return nil
+	}
+
+	// complex data types and resources are only checked to be objects
+	if _, ok := value.(map[string]interface{}); !ok {
+		return []endpointmanager.ValidationIssue{newIssue("structure", expression, fmt.Sprintf("expected an object for %s", typeCode))}
+	}
+	return nil
+}
+
+// primitiveStringValid checks the format of the string based primitive data types
+func primitiveStringValid(typeCode string, str string) bool {
+	switch typeCode {
+	case "code":
+		return codeRegex.MatchString(str)
+	case "id":
+		return idRegex.MatchString(str)
+	case "dateTime":
+		return dateTimeRegex.MatchString(str)
+	case "uri", "url", "canonical":
+		return len(str) > 0 && !strings.ContainsAny(str, " \t\n")
+	}
+	return len(strings.TrimSpace(str)) > 0
+}
+
+// newIssue creates an error issue, truncating the fields so they fit in the validation_issues table
+func newIssue(code string, expression string, diagnostics string) endpointmanager.ValidationIssue {
+	return endpointmanager.ValidationIssue{
+		Severity:    "error",
+		Code:        code,
+		Diagnostics: limitLength(expression + ": " + diagnostics),
+		Expression:  limitLength(expression),
+	}
+}
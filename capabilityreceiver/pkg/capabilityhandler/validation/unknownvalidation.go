@@ -1,11 +0,0 @@
-package validation
-
-type unknownValidation struct {
-	baseVal
-}
-
-func newUnknownVal() *unknownValidation {
-	return &unknownValidation{
-		baseVal: baseVal{},
-	}
-}
@@ -0,0 +1,407 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/pkg/errors"
+)
+
+var usCoreIGURL = "hl7.org/fhir/us/core"
+var searchCombinationURL = "http://hl7.org/fhir/StructureDefinition/capabilitystatement-search-parameter-combination"
+
+// maxFieldLength is the size of the text columns in the validations table
+var maxFieldLength = 500
+
+// usCoreResource is the set of SHALL requirements the US Core Server CapabilityStatement places on a resource
+type usCoreResource struct {
+	Type               string     `json:"type"`
+	Profiles           []string   `json:"profiles"`
+	SearchParams       []string   `json:"searchParams"`
+	SearchCombinations [][]string `json:"searchCombinations"`
+	Interactions       []string   `json:"interactions"`
+}
+
+// usCoreVersion is the list of resource requirements for a single version of US Core
+type usCoreVersion struct {
+	Version   string           `json:"version"`
+	Reference string           `json:"reference"`
+	Resources []usCoreResource `json:"resources"`
+}
+
+// usCoreRequirements holds the requirements for each supported US Core version, ordered from oldest to newest
+type usCoreRequirements struct {
+	Versions []usCoreVersion `json:"versions"`
+}
+
+// LoadUSCoreRequirementsFile reads the US Core requirements in the given JSON file and registers the
+// per resource US Core rules
+func LoadUSCoreRequirementsFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read US Core requirements file %s", path)
+	}
+
+	return LoadUSCoreRequirements(contents)
+}
+
+// LoadUSCoreRequirements parses the given US Core requirements and registers the per resource US Core rules
+// for R4 endpoints. The US Core version an endpoint is checked against is the latest version it claims to
+// implement, or the oldest listed version if it does not claim one.
+func LoadUSCoreRequirements(contents []byte) error {
+	var reqs usCoreRequirements
+	err := json.Unmarshal(contents, &reqs)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse US Core requirements")
+	}
+	if len(reqs.Versions) == 0 {
+		return fmt.Errorf("US Core requirements must include at least one version")
+	}
+
+	rules := []RuleDefinition{
+		{
+			Name:         endpointmanager.USCoreProfileRule,
+			FHIRVersions: []string{R4Family},
+			Severity:     endpointmanager.SeverityError,
+			Check:        reqs.profileCheck,
+		},
+		{
+			Name:         endpointmanager.USCoreSearchRule,
+			FHIRVersions: []string{R4Family},
+			Severity:     endpointmanager.SeverityError,
+			Check:        reqs.searchCheck,
+		},
+		{
+			Name:         endpointmanager.USCoreInteractRule,
+			FHIRVersions: []string{R4Family},
+			Severity:     endpointmanager.SeverityError,
+			Check:        reqs.interactionCheck,
+		},
+	}
+	for _, rule := range rules {
+		err = RegisterRule(rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// profileCheck checks that every US Core profile required for each resource is listed in the resource's
+// supportedProfile field
+func (reqs usCoreRequirements) profileCheck(in *Input) []endpointmanager.Rule {
+	return reqs.runResourceCheck(in.CapStat, endpointmanager.USCoreProfileRule,
+		"The US Core Server SHALL support the US Core profiles for the %s resource.",
+		func(req usCoreResource, resource map[string]interface{}) ([]string, []string) {
+			supported := supportedProfiles(resource)
+			var found []string
+			var missing []string
+			for _, profile := range req.Profiles {
+				name := profileName(profile)
+				if helpers.StringArrayContains(supported, profile) {
+					found = append(found, name)
+				} else {
+					missing = append(missing, name)
+				}
+			}
+			return found, missing
+		},
+		func(req usCoreResource) []string {
+			var names []string
+			for _, profile := range req.Profiles {
+				names = append(names, profileName(profile))
+			}
+			return names
+		})
+}
+
+// searchCheck checks that the SHALL search parameters and search parameter combinations for each resource
+// are declared
+func (reqs usCoreRequirements) searchCheck(in *Input) []endpointmanager.Rule {
+	return reqs.runResourceCheck(in.CapStat, endpointmanager.USCoreSearchRule,
+		"The US Core Server SHALL support the required search parameters and search parameter combinations for the %s resource.",
+		func(req usCoreResource, resource map[string]interface{}) ([]string, []string) {
+			params := searchParams(resource)
+			combinations := searchCombinations(resource)
+			var found []string
+			var missing []string
+			for _, param := range req.SearchParams {
+				if helpers.StringArrayContains(params, param) {
+					found = append(found, param)
+				} else {
+					missing = append(missing, param)
+				}
+			}
+			for _, combination := range req.SearchCombinations {
+				combinationName := strings.Join(combination, "+")
+				if helpers.StringArrayContains(combinations, combinationKey(combination)) {
+					found = append(found, combinationName)
+				} else {
+					missing = append(missing, combinationName)
+				}
+			}
+			return found, missing
+		},
+		func(req usCoreResource) []string {
+			expected := append([]string{}, req.SearchParams...)
+			for _, combination := range req.SearchCombinations {
+				expected = append(expected, strings.Join(combination, "+"))
+			}
+			return expected
+		})
+}
+
+// interactionCheck checks that the required interactions for each resource are declared
+func (reqs usCoreRequirements) interactionCheck(in *Input) []endpointmanager.Rule {
+	return reqs.runResourceCheck(in.CapStat, endpointmanager.USCoreInteractRule,
+		"The US Core Server SHALL support the required interactions for the %s resource.",
+		func(req usCoreResource, resource map[string]interface{}) ([]string, []string) {
+			interactions := interactionCodes(resource)
+			var found []string
+			var missing []string
+			for _, interaction := range req.Interactions {
+				if helpers.StringArrayContains(interactions, interaction) {
+					found = append(found, interaction)
+				} else {
+					missing = append(missing, interaction)
+				}
+			}
+			return found, missing
+		},
+		func(req usCoreResource) []string {
+			return req.Interactions
+		})
+}
+
+// runResourceCheck returns one rule result for each resource in the US Core version the capability statement
+// is checked against. compare returns the requirements that were found and the ones that are missing
+// for a resource, and expected returns all of the requirements for a resource.
+func (reqs usCoreRequirements) runResourceCheck(capStat capabilityparser.CapabilityStatement,
+	ruleName endpointmanager.RuleOption,
+	baseComment string,
+	compare func(usCoreResource, map[string]interface{}) ([]string, []string),
+	expected func(usCoreResource) []string) []endpointmanager.Rule {
+
+	if capStat == nil {
+		return []endpointmanager.Rule{{
+			RuleName:  ruleName,
+			Valid:     false,
+			Expected:  "true",
+			Actual:    "false",
+			Comment:   "The Capability Statement does not exist; cannot check US Core resource requirements.",
+			Reference: reqs.Versions[0].Reference,
+			ImplGuide: "USCore " + reqs.Versions[0].Version,
+		}}
+	}
+
+	version := reqs.versionFor(capStat)
+	resources := capStatResources(capStat)
+
+	var rules []endpointmanager.Rule
+	for _, req := range version.Resources {
+		rule := endpointmanager.Rule{
+			RuleName:  ruleName,
+			Valid:     false,
+			Expected:  limitLength(strings.Join(expected(req), ",")),
+			Reference: version.Reference,
+			ImplGuide: "USCore " + version.Version,
+			Resource:  req.Type,
+		}
+		comment := fmt.Sprintf(baseComment, req.Type)
+
+		resource, ok := resources[req.Type]
+		if !ok {
+			rule.Comment = limitLength(fmt.Sprintf("The %s resource is not included in the Capability Statement. %s", req.Type, comment))
+			rules = append(rules, rule)
+			continue
+		}
+
+		found, missing := compare(req, resource)
+		rule.Actual = limitLength(strings.Join(found, ","))
+		if len(missing) == 0 {
+			rule.Valid = true
+			rule.Comment = comment
+		} else {
+			rule.Comment = limitLength(fmt.Sprintf("Missing: %s. %s", strings.Join(missing, ", "), comment))
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// versionFor returns the latest US Core version the capability statement claims to implement in its
+// implementationGuide or instantiates fields. If it does not claim a known version, the oldest version is used.
+func (reqs usCoreRequirements) versionFor(capStat capabilityparser.CapabilityStatement) usCoreVersion {
+	chosen := reqs.Versions[0]
+
+	capInt, err := capStatMap(capStat)
+	if err != nil {
+		return chosen
+	}
+
+	var claimed []string
+	for _, field := range []string{"implementationGuide", "instantiates"} {
+		for _, value := range valuesAtPath(capInt, []string{field}) {
+			canonical, ok := value.(string)
+			if !ok || !strings.Contains(canonical, usCoreIGURL) {
+				continue
+			}
+			splitCanonical := strings.Split(canonical, "|")
+			if len(splitCanonical) == 2 {
+				claimed = append(claimed, splitCanonical[1])
+			}
+		}
+	}
+
+	for _, version := range reqs.Versions {
+		for _, claimedVersion := range claimed {
+			if majorMinor(claimedVersion) == majorMinor(version.Version) {
+				chosen = version
+			}
+		}
+	}
+
+	return chosen
+}
+
+// capStatResources returns the resources of the server mode rest elements of the capability statement keyed by
+// their type. If a resource is listed more than once, the first one is used.
+func capStatResources(capStat capabilityparser.CapabilityStatement) map[string]map[string]interface{} {
+	resources := make(map[string]map[string]interface{})
+
+	resourceList, err := capStat.GetResourceListByMode(capabilityparser.RestModeServer)
+	if err != nil {
+		return resources
+	}
+	for _, resource := range resourceList {
+		typeStr, ok := resource["type"].(string)
+		if !ok {
+			continue
+		}
+		if _, exists := resources[typeStr]; !exists {
+			resources[typeStr] = resource
+		}
+	}
+
+	return resources
+}
+
+// capStatMap returns the capability statement as a generic map
+func capStatMap(capStat capabilityparser.CapabilityStatement) (map[string]interface{}, error) {
+	var capInt map[string]interface{}
+
+	capJSON, err := capStat.GetJSON()
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(capJSON, &capInt)
+	return capInt, err
+}
+
+// supportedProfiles returns the resource's base profile and supported profiles without version suffixes
+func supportedProfiles(resource map[string]interface{}) []string {
+	var profiles []string
+	for _, value := range valuesAtPath(resource, []string{"supportedProfile"}) {
+		if profile, ok := value.(string); ok {
+			profiles = append(profiles, strings.Split(profile, "|")[0])
+		}
+	}
+	for _, value := range valuesAtPath(resource, []string{"profile"}) {
+		if profile, ok := value.(string); ok {
+			profiles = append(profiles, strings.Split(profile, "|")[0])
+		}
+	}
+	return profiles
+}
+
+// searchParams returns the names of the search parameters declared for the resource
+func searchParams(resource map[string]interface{}) []string {
+	var params []string
+	for _, value := range valuesAtPath(resource, []string{"searchParam", "name"}) {
+		if param, ok := value.(string); ok {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// searchCombinations returns the required search parameter combinations declared for the resource using
+// the search-parameter-combination extension
+func searchCombinations(resource map[string]interface{}) []string {
+	var combinations []string
+
+	extensions, ok := resource["extension"].([]interface{})
+	if !ok {
+		return combinations
+	}
+	for _, extension := range extensions {
+		extMap, ok := extension.(map[string]interface{})
+		if !ok || extMap["url"] != searchCombinationURL {
+			continue
+		}
+		var combination []string
+		for _, inner := range valuesAtPath(extMap, []string{"extension"}) {
+			innerMap, ok := inner.(map[string]interface{})
+			if !ok || innerMap["url"] != "required" {
+				continue
+			}
+			if param, ok := innerMap["valueString"].(string); ok {
+				combination = append(combination, param)
+			}
+		}
+		if len(combination) > 0 {
+			combinations = append(combinations, combinationKey(combination))
+		}
+	}
+
+	return combinations
+}
+
+// interactionCodes returns the codes of the interactions declared for the resource
+func interactionCodes(resource map[string]interface{}) []string {
+	var codes []string
+	for _, value := range valuesAtPath(resource, []string{"interaction", "code"}) {
+		if code, ok := value.(string); ok {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// combinationKey returns an order independent key for a search parameter combination
+func combinationKey(combination []string) string {
+	sorted := append([]string{}, combination...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "+")
+}
+
+// profileName returns the last segment of a profile URL
+func profileName(profile string) string {
+	splitProfile := strings.Split(profile, "/")
+	return splitProfile[len(splitProfile)-1]
+}
+
+// majorMinor returns the major and minor parts of a version string
+func majorMinor(version string) string {
+	splitVersion := strings.Split(version, ".")
+	if len(splitVersion) < 2 {
+		return version
+	}
+	return splitVersion[0] + "." + splitVersion[1]
+}
+
+// limitLength truncates the given string so it fits in the validations table
+func limitLength(str string) string {
+	if len(str) <= maxFieldLength {
+		return str
+	}
+	return str[:maxFieldLength-3] + "..."
+}
@@ -36,6 +36,8 @@ func Test_RunValidation(t *testing.T) {
 		Actual:    "true",
 		Comment:   "The Conformance Resource exists. Servers SHALL provide a Conformance Resource that specifies which interactions and resources are supported.",
 		Reference: "http://hl7.org/fhir/http.html",
+		Severity:  endpointmanager.SeverityError,
+		Weight:    3,
 	}
 
 	expectedLastVal := endpointmanager.Rule{
@@ -46,18 +48,34 @@ func Test_RunValidation(t *testing.T) {
 		Actual:    "true",
 		Reference: "http://hl7.org/fhir/DSTU2/conformance.html",
 		ImplGuide: "USCore 3.1",
+		Severity:  endpointmanager.SeverityError,
+		Weight:    3,
 	}
 
 	requestedFhirVersion := "None"
 	defaultFhirVersion := "1.0.2"
 
-	actualVal := validator.RunValidation(cs, "1.0.2", "TLS 1.2", sr, requestedFhirVersion, defaultFhirVersion)
+	actualVal := validator.RunValidation(cs, "1.0.2", "TLS 1.2", sr, requestedFhirVersion, defaultFhirVersion, nil)
 	th.Assert(t, len(actualVal.Results) == 7, fmt.Sprintf("RunValidation should have returned 7 validation checks, instead it returned %d", len(actualVal.Results)))
 	eq := reflect.DeepEqual(actualVal.Results[0], expectedFirstVal)
 	th.Assert(t, eq == true, fmt.Sprintf("RunValidation's first returned validation is not correct, is instead %+v", actualVal.Results[0]))
 	eq = reflect.DeepEqual(actualVal.Results[6], expectedLastVal)
 	th.Assert(t, eq == true, "RunValidation's last returned validation is not correct")
 
+	// the base validators keep their own order of results, which differs from the r4 validator's
+	expectedOrder := []endpointmanager.RuleOption{
+		endpointmanager.CapStatExistRule,
+		endpointmanager.KindRule,
+		endpointmanager.DescribeEndptRule,
+		endpointmanager.DocumentValidRule,
+		endpointmanager.EndptFunctionRule,
+		endpointmanager.MessagingEndptRule,
+		endpointmanager.UniqueResourcesRule,
+	}
+	for i, ruleName := range expectedOrder {
+		th.Assert(t, actualVal.Results[i].RuleName == ruleName, fmt.Sprintf("Expected result %d to be %s, got %s", i, ruleName, actualVal.Results[i].RuleName))
+	}
+
 	// r4 test
 
 	cs2, err := getR4CapStat()
@@ -76,6 +94,8 @@ func Test_RunValidation(t *testing.T) {
 		Comment:   "Systems SHALL use TLS version 1.2 or higher for all transmissions not taking place over a secure network connection.",
 		Reference: "https://www.hl7.org/fhir/us/core/security.html",
 		ImplGuide: "USCore 3.1",
+		Severity:  endpointmanager.SeverityError,
+		Weight:    3,
 	}
 	expectedLastVal = endpointmanager.Rule{
 		RuleName:  endpointmanager.SearchParamsRule,
@@ -85,23 +105,35 @@ func Test_RunValidation(t *testing.T) {
 		Comment:   "Search parameter names must be unique in the context of a resource.",
 		ImplGuide: "USCore 3.1",
 		Reference: "http://hl7.org/fhir/capabilitystatement.html",
+		Severity:  endpointmanager.SeverityError,
+		Weight:    3,
 	}
 
-	actualVal = validator2.RunValidation(cs2, "4.0.1", "TLS 1.2", sr, requestedFhirVersion, defaultFhirVersion)
+	actualVal = validator2.RunValidation(cs2, "4.0.1", "TLS 1.2", sr, requestedFhirVersion, defaultFhirVersion, nil)
 	th.Assert(t, len(actualVal.Results) == 14, fmt.Sprintf("RunValidation should have returned 15 validation checks, instead it returned %d", len(actualVal.Results)))
 	eq = reflect.DeepEqual(actualVal.Results[2], expectedFourthVal)
 	th.Assert(t, eq == true, "RunValidation's fourth returned validation is not correct")
 	eq = reflect.DeepEqual(actualVal.Results[13], expectedLastVal)
 	th.Assert(t, eq == true, "RunValidation's last returned validation is not correct")
+
+	expectedOrder = []endpointmanager.RuleOption{
+		endpointmanager.KindRule,
+		endpointmanager.InstanceRule,
+		endpointmanager.MessagingEndptRule,
+		endpointmanager.EndptFunctionRule,
+		endpointmanager.DescribeEndptRule,
+		endpointmanager.DocumentValidRule,
+		endpointmanager.UniqueResourcesRule,
+	}
+	for i, ruleName := range expectedOrder {
+		th.Assert(t, actualVal.Results[i+6].RuleName == ruleName, fmt.Sprintf("Expected result %d to be %s, got %s", i+6, ruleName, actualVal.Results[i+6].RuleName))
+	}
 }
 
 func Test_CapStatExists(t *testing.T) {
 	cs, err := getDSTU2CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, dstu2)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedCap := endpointmanager.Rule{
@@ -113,7 +145,7 @@ func Test_CapStatExists(t *testing.T) {
 		Comment:   "The Conformance Resource exists. Servers SHALL provide a Conformance Resource that specifies which interactions and resources are supported.",
 	}
 
-	actualCap := validator.CapStatExists(cs)
+	actualCap := capStatExists(cs)
 	eq := reflect.DeepEqual(actualCap, expectedCap)
 	th.Assert(t, eq == true, fmt.Sprintf("DSTU2 Capability Statement should exist, returned value is instead %+v", actualCap))
 
@@ -128,7 +160,7 @@ func Test_CapStatExists(t *testing.T) {
 		Comment:   "The Conformance Resource does not exist. Servers SHALL provide a Conformance Resource that specifies which interactions and resources are supported.",
 	}
 
-	actualCap = validator.CapStatExists(nil)
+	actualCap = capStatExists(nil)
 	eq = reflect.DeepEqual(actualCap, expectedCap2)
 	th.Assert(t, eq == true, fmt.Sprintf("Capability Statement should not exist, returned value is instead %+v", actualCap))
 
@@ -137,13 +169,10 @@ func Test_CapStatExists(t *testing.T) {
 	cs2, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedCap.Comment = "The Capability Statement exists. Servers SHALL provide a Capability Statement that specifies which interactions and resources are supported."
 	expectedCap.Reference = "http://hl7.org/fhir/http.html"
 	expectedCap.ImplGuide = "USCore 3.1"
-	actualCap = validator2.CapStatExists(cs2)
+	actualCap = r4CapStatExists(cs2)
 	eq = reflect.DeepEqual(actualCap, expectedCap)
 	th.Assert(t, eq == true, fmt.Sprintf("R4 Capability Statement should exist, returned value is instead %+v", actualCap))
 }
@@ -156,9 +185,6 @@ func Test_checkResourceList(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// capability statement does not exist
 
 	expectedVal := endpointmanager.Rule{
@@ -170,7 +196,7 @@ func Test_checkResourceList(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 		Reference: "https://www.hl7.org/fhir/us/core/CapabilityStatement-us-core-server.html",
 	}
-	actualVal := validator.PatientResourceExists(nil)
+	actualVal := r4PatientResourceExists(nil)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, "PatientResourceExists check should be invalid because capability statement does not exist.")
 
@@ -179,11 +205,8 @@ func Test_checkResourceList(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "type"}, []int{0, 0}, 2, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "The Resource Profiles are not properly formatted. The US Core Server SHALL support the US Core Patient resource profile."
-	actualVal = validator2.PatientResourceExists(cs2)
+	actualVal = r4PatientResourceExists(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because type is malformed, is instead %+v", actualVal))
 
@@ -192,11 +215,8 @@ func Test_checkResourceList(t *testing.T) {
 	cs3, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "type"}, []int{0, 0}, 2, deleteField, "")
 	th.Assert(t, err == nil, err)
 
-	validator3, err := getValidator(cs3, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "The Resource Profiles are not properly formatted. The US Core Server SHALL support the US Core Patient resource profile."
-	actualVal = validator3.PatientResourceExists(cs3)
+	actualVal = r4PatientResourceExists(cs3)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because type does not exist, is instead %+v", actualVal))
 
@@ -205,11 +225,8 @@ func Test_checkResourceList(t *testing.T) {
 	cs4, err := nLevelNestedValueChange(cs, []string{"rest", "resource"}, []int{0}, 1, deleteField, "")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "The Resource Profiles do not exist. The US Core Server SHALL support the US Core Patient resource profile."
-	actualVal = validator4.PatientResourceExists(cs4)
+	actualVal = r4PatientResourceExists(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because resources do not exist, is instead %+v", actualVal))
 
@@ -218,22 +235,36 @@ func Test_checkResourceList(t *testing.T) {
 	cs5, err := deleteFieldFromCapStat(cs, "rest")
 	th.Assert(t, err == nil, err)
 
-	validator5, err := getValidator(cs5, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "Rest field does not exist. The US Core Server SHALL support the US Core Patient resource profile."
-	actualVal = validator5.PatientResourceExists(cs5)
+	actualVal = r4PatientResourceExists(cs5)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because the rest field does not exist, is instead %+v", actualVal))
+
+	// only client mode rest entries exist
+
+	cs6, err := nLevelNestedValueChange(cs, []string{"rest", "mode"}, []int{0}, 1, updateString, "client")
+	th.Assert(t, err == nil, err)
+
+	expectedVal.Comment = "The Resource Profiles do not exist. The US Core Server SHALL support the US Core Patient resource profile."
+	actualVal = r4PatientResourceExists(cs6)
+	eq = reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because there are no server resources, is instead %+v", actualVal))
+
+	// rest mode is not formatted properly
+
+	cs7, err := nLevelNestedValueChange(cs, []string{"rest", "mode"}, []int{0}, 1, badFormat, "")
+	th.Assert(t, err == nil, err)
+
+	expectedVal.Comment = "The Rest field is not properly formatted. The US Core Server SHALL support the US Core Patient resource profile."
+	actualVal = r4PatientResourceExists(cs7)
+	eq = reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("PatientResourceExists check should be invalid because the rest mode is malformed, is instead %+v", actualVal))
 }
 
 func Test_PatientResourceExists(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -245,7 +276,7 @@ func Test_PatientResourceExists(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 		Reference: "https://www.hl7.org/fhir/us/core/CapabilityStatement-us-core-server.html",
 	}
-	actualVal := validator.PatientResourceExists(cs)
+	actualVal := r4PatientResourceExists(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The Patient Resource exists and validation should be valid, is instead %+v", actualVal))
 
@@ -254,12 +285,9 @@ func Test_PatientResourceExists(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "type"}, []int{0, 0}, 2, updateString, "unknown")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
-	actualVal = validator2.PatientResourceExists(cs2)
+	actualVal = r4PatientResourceExists(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The Patient Resource does not exist and validation should be invalid, is instead %+v", actualVal))
 }
@@ -267,9 +295,6 @@ func Test_OtherResourceExists(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -281,7 +306,7 @@ func Test_OtherResourceExists(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 		Reference: "https://www.hl7.org/fhir/us/core/CapabilityStatement-us-core-server.html",
 	}
-	actualVal := validator.OtherResourceExists(cs)
+	actualVal := r4OtherResourceExists(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Another resource exists and the check should be valid, is instead %+v", actualVal))
 
@@ -290,26 +315,17 @@ func Test_OtherResourceExists(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "type"}, []int{0, 1}, 2, updateString, "unknown")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
-	actualVal = validator2.OtherResourceExists(cs2)
+	actualVal = r4OtherResourceExists(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Another resource does not exist and the check should be invalid, is instead %+v", actualVal))
 }
 
 func Test_SmartResponseExists(t *testing.T) {
-	cs, err := getR4CapStat()
-	th.Assert(t, err == nil, err)
-
 	sr, err := getSmartResponse()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -322,7 +338,7 @@ func Test_SmartResponseExists(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 	}
 
-	actualVal := validator.SmartResponseExists(sr)
+	actualVal := r4SmartResponseExists(sr)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SMART-on-FHIR response exists so it should be valid, is instead %+v", actualVal))
 
@@ -332,7 +348,7 @@ func Test_SmartResponseExists(t *testing.T) {
 	expectedVal.Actual = "false"
 	expectedVal.Comment = `The SMART Response does not exist. FHIR endpoints requiring authorization SHALL serve a JSON document at the location formed by appending /.well-known/smart-configuration to their base URL.`
 
-	actualVal = validator.SmartResponseExists(nil)
+	actualVal = r4SmartResponseExists(nil)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SMART-on-FHIR response does not exist so it should be invalid, is instead %+v", actualVal))
 }
@@ -341,9 +357,6 @@ func Test_KindValid(t *testing.T) {
 	cs, err := getDSTU2CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, dstu2)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	baseComment := "Kind value should be set to 'instance' because this is a specific system instance."
@@ -359,7 +372,7 @@ func Test_KindValid(t *testing.T) {
 		expectedVal,
 	}
 
-	actualVal := validator.KindValid(cs)
+	actualVal := kindValid(cs)
 	eq := reflect.DeepEqual(actualVal, expectedArray)
 	th.Assert(t, eq == true, fmt.Sprintf("Kind value should equal instance, is instead %+v", actualVal))
 
@@ -372,7 +385,7 @@ func Test_KindValid(t *testing.T) {
 		expectedVal,
 	}
 
-	actualVal = validator.KindValid(nil)
+	actualVal = kindValid(nil)
 	eq = reflect.DeepEqual(actualVal, expectedArray)
 	th.Assert(t, eq == true, fmt.Sprintf("Can't check kind when capability statement does not exist, is instead %+v", actualVal))
 
@@ -396,7 +409,7 @@ func Test_KindValid(t *testing.T) {
 	cs, err = capabilityparser.NewCapabilityStatementFromInterface(csInt)
 	th.Assert(t, err == nil, err)
 
-	actualVal = validator.KindValid(cs)
+	actualVal = kindValid(cs)
 	eq = reflect.DeepEqual(actualVal, expectedArray)
 	th.Assert(t, eq == true, fmt.Sprintf("Kind value should equal capability, is instead %+v", actualVal))
 
@@ -409,15 +422,12 @@ func Test_KindValid(t *testing.T) {
 	cs2, err := deleteFieldFromCapStat(cs, "kind")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, dstu2)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Actual = ""
 	expectedArray = []endpointmanager.Rule{
 		expectedVal,
 	}
 
-	actualVal = validator2.KindValid(cs2)
+	actualVal = kindValid(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedArray)
 	th.Assert(t, eq == true, fmt.Sprintf("Malformed kind value should return an invalid check, is instead %+v", actualVal))
 
@@ -426,9 +436,6 @@ func Test_KindValid(t *testing.T) {
 	cs3, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator3, err := getValidator(cs3, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = true
 	expectedVal.Actual = "instance"
 	expectedVal.Reference = "http://hl7.org/fhir/capabilitystatement.html"
@@ -444,7 +451,7 @@ func Test_KindValid(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 	}
 
-	actualVal = validator3.KindValid(cs3)
+	actualVal = r4KindValid(cs3)
 	eq = reflect.DeepEqual(actualVal[0], expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("R4 KindValid first check should be valid, is instead %+v", actualVal[0]))
 	eq = reflect.DeepEqual(actualVal[1], expectedInstanceVal)
@@ -455,12 +462,9 @@ func Test_KindValid(t *testing.T) {
 	cs4, err := deleteFieldFromCapStat(cs3, "implementation")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedInstanceVal.Valid = false
 	expectedInstanceVal.Actual = "false"
-	actualVal = validator4.KindValid(cs4)
+	actualVal = r4KindValid(cs4)
 	eq = reflect.DeepEqual(actualVal[1], expectedInstanceVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Implementation does not exist so KindValid check should be invalid, is instead %+v", actualVal[1]))
 }
@@ -469,9 +473,6 @@ func Test_MessagingEndpointValid(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	baseComment := "Messaging end-point is required (and is only permitted) when a statement is for an implementation. This endpoint must be an implementation."
@@ -484,7 +485,7 @@ func Test_MessagingEndpointValid(t *testing.T) {
 		Reference: "http://hl7.org/fhir/capabilitystatement.html",
 		ImplGuide: "USCore 3.1",
 	}
-	actualVal := validator.MessagingEndpointValid(cs)
+	actualVal := r4MessagingEndpointValid(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Messaging endpoint should exist, is instead %+v", actualVal))
 
@@ -493,13 +494,10 @@ func Test_MessagingEndpointValid(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"messaging", "endpoint"}, []int{0}, 1, deleteField, "")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
 	expectedVal.Comment = "Endpoint field in Messaging does not exist. " + baseComment
-	actualVal = validator2.MessagingEndpointValid(cs2)
+	actualVal = r4MessagingEndpointValid(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Removing the messaging endpoint should make check invalid, is instead %+v", actualVal))
 
@@ -508,11 +506,8 @@ func Test_MessagingEndpointValid(t *testing.T) {
 	cs3, err := deleteFieldFromCapStat(cs, "messaging")
 	th.Assert(t, err == nil, err)
 
-	validator3, err := getValidator(cs3, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "Messaging does not exist. " + baseComment
-	actualVal = validator3.MessagingEndpointValid(cs3)
+	actualVal = r4MessagingEndpointValid(cs3)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Removing the messaging field should make check invalid, is instead %+v", actualVal))
 
@@ -521,11 +516,8 @@ func Test_MessagingEndpointValid(t *testing.T) {
 	cs4, err := deleteFieldFromCapStat(cs, "kind")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "Kind value should be set to 'instance' because this is a specific system instance. " + baseComment
-	actualVal = validator4.MessagingEndpointValid(cs4)
+	actualVal = r4MessagingEndpointValid(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Removing the kind field should make check invalid, is instead %+v", actualVal))
 }
@@ -534,9 +526,6 @@ func Test_EndpointFunctionValid(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -549,7 +538,7 @@ func Test_EndpointFunctionValid(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 	}
 
-	actualVal := validator.EndpointFunctionValid(cs)
+	actualVal := r4EndpointFunctionValid(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Rest, messaging, and document should exist, is instead %+v", actualVal))
 
@@ -558,11 +547,8 @@ func Test_EndpointFunctionValid(t *testing.T) {
 	cs2, err := deleteFieldFromCapStat(cs, "messaging")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Actual = "rest,document"
-	actualVal = validator2.EndpointFunctionValid(cs2)
+	actualVal = r4EndpointFunctionValid(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Rest and document should exist, is instead %+v", actualVal))
 
@@ -573,12 +559,9 @@ func Test_EndpointFunctionValid(t *testing.T) {
 	cs4, err := deleteFieldFromCapStat(cs3, "document")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Actual = ""
 	expectedVal.Valid = false
-	actualVal = validator4.EndpointFunctionValid(cs4)
+	actualVal = r4EndpointFunctionValid(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Rest, messaging, and document should not exist, is instead %+v", actualVal))
 }
@@ -587,9 +570,6 @@ func Test_DescribeEndpointValid(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -602,7 +582,7 @@ func Test_DescribeEndpointValid(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 	}
 
-	actualVal := validator.DescribeEndpointValid(cs)
+	actualVal := r4DescribeEndpointValid(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Description, software, and implementation should exist, is instead %+v", actualVal))
 
@@ -611,11 +591,8 @@ func Test_DescribeEndpointValid(t *testing.T) {
 	cs2, err := deleteFieldFromCapStat(cs, "software")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Actual = "description,implementation"
-	actualVal = validator2.DescribeEndpointValid(cs2)
+	actualVal = r4DescribeEndpointValid(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Description and implementation should exist, is instead %+v", actualVal))
 
@@ -626,12 +603,9 @@ func Test_DescribeEndpointValid(t *testing.T) {
 	cs4, err := deleteFieldFromCapStat(cs3, "implementation")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Actual = ""
 	expectedVal.Valid = false
-	actualVal = validator4.DescribeEndpointValid(cs4)
+	actualVal = r4DescribeEndpointValid(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("Description, software, and implementation should not exist, is instead %+v", actualVal))
 }
@@ -640,9 +614,6 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	baseComment := "The set of documents must be unique by the combination of profile and mode."
@@ -656,7 +627,7 @@ func Test_DocumentSetValid(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 	}
 
-	actualVal := validator.DocumentSetValid(cs)
+	actualVal := r4DocumentSetValid(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The set of documents should be unique, is instead %+v", actualVal))
 
@@ -665,14 +636,11 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"document", "mode"}, []int{0}, 1, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
 	expectedVal.Comment = "Document field is not formatted correctly. Cannot check if the set of documents are unique. " + baseComment
 
-	actualVal = validator2.DocumentSetValid(cs2)
+	actualVal = r4DocumentSetValid(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("An invalid mode should make the check invalid, is instead %+v", actualVal))
 
@@ -681,11 +649,8 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs3, err := nLevelNestedValueChange(cs, []string{"document", "profile"}, []int{0}, 1, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator3, err := getValidator(cs3, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "Document field is not formatted correctly. Cannot check if the set of documents are unique. " + baseComment
-	actualVal = validator3.DocumentSetValid(cs3)
+	actualVal = r4DocumentSetValid(cs3)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("An invalid profile should make the check invalid, is instead %+v", actualVal))
 
@@ -694,12 +659,9 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs4, err := nLevelNestedValueChange(cs, []string{"document", "mode"}, []int{0}, 1, updateString, "producer")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "The set of documents are not unique. " + baseComment
 
-	actualVal = validator4.DocumentSetValid(cs4)
+	actualVal = r4DocumentSetValid(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The documents not being unique should make the check invalid, is instead %+v", actualVal))
 
@@ -708,11 +670,8 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs5, err := nLevelNestedValueChange(cs, []string{"document"}, []int{}, 0, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator5, err := getValidator(cs5, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "Document field is not formatted correctly. Cannot check if the set of documents are unique. " + baseComment
-	actualVal = validator5.DocumentSetValid(cs5)
+	actualVal = r4DocumentSetValid(cs5)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("An improperly formatted document field should make the check invalid, is instead %+v", actualVal))
 
@@ -721,24 +680,15 @@ func Test_DocumentSetValid(t *testing.T) {
 	cs6, err := deleteFieldFromCapStat(cs, "document")
 	th.Assert(t, err == nil, err)
 
-	validator6, err := getValidator(cs6, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = true
 	expectedVal.Actual = "true"
 	expectedVal.Comment = "Document field does not exist, but is not required. " + baseComment
-	actualVal = validator6.DocumentSetValid(cs6)
+	actualVal = r4DocumentSetValid(cs6)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The check should be valid if the document field does not exist, is instead %+v", actualVal))
 }
 
 func Test_TLSVersion(t *testing.T) {
-	cs, err := getR4CapStat()
-	th.Assert(t, err == nil, err)
-
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	expectedVal := endpointmanager.Rule{
@@ -750,7 +700,7 @@ func Test_TLSVersion(t *testing.T) {
 		Reference: "https://www.hl7.org/fhir/us/core/security.html",
 		ImplGuide: "USCore 3.1",
 	}
-	actualVal := validator.TLSVersion("TLS 1.2")
+	actualVal := r4TLSVersion("TLS 1.2")
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("TLSVersion check should be valid, returned value is instead %+v", actualVal))
 
@@ -758,7 +708,7 @@ func Test_TLSVersion(t *testing.T) {
 
 	expectedVal.Valid = false
 	expectedVal.Actual = "TLS 1.1"
-	actualVal = validator.TLSVersion("TLS 1.1")
+	actualVal = r4TLSVersion("TLS 1.1")
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("TLSVersion check should be invalid, returned value is instead %+v", actualVal))
 }
@@ -767,9 +717,6 @@ func Test_UniqueResources(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	baseComment := "A given resource can only be described once per RESTful mode."
@@ -782,7 +729,7 @@ func Test_UniqueResources(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 		Reference: "http://hl7.org/fhir/capabilitystatement.html",
 	}
-	actualVal := validator.UniqueResources(cs)
+	actualVal := r4UniqueResources(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The given resources should be unique, is instead %+v", actualVal))
 
@@ -791,13 +738,10 @@ func Test_UniqueResources(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "type"}, []int{0, 1}, 2, updateString, "Patient")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
 	expectedVal.Comment = "The resource type Patient is not unique. " + baseComment
-	actualVal = validator2.UniqueResources(cs2)
+	actualVal = r4UniqueResources(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The given resources should not be unique, is instead %+v", actualVal))
 }
@@ -806,9 +750,6 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs, err := getR4CapStat()
 	th.Assert(t, err == nil, err)
 
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	// base test
 
 	baseComment := "Search parameter names must be unique in the context of a resource."
@@ -821,7 +762,7 @@ func Test_SearchParamsUnique(t *testing.T) {
 		ImplGuide: "USCore 3.1",
 		Reference: "http://hl7.org/fhir/capabilitystatement.html",
 	}
-	actualVal := validator.SearchParamsUnique(cs)
+	actualVal := r4SearchParamsUnique(cs)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The search parameters in each resource should be unique, is instead %+v", actualVal))
 
@@ -830,13 +771,10 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs2, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "searchParam", "name"}, []int{0, 0, 0}, 3, updateString, "general-practitioner")
 	th.Assert(t, err == nil, err)
 
-	validator2, err := getValidator(cs2, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = false
 	expectedVal.Actual = "false"
 	expectedVal.Comment = "The resource type Patient does not have unique searchParams. " + baseComment
-	actualVal = validator2.SearchParamsUnique(cs2)
+	actualVal = r4SearchParamsUnique(cs2)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("The search parameters in each resource should not be unique, is instead %+v", actualVal))
 
@@ -845,11 +783,8 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs3, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "searchParam", "name"}, []int{0, 0, 0}, 3, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator3, err := getValidator(cs3, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Comment = "The resource type Patient is not formatted properly. " + baseComment
-	actualVal = validator3.SearchParamsUnique(cs3)
+	actualVal = r4SearchParamsUnique(cs3)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SearchParamsUnique check should be invalid because a searchParam name field is malformed, is instead %+v", actualVal))
 
@@ -858,10 +793,7 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs4, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "searchParam", "name"}, []int{0, 0, 0}, 3, deleteField, "")
 	th.Assert(t, err == nil, err)
 
-	validator4, err := getValidator(cs4, r4)
-	th.Assert(t, err == nil, err)
-
-	actualVal = validator4.SearchParamsUnique(cs4)
+	actualVal = r4SearchParamsUnique(cs4)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SearchParamsUnique check should be invalid because a searchParam name field does not exist, is instead %+v", actualVal))
 
@@ -870,10 +802,7 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs5, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "searchParam"}, []int{0, 0}, 2, badFormat, "")
 	th.Assert(t, err == nil, err)
 
-	validator5, err := getValidator(cs5, r4)
-	th.Assert(t, err == nil, err)
-
-	actualVal = validator5.SearchParamsUnique(cs5)
+	actualVal = r4SearchParamsUnique(cs5)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SearchParamsUnique check should be invalid because a searchParam field is malformed, is instead %+v", actualVal))
 
@@ -882,24 +811,15 @@ func Test_SearchParamsUnique(t *testing.T) {
 	cs6, err := nLevelNestedValueChange(cs, []string{"rest", "resource", "searchParam"}, []int{0, 0}, 2, deleteField, "")
 	th.Assert(t, err == nil, err)
 
-	validator6, err := getValidator(cs6, r4)
-	th.Assert(t, err == nil, err)
-
 	expectedVal.Valid = true
 	expectedVal.Actual = "true"
 	expectedVal.Comment = baseComment
-	actualVal = validator6.SearchParamsUnique(cs6)
+	actualVal = r4SearchParamsUnique(cs6)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("SearchParamsUnique check should be valid even though searchParams do not exist, is instead %+v", actualVal))
 }
 
 func Test_VersionResponseValid(t *testing.T) {
-	cs, err := getR4CapStat()
-	th.Assert(t, err == nil, err)
-
-	validator, err := getValidator(cs, r4)
-	th.Assert(t, err == nil, err)
-
 	fhirVersion := "4.0.1"
 	defaultFhirVersion := "4.0.1"
 
@@ -914,7 +834,7 @@ func Test_VersionResponseValid(t *testing.T) {
 		Comment:   "The default fhir version as specified by the $versions operation should be returned from server when no version specified.",
 	}
 
-	actualVal := validator.VersionResponseValid(fhirVersion, defaultFhirVersion)
+	actualVal := r4VersionResponseValid(fhirVersion, defaultFhirVersion)
 	eq := reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("$version operation and default version should be valid, is instead %+v", actualVal))
 
@@ -923,7 +843,7 @@ func Test_VersionResponseValid(t *testing.T) {
 	defaultFhirVersion = "1.0.2"
 	expectedVal.Expected = "1.0.2"
 	expectedVal.Valid = false
-	actualVal = validator.VersionResponseValid(fhirVersion, defaultFhirVersion)
+	actualVal = r4VersionResponseValid(fhirVersion, defaultFhirVersion)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("$version operation should be valid, but default version should not match fhir version, is instead %+v", actualVal))
 
@@ -932,11 +852,538 @@ func Test_VersionResponseValid(t *testing.T) {
 	defaultFhirVersion = "4.0"
 	expectedVal.Expected = "4.0"
 	expectedVal.Valid = true
-	actualVal = validator.VersionResponseValid(fhirVersion, defaultFhirVersion)
+	actualVal = r4VersionResponseValid(fhirVersion, defaultFhirVersion)
 	eq = reflect.DeepEqual(actualVal, expectedVal)
 	th.Assert(t, eq == true, fmt.Sprintf("$version operation should be valid, and default version's publication and major components should match fhir version, is instead %+v", actualVal))
 }
 
+func Test_RedirectsSecure(t *testing.T) {
+	baseComment := "Redirects from a secure URL SHALL NOT downgrade the request to an insecure URL."
+
+	// base test, http to https redirects are secure
+
+	redirects := []endpointmanager.Redirect{
+		{URL: "http://example.com/fhir/metadata", StatusCode: 301, Location: "https://example.com/fhir/metadata"},
+		{URL: "https://example.com/fhir/metadata", StatusCode: 302, Location: "https://example.com/r4/metadata"},
+	}
+	expectedVal := endpointmanager.Rule{
+		RuleName:  endpointmanager.RedirectSecureRule,
+		Valid:     true,
+		Expected:  "true",
+		Actual:    "true",
+		Comment:   baseComment,
+		Reference: "http://hl7.org/fhir/security.html",
+	}
+
+	actualVal := redirectsSecure(redirects)
+	eq := reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("RedirectsSecure check should be valid, is instead %+v", actualVal))
+
+	// https to http redirect
+
+	redirects = append(redirects, endpointmanager.Redirect{URL: "https://example.com/r4/metadata", StatusCode: 302, Location: "http://example.com/r4/metadata"})
+	expectedVal.Valid = false
+	expectedVal.Actual = "false"
+	expectedVal.Comment = "The endpoint redirected from https://example.com/r4/metadata to http://example.com/r4/metadata. " + baseComment
+
+	actualVal = redirectsSecure(redirects)
+	eq = reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("RedirectsSecure check should be invalid because of the downgrade to http, is instead %+v", actualVal))
+}
+
+func Test_RedirectsSameDomain(t *testing.T) {
+	cs, err := getR4CapStat()
+	th.Assert(t, err == nil, err)
+
+	validator, err := getValidator(cs, r4)
+	th.Assert(t, err == nil, err)
+
+	baseComment := "Redirects should not send requests for the endpoint to a different domain."
+
+	// base test, the scheme, port and path can change
+
+	redirects := []endpointmanager.Redirect{
+		{URL: "http://example.com/fhir/metadata", StatusCode: 301, Location: "https://EXAMPLE.com:8443/fhir/metadata"},
+		{URL: "https://example.com:8443/fhir/metadata", StatusCode: 302, Location: "https://example.com:8443/r4/metadata"},
+	}
+	expectedVal := endpointmanager.Rule{
+		RuleName:  endpointmanager.RedirectDomainRule,
+		Valid:     true,
+		Expected:  "true",
+		Actual:    "true",
+		Comment:   baseComment,
+		Reference: "http://hl7.org/fhir/http.html",
+	}
+
+	actualVal := redirectsSameDomain(redirects)
+	eq := reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("RedirectsSameDomain check should be valid, is instead %+v", actualVal))
+
+	// redirect to another domain
+
+	redirects = append(redirects, endpointmanager.Redirect{URL: "https://example.com:8443/r4/metadata", StatusCode: 302, Location: "https://other.com/r4/metadata"})
+	expectedVal.Valid = false
+	expectedVal.Actual = "false"
+	expectedVal.Comment = "The endpoint redirected from https://example.com:8443/r4/metadata to https://other.com/r4/metadata. " + baseComment
+
+	actualVal = redirectsSameDomain(redirects)
+	eq = reflect.DeepEqual(actualVal, expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("RedirectsSameDomain check should be invalid because of the redirect to another domain, is instead %+v", actualVal))
+
+	// the rules are only run when the endpoint redirected the request
+
+	sr := smartparser.SMARTResponse(nil)
+	withoutRedirects := validator.RunValidation(cs, "4.0.1", "TLS 1.2", sr, "None", "", nil)
+	withRedirects := validator.RunValidation(cs, "4.0.1", "TLS 1.2", sr, "None", "", redirects)
+	th.Assert(t, len(withRedirects.Results) == len(withoutRedirects.Results)+2, fmt.Sprintf("Expected the redirect rules to add 2 validation checks, got %d and %d", len(withoutRedirects.Results), len(withRedirects.Results)))
+	secureRule := withRedirects.Results[len(withRedirects.Results)-2]
+	th.Assert(t, secureRule.RuleName == endpointmanager.RedirectSecureRule && secureRule.Valid && secureRule.Severity == endpointmanager.SeverityError, fmt.Sprintf("Expected a valid redirect secure rule with error severity, got %+v", secureRule))
+	domainRule := withRedirects.Results[len(withRedirects.Results)-1]
+	th.Assert(t, domainRule.RuleName == endpointmanager.RedirectDomainRule && !domainRule.Valid && domainRule.Severity == endpointmanager.SeverityWarning, fmt.Sprintf("Expected an invalid redirect domain rule with warning severity, got %+v", domainRule))
+	th.Assert(t, withRedirects.Score < withoutRedirects.Score, fmt.Sprintf("Expected the failing redirect rule to lower the score from %f, got %f", withoutRedirects.Score, withRedirects.Score))
+}
+
+func Test_RegisterRule(t *testing.T) {
+	defer func() { registry = newRegistry() }()
+
+	cs, err := getR4CapStat()
+	th.Assert(t, err == nil, err)
+
+	sr, err := getSmartResponse()
+	th.Assert(t, err == nil, err)
+
+	validator, err := getValidator(cs, r4)
+	th.Assert(t, err == nil, err)
+
+	expectedVal := endpointmanager.Rule{
+		RuleName:  "alwaysValid",
+		Valid:     true,
+		Expected:  "true",
+		Actual:    "true",
+		Reference: "http://example.com/reference",
+		ImplGuide: "Example IG",
+		Severity:  endpointmanager.SeverityInfo,
+		Weight:    1,
+	}
+
+	// base test
+
+	err = RegisterRule(RuleDefinition{
+		Name:         "alwaysValid",
+		FHIRVersions: []string{R4Family},
+		Severity:     endpointmanager.SeverityInfo,
+		Reference:    "http://example.com/reference",
+		ImplGuide:    "Example IG",
+		Check: func(in *Input) []endpointmanager.Rule {
+			return []endpointmanager.Rule{{RuleName: "alwaysValid", Valid: true, Expected: "true", Actual: "true"}}
+		},
+	})
+	th.Assert(t, err == nil, err)
+
+	actualVal := validator.RunValidation(cs, "4.0.1", "TLS 1.2", sr, "None", "", nil)
+	th.Assert(t, len(actualVal.Results) == 14, fmt.Sprintf("RunValidation should have returned 14 validation checks, instead it returned %d", len(actualVal.Results)))
+	eq := reflect.DeepEqual(actualVal.Results[13], expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("RunValidation's last returned validation should be the registered rule, is instead %+v", actualVal.Results[13]))
+
+	// rule is not run for other FHIR versions
+
+	cs2, err := getDSTU2CapStat()
+	th.Assert(t, err == nil, err)
+
+	validator2, err := getValidator(cs2, dstu2)
+	th.Assert(t, err == nil, err)
+
+	actualVal = validator2.RunValidation(cs2, "1.0.2", "TLS 1.2", sr, "None", "", nil)
+	th.Assert(t, len(actualVal.Results) == 7, fmt.Sprintf("RunValidation should have returned 7 validation checks, instead it returned %d", len(actualVal.Results)))
+
+	// rule names must be unique
+
+	err = RegisterRule(RuleDefinition{
+		Name:         endpointmanager.CapStatExistRule,
+		FHIRVersions: []string{R4Family},
+		Check: func(in *Input) []endpointmanager.Rule {
+			return nil
+		},
+	})
+	th.Assert(t, err != nil, "Expected an error registering a rule with a name that is already registered")
+
+	// rules must have a check and known versions and severity
+
+	err = RegisterRule(RuleDefinition{Name: "noCheck", FHIRVersions: []string{R4Family}})
+	th.Assert(t, err != nil, "Expected an error registering a rule without a check function")
+
+	check := func(in *Input) []endpointmanager.Rule { return nil }
+	err = RegisterRule(RuleDefinition{Name: "badVersion", FHIRVersions: []string{"r5"}, Check: check})
+	th.Assert(t, err != nil, "Expected an error registering a rule with an unknown FHIR version")
+
+	err = RegisterRule(RuleDefinition{Name: "badSeverity", FHIRVersions: []string{R4Family}, Severity: "fatal", Check: check})
+	th.Assert(t, err != nil, "Expected an error registering a rule with an unknown severity")
+
+	err = RegisterRule(RuleDefinition{Name: "badWeight", FHIRVersions: []string{R4Family}, Weight: -1, Check: check})
+	th.Assert(t, err != nil, "Expected an error registering a rule with a negative weight")
+
+	// the weight of a rule is split between the results it returns
+
+	err = RegisterRule(RuleDefinition{
+		Name:         "perResource",
+		FHIRVersions: []string{R4Family},
+		Severity:     endpointmanager.SeverityWarning,
+		Weight:       4,
+		Check: func(in *Input) []endpointmanager.Rule {
+			return []endpointmanager.Rule{
+				{RuleName: "perResource", Valid: true, Resource: "Patient"},
+				{RuleName: "perResource", Valid: false, Resource: "Goal"},
+			}
+		},
+	})
+	th.Assert(t, err == nil, err)
+
+	actualVal = validator.RunValidation(cs, "4.0.1", "TLS 1.2", sr, "None", "", nil)
+	th.Assert(t, len(actualVal.Results) == 16, fmt.Sprintf("RunValidation should have returned 16 validation checks, instead it returned %d", len(actualVal.Results)))
+	for _, result := range actualVal.Results[14:] {
+		th.Assert(t, result.Weight == 2, fmt.Sprintf("Expected the per resource result to have weight 2, got %f", result.Weight))
+		th.Assert(t, result.Severity == endpointmanager.SeverityWarning, fmt.Sprintf("Expected the per resource result to have severity warning, got %s", result.Severity))
+	}
+	th.Assert(t, actualVal.Score == actualVal.ComputeScore(), fmt.Sprintf("Expected RunValidation to set the score to %f, got %f", actualVal.ComputeScore(), actualVal.Score))
+	th.Assert(t, actualVal.Score < 1, fmt.Sprintf("Expected the score to be below 1 when a weighted rule fails, got %f", actualVal.Score))
+}
+
+func Test_LoadRules(t *testing.T) {
+	defer func() { registry = newRegistry() }()
+
+	cs, err := getR4CapStat()
+	th.Assert(t, err == nil, err)
+
+	rules := []byte(`{
+		"rules": [
+			{
+				"name": "softwareNameExists",
+				"fhirVersions": ["r4"],
+				"severity": "info",
+				"path": ["software", "name"],
+				"comment": "The software name should be included."
+			},
+			{
+				"name": "jsonFormat",
+				"fhirVersions": ["r4"],
+				"path": ["format"],
+				"value": "json",
+				"comment": "The server should support json."
+			}
+		]
+	}`)
+	err = LoadRules(rules)
+	th.Assert(t, err == nil, err)
+
+	registered := RegisteredRules(R4Family)
+	th.Assert(t, len(registered) == 17, fmt.Sprintf("Expected 17 registered R4 rules, got %d", len(registered)))
+	th.Assert(t, registered[15].Severity == endpointmanager.SeverityInfo, "Expected the declared severity to be kept")
+	th.Assert(t, registered[16].Severity == endpointmanager.SeverityError, "Expected the severity to default to error")
+
+	// presence rule
+
+	expectedVal := endpointmanager.Rule{
+		RuleName: "softwareNameExists",
+		Valid:    true,
+		Expected: "true",
+		Actual:   "true",
+		Comment:  "The software name should be included.",
+	}
+	actualVal := registered[15].Check(&Input{CapStat: cs})
+	eq := reflect.DeepEqual(actualVal[0], expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected software name to exist, is instead %+v", actualVal[0]))
+
+	cs2, err := deleteFieldFromCapStat(cs, "software")
+	th.Assert(t, err == nil, err)
+
+	expectedVal.Valid = false
+	expectedVal.Actual = "false"
+	actualVal = registered[15].Check(&Input{CapStat: cs2})
+	eq = reflect.DeepEqual(actualVal[0], expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected software name to not exist, is instead %+v", actualVal[0]))
+
+	expectedVal.Comment = "The Capability Statement does not exist; cannot check software.name. The software name should be included."
+	actualVal = registered[15].Check(&Input{})
+	eq = reflect.DeepEqual(actualVal[0], expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected rule to be invalid when the capability statement does not exist, is instead %+v", actualVal[0]))
+
+	// value rule, where format is an array
+
+	cs3, err := nLevelNestedValueChange(cs, []string{"format"}, []int{}, 0, func(innerField map[string]interface{}, field string, optional string) {
+		innerField[field] = []interface{}{"xml", "json"}
+	}, "")
+	th.Assert(t, err == nil, err)
+
+	expectedVal = endpointmanager.Rule{
+		RuleName: "jsonFormat",
+		Valid:    true,
+		Expected: "json",
+		Actual:   "xml,json",
+		Comment:  "The server should support json.",
+	}
+	actualVal = registered[16].Check(&Input{CapStat: cs3})
+	eq = reflect.DeepEqual(actualVal[0], expectedVal)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected json format to be found, is instead %+v", actualVal[0]))
+
+	// rules must have a path
+
+	err = LoadRules([]byte(`{"rules": [{"name": "noPath", "fhirVersions": ["r4"]}]}`))
+	th.Assert(t, err != nil, "Expected an error loading a rule without a path")
+
+	// bad JSON
+
+	err = LoadRules([]byte(`{"rules": `))
+	th.Assert(t, err != nil, "Expected an error loading malformed rule definitions")
+}
+
+func Test_USCoreRequirements(t *testing.T) {
+	defer func() { registry = newRegistry() }()
+
+	requirements := []byte(`{
+		"versions": [
+			{
+				"version": "3.1.1",
+				"reference": "http://hl7.org/fhir/us/core/STU3.1.1/CapabilityStatement-us-core-server.html",
+				"resources": [
+					{
+						"type": "Patient",
+						"profiles": ["http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient"],
+						"searchParams": ["identifier", "name"],
+						"searchCombinations": [],
+						"interactions": ["read", "search-type"]
+					},
+					{
+						"type": "Goal",
+						"profiles": ["http://hl7.org/fhir/us/core/StructureDefinition/us-core-goal"],
+						"searchParams": ["patient"],
+						"searchCombinations": [],
+						"interactions": ["read", "search-type"]
+					}
+				]
+			},
+			{
+				"version": "4.0.0",
+				"reference": "http://hl7.org/fhir/us/core/STU4/CapabilityStatement-us-core-server.html",
+				"resources": [
+					{
+						"type": "Patient",
+						"profiles": ["http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient"],
+						"searchParams": ["identifier", "name"],
+						"searchCombinations": [["name", "birthdate"]],
+						"interactions": ["read", "search-type"]
+					}
+				]
+			}
+		]
+	}`)
+	err := LoadUSCoreRequirements(requirements)
+	th.Assert(t, err == nil, err)
+
+	registered := RegisteredRules(R4Family)
+	th.Assert(t, len(registered) == 18, fmt.Sprintf("Expected 18 registered R4 rules, got %d", len(registered)))
+	profileCheck := registered[15].Check
+	searchCheck := registered[16].Check
+	interactionCheck := registered[17].Check
+
+	cs, err := getR4CapStat()
+	th.Assert(t, err == nil, err)
+
+	// base test, the capability statement does not claim a US Core version so 3.1.1 is used
+
+	expectedProfile := []endpointmanager.Rule{
+		{
+			RuleName:  endpointmanager.USCoreProfileRule,
+			Valid:     false,
+			Expected:  "us-core-patient",
+			Actual:    "",
+			Comment:   "Missing: us-core-patient. The US Core Server SHALL support the US Core profiles for the Patient resource.",
+			Reference: "http://hl7.org/fhir/us/core/STU3.1.1/CapabilityStatement-us-core-server.html",
+			ImplGuide: "USCore 3.1.1",
+			Resource:  "Patient",
+		},
+		{
+			RuleName:  endpointmanager.USCoreProfileRule,
+			Valid:     false,
+			Expected:  "us-core-goal",
+			Actual:    "",
+			Comment:   "The Goal resource is not included in the Capability Statement. The US Core Server SHALL support the US Core profiles for the Goal resource.",
+			Reference: "http://hl7.org/fhir/us/core/STU3.1.1/CapabilityStatement-us-core-server.html",
+			ImplGuide: "USCore 3.1.1",
+			Resource:  "Goal",
+		},
+	}
+	actualProfile := profileCheck(&Input{CapStat: cs})
+	eq := reflect.DeepEqual(actualProfile, expectedProfile)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected US Core profile results %+v, got %+v", expectedProfile, actualProfile))
+
+	actualSearch := searchCheck(&Input{CapStat: cs})
+	th.Assert(t, len(actualSearch) == 2, fmt.Sprintf("Expected 2 US Core search results, got %d", len(actualSearch)))
+	th.Assert(t, actualSearch[0].Valid == false, "Expected the Patient search check to fail")
+	th.Assert(t, actualSearch[0].Expected == "identifier,name", fmt.Sprintf("Expected Patient search parameters to be identifier,name, got %s", actualSearch[0].Expected))
+	th.Assert(t, actualSearch[0].Actual == "identifier", fmt.Sprintf("Expected Patient search parameters found to be identifier, got %s", actualSearch[0].Actual))
+
+	actualInteraction := interactionCheck(&Input{CapStat: cs})
+	th.Assert(t, len(actualInteraction) == 2, fmt.Sprintf("Expected 2 US Core interaction results, got %d", len(actualInteraction)))
+	th.Assert(t, actualInteraction[0].Valid == false, "Expected the Patient interaction check to fail")
+	th.Assert(t, actualInteraction[0].Actual == "read", fmt.Sprintf("Expected Patient interactions found to be read, got %s", actualInteraction[0].Actual))
+
+	// claiming US Core 4.0.0 and meeting its requirements
+
+	csInt, _, err := getCapFormats(cs)
+	th.Assert(t, err == nil, err)
+	csInt["implementationGuide"] = []interface{}{"http://hl7.org/fhir/us/core/ImplementationGuide/hl7.fhir.us.core|4.0.0"}
+	rest := csInt["rest"].([]interface{})[0].(map[string]interface{})
+	patient := rest["resource"].([]interface{})[0].(map[string]interface{})
+	patient["supportedProfile"] = []interface{}{"http://hl7.org/fhir/us/core/StructureDefinition/us-core-patient|4.0.0"}
+	patient["searchParam"] = append(patient["searchParam"].([]interface{}), map[string]interface{}{"name": "name", "type": "string"})
+	patient["interaction"] = append(patient["interaction"].([]interface{}), map[string]interface{}{"code": "search-type"})
+	patient["extension"] = []interface{}{
+		map[string]interface{}{
+			"url": "http://hl7.org/fhir/StructureDefinition/capabilitystatement-search-parameter-combination",
+			"extension": []interface{}{
+				map[string]interface{}{"url": "required", "valueString": "birthdate"},
+				map[string]interface{}{"url": "required", "valueString": "name"},
+			},
+		},
+	}
+	csJSON, err := json.Marshal(csInt)
+	th.Assert(t, err == nil, err)
+	cs2, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+
+	for _, check := range []RuleCheck{profileCheck, searchCheck, interactionCheck} {
+		actual := check(&Input{CapStat: cs2})
+		th.Assert(t, len(actual) == 1, fmt.Sprintf("Expected 1 US Core 4.0.0 result, got %d", len(actual)))
+		th.Assert(t, actual[0].Valid == true, fmt.Sprintf("Expected the %s check to pass, got %+v", actual[0].RuleName, actual[0]))
+		th.Assert(t, actual[0].ImplGuide == "USCore 4.0.0", fmt.Sprintf("Expected the US Core 4.0.0 requirements to be used, got %s", actual[0].ImplGuide))
+		th.Assert(t, actual[0].Resource == "Patient", fmt.Sprintf("Expected the result to be for the Patient resource, got %s", actual[0].Resource))
+	}
+
+	// capability statement does not exist
+
+	actualProfile = profileCheck(&Input{})
+	th.Assert(t, len(actualProfile) == 1, fmt.Sprintf("Expected 1 US Core result when the capability statement does not exist, got %d", len(actualProfile)))
+	th.Assert(t, actualProfile[0].Valid == false, "Expected the US Core profile check to fail when the capability statement does not exist")
+
+	// requirements must include a version
+
+	err = LoadUSCoreRequirements([]byte(`{"versions": []}`))
+	th.Assert(t, err != nil, "Expected an error loading US Core requirements without any versions")
+}
+
+func Test_ValidateStructure(t *testing.T) {
+	defer func() { structureDefinitions = make(map[string]*structureDefinition) }()
+
+	cs, err := getR4CapStat()
+	th.Assert(t, err == nil, err)
+
+	// no StructureDefinition loaded
+
+	issues := validateStructure(R4Family, cs)
+	th.Assert(t, len(issues) == 0, fmt.Sprintf("Expected no issues when no StructureDefinition is loaded, got %d", len(issues)))
+
+	err = LoadStructureDefinitionDir("../../../../resources/prod_resources/structuredefinitions")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(structureDefinitions) == 3, fmt.Sprintf("Expected 3 StructureDefinitions to be loaded, got %d", len(structureDefinitions)))
+
+	// base test, the test capability statement has two empty documentation strings
+
+	expectedIssue := endpointmanager.ValidationIssue{
+		Severity:    "error",
+		Code:        "value",
+		Diagnostics: "CapabilityStatement.rest[0].resource[1].interaction[0].documentation: \"\" is not a valid markdown",
+		Expression:  "CapabilityStatement.rest[0].resource[1].interaction[0].documentation",
+	}
+	issues = validateStructure(R4Family, cs)
+	th.Assert(t, len(issues) == 2, fmt.Sprintf("Expected 2 issues, got %d", len(issues)))
+	eq := reflect.DeepEqual(issues[0], expectedIssue)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected first issue to be %+v, got %+v", expectedIssue, issues[0]))
+
+	// missing required element, array given for a single value, single value given for an array,
+	// wrong data type and an unknown element
+
+	csInt, _, err := getCapFormats(cs)
+	th.Assert(t, err == nil, err)
+	delete(csInt, "status")
+	csInt["kind"] = []interface{}{"instance"}
+	csInt["format"] = "json"
+	csInt["experimental"] = "false"
+	csInt["unknownElement"] = "value"
+	csJSON, err := json.Marshal(csInt)
+	th.Assert(t, err == nil, err)
+	cs2, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+
+	expectedIssues := []endpointmanager.ValidationIssue{
+		{
+			Severity:    "error",
+			Code:        "required",
+			Diagnostics: "CapabilityStatement.status: minimum required = 1, but only found 0",
+			Expression:  "CapabilityStatement.status",
+		},
+		{
+			Severity:    "error",
+			Code:        "structure",
+			Diagnostics: "CapabilityStatement.experimental: expected a boolean",
+			Expression:  "CapabilityStatement.experimental",
+		},
+		{
+			Severity:    "error",
+			Code:        "structure",
+			Diagnostics: "CapabilityStatement.kind: expected a single value, but found an array",
+			Expression:  "CapabilityStatement.kind",
+		},
+		{
+			Severity:    "error",
+			Code:        "structure",
+			Diagnostics: "CapabilityStatement.format: expected an array, but found a single value",
+			Expression:  "CapabilityStatement.format",
+		},
+	}
+	issues = validateStructure(R4Family, cs2)
+	th.Assert(t, len(issues) == 7, fmt.Sprintf("Expected 7 issues, got %d: %+v", len(issues), issues))
+	eq = reflect.DeepEqual(issues[:4], expectedIssues)
+	th.Assert(t, eq == true, fmt.Sprintf("Expected issues %+v, got %+v", expectedIssues, issues[:4]))
+	th.Assert(t, issues[6].Expression == "CapabilityStatement.unknownElement", fmt.Sprintf("Expected the last issue to be for the unknown element, got %+v", issues[6]))
+
+	// RunValidation includes the issues
+
+	validator, err := getValidator(cs2, r4)
+	th.Assert(t, err == nil, err)
+	sr, err := getSmartResponse()
+	th.Assert(t, err == nil, err)
+	actualVal := validator.RunValidation(cs2, "4.0.1", "TLS 1.2", sr, "None", "", nil)
+	eq = reflect.DeepEqual(actualVal.Issues, issues)
+	th.Assert(t, eq == true, "Expected RunValidation to include the structural validation issues")
+
+	// capability statement does not exist
+
+	issues = validateStructure(R4Family, nil)
+	th.Assert(t, len(issues) == 0, fmt.Sprintf("Expected no issues when the capability statement does not exist, got %d", len(issues)))
+
+	// StructureDefinitions in a Bundle, with definitions for other resources ignored
+
+	structureDefinitions = make(map[string]*structureDefinition)
+	bundle := []byte(`{
+		"resourceType": "Bundle",
+		"entry": [
+			{"resource": {"resourceType": "StructureDefinition", "fhirVersion": "4.0.1", "type": "Patient", "snapshot": {"element": []}}},
+			{"resource": {"resourceType": "StructureDefinition", "fhirVersion": "4.0.1", "type": "CapabilityStatement", "snapshot": {"element": [
+				{"path": "CapabilityStatement", "min": 0, "max": "*"},
+				{"path": "CapabilityStatement.status", "min": 1, "max": "1", "type": [{"code": "code"}]}
+			]}}}
+		]
+	}`)
+	err = LoadStructureDefinitions(bundle)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(structureDefinitions) == 1, fmt.Sprintf("Expected 1 StructureDefinition to be loaded from the bundle, got %d", len(structureDefinitions)))
+
+	// unknown FHIR version
+
+	err = LoadStructureDefinitions([]byte(`{"resourceType": "StructureDefinition", "fhirVersion": "5.0.0", "type": "CapabilityStatement", "snapshot": {"element": []}}`))
+	th.Assert(t, err != nil, "Expected an error loading a StructureDefinition with an unknown FHIR version")
+}
+
 // getDSTU2CapStat gets a DSTU2 Capability Statement
 func getDSTU2CapStat() (capabilityparser.CapabilityStatement, error) {
 	path := filepath.Join("../../../testdata", "test_dstu2_capability_statement.json")
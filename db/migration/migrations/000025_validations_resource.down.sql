@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validations DROP COLUMN IF EXISTS resource;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validations ADD COLUMN IF NOT EXISTS resource VARCHAR(500);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validation_results DROP COLUMN IF EXISTS conformance_score;
+
+ALTER TABLE IF EXISTS validations
+DROP COLUMN IF EXISTS severity,
+DROP COLUMN IF EXISTS weight;
+
+COMMIT;
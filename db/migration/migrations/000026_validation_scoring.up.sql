@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validation_results ADD COLUMN IF NOT EXISTS conformance_score DECIMAL(5,4);
+
+ALTER TABLE IF EXISTS validations
+ADD COLUMN IF NOT EXISTS severity VARCHAR(500),
+ADD COLUMN IF NOT EXISTS weight DECIMAL(8,4);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS validation_issues (
+    severity                VARCHAR(500),
+    code                    VARCHAR(500),
+    diagnostics             VARCHAR(500),
+    expression              VARCHAR(500),
+    validation_result_id    INT REFERENCES validation_results(id) ON DELETE SET NULL
+);
+
+COMMIT;
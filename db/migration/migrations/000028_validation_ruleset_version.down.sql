@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validation_results
+DROP COLUMN IF EXISTS ruleset_version,
+DROP COLUMN IF EXISTS revalidated_from;
+
+COMMIT;
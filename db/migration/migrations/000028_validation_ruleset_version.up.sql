@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS validation_results
+ADD COLUMN IF NOT EXISTS ruleset_version VARCHAR(500),
+ADD COLUMN IF NOT EXISTS revalidated_from INT REFERENCES validation_results(id) ON DELETE SET NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_info DROP COLUMN IF EXISTS capability_contents_id CASCADE;
+ALTER TABLE fhir_endpoints_info_history DROP COLUMN IF EXISTS capability_contents_id CASCADE;
+
+DROP TABLE IF EXISTS capability_security_services;
+DROP TABLE IF EXISTS capability_supported_profiles;
+DROP TABLE IF EXISTS capability_search_includes;
+DROP TABLE IF EXISTS capability_operations;
+DROP TABLE IF EXISTS capability_search_params;
+DROP TABLE IF EXISTS capability_interactions;
+DROP TABLE IF EXISTS capability_resources;
+DROP TABLE IF EXISTS capability_rest;
+DROP TABLE IF EXISTS capability_contents;
+
+COMMIT;
@@ -0,0 +1,76 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS capability_contents (
+    id                      SERIAL PRIMARY KEY,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE fhir_endpoints_info ADD COLUMN IF NOT EXISTS capability_contents_id INT REFERENCES capability_contents(id) ON DELETE SET NULL;
+ALTER TABLE fhir_endpoints_info_history ADD COLUMN IF NOT EXISTS capability_contents_id INT REFERENCES capability_contents(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS capability_rest (
+    id                      SERIAL PRIMARY KEY,
+    capability_contents_id  INT REFERENCES capability_contents(id) ON DELETE CASCADE,
+    mode                    VARCHAR(500)
+);
+
+CREATE TABLE IF NOT EXISTS capability_resources (
+    id                      SERIAL PRIMARY KEY,
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    type                    VARCHAR(500),
+    profile                 VARCHAR(500)
+);
+
+-- capability_resource_id is null for the interactions, search parameters and operations of the rest entry itself
+CREATE TABLE IF NOT EXISTS capability_interactions (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    code                    VARCHAR(500)
+);
+
+CREATE TABLE IF NOT EXISTS capability_search_params (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    name                    VARCHAR(500),
+    type                    VARCHAR(500),
+    definition              VARCHAR(500)
+);
+
+CREATE TABLE IF NOT EXISTS capability_operations (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    name                    VARCHAR(500),
+    definition              VARCHAR(500)
+);
+
+CREATE TABLE IF NOT EXISTS capability_search_includes (
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    include                 VARCHAR(500),
+    reverse                 BOOLEAN
+);
+
+CREATE TABLE IF NOT EXISTS capability_supported_profiles (
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    profile                 VARCHAR(500)
+);
+
+CREATE TABLE IF NOT EXISTS capability_security_services (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    system                  VARCHAR(500),
+    code                    VARCHAR(500),
+    display                 VARCHAR(500)
+);
+
+CREATE INDEX IF NOT EXISTS info_capability_contents_id_idx ON fhir_endpoints_info (capability_contents_id);
+CREATE INDEX IF NOT EXISTS info_history_capability_contents_id_idx ON fhir_endpoints_info_history (capability_contents_id);
+CREATE INDEX IF NOT EXISTS capability_rest_contents_id_idx ON capability_rest (capability_contents_id);
+CREATE INDEX IF NOT EXISTS capability_resources_rest_id_idx ON capability_resources (capability_rest_id);
+CREATE INDEX IF NOT EXISTS capability_resources_type_idx ON capability_resources (type);
+CREATE INDEX IF NOT EXISTS capability_interactions_resource_id_idx ON capability_interactions (capability_resource_id);
+CREATE INDEX IF NOT EXISTS capability_search_params_resource_id_idx ON capability_search_params (capability_resource_id);
+CREATE INDEX IF NOT EXISTS capability_operations_resource_id_idx ON capability_operations (capability_resource_id);
+CREATE INDEX IF NOT EXISTS capability_search_includes_resource_id_idx ON capability_search_includes (capability_resource_id);
+CREATE INDEX IF NOT EXISTS capability_supported_profiles_resource_id_idx ON capability_supported_profiles (capability_resource_id);
+CREATE INDEX IF NOT EXISTS capability_security_services_rest_id_idx ON capability_security_services (capability_rest_id);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS capability_changes (
+    id                      SERIAL PRIMARY KEY,
+    url                     VARCHAR(500),
+    requested_fhir_version  VARCHAR(500),
+    vendor_id               INT REFERENCES vendors(id) ON DELETE SET NULL,
+    changes                 JSONB,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS capability_changes_url_idx ON capability_changes (url);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS endpoint_software_versions;
+
+COMMIT;
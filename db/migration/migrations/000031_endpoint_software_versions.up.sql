@@ -0,0 +1,38 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS endpoint_software_versions (
+    id                      SERIAL PRIMARY KEY,
+    url                     VARCHAR(500),
+    requested_fhir_version  VARCHAR(500),
+    vendor_id               INT REFERENCES vendors(id) ON DELETE SET NULL,
+    software_name           VARCHAR(500),
+    software_version        VARCHAR(500),
+    software_release_date   VARCHAR(500),
+    first_seen              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen               TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS endpoint_software_versions_url_idx ON endpoint_software_versions (url, requested_fhir_version);
+CREATE INDEX IF NOT EXISTS endpoint_software_versions_vendor_idx ON endpoint_software_versions (vendor_id);
+
+-- backfill the software versions from the current and historical capability statements
+INSERT INTO endpoint_software_versions (url, requested_fhir_version, vendor_id, software_name, software_version, software_release_date, first_seen, last_seen)
+    SELECT versions.url, versions.requested_fhir_version, vendors.id, versions.software_name, versions.software_version, versions.software_release_date, versions.first_seen, versions.last_seen
+    FROM (
+        SELECT url, requested_fhir_version, MAX(vendor_id) AS vendor_id,
+            LEFT(capability_statement::jsonb->'software'->>'name', 500) AS software_name,
+            LEFT(capability_statement::jsonb->'software'->>'version', 500) AS software_version,
+            LEFT(capability_statement::jsonb->'software'->>'releaseDate', 500) AS software_release_date,
+            MIN(updated_at) AS first_seen, MAX(updated_at) AS last_seen
+        FROM (
+            SELECT url, requested_fhir_version, vendor_id, capability_statement, updated_at FROM fhir_endpoints_info_history
+            UNION ALL
+            SELECT url, requested_fhir_version, vendor_id, capability_statement, updated_at FROM fhir_endpoints_info
+        ) AS infos
+        WHERE capability_statement::jsonb->'software' IS NOT NULL
+        GROUP BY url, requested_fhir_version, software_name, software_version, software_release_date
+    ) AS versions
+    LEFT JOIN vendors ON vendors.id = versions.vendor_id
+    WHERE NOT EXISTS (SELECT 1 FROM endpoint_software_versions);
+
+COMMIT;
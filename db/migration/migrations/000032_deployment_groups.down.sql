@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP VIEW IF EXISTS deployment_group_metrics;
+DROP TABLE IF EXISTS deployment_group_endpoints;
+DROP TABLE IF EXISTS deployment_groups;
+
+ALTER TABLE fhir_endpoints_metadata DROP COLUMN IF EXISTS tls_certificate_fingerprint;
+
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_metadata ADD COLUMN IF NOT EXISTS tls_certificate_fingerprint VARCHAR(500);
+
+CREATE TABLE IF NOT EXISTS deployment_groups (
+    id                          SERIAL PRIMARY KEY,
+    fingerprint                 VARCHAR(500) UNIQUE,
+    capability_statement_hash   VARCHAR(500),
+    software_name               VARCHAR(500),
+    software_version            VARCHAR(500),
+    implementation_host         VARCHAR(500),
+    tls_certificate_fingerprint VARCHAR(500),
+    supported_versions          VARCHAR(500)[],
+    created_at                  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS deployment_group_endpoints (
+    url                     VARCHAR(500) PRIMARY KEY,
+    deployment_group_id     INT REFERENCES deployment_groups(id) ON DELETE CASCADE,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS deployment_group_endpoints_group_idx ON deployment_group_endpoints (deployment_group_id);
+
+CREATE or REPLACE VIEW deployment_group_metrics AS
+SELECT groups.id AS deployment_group_id, groups.software_name, groups.software_version, groups.implementation_host,
+    COUNT(DISTINCT members.url) AS endpoint_count,
+    AVG(endpts_metadata.availability) AS availability,
+    AVG(endpts_metadata.response_time_seconds) AS response_time_seconds,
+    AVG(validation_results.conformance_score) AS conformance_score
+FROM deployment_groups AS groups
+JOIN deployment_group_endpoints AS members ON members.deployment_group_id = groups.id
+LEFT JOIN fhir_endpoints_info AS endpts_info ON members.url = endpts_info.url AND endpts_info.requested_fhir_version = 'None'
+LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
+LEFT JOIN validation_results ON endpts_info.validation_result_id = validation_results.id
+GROUP BY groups.id;
+
+COMMIT;
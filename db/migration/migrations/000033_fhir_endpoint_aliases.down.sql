@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS fhir_endpoint_aliases;
+
+ALTER TABLE fhir_endpoints_metadata DROP COLUMN IF EXISTS final_url;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_metadata ADD COLUMN IF NOT EXISTS final_url VARCHAR(500);
+
+CREATE TABLE IF NOT EXISTS fhir_endpoint_aliases (
+    url                     VARCHAR(500) PRIMARY KEY,
+    canonical_url           VARCHAR(500) NOT NULL,
+    final_url               VARCHAR(500),
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS fhir_endpoint_aliases_canonical_url_idx ON fhir_endpoint_aliases (canonical_url);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_metadata ADD COLUMN IF NOT EXISTS redirects JSONB;
+
+COMMIT;
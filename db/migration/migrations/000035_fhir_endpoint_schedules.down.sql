@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS fhir_endpoint_schedules;
+
+COMMIT;
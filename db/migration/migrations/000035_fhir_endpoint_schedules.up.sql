@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS fhir_endpoint_schedules (
+    url                     VARCHAR(500) PRIMARY KEY,
+    tier                    VARCHAR(500) NOT NULL,
+    next_query_at           TIMESTAMPTZ NOT NULL,
+    last_queried_at         TIMESTAMPTZ,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS fhir_endpoint_schedules_next_query_at_idx ON fhir_endpoint_schedules (next_query_at);
+
+COMMIT;
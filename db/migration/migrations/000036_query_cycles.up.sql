@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS query_cycles (
+    id                      SERIAL PRIMARY KEY,
+    status                  VARCHAR(500) NOT NULL,
+    endpoint_count          INTEGER NOT NULL DEFAULT 0,
+    versions_received       INTEGER NOT NULL DEFAULT 0,
+    enqueued                INTEGER NOT NULL DEFAULT 0,
+    queried                 INTEGER NOT NULL DEFAULT 0,
+    stored                  INTEGER NOT NULL DEFAULT 0,
+    failed                  INTEGER NOT NULL DEFAULT 0,
+    started_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at            TIMESTAMPTZ,
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS lanternmq_bindings;
+DROP TABLE IF EXISTS lanternmq_exchanges;
+DROP TABLE IF EXISTS lanternmq_messages;
+DROP TABLE IF EXISTS lanternmq_queues;
+
+COMMIT;
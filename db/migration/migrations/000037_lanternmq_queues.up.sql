@@ -0,0 +1,52 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS lanternmq_queues (
+    name                    VARCHAR(500) PRIMARY KEY,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS lanternmq_messages (
+    id                      BIGSERIAL PRIMARY KEY,
+    queue                   VARCHAR(500) NOT NULL REFERENCES lanternmq_queues(name) ON DELETE CASCADE,
+    body                    BYTEA NOT NULL,
+    attempts                INTEGER NOT NULL DEFAULT 0,
+    error                   TEXT,
+    return_queue            VARCHAR(500),
+    available_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS lanternmq_exchanges (
+    name                    VARCHAR(500) PRIMARY KEY,
+    type                    VARCHAR(500) NOT NULL,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS lanternmq_bindings (
+    exchange                VARCHAR(500) NOT NULL REFERENCES lanternmq_exchanges(name) ON DELETE CASCADE,
+    queue                   VARCHAR(500) NOT NULL REFERENCES lanternmq_queues(name) ON DELETE CASCADE,
+    routing_key             VARCHAR(500) NOT NULL,
+    PRIMARY KEY (exchange, queue, routing_key)
+);
+
+CREATE INDEX IF NOT EXISTS lanternmq_messages_queue_idx ON lanternmq_messages (queue, available_at, id);
+
+INSERT INTO lanternmq_queues (name) VALUES
+    ('capability-statements'),
+    ('endpoints-to-capability'),
+    ('version-responses'),
+    ('endpoints-to-version-responses'),
+    ('endpoints-to-version-responses-retry'),
+    ('test-queue'),
+    ('test-endpoints-to-capability'),
+    ('test-version-responses'),
+    ('test-endpoints-to-version-responses'),
+    ('test-endpoints-to-version-responses-retry')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO lanternmq_exchanges (name, type) VALUES
+    ('capability-changes', 'topic'),
+    ('test-capability-changes', 'topic')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE lanternmq_messages DROP COLUMN IF EXISTS priority;
+ALTER TABLE lanternmq_queues DROP COLUMN IF EXISTS max_priority;
+
+COMMIT;
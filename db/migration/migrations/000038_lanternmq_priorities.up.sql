@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE lanternmq_queues ADD COLUMN IF NOT EXISTS max_priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE lanternmq_messages ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+
+UPDATE lanternmq_queues SET max_priority = 2 WHERE name IN (
+    'version-responses',
+    'endpoints-to-capability',
+    'test-version-responses',
+    'test-endpoints-to-capability'
+);
+
+COMMIT;
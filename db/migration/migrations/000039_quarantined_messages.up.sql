@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS quarantined_messages (
+    id                      SERIAL PRIMARY KEY,
+    source                  VARCHAR(500),
+    payload                 BYTEA,
+    error                   TEXT,
+    stack                   TEXT,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS quarantined_messages_source_idx ON quarantined_messages (source);
+
+COMMIT;
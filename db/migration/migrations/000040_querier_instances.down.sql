@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS querier_instances;
+DROP TABLE IF EXISTS host_rate_limits;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS querier_instances (
+    id                      VARCHAR(500) PRIMARY KEY,
+    hostname                VARCHAR(500),
+    num_workers             INTEGER,
+    started_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_heartbeat          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS host_rate_limits (
+    host                    VARCHAR(500) PRIMARY KEY,
+    next_request_at         TIMESTAMPTZ NOT NULL
+);
+
+COMMIT;
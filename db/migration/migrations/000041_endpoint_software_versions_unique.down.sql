@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS endpoint_software_versions_unique_idx;
+
+COMMIT;
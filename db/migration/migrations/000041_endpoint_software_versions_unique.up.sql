@@ -0,0 +1,35 @@
+BEGIN;
+
+UPDATE endpoint_software_versions SET software_name = '' WHERE software_name IS NULL;
+UPDATE endpoint_software_versions SET software_version = '' WHERE software_version IS NULL;
+UPDATE endpoint_software_versions SET software_release_date = '' WHERE software_release_date IS NULL;
+
+-- merge the entries of a software version that an endpoint advertised more than once into its first entry
+UPDATE endpoint_software_versions AS versions
+SET first_seen = merged.first_seen,
+    last_seen = merged.last_seen,
+    vendor_id = merged.vendor_id
+FROM (
+    SELECT DISTINCT ON (url, requested_fhir_version, software_name, software_version, software_release_date)
+        MIN(id) OVER same_version AS id,
+        MIN(first_seen) OVER same_version AS first_seen,
+        MAX(last_seen) OVER same_version AS last_seen,
+        vendor_id
+    FROM endpoint_software_versions
+    WINDOW same_version AS (PARTITION BY url, requested_fhir_version, software_name, software_version, software_release_date)
+    ORDER BY url, requested_fhir_version, software_name, software_version, software_release_date, last_seen DESC, id DESC
+) AS merged
+WHERE versions.id = merged.id;
+
+DELETE FROM endpoint_software_versions AS duplicate
+USING endpoint_software_versions AS kept
+WHERE duplicate.url = kept.url
+AND duplicate.requested_fhir_version = kept.requested_fhir_version
+AND duplicate.software_name = kept.software_name
+AND duplicate.software_version = kept.software_version
+AND duplicate.software_release_date = kept.software_release_date
+AND duplicate.id > kept.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS endpoint_software_versions_unique_idx ON endpoint_software_versions (url, requested_fhir_version, software_name, software_version, software_release_date);
+
+COMMIT;
@@ -0,0 +1,46 @@
+BEGIN;
+
+-- the aliases of the endpoints stored before aliases were recorded, and the canonical URLs built by an older
+-- version of endpointmanager.CanonicalURL, are backfilled by the backfill_aliases command, so that every canonical
+-- URL is built by the same Go code
+
+DROP VIEW IF EXISTS endpoint_export;
+DROP VIEW IF EXISTS deployment_group_metrics;
+
+CREATE or REPLACE VIEW endpoint_export AS
+SELECT endpts.url, endpts.list_source, endpts.organization_names AS endpoint_names,
+    vendors.name as vendor_name,
+    endpts_info.tls_version, endpts_info.mime_types, endpts_metadata.http_response,
+    endpts_metadata.response_time_seconds, endpts_metadata.smart_http_response, endpts_metadata.errors,
+    EXISTS (SELECT 1 FROM fhir_endpoints_info WHERE capability_statement::jsonb != 'null' AND endpts.url = fhir_endpoints_info.url) as CAP_STAT_EXISTS,
+    endpts_info.capability_fhir_version AS FHIR_VERSION,
+    endpts_info.capability_statement->>'publisher' AS PUBLISHER,
+    endpts_info.capability_statement->'software'->'name' AS SOFTWARE_NAME,
+    endpts_info.capability_statement->'software'->'version' AS SOFTWARE_VERSION,
+    endpts_info.capability_statement->'software'->'releaseDate' AS SOFTWARE_RELEASEDATE,
+    endpts_info.capability_statement->'format' AS FORMAT,
+    endpts_info.capability_statement->>'kind' AS KIND,
+    endpts_info.updated_at AS INFO_UPDATED, endpts_info.created_at AS INFO_CREATED,
+    endpts_info.requested_fhir_version, endpts_metadata.availability,
+    COALESCE(aliases.canonical_url, endpts.url) AS canonical_url
+FROM fhir_endpoints AS endpts
+LEFT JOIN fhir_endpoints_info AS endpts_info ON endpts.url = endpts_info.url
+LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
+LEFT JOIN vendors ON endpts_info.vendor_id = vendors.id
+LEFT JOIN fhir_endpoint_aliases AS aliases ON endpts.url = aliases.url;
+
+CREATE or REPLACE VIEW deployment_group_metrics AS
+SELECT groups.id AS deployment_group_id, groups.software_name, groups.software_version, groups.implementation_host,
+    COUNT(DISTINCT COALESCE(aliases.canonical_url, members.url)) AS endpoint_count,
+    AVG(endpts_metadata.availability) AS availability,
+    AVG(endpts_metadata.response_time_seconds) AS response_time_seconds,
+    AVG(validation_results.conformance_score) AS conformance_score
+FROM deployment_groups AS groups
+JOIN deployment_group_endpoints AS members ON members.deployment_group_id = groups.id
+LEFT JOIN fhir_endpoint_aliases AS aliases ON members.url = aliases.url
+LEFT JOIN fhir_endpoints_info AS endpts_info ON members.url = endpts_info.url AND endpts_info.requested_fhir_version = 'None'
+LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
+LEFT JOIN validation_results ON endpts_info.validation_result_id = validation_results.id
+GROUP BY groups.id;
+
+COMMIT;
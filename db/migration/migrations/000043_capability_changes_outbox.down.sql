@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS capability_changes_unpublished_idx;
+
+ALTER TABLE capability_changes DROP COLUMN IF EXISTS published_at;
+
+COMMIT;
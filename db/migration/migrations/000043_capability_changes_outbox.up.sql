@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE capability_changes ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+
+-- the changes stored before were published when they were stored
+UPDATE capability_changes SET published_at = created_at WHERE published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS capability_changes_unpublished_idx ON capability_changes (id) WHERE published_at IS NULL;
+
+COMMIT;
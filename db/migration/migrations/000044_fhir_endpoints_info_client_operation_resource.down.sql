@@ -0,0 +1,21 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_info DISABLE TRIGGER set_timestamp_fhir_endpoints_info;
+ALTER TABLE fhir_endpoints_info DISABLE TRIGGER add_fhir_endpoint_info_history_trigger;
+
+-- put the client mode operations back into operation_resource with a "client:" prefix
+UPDATE fhir_endpoints_info SET
+    operation_resource = COALESCE(operation_resource, '{}'::jsonb) || (SELECT jsonb_object_agg('client:' || key, value) FROM jsonb_each(client_operation_resource))
+WHERE client_operation_resource IS NOT NULL AND client_operation_resource <> '{}'::jsonb;
+
+UPDATE fhir_endpoints_info_history SET
+    operation_resource = COALESCE(operation_resource, '{}'::jsonb) || (SELECT jsonb_object_agg('client:' || key, value) FROM jsonb_each(client_operation_resource))
+WHERE client_operation_resource IS NOT NULL AND client_operation_resource <> '{}'::jsonb;
+
+ALTER TABLE fhir_endpoints_info ENABLE TRIGGER set_timestamp_fhir_endpoints_info;
+ALTER TABLE fhir_endpoints_info ENABLE TRIGGER add_fhir_endpoint_info_history_trigger;
+
+ALTER TABLE fhir_endpoints_info DROP COLUMN IF EXISTS client_operation_resource;
+ALTER TABLE fhir_endpoints_info_history DROP COLUMN IF EXISTS client_operation_resource;
+
+COMMIT;
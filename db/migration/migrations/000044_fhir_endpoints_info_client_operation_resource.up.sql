@@ -0,0 +1,23 @@
+BEGIN;
+
+ALTER TABLE fhir_endpoints_info ADD COLUMN IF NOT EXISTS client_operation_resource JSONB;
+ALTER TABLE fhir_endpoints_info_history ADD COLUMN IF NOT EXISTS client_operation_resource JSONB;
+
+-- move the client mode operations that were stored with a "client:" prefix into their own column
+ALTER TABLE fhir_endpoints_info DISABLE TRIGGER set_timestamp_fhir_endpoints_info;
+ALTER TABLE fhir_endpoints_info DISABLE TRIGGER add_fhir_endpoint_info_history_trigger;
+
+UPDATE fhir_endpoints_info SET
+    client_operation_resource = (SELECT jsonb_object_agg(substring(key from 8), value) FROM jsonb_each(operation_resource) WHERE key LIKE 'client:%'),
+    operation_resource = (SELECT COALESCE(jsonb_object_agg(key, value), '{}'::jsonb) FROM jsonb_each(operation_resource) WHERE key NOT LIKE 'client:%')
+WHERE EXISTS (SELECT 1 FROM jsonb_object_keys(operation_resource) AS key WHERE key LIKE 'client:%');
+
+UPDATE fhir_endpoints_info_history SET
+    client_operation_resource = (SELECT jsonb_object_agg(substring(key from 8), value) FROM jsonb_each(operation_resource) WHERE key LIKE 'client:%'),
+    operation_resource = (SELECT COALESCE(jsonb_object_agg(key, value), '{}'::jsonb) FROM jsonb_each(operation_resource) WHERE key NOT LIKE 'client:%')
+WHERE EXISTS (SELECT 1 FROM jsonb_object_keys(operation_resource) AS key WHERE key LIKE 'client:%');
+
+ALTER TABLE fhir_endpoints_info ENABLE TRIGGER set_timestamp_fhir_endpoints_info;
+ALTER TABLE fhir_endpoints_info ENABLE TRIGGER add_fhir_endpoint_info_history_trigger;
+
+COMMIT;
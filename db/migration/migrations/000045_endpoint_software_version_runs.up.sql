@@ -0,0 +1,8 @@
+BEGIN;
+
+-- each entry is now a run of consecutive observations of a software version, so an endpoint can have more
+-- than one entry for the same software version. The entries stored before cover every observation of their
+-- software version and are kept as they are.
+DROP INDEX IF EXISTS endpoint_software_versions_unique_idx;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DELETE FROM lanternmq_queues WHERE name IN (
+    'capability-statements-retry',
+    'capability-statements-dead-letters',
+    'endpoints-to-version-responses-dead-letters',
+    'test-queue-retry',
+    'test-queue-dead-letters',
+    'test-endpoints-to-version-responses-dead-letters'
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+INSERT INTO lanternmq_queues (name, max_priority) VALUES
+    ('capability-statements-retry', 0),
+    ('capability-statements-dead-letters', 0),
+    ('endpoints-to-version-responses-dead-letters', 0),
+    ('test-queue-retry', 0),
+    ('test-queue-dead-letters', 0),
+    ('test-endpoints-to-version-responses-dead-letters', 0)
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE lanternmq_messages DROP COLUMN IF EXISTS locked_until;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE lanternmq_messages ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+COMMIT;
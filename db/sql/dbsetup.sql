@@ -167,12 +167,23 @@ CREATE TABLE fhir_endpoints_metadata (
     response_time_seconds   DECIMAL(7,4),
     smart_http_response     INTEGER,
     requested_fhir_version VARCHAR(500) DEFAULT 'None',
+    tls_certificate_fingerprint VARCHAR(500),
+    final_url               VARCHAR(500),
+    redirects               JSONB,
     created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE TABLE validation_results (
-    id                      SERIAL PRIMARY KEY
+    id                      SERIAL PRIMARY KEY,
+    conformance_score       DECIMAL(5,4),
+    ruleset_version         VARCHAR(500),
+    revalidated_from        INT REFERENCES validation_results(id) ON DELETE SET NULL
+);
+
+CREATE TABLE capability_contents (
+    id                      SERIAL PRIMARY KEY,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 CREATE TABLE fhir_endpoints_info (
@@ -193,6 +204,8 @@ CREATE TABLE fhir_endpoints_info (
     metadata_id             INT REFERENCES fhir_endpoints_metadata(id) ON DELETE SET NULL,
     requested_fhir_version  VARCHAR(500),
     capability_fhir_version VARCHAR(500),
+    capability_contents_id  INT REFERENCES capability_contents(id) ON DELETE SET NULL,
+    client_operation_resource JSONB,
     CONSTRAINT fhir_endpoints_info_unique UNIQUE(url, requested_fhir_version)
 );
 
@@ -216,7 +229,9 @@ CREATE TABLE fhir_endpoints_info_history (
     smart_response          JSON, 
     metadata_id             INT REFERENCES fhir_endpoints_metadata(id) ON DELETE SET NULL,
     requested_fhir_version  VARCHAR(500),
-    capability_fhir_version VARCHAR(500)
+    capability_fhir_version VARCHAR(500),
+    capability_contents_id  INT REFERENCES capability_contents(id) ON DELETE SET NULL,
+    client_operation_resource JSONB
 );
 
 CREATE TABLE endpoint_organization (
@@ -259,9 +274,221 @@ CREATE TABLE validations (
     comment                 VARCHAR(500),
     reference               VARCHAR(500),
     implementation_guide    VARCHAR(500),
+    validation_result_id    INT REFERENCES validation_results(id) ON DELETE SET NULL,
+    resource                VARCHAR(500),
+    severity                VARCHAR(500),
+    weight                  DECIMAL(8,4)
+);
+
+CREATE TABLE validation_issues (
+    severity                VARCHAR(500),
+    code                    VARCHAR(500),
+    diagnostics             VARCHAR(500),
+    expression              VARCHAR(500),
     validation_result_id    INT REFERENCES validation_results(id) ON DELETE SET NULL
 );
 
+CREATE TABLE capability_rest (
+    id                      SERIAL PRIMARY KEY,
+    capability_contents_id  INT REFERENCES capability_contents(id) ON DELETE CASCADE,
+    mode                    VARCHAR(500)
+);
+
+CREATE TABLE capability_resources (
+    id                      SERIAL PRIMARY KEY,
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    type                    VARCHAR(500),
+    profile                 VARCHAR(500)
+);
+
+-- capability_resource_id is null for the interactions, search parameters and operations of the rest entry itself
+CREATE TABLE capability_interactions (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    code                    VARCHAR(500)
+);
+
+CREATE TABLE capability_search_params (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    name                    VARCHAR(500),
+    type                    VARCHAR(500),
+    definition              VARCHAR(500)
+);
+
+CREATE TABLE capability_operations (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    name                    VARCHAR(500),
+    definition              VARCHAR(500)
+);
+
+CREATE TABLE capability_search_includes (
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    include                 VARCHAR(500),
+    reverse                 BOOLEAN
+);
+
+CREATE TABLE capability_supported_profiles (
+    capability_resource_id  INT REFERENCES capability_resources(id) ON DELETE CASCADE,
+    profile                 VARCHAR(500)
+);
+
+CREATE TABLE capability_security_services (
+    capability_rest_id      INT REFERENCES capability_rest(id) ON DELETE CASCADE,
+    system                  VARCHAR(500),
+    code                    VARCHAR(500),
+    display                 VARCHAR(500)
+);
+
+CREATE TABLE capability_changes (
+    id                      SERIAL PRIMARY KEY,
+    url                     VARCHAR(500),
+    requested_fhir_version  VARCHAR(500),
+    vendor_id               INT REFERENCES vendors(id) ON DELETE SET NULL,
+    changes                 JSONB,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at            TIMESTAMPTZ
+);
+
+CREATE TABLE endpoint_software_versions (
+    id                      SERIAL PRIMARY KEY,
+    url                     VARCHAR(500),
+    requested_fhir_version  VARCHAR(500),
+    vendor_id               INT REFERENCES vendors(id) ON DELETE SET NULL,
+    software_name           VARCHAR(500),
+    software_version        VARCHAR(500),
+    software_release_date   VARCHAR(500),
+    first_seen              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen               TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE fhir_endpoint_aliases (
+    url                     VARCHAR(500) PRIMARY KEY,
+    canonical_url           VARCHAR(500) NOT NULL,
+    final_url               VARCHAR(500),
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE fhir_endpoint_schedules (
+    url                     VARCHAR(500) PRIMARY KEY,
+    tier                    VARCHAR(500) NOT NULL,
+    next_query_at           TIMESTAMPTZ NOT NULL,
+    last_queried_at         TIMESTAMPTZ,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE query_cycles (
+    id                      SERIAL PRIMARY KEY,
+    status                  VARCHAR(500) NOT NULL,
+    endpoint_count          INTEGER NOT NULL DEFAULT 0,
+    versions_received       INTEGER NOT NULL DEFAULT 0,
+    enqueued                INTEGER NOT NULL DEFAULT 0,
+    queried                 INTEGER NOT NULL DEFAULT 0,
+    stored                  INTEGER NOT NULL DEFAULT 0,
+    failed                  INTEGER NOT NULL DEFAULT 0,
+    started_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at            TIMESTAMPTZ,
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE lanternmq_queues (
+    name                    VARCHAR(500) PRIMARY KEY,
+    max_priority            SMALLINT NOT NULL DEFAULT 0,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE lanternmq_messages (
+    id                      BIGSERIAL PRIMARY KEY,
+    queue                   VARCHAR(500) NOT NULL REFERENCES lanternmq_queues(name) ON DELETE CASCADE,
+    body                    BYTEA NOT NULL,
+    priority                SMALLINT NOT NULL DEFAULT 0,
+    attempts                INTEGER NOT NULL DEFAULT 0,
+    error                   TEXT,
+    return_queue            VARCHAR(500),
+    available_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until            TIMESTAMPTZ,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE lanternmq_exchanges (
+    name                    VARCHAR(500) PRIMARY KEY,
+    type                    VARCHAR(500) NOT NULL,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE lanternmq_bindings (
+    exchange                VARCHAR(500) NOT NULL REFERENCES lanternmq_exchanges(name) ON DELETE CASCADE,
+    queue                   VARCHAR(500) NOT NULL REFERENCES lanternmq_queues(name) ON DELETE CASCADE,
+    routing_key             VARCHAR(500) NOT NULL,
+    PRIMARY KEY (exchange, queue, routing_key)
+);
+
+INSERT INTO lanternmq_queues (name, max_priority) VALUES
+    ('capability-statements', 0),
+    ('capability-statements-retry', 0),
+    ('capability-statements-dead-letters', 0),
+    ('endpoints-to-capability', 2),
+    ('version-responses', 2),
+    ('endpoints-to-version-responses', 0),
+    ('endpoints-to-version-responses-retry', 0),
+    ('endpoints-to-version-responses-dead-letters', 0),
+    ('test-queue', 0),
+    ('test-queue-retry', 0),
+    ('test-queue-dead-letters', 0),
+    ('test-endpoints-to-capability', 2),
+    ('test-version-responses', 2),
+    ('test-endpoints-to-version-responses', 0),
+    ('test-endpoints-to-version-responses-retry', 0),
+    ('test-endpoints-to-version-responses-dead-letters', 0);
+
+INSERT INTO lanternmq_exchanges (name, type) VALUES
+    ('capability-changes', 'topic'),
+    ('test-capability-changes', 'topic');
+
+CREATE TABLE quarantined_messages (
+    id                      SERIAL PRIMARY KEY,
+    source                  VARCHAR(500),
+    payload                 BYTEA,
+    error                   TEXT,
+    stack                   TEXT,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE querier_instances (
+    id                      VARCHAR(500) PRIMARY KEY,
+    hostname                VARCHAR(500),
+    num_workers             INTEGER,
+    started_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_heartbeat          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE host_rate_limits (
+    host                    VARCHAR(500) PRIMARY KEY,
+    next_request_at         TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE deployment_groups (
+    id                          SERIAL PRIMARY KEY,
+    fingerprint                 VARCHAR(500) UNIQUE,
+    capability_statement_hash   VARCHAR(500),
+    software_name               VARCHAR(500),
+    software_version            VARCHAR(500),
+    implementation_host         VARCHAR(500),
+    tls_certificate_fingerprint VARCHAR(500),
+    supported_versions          VARCHAR(500)[],
+    created_at                  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE deployment_group_endpoints (
+    url                     VARCHAR(500) PRIMARY KEY,
+    deployment_group_id     INT REFERENCES deployment_groups(id) ON DELETE CASCADE,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 
 CREATE TRIGGER set_timestamp_fhir_endpoints
 BEFORE UPDATE ON fhir_endpoints
@@ -340,11 +567,13 @@ SELECT endpts.url, endpts.list_source, endpts.organization_names AS endpoint_nam
     endpts_info.capability_statement->'format' AS FORMAT,
     endpts_info.capability_statement->>'kind' AS KIND,
     endpts_info.updated_at AS INFO_UPDATED, endpts_info.created_at AS INFO_CREATED,
-    endpts_info.requested_fhir_version, endpts_metadata.availability
+    endpts_info.requested_fhir_version, endpts_metadata.availability,
+    COALESCE(aliases.canonical_url, endpts.url) AS canonical_url
 FROM fhir_endpoints AS endpts
 LEFT JOIN fhir_endpoints_info AS endpts_info ON endpts.url = endpts_info.url
 LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
-LEFT JOIN vendors ON endpts_info.vendor_id = vendors.id;
+LEFT JOIN vendors ON endpts_info.vendor_id = vendors.id
+LEFT JOIN fhir_endpoint_aliases AS aliases ON endpts.url = aliases.url;
 
 CREATE or REPLACE VIEW organization_location AS
     SELECT endpts.url, endpts.organization_names AS endpoint_names, endpts_info.capability_fhir_version AS FHIR_VERSION, 
@@ -358,6 +587,20 @@ LEFT JOIN vendors ON endpts_info.vendor_id = vendors.id
 LEFT JOIN npi_organizations AS orgs ON links.organization_npi_id = orgs.npi_id
 WHERE links.confidence > .97 AND orgs.Location->>'zipcode' IS NOT null;
 
+CREATE or REPLACE VIEW deployment_group_metrics AS
+SELECT groups.id AS deployment_group_id, groups.software_name, groups.software_version, groups.implementation_host,
+    COUNT(DISTINCT COALESCE(aliases.canonical_url, members.url)) AS endpoint_count,
+    AVG(endpts_metadata.availability) AS availability,
+    AVG(endpts_metadata.response_time_seconds) AS response_time_seconds,
+    AVG(validation_results.conformance_score) AS conformance_score
+FROM deployment_groups AS groups
+JOIN deployment_group_endpoints AS members ON members.deployment_group_id = groups.id
+LEFT JOIN fhir_endpoint_aliases AS aliases ON members.url = aliases.url
+LEFT JOIN fhir_endpoints_info AS endpts_info ON members.url = endpts_info.url AND endpts_info.requested_fhir_version = 'None'
+LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
+LEFT JOIN validation_results ON endpts_info.validation_result_id = validation_results.id
+GROUP BY groups.id;
+
 CREATE INDEX fhir_endpoints_url_idx ON fhir_endpoints (url);
 CREATE INDEX fhir_endpoints_info_url_idx ON fhir_endpoints_info (url);
 CREATE INDEX fhir_endpoints_info_history_url_idx ON fhir_endpoints_info_history (url);
@@ -409,6 +652,27 @@ CREATE INDEX info_metadata_id_idx ON fhir_endpoints_info (metadata_id);
 CREATE INDEX info_history_metadata_id_idx ON fhir_endpoints_info_history (metadata_id);
 CREATE INDEX metadata_id_idx ON fhir_endpoints_metadata (id);
 
+CREATE INDEX info_capability_contents_id_idx ON fhir_endpoints_info (capability_contents_id);
+CREATE INDEX info_history_capability_contents_id_idx ON fhir_endpoints_info_history (capability_contents_id);
+CREATE INDEX capability_rest_contents_id_idx ON capability_rest (capability_contents_id);
+CREATE INDEX capability_resources_rest_id_idx ON capability_resources (capability_rest_id);
+CREATE INDEX capability_resources_type_idx ON capability_resources (type);
+CREATE INDEX capability_interactions_resource_id_idx ON capability_interactions (capability_resource_id);
+CREATE INDEX capability_search_params_resource_id_idx ON capability_search_params (capability_resource_id);
+CREATE INDEX capability_operations_resource_id_idx ON capability_operations (capability_resource_id);
+CREATE INDEX capability_search_includes_resource_id_idx ON capability_search_includes (capability_resource_id);
+CREATE INDEX capability_supported_profiles_resource_id_idx ON capability_supported_profiles (capability_resource_id);
+CREATE INDEX capability_security_services_rest_id_idx ON capability_security_services (capability_rest_id);
+CREATE INDEX capability_changes_url_idx ON capability_changes (url);
+CREATE INDEX capability_changes_unpublished_idx ON capability_changes (id) WHERE published_at IS NULL;
+CREATE INDEX endpoint_software_versions_url_idx ON endpoint_software_versions (url, requested_fhir_version);
+CREATE INDEX endpoint_software_versions_vendor_idx ON endpoint_software_versions (vendor_id);
+CREATE INDEX deployment_group_endpoints_group_idx ON deployment_group_endpoints (deployment_group_id);
+CREATE INDEX fhir_endpoint_aliases_canonical_url_idx ON fhir_endpoint_aliases (canonical_url);
+CREATE INDEX fhir_endpoint_schedules_next_query_at_idx ON fhir_endpoint_schedules (next_query_at);
+CREATE INDEX lanternmq_messages_queue_idx ON lanternmq_messages (queue, available_at, id);
+CREATE INDEX quarantined_messages_source_idx ON quarantined_messages (source);
+
 CREATE INDEX healthit_product_name_version_idx ON healthit_products (name, version);
 CREATE INDEX metadata_response_time_idx ON fhir_endpoints_metadata(response_time_seconds);
 CREATE INDEX metadata_requested_version_idx ON fhir_endpoints_metadata(requested_fhir_version);
@@ -7,6 +7,11 @@ require (
 	github.com/onc-healthit/lantern-back-end/endpointmanager v0.0.0-20221019221955-c3caa901f6a4
 	github.com/onc-healthit/lantern-back-end/lanternmq v0.0.0-20221019221955-c3caa901f6a4
 	github.com/spf13/viper v1.10.1
-	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 	github.com/stretchr/testify v1.7.0
 )
+
+replace github.com/onc-healthit/lantern-back-end/capabilityreceiver => ../capabilityreceiver
+
+replace github.com/onc-healthit/lantern-back-end/endpointmanager => ../endpointmanager
+
+replace github.com/onc-healthit/lantern-back-end/lanternmq => ../lanternmq
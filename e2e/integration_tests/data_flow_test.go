@@ -29,8 +29,8 @@ import (
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	aq "github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 	"github.com/spf13/viper"
-	"github.com/streadway/amqp"
 	Assert "github.com/stretchr/testify/assert"
 )
 
@@ -46,8 +46,8 @@ var endptList = "./testdata/TestEndpointSources.json"
 var shortEndptList = "./testdata/TestEndpointSources_1.json"
 var LanternEndptList = "./testdata/TestLanternEndpointSources.json"
 
-var conn *amqp.Connection
-var channel *amqp.Channel
+var conn lanternmq.MessageQueue
+var channel lanternmq.ChannelID
 
 func TestMain(m *testing.M) {
 	config.SetupConfigForTests()
@@ -66,7 +66,6 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 
 	teardown(store.DB)
-	channel.Close()
 	conn.Close()
 
 	os.Exit(code)
@@ -119,15 +118,14 @@ func testQueueSetup() {
 		log.Fatal("Check Resources Error: ", err.Error())
 	}
 
-	fmt.Printf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
 	// setup specific queue info so we can test what's in the queue
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
-	conn, err = amqp.Dial(s)
+	conn = &rabbitmq.MessageQueue{}
+	err = conn.Connect(qUser, qPassword, qHost, qPort)
 	if err != nil {
 		log.Fatal("Database Connection Error: ", err.Error())
 	}
 
-	channel, err = conn.Channel()
+	channel, err = conn.CreateChannel()
 	if err != nil {
 		log.Fatal("Channel Connection Error: ", err.Error())
 	}
@@ -138,18 +136,18 @@ func sendEndpointsOverQueue(ctx context.Context, t *testing.T, queueName string,
 	wg.Add(1)
 	errs := make(chan error)
 
-	go se.GetEnptsAndSend(ctx, &wg, queueName, 10, store, &mq, &chID, errs)
+	go se.GetEnptsAndSend(ctx, &wg, queueName, se.ScheduleFromConfig(), store, &mq, &chID, errs)
 	time.Sleep(30 * time.Second)
 }
 
 func queueIsEmpty(t *testing.T, queueName string) {
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 0, "should be no messages in queue.")
 }
 
-func checkCleanQueue(t *testing.T, queueName string, channel *amqp.Channel) {
-	err := aq.CleanQueue(queueName, channel)
+func checkCleanQueue(t *testing.T, queueName string) {
+	err := aq.CleanQueue(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 }
 
@@ -426,7 +424,7 @@ func Test_GetCHPLProducts(t *testing.T) {
 func Test_RetrieveCapabilityStatements(t *testing.T) {
 	var err error
 	queueIsEmpty(t, testQName)
-	defer checkCleanQueue(t, testQName, channel)
+	defer checkCleanQueue(t, testQName)
 	capQName := viper.GetString("versionsquery_qname")
 
 	var mq lanternmq.MessageQueue
@@ -443,7 +441,7 @@ func Test_RetrieveCapabilityStatements(t *testing.T) {
 	mq, chID, err = aq.ConnectToQueue(mq, chID, testQName)
 	defer mq.Close()
 	ctx, _ = context.WithTimeout(context.Background(), 30*time.Second)
-	go capabilityhandler.ReceiveCapabilityStatements(ctx, store, mq, chID, testQName)
+	go capabilityhandler.ReceiveCapabilityStatements(ctx, store, mq, chID, testQName, nil, nil, "", lanternmq.RetryPolicy{MaxAttempts: 1})
 	select {
 	case <-ctx.Done():
 		return
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	se "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/sendendpoints"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -23,17 +26,29 @@ func main() {
 
 	queryInterval := viper.GetInt("capquery_qryintvl")
 
-	// Divide query interval (in seconds) by an average of 1.5 seconds per request to get the maximum number of endpoints that can be queried within query interval
-	maxEndpoints := int(math.Floor(float64(queryInterval*60) / float64(1.5)))
+	// Divide query interval (in seconds) by an average of 1.5 seconds per request to get the maximum number of queries that can be made within query interval
+	maxQueries := math.Floor(float64(queryInterval*60) / float64(1.5))
 
-	var endpointTotal int
-	endpointCountQuery := "SELECT COUNT(*) from fhir_endpoints;"
-	err = store.DB.QueryRow(endpointCountQuery).Scan(&endpointTotal)
+	// Endpoints are queried more or less often than once per query interval depending on their schedule tier
+	schedule := se.ScheduleFromConfig()
+	infos, err := store.GetFHIREndpointScheduleInfos(context.Background())
 	helpers.FailOnError("", err)
+	queryTotal := schedule.QueriesPerInterval(infos, time.Now())
 
-	if endpointTotal >= maxEndpoints {
-		querierScale := int(math.Ceil(float64(endpointTotal) / float64(maxEndpoints)))
-		queryIntervalIncrease := int(math.Ceil(float64(float64(endpointTotal)*float64(1.5)) / float64(60)))
-		log.Warn(fmt.Sprintf("The current number of endpoints (%d) exceeds the maximum amount of endpoints that can be queried within the given Lantern query interval (%d minutes). Make sure to either scale out the capability querier service as defined in the README, or define a longer query threshold. With current query interval make sure you have at least %d querier instances, or with one querier instance make sure you increase query interval to at least %d minutes", endpointTotal, queryInterval, querierScale, queryIntervalIncrease))
+	// Each running querier instance can make the maximum number of queries, so the instances whose heartbeat is
+	// recent enough that they have not been removed as stale share the load
+	heartbeatInterval := time.Duration(viper.GetInt("capquery_heartbeat_intvl")) * time.Second
+	instances, err := store.GetQuerierInstances(context.Background(), time.Now().Add(-3*heartbeatInterval))
+	helpers.FailOnError("", err)
+	numInstances := len(instances)
+	if numInstances == 0 {
+		log.Info("No capability querier instances are running, so the recommendation assumes that one instance will be")
+		numInstances = 1
+	}
+
+	if queryTotal >= maxQueries*float64(numInstances) {
+		querierScale := int(math.Ceil(queryTotal / maxQueries))
+		queryIntervalIncrease := int(math.Ceil(queryTotal * float64(1.5) / float64(60) / float64(numInstances)))
+		log.Warn(fmt.Sprintf("The current number of endpoints (%d) needs about %d queries within the given Lantern query interval (%d minutes), which exceeds the maximum amount of queries that the %d running querier instances can make within the interval. Make sure to either scale out the capability querier service as defined in the README, query down endpoints less often, or define a longer query threshold. With current query interval make sure you have at least %d querier instances, or with %d querier instances make sure you increase query interval to at least %d minutes", len(infos), int(math.Ceil(queryTotal)), queryInterval, numInstances, querierScale, numInstances, queryIntervalIncrease))
 	}
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"strconv"
 	"time"
@@ -14,7 +13,6 @@ import (
 	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"github.com/streadway/amqp"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 )
@@ -29,8 +27,6 @@ func main() {
 	err := config.SetupConfig()
 	helpers.FailOnError("Error setting up config", err)
 
-	var channel *amqp.Channel
-
 	capQName := viper.GetString("endptinfo_capquery_qname")
 	qUser := viper.GetString("quser")
 	qPassword := viper.GetString("qpassword")
@@ -38,15 +34,12 @@ func main() {
 	qPort := viper.GetString("qport")
 
 	// setup specific queue info so we can test what's in the queue
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
-	conn, err := amqp.Dial(s)
-	helpers.FailOnError("", err)
-
-	channel, err = conn.Channel()
+	mq, ch, err := accessqueue.ConnectToServerAndQueue(qUser, qPassword, qHost, qPort, capQName)
 	helpers.FailOnError("", err)
 
-	count, err := accessqueue.QueueCount(capQName, channel)
+	count, err := accessqueue.QueueCount(capQName, mq, ch)
 	helpers.FailOnError("", err)
+	mq.Close()
 
 	if count != 0 {
 		log.Fatalf("There are %d messages in the queue. Queue must be empty to run the endpoint populator.", count)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/queryendpoint"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// localAddress returns the given address to listen on, using localhost if the address does not give a host, so
+// that on demand queries are not served to other machines unless a host such as 0.0.0.0 is given
+func localAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != "" {
+		return address
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+func main() {
+	urlList := flag.String("urls", "", "comma separated list of endpoint URLs to query")
+	listSource := flag.String("source", "", "list source whose endpoints should be queried")
+	address := flag.String("serve", "", "address to serve on demand queries from, eg. ':8090', instead of querying once. Addresses without a host are only served on localhost")
+	timeout := flag.Int("timeout", 10, "how long to wait for the endpoints to be queried, in minutes")
+	flag.Parse()
+
+	if *urlList == "" && *listSource == "" && *address == "" {
+		log.Fatal("ERROR: One of the -urls, -source or -serve flags is required")
+	}
+
+	err := config.SetupConfig()
+	helpers.FailOnError("", err)
+
+	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	helpers.FailOnError("", err)
+	log.Info("Successfully connected to DB!")
+
+	// Set up the queue for sending messages to capabilityquerier
+	capQName := viper.GetString("versionsquery_qname")
+	mq, channelID, err := accessqueue.ConnectToServerAndQueue(viper.GetString("quser"), viper.GetString("qpassword"), viper.GetString("qhost"), viper.GetString("qport"), capQName)
+	helpers.FailOnError("", err)
+	defer mq.Close()
+	err = accessqueue.ConfirmPublishes(mq, channelID, viper.GetInt("qconfirm_timeout"))
+	helpers.FailOnError("", err)
+	log.Info("Successfully connected to capabilityquerier Queue!")
+
+	waitTime := time.Duration(*timeout) * time.Minute
+
+	if *address != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/query", queryendpoint.Handler(store, &mq, &channelID, capQName, waitTime))
+		// responses are only written once the query cycle completes or times out, so writes are given longer
+		// than the wait time
+		server := &http.Server{
+			Addr:              localAddress(*address),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      waitTime + 2*time.Minute,
+			IdleTimeout:       2 * time.Minute,
+		}
+		log.Infof("Serving on demand queries at %s/query", server.Addr)
+		err = server.ListenAndServe()
+		helpers.FailOnError("", err)
+		return
+	}
+
+	ctx := context.Background()
+	var urls []string
+	for _, url := range strings.Split(*urlList, ",") {
+		url = strings.TrimSpace(url)
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if *listSource != "" {
+		sourceURLs, err := queryendpoint.ListSourceURLs(ctx, store, *listSource)
+		helpers.FailOnError("", err)
+		urls = queryendpoint.MergeURLs(urls, sourceURLs)
+	}
+
+	unknown, err := queryendpoint.UnknownURLs(ctx, store, urls)
+	helpers.FailOnError("", err)
+	if len(unknown) > 0 {
+		log.Fatalf("ERROR: Unknown endpoints: %s", strings.Join(unknown, ", "))
+	}
+
+	response, err := queryendpoint.QueryEndpoints(ctx, store, &mq, &channelID, capQName, urls, waitTime)
+	helpers.FailOnError("", err)
+
+	output, err := json.MarshalIndent(response, "", "  ")
+	helpers.FailOnError("", err)
+	fmt.Println(string(output))
+}
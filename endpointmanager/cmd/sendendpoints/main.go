@@ -4,12 +4,16 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
 	se "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/sendendpoints"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/metrics"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -22,12 +26,22 @@ func main() {
 	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
 	helpers.FailOnError("", err)
 	log.Info("Successfully connected to DB!")
+	metrics.AddHealthCheck("database", store.DB.Ping)
 
 	// Set up the queue for sending messages to capabilityquerier
 	capQName := viper.GetString("versionsquery_qname")
 	mq, channelID, err := accessqueue.ConnectToServerAndQueue(viper.GetString("quser"), viper.GetString("qpassword"), viper.GetString("qhost"), viper.GetString("qport"), capQName)
 	helpers.FailOnError("", err)
+	err = accessqueue.ConfirmPublishes(mq, channelID, viper.GetInt("qconfirm_timeout"))
+	helpers.FailOnError("", err)
 	log.Info("Successfully connected to capabilityquerier Queue!")
+	mq = lanternmq.Instrument(mq, metrics.QueueHooks())
+	metrics.WatchQueue(mq, channelID, capQName)
+
+	go func() {
+		err := metrics.Serve(viper.GetString("metrics_addr"))
+		log.Warnf("stopped serving metrics: %s", err.Error())
+	}()
 
 	errs := make(chan error)
 
@@ -37,16 +51,31 @@ func main() {
 		helpers.FailOnError("Failed to create empty JSON export file", err)
 	}
 
-	// Infinite query loop
+	// Query loop, which runs until SIGINT or SIGTERM is received
 	var wg sync.WaitGroup
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelOnSignal(cancel)
 	wg.Add(1)
-	capInterval := viper.GetInt("capquery_qryintvl")
-	go se.GetEnptsAndSend(ctx, &wg, capQName, capInterval, store, &mq, &channelID, errs)
+	schedule := se.ScheduleFromConfig()
+	go se.GetEnptsAndSend(ctx, &wg, capQName, schedule, store, &mq, &channelID, errs)
 
-	for elem := range errs {
-		log.Warn(elem)
-	}
+	go func() {
+		for elem := range errs {
+			log.Warn(elem)
+		}
+	}()
 
 	wg.Wait()
 }
+
+// cancelOnSignal cancels the context of the query loop when SIGINT or SIGTERM is received
+func cancelOnSignal(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		log.Infof("received %s, stopping sending endpoints", sig)
+		cancel()
+	}()
+}
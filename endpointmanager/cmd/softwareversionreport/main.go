@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/config"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/helpers"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/softwareversionreport"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// parseDate parses the given command line date, returning the default time if the date is empty
+func parseDate(date string, defaultTime time.Time) time.Time {
+	if date == "" {
+		return defaultTime
+	}
+	parsed, err := time.Parse("2006-01-02", date)
+	helpers.FailOnError(fmt.Sprintf("Invalid date %s, dates must have the format YYYY-MM-DD. Error:", date), err)
+	return parsed
+}
+
+func main() {
+	reportFile := flag.String("file", "", "name of the CSV file to write the report to (required)")
+	interval := flag.String("interval", "", "length of each period of the report, either day, week or month (default month)")
+	startDate := flag.String("start", "", "date the report starts from, formatted as 2021-01-31 (default one year before the end date)")
+	endDate := flag.String("end", "", "date the report ends on, formatted as 2021-01-31 (default today)")
+	flag.Parse()
+
+	if *reportFile == "" {
+		log.Fatalf("ERROR: Missing the report file name. Usage: softwareversionreport -file=<file> [-interval=<day/week/month>] [-start=<date>] [-end=<date>]")
+	}
+	if *interval == "" {
+		*interval = "month"
+	}
+	end := parseDate(*endDate, time.Now())
+	start := parseDate(*startDate, end.AddDate(-1, 0, 0))
+	if end.Before(start) {
+		log.Fatalf("ERROR: The end date %s is before the start date %s", end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+
+	err := config.SetupConfig()
+	helpers.FailOnError("", err)
+
+	store, err := postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	helpers.FailOnError("", err)
+	ctx := context.Background()
+	log.Info("Successfully connected to DB!")
+
+	err = softwareversionreport.CreateSoftwareVersionReport(ctx, store, *reportFile, *interval, start, end)
+	helpers.FailOnError("", err)
+}
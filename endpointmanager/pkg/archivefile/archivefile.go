@@ -68,7 +68,6 @@ type historyArgs struct {
 	dateStart            string
 	dateEnd              string
 	store                *postgresql.Store
-	result               chan Result
 }
 
 // historyEntry is the format of the data received from the history table for the given URL
@@ -115,7 +114,6 @@ func CreateArchive(ctx context.Context,
 		return nil, fmt.Errorf("ERROR getting data from fhir_endpoints: %s", err)
 	}
 
-	totalEntries := 0
 	urls_fhir_version := make(map[string][]string)
 	allData := make(map[string]map[string]totalSummary)
 	defer rows.Close()
@@ -148,33 +146,33 @@ func CreateArchive(ctx context.Context,
 			val.ListSource = append(val.ListSource, listSource)
 			val.OrganizationNames = append(val.OrganizationNames, entry.OrganizationNames...)
 			allData[entry.URL][entry.RequestedFhirVersion] = val
-			totalEntries++
 		} else {
 			entry.ListSource = []string{listSource}
 			allData[entry.URL][entry.RequestedFhirVersion] = entry
 			urls_fhir_version[entry.URL] = append(urls_fhir_version[entry.URL], entry.RequestedFhirVersion)
-			totalEntries++
 		}
 	}
 
 	// Start workers
-	errs := make(chan error)
 	allWorkers := workers.NewWorkers()
-	err = allWorkers.Start(ctx, numWorkers, errs)
+	err = allWorkers.Start(ctx, numWorkers, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Error from starting workers. Error: %s", err)
 	}
+	defer allWorkers.Stop()
 
 	// Get history data using workers
-	resultCh := make(chan Result)
-	go createJobs(ctx, resultCh, urls_fhir_version, dateStart, dateEnd, "history", workerDur, store, allWorkers)
+	results := createJobs(ctx, urls_fhir_version, dateStart, dateEnd, "history", workerDur, store, allWorkers)
 
 	// Add the results from createJobs to allData
-	count := 0
-	for res := range resultCh {
+	var resultErr error
+	for _, res := range waitForResults(ctx, results) {
 		u, ok := allData[res.URL][res.RequestedFhirVersion]
 		if !ok {
-			return nil, fmt.Errorf("The URL %s does not exist in the fhir_endpoints tables", res.URL)
+			if resultErr == nil {
+				resultErr = fmt.Errorf("The URL %s does not exist in the fhir_endpoints tables", res.URL)
+			}
+			continue
 		}
 		u.NumberOfUpdates = res.Summary.NumberOfUpdates
 		u.Updated = res.Summary.Updated
@@ -183,10 +181,9 @@ func CreateArchive(ctx context.Context,
 		u.TLSVersion = res.Summary.TLSVersion
 		u.MIMETypes = res.Summary.MIMETypes
 		allData[res.URL][res.RequestedFhirVersion] = u
-		if count >= totalEntries-1 {
-			close(resultCh)
-		}
-		count++
+	}
+	if resultErr != nil {
+		return nil, resultErr
 	}
 
 	// Get vendor information separately so the endpoints that don't have vendor information aren't
@@ -238,25 +235,25 @@ func CreateArchive(ctx context.Context,
 	}
 
 	// Get history data using workers
-	metaResultCh := make(chan Result)
-	go createJobs(ctx, metaResultCh, urls_fhir_version, dateStart, dateEnd, "metadata", workerDur, store, allWorkers)
+	metaResults := createJobs(ctx, urls_fhir_version, dateStart, dateEnd, "metadata", workerDur, store, allWorkers)
 
 	// Add the results from metadata to allData
-	count = 0
-	for res := range metaResultCh {
+	for _, res := range waitForResults(ctx, metaResults) {
 		u, ok := allData[res.URL][res.RequestedFhirVersion]
 		if !ok {
-			return nil, fmt.Errorf("The URL %s does not exist in the fhir_endpoints tables", res.URL)
+			if resultErr == nil {
+				resultErr = fmt.Errorf("The URL %s does not exist in the fhir_endpoints tables", res.URL)
+			}
+			continue
 		}
 		u.ResponseTimeSecond = res.Summary.ResponseTimeSecond
 		u.HTTPResponse = res.Summary.HTTPResponse
 		u.SmartHTTPResponse = res.Summary.SmartHTTPResponse
 		u.Errors = res.Summary.Errors
 		allData[res.URL][res.RequestedFhirVersion] = u
-		if count == totalEntries-1 {
-			close(metaResultCh)
-		}
-		count++
+	}
+	if resultErr != nil {
+		return nil, resultErr
 	}
 
 	var entries []totalSummary
@@ -281,16 +278,16 @@ func makeDefaultMap() map[string]interface{} {
 }
 
 // creates jobs for the workers so that each worker gets the history data
-// for a specified url
+// for a specified url, and returns the workers' results for the jobs
 func createJobs(ctx context.Context,
-	ch chan Result,
 	urls_fhir_version map[string][]string,
 	dateStart string,
 	dateEnd string,
 	jobType string,
 	workerDur int,
 	store *postgresql.Store,
-	allWorkers *workers.Workers) {
+	allWorkers *workers.Workers) []*workers.Result {
+	var results []*workers.Result
 	for url, requested_versions := range urls_fhir_version {
 		for index := range requested_versions {
 			jobArgs := make(map[string]interface{})
@@ -300,7 +297,6 @@ func createJobs(ctx context.Context,
 				dateStart:            dateStart,
 				dateEnd:              dateEnd,
 				store:                store,
-				result:               ch,
 			}
 
 			job := workers.Job{
@@ -310,22 +306,43 @@ func createJobs(ctx context.Context,
 			}
 
 			if jobType == "history" {
-				job.Handler = getHistory
+				job.ValueHandler = getHistory
 			} else {
-				job.Handler = getMetadata
+				job.ValueHandler = getMetadata
 			}
 
-			err := allWorkers.Add(&job)
+			result, err := allWorkers.Add(ctx, &job)
 			if err != nil {
 				log.Warnf("Error while adding job for getting history for URL %s, %s", url, err)
+				continue
 			}
+			results = append(results, result)
 		}
 	}
+
+	return results
+}
+
+// waitForResults waits for each of the given jobs to finish and returns the Result each job produced.
+// Jobs that failed or were not run are logged and left out.
+func waitForResults(ctx context.Context, results []*workers.Result) []Result {
+	var values []Result
+	for _, result := range results {
+		err := result.Wait(ctx)
+		if err != nil {
+			log.Warnf("Error while getting the result of a job: %s", err)
+			continue
+		}
+		if value, ok := result.Value().(Result); ok {
+			values = append(values, value)
+		}
+	}
+	return values
 }
 
 // getHistory retrieves the data from the history table for a specific URL and formats it
-// as a totalSummary object before sending it back over the channel
-func getHistory(ctx context.Context, args *map[string]interface{}) error {
+// as a totalSummary object, which is returned as the job's Result
+func getHistory(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	returnResult := totalSummary{
 		NumberOfUpdates: 0,
 		Updated:         makeDefaultMap(),
@@ -337,7 +354,7 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 
 	ha, ok := (*args)["historyArgs"].(historyArgs)
 	if !ok {
-		return fmt.Errorf("unable to cast arguments to type historyArgs")
+		return nil, fmt.Errorf("unable to cast arguments to type historyArgs")
 	}
 
 	// Get all rows in the history table between given dates
@@ -346,13 +363,11 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 	historyRows, err := ha.store.DB.QueryContext(ctx, historyQuery, ha.fhirURL, ha.requestedFhirVersion)
 	if err != nil {
 		log.Warnf("Failed getting the history rows for URL %s with requested version %s. Error: %s", ha.fhirURL, ha.requestedFhirVersion, err)
-		result := Result{
+		return Result{
 			URL:                  ha.fhirURL,
 			RequestedFhirVersion: ha.requestedFhirVersion,
 			Summary:              returnResult,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	defer historyRows.Close()
@@ -371,13 +386,11 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 			pq.Array(&e.MIMETypes))
 		if err != nil {
 			log.Warnf("Error while scanning the rows of the history table for URL %s with requested version %s. Error: %s", ha.fhirURL, ha.requestedFhirVersion, err)
-			result := Result{
+			return Result{
 				URL:                  ha.fhirURL,
 				RequestedFhirVersion: ha.requestedFhirVersion,
 				Summary:              returnResult,
-			}
-			ha.result <- result
-			return nil
+			}, nil
 		}
 
 		if fhirVersion == "" {
@@ -420,24 +433,22 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 		}
 	}
 
-	result := Result{
+	return Result{
 		URL:                  ha.fhirURL,
 		RequestedFhirVersion: ha.requestedFhirVersion,
 		Summary:              returnResult,
-	}
-	ha.result <- result
-	return nil
+	}, nil
 }
 
 // getMetadata retrieves the data from the metadata table for a specific URL and formats it
-// as a totalSummary object before sending it back over the channel
-func getMetadata(ctx context.Context, args *map[string]interface{}) error {
+// as a totalSummary object, which is returned as the job's Result
+func getMetadata(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	var returnResult totalSummary
 	var history []metadataEntry
 
 	ha, ok := (*args)["historyArgs"].(historyArgs)
 	if !ok {
-		return fmt.Errorf("unable to cast arguments to type historyArgs")
+		return nil, fmt.Errorf("unable to cast arguments to type historyArgs")
 	}
 
 	// Get all rows in the history table between given dates
@@ -446,13 +457,11 @@ func getMetadata(ctx context.Context, args *map[string]interface{}) error {
 	metadataRows, err := ha.store.DB.QueryContext(ctx, metadataQuery, ha.fhirURL, ha.requestedFhirVersion)
 	if err != nil {
 		log.Warnf("Failed getting the metadata rows for URL %s with requested version %s. Error: %s", ha.fhirURL, ha.requestedFhirVersion, err)
-		result := Result{
+		return Result{
 			URL:                  ha.fhirURL,
 			RequestedFhirVersion: ha.requestedFhirVersion,
 			Summary:              returnResult,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
 	defer metadataRows.Close()
@@ -469,13 +478,11 @@ func getMetadata(ctx context.Context, args *map[string]interface{}) error {
 			&e.Errors)
 		if err != nil {
 			log.Warnf("Error while scanning the rows of the metadata table for URL %s with requested version %s. Error: %s", ha.fhirURL, ha.requestedFhirVersion, err)
-			result := Result{
+			return Result{
 				URL:                  ha.fhirURL,
 				RequestedFhirVersion: ha.requestedFhirVersion,
 				Summary:              returnResult,
-			}
-			ha.result <- result
-			return nil
+			}, nil
 		}
 
 		history = append(history, e)
@@ -553,11 +560,9 @@ func getMetadata(ctx context.Context, args *map[string]interface{}) error {
 		returnResult.Errors = errorArray
 	}
 
-	result := Result{
+	return Result{
 		URL:                  ha.fhirURL,
 		RequestedFhirVersion: ha.requestedFhirVersion,
 		Summary:              returnResult,
-	}
-	ha.result <- result
-	return nil
+	}, nil
 }
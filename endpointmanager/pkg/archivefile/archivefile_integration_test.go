@@ -325,7 +325,6 @@ func Test_getHistory(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 1, fmt.Sprintf("Should have got 1, intead got %d", count))
 
-	resultCh2 := make(chan Result)
 	jobArgs2 := make(map[string]interface{})
 	jobArgs2["historyArgs"] = historyArgs{
 		fhirURL:   "http://example.com/DTSU2/",
@@ -333,17 +332,14 @@ func Test_getHistory(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh2,
 	}
 
-	go getHistory(ctx, &jobArgs2)
-
-	for res := range resultCh2 {
-		th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Summary.NumberOfUpdates == 1, fmt.Sprintf("1 update should have been registered, instead there were %d updates", res.Summary.NumberOfUpdates))
-		th.Assert(t, res.Summary.FHIRVersion["first"] == nil, fmt.Sprintf("FHIR Version first should have been nil, is instead %s", res.Summary.FHIRVersion["first"]))
-		close(resultCh2)
-	}
+	value2, err := getHistory(ctx, &jobArgs2)
+	th.Assert(t, err == nil, err)
+	res2 := value2.(Result)
+	th.Assert(t, res2.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res2.URL))
+	th.Assert(t, res2.Summary.NumberOfUpdates == 1, fmt.Sprintf("1 update should have been registered, instead there were %d updates", res2.Summary.NumberOfUpdates))
+	th.Assert(t, res2.Summary.FHIRVersion["first"] == nil, fmt.Sprintf("FHIR Version first should have been nil, is instead %s", res2.Summary.FHIRVersion["first"]))
 
 	// Base Case
 
@@ -354,7 +350,6 @@ func Test_getHistory(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 2, fmt.Sprintf("Should have got 2, intead got %d", count))
 
-	resultCh := make(chan Result)
 	jobArgs := make(map[string]interface{})
 	jobArgs["historyArgs"] = historyArgs{
 		fhirURL:   "http://example.com/DTSU2/",
@@ -362,19 +357,16 @@ func Test_getHistory(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh,
 	}
 
-	go getHistory(ctx, &jobArgs)
-
-	for res := range resultCh {
-		th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Summary.NumberOfUpdates == 2, fmt.Sprintf("2 updates should have been registered, instead there were %d updates", res.Summary.NumberOfUpdates))
-		th.Assert(t, res.Summary.TLSVersion["first"] == "TLS 1.2", fmt.Sprintf("TLS first should have been TLS 1.2, is instead %s", res.Summary.TLSVersion["first"]))
-		th.Assert(t, res.Summary.TLSVersion["last"] == nil, fmt.Sprintf("TLS last should have been nil, it is instead %s", res.Summary.TLSVersion["last"]))
-		th.Assert(t, res.Summary.FHIRVersion["last"] == "1.0.2", fmt.Sprintf("FHIR Version last should have been 1.0.2, is instead %+v", res.Summary.FHIRVersion["last"]))
-		close(resultCh)
-	}
+	value, err := getHistory(ctx, &jobArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
+	th.Assert(t, res.Summary.NumberOfUpdates == 2, fmt.Sprintf("2 updates should have been registered, instead there were %d updates", res.Summary.NumberOfUpdates))
+	th.Assert(t, res.Summary.TLSVersion["first"] == "TLS 1.2", fmt.Sprintf("TLS first should have been TLS 1.2, is instead %s", res.Summary.TLSVersion["first"]))
+	th.Assert(t, res.Summary.TLSVersion["last"] == nil, fmt.Sprintf("TLS last should have been nil, it is instead %s", res.Summary.TLSVersion["last"]))
+	th.Assert(t, res.Summary.FHIRVersion["last"] == "1.0.2", fmt.Sprintf("FHIR Version last should have been 1.0.2, is instead %+v", res.Summary.FHIRVersion["last"]))
 
 	// If the args are not properly formatted
 
@@ -383,12 +375,11 @@ func Test_getHistory(t *testing.T) {
 		"nonsense": 1,
 	}
 
-	err = getHistory(ctx, &jobArgs3)
+	_, err = getHistory(ctx, &jobArgs3)
 	th.Assert(t, err != nil, fmt.Sprint("Malformed arguments should have thrown error."))
 
 	// If the URL does not exist, return default data
 
-	resultCh4 := make(chan Result)
 	jobArgs4 := make(map[string]interface{})
 	jobArgs4["historyArgs"] = historyArgs{
 		fhirURL:   "thisurldoesntexist.com",
@@ -396,17 +387,15 @@ func Test_getHistory(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh4,
 	}
 
-	go getHistory(ctx, &jobArgs4)
-	for res := range resultCh4 {
-		th.Assert(t, res.Summary.NumberOfUpdates == 0, fmt.Sprintf("Expected 0 entries in history table. Actually had %d entries.", res.Summary.NumberOfUpdates))
-		th.Assert(t, res.URL == "thisurldoesntexist.com", fmt.Sprintf("Expected URL to equal 'thisurldoesntexist.com'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Summary.TLSVersion["first"] == nil, fmt.Sprint("TLS first should have been nil"))
-		th.Assert(t, res.Summary.TLSVersion["last"] == nil, fmt.Sprint("TLS last should have been nil"))
-		close(resultCh4)
-	}
+	value4, err := getHistory(ctx, &jobArgs4)
+	th.Assert(t, err == nil, err)
+	res4 := value4.(Result)
+	th.Assert(t, res4.Summary.NumberOfUpdates == 0, fmt.Sprintf("Expected 0 entries in history table. Actually had %d entries.", res4.Summary.NumberOfUpdates))
+	th.Assert(t, res4.URL == "thisurldoesntexist.com", fmt.Sprintf("Expected URL to equal 'thisurldoesntexist.com'. Is actually '%s'.", res4.URL))
+	th.Assert(t, res4.Summary.TLSVersion["first"] == nil, fmt.Sprint("TLS first should have been nil"))
+	th.Assert(t, res4.Summary.TLSVersion["last"] == nil, fmt.Sprint("TLS last should have been nil"))
 }
 
 func Test_getMetadata(t *testing.T) {
@@ -428,7 +417,6 @@ func Test_getMetadata(t *testing.T) {
 
 	// Base Case
 
-	resultCh := make(chan Result)
 	jobArgs := make(map[string]interface{})
 	jobArgs["historyArgs"] = historyArgs{
 		fhirURL:   "http://example.com/DTSU2/",
@@ -436,24 +424,20 @@ func Test_getMetadata(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh,
 	}
 
-	go getMetadata(ctx, &jobArgs)
-
-	for res := range resultCh {
-		th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
-		th.Assert(t, len(res.Summary.SmartHTTPResponse) == 1, fmt.Sprintf("There should be 1 entry for the SMART HTTP Response, is instead %d", len(res.Summary.SmartHTTPResponse)))
-		th.Assert(t, res.Summary.SmartHTTPResponse[0].ResponseCode == 400, fmt.Sprintf("SMART HTTP Response Code should be 400, is instead %d", res.Summary.SmartHTTPResponse[0].ResponseCode))
-		th.Assert(t, res.Summary.SmartHTTPResponse[0].ResponseCount == 1, fmt.Sprintf("SMART HTTP Response Count should be 1, is instead %d", res.Summary.SmartHTTPResponse[0].ResponseCount))
-		close(resultCh)
-	}
+	value, err := getMetadata(ctx, &jobArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
+	th.Assert(t, len(res.Summary.SmartHTTPResponse) == 1, fmt.Sprintf("There should be 1 entry for the SMART HTTP Response, is instead %d", len(res.Summary.SmartHTTPResponse)))
+	th.Assert(t, res.Summary.SmartHTTPResponse[0].ResponseCode == 400, fmt.Sprintf("SMART HTTP Response Code should be 400, is instead %d", res.Summary.SmartHTTPResponse[0].ResponseCode))
+	th.Assert(t, res.Summary.SmartHTTPResponse[0].ResponseCount == 1, fmt.Sprintf("SMART HTTP Response Count should be 1, is instead %d", res.Summary.SmartHTTPResponse[0].ResponseCount))
 
 	// Add 2nd Metadata for Endpoint
 	_, err = store.AddFHIREndpointMetadata(ctx, &testMetadata2)
 	th.Assert(t, err == nil, err)
 
-	resultCh2 := make(chan Result)
 	jobArgs2 := make(map[string]interface{})
 	jobArgs2["historyArgs"] = historyArgs{
 		fhirURL:   "http://example.com/DTSU2/",
@@ -461,27 +445,23 @@ func Test_getMetadata(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh2,
 	}
 
-	go getMetadata(ctx, &jobArgs2)
-
-	for res := range resultCh2 {
-		th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
-		th.Assert(t, len(res.Summary.SmartHTTPResponse) == 2, fmt.Sprintf("SMART HTTP Response should have 2 entries, instead has %d", len(res.Summary.SmartHTTPResponse)))
-		th.Assert(t, len(res.Summary.HTTPResponse) == 1, fmt.Sprintf("HTTP Response should have 1 entry, instead has %d", len(res.Summary.HTTPResponse)))
-		th.Assert(t, res.Summary.HTTPResponse[0].ResponseCode == 200, fmt.Sprintf("HTTP Response Code should be 200, is instead %d", res.Summary.HTTPResponse[0].ResponseCode))
-		th.Assert(t, res.Summary.HTTPResponse[0].ResponseCount == 2, fmt.Sprintf("HTTP Response Count should be 2, is instead %d", res.Summary.HTTPResponse[0].ResponseCount))
-		th.Assert(t, len(res.Summary.Errors) == 1, fmt.Sprintf("Errors should have 1 entry, instead has %d", len(res.Summary.Errors)))
-		th.Assert(t, res.Summary.ResponseTimeSecond == 0.9, fmt.Sprintf("HTTP Response Code should be 0.9, the median of [0.8, 1.0], is instead %f", res.Summary.ResponseTimeSecond))
-		close(resultCh2)
-	}
+	value2, err := getMetadata(ctx, &jobArgs2)
+	th.Assert(t, err == nil, err)
+	res2 := value2.(Result)
+	th.Assert(t, res2.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res2.URL))
+	th.Assert(t, len(res2.Summary.SmartHTTPResponse) == 2, fmt.Sprintf("SMART HTTP Response should have 2 entries, instead has %d", len(res2.Summary.SmartHTTPResponse)))
+	th.Assert(t, len(res2.Summary.HTTPResponse) == 1, fmt.Sprintf("HTTP Response should have 1 entry, instead has %d", len(res2.Summary.HTTPResponse)))
+	th.Assert(t, res2.Summary.HTTPResponse[0].ResponseCode == 200, fmt.Sprintf("HTTP Response Code should be 200, is instead %d", res2.Summary.HTTPResponse[0].ResponseCode))
+	th.Assert(t, res2.Summary.HTTPResponse[0].ResponseCount == 2, fmt.Sprintf("HTTP Response Count should be 2, is instead %d", res2.Summary.HTTPResponse[0].ResponseCount))
+	th.Assert(t, len(res2.Summary.Errors) == 1, fmt.Sprintf("Errors should have 1 entry, instead has %d", len(res2.Summary.Errors)))
+	th.Assert(t, res2.Summary.ResponseTimeSecond == 0.9, fmt.Sprintf("HTTP Response Code should be 0.9, the median of [0.8, 1.0], is instead %f", res2.Summary.ResponseTimeSecond))
 
 	// Add 3nd Metadata for Endpoint
 	_, err = store.AddFHIREndpointMetadata(ctx, &testMetadata)
 	th.Assert(t, err == nil, err)
 
-	resultCh3 := make(chan Result)
 	jobArgs3 := make(map[string]interface{})
 	jobArgs3["historyArgs"] = historyArgs{
 		fhirURL:   "http://example.com/DTSU2/",
@@ -489,21 +469,18 @@ func Test_getMetadata(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh3,
 	}
 
-	go getMetadata(ctx, &jobArgs3)
-
-	for res := range resultCh3 {
-		th.Assert(t, res.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res.URL))
-		th.Assert(t, len(res.Summary.SmartHTTPResponse) == 2, fmt.Sprintf("SMART HTTP Response should have 2 entries, instead has %d", len(res.Summary.SmartHTTPResponse)))
-		th.Assert(t, len(res.Summary.HTTPResponse) == 1, fmt.Sprintf("HTTP Response should have 1 entry, instead has %d", len(res.Summary.HTTPResponse)))
-		th.Assert(t, res.Summary.HTTPResponse[0].ResponseCode == 200, fmt.Sprintf("HTTP Response Code should be 200, is instead %d", res.Summary.HTTPResponse[0].ResponseCode))
-		th.Assert(t, res.Summary.HTTPResponse[0].ResponseCount == 3, fmt.Sprintf("HTTP Response Count should be 2, is instead %d", res.Summary.HTTPResponse[0].ResponseCount))
-		th.Assert(t, len(res.Summary.Errors) == 1, fmt.Sprintf("Errors should have 1 entry, instead has %d", len(res.Summary.Errors)))
-		th.Assert(t, res.Summary.ResponseTimeSecond == 0.8, fmt.Sprintf("HTTP Response Code should be 0.8, the median of [0.8, 0.8, 1.0], is instead %f", res.Summary.ResponseTimeSecond))
-		close(resultCh3)
-	}
+	value3, err := getMetadata(ctx, &jobArgs3)
+	th.Assert(t, err == nil, err)
+	res3 := value3.(Result)
+	th.Assert(t, res3.URL == "http://example.com/DTSU2/", fmt.Sprintf("Expected URL to equal 'http://example.com/DTSU2/'. Is actually '%s'.", res3.URL))
+	th.Assert(t, len(res3.Summary.SmartHTTPResponse) == 2, fmt.Sprintf("SMART HTTP Response should have 2 entries, instead has %d", len(res3.Summary.SmartHTTPResponse)))
+	th.Assert(t, len(res3.Summary.HTTPResponse) == 1, fmt.Sprintf("HTTP Response should have 1 entry, instead has %d", len(res3.Summary.HTTPResponse)))
+	th.Assert(t, res3.Summary.HTTPResponse[0].ResponseCode == 200, fmt.Sprintf("HTTP Response Code should be 200, is instead %d", res3.Summary.HTTPResponse[0].ResponseCode))
+	th.Assert(t, res3.Summary.HTTPResponse[0].ResponseCount == 3, fmt.Sprintf("HTTP Response Count should be 2, is instead %d", res3.Summary.HTTPResponse[0].ResponseCount))
+	th.Assert(t, len(res3.Summary.Errors) == 1, fmt.Sprintf("Errors should have 1 entry, instead has %d", len(res3.Summary.Errors)))
+	th.Assert(t, res3.Summary.ResponseTimeSecond == 0.8, fmt.Sprintf("HTTP Response Code should be 0.8, the median of [0.8, 0.8, 1.0], is instead %f", res3.Summary.ResponseTimeSecond))
 
 	// If the args are not properly formatted
 
@@ -512,12 +489,11 @@ func Test_getMetadata(t *testing.T) {
 		"nonsense": 1,
 	}
 
-	err = getMetadata(ctx, &jobArgs4)
+	_, err = getMetadata(ctx, &jobArgs4)
 	th.Assert(t, err != nil, fmt.Sprint("Malformed arguments should have thrown error."))
 
 	// If the URL does not exist, return default data
 
-	resultCh5 := make(chan Result)
 	jobArgs5 := make(map[string]interface{})
 	jobArgs5["historyArgs"] = historyArgs{
 		fhirURL:   "thisurldoesntexist.com",
@@ -525,17 +501,15 @@ func Test_getMetadata(t *testing.T) {
 		dateStart: formatToday,
 		dateEnd:   formatTomorrow,
 		store:     store,
-		result:    resultCh5,
 	}
 
-	go getMetadata(ctx, &jobArgs5)
-	for res := range resultCh5 {
-		th.Assert(t, len(res.Summary.HTTPResponse) == 0, fmt.Sprintf("HTTP Response should have 0 entries, instead has %d", len(res.Summary.HTTPResponse)))
-		th.Assert(t, len(res.Summary.SmartHTTPResponse) == 0, fmt.Sprintf("SMART HTTP Response should have 0 entries, instead has %d", len(res.Summary.SmartHTTPResponse)))
-		th.Assert(t, len(res.Summary.Errors) == 0, fmt.Sprintf("Errors should have 0 entries, instead has %d", len(res.Summary.Errors)))
-		th.Assert(t, res.Summary.ResponseTimeSecond == nil, fmt.Sprintf("ResponseTimeSecond should be 0, instead is %f", res.Summary.ResponseTimeSecond))
-		close(resultCh5)
-	}
+	value5, err := getMetadata(ctx, &jobArgs5)
+	th.Assert(t, err == nil, err)
+	res5 := value5.(Result)
+	th.Assert(t, len(res5.Summary.HTTPResponse) == 0, fmt.Sprintf("HTTP Response should have 0 entries, instead has %d", len(res5.Summary.HTTPResponse)))
+	th.Assert(t, len(res5.Summary.SmartHTTPResponse) == 0, fmt.Sprintf("SMART HTTP Response should have 0 entries, instead has %d", len(res5.Summary.SmartHTTPResponse)))
+	th.Assert(t, len(res5.Summary.Errors) == 0, fmt.Sprintf("Errors should have 0 entries, instead has %d", len(res5.Summary.Errors)))
+	th.Assert(t, res5.Summary.ResponseTimeSecond == nil, fmt.Sprintf("ResponseTimeSecond should be 0, instead is %f", res5.Summary.ResponseTimeSecond))
 }
 
 func setupCapabilityStatement(t *testing.T, path string) {
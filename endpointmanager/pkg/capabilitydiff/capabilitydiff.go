@@ -0,0 +1,227 @@
+package capabilitydiff
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+)
+
+// ChangeType is the part of the capability statement that a Change describes
+type ChangeType string
+
+// The parts of a capability statement that are compared
+const (
+	StatementChange       ChangeType = "statement"
+	FHIRVersionChange     ChangeType = "fhirVersion"
+	SoftwareNameChange    ChangeType = "softwareName"
+	SoftwareVersionChange ChangeType = "softwareVersion"
+	ResourceChange        ChangeType = "resource"
+	InteractionChange     ChangeType = "interaction"
+	SearchParamChange     ChangeType = "searchParam"
+	ProfileChange         ChangeType = "profile"
+)
+
+// Action is how the part of the capability statement described by a Change changed
+type Action string
+
+// The actions a Change can have
+const (
+	Added   Action = "added"
+	Removed Action = "removed"
+	Changed Action = "changed"
+)
+
+// Change is a single difference between two capability statements. Resource is set for the resource, interaction,
+// search parameter and profile changes. Value is the interaction, search parameter or profile that was added or
+// removed. OldValue and NewValue are set for the values that changed, such as the FHIR version.
+type Change struct {
+	Type     ChangeType `json:"type"`
+	Action   Action     `json:"action"`
+	Resource string     `json:"resource,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	OldValue string     `json:"oldValue,omitempty"`
+	NewValue string     `json:"newValue,omitempty"`
+}
+
+// RoutingKey returns the topic routing key the change is published with, in the form <type>.<action>.<resource>,
+// eg. "searchParam.removed.MedicationRequest". Changes that are not about a resource have the form <type>.<action>,
+// eg. "fhirVersion.changed".
+func (c Change) RoutingKey() string {
+	if c.Resource == "" {
+		return string(c.Type) + "." + string(c.Action)
+	}
+	return string(c.Type) + "." + string(c.Action) + "." + c.Resource
+}
+
+// resourceInfo holds the parts of a capability statement resource that are compared
+type resourceInfo struct {
+	interactions []string
+	searchParams []string
+	profiles     []string
+}
+
+// Diff returns the changes needed to go from the old capability statement to the new one. Only the server mode
+// rest entries of the statements are compared. If either statement is nil, a single statement change is
+// returned rather than listing every resource as added or removed.
+func Diff(oldStat capabilityparser.CapabilityStatement, newStat capabilityparser.CapabilityStatement) []Change {
+	var changes []Change
+
+	if oldStat == nil && newStat == nil {
+		return changes
+	} else if oldStat == nil {
+		return append(changes, Change{Type: StatementChange, Action: Added})
+	} else if newStat == nil {
+		return append(changes, Change{Type: StatementChange, Action: Removed})
+	}
+
+	oldFHIRVersion, _ := oldStat.GetFHIRVersion()
+	newFHIRVersion, _ := newStat.GetFHIRVersion()
+	changes = appendValueChange(changes, FHIRVersionChange, oldFHIRVersion, newFHIRVersion)
+
+	oldSoftwareName, _ := oldStat.GetSoftwareName()
+	newSoftwareName, _ := newStat.GetSoftwareName()
+	changes = appendValueChange(changes, SoftwareNameChange, oldSoftwareName, newSoftwareName)
+
+	oldSoftwareVersion, _ := oldStat.GetSoftwareVersion()
+	newSoftwareVersion, _ := newStat.GetSoftwareVersion()
+	changes = appendValueChange(changes, SoftwareVersionChange, oldSoftwareVersion, newSoftwareVersion)
+
+	oldResources := serverResources(oldStat)
+	newResources := serverResources(newStat)
+
+	for _, resourceType := range sortedKeys(oldResources) {
+		if _, ok := newResources[resourceType]; !ok {
+			changes = append(changes, Change{Type: ResourceChange, Action: Removed, Resource: resourceType})
+		}
+	}
+	for _, resourceType := range sortedKeys(newResources) {
+		newInfo := newResources[resourceType]
+		oldInfo, ok := oldResources[resourceType]
+		if !ok {
+			changes = append(changes, Change{Type: ResourceChange, Action: Added, Resource: resourceType})
+			continue
+		}
+		changes = appendListChanges(changes, InteractionChange, resourceType, oldInfo.interactions, newInfo.interactions)
+		changes = appendListChanges(changes, SearchParamChange, resourceType, oldInfo.searchParams, newInfo.searchParams)
+		changes = appendListChanges(changes, ProfileChange, resourceType, oldInfo.profiles, newInfo.profiles)
+	}
+
+	return changes
+}
+
+// appendValueChange adds a change to the given list if the old and new values are different
+func appendValueChange(changes []Change, changeType ChangeType, oldValue string, newValue string) []Change {
+	if oldValue == newValue {
+		return changes
+	}
+	return append(changes, Change{Type: changeType, Action: Changed, OldValue: oldValue, NewValue: newValue})
+}
+
+// appendListChanges adds a change to the given list for each value that is only in the old list or only in the
+// new list of values of the given resource
+func appendListChanges(changes []Change, changeType ChangeType, resourceType string, oldValues []string, newValues []string) []Change {
+	for _, value := range oldValues {
+		if !contains(newValues, value) {
+			changes = append(changes, Change{Type: changeType, Action: Removed, Resource: resourceType, Value: value})
+		}
+	}
+	for _, value := range newValues {
+		if !contains(oldValues, value) {
+			changes = append(changes, Change{Type: changeType, Action: Added, Resource: resourceType, Value: value})
+		}
+	}
+	return changes
+}
+
+// serverResources returns the interactions, search parameters and profiles of each of the resources of the
+// server mode rest entries of the given capability statement, keyed by the resource type
+func serverResources(capStat capabilityparser.CapabilityStatement) map[string]*resourceInfo {
+	resources := make(map[string]*resourceInfo)
+
+	resourceList, err := capStat.GetResourceListByMode(capabilityparser.RestModeServer)
+	if err != nil {
+		return resources
+	}
+	for _, resource := range resourceList {
+		resourceType, ok := resource["type"].(string)
+		if !ok {
+			continue
+		}
+		info, ok := resources[resourceType]
+		if !ok {
+			info = &resourceInfo{}
+			resources[resourceType] = info
+		}
+
+		interactions, _ := resource["interaction"].([]interface{})
+		for _, interaction := range interactions {
+			interactionMap, ok := interaction.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			info.interactions = appendUnique(info.interactions, stringValue(interactionMap["code"]))
+		}
+
+		searchParams, _ := resource["searchParam"].([]interface{})
+		for _, param := range searchParams {
+			paramMap, ok := param.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			info.searchParams = appendUnique(info.searchParams, stringValue(paramMap["name"]))
+		}
+
+		info.profiles = appendUnique(info.profiles, stringValue(resource["profile"]))
+		supportedProfiles, _ := resource["supportedProfile"].([]interface{})
+		for _, profile := range supportedProfiles {
+			info.profiles = appendUnique(info.profiles, stringValue(profile))
+		}
+	}
+
+	for _, info := range resources {
+		sort.Strings(info.interactions)
+		sort.Strings(info.searchParams)
+		sort.Strings(info.profiles)
+	}
+
+	return resources
+}
+
+// stringValue returns the given value if it is a string, or its reference if it is a DSTU2 or STU3 Reference
+func stringValue(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return strings.TrimSpace(str)
+	}
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		str, _ := valueMap["reference"].(string)
+		return strings.TrimSpace(str)
+	}
+	return ""
+}
+
+// appendUnique adds the given value to the list if it is not empty and is not already in the list
+func appendUnique(list []string, value string) []string {
+	if value == "" || contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+func contains(list []string, value string) bool {
+	for _, elem := range list {
+		if elem == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(resources map[string]*resourceInfo) []string {
+	keys := make([]string, 0, len(resources))
+	for key := range resources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
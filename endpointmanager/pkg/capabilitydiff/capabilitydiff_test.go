@@ -0,0 +1,104 @@
+package capabilitydiff
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_Diff(t *testing.T) {
+	oldStat, err := capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{
+		"fhirVersion": "4.0.0",
+		"software":    map[string]interface{}{"name": "EHR", "version": "1.0"},
+		"rest": []interface{}{
+			map[string]interface{}{
+				"mode": "server",
+				"resource": []interface{}{
+					map[string]interface{}{
+						"type":        "MedicationRequest",
+						"interaction": []interface{}{map[string]interface{}{"code": "read"}, map[string]interface{}{"code": "search-type"}},
+						"searchParam": []interface{}{map[string]interface{}{"name": "patient"}, map[string]interface{}{"name": "status"}},
+					},
+					map[string]interface{}{"type": "Device"},
+				},
+			},
+		},
+	})
+	th.Assert(t, err == nil, err)
+
+	newStat, err := capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{
+		"fhirVersion": "4.0.1",
+		"software":    map[string]interface{}{"name": "EHR", "version": "2.0"},
+		"rest": []interface{}{
+			map[string]interface{}{
+				"mode": "server",
+				"resource": []interface{}{
+					map[string]interface{}{
+						"type":             "MedicationRequest",
+						"interaction":      []interface{}{map[string]interface{}{"code": "read"}},
+						"searchParam":      []interface{}{map[string]interface{}{"name": "status"}},
+						"supportedProfile": []interface{}{"http://hl7.org/fhir/us/core/StructureDefinition/us-core-medicationrequest"},
+					},
+					map[string]interface{}{"type": "Patient"},
+				},
+			},
+			map[string]interface{}{
+				"mode": "client",
+				"resource": []interface{}{
+					map[string]interface{}{"type": "Observation"},
+				},
+			},
+		},
+	})
+	th.Assert(t, err == nil, err)
+
+	expected := []Change{
+		{Type: FHIRVersionChange, Action: Changed, OldValue: "4.0.0", NewValue: "4.0.1"},
+		{Type: SoftwareVersionChange, Action: Changed, OldValue: "1.0", NewValue: "2.0"},
+		{Type: ResourceChange, Action: Removed, Resource: "Device"},
+		{Type: InteractionChange, Action: Removed, Resource: "MedicationRequest", Value: "search-type"},
+		{Type: SearchParamChange, Action: Removed, Resource: "MedicationRequest", Value: "patient"},
+		{Type: ProfileChange, Action: Added, Resource: "MedicationRequest", Value: "http://hl7.org/fhir/us/core/StructureDefinition/us-core-medicationrequest"},
+		{Type: ResourceChange, Action: Added, Resource: "Patient"},
+	}
+	changes := Diff(oldStat, newStat)
+	th.Assert(t, reflect.DeepEqual(changes, expected), fmt.Sprintf("expected changes %+v, got %+v", expected, changes))
+
+	// reversing the statements reverses the changes
+	changes = Diff(newStat, oldStat)
+	th.Assert(t, len(changes) == len(expected), fmt.Sprintf("expected %d changes, got %d", len(expected), len(changes)))
+	th.Assert(t, changes[2] == Change{Type: ResourceChange, Action: Removed, Resource: "Patient"}, fmt.Sprintf("expected Patient to be removed, got %+v", changes[2]))
+	th.Assert(t, changes[3] == Change{Type: ResourceChange, Action: Added, Resource: "Device"}, fmt.Sprintf("expected Device to be added, got %+v", changes[3]))
+
+	// identical statements have no changes
+	path := filepath.Join("../testdata", "cerner_capability_dstu2.json")
+	csJSON, err := ioutil.ReadFile(path)
+	th.Assert(t, err == nil, err)
+	cs1, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+	cs2, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+	changes = Diff(cs1, cs2)
+	th.Assert(t, len(changes) == 0, fmt.Sprintf("expected no changes between identical statements, got %+v", changes))
+
+	// a missing statement is a single change
+	changes = Diff(nil, cs1)
+	th.Assert(t, reflect.DeepEqual(changes, []Change{{Type: StatementChange, Action: Added}}), fmt.Sprintf("expected statement added change, got %+v", changes))
+	changes = Diff(cs1, nil)
+	th.Assert(t, reflect.DeepEqual(changes, []Change{{Type: StatementChange, Action: Removed}}), fmt.Sprintf("expected statement removed change, got %+v", changes))
+	changes = Diff(nil, nil)
+	th.Assert(t, len(changes) == 0, fmt.Sprintf("expected no changes between nil statements, got %+v", changes))
+}
+
+func Test_RoutingKey(t *testing.T) {
+	change := Change{Type: SearchParamChange, Action: Removed, Resource: "MedicationRequest", Value: "patient"}
+	th.Assert(t, change.RoutingKey() == "searchParam.removed.MedicationRequest", fmt.Sprintf("unexpected routing key %s", change.RoutingKey()))
+
+	change = Change{Type: FHIRVersionChange, Action: Changed, OldValue: "4.0.0", NewValue: "4.0.1"}
+	th.Assert(t, change.RoutingKey() == "fhirVersion.changed", fmt.Sprintf("unexpected routing key %s", change.RoutingKey()))
+}
@@ -88,6 +88,23 @@ func (cp *baseParser) GetSoftwareVersion() (string, error) {
 	return versionStr, nil
 }
 
+// GetSoftwareReleaseDate returns the software release date specified in the conformance/capability statement.
+func (cp *baseParser) GetSoftwareReleaseDate() (string, error) {
+	softwareMap, err := cp.GetSoftware()
+	if err != nil || len(softwareMap) == 0 {
+		return "", err
+	}
+	releaseDate := softwareMap["releaseDate"]
+	if releaseDate == nil {
+		return "", nil
+	}
+	releaseDateStr, ok := releaseDate.(string)
+	if !ok {
+		return "", fmt.Errorf("unable to cast %s capability statement software.releaseDate value to a string", cp.version)
+	}
+	return releaseDateStr, nil
+}
+
 // GetCopyright returns the copyright specified in the capability/conformance statement.
 func (cp *baseParser) GetCopyright() (string, error) {
 	copyright := cp.capStat["copyright"]
@@ -116,7 +133,7 @@ func (cp *baseParser) GetRest() ([]map[string]interface{}, error) {
 	for _, restElem := range restList {
 		restMap, ok := restElem.(map[string]interface{})
 		if !ok {
-			return returnList, fmt.Errorf("unable to cast %s capability statement messaging value to a map[string]interface{}", cp.version)
+			return returnList, fmt.Errorf("unable to cast %s capability statement rest value to a map[string]interface{}", cp.version)
 		}
 		returnList = append(returnList, restMap)
 	}
@@ -145,6 +162,46 @@ func (cp *baseParser) GetResourceList(rest map[string]interface{}) ([]map[string
 	return returnList, nil
 }
 
+// GetRestMode returns the mode (server or client) of the given rest map of the capability/conformance statement.
+// Rest entries without a mode are treated as server entries.
+func (cp *baseParser) GetRestMode(rest map[string]interface{}) (string, error) {
+	mode := rest["mode"]
+	if mode == nil {
+		return RestModeServer, nil
+	}
+	modeStr, ok := mode.(string)
+	if !ok {
+		return "", fmt.Errorf("unable to cast %s capability statement rest mode value to a string", cp.version)
+	}
+	return modeStr, nil
+}
+
+// GetResourceListByMode returns the resources of all of the rest entries of the capability/conformance statement
+// that have the given mode.
+func (cp *baseParser) GetResourceListByMode(mode string) ([]map[string]interface{}, error) {
+	var returnList []map[string]interface{}
+
+	rest, err := cp.GetRest()
+	if err != nil {
+		return returnList, err
+	}
+	for _, restElem := range rest {
+		restMode, err := cp.GetRestMode(restElem)
+		if err != nil {
+			return returnList, err
+		}
+		if restMode != mode {
+			continue
+		}
+		resourceList, err := cp.GetResourceList(restElem)
+		if err != nil {
+			return returnList, err
+		}
+		returnList = append(returnList, resourceList...)
+	}
+	return returnList, nil
+}
+
 // GetKind returns the kind specified in the capability/conformance statement.
 func (cp *baseParser) GetKind() (string, error) {
 	kind := cp.capStat["kind"]
@@ -351,6 +351,39 @@ func Test_GetSoftwareVersion(t *testing.T) {
 	th.Assert(t, actual == expected, fmt.Sprintf("expected %s. received %s.", expected, actual))
 }
 
+func Test_GetSoftwareReleaseDate(t *testing.T) {
+	field := "software"
+
+	// basic
+
+	expected := "2019-11-22"
+	cs, err := getDSTU2CapStat()
+	th.Assert(t, err == nil, err)
+
+	actual, err := cs.GetSoftwareReleaseDate()
+	th.Assert(t, err == nil, err)
+	th.Assert(t, actual == expected, fmt.Sprintf("expected %s. received %s.", expected, actual))
+
+	// nested bad format
+
+	cs3, err := getNestedBadFormatCapStat(cs, field, "releaseDate")
+	th.Assert(t, err == nil, err)
+
+	_, err = cs3.GetSoftwareReleaseDate()
+	th.Assert(t, err != nil, "expected error due to bad format")
+
+	// missing nested field
+
+	expected = ""
+
+	cs4, err := deleteNestedFieldFromCapStat(cs, field, "releaseDate")
+	th.Assert(t, err == nil, err)
+
+	actual, err = cs4.GetSoftwareReleaseDate()
+	th.Assert(t, err == nil, err)
+	th.Assert(t, actual == expected, fmt.Sprintf("expected %s. received %s.", expected, actual))
+}
+
 func Test_GetRest(t *testing.T) {
 	field := "rest"
 
@@ -448,6 +481,88 @@ func Test_GetResourceList(t *testing.T) {
 	th.Assert(t, eq == true, fmt.Sprintf("expected %s. received %s.", emptyMap, actualRecs))
 }
 
+func Test_GetRestMode(t *testing.T) {
+	cs, err := getDSTU2CapStat()
+	th.Assert(t, err == nil, err)
+
+	// basic
+
+	actual, err := cs.GetRestMode(map[string]interface{}{"mode": "client"})
+	th.Assert(t, err == nil, err)
+	th.Assert(t, actual == RestModeClient, fmt.Sprintf("expected mode %s. received mode %s.", RestModeClient, actual))
+
+	// missing field defaults to server
+
+	actual, err = cs.GetRestMode(map[string]interface{}{})
+	th.Assert(t, err == nil, err)
+	th.Assert(t, actual == RestModeServer, fmt.Sprintf("expected mode %s. received mode %s.", RestModeServer, actual))
+
+	// bad format
+
+	_, err = cs.GetRestMode(map[string]interface{}{"mode": []int{1, 2, 3}})
+	th.Assert(t, err != nil, "expected error due to bad format")
+}
+
+func Test_GetResourceListByMode(t *testing.T) {
+	csInt := map[string]interface{}{
+		"fhirVersion": "4.0.1",
+		"rest": []interface{}{
+			map[string]interface{}{
+				"mode": "server",
+				"resource": []interface{}{
+					map[string]interface{}{"type": "Patient"},
+				},
+			},
+			map[string]interface{}{
+				"mode": "client",
+				"resource": []interface{}{
+					map[string]interface{}{"type": "Observation"},
+				},
+			},
+			map[string]interface{}{
+				"resource": []interface{}{
+					map[string]interface{}{"type": "Encounter"},
+				},
+			},
+		},
+	}
+
+	// basic
+
+	cs, err := NewCapabilityStatementFromInterface(csInt)
+	th.Assert(t, err == nil, err)
+
+	serverRecs, err := cs.GetResourceListByMode(RestModeServer)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(serverRecs) == 2, fmt.Sprintf("expected 2 server resources. received %d.", len(serverRecs)))
+	th.Assert(t, serverRecs[0]["type"] == "Patient", fmt.Sprintf("expected Patient resource. received %s.", serverRecs[0]["type"]))
+	th.Assert(t, serverRecs[1]["type"] == "Encounter", fmt.Sprintf("expected Encounter resource. received %s.", serverRecs[1]["type"]))
+
+	clientRecs, err := cs.GetResourceListByMode(RestModeClient)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(clientRecs) == 1, fmt.Sprintf("expected 1 client resource. received %d.", len(clientRecs)))
+	th.Assert(t, clientRecs[0]["type"] == "Observation", fmt.Sprintf("expected Observation resource. received %s.", clientRecs[0]["type"]))
+
+	// bad format
+
+	csInt["rest"] = []interface{}{"server"}
+	cs, err = NewCapabilityStatementFromInterface(csInt)
+	th.Assert(t, err == nil, err)
+
+	_, err = cs.GetResourceListByMode(RestModeServer)
+	th.Assert(t, err != nil, "expected error due to bad format")
+
+	// missing field
+
+	delete(csInt, "rest")
+	cs, err = NewCapabilityStatementFromInterface(csInt)
+	th.Assert(t, err == nil, err)
+
+	serverRecs, err = cs.GetResourceListByMode(RestModeServer)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(serverRecs) == 0, fmt.Sprintf("expected no server resources. received %d.", len(serverRecs)))
+}
+
 func Test_GetKind(t *testing.T) {
 	field := "kind"
 
@@ -15,6 +15,12 @@ var dstu2 = []string{"0.4.0", "0.5.0", "1.0.0", "1.0.1", "1.0.2"}
 var stu3 = []string{"1.1.0", "1.2.0", "1.4.0", "1.6.0", "1.8.0", "3.0.0", "3.0.1", "3.0.2"}
 var r4 = []string{"3.2.0", "3.3.0", "3.5.0", "3.5a.0", "4.0.0", "4.0.1"}
 
+// the modes of a capability/conformance statement rest entry
+const (
+	RestModeServer = "server"
+	RestModeClient = "client"
+)
+
 // CapabilityStatement provides access to key fields of the capability statement. It wraps the capability statements
 // so users don't need to worry about the capability statement version.
 type CapabilityStatement interface {
@@ -23,9 +29,12 @@ type CapabilityStatement interface {
 	GetSoftware() (map[string]interface{}, error)
 	GetSoftwareName() (string, error)
 	GetSoftwareVersion() (string, error)
+	GetSoftwareReleaseDate() (string, error)
 	GetCopyright() (string, error)
 	GetRest() ([]map[string]interface{}, error)
 	GetResourceList(map[string]interface{}) ([]map[string]interface{}, error)
+	GetRestMode(map[string]interface{}) (string, error)
+	GetResourceListByMode(string) ([]map[string]interface{}, error)
 	GetKind() (string, error)
 	GetImplementation() (map[string]interface{}, error)
 	GetMessaging() ([]map[string]interface{}, error)
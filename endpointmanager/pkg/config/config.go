@@ -59,10 +59,62 @@ func SetupConfig() error {
 	if err != nil {
 		return err
 	}
+	err = viper.BindEnv("qconfirm_timeout") // in seconds
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("qbackend")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("metrics_addr")
+	if err != nil {
+		return err
+	}
 	err = viper.BindEnv("capquery_qryintvl") // in minutes
 	if err != nil {
 		return err
 	}
+	err = viper.BindEnv("capquery_maxredirects")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_host_intvl") // in milliseconds
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_heartbeat_intvl") // in seconds
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_new_intvl") // in minutes
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_changed_intvl") // in minutes
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_down_intvl") // in minutes
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_check_intvl") // in minutes
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_recent_threshold") // in days
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_down_threshold") // in days
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_cycle_timeout") // in minutes
+	if err != nil {
+		return err
+	}
 
 	// Version Response Queue Setup
 	err = viper.BindEnv("versionsquery_qname")
@@ -73,6 +125,30 @@ func SetupConfig() error {
 	if err != nil {
 		return err
 	}
+	err = viper.BindEnv("versionsquery_response_retry_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("versionsquery_response_deadletter_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_retry_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_deadletter_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("qretry_maxattempts")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("qretry_delay") // in seconds
+	if err != nil {
+		return err
+	}
 
 	// Version Response Queue Setup
 	err = viper.BindEnv("versionsquery_qname")
@@ -84,6 +160,16 @@ func SetupConfig() error {
 		return err
 	}
 
+	// Capability Statement Changes Exchange Setup
+	err = viper.BindEnv("capchanges_exchange")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capchanges_publish_intvl") // in seconds
+	if err != nil {
+		return err
+	}
+
 	// Info History Pruning
 	err = viper.BindEnv("pruning_threshold") // in minutes
 	if err != nil {
@@ -110,11 +196,32 @@ func SetupConfig() error {
 	viper.SetDefault("qpassword", "capabilityquerier")
 	viper.SetDefault("qhost", "localhost")
 	viper.SetDefault("qport", "5672")
+	viper.SetDefault("qconfirm_timeout", 0) // in seconds, 0 -> publishes are not confirmed
+	viper.SetDefault("qbackend", "rabbitmq")
+	viper.SetDefault("metrics_addr", ":8081")
 	viper.SetDefault("capquery_qname", "capability-statements")
 	viper.SetDefault("endptinfo_capquery_qname", "endpoints-to-capability")
 	viper.SetDefault("versionsquery_qname", "version-responses")
 	viper.SetDefault("versionsquery_response_qname", "endpoints-to-version-responses")
+	viper.SetDefault("versionsquery_response_retry_qname", "endpoints-to-version-responses-retry")
+	viper.SetDefault("versionsquery_response_deadletter_qname", "endpoints-to-version-responses-dead-letters")
+	viper.SetDefault("capquery_retry_qname", "capability-statements-retry")
+	viper.SetDefault("capquery_deadletter_qname", "capability-statements-dead-letters")
+	viper.SetDefault("qretry_maxattempts", 5)
+	viper.SetDefault("qretry_delay", 60)        // in seconds
 	viper.SetDefault("capquery_qryintvl", 1380) // 1380 minutes -> 23 hours.
+	viper.SetDefault("capquery_maxredirects", 10)
+	viper.SetDefault("capquery_host_intvl", 100)     // in milliseconds, 0 -> requests to a host are not spaced out
+	viper.SetDefault("capquery_heartbeat_intvl", 30) // in seconds
+	viper.SetDefault("capquery_new_intvl", 360)      // 360 minutes -> 6 hours.
+	viper.SetDefault("capquery_changed_intvl", 360)  // 360 minutes -> 6 hours.
+	viper.SetDefault("capquery_down_intvl", 10080)   // 10080 minutes -> 1 week.
+	viper.SetDefault("capquery_check_intvl", 5)      // in minutes
+	viper.SetDefault("capquery_recent_threshold", 7) // in days
+	viper.SetDefault("capquery_down_threshold", 14)  // in days
+	viper.SetDefault("capquery_cycle_timeout", 120)  // in minutes
+	viper.SetDefault("capchanges_exchange", "capability-changes")
+	viper.SetDefault("capchanges_publish_intvl", 60) // in seconds
 
 	viper.SetDefault("pruning_threshold", 43800) // 43800 minutes -> 1 month.
 
@@ -187,6 +294,22 @@ func SetupConfigForTests() error {
 	if err != nil {
 		return err
 	}
+	err = viper.BindEnv("versionsquery_response_retry_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("versionsquery_response_deadletter_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_retry_qname")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("capquery_deadletter_qname")
+	if err != nil {
+		return err
+	}
 
 	// Version Response Queue Setup
 	err = viper.BindEnv("versionsquery_qname")
@@ -204,6 +327,11 @@ func SetupConfigForTests() error {
 	viper.SetDefault("endptinfo_capquery_qname", "test-endpoints-to-capability")
 	viper.SetDefault("versionsquery_qname", "test-version-responses")
 	viper.SetDefault("versionsquery_response_qname", "test-endpoints-to-version-responses")
+	viper.SetDefault("versionsquery_response_retry_qname", "test-endpoints-to-version-responses-retry")
+	viper.SetDefault("versionsquery_response_deadletter_qname", "test-endpoints-to-version-responses-dead-letters")
+	viper.SetDefault("capquery_retry_qname", "test-queue-retry")
+	viper.SetDefault("capquery_deadletter_qname", "test-queue-dead-letters")
+	viper.SetDefault("capchanges_exchange", "test-capability-changes")
 
 	if prevQName == viper.GetString("qname") {
 		panic("Test queue and dev/prod queue must be different. Test queue: " + viper.GetString("qname") + ". Prod/Dev queue: " + prevQName)
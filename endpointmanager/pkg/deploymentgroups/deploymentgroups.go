@@ -0,0 +1,167 @@
+package deploymentgroups
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/versionsoperatorparser"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ignoredCapabilityFields are the capability statement fields that can differ between the tenants of a single
+// deployment, so they are left out of the capability statement hash
+var ignoredCapabilityFields = []string{"id", "meta", "text", "url", "date", "name", "title", "description", "contact", "implementation"}
+
+// UpdateDeploymentGroups groups the stored FHIR endpoints by deployment and replaces the stored deployment groups
+// with the result
+func UpdateDeploymentGroups(ctx context.Context, store *postgresql.Store) error {
+	infos, err := store.GetEndpointDeploymentInfos(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting endpoint deployment information failed")
+	}
+
+	groups := GroupEndpoints(infos)
+
+	err = store.SaveDeploymentGroups(ctx, groups)
+	if err != nil {
+		return errors.Wrap(err, "saving deployment groups failed")
+	}
+
+	log.Infof("Grouped %d endpoints into %d deployment groups", len(infos), len(groups))
+	return nil
+}
+
+// GroupEndpoints places the given endpoints with the same fingerprint in the same deployment group. Endpoints
+// without a capability statement are not placed in a group. The groups are sorted by fingerprint and the URLs
+// of each group are sorted.
+func GroupEndpoints(infos []*endpointmanager.EndpointDeploymentInfo) []*endpointmanager.DeploymentGroup {
+	groupsByFingerprint := make(map[string]*endpointmanager.DeploymentGroup)
+
+	for _, info := range infos {
+		group := Fingerprint(info)
+		if group == nil {
+			continue
+		}
+		existingGroup, ok := groupsByFingerprint[group.Fingerprint]
+		if !ok {
+			groupsByFingerprint[group.Fingerprint] = group
+			continue
+		}
+		existingGroup.URLs = append(existingGroup.URLs, info.URL)
+	}
+
+	groups := make([]*endpointmanager.DeploymentGroup, 0, len(groupsByFingerprint))
+	for _, group := range groupsByFingerprint {
+		sort.Strings(group.URLs)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Fingerprint < groups[j].Fingerprint
+	})
+	return groups
+}
+
+// Fingerprint returns a deployment group holding only the given endpoint, with the fingerprint and the fields it
+// is built from set. It returns nil if the endpoint does not have a capability statement.
+func Fingerprint(info *endpointmanager.EndpointDeploymentInfo) *endpointmanager.DeploymentGroup {
+	if info.CapabilityStatement == nil {
+		return nil
+	}
+
+	capStatHash, err := capabilityStatementHash(info)
+	if err != nil {
+		log.Warnf("unable to hash the capability statement of %s: %s", info.URL, err)
+		return nil
+	}
+	softwareName, _ := info.CapabilityStatement.GetSoftwareName()
+	softwareVersion, _ := info.CapabilityStatement.GetSoftwareVersion()
+
+	group := endpointmanager.DeploymentGroup{
+		CapabilityStatementHash:   capStatHash,
+		SoftwareName:              strings.TrimSpace(softwareName),
+		SoftwareVersion:           strings.TrimSpace(softwareVersion),
+		ImplementationHost:        implementationHost(info),
+		TLSCertificateFingerprint: info.TLSCertificateFingerprint,
+		SupportedVersions:         supportedVersions(info.VersionsResponse),
+		URLs:                      []string{info.URL},
+	}
+	group.Fingerprint = hashStrings([]string{
+		group.CapabilityStatementHash,
+		group.SoftwareName,
+		group.SoftwareVersion,
+		group.ImplementationHost,
+		group.TLSCertificateFingerprint,
+		strings.Join(group.SupportedVersions, ","),
+	})
+	return &group
+}
+
+// capabilityStatementHash returns the hash of the endpoint's capability statement without the fields that can
+// differ between the tenants of a deployment
+func capabilityStatementHash(info *endpointmanager.EndpointDeploymentInfo) (string, error) {
+	capJSON, err := info.CapabilityStatement.GetJSON()
+	if err != nil {
+		return "", err
+	}
+	var capInt map[string]interface{}
+	err = json.Unmarshal(capJSON, &capInt)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range ignoredCapabilityFields {
+		delete(capInt, field)
+	}
+	// json.Marshal sorts map keys, so equal capability statements always have the same JSON
+	canonicalJSON, err := json.Marshal(capInt)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(canonicalJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// implementationHost returns the host of the implementation URL of the endpoint's capability statement, or the
+// host of the endpoint's URL if the capability statement does not have an implementation URL
+func implementationHost(info *endpointmanager.EndpointDeploymentInfo) string {
+	implementation, _ := info.CapabilityStatement.GetImplementation()
+	implURL, _ := implementation["url"].(string)
+	if host := urlHost(implURL); host != "" {
+		return host
+	}
+	return urlHost(info.URL)
+}
+
+func urlHost(rawURL string) string {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsedURL.Hostname())
+}
+
+// supportedVersions returns the sorted FHIR versions listed in the given $versions response, skipping any
+// values that are not strings
+func supportedVersions(vr versionsoperatorparser.VersionsResponse) []string {
+	var versions []string
+	versionsInt, _ := vr.Response["versions"].([]interface{})
+	for _, version := range versionsInt {
+		if versionStr, ok := version.(string); ok {
+			versions = append(versions, versionStr)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func hashStrings(values []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(values, "\n")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,94 @@
+package deploymentgroups
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/versionsoperatorparser"
+)
+
+func tenantInfo(t *testing.T, url string, tenant string, softwareVersion string, certFingerprint string) *endpointmanager.EndpointDeploymentInfo {
+	capStat, err := capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{
+		"id":          tenant,
+		"fhirVersion": "4.0.1",
+		"date":        "2020-06-01",
+		"software":    map[string]interface{}{"name": "EHR", "version": softwareVersion},
+		"implementation": map[string]interface{}{
+			"description": "Tenant " + tenant,
+			"url":         "https://FHIR.example.com/" + tenant,
+		},
+		"rest": []interface{}{
+			map[string]interface{}{
+				"mode":     "server",
+				"resource": []interface{}{map[string]interface{}{"type": "Patient"}},
+			},
+		},
+	})
+	th.Assert(t, err == nil, err)
+
+	return &endpointmanager.EndpointDeploymentInfo{
+		URL:                       url,
+		CapabilityStatement:       capStat,
+		TLSCertificateFingerprint: certFingerprint,
+		VersionsResponse: versionsoperatorparser.VersionsResponse{
+			Response: map[string]interface{}{"default": "4.0", "versions": []interface{}{"4.0", "3.0"}},
+		},
+	}
+}
+
+func Test_GroupEndpoints(t *testing.T) {
+	tenant1 := tenantInfo(t, "https://fhir.example.com/tenant1", "tenant1", "1.0", "abc")
+	tenant2 := tenantInfo(t, "https://fhir.example.com/tenant2", "tenant2", "1.0", "abc")
+	newerVersion := tenantInfo(t, "https://fhir.example.com/tenant3", "tenant3", "2.0", "abc")
+	otherCert := tenantInfo(t, "https://fhir.example.com/tenant4", "tenant4", "1.0", "def")
+	noCapStat := &endpointmanager.EndpointDeploymentInfo{URL: "https://down.example.com/fhir"}
+
+	groups := GroupEndpoints([]*endpointmanager.EndpointDeploymentInfo{tenant2, newerVersion, noCapStat, otherCert, tenant1})
+	th.Assert(t, len(groups) == 3, fmt.Sprintf("expected 3 deployment groups, got %d", len(groups)))
+
+	var tenantGroup *endpointmanager.DeploymentGroup
+	for _, group := range groups {
+		if len(group.URLs) == 2 {
+			tenantGroup = group
+		}
+	}
+	th.Assert(t, tenantGroup != nil, "expected the tenants with the same fingerprint to be grouped")
+	th.Assert(t, reflect.DeepEqual(tenantGroup.URLs, []string{tenant1.URL, tenant2.URL}), fmt.Sprintf("unexpected group URLs %v", tenantGroup.URLs))
+	th.Assert(t, tenantGroup.SoftwareName == "EHR" && tenantGroup.SoftwareVersion == "1.0", fmt.Sprintf("unexpected group software %s %s", tenantGroup.SoftwareName, tenantGroup.SoftwareVersion))
+	th.Assert(t, tenantGroup.ImplementationHost == "fhir.example.com", fmt.Sprintf("unexpected implementation host %s", tenantGroup.ImplementationHost))
+	th.Assert(t, tenantGroup.TLSCertificateFingerprint == "abc", fmt.Sprintf("unexpected TLS certificate fingerprint %s", tenantGroup.TLSCertificateFingerprint))
+	th.Assert(t, reflect.DeepEqual(tenantGroup.SupportedVersions, []string{"3.0", "4.0"}), fmt.Sprintf("unexpected supported versions %v", tenantGroup.SupportedVersions))
+
+	for i := 1; i < len(groups); i++ {
+		th.Assert(t, groups[i-1].Fingerprint < groups[i].Fingerprint, "expected the groups to be sorted by fingerprint")
+	}
+
+	th.Assert(t, len(GroupEndpoints(nil)) == 0, "expected no deployment groups")
+}
+
+func Test_Fingerprint(t *testing.T) {
+	info := tenantInfo(t, "https://fhir.example.com/tenant1", "tenant1", "1.0", "abc")
+	group := Fingerprint(info)
+	th.Assert(t, group != nil, "expected a fingerprint for an endpoint with a capability statement")
+	th.Assert(t, len(group.Fingerprint) == 64, fmt.Sprintf("expected a SHA-256 fingerprint, got %s", group.Fingerprint))
+	th.Assert(t, group.CapabilityStatementHash != "", "expected the capability statement hash to be set")
+
+	// the fingerprint does not depend on the order of the $versions response
+	reordered := tenantInfo(t, "https://fhir.example.com/tenant2", "tenant2", "1.0", "abc")
+	reordered.VersionsResponse.Response["versions"] = []interface{}{"3.0", "4.0", 4}
+	th.Assert(t, Fingerprint(reordered).Fingerprint == group.Fingerprint, "expected the same fingerprint for reordered versions")
+
+	// the endpoint URL host is used when there is no implementation URL
+	capStat, err := capabilityparser.NewCapabilityStatementFromInterface(map[string]interface{}{"fhirVersion": "4.0.1"})
+	th.Assert(t, err == nil, err)
+	noImplementation := &endpointmanager.EndpointDeploymentInfo{URL: "https://Other.example.com/fhir", CapabilityStatement: capStat}
+	group = Fingerprint(noImplementation)
+	th.Assert(t, group.ImplementationHost == "other.example.com", fmt.Sprintf("unexpected implementation host %s", group.ImplementationHost))
+	th.Assert(t, len(group.SupportedVersions) == 0, fmt.Sprintf("expected no supported versions, got %v", group.SupportedVersions))
+
+	th.Assert(t, Fingerprint(&endpointmanager.EndpointDeploymentInfo{URL: "https://down.example.com/fhir"}) == nil, "expected no fingerprint without a capability statement")
+}
@@ -0,0 +1,75 @@
+package endpointmanager
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// endpointURLSuffixes are the paths that Lantern appends to an endpoint's URL when querying it
+var endpointURLSuffixes = []string{"/metadata", "/.well-known/smart-configuration", "/$versions"}
+
+// defaultPorts are the ports that URLs with each scheme use when no port is given
+var defaultPorts = map[string]string{"https": "443", "http": "80"}
+
+// FHIREndpointAlias links the URL of a FHIR endpoint to the canonical URL of the server it belongs to. Endpoints
+// with the same canonical URL are aliases of each other. FinalURL is the URL the endpoint's capability statement
+// was last returned from after following any redirects.
+type FHIREndpointAlias struct {
+	URL          string
+	CanonicalURL string
+	FinalURL     string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// NewFHIREndpointAlias returns the alias of the given endpoint URL. The canonical URL is built from the final URL
+// if there is one, and from the endpoint URL otherwise.
+func NewFHIREndpointAlias(endpointURL string, finalURL string) *FHIREndpointAlias {
+	alias := FHIREndpointAlias{
+		URL:      endpointURL,
+		FinalURL: finalURL,
+	}
+	if finalURL != "" {
+		alias.CanonicalURL = CanonicalURL(finalURL)
+	} else {
+		alias.CanonicalURL = CanonicalURL(endpointURL)
+	}
+	return &alias
+}
+
+// CanonicalURL returns the form of the given endpoint URL that is shared by every URL of the same server. The
+// scheme is always https, the host is lower cased, and the default port of the URL's own scheme, fragments,
+// trailing slashes and the metadata, well-known and $versions suffixes are removed. The path and query keep their
+// case, since tenants are often told apart by them.
+func CanonicalURL(endpointURL string) string {
+	trimmed := strings.TrimSpace(endpointURL)
+	parsedURL, err := url.Parse(NormalizeURL(trimmed))
+	if err != nil || parsedURL.Host == "" {
+		return trimmed
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	if port := parsedURL.Port(); port != "" && port != defaultPorts[parsedURL.Scheme] {
+		host = host + ":" + port
+	}
+
+	canonical := "https://" + host + TrimEndpointURLSuffix(parsedURL.EscapedPath())
+	if parsedURL.RawQuery != "" {
+		canonical = canonical + "?" + parsedURL.RawQuery
+	}
+	return canonical
+}
+
+// TrimEndpointURLSuffix removes any trailing slashes and the metadata, well-known or $versions suffix from the
+// given URL
+func TrimEndpointURLSuffix(endpointURL string) string {
+	trimmed := strings.TrimRight(endpointURL, "/")
+	for _, suffix := range endpointURLSuffixes {
+		if strings.HasSuffix(trimmed, suffix) {
+			trimmed = strings.TrimRight(strings.TrimSuffix(trimmed, suffix), "/")
+			break
+		}
+	}
+	return trimmed
+}
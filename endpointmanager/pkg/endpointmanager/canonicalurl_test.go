@@ -0,0 +1,79 @@
+package endpointmanager
+
+import "testing"
+
+func Test_CanonicalURL(t *testing.T) {
+	canonical := "https://fhir.example.com/r4/tenant1"
+	aliases := []string{
+		"https://fhir.example.com/r4/tenant1",
+		"https://fhir.example.com/r4/tenant1/",
+		"http://fhir.example.com/r4/tenant1",
+		"https://FHIR.Example.com/r4/tenant1",
+		"https://fhir.example.com:443/r4/tenant1",
+		"http://fhir.example.com:80/r4/tenant1/",
+		"https://fhir.example.com/r4/tenant1/metadata",
+		"https://fhir.example.com/r4/tenant1/metadata/",
+		"https://fhir.example.com/r4/tenant1/.well-known/smart-configuration",
+		"https://fhir.example.com/r4/tenant1/$versions",
+		"fhir.example.com/r4/tenant1",
+		" https://fhir.example.com/r4/tenant1#section ",
+	}
+	for _, alias := range aliases {
+		if CanonicalURL(alias) != canonical {
+			t.Errorf("Expected %s to be canonicalized to %s, got %s", alias, canonical, CanonicalURL(alias))
+		}
+	}
+
+	// the path, query and non default ports tell endpoints apart
+	different := []string{
+		"https://fhir.example.com/R4/tenant1",
+		"https://fhir.example.com/r4/tenant2",
+		"https://fhir.example.com:8443/r4/tenant1",
+		"https://fhir.example.com:80/r4/tenant1",
+		"http://fhir.example.com:443/r4/tenant1",
+		"https://fhir.example.com/r4/tenant1?tenant=2",
+	}
+	for _, url := range different {
+		if CanonicalURL(url) == canonical {
+			t.Errorf("Expected %s not to be canonicalized to %s", url, canonical)
+		}
+	}
+
+	if CanonicalURL("https://fhir.example.com:80/r4") != "https://fhir.example.com:80/r4" {
+		t.Errorf("Expected the http default port to be kept in an https URL, got %s", CanonicalURL("https://fhir.example.com:80/r4"))
+	}
+
+	if CanonicalURL("https://fhir.example.com:8443/r4/?tenant=2") != "https://fhir.example.com:8443/r4?tenant=2" {
+		t.Errorf("Expected the non default port and query to be kept, got %s", CanonicalURL("https://fhir.example.com:8443/r4/?tenant=2"))
+	}
+}
+
+func Test_TrimEndpointURLSuffix(t *testing.T) {
+	if TrimEndpointURLSuffix("https://foobar.com/fhir/metadata") != "https://foobar.com/fhir" {
+		t.Errorf("Expected the metadata suffix to be removed")
+	}
+	if TrimEndpointURLSuffix("https://foobar.com/fhir/$versions/") != "https://foobar.com/fhir" {
+		t.Errorf("Expected the $versions suffix to be removed")
+	}
+	if TrimEndpointURLSuffix("https://foobar.com/fhir//") != "https://foobar.com/fhir" {
+		t.Errorf("Expected the trailing slashes to be removed")
+	}
+	if TrimEndpointURLSuffix("https://foobar.com/metadata-server") != "https://foobar.com/metadata-server" {
+		t.Errorf("Expected a path that only starts with metadata to be kept")
+	}
+}
+
+func Test_NewFHIREndpointAlias(t *testing.T) {
+	alias := NewFHIREndpointAlias("http://foobar.com/fhir/", "")
+	if alias.CanonicalURL != "https://foobar.com/fhir" {
+		t.Errorf("Expected the canonical URL to be built from the endpoint URL, got %s", alias.CanonicalURL)
+	}
+
+	alias = NewFHIREndpointAlias("http://foobar.com/fhir/", "https://new.foobar.com/fhir")
+	if alias.CanonicalURL != "https://new.foobar.com/fhir" {
+		t.Errorf("Expected the canonical URL to be built from the final URL, got %s", alias.CanonicalURL)
+	}
+	if alias.URL != "http://foobar.com/fhir/" || alias.FinalURL != "https://new.foobar.com/fhir" {
+		t.Errorf("Unexpected alias %+v", alias)
+	}
+}
@@ -0,0 +1,20 @@
+package endpointmanager
+
+import (
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
+)
+
+// CapabilityChange is a record of the differences found between the capability statement stored for a FHIR
+// endpoint and a new capability statement returned by that endpoint. PublishedAt is the time the changes were
+// published, and is zero until they are.
+type CapabilityChange struct {
+	ID                   int
+	URL                  string
+	RequestedFhirVersion string
+	VendorID             int
+	Changes              []capabilitydiff.Change
+	CreatedAt            time.Time
+	PublishedAt          time.Time
+}
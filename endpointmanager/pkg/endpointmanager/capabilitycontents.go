@@ -0,0 +1,50 @@
+package endpointmanager
+
+// CapabilityContents is the normalized contents of a capability statement's rest entries. It is stored
+// in relational tables so that the supported resources, interactions, search parameters, operations,
+// profiles and security services can be queried without parsing the capability statement JSON.
+type CapabilityContents struct {
+	Rest []CapabilityRest
+}
+
+// CapabilityRest holds the contents of one rest entry of a capability statement
+type CapabilityRest struct {
+	Mode             string
+	Interactions     []string
+	SearchParams     []CapabilitySearchParam
+	Operations       []CapabilityOperation
+	SecurityServices []CapabilitySecurityService
+	Resources        []CapabilityResource
+}
+
+// CapabilityResource holds the contents of one resource entry of a capability statement rest entry
+type CapabilityResource struct {
+	Type              string
+	Profile           string
+	SupportedProfiles []string
+	Interactions      []string
+	SearchParams      []CapabilitySearchParam
+	SearchIncludes    []string
+	SearchRevIncludes []string
+	Operations        []CapabilityOperation
+}
+
+// CapabilitySearchParam is a search parameter supported by a rest entry or resource
+type CapabilitySearchParam struct {
+	Name       string
+	Type       string
+	Definition string
+}
+
+// CapabilityOperation is an operation supported by a rest entry or resource
+type CapabilityOperation struct {
+	Name       string
+	Definition string
+}
+
+// CapabilitySecurityService is a coding from a rest entry's security services
+type CapabilitySecurityService struct {
+	System  string
+	Code    string
+	Display string
+}
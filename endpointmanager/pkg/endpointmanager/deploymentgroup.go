@@ -0,0 +1,33 @@
+package endpointmanager
+
+import (
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/versionsoperatorparser"
+)
+
+// DeploymentGroup is a group of FHIR endpoints that appear to be served by the same backend deployment, such as
+// the tenants of a single server. Endpoints are in the same group when they have the same fingerprint, which is
+// built from the rest of the group's fields.
+type DeploymentGroup struct {
+	ID                        int
+	Fingerprint               string
+	CapabilityStatementHash   string
+	SoftwareName              string
+	SoftwareVersion           string
+	ImplementationHost        string
+	TLSCertificateFingerprint string
+	SupportedVersions         []string
+	URLs                      []string
+	CreatedAt                 time.Time
+	UpdatedAt                 time.Time
+}
+
+// EndpointDeploymentInfo is the information about a FHIR endpoint that is used to find its deployment group
+type EndpointDeploymentInfo struct {
+	URL                       string
+	CapabilityStatement       capabilityparser.CapabilityStatement
+	TLSCertificateFingerprint string
+	VersionsResponse          versionsoperatorparser.VersionsResponse
+}
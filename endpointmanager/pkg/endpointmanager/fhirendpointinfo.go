@@ -29,10 +29,13 @@ type FHIREndpointInfo struct {
 	SMARTResponseBytes       []byte
 	IncludedFields           []IncludedField
 	OperationResource        map[string][]string
+	ClientOperationResource  map[string][]string // the operations of the client mode rest entries
 	Metadata                 *FHIREndpointMetadata
 	RequestedFhirVersion     string
 	CapabilityFhirVersion    string
 	SupportedProfiles        []SupportedProfile
+	CapabilityContents       *CapabilityContents
+	CapabilityContentsID     int
 }
 
 // EqualExcludeMetadata checks each field of the two FHIREndpointInfos except for metadata fields to see if they are equal.
@@ -82,6 +85,9 @@ func (e *FHIREndpointInfo) EqualExcludeMetadata(e2 *FHIREndpointInfo) bool {
 	if e.ValidationID != e2.ValidationID {
 		return false
 	}
+	if e.CapabilityContentsID != e2.CapabilityContentsID {
+		return false
+	}
 	if e.SMARTResponse != nil && !e.SMARTResponse.Equal(e2.SMARTResponse) {
 		return false
 	}
@@ -101,7 +107,11 @@ func (e *FHIREndpointInfo) EqualExcludeMetadata(e2 *FHIREndpointInfo) bool {
 	// If the two endpoints have the same values in a different order, the Equal
 	// function will return false, so the resources need to be sorted for the Equal
 	// function to work as expected
-	return compareOperations(e.OperationResource, e2.OperationResource)
+	if !compareOperations(e.OperationResource, e2.OperationResource) {
+		return false
+	}
+
+	return compareOperations(e.ClientOperationResource, e2.ClientOperationResource)
 }
 
 // Equal checks each field of the two FHIREndpointInfos except for the database ID, CreatedAt and UpdatedAt fields to see if they are equal.
@@ -134,16 +144,49 @@ type IncludedField struct {
 	Extension bool
 }
 
-// SupportedProfile is a struct used to keep track of all of the profiles in the capability statement
+// SupportedProfile is a struct used to keep track of all of the profiles in the capability statement.
+// Mode is the mode (server or client) of the rest entry the profile was found in, and is empty for
+// DSTU2 conformance statement profiles.
 type SupportedProfile struct {
 	ProfileURL  string
 	ProfileName string
 	Resource    string
+	Mode        string
 }
 
-// Validation holds all of the validation results from running the validation checks
+// Validation holds all of the validation results from running the validation checks, the
+// conformance score computed from them, and the issues found by structurally validating the
+// capability statement
 type Validation struct {
 	Results []Rule
+	Score   float64
+	Issues  []ValidationIssue
+}
+
+// ValidationIssue is an OperationOutcome style issue found while structurally validating a capability
+// statement against its FHIR StructureDefinition
+type ValidationIssue struct {
+	Severity    string
+	Code        string
+	Diagnostics string
+	Expression  string
+}
+
+// ComputeScore returns the weighted fraction of the validation results that passed, between 0 and 1.
+// Results without a weight are not counted.
+func (v *Validation) ComputeScore() float64 {
+	var total float64
+	var passed float64
+	for _, rule := range v.Results {
+		total += rule.Weight
+		if rule.Valid {
+			passed += rule.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return passed / total
 }
 
 // Rule is the information returned from running the validation rule given by RuleName
@@ -155,6 +198,32 @@ type Rule struct {
 	Comment   string
 	Reference string
 	ImplGuide string
+	Resource  string
+	Severity  Severity
+	Weight    float64
+}
+
+// Severity describes how much a failing validation rule matters
+type Severity string
+
+// The severities a validation rule can have
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// DefaultWeight returns the weight given to a rule with the given severity when the rule does not set its own
+func (s Severity) DefaultWeight() float64 {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	}
+	return 0
 }
 
 // RuleOption is an enum of the names given to the rule validation checks
@@ -175,6 +244,11 @@ const (
 	UniqueResourcesRule  RuleOption = "uniqueResourcesRule"
 	SearchParamsRule     RuleOption = "searchParamsRule"
 	VersionsResponseRule RuleOption = "versionsResponseRule"
+	USCoreProfileRule    RuleOption = "usCoreProfileRule"
+	USCoreSearchRule     RuleOption = "usCoreSearchRule"
+	USCoreInteractRule   RuleOption = "usCoreInteractionRule"
+	RedirectSecureRule   RuleOption = "redirectSecureRule"
+	RedirectDomainRule   RuleOption = "redirectDomainRule"
 )
 
 // compareOperations compares the operation resource fields for an endpoint
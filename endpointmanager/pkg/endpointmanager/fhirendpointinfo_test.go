@@ -375,6 +375,12 @@ func Test_FHIREndpointInfoEqual(t *testing.T) {
 	}
 	endpointInfo2.ValidationID = endpointInfo1.ValidationID
 
+	endpointInfo2.CapabilityContentsID = 4
+	if endpointInfo1.Equal(endpointInfo2) {
+		t.Errorf("Expect endpointInfo 1 to not equal endpointInfo 2. CapabilityContentsID should be different. %d vs %d", endpointInfo1.CapabilityContentsID, endpointInfo2.CapabilityContentsID)
+	}
+	endpointInfo2.CapabilityContentsID = endpointInfo1.CapabilityContentsID
+
 	endpointInfo2.TLSVersion = "other"
 	if endpointInfo1.Equal(endpointInfo2) {
 		t.Errorf("Did not expect endpointInfo1 to equal endpointInfo 2. TLSVersion should be different. %s vs %s", endpointInfo1.TLSVersion, endpointInfo2.TLSVersion)
@@ -505,6 +511,12 @@ func Test_FHIREndpointInfoEqual(t *testing.T) {
 	}
 	endpointInfo2.OperationResource = endpointInfo1.OperationResource
 
+	endpointInfo2.ClientOperationResource = map[string][]string{"read": {"Patient"}}
+	if endpointInfo1.Equal(endpointInfo2) {
+		t.Errorf("Did not expect endpointInfo1 to equal endpointInfo 2. ClientOperationResource should be different. %s vs %s", endpointInfo1.ClientOperationResource, endpointInfo2.ClientOperationResource)
+	}
+	endpointInfo2.ClientOperationResource = endpointInfo1.ClientOperationResource
+
 	endpointInfo1.SupportedProfiles[0].ProfileName = "Wrong Profile Name"
 
 	if endpointInfo1.Equal(endpointInfo2) {
@@ -571,3 +583,35 @@ func Test_FHIREndpointInfoEqual(t *testing.T) {
 		t.Errorf("Nil endpointInfo 1 should equal nil endpointInfo 2.")
 	}
 }
+
+func Test_ValidationComputeScore(t *testing.T) {
+	validation := Validation{
+		Results: []Rule{
+			{RuleName: CapStatExistRule, Valid: true, Severity: SeverityError, Weight: SeverityError.DefaultWeight()},
+			{RuleName: KindRule, Valid: false, Severity: SeverityWarning, Weight: SeverityWarning.DefaultWeight()},
+			{RuleName: MessagingEndptRule, Valid: true, Severity: SeverityInfo, Weight: SeverityInfo.DefaultWeight()},
+		},
+	}
+
+	score := validation.ComputeScore()
+	if score != 4.0/6.0 {
+		t.Errorf("Expected score to be %f, got %f", 4.0/6.0, score)
+	}
+
+	// results without a weight are not counted
+	validation.Results = append(validation.Results, Rule{RuleName: TLSVersion, Valid: false})
+	score = validation.ComputeScore()
+	if score != 4.0/6.0 {
+		t.Errorf("Expected unweighted result to not change the score %f, got %f", 4.0/6.0, score)
+	}
+
+	validation.Results = []Rule{}
+	score = validation.ComputeScore()
+	if score != 0 {
+		t.Errorf("Expected score of validation without results to be 0, got %f", score)
+	}
+
+	if Severity("fatal").DefaultWeight() != 0 {
+		t.Errorf("Expected an unknown severity to have a default weight of 0")
+	}
+}
@@ -9,16 +9,28 @@ import (
 // FHIREndpointMetadata represents information about the request made
 // to the FHIR endpoint's capability statement and it's SMART on FHIR well-known configuration
 type FHIREndpointMetadata struct {
-	ID                   int
-	URL                  string
-	HTTPResponse         int
-	Errors               string
-	CreatedAt            time.Time
-	UpdatedAt            time.Time
-	SMARTHTTPResponse    int
-	ResponseTime         float64
-	Availability         float64
-	RequestedFhirVersion string
+	ID                        int
+	URL                       string
+	HTTPResponse              int
+	Errors                    string
+	CreatedAt                 time.Time
+	UpdatedAt                 time.Time
+	SMARTHTTPResponse         int
+	ResponseTime              float64
+	Availability              float64
+	RequestedFhirVersion      string
+	TLSCertificateFingerprint string
+	FinalURL                  string
+	Redirects                 []Redirect
+}
+
+// Redirect is a single redirect followed when requesting an endpoint. URL is the URL that was requested,
+// StatusCode is the HTTP status code of the redirect response and Location is the absolute URL the response
+// redirected to.
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location"`
 }
 
 // Equal checks each field of the two FHIREndpointMetadatass except for the database ID, CreatedAt and UpdatedAt fields to see if they are equal.
@@ -52,6 +64,20 @@ func (e *FHIREndpointMetadata) Equal(e2 *FHIREndpointMetadata) bool {
 	if e.RequestedFhirVersion != e2.RequestedFhirVersion {
 		return false
 	}
+	if e.TLSCertificateFingerprint != e2.TLSCertificateFingerprint {
+		return false
+	}
+	if e.FinalURL != e2.FinalURL {
+		return false
+	}
+	if len(e.Redirects) != len(e2.Redirects) {
+		return false
+	}
+	for i := range e.Redirects {
+		if e.Redirects[i] != e2.Redirects[i] {
+			return false
+		}
+	}
 
 	return true
 }
@@ -82,6 +82,12 @@ func Test_FHIREndpointMetadataEqual(t *testing.T) {
 	}
 	endpointMetadata2.RequestedFhirVersion = endpointMetadata1.RequestedFhirVersion
 
+	endpointMetadata2.Redirects = []Redirect{{URL: "http://www.example.com/metadata", StatusCode: 301, Location: "https://www.example.com/metadata"}}
+	if endpointMetadata1.Equal(endpointMetadata2) {
+		t.Errorf("Did not expect endpointMetadata1 to equal endpointMetadata2. Redirects should be different. %v vs %v", endpointMetadata1.Redirects, endpointMetadata2.Redirects)
+	}
+	endpointMetadata2.Redirects = endpointMetadata1.Redirects
+
 	endpointMetadata2 = nil
 	if endpointMetadata1.Equal(endpointMetadata2) {
 		t.Errorf("Did not expect endpointMetadata1 to equal nil endpointMetadata2.")
@@ -0,0 +1,24 @@
+package endpointmanager
+
+import "time"
+
+// FHIREndpointSchedule records when a FHIR endpoint is next due to be queried and the tier it was scheduled with
+type FHIREndpointSchedule struct {
+	URL           string
+	Tier          string
+	NextQueryAt   time.Time
+	LastQueriedAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// FHIREndpointScheduleInfo holds the information about a FHIR endpoint that is used to pick how often it is
+// queried. The time fields are zero if the event never happened, and Schedule is nil if the endpoint has not
+// been scheduled yet.
+type FHIREndpointScheduleInfo struct {
+	URL              string
+	CreatedAt        time.Time
+	LastSuccessfulAt time.Time
+	LastChangedAt    time.Time
+	Schedule         *FHIREndpointSchedule
+}
@@ -0,0 +1,148 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var addCapabilityChangeStatement *sql.Stmt
+var setCapabilityChangePublishedStatement *sql.Stmt
+
+// GetCapabilityChangesUsingURL gets the capability change records stored for the given URL, ordered from oldest
+// to newest
+func (s *Store) GetCapabilityChangesUsingURL(ctx context.Context, url string) ([]*endpointmanager.CapabilityChange, error) {
+	sqlStatement := `
+	SELECT
+		id,
+		url,
+		requested_fhir_version,
+		vendor_id,
+		changes,
+		created_at,
+		published_at
+	FROM capability_changes WHERE url=$1
+	ORDER BY created_at, id`
+
+	return s.getCapabilityChanges(ctx, sqlStatement, url)
+}
+
+// GetUnpublishedCapabilityChanges gets up to 'limit' capability change records that have not been published yet,
+// ordered from oldest to newest
+func (s *Store) GetUnpublishedCapabilityChanges(ctx context.Context, limit int) ([]*endpointmanager.CapabilityChange, error) {
+	sqlStatement := `
+	SELECT
+		id,
+		url,
+		requested_fhir_version,
+		vendor_id,
+		changes,
+		created_at,
+		published_at
+	FROM capability_changes WHERE published_at IS NULL
+	ORDER BY id
+	LIMIT $1`
+
+	return s.getCapabilityChanges(ctx, sqlStatement, limit)
+}
+
+func (s *Store) getCapabilityChanges(ctx context.Context, sqlStatement string, args ...interface{}) ([]*endpointmanager.CapabilityChange, error) {
+	var changes []*endpointmanager.CapabilityChange
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change endpointmanager.CapabilityChange
+		var requestedFhirVersion sql.NullString
+		var vendorIDNullable sql.NullInt64
+		var changesJSON []byte
+		var publishedAt sql.NullTime
+
+		err = rows.Scan(
+			&change.ID,
+			&change.URL,
+			&requestedFhirVersion,
+			&vendorIDNullable,
+			&changesJSON,
+			&change.CreatedAt,
+			&publishedAt)
+		if err != nil {
+			return nil, err
+		}
+		change.RequestedFhirVersion = requestedFhirVersion.String
+		ints := getRegularInts([]sql.NullInt64{vendorIDNullable})
+		change.VendorID = ints[0]
+		change.PublishedAt = publishedAt.Time
+		if changesJSON != nil {
+			err = json.Unmarshal(changesJSON, &change.Changes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, &change)
+	}
+	return changes, rows.Err()
+}
+
+// AddCapabilityChange adds the given capability change record to the database, unpublished, and sets its ID and
+// CreatedAt fields to the stored values
+func (s *Store) AddCapabilityChange(ctx context.Context, c *endpointmanager.CapabilityChange) error {
+	return addCapabilityChange(ctx, addCapabilityChangeStatement, c)
+}
+
+// addCapabilityChange adds the capability change record with the given insert statement, which is either the
+// prepared statement or the prepared statement for a transaction
+func addCapabilityChange(ctx context.Context, stmt *sql.Stmt, c *endpointmanager.CapabilityChange) error {
+	changesJSON, err := json.Marshal(c.Changes)
+	if err != nil {
+		return err
+	}
+
+	nullableInts := getNullableInts([]int{c.VendorID})
+
+	row := stmt.QueryRowContext(ctx,
+		c.URL,
+		c.RequestedFhirVersion,
+		nullableInts[0],
+		changesJSON)
+
+	return row.Scan(&c.ID, &c.CreatedAt)
+}
+
+// SetCapabilityChangePublished records that the given capability change record has been published, and sets its
+// PublishedAt field to the stored value
+func (s *Store) SetCapabilityChangePublished(ctx context.Context, c *endpointmanager.CapabilityChange) error {
+	row := setCapabilityChangePublishedStatement.QueryRowContext(ctx, c.ID)
+	return row.Scan(&c.PublishedAt)
+}
+
+func prepareCapabilityChangeStatements(s *Store) error {
+	var err error
+	addCapabilityChangeStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_changes (
+			url,
+			requested_fhir_version,
+			vendor_id,
+			changes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;`)
+	if err != nil {
+		return err
+	}
+	setCapabilityChangePublishedStatement, err = s.DB.Prepare(`
+		UPDATE capability_changes
+		SET published_at = NOW()
+		WHERE id = $1
+		RETURNING published_at;`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistCapabilityChange(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	change1 := endpointmanager.CapabilityChange{
+		URL:                  "http://example.com/fhir",
+		RequestedFhirVersion: "None",
+		Changes: []capabilitydiff.Change{
+			{Type: capabilitydiff.SearchParamChange, Action: capabilitydiff.Removed, Resource: "MedicationRequest", Value: "patient"},
+			{Type: capabilitydiff.FHIRVersionChange, Action: capabilitydiff.Changed, OldValue: "4.0.0", NewValue: "4.0.1"},
+		},
+	}
+	change2 := endpointmanager.CapabilityChange{
+		URL:                  "http://example.com/fhir",
+		RequestedFhirVersion: "4.0.1",
+		Changes: []capabilitydiff.Change{
+			{Type: capabilitydiff.ResourceChange, Action: capabilitydiff.Added, Resource: "Patient"},
+		},
+	}
+	otherChange := endpointmanager.CapabilityChange{
+		URL:     "http://other.example.com/fhir",
+		Changes: []capabilitydiff.Change{{Type: capabilitydiff.StatementChange, Action: capabilitydiff.Removed}},
+	}
+
+	// add capability changes
+
+	err = store.AddCapabilityChange(ctx, &change1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding capability change: %s", err))
+	th.Assert(t, change1.ID != 0, "Expected the capability change ID to be set")
+	th.Assert(t, !change1.CreatedAt.IsZero(), "Expected the capability change creation time to be set")
+
+	err = store.AddCapabilityChange(ctx, &change2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding capability change: %s", err))
+
+	err = store.AddCapabilityChange(ctx, &otherChange)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding capability change: %s", err))
+
+	// retrieve capability changes
+
+	changes, err := store.GetCapabilityChangesUsingURL(ctx, change1.URL)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting capability changes: %s", err))
+	th.Assert(t, len(changes) == 2, fmt.Sprintf("Expected 2 capability changes for %s, got %d", change1.URL, len(changes)))
+	th.Assert(t, changes[0].ID == change1.ID, fmt.Sprintf("Expected the first change to have ID %d, got %d", change1.ID, changes[0].ID))
+	th.Assert(t, changes[0].RequestedFhirVersion == "None", fmt.Sprintf("Expected requested version None, got %s", changes[0].RequestedFhirVersion))
+	th.Assert(t, reflect.DeepEqual(changes[0].Changes, change1.Changes), fmt.Sprintf("Expected changes %+v, got %+v", change1.Changes, changes[0].Changes))
+	th.Assert(t, reflect.DeepEqual(changes[1].Changes, change2.Changes), fmt.Sprintf("Expected changes %+v, got %+v", change2.Changes, changes[1].Changes))
+
+	changes, err = store.GetCapabilityChangesUsingURL(ctx, "http://none.example.com/fhir")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting capability changes: %s", err))
+	th.Assert(t, len(changes) == 0, fmt.Sprintf("Expected no capability changes, got %d", len(changes)))
+
+	// publish capability changes
+
+	changes, err = store.GetUnpublishedCapabilityChanges(ctx, 2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting unpublished capability changes: %s", err))
+	th.Assert(t, len(changes) == 2, fmt.Sprintf("Expected 2 unpublished capability changes, got %d", len(changes)))
+	th.Assert(t, changes[0].ID == change1.ID && changes[1].ID == change2.ID, "Expected the oldest unpublished capability changes")
+	th.Assert(t, changes[0].PublishedAt.IsZero(), "Expected the capability change to be unpublished")
+
+	err = store.SetCapabilityChangePublished(ctx, changes[0])
+	th.Assert(t, err == nil, fmt.Sprintf("Error setting capability change published: %s", err))
+	th.Assert(t, !changes[0].PublishedAt.IsZero(), "Expected the capability change published time to be set")
+
+	changes, err = store.GetUnpublishedCapabilityChanges(ctx, 10)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting unpublished capability changes: %s", err))
+	th.Assert(t, len(changes) == 2, fmt.Sprintf("Expected 2 unpublished capability changes, got %d", len(changes)))
+	th.Assert(t, changes[0].ID == change2.ID && changes[1].ID == otherChange.ID, "Expected the published capability change to be left out")
+}
@@ -0,0 +1,419 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var addCapabilityContentsStatement *sql.Stmt
+var addCapabilityRestStatement *sql.Stmt
+var addCapabilityResourceStatement *sql.Stmt
+var addCapabilityInteractionStatement *sql.Stmt
+var addCapabilitySearchParamStatement *sql.Stmt
+var addCapabilityOperationStatement *sql.Stmt
+var addCapabilitySearchIncludeStatement *sql.Stmt
+var addCapabilitySupportedProfileStatement *sql.Stmt
+var addCapabilitySecurityServiceStatement *sql.Stmt
+
+// GetCapabilityContents gets the capability statement contents stored with the given capability_contents_id.
+// If there are no stored rest entries for the ID, an empty CapabilityContents is returned.
+func (s *Store) GetCapabilityContents(ctx context.Context, id int) (*endpointmanager.CapabilityContents, error) {
+	var contents endpointmanager.CapabilityContents
+	// maps the database IDs of the rest and resource entries to their index in contents
+	restIndex := make(map[int64]int)
+	resourceIndex := make(map[int64][2]int)
+
+	rows, err := s.DB.QueryContext(ctx, `
+	SELECT id, mode
+	FROM capability_rest WHERE capability_contents_id=$1
+	ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var restID int64
+		var mode sql.NullString
+		err = rows.Scan(&restID, &mode)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		restIndex[restID] = len(contents.Rest)
+		contents.Rest = append(contents.Rest, endpointmanager.CapabilityRest{Mode: mode.String})
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT res.id, res.capability_rest_id, res.type, res.profile
+	FROM capability_resources AS res
+	JOIN capability_rest AS rest ON res.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1
+	ORDER BY res.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var resourceID int64
+		var restID int64
+		var resourceType sql.NullString
+		var profile sql.NullString
+		err = rows.Scan(&resourceID, &restID, &resourceType, &profile)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rest := &contents.Rest[restIndex[restID]]
+		resourceIndex[resourceID] = [2]int{restIndex[restID], len(rest.Resources)}
+		rest.Resources = append(rest.Resources, endpointmanager.CapabilityResource{
+			Type:    resourceType.String,
+			Profile: profile.String,
+		})
+	}
+	rows.Close()
+
+	// resource returns the resource with the given database ID, or nil if the ID is null
+	resource := func(resourceID sql.NullInt64) *endpointmanager.CapabilityResource {
+		if !resourceID.Valid {
+			return nil
+		}
+		index := resourceIndex[resourceID.Int64]
+		return &contents.Rest[index[0]].Resources[index[1]]
+	}
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT inter.capability_rest_id, inter.capability_resource_id, inter.code
+	FROM capability_interactions AS inter
+	JOIN capability_rest AS rest ON inter.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var restID int64
+		var resourceID sql.NullInt64
+		var code sql.NullString
+		err = rows.Scan(&restID, &resourceID, &code)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if res := resource(resourceID); res != nil {
+			res.Interactions = append(res.Interactions, code.String)
+		} else {
+			rest := &contents.Rest[restIndex[restID]]
+			rest.Interactions = append(rest.Interactions, code.String)
+		}
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT param.capability_rest_id, param.capability_resource_id, param.name, param.type, param.definition
+	FROM capability_search_params AS param
+	JOIN capability_rest AS rest ON param.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var restID int64
+		var resourceID sql.NullInt64
+		var name, paramType, definition sql.NullString
+		err = rows.Scan(&restID, &resourceID, &name, &paramType, &definition)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		param := endpointmanager.CapabilitySearchParam{
+			Name:       name.String,
+			Type:       paramType.String,
+			Definition: definition.String,
+		}
+		if res := resource(resourceID); res != nil {
+			res.SearchParams = append(res.SearchParams, param)
+		} else {
+			rest := &contents.Rest[restIndex[restID]]
+			rest.SearchParams = append(rest.SearchParams, param)
+		}
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT op.capability_rest_id, op.capability_resource_id, op.name, op.definition
+	FROM capability_operations AS op
+	JOIN capability_rest AS rest ON op.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var restID int64
+		var resourceID sql.NullInt64
+		var name, definition sql.NullString
+		err = rows.Scan(&restID, &resourceID, &name, &definition)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		op := endpointmanager.CapabilityOperation{
+			Name:       name.String,
+			Definition: definition.String,
+		}
+		if res := resource(resourceID); res != nil {
+			res.Operations = append(res.Operations, op)
+		} else {
+			rest := &contents.Rest[restIndex[restID]]
+			rest.Operations = append(rest.Operations, op)
+		}
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT inc.capability_resource_id, inc.include, inc.reverse
+	FROM capability_search_includes AS inc
+	JOIN capability_resources AS res ON inc.capability_resource_id = res.id
+	JOIN capability_rest AS rest ON res.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var resourceID sql.NullInt64
+		var include sql.NullString
+		var reverse bool
+		err = rows.Scan(&resourceID, &include, &reverse)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		res := resource(resourceID)
+		if reverse {
+			res.SearchRevIncludes = append(res.SearchRevIncludes, include.String)
+		} else {
+			res.SearchIncludes = append(res.SearchIncludes, include.String)
+		}
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT prof.capability_resource_id, prof.profile
+	FROM capability_supported_profiles AS prof
+	JOIN capability_resources AS res ON prof.capability_resource_id = res.id
+	JOIN capability_rest AS rest ON res.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var resourceID sql.NullInt64
+		var profile sql.NullString
+		err = rows.Scan(&resourceID, &profile)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		res := resource(resourceID)
+		res.SupportedProfiles = append(res.SupportedProfiles, profile.String)
+	}
+	rows.Close()
+
+	rows, err = s.DB.QueryContext(ctx, `
+	SELECT sec.capability_rest_id, sec.system, sec.code, sec.display
+	FROM capability_security_services AS sec
+	JOIN capability_rest AS rest ON sec.capability_rest_id = rest.id
+	WHERE rest.capability_contents_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var restID int64
+		var system, code, display sql.NullString
+		err = rows.Scan(&restID, &system, &code, &display)
+		if err != nil {
+			return nil, err
+		}
+		rest := &contents.Rest[restIndex[restID]]
+		rest.SecurityServices = append(rest.SecurityServices, endpointmanager.CapabilitySecurityService{
+			System:  system.String,
+			Code:    code.String,
+			Display: display.String,
+		})
+	}
+
+	return &contents, nil
+}
+
+// AddCapabilityContents adds the capability statement contents to the database and returns the
+// capability_contents_id that groups them together
+func (s *Store) AddCapabilityContents(ctx context.Context, c *endpointmanager.CapabilityContents) (int, error) {
+	var contentsID int
+
+	row := addCapabilityContentsStatement.QueryRowContext(ctx)
+	err := row.Scan(&contentsID)
+	if err != nil {
+		return 0, err
+	}
+	if c == nil {
+		return contentsID, nil
+	}
+
+	for _, rest := range c.Rest {
+		var restID int
+		row = addCapabilityRestStatement.QueryRowContext(ctx, contentsID, rest.Mode)
+		err = row.Scan(&restID)
+		if err != nil {
+			return 0, err
+		}
+
+		err = addCapabilityElements(ctx, restID, sql.NullInt64{}, rest.Interactions, rest.SearchParams, rest.Operations)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, service := range rest.SecurityServices {
+			_, err = addCapabilitySecurityServiceStatement.ExecContext(ctx,
+				restID,
+				service.System,
+				service.Code,
+				service.Display)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		for _, resource := range rest.Resources {
+			var resourceID int64
+			row = addCapabilityResourceStatement.QueryRowContext(ctx, restID, resource.Type, resource.Profile)
+			err = row.Scan(&resourceID)
+			if err != nil {
+				return 0, err
+			}
+			resourceIDNullable := sql.NullInt64{Int64: resourceID, Valid: true}
+
+			err = addCapabilityElements(ctx, restID, resourceIDNullable, resource.Interactions, resource.SearchParams, resource.Operations)
+			if err != nil {
+				return 0, err
+			}
+
+			for _, include := range resource.SearchIncludes {
+				_, err = addCapabilitySearchIncludeStatement.ExecContext(ctx, resourceID, include, false)
+				if err != nil {
+					return 0, err
+				}
+			}
+			for _, include := range resource.SearchRevIncludes {
+				_, err = addCapabilitySearchIncludeStatement.ExecContext(ctx, resourceID, include, true)
+				if err != nil {
+					return 0, err
+				}
+			}
+			for _, profile := range resource.SupportedProfiles {
+				_, err = addCapabilitySupportedProfileStatement.ExecContext(ctx, resourceID, profile)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	return contentsID, nil
+}
+
+// addCapabilityElements adds the interactions, search parameters and operations that are shared by rest
+// and resource entries. resourceID is null for the elements of the rest entry itself.
+func addCapabilityElements(ctx context.Context,
+	restID int,
+	resourceID sql.NullInt64,
+	interactions []string,
+	searchParams []endpointmanager.CapabilitySearchParam,
+	operations []endpointmanager.CapabilityOperation) error {
+	for _, code := range interactions {
+		_, err := addCapabilityInteractionStatement.ExecContext(ctx, restID, resourceID, code)
+		if err != nil {
+			return err
+		}
+	}
+	for _, param := range searchParams {
+		_, err := addCapabilitySearchParamStatement.ExecContext(ctx,
+			restID,
+			resourceID,
+			param.Name,
+			param.Type,
+			param.Definition)
+		if err != nil {
+			return err
+		}
+	}
+	for _, op := range operations {
+		_, err := addCapabilityOperationStatement.ExecContext(ctx, restID, resourceID, op.Name, op.Definition)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prepareCapabilityContentsStatements(s *Store) error {
+	var err error
+	addCapabilityContentsStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_contents (id)
+		VALUES (DEFAULT)
+		RETURNING id;`)
+	if err != nil {
+		return err
+	}
+	addCapabilityRestStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_rest (capability_contents_id, mode)
+		VALUES ($1, $2)
+		RETURNING id;`)
+	if err != nil {
+		return err
+	}
+	addCapabilityResourceStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_resources (capability_rest_id, type, profile)
+		VALUES ($1, $2, $3)
+		RETURNING id;`)
+	if err != nil {
+		return err
+	}
+	addCapabilityInteractionStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_interactions (capability_rest_id, capability_resource_id, code)
+		VALUES ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	addCapabilitySearchParamStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_search_params (capability_rest_id, capability_resource_id, name, type, definition)
+		VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return err
+	}
+	addCapabilityOperationStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_operations (capability_rest_id, capability_resource_id, name, definition)
+		VALUES ($1, $2, $3, $4)`)
+	if err != nil {
+		return err
+	}
+	addCapabilitySearchIncludeStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_search_includes (capability_resource_id, include, reverse)
+		VALUES ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	addCapabilitySupportedProfileStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_supported_profiles (capability_resource_id, profile)
+		VALUES ($1, $2)`)
+	if err != nil {
+		return err
+	}
+	addCapabilitySecurityServiceStatement, err = s.DB.Prepare(`
+		INSERT INTO capability_security_services (capability_rest_id, system, code, display)
+		VALUES ($1, $2, $3, $4)`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
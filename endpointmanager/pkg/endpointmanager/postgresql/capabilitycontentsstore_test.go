@@ -0,0 +1,108 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistCapabilityContents(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	testContents := endpointmanager.CapabilityContents{
+		Rest: []endpointmanager.CapabilityRest{
+			{
+				Mode:         "server",
+				Interactions: []string{"transaction", "search-system"},
+				SearchParams: []endpointmanager.CapabilitySearchParam{
+					{Name: "_lastUpdated", Type: "date"},
+				},
+				Operations: []endpointmanager.CapabilityOperation{
+					{Name: "export", Definition: "http://hl7.org/fhir/uv/bulkdata/OperationDefinition/export"},
+				},
+				SecurityServices: []endpointmanager.CapabilitySecurityService{
+					{System: "http://terminology.hl7.org/CodeSystem/restful-security-service", Code: "SMART-on-FHIR", Display: "SMART-on-FHIR"},
+				},
+				Resources: []endpointmanager.CapabilityResource{
+					{
+						Type:              "Observation",
+						Profile:           "http://hl7.org/fhir/StructureDefinition/Observation",
+						SupportedProfiles: []string{"http://hl7.org/fhir/us/core/StructureDefinition/us-core-smokingstatus"},
+						Interactions:      []string{"read", "search-type"},
+						SearchParams: []endpointmanager.CapabilitySearchParam{
+							{Name: "patient", Type: "reference", Definition: "http://hl7.org/fhir/us/core/SearchParameter/us-core-observation-patient"},
+							{Name: "code", Type: "token"},
+						},
+						SearchIncludes:    []string{"Observation:patient"},
+						SearchRevIncludes: []string{"Provenance:target"},
+					},
+					{
+						Type: "Patient",
+					},
+				},
+			},
+			{
+				Mode: "client",
+				Resources: []endpointmanager.CapabilityResource{
+					{
+						Type:         "Patient",
+						Interactions: []string{"read"},
+						Operations: []endpointmanager.CapabilityOperation{
+							{Name: "everything"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// add capability contents
+
+	contentsID1, err := store.AddCapabilityContents(ctx, &testContents)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding capability contents: %s", err))
+
+	contentsID2, err := store.AddCapabilityContents(ctx, &endpointmanager.CapabilityContents{})
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding empty capability contents: %s", err))
+	th.Assert(t, contentsID1 != contentsID2, "Expected the capability contents to have different IDs")
+
+	// retrieve capability contents
+
+	contents, err := store.GetCapabilityContents(ctx, contentsID1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting capability contents from ID %d, error: %s", contentsID1, err))
+	th.Assert(t, reflect.DeepEqual(*contents, testContents), fmt.Sprintf("Expected capability contents %+v, got %+v", testContents, *contents))
+
+	contents, err = store.GetCapabilityContents(ctx, contentsID2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting capability contents from ID %d, error: %s", contentsID2, err))
+	th.Assert(t, len(contents.Rest) == 0, fmt.Sprintf("ID %d should have no rest entries, has %d", contentsID2, len(contents.Rest)))
+
+	// check that the contents can be queried relationally
+
+	var count int
+	row := store.DB.QueryRow(`
+		SELECT COUNT(*) FROM capability_resources AS res
+		JOIN capability_rest AS rest ON res.capability_rest_id = rest.id
+		JOIN capability_search_includes AS inc ON inc.capability_resource_id = res.id
+		WHERE rest.capability_contents_id = $1 AND res.type = 'Observation' AND inc.reverse;`, contentsID1)
+	err = row.Scan(&count)
+	th.Assert(t, err == nil, fmt.Sprintf("Error querying the capability contents tables: %s", err))
+	th.Assert(t, count == 1, fmt.Sprintf("Expected 1 Observation _revinclude, got %d", count))
+
+	// deleting the contents removes all of their rows
+
+	_, err = store.DB.Exec("DELETE FROM capability_contents WHERE id = $1;", contentsID1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error deleting capability contents: %s", err))
+	row = store.DB.QueryRow("SELECT COUNT(*) FROM capability_interactions;")
+	err = row.Scan(&count)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting capability interactions count: %s", err))
+	th.Assert(t, count == 0, fmt.Sprintf("Expected the capability interactions to be deleted, %d remain", count))
+}
@@ -0,0 +1,219 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/pkg/errors"
+)
+
+// GetEndpointDeploymentInfos gets the capability statement, TLS certificate fingerprint and $versions response
+// of each FHIR endpoint that was queried without requesting a specific FHIR version. Endpoints whose stored
+// capability statement cannot be parsed are returned without a capability statement.
+func (s *Store) GetEndpointDeploymentInfos(ctx context.Context) ([]*endpointmanager.EndpointDeploymentInfo, error) {
+	var infos []*endpointmanager.EndpointDeploymentInfo
+
+	sqlStatement := `
+	SELECT
+		endpts_info.url,
+		endpts_info.capability_statement,
+		endpts_metadata.tls_certificate_fingerprint,
+		(SELECT versions_response FROM fhir_endpoints
+			WHERE fhir_endpoints.url = endpts_info.url AND versions_response IS NOT NULL
+			ORDER BY id LIMIT 1)
+	FROM fhir_endpoints_info AS endpts_info
+	LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id
+	WHERE endpts_info.requested_fhir_version = 'None'
+	ORDER BY endpts_info.url`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var info endpointmanager.EndpointDeploymentInfo
+		var capabilityStatementJSON []byte
+		var tlsCertificateFingerprint sql.NullString
+		var versionsResponseJSON []byte
+
+		err = rows.Scan(
+			&info.URL,
+			&capabilityStatementJSON,
+			&tlsCertificateFingerprint,
+			&versionsResponseJSON)
+		if err != nil {
+			return nil, err
+		}
+		info.TLSCertificateFingerprint = tlsCertificateFingerprint.String
+		// an unparsable capability statement is treated as missing so that one endpoint does not stop the others
+		// from being grouped
+		info.CapabilityStatement, _ = capabilityparser.NewCapabilityStatement(capabilityStatementJSON)
+		if versionsResponseJSON != nil {
+			err = json.Unmarshal(versionsResponseJSON, &info.VersionsResponse)
+			if err != nil {
+				return nil, errors.Wrap(err, "error unmarshalling JSON versions response")
+			}
+		}
+		infos = append(infos, &info)
+	}
+	return infos, rows.Err()
+}
+
+// GetDeploymentGroups gets all of the deployment groups along with the URLs of their endpoints
+func (s *Store) GetDeploymentGroups(ctx context.Context) ([]*endpointmanager.DeploymentGroup, error) {
+	sqlStatement := `
+	SELECT
+		groups.id,
+		groups.fingerprint,
+		groups.capability_statement_hash,
+		groups.software_name,
+		groups.software_version,
+		groups.implementation_host,
+		groups.tls_certificate_fingerprint,
+		groups.supported_versions,
+		ARRAY(SELECT url FROM deployment_group_endpoints WHERE deployment_group_id = groups.id ORDER BY url),
+		groups.created_at,
+		groups.updated_at
+	FROM deployment_groups AS groups
+	ORDER BY groups.id`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*endpointmanager.DeploymentGroup
+	for rows.Next() {
+		group, err := scanDeploymentGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// GetDeploymentGroupUsingURL gets the deployment group of the endpoint with the given URL. If the endpoint is not
+// in a deployment group, sql.ErrNoRows will be returned.
+func (s *Store) GetDeploymentGroupUsingURL(ctx context.Context, url string) (*endpointmanager.DeploymentGroup, error) {
+	sqlStatement := `
+	SELECT
+		groups.id,
+		groups.fingerprint,
+		groups.capability_statement_hash,
+		groups.software_name,
+		groups.software_version,
+		groups.implementation_host,
+		groups.tls_certificate_fingerprint,
+		groups.supported_versions,
+		ARRAY(SELECT url FROM deployment_group_endpoints WHERE deployment_group_id = groups.id ORDER BY url),
+		groups.created_at,
+		groups.updated_at
+	FROM deployment_groups AS groups
+	JOIN deployment_group_endpoints AS members ON members.deployment_group_id = groups.id
+	WHERE members.url = $1`
+
+	row := s.DB.QueryRowContext(ctx, sqlStatement, url)
+	return scanDeploymentGroup(row)
+}
+
+// SaveDeploymentGroups replaces the stored deployment groups with the given groups. Groups whose fingerprint is
+// already stored keep their ID, and groups that are no longer found are deleted. The ID of each of the given
+// groups is set to its stored ID.
+func (s *Store) SaveDeploymentGroups(ctx context.Context, groups []*endpointmanager.DeploymentGroup) (err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM deployment_group_endpoints")
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		row := tx.QueryRowContext(ctx, `
+		INSERT INTO deployment_groups (
+			fingerprint,
+			capability_statement_hash,
+			software_name,
+			software_version,
+			implementation_host,
+			tls_certificate_fingerprint,
+			supported_versions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (fingerprint) DO UPDATE SET updated_at = NOW()
+		RETURNING id`,
+			group.Fingerprint,
+			group.CapabilityStatementHash,
+			group.SoftwareName,
+			group.SoftwareVersion,
+			group.ImplementationHost,
+			group.TLSCertificateFingerprint,
+			pq.Array(group.SupportedVersions))
+		err = row.Scan(&group.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, url := range group.URLs {
+			_, err = tx.ExecContext(ctx, "INSERT INTO deployment_group_endpoints (url, deployment_group_id) VALUES ($1, $2)", url, group.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM deployment_groups
+		WHERE NOT EXISTS (SELECT 1 FROM deployment_group_endpoints WHERE deployment_group_id = deployment_groups.id)`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanDeploymentGroup scans a deployment group row selected by GetDeploymentGroups or GetDeploymentGroupUsingURL
+func scanDeploymentGroup(row interface{ Scan(...interface{}) error }) (*endpointmanager.DeploymentGroup, error) {
+	var group endpointmanager.DeploymentGroup
+	var capabilityStatementHash sql.NullString
+	var softwareName sql.NullString
+	var softwareVersion sql.NullString
+	var implementationHost sql.NullString
+	var tlsCertificateFingerprint sql.NullString
+
+	err := row.Scan(
+		&group.ID,
+		&group.Fingerprint,
+		&capabilityStatementHash,
+		&softwareName,
+		&softwareVersion,
+		&implementationHost,
+		&tlsCertificateFingerprint,
+		pq.Array(&group.SupportedVersions),
+		pq.Array(&group.URLs),
+		&group.CreatedAt,
+		&group.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	group.CapabilityStatementHash = capabilityStatementHash.String
+	group.SoftwareName = softwareName.String
+	group.SoftwareVersion = softwareVersion.String
+	group.ImplementationHost = implementationHost.String
+	group.TLSCertificateFingerprint = tlsCertificateFingerprint.String
+	return &group, nil
+}
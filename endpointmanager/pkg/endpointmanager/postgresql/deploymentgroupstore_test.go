@@ -0,0 +1,106 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistDeploymentGroups(t *testing.T) {
+	SetupStore()
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	// endpoint deployment information
+
+	path := filepath.Join("../../testdata", "cerner_capability_dstu2.json")
+	csJSON, err := ioutil.ReadFile(path)
+	th.Assert(t, err == nil, err)
+	cs, err := capabilityparser.NewCapabilityStatement(csJSON)
+	th.Assert(t, err == nil, err)
+
+	metadata := &endpointmanager.FHIREndpointMetadata{
+		URL:                       "http://example.com/tenant1/fhir",
+		HTTPResponse:              200,
+		RequestedFhirVersion:      "None",
+		TLSCertificateFingerprint: "abc123",
+	}
+	metadataID, err := store.AddFHIREndpointMetadata(ctx, metadata)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint metadata: %s", err))
+
+	storedMetadata, err := store.GetFHIREndpointMetadata(ctx, metadataID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting fhir endpoint metadata: %s", err))
+	th.Assert(t, storedMetadata.TLSCertificateFingerprint == "abc123", fmt.Sprintf("Expected TLS certificate fingerprint abc123, got %s", storedMetadata.TLSCertificateFingerprint))
+
+	endpointInfo := &endpointmanager.FHIREndpointInfo{
+		URL:                  metadata.URL,
+		CapabilityStatement:  cs,
+		RequestedFhirVersion: "None",
+		Metadata:             metadata,
+	}
+	err = store.AddFHIREndpointInfo(ctx, endpointInfo, metadataID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint info: %s", err))
+
+	infos, err := store.GetEndpointDeploymentInfos(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting endpoint deployment infos: %s", err))
+	th.Assert(t, len(infos) == 1, fmt.Sprintf("Expected 1 endpoint deployment info, got %d", len(infos)))
+	th.Assert(t, infos[0].TLSCertificateFingerprint == "abc123", fmt.Sprintf("Expected TLS certificate fingerprint abc123, got %s", infos[0].TLSCertificateFingerprint))
+	th.Assert(t, infos[0].CapabilityStatement != nil, "Expected the endpoint capability statement to be set")
+
+	// save deployment groups
+
+	group1 := &endpointmanager.DeploymentGroup{
+		Fingerprint:       "fingerprint1",
+		SoftwareName:      "EHR",
+		SoftwareVersion:   "1.0",
+		SupportedVersions: []string{"3.0", "4.0"},
+		URLs:              []string{"http://example.com/tenant1/fhir", "http://example.com/tenant2/fhir"},
+	}
+	group2 := &endpointmanager.DeploymentGroup{
+		Fingerprint: "fingerprint2",
+		URLs:        []string{"http://other.example.com/fhir"},
+	}
+	err = store.SaveDeploymentGroups(ctx, []*endpointmanager.DeploymentGroup{group1, group2})
+	th.Assert(t, err == nil, fmt.Sprintf("Error saving deployment groups: %s", err))
+	th.Assert(t, group1.ID != 0 && group2.ID != 0, "Expected the deployment group IDs to be set")
+
+	groups, err := store.GetDeploymentGroups(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting deployment groups: %s", err))
+	th.Assert(t, len(groups) == 2, fmt.Sprintf("Expected 2 deployment groups, got %d", len(groups)))
+	th.Assert(t, reflect.DeepEqual(groups[0].URLs, group1.URLs), fmt.Sprintf("Expected URLs %v, got %v", group1.URLs, groups[0].URLs))
+	th.Assert(t, reflect.DeepEqual(groups[0].SupportedVersions, group1.SupportedVersions), fmt.Sprintf("Expected supported versions %v, got %v", group1.SupportedVersions, groups[0].SupportedVersions))
+
+	group, err := store.GetDeploymentGroupUsingURL(ctx, "http://example.com/tenant2/fhir")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting deployment group: %s", err))
+	th.Assert(t, group.ID == group1.ID, fmt.Sprintf("Expected deployment group %d, got %d", group1.ID, group.ID))
+
+	// saving again keeps the ID of groups that are still found and deletes the others
+
+	group1Again := &endpointmanager.DeploymentGroup{
+		Fingerprint: "fingerprint1",
+		URLs:        []string{"http://example.com/tenant1/fhir"},
+	}
+	err = store.SaveDeploymentGroups(ctx, []*endpointmanager.DeploymentGroup{group1Again})
+	th.Assert(t, err == nil, fmt.Sprintf("Error saving deployment groups: %s", err))
+	th.Assert(t, group1Again.ID == group1.ID, fmt.Sprintf("Expected deployment group to keep ID %d, got %d", group1.ID, group1Again.ID))
+
+	groups, err = store.GetDeploymentGroups(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting deployment groups: %s", err))
+	th.Assert(t, len(groups) == 1, fmt.Sprintf("Expected 1 deployment group, got %d", len(groups)))
+
+	_, err = store.GetDeploymentGroupUsingURL(ctx, "http://example.com/tenant2/fhir")
+	th.Assert(t, err == sql.ErrNoRows, fmt.Sprintf("Expected no deployment group, got error %v", err))
+}
@@ -0,0 +1,172 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var addFHIREndpointAliasStatement *sql.Stmt
+var updateFHIREndpointAliasStatement *sql.Stmt
+var getCanonicalFHIREndpointURLStatement *sql.Stmt
+
+// GetFHIREndpointAlias gets the alias of the endpoint with the given URL. If the alias does not exist in the
+// database, sql.ErrNoRows will be returned.
+func (s *Store) GetFHIREndpointAlias(ctx context.Context, url string) (*endpointmanager.FHIREndpointAlias, error) {
+	sqlStatement := `
+	SELECT
+		url,
+		canonical_url,
+		final_url,
+		created_at,
+		updated_at
+	FROM fhir_endpoint_aliases WHERE url=$1`
+
+	row := s.DB.QueryRowContext(ctx, sqlStatement, url)
+	return scanFHIREndpointAlias(row)
+}
+
+// GetCanonicalFHIREndpointURL gets the URL that an endpoint with the given URL is stored under. If the given URL
+// has the same canonical URL as endpoints that are already in the fhir_endpoints table, the URL of the first of
+// those endpoints to be added is returned, so that aliases of an endpoint are stored as one endpoint. Otherwise the
+// given URL is returned. The canonical URL is the one in the URL's alias if it has one, since that is found by
+// following redirects, and is built from the URL otherwise.
+func (s *Store) GetCanonicalFHIREndpointURL(ctx context.Context, url string) (string, error) {
+	var canonicalEndpointURL string
+
+	row := getCanonicalFHIREndpointURLStatement.QueryRowContext(ctx, url, endpointmanager.CanonicalURL(url))
+	err := row.Scan(&canonicalEndpointURL)
+	if err == sql.ErrNoRows {
+		return url, nil
+	}
+	return canonicalEndpointURL, err
+}
+
+// GetFHIREndpointAliasBackfill gets the alias of every endpoint URL that has one, along with an alias for each
+// endpoint in the fhir_endpoints table that does not have one yet. The aliases of the endpoints without one have
+// an empty canonical URL, the final URL of the endpoint's last query and the time the endpoint was first added.
+func (s *Store) GetFHIREndpointAliasBackfill(ctx context.Context) ([]*endpointmanager.FHIREndpointAlias, error) {
+	var aliases []*endpointmanager.FHIREndpointAlias
+
+	sqlStatement := `
+	SELECT
+		url,
+		canonical_url,
+		final_url,
+		created_at,
+		updated_at
+	FROM fhir_endpoint_aliases
+	UNION ALL
+	SELECT
+		endpts.url,
+		'',
+		MAX(endpts_metadata.final_url),
+		MIN(endpts.created_at),
+		MIN(endpts.created_at)
+	FROM fhir_endpoints AS endpts
+	LEFT JOIN fhir_endpoints_info AS endpts_info ON endpts.url = endpts_info.url AND endpts_info.requested_fhir_version = 'None'
+	LEFT JOIN fhir_endpoints_metadata AS endpts_metadata ON endpts_info.metadata_id = endpts_metadata.id AND endpts_metadata.final_url <> ''
+	WHERE NOT EXISTS (SELECT 1 FROM fhir_endpoint_aliases WHERE fhir_endpoint_aliases.url = endpts.url)
+	GROUP BY endpts.url`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		alias, err := scanFHIREndpointAlias(rows)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// AddFHIREndpointAlias adds the given alias to the database if there is no alias for its URL yet. An existing
+// alias is left as is, so that a canonical URL found by following redirects is not replaced. The alias is created
+// at the given CreatedAt time if it is set, and now otherwise.
+func (s *Store) AddFHIREndpointAlias(ctx context.Context, a *endpointmanager.FHIREndpointAlias) error {
+	_, err := addFHIREndpointAliasStatement.ExecContext(ctx,
+		a.URL,
+		a.CanonicalURL,
+		a.FinalURL,
+		sql.NullTime{Time: a.CreatedAt, Valid: !a.CreatedAt.IsZero()})
+	return err
+}
+
+// UpdateFHIREndpointAlias adds the given alias to the database, replacing the canonical and final URLs of any
+// existing alias for its URL
+func (s *Store) UpdateFHIREndpointAlias(ctx context.Context, a *endpointmanager.FHIREndpointAlias) error {
+	_, err := updateFHIREndpointAliasStatement.ExecContext(ctx,
+		a.URL,
+		a.CanonicalURL,
+		a.FinalURL)
+	return err
+}
+
+// DeleteFHIREndpointAlias deletes the alias of the endpoint with the given URL
+func (s *Store) DeleteFHIREndpointAlias(ctx context.Context, url string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM fhir_endpoint_aliases WHERE url=$1", url)
+	return err
+}
+
+func scanFHIREndpointAlias(row interface{ Scan(...interface{}) error }) (*endpointmanager.FHIREndpointAlias, error) {
+	var alias endpointmanager.FHIREndpointAlias
+	var finalURL sql.NullString
+
+	err := row.Scan(
+		&alias.URL,
+		&alias.CanonicalURL,
+		&finalURL,
+		&alias.CreatedAt,
+		&alias.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	alias.FinalURL = finalURL.String
+	return &alias, nil
+}
+
+func prepareFHIREndpointAliasStatements(s *Store) error {
+	var err error
+	addFHIREndpointAliasStatement, err = s.DB.Prepare(`
+		INSERT INTO fhir_endpoint_aliases (
+			url,
+			canonical_url,
+			final_url,
+			created_at)
+		VALUES ($1, $2, $3, COALESCE($4::timestamptz, NOW()))
+		ON CONFLICT (url) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	updateFHIREndpointAliasStatement, err = s.DB.Prepare(`
+		INSERT INTO fhir_endpoint_aliases (
+			url,
+			canonical_url,
+			final_url)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (url) DO UPDATE SET
+			canonical_url = EXCLUDED.canonical_url,
+			final_url = EXCLUDED.final_url,
+			updated_at = NOW()`)
+	if err != nil {
+		return err
+	}
+	getCanonicalFHIREndpointURLStatement, err = s.DB.Prepare(`
+		SELECT aliases.url
+		FROM fhir_endpoint_aliases AS aliases
+		WHERE aliases.canonical_url = COALESCE((SELECT canonical_url FROM fhir_endpoint_aliases WHERE url = $1), $2)
+			AND EXISTS (SELECT 1 FROM fhir_endpoints WHERE fhir_endpoints.url = aliases.url)
+		ORDER BY aliases.created_at, aliases.url
+		LIMIT 1`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -29,9 +29,11 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 	var supportedProfilesJSON []byte
 	var healthitProductIDNullable sql.NullInt64
 	var validationResultIDNullable sql.NullInt64
+	var capabilityContentsIDNullable sql.NullInt64
 	var vendorIDNullable sql.NullInt64
 	var smartResponseJSON []byte
 	var operResourceJSON []byte
+	var clientOperResourceJSON []byte
 	var metadataID int
 
 	sqlStatementInfo := `
@@ -48,11 +50,13 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 		smart_response,
 		included_fields,
 		operation_resource,
+		client_operation_resource,
 		supported_profiles,
 		validation_result_id,
 		metadata_id,
 		requested_fhir_version,
-		capability_fhir_version
+		capability_fhir_version,
+		capability_contents_id
 	FROM fhir_endpoints_info WHERE id=$1`
 	row := s.DB.QueryRowContext(ctx, sqlStatementInfo, id)
 
@@ -69,11 +73,13 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 		&smartResponseJSON,
 		&includedFieldsJSON,
 		&operResourceJSON,
+		&clientOperResourceJSON,
 		&supportedProfilesJSON,
 		&validationResultIDNullable,
 		&metadataID,
 		&endpointInfo.RequestedFhirVersion,
-		&endpointInfo.CapabilityFhirVersion)
+		&endpointInfo.CapabilityFhirVersion,
+		&capabilityContentsIDNullable)
 	if err != nil {
 		return nil, err
 	}
@@ -85,10 +91,11 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 		}
 	}
 
-	ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable})
+	ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable, capabilityContentsIDNullable})
 	endpointInfo.HealthITProductID = ints[0]
 	endpointInfo.VendorID = ints[1]
 	endpointInfo.ValidationID = ints[2]
+	endpointInfo.CapabilityContentsID = ints[3]
 
 	if includedFieldsJSON != nil {
 		err = json.Unmarshal(includedFieldsJSON, &endpointInfo.IncludedFields)
@@ -102,6 +109,12 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 			return nil, err
 		}
 	}
+	if clientOperResourceJSON != nil {
+		err = json.Unmarshal(clientOperResourceJSON, &endpointInfo.ClientOperationResource)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if supportedProfilesJSON != nil {
 		err = json.Unmarshal(supportedProfilesJSON, &endpointInfo.SupportedProfiles)
 		if err != nil {
@@ -129,6 +142,7 @@ func (s *Store) GetFHIREndpointInfo(ctx context.Context, id int) (*endpointmanag
 func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([]*endpointmanager.FHIREndpointInfo, error) {
 	var endpointInfos []*endpointmanager.FHIREndpointInfo
 	var operResourceJSON []byte
+	var clientOperResourceJSON []byte
 	sqlStatementInfo := `
 	SELECT
 		id,
@@ -144,10 +158,12 @@ func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([
 		smart_response,
 		included_fields,
 		operation_resource,
+		client_operation_resource,
 		supported_profiles,
 		metadata_id,
 		requested_fhir_version,
-		capability_fhir_version
+		capability_fhir_version,
+		capability_contents_id
 	FROM fhir_endpoints_info WHERE fhir_endpoints_info.url = $1`
 
 	rows, err := s.DB.QueryContext(ctx, sqlStatementInfo, url)
@@ -162,6 +178,7 @@ func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([
 		var supportedProfilesJSON []byte
 		var healthitProductIDNullable sql.NullInt64
 		var validationResultIDNullable sql.NullInt64
+		var capabilityContentsIDNullable sql.NullInt64
 		var vendorIDNullable sql.NullInt64
 		var smartResponseJSON []byte
 		var metadataID int
@@ -180,10 +197,12 @@ func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([
 			&smartResponseJSON,
 			&includedFieldsJSON,
 			&operResourceJSON,
+			&clientOperResourceJSON,
 			&supportedProfilesJSON,
 			&metadataID,
 			&endpointInfo.RequestedFhirVersion,
-			&endpointInfo.CapabilityFhirVersion)
+			&endpointInfo.CapabilityFhirVersion,
+			&capabilityContentsIDNullable)
 		if err != nil {
 			return nil, err
 		}
@@ -195,10 +214,11 @@ func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([
 			}
 		}
 
-		ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable})
+		ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable, capabilityContentsIDNullable})
 		endpointInfo.HealthITProductID = ints[0]
 		endpointInfo.VendorID = ints[1]
 		endpointInfo.ValidationID = ints[2]
+		endpointInfo.CapabilityContentsID = ints[3]
 
 		if includedFieldsJSON != nil {
 			err = json.Unmarshal(includedFieldsJSON, &endpointInfo.IncludedFields)
@@ -207,6 +227,20 @@ func (s *Store) GetFHIREndpointInfosUsingURL(ctx context.Context, url string) ([
 			}
 		}
 
+		if operResourceJSON != nil {
+			err = json.Unmarshal(operResourceJSON, &endpointInfo.OperationResource)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if clientOperResourceJSON != nil {
+			err = json.Unmarshal(clientOperResourceJSON, &endpointInfo.ClientOperationResource)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if supportedProfilesJSON != nil {
 			err = json.Unmarshal(supportedProfilesJSON, &endpointInfo.SupportedProfiles)
 			if err != nil {
@@ -242,9 +276,11 @@ func (s *Store) GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx context.Conte
 	var supportedProfilesJSON []byte
 	var healthitProductIDNullable sql.NullInt64
 	var validationResultIDNullable sql.NullInt64
+	var capabilityContentsIDNullable sql.NullInt64
 	var vendorIDNullable sql.NullInt64
 	var smartResponseJSON []byte
 	var operResourceJSON []byte
+	var clientOperResourceJSON []byte
 	var metadataID int
 
 	sqlStatementInfo := `
@@ -261,11 +297,13 @@ func (s *Store) GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx context.Conte
 		smart_response,
 		included_fields,
 		operation_resource,
+		client_operation_resource,
 		supported_profiles,
 		validation_result_id,
 		metadata_id,
 		requested_fhir_version,
-		capability_fhir_version
+		capability_fhir_version,
+		capability_contents_id
 	FROM fhir_endpoints_info WHERE fhir_endpoints_info.url = $1 AND fhir_endpoints_info.requested_fhir_version = $2`
 
 	row := s.DB.QueryRowContext(ctx, sqlStatementInfo, url, requestedVersion)
@@ -283,11 +321,13 @@ func (s *Store) GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx context.Conte
 		&smartResponseJSON,
 		&includedFieldsJSON,
 		&operResourceJSON,
+		&clientOperResourceJSON,
 		&supportedProfilesJSON,
 		&validationResultIDNullable,
 		&metadataID,
 		&endpointInfo.RequestedFhirVersion,
-		&endpointInfo.CapabilityFhirVersion)
+		&endpointInfo.CapabilityFhirVersion,
+		&capabilityContentsIDNullable)
 	if err != nil {
 		return nil, err
 	}
@@ -299,10 +339,11 @@ func (s *Store) GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx context.Conte
 		}
 	}
 
-	ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable})
+	ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable, capabilityContentsIDNullable})
 	endpointInfo.HealthITProductID = ints[0]
 	endpointInfo.VendorID = ints[1]
 	endpointInfo.ValidationID = ints[2]
+	endpointInfo.CapabilityContentsID = ints[3]
 
 	if includedFieldsJSON != nil {
 		err = json.Unmarshal(includedFieldsJSON, &endpointInfo.IncludedFields)
@@ -317,6 +358,12 @@ func (s *Store) GetFHIREndpointInfoUsingURLAndRequestedVersion(ctx context.Conte
 			return nil, err
 		}
 	}
+	if clientOperResourceJSON != nil {
+		err = json.Unmarshal(clientOperResourceJSON, &endpointInfo.ClientOperationResource)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if supportedProfilesJSON != nil {
 		err = json.Unmarshal(supportedProfilesJSON, &endpointInfo.SupportedProfiles)
@@ -347,8 +394,18 @@ func (s *Store) GetFHIREndpointInfoValidation(ctx context.Context, e *endpointma
 	if err != nil {
 		return nil, err
 	}
+	score, err := s.GetValidationScoreByID(ctx, e.ValidationID)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := s.GetValidationIssuesByID(ctx, e.ValidationID)
+	if err != nil {
+		return nil, err
+	}
 	validationObj := endpointmanager.Validation{
 		Results: *validationRows,
+		Score:   score,
+		Issues:  issues,
 	}
 	return &validationObj, nil
 }
@@ -374,6 +431,11 @@ func (s *Store) AddFHIREndpointInfo(ctx context.Context, e *endpointmanager.FHIR
 		return err
 	}
 
+	clientOperResourceJSON, err := json.Marshal(e.ClientOperationResource)
+	if err != nil {
+		return err
+	}
+
 	supportedProfilesJSON, err := json.Marshal(e.SupportedProfiles)
 	if err != nil {
 		return err
@@ -386,7 +448,7 @@ func (s *Store) AddFHIREndpointInfo(ctx context.Context, e *endpointmanager.FHIR
 		smartResponseJSON = []byte("null")
 	}
 
-	nullableInts := getNullableInts([]int{e.HealthITProductID, e.VendorID, e.ValidationID})
+	nullableInts := getNullableInts([]int{e.HealthITProductID, e.VendorID, e.ValidationID, e.CapabilityContentsID})
 
 	row := addFHIREndpointInfoStatement.QueryRowContext(ctx,
 		e.URL,
@@ -402,7 +464,9 @@ func (s *Store) AddFHIREndpointInfo(ctx context.Context, e *endpointmanager.FHIR
 		nullableInts[2],
 		metadataID,
 		e.RequestedFhirVersion,
-		e.CapabilityFhirVersion)
+		e.CapabilityFhirVersion,
+		nullableInts[3],
+		clientOperResourceJSON)
 
 	err = row.Scan(&e.ID)
 
@@ -411,6 +475,38 @@ func (s *Store) AddFHIREndpointInfo(ctx context.Context, e *endpointmanager.FHIR
 
 // UpdateFHIREndpointInfo updates the FHIREndpointInfo in the database using the FHIREndpointInfo's database id as the key.
 func (s *Store) UpdateFHIREndpointInfo(ctx context.Context, e *endpointmanager.FHIREndpointInfo, metadataID int) error {
+	return updateFHIREndpointInfo(ctx, updateFHIREndpointInfoStatement, e, metadataID)
+}
+
+// UpdateFHIREndpointInfoAndAddCapabilityChange updates the FHIREndpointInfo like UpdateFHIREndpointInfo and adds the
+// given capability change record in the same transaction, so that the change is only stored if the update is. The
+// change record is added unpublished, so that it can be published from the database once the update is committed.
+func (s *Store) UpdateFHIREndpointInfoAndAddCapabilityChange(ctx context.Context, e *endpointmanager.FHIREndpointInfo, metadataID int, c *endpointmanager.CapabilityChange) (err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = updateFHIREndpointInfo(ctx, tx.StmtContext(ctx, updateFHIREndpointInfoStatement), e, metadataID)
+	if err != nil {
+		return err
+	}
+	err = addCapabilityChange(ctx, tx.StmtContext(ctx, addCapabilityChangeStatement), c)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateFHIREndpointInfo updates the FHIREndpointInfo with the given update statement, which is either the
+// prepared statement or the prepared statement for a transaction
+func updateFHIREndpointInfo(ctx context.Context, stmt *sql.Stmt, e *endpointmanager.FHIREndpointInfo, metadataID int) error {
 	var err error
 	var capabilityStatementJSON []byte
 
@@ -435,6 +531,11 @@ func (s *Store) UpdateFHIREndpointInfo(ctx context.Context, e *endpointmanager.F
 		return err
 	}
 
+	clientOperResourceJSON, err := json.Marshal(e.ClientOperationResource)
+	if err != nil {
+		return err
+	}
+
 	supportedProfilesJSON, err := json.Marshal(e.SupportedProfiles)
 	if err != nil {
 		return err
@@ -452,9 +553,9 @@ func (s *Store) UpdateFHIREndpointInfo(ctx context.Context, e *endpointmanager.F
 		smartResponseJSON = []byte("null")
 	}
 
-	nullableInts := getNullableInts([]int{e.HealthITProductID, e.VendorID, e.ValidationID})
+	nullableInts := getNullableInts([]int{e.HealthITProductID, e.VendorID, e.ValidationID, e.CapabilityContentsID})
 
-	_, err = updateFHIREndpointInfoStatement.ExecContext(ctx,
+	_, err = stmt.ExecContext(ctx,
 		e.URL,
 		nullableInts[0],
 		nullableInts[1],
@@ -469,6 +570,8 @@ func (s *Store) UpdateFHIREndpointInfo(ctx context.Context, e *endpointmanager.F
 		metadataID,
 		e.RequestedFhirVersion,
 		e.CapabilityFhirVersion,
+		nullableInts[3],
+		clientOperResourceJSON,
 		e.ID)
 
 	return err
@@ -502,6 +605,7 @@ func (s *Store) DeleteFHIREndpointInfo(ctx context.Context, e *endpointmanager.F
 func (s *Store) GetFHIREndpointInfosByURLWithDifferentRequestedVersion(ctx context.Context, url string, versions []string) ([]*endpointmanager.FHIREndpointInfo, error) {
 	var endpointInfos []*endpointmanager.FHIREndpointInfo
 	var operResourceJSON []byte
+	var clientOperResourceJSON []byte
 
 	// Convert array of strings to a string that postgres can convert back to an sql ARRAY
 	versionsString := strings.Join(versions, ",")
@@ -518,6 +622,7 @@ func (s *Store) GetFHIREndpointInfosByURLWithDifferentRequestedVersion(ctx conte
 		var supportedProfilesJSON []byte
 		var healthitProductIDNullable sql.NullInt64
 		var validationResultIDNullable sql.NullInt64
+		var capabilityContentsIDNullable sql.NullInt64
 		var vendorIDNullable sql.NullInt64
 		var smartResponseJSON []byte
 		var metadataID int
@@ -536,10 +641,12 @@ func (s *Store) GetFHIREndpointInfosByURLWithDifferentRequestedVersion(ctx conte
 			&smartResponseJSON,
 			&includedFieldsJSON,
 			&operResourceJSON,
+			&clientOperResourceJSON,
 			&supportedProfilesJSON,
 			&metadataID,
 			&endpointInfo.RequestedFhirVersion,
-			&endpointInfo.CapabilityFhirVersion)
+			&endpointInfo.CapabilityFhirVersion,
+			&capabilityContentsIDNullable)
 		if err != nil {
 			return nil, err
 		}
@@ -551,10 +658,11 @@ func (s *Store) GetFHIREndpointInfosByURLWithDifferentRequestedVersion(ctx conte
 			}
 		}
 
-		ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable})
+		ints := getRegularInts([]sql.NullInt64{healthitProductIDNullable, vendorIDNullable, validationResultIDNullable, capabilityContentsIDNullable})
 		endpointInfo.HealthITProductID = ints[0]
 		endpointInfo.VendorID = ints[1]
 		endpointInfo.ValidationID = ints[2]
+		endpointInfo.CapabilityContentsID = ints[3]
 
 		if includedFieldsJSON != nil {
 			err = json.Unmarshal(includedFieldsJSON, &endpointInfo.IncludedFields)
@@ -563,6 +671,20 @@ func (s *Store) GetFHIREndpointInfosByURLWithDifferentRequestedVersion(ctx conte
 			}
 		}
 
+		if operResourceJSON != nil {
+			err = json.Unmarshal(operResourceJSON, &endpointInfo.OperationResource)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if clientOperResourceJSON != nil {
+			err = json.Unmarshal(clientOperResourceJSON, &endpointInfo.ClientOperationResource)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if supportedProfilesJSON != nil {
 			err = json.Unmarshal(supportedProfilesJSON, &endpointInfo.SupportedProfiles)
 			if err != nil {
@@ -607,8 +729,10 @@ func prepareFHIREndpointInfoStatements(s *Store) error {
 			validation_result_id,
 			metadata_id,
 			requested_fhir_version,
-			capability_fhir_version)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			capability_fhir_version,
+			capability_contents_id,
+			client_operation_resource)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING id`)
 	if err != nil {
 		return err
@@ -629,8 +753,10 @@ func prepareFHIREndpointInfoStatements(s *Store) error {
 			validation_result_id = $11,
 			metadata_id = $12,
 			requested_fhir_version = $13,
-			capability_fhir_version = $14		
-		WHERE id = $15`)
+			capability_fhir_version = $14,
+			capability_contents_id = $15,
+			client_operation_resource = $16
+		WHERE id = $17`)
 	if err != nil {
 		return err
 	}
@@ -663,10 +789,12 @@ func prepareFHIREndpointInfoStatements(s *Store) error {
 		smart_response,
 		included_fields,
 		operation_resource,
+		client_operation_resource,
 		supported_profiles,
 		metadata_id,
 		requested_fhir_version,
-		capability_fhir_version
+		capability_fhir_version,
+		capability_contents_id
 		FROM fhir_endpoints_info WHERE fhir_endpoints_info.url = $1 AND NOT (fhir_endpoints_info.requested_fhir_version = ANY (string_to_array($2,',','')))`)
 	if err != nil {
 		return err
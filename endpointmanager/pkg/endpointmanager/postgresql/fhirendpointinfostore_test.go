@@ -9,8 +9,10 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilitydiff"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/capabilityparser"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
@@ -323,6 +325,51 @@ func Test_PersistFHIREndpointInfo(t *testing.T) {
 
 	e1.CapabilityStatement = capStat
 
+	// update endpointInfo with a capability change record in the same transaction
+
+	change := endpointmanager.CapabilityChange{
+		URL:                  e1.URL,
+		RequestedFhirVersion: e1.RequestedFhirVersion,
+		Changes:              []capabilitydiff.Change{{Type: capabilitydiff.StatementChange, Action: capabilitydiff.Added}},
+	}
+	err = store.UpdateFHIREndpointInfoAndAddCapabilityChange(ctx, e1, metadataID, &change)
+	if err != nil {
+		t.Errorf("Error updating fhir endpointInfo with a capability change: %s", err.Error())
+	}
+	if change.ID == 0 {
+		t.Errorf("Expected the capability change ID to be set")
+	}
+	e1, err = store.GetFHIREndpointInfo(ctx, endpointInfo1.ID)
+	if err != nil {
+		t.Errorf("Error getting fhir endpointInfo: %s", err.Error())
+	}
+	if e1.CapabilityStatement == nil {
+		t.Errorf("Expected capability statement to be updated with the capability change")
+	}
+
+	// the update is rolled back if the capability change record can't be added
+	e1.TLSVersion = "TLS 1.0"
+	badChange := change
+	badChange.URL = strings.Repeat("a", 510)
+	err = store.UpdateFHIREndpointInfoAndAddCapabilityChange(ctx, e1, metadataID, &badChange)
+	if err == nil {
+		t.Errorf("Expected an error adding a capability change with a URL that is too long")
+	}
+	e1, err = store.GetFHIREndpointInfo(ctx, endpointInfo1.ID)
+	if err != nil {
+		t.Errorf("Error getting fhir endpointInfo: %s", err.Error())
+	}
+	if e1.TLSVersion == "TLS 1.0" {
+		t.Errorf("Expected the update to be rolled back when the capability change record can't be added")
+	}
+	changes, err := store.GetCapabilityChangesUsingURL(ctx, e1.URL)
+	if err != nil {
+		t.Errorf("Error getting capability changes: %s", err.Error())
+	}
+	if len(changes) != 1 {
+		t.Errorf("Expected 1 capability change record, got %d", len(changes))
+	}
+
 	// delete endpointInfos
 
 	err = store.DeleteFHIREndpointInfo(ctx, endpointInfo1)
@@ -3,6 +3,7 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 )
@@ -25,10 +26,17 @@ func (s *Store) GetFHIREndpointMetadata(ctx context.Context, metadataID int) (*e
 		response_time_seconds,
 		smart_http_response,
 		requested_fhir_version,
+		tls_certificate_fingerprint,
+		final_url,
+		redirects,
 		updated_at,
 		created_at 
 	FROM fhir_endpoints_metadata WHERE id=$1;`
 
+	var tlsCertificateFingerprint sql.NullString
+	var finalURL sql.NullString
+	var redirectsJSON []byte
+
 	row := s.DB.QueryRowContext(ctx, sqlStatementMetadata, metadataID)
 
 	err := row.Scan(
@@ -39,11 +47,22 @@ func (s *Store) GetFHIREndpointMetadata(ctx context.Context, metadataID int) (*e
 		&endpointMetadata.ResponseTime,
 		&endpointMetadata.SMARTHTTPResponse,
 		&endpointMetadata.RequestedFhirVersion,
+		&tlsCertificateFingerprint,
+		&finalURL,
+		&redirectsJSON,
 		&endpointMetadata.UpdatedAt,
 		&endpointMetadata.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+	endpointMetadata.TLSCertificateFingerprint = tlsCertificateFingerprint.String
+	endpointMetadata.FinalURL = finalURL.String
+	if redirectsJSON != nil {
+		err = json.Unmarshal(redirectsJSON, &endpointMetadata.Redirects)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &endpointMetadata, err
 }
@@ -53,6 +72,11 @@ func (s *Store) AddFHIREndpointMetadata(ctx context.Context, e *endpointmanager.
 	var err error
 	var metadataID int
 
+	redirectsJSON, err := json.Marshal(e.Redirects)
+	if err != nil {
+		return 0, err
+	}
+
 	row := addFHIREndpointMetadataStatement.QueryRowContext(ctx,
 		e.URL,
 		e.HTTPResponse,
@@ -60,7 +84,10 @@ func (s *Store) AddFHIREndpointMetadata(ctx context.Context, e *endpointmanager.
 		e.Errors,
 		e.ResponseTime,
 		e.SMARTHTTPResponse,
-		e.RequestedFhirVersion)
+		e.RequestedFhirVersion,
+		e.TLSCertificateFingerprint,
+		e.FinalURL,
+		redirectsJSON)
 
 	err = row.Scan(&metadataID)
 
@@ -77,8 +104,11 @@ func prepareFHIREndpointMetadataStatements(s *Store) error {
 			errors,
 			response_time_seconds,
 			smart_http_response,
-			requested_fhir_version)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+			requested_fhir_version,
+			tls_certificate_fingerprint,
+			final_url,
+			redirects)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id`)
 	return err
 }
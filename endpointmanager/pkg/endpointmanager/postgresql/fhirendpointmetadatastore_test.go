@@ -47,7 +47,8 @@ func Test_PersistFHIREndpointMetadata(t *testing.T) {
 		Errors:               "Example Error 2",
 		SMARTHTTPResponse:    0,
 		Availability:         0,
-		RequestedFhirVersion: "None"}
+		RequestedFhirVersion: "None",
+		Redirects:            []endpointmanager.Redirect{{URL: "http://other.example.com/FHIR/DSTU2/metadata", StatusCode: 301, Location: "https://other.example.com/FHIR/DSTU2/metadata"}}}
 
 	// endpointInfos
 	var endpointInfo1 = &endpointmanager.FHIREndpointInfo{
@@ -0,0 +1,147 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var updateFHIREndpointScheduleStatement *sql.Stmt
+
+// GetFHIREndpointScheduleInfos gets the scheduling information for every distinct FHIR endpoint URL. An endpoint's
+// last successful query is the latest metadata entry with a 200 response, and its last change is the latest
+// capability statement change recorded for it.
+func (s *Store) GetFHIREndpointScheduleInfos(ctx context.Context) ([]*endpointmanager.FHIREndpointScheduleInfo, error) {
+	sqlStatement := `
+	SELECT
+		endpts.url,
+		endpts.created_at,
+		(SELECT MAX(updated_at) FROM fhir_endpoints_metadata WHERE fhir_endpoints_metadata.url = endpts.url AND http_response = 200),
+		(SELECT MAX(created_at) FROM capability_changes WHERE capability_changes.url = endpts.url),
+		schedules.tier,
+		schedules.next_query_at,
+		schedules.last_queried_at,
+		schedules.created_at,
+		schedules.updated_at
+	FROM (SELECT url, MIN(created_at) AS created_at FROM fhir_endpoints GROUP BY url) AS endpts
+	LEFT JOIN fhir_endpoint_schedules AS schedules ON endpts.url = schedules.url
+	ORDER BY endpts.url`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []*endpointmanager.FHIREndpointScheduleInfo
+	for rows.Next() {
+		var info endpointmanager.FHIREndpointScheduleInfo
+		var lastSuccessfulAt sql.NullTime
+		var lastChangedAt sql.NullTime
+		var tier sql.NullString
+		var nextQueryAt sql.NullTime
+		var lastQueriedAt sql.NullTime
+		var scheduleCreatedAt sql.NullTime
+		var scheduleUpdatedAt sql.NullTime
+
+		err = rows.Scan(
+			&info.URL,
+			&info.CreatedAt,
+			&lastSuccessfulAt,
+			&lastChangedAt,
+			&tier,
+			&nextQueryAt,
+			&lastQueriedAt,
+			&scheduleCreatedAt,
+			&scheduleUpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		info.LastSuccessfulAt = lastSuccessfulAt.Time
+		info.LastChangedAt = lastChangedAt.Time
+		if tier.Valid {
+			info.Schedule = &endpointmanager.FHIREndpointSchedule{
+				URL:           info.URL,
+				Tier:          tier.String,
+				NextQueryAt:   nextQueryAt.Time,
+				LastQueriedAt: lastQueriedAt.Time,
+				CreatedAt:     scheduleCreatedAt.Time,
+				UpdatedAt:     scheduleUpdatedAt.Time,
+			}
+		}
+		infos = append(infos, &info)
+	}
+	return infos, rows.Err()
+}
+
+// GetFHIREndpointSchedule gets the schedule of the endpoint with the given URL. If the endpoint has not been
+// scheduled, sql.ErrNoRows will be returned.
+func (s *Store) GetFHIREndpointSchedule(ctx context.Context, url string) (*endpointmanager.FHIREndpointSchedule, error) {
+	sqlStatement := `
+	SELECT
+		url,
+		tier,
+		next_query_at,
+		last_queried_at,
+		created_at,
+		updated_at
+	FROM fhir_endpoint_schedules WHERE url=$1`
+
+	var schedule endpointmanager.FHIREndpointSchedule
+	var lastQueriedAt sql.NullTime
+
+	err := s.DB.QueryRowContext(ctx, sqlStatement, url).Scan(
+		&schedule.URL,
+		&schedule.Tier,
+		&schedule.NextQueryAt,
+		&lastQueriedAt,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	schedule.LastQueriedAt = lastQueriedAt.Time
+	return &schedule, nil
+}
+
+// UpdateFHIREndpointSchedule adds the given schedule to the database, replacing any existing schedule for its URL
+func (s *Store) UpdateFHIREndpointSchedule(ctx context.Context, schedule *endpointmanager.FHIREndpointSchedule) error {
+	var lastQueriedAt sql.NullTime
+	if !schedule.LastQueriedAt.IsZero() {
+		lastQueriedAt = sql.NullTime{Time: schedule.LastQueriedAt, Valid: true}
+	}
+	_, err := updateFHIREndpointScheduleStatement.ExecContext(ctx,
+		schedule.URL,
+		schedule.Tier,
+		schedule.NextQueryAt,
+		lastQueriedAt)
+	return err
+}
+
+// DeleteFHIREndpointSchedule deletes the schedule of the endpoint with the given URL
+func (s *Store) DeleteFHIREndpointSchedule(ctx context.Context, url string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM fhir_endpoint_schedules WHERE url=$1", url)
+	return err
+}
+
+func prepareFHIREndpointScheduleStatements(s *Store) error {
+	var err error
+	updateFHIREndpointScheduleStatement, err = s.DB.Prepare(`
+		INSERT INTO fhir_endpoint_schedules (
+			url,
+			tier,
+			next_query_at,
+			last_queried_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (url) DO UPDATE SET
+			tier = EXCLUDED.tier,
+			next_query_at = EXCLUDED.next_query_at,
+			last_queried_at = EXCLUDED.last_queried_at,
+			updated_at = NOW()`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
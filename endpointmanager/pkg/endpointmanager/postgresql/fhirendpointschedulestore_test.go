@@ -0,0 +1,90 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistFHIREndpointSchedule(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	endpoint1 := &endpointmanager.FHIREndpoint{
+		URL:        "https://example.com/FHIR/DSTU2/",
+		ListSource: "https://github.com/cerner/ignite-endpoints"}
+	endpoint2 := &endpointmanager.FHIREndpoint{
+		URL:        "https://other.example.com/FHIR/DSTU2/",
+		ListSource: "https://github.com/cerner/ignite-endpoints"}
+
+	err = store.AddFHIREndpoint(ctx, endpoint1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint: %s", err))
+	err = store.AddFHIREndpoint(ctx, endpoint2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint: %s", err))
+
+	// only the successful response is used for the last successful time
+	_, err = store.AddFHIREndpointMetadata(ctx, &endpointmanager.FHIREndpointMetadata{URL: endpoint1.URL, HTTPResponse: 200, RequestedFhirVersion: "None"})
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint metadata: %s", err))
+	_, err = store.AddFHIREndpointMetadata(ctx, &endpointmanager.FHIREndpointMetadata{URL: endpoint2.URL, HTTPResponse: 404, RequestedFhirVersion: "None"})
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding fhir endpoint metadata: %s", err))
+
+	// endpoints without a schedule
+
+	infos, err := store.GetFHIREndpointScheduleInfos(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting schedule infos: %s", err))
+	th.Assert(t, len(infos) == 2, fmt.Sprintf("Expected 2 schedule infos, got %d", len(infos)))
+	th.Assert(t, infos[0].URL == endpoint1.URL && infos[1].URL == endpoint2.URL, "Expected the schedule infos to be ordered by URL")
+	th.Assert(t, !infos[0].CreatedAt.IsZero(), "Expected the endpoint creation time to be set")
+	th.Assert(t, !infos[0].LastSuccessfulAt.IsZero(), "Expected the first endpoint to have a successful response")
+	th.Assert(t, infos[1].LastSuccessfulAt.IsZero(), "Expected the second endpoint to not have a successful response")
+	th.Assert(t, infos[0].LastChangedAt.IsZero(), "Expected the first endpoint to not have changed")
+	th.Assert(t, infos[0].Schedule == nil && infos[1].Schedule == nil, "Expected the endpoints to not be scheduled")
+
+	_, err = store.GetFHIREndpointSchedule(ctx, endpoint1.URL)
+	th.Assert(t, err == sql.ErrNoRows, fmt.Sprintf("Expected no schedule, got %s", err))
+
+	// add and update a schedule
+
+	now := time.Now().UTC().Truncate(time.Second)
+	schedule := &endpointmanager.FHIREndpointSchedule{
+		URL:           endpoint1.URL,
+		Tier:          "new",
+		NextQueryAt:   now.Add(6 * time.Hour),
+		LastQueriedAt: now,
+	}
+	err = store.UpdateFHIREndpointSchedule(ctx, schedule)
+	th.Assert(t, err == nil, fmt.Sprintf("Error updating schedule: %s", err))
+
+	schedule.Tier = "default"
+	schedule.NextQueryAt = now.Add(24 * time.Hour)
+	err = store.UpdateFHIREndpointSchedule(ctx, schedule)
+	th.Assert(t, err == nil, fmt.Sprintf("Error updating schedule: %s", err))
+
+	stored, err := store.GetFHIREndpointSchedule(ctx, endpoint1.URL)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting schedule: %s", err))
+	th.Assert(t, stored.Tier == "default", fmt.Sprintf("Expected the default tier, got %s", stored.Tier))
+	th.Assert(t, stored.NextQueryAt.Equal(schedule.NextQueryAt), fmt.Sprintf("Expected next query at %s, got %s", schedule.NextQueryAt, stored.NextQueryAt))
+	th.Assert(t, stored.LastQueriedAt.Equal(now), fmt.Sprintf("Expected last queried at %s, got %s", now, stored.LastQueriedAt))
+
+	infos, err = store.GetFHIREndpointScheduleInfos(ctx)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting schedule infos: %s", err))
+	th.Assert(t, infos[0].Schedule != nil && infos[0].Schedule.Tier == "default", fmt.Sprintf("Expected the first endpoint to be scheduled, got %+v", infos[0].Schedule))
+	th.Assert(t, infos[1].Schedule == nil, "Expected the second endpoint to not be scheduled")
+
+	// delete a schedule
+
+	err = store.DeleteFHIREndpointSchedule(ctx, endpoint1.URL)
+	th.Assert(t, err == nil, fmt.Sprintf("Error deleting schedule: %s", err))
+	_, err = store.GetFHIREndpointSchedule(ctx, endpoint1.URL)
+	th.Assert(t, err == sql.ErrNoRows, fmt.Sprintf("Expected no schedule after deleting, got %s", err))
+}
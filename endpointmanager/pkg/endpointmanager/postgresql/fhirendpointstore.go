@@ -253,6 +253,33 @@ func (s *Store) GetFHIREndpointsUsingListSourceAndUpdateTime(ctx context.Context
 	return endpoints, nil
 }
 
+// GetFHIREndpointURLsUsingListSource returns the URLs of all of the fhir endpoints in the database from the
+// given list source
+func (s *Store) GetFHIREndpointURLsUsingListSource(ctx context.Context, listSource string) ([]string, error) {
+	sqlStatement := `
+	SELECT
+		url
+	FROM fhir_endpoints WHERE list_source=$1
+	ORDER BY url`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, listSource)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		err = rows.Scan(&url)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
 // UpdateFHIREndpointsNPIOrg updates each endpoint with new organization IDs and names
 func (s *Store) UpdateFHIREndpointsNPIOrg(ctx context.Context, e *endpointmanager.FHIREndpoint, add bool) error {
 	existingEndpts, err := s.GetFHIREndpointUsingURL(ctx, e.URL)
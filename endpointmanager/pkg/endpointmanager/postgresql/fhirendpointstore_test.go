@@ -62,6 +62,14 @@ func Test_PersistFHIREndpoint(t *testing.T) {
 		t.Errorf("retrieved endpoint is not equal to saved endpoint.")
 	}
 
+	urls, err := store.GetFHIREndpointURLsUsingListSource(ctx, endpoint1.ListSource)
+	if err != nil {
+		t.Errorf("Error getting fhir endpoint URLs: %s", err.Error())
+	}
+	if len(urls) != 1 || urls[0] != endpoint1.URL {
+		t.Errorf("expected only %s from list source %s, got %v", endpoint1.URL, endpoint1.ListSource, urls)
+	}
+
 	// update endpoint
 	e1.ListSource = "Unknown"
 
@@ -12,7 +12,9 @@ var pruningStatementQueryInterval *sql.Stmt
 var pruningStatementNoQueryInterval *sql.Stmt
 var pruningDeleteStatement *sql.Stmt
 var pruningDeleteValStatement *sql.Stmt
+var pruningDeleteValIssueStatement *sql.Stmt
 var pruningDeleteValResStatement *sql.Stmt
+var pruningDeleteCapContentsStatement *sql.Stmt
 
 // PruningGetInfoHistory gets info history entries for pruning
 func (s *Store) PruningGetInfoHistory(ctx context.Context, queryInterval bool) (*sql.Rows, error) {
@@ -29,20 +31,49 @@ func (s *Store) PruningGetInfoHistory(ctx context.Context, queryInterval bool) (
 	return rows, err
 }
 
-// PruningDeleteInfoHistory deletes info history entry due to pruning
+// PruningDeleteInfoHistory deletes info history entry due to pruning, along with its capability statement
+// contents if no other info or info history entry uses them
 func (s *Store) PruningDeleteInfoHistory(ctx context.Context, url string, entryDate string, requested_fhir_version string) error {
-	_, err := pruningDeleteStatement.ExecContext(ctx, url, requested_fhir_version, entryDate)
-	return err
+	rows, err := pruningDeleteStatement.QueryContext(ctx, url, requested_fhir_version, entryDate)
+	if err != nil {
+		return err
+	}
+	var contentsIDs []int64
+	for rows.Next() {
+		var contentsID sql.NullInt64
+		err = rows.Scan(&contentsID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if contentsID.Valid {
+			contentsIDs = append(contentsIDs, contentsID.Int64)
+		}
+	}
+	rows.Close()
+
+	for _, contentsID := range contentsIDs {
+		_, err = pruningDeleteCapContentsStatement.ExecContext(ctx, contentsID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// PruningDeleteValidationTable deletes validation table entries based on the given ID
+// PruningDeleteValidationTable deletes validation and validation issue table entries based on the given ID,
+// including the entries of any validation results that were revalidated from it
 func (s *Store) PruningDeleteValidationTable(ctx context.Context, valResID int) error {
 	_, err := pruningDeleteValStatement.ExecContext(ctx, valResID)
+	if err != nil {
+		return err
+	}
+	_, err = pruningDeleteValIssueStatement.ExecContext(ctx, valResID)
 	return err
 }
 
 // PruningDeleteValidationResultEntry deletes an entry from the validation_results table based
-// on the given ID
+// on the given ID, along with any validation results that were revalidated from it
 func (s *Store) PruningDeleteValidationResultEntry(ctx context.Context, valResID int) error {
 	_, err := pruningDeleteValResStatement.ExecContext(ctx, valResID)
 	return err
@@ -74,17 +105,32 @@ func prepareHistoryPruningStatements(s *Store) error {
 		return err
 	}
 	pruningDeleteStatement, err = s.DB.Prepare(`
-		DELETE FROM fhir_endpoints_info_history WHERE url=$1 AND operation='U' AND requested_fhir_version=$2 AND entered_at = $3;`)
+		DELETE FROM fhir_endpoints_info_history WHERE url=$1 AND operation='U' AND requested_fhir_version=$2 AND entered_at = $3
+		RETURNING capability_contents_id;`)
 	if err != nil {
 		return err
 	}
 	pruningDeleteValStatement, err = s.DB.Prepare(`
-		DELETE FROM validations WHERE validation_result_id = $1;`)
+		DELETE FROM validations WHERE validation_result_id IN
+			(SELECT id FROM validation_results WHERE id = $1 OR revalidated_from = $1);`)
+	if err != nil {
+		return err
+	}
+	pruningDeleteValIssueStatement, err = s.DB.Prepare(`
+		DELETE FROM validation_issues WHERE validation_result_id IN
+			(SELECT id FROM validation_results WHERE id = $1 OR revalidated_from = $1);`)
 	if err != nil {
 		return err
 	}
 	pruningDeleteValResStatement, err = s.DB.Prepare(`
-		DELETE FROM validation_results WHERE id = $1;`)
+		DELETE FROM validation_results WHERE id = $1 OR revalidated_from = $1;`)
+	if err != nil {
+		return err
+	}
+	pruningDeleteCapContentsStatement, err = s.DB.Prepare(`
+		DELETE FROM capability_contents WHERE id = $1
+			AND NOT EXISTS (SELECT 1 FROM fhir_endpoints_info WHERE capability_contents_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM fhir_endpoints_info_history WHERE capability_contents_id = $1);`)
 	if err != nil {
 		return err
 	}
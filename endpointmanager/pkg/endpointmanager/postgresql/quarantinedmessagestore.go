@@ -0,0 +1,77 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var addQuarantinedMessageStatement *sql.Stmt
+
+// GetQuarantinedMessages gets the quarantined messages from the given source, ordered from oldest to newest
+func (s *Store) GetQuarantinedMessages(ctx context.Context, source string) ([]*endpointmanager.QuarantinedMessage, error) {
+	var messages []*endpointmanager.QuarantinedMessage
+
+	sqlStatement := `
+	SELECT
+		id,
+		source,
+		payload,
+		error,
+		stack,
+		created_at
+	FROM quarantined_messages WHERE source=$1
+	ORDER BY created_at, id`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message endpointmanager.QuarantinedMessage
+		err = rows.Scan(
+			&message.ID,
+			&message.Source,
+			&message.Payload,
+			&message.Error,
+			&message.Stack,
+			&message.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, rows.Err()
+}
+
+// AddQuarantinedMessage adds the given quarantined message to the database and sets its ID and CreatedAt fields
+// to the stored values
+func (s *Store) AddQuarantinedMessage(ctx context.Context, m *endpointmanager.QuarantinedMessage) error {
+	row := addQuarantinedMessageStatement.QueryRowContext(ctx,
+		m.Source,
+		m.Payload,
+		m.Error,
+		m.Stack)
+
+	return row.Scan(&m.ID, &m.CreatedAt)
+}
+
+func prepareQuarantinedMessageStatements(s *Store) error {
+	var err error
+	addQuarantinedMessageStatement, err = s.DB.Prepare(`
+		INSERT INTO quarantined_messages (
+			source,
+			payload,
+			error,
+			stack)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistQuarantinedMessage(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	message1 := endpointmanager.QuarantinedMessage{
+		Source:  "capability-statements",
+		Payload: []byte(`{"url":"http://example.com/fhir","capabilityStatement":{"rest":"malformed"}}`),
+		Error:   "message handler panicked: interface conversion: interface {} is string, not []interface {}",
+		Stack:   "goroutine 1 [running]:",
+	}
+	message2 := endpointmanager.QuarantinedMessage{
+		Source:  "capability-statements",
+		Payload: []byte("not json"),
+		Error:   "message handler panicked: runtime error: index out of range [0] with length 0",
+	}
+	otherMessage := endpointmanager.QuarantinedMessage{
+		Source:  "endpoints-to-capability",
+		Payload: []byte(`{"url":"http://other.example.com/fhir"}`),
+		Error:   "job panicked: runtime error: invalid memory address or nil pointer dereference",
+	}
+
+	// add quarantined messages
+
+	err = store.AddQuarantinedMessage(ctx, &message1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding quarantined message: %s", err))
+	th.Assert(t, message1.ID != 0, "Expected the quarantined message ID to be set")
+	th.Assert(t, !message1.CreatedAt.IsZero(), "Expected the quarantined message creation time to be set")
+
+	err = store.AddQuarantinedMessage(ctx, &message2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding quarantined message: %s", err))
+
+	err = store.AddQuarantinedMessage(ctx, &otherMessage)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding quarantined message: %s", err))
+
+	// retrieve quarantined messages
+
+	messages, err := store.GetQuarantinedMessages(ctx, message1.Source)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting quarantined messages: %s", err))
+	th.Assert(t, len(messages) == 2, fmt.Sprintf("Expected 2 quarantined messages from %s, got %d", message1.Source, len(messages)))
+	th.Assert(t, messages[0].ID == message1.ID, fmt.Sprintf("Expected the first message to have ID %d, got %d", message1.ID, messages[0].ID))
+	th.Assert(t, string(messages[0].Payload) == string(message1.Payload), fmt.Sprintf("Expected payload %s, got %s", message1.Payload, messages[0].Payload))
+	th.Assert(t, messages[0].Error == message1.Error, fmt.Sprintf("Expected error %s, got %s", message1.Error, messages[0].Error))
+	th.Assert(t, messages[0].Stack == message1.Stack, fmt.Sprintf("Expected stack %s, got %s", message1.Stack, messages[0].Stack))
+	th.Assert(t, messages[1].Stack == "", fmt.Sprintf("Expected an empty stack, got %s", messages[1].Stack))
+
+	messages, err = store.GetQuarantinedMessages(ctx, "none")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting quarantined messages: %s", err))
+	th.Assert(t, len(messages) == 0, fmt.Sprintf("Expected no quarantined messages, got %d", len(messages)))
+}
@@ -0,0 +1,165 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var updateQuerierHeartbeatStatement *sql.Stmt
+var deleteQuerierInstanceStatement *sql.Stmt
+var deleteStaleQuerierInstancesStatement *sql.Stmt
+var reserveHostRequestStatement *sql.Stmt
+var releaseHostRequestStatement *sql.Stmt
+
+// GetQuerierInstances gets the capability querier instances whose last heartbeat was at or after activeSince,
+// ordered by when they started
+func (s *Store) GetQuerierInstances(ctx context.Context, activeSince time.Time) ([]*endpointmanager.QuerierInstance, error) {
+	var instances []*endpointmanager.QuerierInstance
+
+	sqlStatement := `
+	SELECT
+		id,
+		hostname,
+		num_workers,
+		started_at,
+		last_heartbeat
+	FROM querier_instances WHERE last_heartbeat >= $1
+	ORDER BY started_at, id`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, activeSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var instance endpointmanager.QuerierInstance
+		err = rows.Scan(
+			&instance.ID,
+			&instance.Hostname,
+			&instance.NumWorkers,
+			&instance.StartedAt,
+			&instance.LastHeartbeat)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, &instance)
+	}
+	return instances, rows.Err()
+}
+
+// UpdateQuerierHeartbeat registers the given capability querier instance if it isn't registered yet, and
+// otherwise sets its last heartbeat to the current time. The StartedAt and LastHeartbeat fields are set to the
+// stored values.
+func (s *Store) UpdateQuerierHeartbeat(ctx context.Context, instance *endpointmanager.QuerierInstance) error {
+	row := updateQuerierHeartbeatStatement.QueryRowContext(ctx,
+		instance.ID,
+		instance.Hostname,
+		instance.NumWorkers)
+
+	return row.Scan(&instance.StartedAt, &instance.LastHeartbeat)
+}
+
+// DeleteQuerierInstance removes the capability querier instance with the given ID
+func (s *Store) DeleteQuerierInstance(ctx context.Context, id string) error {
+	_, err := deleteQuerierInstanceStatement.ExecContext(ctx, id)
+	return err
+}
+
+// DeleteStaleQuerierInstances removes the capability querier instances whose last heartbeat was before the given
+// time, which stopped without removing themselves, and returns how many were removed
+func (s *Store) DeleteStaleQuerierInstances(ctx context.Context, before time.Time) (int64, error) {
+	res, err := deleteStaleQuerierInstancesStatement.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ReserveHostRequest reserves the next time a request can be made to the given host, keeping the requests that
+// every capability querier instance makes to the host at least the given interval apart. It returns how long to
+// wait before making the request, and the host's next request time after the reservation, which is needed to
+// release the reservation. The wait is worked out by the database so that it doesn't depend on the instances'
+// clocks agreeing.
+func (s *Store) ReserveHostRequest(ctx context.Context, host string, interval time.Duration) (time.Duration, time.Time, error) {
+	var waitSeconds float64
+	var nextRequestAt time.Time
+	row := reserveHostRequestStatement.QueryRowContext(ctx, host, interval.Seconds())
+	err := row.Scan(&waitSeconds, &nextRequestAt)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return time.Duration(waitSeconds * float64(time.Second)), nextRequestAt, nil
+}
+
+// ReleaseHostRequest gives back a reservation made with ReserveHostRequest for a request that won't be made, so
+// that the following requests to the host don't wait for it. The reservation is only given back while it is
+// still the host's latest one, since the requests reserved after it are already waiting for their own slots. It
+// returns whether the reservation was given back.
+func (s *Store) ReleaseHostRequest(ctx context.Context, host string, interval time.Duration, nextRequestAt time.Time) (bool, error) {
+	res, err := releaseHostRequestStatement.ExecContext(ctx, host, interval.Seconds(), nextRequestAt)
+	if err != nil {
+		return false, err
+	}
+	released, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return released > 0, nil
+}
+
+func prepareQuerierInstanceStatements(s *Store) error {
+	var err error
+	updateQuerierHeartbeatStatement, err = s.DB.Prepare(`
+		INSERT INTO querier_instances (
+			id,
+			hostname,
+			num_workers)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET hostname = EXCLUDED.hostname,
+			num_workers = EXCLUDED.num_workers,
+			last_heartbeat = NOW()
+		RETURNING started_at, last_heartbeat;`)
+	if err != nil {
+		return err
+	}
+	deleteQuerierInstanceStatement, err = s.DB.Prepare(`
+		DELETE FROM querier_instances
+		WHERE id = $1;`)
+	if err != nil {
+		return err
+	}
+	deleteStaleQuerierInstancesStatement, err = s.DB.Prepare(`
+		DELETE FROM querier_instances
+		WHERE last_heartbeat < $1;`)
+	if err != nil {
+		return err
+	}
+	// the row lock taken by the upsert makes concurrent reservations for the same host take turns, and each
+	// reservation is the later of now and the previous reservation's slot, plus the interval
+	reserveHostRequestStatement, err = s.DB.Prepare(`
+		INSERT INTO host_rate_limits (
+			host,
+			next_request_at)
+		VALUES ($1, NOW() + $2::float8 * INTERVAL '1 second')
+		ON CONFLICT (host) DO UPDATE
+		SET next_request_at = GREATEST(host_rate_limits.next_request_at, NOW()) + $2::float8 * INTERVAL '1 second'
+		RETURNING GREATEST(EXTRACT(EPOCH FROM (next_request_at - $2::float8 * INTERVAL '1 second' - NOW())), 0)::float8,
+			next_request_at;`)
+	if err != nil {
+		return err
+	}
+	releaseHostRequestStatement, err = s.DB.Prepare(`
+		UPDATE host_rate_limits
+		SET next_request_at = next_request_at - $2::float8 * INTERVAL '1 second'
+		WHERE host = $1 AND next_request_at = $3;`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
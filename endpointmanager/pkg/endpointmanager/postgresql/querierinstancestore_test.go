@@ -0,0 +1,124 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistQuerierInstance(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	instance1 := endpointmanager.QuerierInstance{
+		ID:         "capability_querier-1",
+		Hostname:   "capability_querier",
+		NumWorkers: 10,
+	}
+	instance2 := endpointmanager.QuerierInstance{
+		ID:         "capability_querier-2",
+		Hostname:   "capability_querier",
+		NumWorkers: 20,
+	}
+
+	// register instances
+
+	err = store.UpdateQuerierHeartbeat(ctx, &instance1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error registering querier instance: %s", err))
+	th.Assert(t, !instance1.StartedAt.IsZero(), "Expected the querier instance start time to be set")
+	th.Assert(t, !instance1.LastHeartbeat.IsZero(), "Expected the querier instance heartbeat to be set")
+	startedAt := instance1.StartedAt
+
+	err = store.UpdateQuerierHeartbeat(ctx, &instance2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error registering querier instance: %s", err))
+
+	// update a heartbeat
+
+	firstHeartbeat := instance1.LastHeartbeat
+	err = store.UpdateQuerierHeartbeat(ctx, &instance1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error updating querier instance heartbeat: %s", err))
+	th.Assert(t, instance1.StartedAt.Equal(startedAt), fmt.Sprintf("Expected the start time to stay %s, got %s", startedAt, instance1.StartedAt))
+	th.Assert(t, !instance1.LastHeartbeat.Before(firstHeartbeat), "Expected the heartbeat to move forward")
+
+	// retrieve instances
+
+	instances, err := store.GetQuerierInstances(ctx, time.Time{})
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting querier instances: %s", err))
+	th.Assert(t, len(instances) == 2, fmt.Sprintf("Expected 2 querier instances, got %d", len(instances)))
+	th.Assert(t, instances[0].ID == instance1.ID, fmt.Sprintf("Expected the first instance to be %s, got %s", instance1.ID, instances[0].ID))
+	th.Assert(t, instances[1].NumWorkers == instance2.NumWorkers, fmt.Sprintf("Expected %d workers, got %d", instance2.NumWorkers, instances[1].NumWorkers))
+
+	instances, err = store.GetQuerierInstances(ctx, instance1.LastHeartbeat.Add(time.Hour))
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting querier instances: %s", err))
+	th.Assert(t, len(instances) == 0, fmt.Sprintf("Expected no active querier instances, got %d", len(instances)))
+
+	// delete stale instances
+
+	removed, err := store.DeleteStaleQuerierInstances(ctx, instance1.LastHeartbeat.Add(-time.Hour))
+	th.Assert(t, err == nil, fmt.Sprintf("Error deleting stale querier instances: %s", err))
+	th.Assert(t, removed == 0, fmt.Sprintf("Expected no stale querier instances to be removed, got %d", removed))
+
+	_, err = store.DB.ExecContext(ctx, "UPDATE querier_instances SET last_heartbeat = NOW() - INTERVAL '1 hour' WHERE id = $1", instance2.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error aging querier instance heartbeat: %s", err))
+	removed, err = store.DeleteStaleQuerierInstances(ctx, instance1.LastHeartbeat.Add(-time.Minute))
+	th.Assert(t, err == nil, fmt.Sprintf("Error deleting stale querier instances: %s", err))
+	th.Assert(t, removed == 1, fmt.Sprintf("Expected 1 stale querier instance to be removed, got %d", removed))
+
+	// delete an instance
+
+	err = store.DeleteQuerierInstance(ctx, instance1.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error deleting querier instance: %s", err))
+	instances, err = store.GetQuerierInstances(ctx, time.Time{})
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting querier instances: %s", err))
+	th.Assert(t, len(instances) == 0, fmt.Sprintf("Expected no querier instances, got %d", len(instances)))
+}
+
+func Test_ReserveHostRequest(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	ctx := context.Background()
+	interval := time.Minute
+
+	// the first request to a host doesn't wait
+	wait, _, err := store.ReserveHostRequest(ctx, "example.com", interval)
+	th.Assert(t, err == nil, fmt.Sprintf("Error reserving host request: %s", err))
+	th.Assert(t, wait == 0, fmt.Sprintf("Expected no wait for the first request, got %s", wait))
+
+	// the following requests wait an interval after the previous one
+	wait, secondNext, err := store.ReserveHostRequest(ctx, "example.com", interval)
+	th.Assert(t, err == nil, fmt.Sprintf("Error reserving host request: %s", err))
+	th.Assert(t, wait > interval-5*time.Second && wait <= interval, fmt.Sprintf("Expected to wait about %s, got %s", interval, wait))
+
+	wait, thirdNext, err := store.ReserveHostRequest(ctx, "example.com", interval)
+	th.Assert(t, err == nil, fmt.Sprintf("Error reserving host request: %s", err))
+	th.Assert(t, wait > 2*interval-5*time.Second && wait <= 2*interval, fmt.Sprintf("Expected to wait about %s, got %s", 2*interval, wait))
+
+	// a reservation that is no longer the latest one isn't given back
+	released, err := store.ReleaseHostRequest(ctx, "example.com", interval, secondNext)
+	th.Assert(t, err == nil, fmt.Sprintf("Error releasing host request: %s", err))
+	th.Assert(t, !released, "Expected an earlier reservation not to be released")
+
+	// the latest reservation is given back, so the next request takes its slot
+	released, err = store.ReleaseHostRequest(ctx, "example.com", interval, thirdNext)
+	th.Assert(t, err == nil, fmt.Sprintf("Error releasing host request: %s", err))
+	th.Assert(t, released, "Expected the latest reservation to be released")
+
+	wait, _, err = store.ReserveHostRequest(ctx, "example.com", interval)
+	th.Assert(t, err == nil, fmt.Sprintf("Error reserving host request: %s", err))
+	th.Assert(t, wait > 2*interval-5*time.Second && wait <= 2*interval, fmt.Sprintf("Expected to wait about %s, got %s", 2*interval, wait))
+
+	// other hosts are limited separately
+	wait, _, err = store.ReserveHostRequest(ctx, "other.example.com", interval)
+	th.Assert(t, err == nil, fmt.Sprintf("Error reserving host request: %s", err))
+	th.Assert(t, wait == 0, fmt.Sprintf("Expected no wait for another host, got %s", wait))
+}
@@ -0,0 +1,135 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var recordQueryCycleVersionsResponseStatement *sql.Stmt
+var recordQueryCycleStoredStatement *sql.Stmt
+var recordQueryCycleFailedStatement *sql.Stmt
+
+// GetQueryCycle gets the query cycle with the given id. If the cycle does not exist in the database, sql.ErrNoRows
+// will be returned.
+func (s *Store) GetQueryCycle(ctx context.Context, id int) (*endpointmanager.QueryCycle, error) {
+	sqlStatement := `
+	SELECT
+		id,
+		status,
+		endpoint_count,
+		versions_received,
+		enqueued,
+		queried,
+		stored,
+		failed,
+		started_at,
+		completed_at,
+		updated_at
+	FROM query_cycles WHERE id=$1`
+
+	var cycle endpointmanager.QueryCycle
+	var completedAt sql.NullTime
+
+	err := s.DB.QueryRowContext(ctx, sqlStatement, id).Scan(
+		&cycle.ID,
+		&cycle.Status,
+		&cycle.EndpointCount,
+		&cycle.VersionsReceived,
+		&cycle.Enqueued,
+		&cycle.Queried,
+		&cycle.Stored,
+		&cycle.Failed,
+		&cycle.StartedAt,
+		&completedAt,
+		&cycle.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	cycle.CompletedAt = completedAt.Time
+	return &cycle, nil
+}
+
+// AddQueryCycle adds a new query cycle with the sending status to the database and sets the id and start time
+// of the given cycle
+func (s *Store) AddQueryCycle(ctx context.Context, cycle *endpointmanager.QueryCycle) error {
+	cycle.Status = endpointmanager.QueryCycleSending
+	row := s.DB.QueryRowContext(ctx,
+		"INSERT INTO query_cycles (status) VALUES ($1) RETURNING id, started_at",
+		cycle.Status)
+	return row.Scan(&cycle.ID, &cycle.StartedAt)
+}
+
+// UpdateQueryCycle updates the status and endpoint count of the given query cycle. The completion time is set
+// when the status is completed or timed out. The progress counts are left as they are.
+func (s *Store) UpdateQueryCycle(ctx context.Context, cycle *endpointmanager.QueryCycle) error {
+	var completedAt sql.NullTime
+	if !cycle.CompletedAt.IsZero() {
+		completedAt = sql.NullTime{Time: cycle.CompletedAt, Valid: true}
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE query_cycles
+		SET status = $2,
+			endpoint_count = $3,
+			completed_at = $4,
+			updated_at = NOW()
+		WHERE id = $1`,
+		cycle.ID,
+		cycle.Status,
+		cycle.EndpointCount,
+		completedAt)
+	return err
+}
+
+// RecordQueryCycleVersionsResponse records that a $versions response was received for the query cycle with the
+// given id, and that the given number of capability statement queries were enqueued for it
+func (s *Store) RecordQueryCycleVersionsResponse(ctx context.Context, id int, enqueued int) error {
+	_, err := recordQueryCycleVersionsResponseStatement.ExecContext(ctx, id, enqueued)
+	return err
+}
+
+// RecordQueryCycleCapabilityStatement records that a capability statement message was received for the query
+// cycle with the given id, and whether it was stored
+func (s *Store) RecordQueryCycleCapabilityStatement(ctx context.Context, id int, stored bool) error {
+	var err error
+	if stored {
+		_, err = recordQueryCycleStoredStatement.ExecContext(ctx, id)
+	} else {
+		_, err = recordQueryCycleFailedStatement.ExecContext(ctx, id)
+	}
+	return err
+}
+
+func prepareQueryCycleStatements(s *Store) error {
+	var err error
+	recordQueryCycleVersionsResponseStatement, err = s.DB.Prepare(`
+		UPDATE query_cycles
+		SET versions_received = versions_received + 1,
+			enqueued = enqueued + $2,
+			updated_at = NOW()
+		WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	recordQueryCycleStoredStatement, err = s.DB.Prepare(`
+		UPDATE query_cycles
+		SET queried = queried + 1,
+			stored = stored + 1,
+			updated_at = NOW()
+		WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	recordQueryCycleFailedStatement, err = s.DB.Prepare(`
+		UPDATE query_cycles
+		SET queried = queried + 1,
+			failed = failed + 1,
+			updated_at = NOW()
+		WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistQueryCycle(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	// add a cycle
+
+	var cycle endpointmanager.QueryCycle
+	err = store.AddQueryCycle(ctx, &cycle)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding query cycle: %s", err))
+	th.Assert(t, cycle.ID != 0, "Expected the query cycle id to be set")
+	th.Assert(t, !cycle.StartedAt.IsZero(), "Expected the query cycle start time to be set")
+
+	stored, err := store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting query cycle: %s", err))
+	th.Assert(t, stored.Status == endpointmanager.QueryCycleSending, fmt.Sprintf("Expected the sending status, got %s", stored.Status))
+	th.Assert(t, stored.CompletedAt.IsZero(), "Expected the query cycle to not be completed")
+
+	// record progress
+
+	cycle.Status = endpointmanager.QueryCycleRunning
+	cycle.EndpointCount = 2
+	err = store.UpdateQueryCycle(ctx, &cycle)
+	th.Assert(t, err == nil, fmt.Sprintf("Error updating query cycle: %s", err))
+
+	err = store.RecordQueryCycleVersionsResponse(ctx, cycle.ID, 2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording versions response: %s", err))
+	err = store.RecordQueryCycleVersionsResponse(ctx, cycle.ID, 0)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording versions response: %s", err))
+	err = store.RecordQueryCycleCapabilityStatement(ctx, cycle.ID, true)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording capability statement: %s", err))
+
+	stored, err = store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting query cycle: %s", err))
+	th.Assert(t, stored.EndpointCount == 2, fmt.Sprintf("Expected 2 endpoints, got %d", stored.EndpointCount))
+	th.Assert(t, stored.VersionsReceived == 2, fmt.Sprintf("Expected 2 versions responses, got %d", stored.VersionsReceived))
+	th.Assert(t, stored.Enqueued == 2, fmt.Sprintf("Expected 2 enqueued queries, got %d", stored.Enqueued))
+	th.Assert(t, stored.Queried == 1 && stored.Stored == 1, fmt.Sprintf("Expected 1 stored query, got %d/%d", stored.Stored, stored.Queried))
+	th.Assert(t, !stored.Done(), "Expected the query cycle to not be done")
+
+	err = store.RecordQueryCycleCapabilityStatement(ctx, cycle.ID, false)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording capability statement: %s", err))
+
+	stored, err = store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting query cycle: %s", err))
+	th.Assert(t, stored.Failed == 1, fmt.Sprintf("Expected 1 failed query, got %d", stored.Failed))
+	th.Assert(t, stored.Done(), "Expected the query cycle to be done")
+
+	// complete the cycle
+
+	cycle.Status = endpointmanager.QueryCycleCompleted
+	cycle.CompletedAt = time.Now()
+	err = store.UpdateQueryCycle(ctx, &cycle)
+	th.Assert(t, err == nil, fmt.Sprintf("Error updating query cycle: %s", err))
+
+	stored, err = store.GetQueryCycle(ctx, cycle.ID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting query cycle: %s", err))
+	th.Assert(t, stored.Status == endpointmanager.QueryCycleCompleted, fmt.Sprintf("Expected the completed status, got %s", stored.Status))
+	th.Assert(t, !stored.CompletedAt.IsZero(), "Expected the query cycle completion time to be set")
+
+	_, err = store.GetQueryCycle(ctx, cycle.ID+1)
+	th.Assert(t, err == sql.ErrNoRows, fmt.Sprintf("Expected no query cycle, got %s", err))
+}
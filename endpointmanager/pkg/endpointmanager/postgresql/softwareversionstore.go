@@ -0,0 +1,222 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+)
+
+// prepared statements are left open to be used throughout the execution of the application
+var lockEndpointSoftwareVersionsStatement *sql.Stmt
+var getLatestEndpointSoftwareVersionStatement *sql.Stmt
+var updateEndpointSoftwareVersionStatement *sql.Stmt
+var addEndpointSoftwareVersionStatement *sql.Stmt
+
+// GetEndpointSoftwareVersions gets the software versions advertised by the endpoint with the given URL and
+// requested FHIR version, ordered from oldest to newest
+func (s *Store) GetEndpointSoftwareVersions(ctx context.Context, url string, requestedFhirVersion string) ([]*endpointmanager.EndpointSoftwareVersion, error) {
+	var versions []*endpointmanager.EndpointSoftwareVersion
+
+	sqlStatement := `
+	SELECT
+		id,
+		url,
+		requested_fhir_version,
+		vendor_id,
+		software_name,
+		software_version,
+		software_release_date,
+		first_seen,
+		last_seen
+	FROM endpoint_software_versions WHERE url=$1 AND requested_fhir_version=$2
+	ORDER BY first_seen, id`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, url, requestedFhirVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version endpointmanager.EndpointSoftwareVersion
+		var requestedVersion sql.NullString
+		var vendorIDNullable sql.NullInt64
+		var name sql.NullString
+		var softwareVersion sql.NullString
+		var releaseDate sql.NullString
+
+		err = rows.Scan(
+			&version.ID,
+			&version.URL,
+			&requestedVersion,
+			&vendorIDNullable,
+			&name,
+			&softwareVersion,
+			&releaseDate,
+			&version.FirstSeen,
+			&version.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		version.RequestedFhirVersion = requestedVersion.String
+		ints := getRegularInts([]sql.NullInt64{vendorIDNullable})
+		version.VendorID = ints[0]
+		version.Name = name.String
+		version.Version = softwareVersion.String
+		version.ReleaseDate = releaseDate.String
+		versions = append(versions, &version)
+	}
+	return versions, rows.Err()
+}
+
+// RecordEndpointSoftwareVersion records that the endpoint advertised the given software version. Each entry is
+// a run of consecutive observations of the same software version. If the endpoint's latest entry has the same
+// name, version and release date, that entry's last seen time and vendor are updated. Otherwise the given
+// software version is added as a new entry, so an endpoint that goes back to an earlier software version gets a
+// new entry for it. The ID, FirstSeen and LastSeen fields are set to the stored values.
+func (s *Store) RecordEndpointSoftwareVersion(ctx context.Context, sv *endpointmanager.EndpointSoftwareVersion) (err error) {
+	nullableInts := getNullableInts([]int{sv.VendorID})
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// concurrent receivers recording a software version for the same endpoint wait for each other, so they
+	// see each other's entries
+	_, err = tx.StmtContext(ctx, lockEndpointSoftwareVersionsStatement).ExecContext(ctx, sv.URL, sv.RequestedFhirVersion)
+	if err != nil {
+		return err
+	}
+
+	var latestID int
+	var name, version, releaseDate sql.NullString
+	err = tx.StmtContext(ctx, getLatestEndpointSoftwareVersionStatement).QueryRowContext(ctx, sv.URL, sv.RequestedFhirVersion).Scan(
+		&latestID,
+		&name,
+		&version,
+		&releaseDate)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var row *sql.Row
+	if err == nil && name.String == sv.Name && version.String == sv.Version && releaseDate.String == sv.ReleaseDate {
+		row = tx.StmtContext(ctx, updateEndpointSoftwareVersionStatement).QueryRowContext(ctx, latestID, nullableInts[0])
+	} else {
+		row = tx.StmtContext(ctx, addEndpointSoftwareVersionStatement).QueryRowContext(ctx,
+			sv.URL,
+			sv.RequestedFhirVersion,
+			nullableInts[0],
+			sv.Name,
+			sv.Version,
+			sv.ReleaseDate)
+	}
+	err = row.Scan(&sv.ID, &sv.FirstSeen, &sv.LastSeen)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetSoftwareVersionAdoption gets the number of endpoints of each vendor that advertised each software version
+// during each period between the given start and end times. The interval is the length of each period and must
+// be "day", "week" or "month". An endpoint is counted for a software version in every period that overlaps one of
+// its runs of consecutive observations of that version. Endpoints that are aliases
+// of each other are counted once, using their canonical URL.
+func (s *Store) GetSoftwareVersionAdoption(ctx context.Context, interval string, start time.Time, end time.Time) ([]*endpointmanager.SoftwareVersionAdoption, error) {
+	var adoption []*endpointmanager.SoftwareVersionAdoption
+
+	if interval != "day" && interval != "week" && interval != "month" {
+		return nil, fmt.Errorf("unknown interval %s, must be day, week or month", interval)
+	}
+
+	sqlStatement := `
+	SELECT
+		COALESCE(vendors.name, ''),
+		COALESCE(versions.software_name, ''),
+		COALESCE(versions.software_version, ''),
+		periods.period,
+		COUNT(DISTINCT COALESCE(aliases.canonical_url, versions.url))
+	FROM generate_series(date_trunc($1, $2::timestamptz), $3::timestamptz, ('1 ' || $1)::interval) AS periods(period)
+	JOIN endpoint_software_versions AS versions
+		ON versions.first_seen < periods.period + ('1 ' || $1)::interval AND versions.last_seen >= periods.period
+	LEFT JOIN fhir_endpoint_aliases AS aliases ON versions.url = aliases.url
+	LEFT JOIN vendors ON versions.vendor_id = vendors.id
+	GROUP BY 1, 2, 3, 4
+	ORDER BY 1, 4, 2, 3`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry endpointmanager.SoftwareVersionAdoption
+		err = rows.Scan(
+			&entry.VendorName,
+			&entry.SoftwareName,
+			&entry.SoftwareVersion,
+			&entry.Period,
+			&entry.EndpointCount)
+		if err != nil {
+			return nil, err
+		}
+		adoption = append(adoption, &entry)
+	}
+	return adoption, rows.Err()
+}
+
+func prepareSoftwareVersionStatements(s *Store) error {
+	var err error
+	lockEndpointSoftwareVersionsStatement, err = s.DB.Prepare(`
+		SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2));`)
+	if err != nil {
+		return err
+	}
+	getLatestEndpointSoftwareVersionStatement, err = s.DB.Prepare(`
+		SELECT
+			id,
+			software_name,
+			software_version,
+			software_release_date
+		FROM endpoint_software_versions WHERE url=$1 AND requested_fhir_version=$2
+		ORDER BY last_seen DESC, id DESC
+		LIMIT 1;`)
+	if err != nil {
+		return err
+	}
+	updateEndpointSoftwareVersionStatement, err = s.DB.Prepare(`
+		UPDATE endpoint_software_versions
+		SET vendor_id = $2,
+			last_seen = NOW()
+		WHERE id = $1
+		RETURNING id, first_seen, last_seen;`)
+	if err != nil {
+		return err
+	}
+	addEndpointSoftwareVersionStatement, err = s.DB.Prepare(`
+		INSERT INTO endpoint_software_versions (
+			url,
+			requested_fhir_version,
+			vendor_id,
+			software_name,
+			software_version,
+			software_release_date)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, first_seen, last_seen;`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// +build integration
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_PersistEndpointSoftwareVersion(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	version1 := endpointmanager.EndpointSoftwareVersion{
+		URL:                  "http://example.com/fhir",
+		RequestedFhirVersion: "None",
+		Name:                 "EHR",
+		Version:              "1.0",
+		ReleaseDate:          "2019-11-22",
+	}
+	version2 := endpointmanager.EndpointSoftwareVersion{
+		URL:                  "http://example.com/fhir",
+		RequestedFhirVersion: "None",
+		Name:                 "EHR",
+		Version:              "2.0",
+	}
+
+	// record software versions
+
+	err = store.RecordEndpointSoftwareVersion(ctx, &version1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording software version: %s", err))
+	th.Assert(t, version1.ID != 0, "Expected the software version ID to be set")
+	th.Assert(t, !version1.FirstSeen.IsZero(), "Expected the software version first seen time to be set")
+	firstID := version1.ID
+
+	// recording the same software version again updates the existing entry
+	sameVersion := version1
+	sameVersion.ID = 0
+	err = store.RecordEndpointSoftwareVersion(ctx, &sameVersion)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording software version: %s", err))
+	th.Assert(t, sameVersion.ID == firstID, fmt.Sprintf("Expected the existing software version %d to be updated, got %d", firstID, sameVersion.ID))
+	th.Assert(t, !sameVersion.LastSeen.Before(version1.LastSeen), "Expected the last seen time to be updated")
+
+	err = store.RecordEndpointSoftwareVersion(ctx, &version2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording software version: %s", err))
+	th.Assert(t, version2.ID != firstID, "Expected a new software version entry to be added")
+
+	// going back to a software version adds a new entry, so the first entry does not span the second one
+	sameVersion.ID = 0
+	err = store.RecordEndpointSoftwareVersion(ctx, &sameVersion)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording software version: %s", err))
+	th.Assert(t, sameVersion.ID != firstID && sameVersion.ID != version2.ID, fmt.Sprintf("Expected a new software version entry to be added, got %d", sameVersion.ID))
+
+	// concurrent receivers recording the same software version share one entry
+	version3 := endpointmanager.EndpointSoftwareVersion{
+		URL:                  "http://example.com/fhir",
+		RequestedFhirVersion: "None",
+		Name:                 "EHR",
+		Version:              "3.0",
+	}
+	var wg sync.WaitGroup
+	ids := make([]int, 5)
+	errs := make([]error, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sv := version3
+			errs[i] = store.RecordEndpointSoftwareVersion(ctx, &sv)
+			ids[i] = sv.ID
+		}(i)
+	}
+	wg.Wait()
+	for i := range ids {
+		th.Assert(t, errs[i] == nil, fmt.Sprintf("Error recording software version: %s", errs[i]))
+		th.Assert(t, ids[i] == ids[0], fmt.Sprintf("Expected one software version entry, got IDs %d and %d", ids[0], ids[i]))
+	}
+
+	// retrieve software versions
+
+	versions, err := store.GetEndpointSoftwareVersions(ctx, version1.URL, "None")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting software versions: %s", err))
+	th.Assert(t, len(versions) == 4, fmt.Sprintf("Expected 4 software versions, got %d", len(versions)))
+	th.Assert(t, versions[0].Version == "1.0" && versions[0].ReleaseDate == "2019-11-22", fmt.Sprintf("Unexpected first software version %+v", versions[0]))
+	th.Assert(t, versions[1].Version == "2.0", fmt.Sprintf("Unexpected second software version %+v", versions[1]))
+	th.Assert(t, versions[2].Version == "1.0", fmt.Sprintf("Unexpected third software version %+v", versions[2]))
+	th.Assert(t, versions[3].Version == "3.0", fmt.Sprintf("Unexpected fourth software version %+v", versions[3]))
+	th.Assert(t, !versions[0].LastSeen.After(versions[1].FirstSeen), fmt.Sprintf("Expected the first entry to end before the second one starts, %+v", versions[0]))
+
+	versions, err = store.GetEndpointSoftwareVersions(ctx, version1.URL, "4.0.1")
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting software versions: %s", err))
+	th.Assert(t, len(versions) == 0, fmt.Sprintf("Expected no software versions, got %d", len(versions)))
+
+	// software version adoption
+
+	// an alias of the endpoint advertising the same software version is not counted again
+	aliasVersion := version1
+	aliasVersion.ID = 0
+	aliasVersion.URL = "https://EXAMPLE.com/fhir/"
+	err = store.RecordEndpointSoftwareVersion(ctx, &aliasVersion)
+	th.Assert(t, err == nil, fmt.Sprintf("Error recording software version: %s", err))
+	err = store.AddFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(version1.URL, ""))
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding endpoint alias: %s", err))
+	err = store.AddFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(aliasVersion.URL, ""))
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding endpoint alias: %s", err))
+
+	now := time.Now()
+	adoption, err := store.GetSoftwareVersionAdoption(ctx, "day", now.Add(-24*time.Hour), now)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting software version adoption: %s", err))
+	th.Assert(t, len(adoption) == 3, fmt.Sprintf("Expected adoption of 3 software versions, got %d", len(adoption)))
+	for _, entry := range adoption {
+		th.Assert(t, entry.EndpointCount == 1, fmt.Sprintf("Expected 1 endpoint for %+v", entry))
+	}
+
+	_, err = store.GetSoftwareVersionAdoption(ctx, "year", now.Add(-24*time.Hour), now)
+	th.Assert(t, err != nil, "Expected an error for an unknown interval")
+}
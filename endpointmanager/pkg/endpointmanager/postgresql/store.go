@@ -46,6 +46,18 @@ func NewStore(host string, port int, user string, password string, dbname string
 	if err != nil {
 		return nil, err
 	}
+	err = prepareFHIREndpointAliasStatements(&store)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareFHIREndpointScheduleStatements(&store)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareQueryCycleStatements(&store)
+	if err != nil {
+		return nil, err
+	}
 	err = prepareFHIREndpointInfoStatements(&store)
 	if err != nil {
 		return nil, err
@@ -58,6 +70,18 @@ func NewStore(host string, port int, user string, password string, dbname string
 	if err != nil {
 		return nil, err
 	}
+	err = prepareCapabilityContentsStatements(&store)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareCapabilityChangeStatements(&store)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareSoftwareVersionStatements(&store)
+	if err != nil {
+		return nil, err
+	}
 	err = prepareHealthITProductStatements(&store)
 	if err != nil {
 		return nil, err
@@ -82,6 +106,14 @@ func NewStore(host string, port int, user string, password string, dbname string
 	if err != nil {
 		return nil, err
 	}
+	err = prepareQuarantinedMessageStatements(&store)
+	if err != nil {
+		return nil, err
+	}
+	err = prepareQuerierInstanceStatements(&store)
+	if err != nil {
+		return nil, err
+	}
 
 	return &store, nil
 }
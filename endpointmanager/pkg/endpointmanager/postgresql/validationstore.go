@@ -10,6 +10,9 @@ import (
 // prepared statements are left open to be used throughout the execution of the application
 var addValidationStatement *sql.Stmt
 var addValidationResultStatement *sql.Stmt
+var addRevalidationResultStatement *sql.Stmt
+var updateValidationScoreStatement *sql.Stmt
+var addValidationIssueStatement *sql.Stmt
 
 // GetValidationByID gets the rows of the validation table that have the given validation_result_id
 func (s *Store) GetValidationByID(ctx context.Context, id int) (*[]endpointmanager.Rule, error) {
@@ -23,7 +26,10 @@ func (s *Store) GetValidationByID(ctx context.Context, id int) (*[]endpointmanag
 		actual,
 		comment,
 		reference,
-		implementation_guide
+		implementation_guide,
+		resource,
+		severity,
+		weight
 	FROM validations WHERE validation_result_id=$1`
 
 	rows, err := s.DB.QueryContext(ctx, sqlStatementInfo, id)
@@ -33,6 +39,9 @@ func (s *Store) GetValidationByID(ctx context.Context, id int) (*[]endpointmanag
 
 	for rows.Next() {
 		var ruleInfo endpointmanager.Rule
+		var resourceString sql.NullString
+		var severityString sql.NullString
+		var weightNullable sql.NullFloat64
 
 		err := rows.Scan(
 			&ruleInfo.RuleName,
@@ -41,15 +50,55 @@ func (s *Store) GetValidationByID(ctx context.Context, id int) (*[]endpointmanag
 			&ruleInfo.Actual,
 			&ruleInfo.Comment,
 			&ruleInfo.Reference,
-			&ruleInfo.ImplGuide)
+			&ruleInfo.ImplGuide,
+			&resourceString,
+			&severityString,
+			&weightNullable)
 		if err != nil {
 			return nil, err
 		}
+		ruleInfo.Resource = resourceString.String
+		ruleInfo.Severity = endpointmanager.Severity(severityString.String)
+		ruleInfo.Weight = weightNullable.Float64
 		validationRows = append(validationRows, ruleInfo)
 	}
 	return &validationRows, nil
 }
 
+// GetValidationIssuesByID gets the rows of the validation_issues table that have the given validation_result_id
+func (s *Store) GetValidationIssuesByID(ctx context.Context, id int) ([]endpointmanager.ValidationIssue, error) {
+	var issues []endpointmanager.ValidationIssue
+
+	sqlStatement := `
+	SELECT
+		severity,
+		code,
+		diagnostics,
+		expression
+	FROM validation_issues WHERE validation_result_id=$1`
+
+	rows, err := s.DB.QueryContext(ctx, sqlStatement, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var issue endpointmanager.ValidationIssue
+
+		err := rows.Scan(
+			&issue.Severity,
+			&issue.Code,
+			&issue.Diagnostics,
+			&issue.Expression)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
 // AddValidationResult creates a new ID for the validation data and returns it
 func (s *Store) AddValidationResult(ctx context.Context) (int, error) {
 	var err error
@@ -61,7 +110,40 @@ func (s *Store) AddValidationResult(ctx context.Context) (int, error) {
 	return valResID, err
 }
 
-// AddValidation adds the Validation data to the database
+// GetValidationScoreByID gets the conformance score stored for the given validation_result_id. If no score
+// has been stored for the validation result or the validation result does not exist, 0 is returned.
+func (s *Store) GetValidationScoreByID(ctx context.Context, id int) (float64, error) {
+	var scoreNullable sql.NullFloat64
+
+	sqlStatement := `SELECT conformance_score FROM validation_results WHERE id=$1`
+	err := s.DB.QueryRowContext(ctx, sqlStatement, id).Scan(&scoreNullable)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return scoreNullable.Float64, err
+}
+
+// AddRevalidationResult creates a new ID for validation data that was recreated from the validation result
+// with the given ID using the given ruleset version, and returns it. If sourceID is 0, the new validation
+// result is not linked to a previous result.
+func (s *Store) AddRevalidationResult(ctx context.Context, rulesetVersion string, sourceID int) (int, error) {
+	var err error
+
+	sourceIDNullable := sql.NullInt64{
+		Int64: int64(sourceID),
+		Valid: sourceID != 0,
+	}
+
+	valResRow := addRevalidationResultStatement.QueryRowContext(ctx, rulesetVersion, sourceIDNullable)
+	valResID := 0
+	err = valResRow.Scan(&valResID)
+
+	return valResID, err
+}
+
+// AddValidation adds the Validation data and structural validation issues to the database and stores
+// the Validation's conformance score on the given validation result
 func (s *Store) AddValidation(ctx context.Context, v *endpointmanager.Validation, valResID int) error {
 	var err error
 
@@ -74,12 +156,29 @@ func (s *Store) AddValidation(ctx context.Context, v *endpointmanager.Validation
 			ruleInfo.Comment,
 			ruleInfo.Reference,
 			ruleInfo.ImplGuide,
+			valResID,
+			ruleInfo.Resource,
+			ruleInfo.Severity,
+			ruleInfo.Weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, issue := range v.Issues {
+		_, err = addValidationIssueStatement.ExecContext(ctx,
+			issue.Severity,
+			issue.Code,
+			issue.Diagnostics,
+			issue.Expression,
 			valResID)
 		if err != nil {
 			return err
 		}
 	}
 
+	_, err = updateValidationScoreStatement.ExecContext(ctx, v.Score, valResID)
+
 	return err
 }
 
@@ -92,6 +191,13 @@ func prepareValidationStatements(s *Store) error {
 	if err != nil {
 		return err
 	}
+	addRevalidationResultStatement, err = s.DB.Prepare(`
+		INSERT INTO validation_results (ruleset_version, revalidated_from)
+		VALUES ($1, $2)
+		RETURNING id;`)
+	if err != nil {
+		return err
+	}
 	addValidationStatement, err = s.DB.Prepare(`
 	INSERT INTO validations (
 		rule_name,
@@ -101,8 +207,29 @@ func prepareValidationStatements(s *Store) error {
 		comment,
 		reference,
 		implementation_guide,
+		validation_result_id,
+		resource,
+		severity,
+		weight)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)
+	if err != nil {
+		return err
+	}
+	addValidationIssueStatement, err = s.DB.Prepare(`
+	INSERT INTO validation_issues (
+		severity,
+		code,
+		diagnostics,
+		expression,
 		validation_result_id)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return err
+	}
+	updateValidationScoreStatement, err = s.DB.Prepare(`
+		UPDATE validation_results
+		SET conformance_score = $1
+		WHERE id = $2`)
 	if err != nil {
 		return err
 	}
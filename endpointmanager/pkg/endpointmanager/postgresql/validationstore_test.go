@@ -4,7 +4,9 @@ package postgresql
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
@@ -48,6 +50,27 @@ func Test_PersistValidation(t *testing.T) {
 				ImplGuide: "USCore 3.1",
 				Reference: "https://www.hl7.org/fhir/us/core/CapabilityStatement-us-core-server.html",
 			},
+			{
+				RuleName:  endpointmanager.USCoreProfileRule,
+				Valid:     true,
+				Actual:    "us-core-patient",
+				Expected:  "us-core-patient",
+				Comment:   "The US Core Server SHALL support the US Core profiles for the Patient resource.",
+				ImplGuide: "USCore 3.1.1",
+				Reference: "http://hl7.org/fhir/us/core/STU3.1.1/CapabilityStatement-us-core-server.html",
+				Resource:  "Patient",
+				Severity:  endpointmanager.SeverityError,
+				Weight:    3,
+			},
+		},
+		Score: 0.75,
+		Issues: []endpointmanager.ValidationIssue{
+			{
+				Severity:    "error",
+				Code:        "required",
+				Diagnostics: "CapabilityStatement.status: minimum required = 1, but only found 0",
+				Expression:  "CapabilityStatement.status",
+			},
 		},
 	}
 
@@ -83,5 +106,52 @@ func Test_PersistValidation(t *testing.T) {
 
 	validationRows, err = store.GetValidationByID(ctx, valResID2)
 	th.Assert(t, err == nil, fmt.Sprintf("Error getting validation from ID %d, error: %s", valResID2, err))
-	th.Assert(t, len(*validationRows) == 2, fmt.Sprintf("ID %d should have length 2, is instead %d", valResID2, len(*validationRows)))
+	th.Assert(t, len(*validationRows) == 3, fmt.Sprintf("ID %d should have length 3, is instead %d", valResID2, len(*validationRows)))
+	th.Assert(t, (*validationRows)[0].Resource == "", fmt.Sprintf("The first rule for ID %d should not have a resource, has %s", valResID2, (*validationRows)[0].Resource))
+	th.Assert(t, (*validationRows)[2].Resource == "Patient", fmt.Sprintf("The third rule for ID %d should have the Patient resource, has %s", valResID2, (*validationRows)[2].Resource))
+	th.Assert(t, (*validationRows)[2].Severity == endpointmanager.SeverityError, fmt.Sprintf("The third rule for ID %d should have severity error, has %s", valResID2, (*validationRows)[2].Severity))
+	th.Assert(t, (*validationRows)[2].Weight == 3, fmt.Sprintf("The third rule for ID %d should have weight 3, has %f", valResID2, (*validationRows)[2].Weight))
+
+	// retrieve issues
+
+	issues, err := store.GetValidationIssuesByID(ctx, valResID1)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting validation issues from ID %d, error: %s", valResID1, err))
+	th.Assert(t, len(issues) == 0, fmt.Sprintf("ID %d should have no issues, has %d", valResID1, len(issues)))
+
+	issues, err = store.GetValidationIssuesByID(ctx, valResID2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting validation issues from ID %d, error: %s", valResID2, err))
+	th.Assert(t, len(issues) == 1, fmt.Sprintf("ID %d should have 1 issue, has %d", valResID2, len(issues)))
+	th.Assert(t, reflect.DeepEqual(issues[0], testValidation2.Issues[0]), fmt.Sprintf("ID %d issue should be %+v, is instead %+v", valResID2, testValidation2.Issues[0], issues[0]))
+
+	// retrieve scores
+
+	score, err := store.GetValidationScoreByID(ctx, valResID2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting validation score from ID %d, error: %s", valResID2, err))
+	th.Assert(t, score == 0.75, fmt.Sprintf("ID %d should have score 0.75, is instead %f", valResID2, score))
+
+	score, err = store.GetValidationScoreByID(ctx, valResID2+100)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting validation score for ID that does not exist, error: %s", err))
+	th.Assert(t, score == 0, fmt.Sprintf("ID that does not exist should have score 0, is instead %f", score))
+
+	// add revalidation results
+
+	revalResID, err := store.AddRevalidationResult(ctx, "test-ruleset", valResID2)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding revalidation result ID: %s", err))
+
+	var rulesetVersion string
+	var sourceID int
+	revalResRow := store.DB.QueryRow("SELECT ruleset_version, revalidated_from FROM validation_results WHERE id = $1;", revalResID)
+	err = revalResRow.Scan(&rulesetVersion, &sourceID)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting revalidation result %d: %s", revalResID, err))
+	th.Assert(t, rulesetVersion == "test-ruleset", fmt.Sprintf("Ruleset version should be test-ruleset, is instead %s", rulesetVersion))
+	th.Assert(t, sourceID == valResID2, fmt.Sprintf("Revalidation result should come from %d, comes from %d", valResID2, sourceID))
+
+	revalResID, err = store.AddRevalidationResult(ctx, "test-ruleset", 0)
+	th.Assert(t, err == nil, fmt.Sprintf("Error adding revalidation result ID without a source: %s", err))
+
+	var sourceIDNullable sql.NullInt64
+	revalResRow = store.DB.QueryRow("SELECT revalidated_from FROM validation_results WHERE id = $1;", revalResID)
+	err = revalResRow.Scan(&sourceIDNullable)
+	th.Assert(t, err == nil, fmt.Sprintf("Error getting revalidation result %d: %s", revalResID, err))
+	th.Assert(t, !sourceIDNullable.Valid, "Revalidation result without a source should not be linked to a previous result")
 }
@@ -0,0 +1,14 @@
+package endpointmanager
+
+import "time"
+
+// QuarantinedMessage is a queue message or worker job payload that a Lantern service panicked while processing,
+// kept along with the panic so that it can be looked into without the service going down.
+type QuarantinedMessage struct {
+	ID        int
+	Source    string
+	Payload   []byte
+	Error     string
+	Stack     string
+	CreatedAt time.Time
+}
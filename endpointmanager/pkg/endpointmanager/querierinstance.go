@@ -0,0 +1,13 @@
+package endpointmanager
+
+import "time"
+
+// QuerierInstance is a running capability querier. Each instance registers itself and updates its heartbeat
+// while it runs, so that the instances sharing the query load can be seen.
+type QuerierInstance struct {
+	ID            string
+	Hostname      string
+	NumWorkers    int
+	StartedAt     time.Time
+	LastHeartbeat time.Time
+}
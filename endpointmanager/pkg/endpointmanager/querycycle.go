@@ -0,0 +1,36 @@
+package endpointmanager
+
+import "time"
+
+// The statuses a query cycle can have
+const (
+	QueryCycleSending   = "sending"
+	QueryCycleRunning   = "running"
+	QueryCycleCompleted = "completed"
+	QueryCycleTimedOut  = "timed_out"
+)
+
+// QueryCycle tracks the progress of a batch of endpoints sent to be queried. EndpointCount and Status are set by
+// the endpoint manager, and the progress counts are updated by the capability receiver as the cycle's messages
+// come back.
+type QueryCycle struct {
+	ID               int
+	Status           string
+	EndpointCount    int
+	VersionsReceived int
+	Enqueued         int
+	Queried          int
+	Stored           int
+	Failed           int
+	StartedAt        time.Time
+	CompletedAt      time.Time
+	UpdatedAt        time.Time
+}
+
+// Done returns whether every endpoint in the cycle has been sent and queried, and every capability statement
+// query enqueued for the cycle has come back
+func (c *QueryCycle) Done() bool {
+	return c.Status != QueryCycleSending &&
+		c.VersionsReceived >= c.EndpointCount &&
+		c.Queried >= c.Enqueued
+}
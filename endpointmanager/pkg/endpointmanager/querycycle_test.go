@@ -0,0 +1,32 @@
+package endpointmanager
+
+import "testing"
+
+func Test_QueryCycleDone(t *testing.T) {
+	cycle := QueryCycle{Status: QueryCycleSending, EndpointCount: 2, VersionsReceived: 2, Enqueued: 3, Queried: 3}
+	if cycle.Done() {
+		t.Errorf("Expected a cycle that is still sending not to be done")
+	}
+
+	cycle.Status = QueryCycleRunning
+	if !cycle.Done() {
+		t.Errorf("Expected a cycle with every message received to be done")
+	}
+
+	cycle.VersionsReceived = 1
+	if cycle.Done() {
+		t.Errorf("Expected a cycle missing a versions response not to be done")
+	}
+
+	cycle.VersionsReceived = 2
+	cycle.Queried = 2
+	if cycle.Done() {
+		t.Errorf("Expected a cycle missing a capability statement not to be done")
+	}
+
+	// endpoints without any supported versions do not enqueue capability statement queries
+	cycle = QueryCycle{Status: QueryCycleRunning, EndpointCount: 1, VersionsReceived: 1}
+	if !cycle.Done() {
+		t.Errorf("Expected a cycle with no capability statement queries to be done")
+	}
+}
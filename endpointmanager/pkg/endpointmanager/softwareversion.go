@@ -0,0 +1,27 @@
+package endpointmanager
+
+import "time"
+
+// EndpointSoftwareVersion is a software name, version and release date advertised in the capability statement of
+// a FHIR endpoint, along with the first and last times the endpoint was seen advertising it.
+type EndpointSoftwareVersion struct {
+	ID                   int
+	URL                  string
+	RequestedFhirVersion string
+	VendorID             int
+	Name                 string
+	Version              string
+	ReleaseDate          string
+	FirstSeen            time.Time
+	LastSeen             time.Time
+}
+
+// SoftwareVersionAdoption is the number of endpoints of a vendor that advertised a software version during the
+// period starting at Period.
+type SoftwareVersionAdoption struct {
+	VendorName      string
+	SoftwareName    string
+	SoftwareVersion string
+	Period          time.Time
+	EndpointCount   int
+}
@@ -37,6 +37,11 @@ func AddEndpointData(ctx context.Context, store *postgresql.Store, endpoints *fe
 			if fhirURL[len(fhirURL)-1:] != "/" {
 				fhirURL = fhirURL + "/"
 			}
+			fhirURL, err = store.GetCanonicalFHIREndpointURL(ctx, fhirURL)
+			if err != nil {
+				log.Warn(err)
+				continue
+			}
 			existingEndpt, err := store.GetFHIREndpointUsingURLAndListSource(ctx, fhirURL, endpoint.ListSource)
 			if err != nil {
 				log.Warn(err)
@@ -56,16 +61,86 @@ func AddEndpointData(ctx context.Context, store *postgresql.Store, endpoints *fe
 }
 
 // saveEndpointData formats the endpoint as a FHIREndpoint and then checks to see if it's in the database.
-// If it is, ignore it, if it isn't, add it to the database.
+// If it is, ignore it, if it isn't, add it to the database. An endpoint whose URL is an alias of an endpoint
+// that is already in the database, such as the same URL with a different case host or a URL that redirects
+// to it, is saved under the existing endpoint's URL, and its URL is recorded as an alias of that endpoint.
 func saveEndpointData(ctx context.Context, store *postgresql.Store, endpoint *fetcher.EndpointEntry) error {
 	fhirEndpoint, err := formatToFHIREndpt(endpoint)
 	if err != nil {
 		return err
 	}
 
+	listURL := fhirEndpoint.URL
+	fhirEndpoint.URL, err = store.GetCanonicalFHIREndpointURL(ctx, listURL)
+	if err != nil {
+		return errors.Wrap(err, "getting canonical fhir endpoint URL failed")
+	}
+
 	err = store.AddOrUpdateFHIREndpoint(ctx, fhirEndpoint)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// link the endpoint to its canonical URL, keeping any canonical URL already found by following redirects
+	err = store.AddFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(fhirEndpoint.URL, ""))
+	if err != nil {
+		return err
+	}
+	if listURL == fhirEndpoint.URL {
+		return nil
+	}
+
+	// link the URL from the list to the canonical URL of the endpoint it was saved as
+	storedAlias, err := store.GetFHIREndpointAlias(ctx, fhirEndpoint.URL)
+	if err != nil {
+		return errors.Wrap(err, "getting the alias of the stored fhir endpoint failed")
+	}
+	finalURL := storedAlias.FinalURL
+	if finalURL == "" {
+		finalURL = fhirEndpoint.URL
+	}
+	return store.UpdateFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(listURL, finalURL))
+}
+
+// BackfillEndpointAliases links the endpoints in the fhir_endpoints table that do not have an alias yet to their
+// canonical URL, using the final URL of their last query if there is one, and rebuilds the canonical URL of the
+// existing aliases whose canonical URL was built differently. The aliases that are added are created at the time
+// their endpoint was first added, so the first endpoint added stays the one its aliases are saved as.
+func BackfillEndpointAliases(ctx context.Context, store *postgresql.Store) error {
+	aliases, err := store.GetFHIREndpointAliasBackfill(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting the endpoint aliases to backfill failed")
+	}
+
+	added := 0
+	updated := 0
+	for _, alias := range aliases {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "added %d and updated %d endpoint aliases before context ended", added, updated)
+		default:
+			// ok
+		}
+
+		backfilled := endpointmanager.NewFHIREndpointAlias(alias.URL, alias.FinalURL)
+		if alias.CanonicalURL == "" {
+			backfilled.CreatedAt = alias.CreatedAt
+			err = store.AddFHIREndpointAlias(ctx, backfilled)
+			if err != nil {
+				return errors.Wrapf(err, "adding the alias of %s failed", alias.URL)
+			}
+			added++
+		} else if alias.CanonicalURL != backfilled.CanonicalURL {
+			err = store.UpdateFHIREndpointAlias(ctx, backfilled)
+			if err != nil {
+				return errors.Wrapf(err, "updating the alias of %s failed", alias.URL)
+			}
+			updated++
+		}
+	}
+
+	log.Infof("Added %d and updated %d endpoint aliases", added, updated)
+	return nil
 }
 
 // formatToFHIREndpt takes an entry in the list of endpoints and formats it for the fhir_endpoints table in the database
@@ -130,6 +205,14 @@ func RemoveOldEndpoints(ctx context.Context, store *postgresql.Store, updateTime
 						continue
 					}
 				}
+				err = store.DeleteFHIREndpointAlias(ctx, endpoint.URL)
+				if err != nil {
+					log.Warn(err)
+				}
+				err = store.DeleteFHIREndpointSchedule(ctx, endpoint.URL)
+				if err != nil {
+					log.Warn(err)
+				}
 			}
 		}
 	}
@@ -171,6 +171,11 @@ func Test_saveEndpointData(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, fhirEndpt.Equal(savedEndpt), "stored data does not equal expected store data")
 
+	// check that the stored item is linked to its canonical URL
+	alias, err := store.GetFHIREndpointAlias(ctx, savedEndpt.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, alias.CanonicalURL == endpointmanager.CanonicalURL(savedEndpt.URL), fmt.Sprintf("expected canonical URL %s, got %s", endpointmanager.CanonicalURL(savedEndpt.URL), alias.CanonicalURL))
+
 	// check that an item with the same URL replaces item and merges the organization names lists
 	endpt.OrganizationNames = []string{"AdvantageCare Physicians 2"}
 	err = saveEndpointData(ctx, store, &endpt)
@@ -188,6 +193,40 @@ func Test_saveEndpointData(t *testing.T) {
 	th.Assert(t, helpers.StringArraysEqual(savedEndpt.OrganizationNames, []string{"AdvantageCare Physicians", "AdvantageCare Physicians 2"}),
 		fmt.Sprintf("stored data %v does not equal expected store data [AdvantageCare Physicians, AdvantageCare Physicians 2]", savedEndpt.OrganizationNames))
 
+	// check that an alias of the stored item is saved as the stored item
+	aliasEndpt := endpt
+	aliasEndpt.FHIRPatientFacingURI = "https://EPWEBAPPS.acpny.com:443/FHIRproxy/api/FHIR/DSTU2"
+	aliasEndpt.OrganizationNames = []string{"AdvantageCare Physicians 3"}
+	err = saveEndpointData(ctx, store, &aliasEndpt)
+	th.Assert(t, err == nil, err)
+
+	err = ctStmt.QueryRow().Scan(&ct)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, ct == 1, "expected the alias to be saved as the stored item")
+
+	savedEndpt, err = store.GetFHIREndpoint(ctx, endptID)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, savedEndpt.URL == fhirEndpt.URL, fmt.Sprintf("expected the stored URL %s to be kept, got %s", fhirEndpt.URL, savedEndpt.URL))
+	th.Assert(t, helpers.StringArraysEqual(savedEndpt.OrganizationNames, []string{"AdvantageCare Physicians", "AdvantageCare Physicians 2", "AdvantageCare Physicians 3"}),
+		fmt.Sprintf("stored data %v does not include the alias organization name", savedEndpt.OrganizationNames))
+
+	// check that the list URL of the alias is linked to the canonical URL of the stored item
+	listAlias, err := store.GetFHIREndpointAlias(ctx, "https://EPWEBAPPS.acpny.com:443/FHIRproxy/api/FHIR/DSTU2/")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, listAlias.CanonicalURL == alias.CanonicalURL, fmt.Sprintf("expected the list URL to have canonical URL %s, got %s", alias.CanonicalURL, listAlias.CanonicalURL))
+
+	// check that a URL that redirects to the stored item is saved as the stored item
+	redirectEndpt := endpt
+	redirectEndpt.FHIRPatientFacingURI = "https://old.acpny.com/FHIRproxy/api/FHIR/DSTU2/"
+	err = store.UpdateFHIREndpointAlias(ctx, endpointmanager.NewFHIREndpointAlias(redirectEndpt.FHIRPatientFacingURI, fhirEndpt.URL))
+	th.Assert(t, err == nil, err)
+	err = saveEndpointData(ctx, store, &redirectEndpt)
+	th.Assert(t, err == nil, err)
+
+	err = ctStmt.QueryRow().Scan(&ct)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, ct == 1, "expected the redirected URL to be saved as the stored item")
+
 	// reset context
 	ctx = context.Background()
 
@@ -462,6 +501,47 @@ func Test_RemoveOldEndpoints(t *testing.T) {
 
 }
 
+func Test_BackfillEndpointAliases(t *testing.T) {
+	teardown, _ := th.IntegrationDBTestSetup(t, store.DB)
+	defer teardown(t, store.DB)
+
+	var err error
+	ctx := context.Background()
+
+	// an endpoint stored before aliases were recorded
+	endpt2 := testFHIREndpoint2
+	err = store.AddFHIREndpoint(ctx, &endpt2)
+	th.Assert(t, err == nil, err)
+	savedEndpt2, err := store.GetFHIREndpoint(ctx, endpt2.ID)
+	th.Assert(t, err == nil, err)
+	// an endpoint whose canonical URL was built by an older version of CanonicalURL
+	endpt3 := testFHIREndpoint3
+	err = store.AddFHIREndpoint(ctx, &endpt3)
+	th.Assert(t, err == nil, err)
+	err = store.UpdateFHIREndpointAlias(ctx, &endpointmanager.FHIREndpointAlias{URL: endpt3.URL, CanonicalURL: "https://example.com:80/DTSU2"})
+	th.Assert(t, err == nil, err)
+
+	err = BackfillEndpointAliases(ctx, store)
+	th.Assert(t, err == nil, err)
+
+	alias, err := store.GetFHIREndpointAlias(ctx, endpt2.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, alias.CanonicalURL == endpointmanager.CanonicalURL(endpt2.URL), fmt.Sprintf("expected canonical URL %s, got %s", endpointmanager.CanonicalURL(endpt2.URL), alias.CanonicalURL))
+	th.Assert(t, alias.CreatedAt.Equal(savedEndpt2.CreatedAt), fmt.Sprintf("expected the alias to be created when the endpoint was added at %s, got %s", savedEndpt2.CreatedAt, alias.CreatedAt))
+
+	alias, err = store.GetFHIREndpointAlias(ctx, endpt3.URL)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, alias.CanonicalURL == "https://example.com/DTSU2", fmt.Sprintf("expected canonical URL https://example.com/DTSU2, got %s", alias.CanonicalURL))
+
+	// backfilling again leaves the aliases as they are
+	err = BackfillEndpointAliases(ctx, store)
+	th.Assert(t, err == nil, err)
+	var ct int
+	err = store.DB.QueryRow("SELECT COUNT(*) FROM fhir_endpoint_aliases").Scan(&ct)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, ct == 2, fmt.Sprintf("expected 2 endpoint aliases, got %d", ct))
+}
+
 func setup() error {
 	var err error
 	store, err = postgresql.NewStore(viper.GetString("dbhost"), viper.GetInt("dbport"), viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbname"), viper.GetString("dbsslmode"))
@@ -36,6 +36,7 @@ type Operation struct {
 	SMARTHTTPResponse      int                    `json:"smart_http_response"`
 	SMARTResponse          map[string]interface{} `json:"smart_response"`
 	UpdatedAt              time.Time              `json:"updated"`
+	ConformanceScore       *float64               `json:"conformance_score"`
 }
 
 // Result is the value that is returned from getting the history data from the
@@ -48,7 +49,6 @@ type Result struct {
 type historyArgs struct {
 	fhirURL    string
 	store      *postgresql.Store
-	result     chan Result
 	exportType string
 }
 
@@ -109,7 +109,6 @@ func createJSON(ctx context.Context, store *postgresql.Store, exportType string)
 		entries = append(entries, e)
 	}
 
-	errs := make(chan error)
 	numWorkers := viper.GetInt("export_numworkers")
 	// If numWorkers not set, default to 10 workers
 	if numWorkers == 0 {
@@ -118,25 +117,26 @@ func createJSON(ctx context.Context, store *postgresql.Store, exportType string)
 	allWorkers := workers.NewWorkers()
 
 	// Start workers
-	err = allWorkers.Start(ctx, numWorkers, errs)
+	err = allWorkers.Start(ctx, numWorkers, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Error from starting workers. Error: %s", err)
 	}
+	defer allWorkers.Stop()
 
-	resultCh := make(chan Result)
-	go createJobs(ctx, resultCh, urls, store, allWorkers, exportType)
+	results := createJobs(ctx, urls, store, allWorkers, exportType)
 
 	// Add the results from createJobs to mapURLHistory
-	count := 0
 	mapURLHistory := make(map[string][]Operation)
-	for res := range resultCh {
-		if res.URL != "unknown" {
-			mapURLHistory[res.URL] = res.Rows
+	for _, result := range results {
+		err = result.Wait(ctx)
+		if err != nil {
+			log.Warnf("Error while getting the history of a URL: %s", err)
+			continue
 		}
-		if count == len(urls)-1 {
-			close(resultCh)
+		res, ok := result.Value().(Result)
+		if ok && res.URL != "unknown" {
+			mapURLHistory[res.URL] = res.Rows
 		}
-		count++
 	}
 
 	// Add each array of rows to the Operation field in the entries
@@ -191,19 +191,18 @@ func getSupportedResources(opRes []byte) []string {
 }
 
 // creates jobs for the workers so that each worker gets the history data
-// for a specified url
+// for a specified url, and returns the workers' results for the jobs
 func createJobs(ctx context.Context,
-	ch chan Result,
 	urls []string,
 	store *postgresql.Store,
 	allWorkers *workers.Workers,
-	exportType string) {
+	exportType string) []*workers.Result {
+	var results []*workers.Result
 	for index := range urls {
 		jobArgs := make(map[string]interface{})
 		jobArgs["historyArgs"] = historyArgs{
 			fhirURL:    urls[index],
 			store:      store,
-			result:     ch,
 			exportType: exportType,
 		}
 		workerDur := viper.GetInt("export_duration")
@@ -213,26 +212,30 @@ func createJobs(ctx context.Context,
 		}
 
 		job := workers.Job{
-			Context:     ctx,
-			Duration:    time.Duration(workerDur) * time.Second,
-			Handler:     getHistory,
-			HandlerArgs: &jobArgs,
+			Context:      ctx,
+			Duration:     time.Duration(workerDur) * time.Second,
+			ValueHandler: getHistory,
+			HandlerArgs:  &jobArgs,
 		}
 
-		err := allWorkers.Add(&job)
+		result, err := allWorkers.Add(ctx, &job)
 		if err != nil {
 			log.Warnf("Error while adding job for getting history for URL %s, %s", urls[index], err)
+			continue
 		}
+		results = append(results, result)
 	}
+
+	return results
 }
 
-// getHistory gets the database history of a specified url
-func getHistory(ctx context.Context, args *map[string]interface{}) error {
+// getHistory gets the database history of a specified url, which is returned as the job's Result
+func getHistory(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
 	var resultRows []Operation
 
 	ha, ok := (*args)["historyArgs"].(historyArgs)
 	if !ok {
-		return fmt.Errorf("unable to cast arguments to type historyArgs")
+		return nil, fmt.Errorf("unable to cast arguments to type historyArgs")
 	}
 
 	exportType := ha.exportType
@@ -244,7 +247,8 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 		selectHistory = `
 		SELECT fhir_endpoints_info_history.url, fhir_endpoints_metadata.http_response, fhir_endpoints_metadata.response_time_seconds, fhir_endpoints_metadata.errors,
 		capability_statement, tls_version, mime_types, operation_resource,
-		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version
+		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version,
+		(SELECT conformance_score FROM validation_results WHERE validation_results.id = fhir_endpoints_info_history.validation_result_id)
 		FROM fhir_endpoints_info_history, fhir_endpoints_metadata
 		WHERE fhir_endpoints_info_history.metadata_id = fhir_endpoints_metadata.id AND fhir_endpoints_info_history.url=$1 AND (date_trunc('month', fhir_endpoints_info_history.updated_at) = date_trunc('month', current_date - INTERVAL '1 month'))
 		ORDER BY fhir_endpoints_info_history.updated_at DESC;`
@@ -252,7 +256,8 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 		selectHistory = `
 		SELECT fhir_endpoints_info_history.url, fhir_endpoints_metadata.http_response, fhir_endpoints_metadata.response_time_seconds, fhir_endpoints_metadata.errors,
 		capability_statement, tls_version, mime_types, operation_resource,
-		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version
+		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version,
+		(SELECT conformance_score FROM validation_results WHERE validation_results.id = fhir_endpoints_info_history.validation_result_id)
 		FROM fhir_endpoints_info_history, fhir_endpoints_metadata
 		WHERE fhir_endpoints_info_history.metadata_id = fhir_endpoints_metadata.id AND fhir_endpoints_info_history.url=$1 AND (date_trunc('day', fhir_endpoints_info_history.updated_at) >= date_trunc('day', current_date - INTERVAL '30 day'))
 		ORDER BY fhir_endpoints_info_history.updated_at DESC;`
@@ -260,7 +265,8 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 		selectHistory = `
 		SELECT fhir_endpoints_info_history.url, fhir_endpoints_metadata.http_response, fhir_endpoints_metadata.response_time_seconds, fhir_endpoints_metadata.errors,
 		capability_statement, tls_version, mime_types, operation_resource,
-		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version
+		fhir_endpoints_metadata.smart_http_response, smart_response, fhir_endpoints_info_history.updated_at, capability_fhir_version,
+		(SELECT conformance_score FROM validation_results WHERE validation_results.id = fhir_endpoints_info_history.validation_result_id)
 		FROM fhir_endpoints_info_history, fhir_endpoints_metadata
 		WHERE fhir_endpoints_info_history.metadata_id = fhir_endpoints_metadata.id AND fhir_endpoints_info_history.url=$1
 		ORDER BY fhir_endpoints_info_history.updated_at DESC;`
@@ -269,15 +275,13 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 	historyRows, err := ha.store.DB.QueryContext(ctx, selectHistory, ha.fhirURL)
 	if err != nil {
 		log.Warnf("Failed getting the history rows for URL %s. Error: %s", ha.fhirURL, err)
-		result := Result{
+		return Result{
 			URL:  ha.fhirURL,
 			Rows: resultRows,
-		}
-		ha.result <- result
-		return nil
+		}, nil
 	}
 
-	// Puts the rows in an array and sends it back on the channel to be processed
+	// Puts the rows in an array to be returned for processing
 	defer historyRows.Close()
 	for historyRows.Next() {
 		var op Operation
@@ -285,6 +289,7 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 		var capStat []byte
 		var smartRsp []byte
 		var opRes []byte
+		var scoreNullable sql.NullFloat64
 		err = historyRows.Scan(
 			&url,
 			&op.HTTPResponse,
@@ -297,26 +302,26 @@ func getHistory(ctx context.Context, args *map[string]interface{}) error {
 			&op.SMARTHTTPResponse,
 			&smartRsp,
 			&op.UpdatedAt,
-			&op.FHIRVersion)
+			&op.FHIRVersion,
+			&scoreNullable)
 		if err != nil {
 			log.Warnf("Error while scanning the rows of the history table for URL %s. Error: %s", ha.fhirURL, err)
-			result := Result{
+			return Result{
 				URL:  ha.fhirURL,
 				Rows: resultRows,
-			}
-			ha.result <- result
-			return nil
+			}, nil
 		}
 
 		op.SMARTResponse = getSMARTResponse(smartRsp)
 		op.SupportedResources = getSupportedResources(opRes)
+		if scoreNullable.Valid {
+			op.ConformanceScore = &scoreNullable.Float64
+		}
 
 		resultRows = append(resultRows, op)
 	}
-	result := Result{
+	return Result{
 		URL:  ha.fhirURL,
 		Rows: resultRows,
-	}
-	ha.result <- result
-	return nil
+	}, nil
 }
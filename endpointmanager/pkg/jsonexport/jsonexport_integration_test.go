@@ -140,23 +140,19 @@ func Test_getHistory(t *testing.T) {
 
 	// Base case
 
-	resultCh := make(chan Result)
 	jobArgs := make(map[string]interface{})
 	jobArgs["historyArgs"] = historyArgs{
 		fhirURL: "www.testURL.com",
 		store:   store,
-		result:  resultCh,
 		exportType: "30days",
 	}
 
-	go getHistory(ctx, &jobArgs)
-
-	for res := range resultCh {
-		th.Assert(t, len(res.Rows) == 1, fmt.Sprintf("Expected 1 entry in history table. Actually had %d entries.", len(res.Rows)))
-		th.Assert(t, res.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Rows[0].TLSVersion == "TLS 1.3", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", res.Rows[0].TLSVersion))
-		close(resultCh)
-	}
+	value, err := getHistory(ctx, &jobArgs)
+	th.Assert(t, err == nil, err)
+	res := value.(Result)
+	th.Assert(t, len(res.Rows) == 1, fmt.Sprintf("Expected 1 entry in history table. Actually had %d entries.", len(res.Rows)))
+	th.Assert(t, res.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", res.URL))
+	th.Assert(t, res.Rows[0].TLSVersion == "TLS 1.3", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", res.Rows[0].TLSVersion))
 
 	// base case with export type equal to month
 
@@ -176,23 +172,19 @@ func Test_getHistory(t *testing.T) {
 	th.Assert(t, err == nil, err)
 	th.Assert(t, actualNumEndptsStored == 1, fmt.Sprintf("Expected 1 endpoints stored. Actually had %d endpoints stored.", actualNumEndptsStored))
 
-	resultChMonth := make(chan Result)
 	jobArgsMonth := make(map[string]interface{})
 	jobArgsMonth["historyArgs"] = historyArgs{
 		fhirURL: "www.testURL.com",
 		store:   store,
-		result:  resultChMonth,
 		exportType: "month",
 	}
 
-	go getHistory(ctx, &jobArgsMonth)
-
-	for res := range resultChMonth {
-		th.Assert(t, len(res.Rows) == 1, fmt.Sprintf("Expected 1 entry in history table. Actually had %d entries.", len(res.Rows)))
-		th.Assert(t, res.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Rows[0].TLSVersion == "TLS 1.3", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", res.Rows[0].TLSVersion))
-		close(resultChMonth)
-	}
+	valueMonth, err := getHistory(ctx, &jobArgsMonth)
+	th.Assert(t, err == nil, err)
+	resMonth := valueMonth.(Result)
+	th.Assert(t, len(resMonth.Rows) == 1, fmt.Sprintf("Expected 1 entry in history table. Actually had %d entries.", len(resMonth.Rows)))
+	th.Assert(t, resMonth.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", resMonth.URL))
+	th.Assert(t, resMonth.Rows[0].TLSVersion == "TLS 1.3", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", resMonth.Rows[0].TLSVersion))
 
 	// base case with export type equal to all
 
@@ -214,23 +206,19 @@ func Test_getHistory(t *testing.T) {
 	th.Assert(t, actualNumEndptsStored == 3, fmt.Sprintf("Expected 3 endpoints stored. Actually had %d endpoints stored.", actualNumEndptsStored))
 
 
-	resultChAll := make(chan Result)
 	jobArgsAll := make(map[string]interface{})
 	jobArgsAll["historyArgs"] = historyArgs{
 		fhirURL: "www.testURL.com",
 		store:   store,
-		result:  resultChAll,
 		exportType: "all",
 	}
 
-	go getHistory(ctx, &jobArgsAll)
-
-	for res := range resultChAll {
-		th.Assert(t, len(res.Rows) == 3, fmt.Sprintf("Expected 3 entries in history table. Actually had %d entries.", len(res.Rows)))
-		th.Assert(t, res.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", res.URL))
-		th.Assert(t, res.Rows[0].TLSVersion == "TLS 1.4", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", res.Rows[0].TLSVersion))
-		close(resultChAll)
-	}
+	valueAll, err := getHistory(ctx, &jobArgsAll)
+	th.Assert(t, err == nil, err)
+	resAll := valueAll.(Result)
+	th.Assert(t, len(resAll.Rows) == 3, fmt.Sprintf("Expected 3 entries in history table. Actually had %d entries.", len(resAll.Rows)))
+	th.Assert(t, resAll.URL == "www.testURL.com", fmt.Sprintf("Expected URL to equal 'www.testURL.com'. Is actually '%s'.", resAll.URL))
+	th.Assert(t, resAll.Rows[0].TLSVersion == "TLS 1.4", fmt.Sprintf("Should be the current entry in the fhir_endpoints_info table. %+v", resAll.Rows[0].TLSVersion))
 
 	// If the args are not properly formatted
 
@@ -239,26 +227,23 @@ func Test_getHistory(t *testing.T) {
 		"nonsense": 1,
 	}
 
-	err = getHistory(ctx, &jobArgs2)
+	_, err = getHistory(ctx, &jobArgs2)
 	th.Assert(t, err != nil, fmt.Sprint("Malformed arguments should have thrown error."))
 
 	// If the URL does not exist, return an empty array
 
-	resultCh3 := make(chan Result)
 	jobArgs3 := make(map[string]interface{})
 	jobArgs3["historyArgs"] = historyArgs{
 		fhirURL: "thisurldoesntexist.com",
 		store:   store,
-		result:  resultCh3,
 		exportType: "30days",
 	}
 
-	go getHistory(ctx, &jobArgs3)
-	for res := range resultCh3 {
-		th.Assert(t, len(res.Rows) == 0, fmt.Sprintf("Expected 0 entries in history table. Actually had %d entries.", len(res.Rows)))
-		th.Assert(t, res.URL == "thisurldoesntexist.com", fmt.Sprintf("Expected URL to equal 'thisurldoesntexist.com'. Is actually '%s'.", res.URL))
-		close(resultCh3)
-	}
+	value3, err := getHistory(ctx, &jobArgs3)
+	th.Assert(t, err == nil, err)
+	res3 := value3.(Result)
+	th.Assert(t, len(res3.Rows) == 0, fmt.Sprintf("Expected 0 entries in history table. Actually had %d entries.", len(res3.Rows)))
+	th.Assert(t, res3.URL == "thisurldoesntexist.com", fmt.Sprintf("Expected URL to equal 'thisurldoesntexist.com'. Is actually '%s'.", res3.URL))
 }
 
 func setup() error {
@@ -0,0 +1,45 @@
+package quarantine
+
+import (
+	"context"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/workers"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	log "github.com/sirupsen/logrus"
+)
+
+// Store is the part of the postgresql Store that quarantined messages are added to.
+type Store interface {
+	AddQuarantinedMessage(context.Context, *endpointmanager.QuarantinedMessage) error
+}
+
+// MessageHook returns a function to use as the Panicked hook of lanternmq.Hooks, which stores each message
+// that a message handler panicked on in the quarantined_messages table with the queue's name as its source.
+func MessageHook(ctx context.Context, store Store) func(string, []byte, *lanternmq.PanicError) {
+	return func(qName string, message []byte, err *lanternmq.PanicError) {
+		add(ctx, store, qName, message, err.Error(), err.Stack)
+	}
+}
+
+// JobHook returns a function to use as the JobPanicked field of workers.Workers, which stores the payload
+// of each job that panicked in the quarantined_messages table with 'source' as its source. 'payload' returns
+// the payload to store for a job, such as the queue message the job was created for.
+func JobHook(ctx context.Context, store Store, source string, payload func(*workers.Job) []byte) func(*workers.Job, *workers.PanicError) {
+	return func(job *workers.Job, err *workers.PanicError) {
+		add(ctx, store, source, payload(job), err.Error(), err.Stack)
+	}
+}
+
+func add(ctx context.Context, store Store, source string, payload []byte, errMsg string, stack []byte) {
+	log.Errorf("quarantining message from %s: %s\n%s", source, errMsg, stack)
+	err := store.AddQuarantinedMessage(ctx, &endpointmanager.QuarantinedMessage{
+		Source:  source,
+		Payload: payload,
+		Error:   errMsg,
+		Stack:   string(stack),
+	})
+	if err != nil {
+		log.Warnf("unable to quarantine message from %s: %s", source, err.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/workers"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+)
+
+type mockStore struct {
+	messages []*endpointmanager.QuarantinedMessage
+	err      error
+}
+
+func (s *mockStore) AddQuarantinedMessage(ctx context.Context, m *endpointmanager.QuarantinedMessage) error {
+	s.messages = append(s.messages, m)
+	return s.err
+}
+
+func Test_MessageHook(t *testing.T) {
+	store := &mockStore{}
+	hook := MessageHook(context.Background(), store)
+
+	hook("capability-statements", []byte("malformed"), &lanternmq.PanicError{Value: "interface conversion", Stack: []byte("stack")})
+	th.Assert(t, len(store.messages) == 1, fmt.Sprintf("expected one quarantined message, got %d", len(store.messages)))
+	m := store.messages[0]
+	th.Assert(t, m.Source == "capability-statements", fmt.Sprintf("expected the queue name as the source, got %s", m.Source))
+	th.Assert(t, string(m.Payload) == "malformed", fmt.Sprintf("expected the message as the payload, got %s", m.Payload))
+	th.Assert(t, m.Error == "message handler panicked: interface conversion", fmt.Sprintf("unexpected error %s", m.Error))
+	th.Assert(t, m.Stack == "stack", fmt.Sprintf("expected the stack trace, got %s", m.Stack))
+
+	// a failure to store the message is only logged
+	store.err = errors.New("database unavailable")
+	hook("capability-statements", []byte("malformed"), &lanternmq.PanicError{Value: "interface conversion"})
+	th.Assert(t, len(store.messages) == 2, "expected the second message to be quarantined")
+}
+
+func Test_JobHook(t *testing.T) {
+	store := &mockStore{}
+	payload := func(job *workers.Job) []byte {
+		message, _ := (*job.HandlerArgs)["message"].([]byte)
+		return message
+	}
+	hook := JobHook(context.Background(), store, "endpoints-to-capability", payload)
+
+	args := map[string]interface{}{"message": []byte(`{"url":"http://example.com/fhir"}`)}
+	hook(&workers.Job{HandlerArgs: &args}, &workers.PanicError{Value: "index out of range", Stack: []byte("stack")})
+	th.Assert(t, len(store.messages) == 1, fmt.Sprintf("expected one quarantined message, got %d", len(store.messages)))
+	m := store.messages[0]
+	th.Assert(t, m.Source == "endpoints-to-capability", fmt.Sprintf("expected the given source, got %s", m.Source))
+	th.Assert(t, string(m.Payload) == `{"url":"http://example.com/fhir"}`, fmt.Sprintf("expected the job's message as the payload, got %s", m.Payload))
+	th.Assert(t, m.Error == "job panicked: index out of range", fmt.Sprintf("unexpected error %s", m.Error))
+}
@@ -0,0 +1,309 @@
+package queryendpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	se "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/sendendpoints"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkInterval is how long to wait between checks of whether an on demand query cycle has completed
+var checkInterval = 5 * time.Second
+
+// abandonTimeout is how long marking a query cycle as timed out may take once its request has been cancelled
+var abandonTimeout = 30 * time.Second
+
+// Response is the outcome of an on demand query. It includes the query cycle the endpoints were sent in, whether
+// the cycle completed or timed out, and the information stored for each endpoint.
+type Response struct {
+	CycleID int       `json:"cycle_id"`
+	Status  string    `json:"status"`
+	Results []*Result `json:"results"`
+}
+
+// Result is the information stored for one requested FHIR version of an endpoint, along with its validation
+// results and the metadata of the request that was made
+type Result struct {
+	URL                   string                                `json:"url"`
+	RequestedFhirVersion  string                                `json:"requested_fhir_version"`
+	CapabilityFhirVersion string                                `json:"capability_fhir_version"`
+	TLSVersion            string                                `json:"tls_version"`
+	MIMETypes             []string                              `json:"mime_types"`
+	UpdatedAt             time.Time                             `json:"updated"`
+	Metadata              *endpointmanager.FHIREndpointMetadata `json:"metadata"`
+	Validation            *endpointmanager.Validation           `json:"validation"`
+}
+
+// ListSourceURLs returns the URLs of the endpoints in the database from the given list source
+func ListSourceURLs(ctx context.Context, store *postgresql.Store, listSource string) ([]string, error) {
+	urls, err := store.GetFHIREndpointURLsUsingListSource(ctx, listSource)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get the endpoints from list source %s", listSource)
+	}
+	return urls, nil
+}
+
+// MergeURLs returns the given URLs followed by the other URLs that are not already included
+func MergeURLs(urls []string, others []string) []string {
+	included := make(map[string]bool)
+	for _, url := range urls {
+		included[url] = true
+	}
+	for _, url := range others {
+		if !included[url] {
+			included[url] = true
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// UnknownURLs returns the given URLs that are not in the fhir_endpoints table, so that only endpoints Lantern
+// already knows about are queried on demand
+func UnknownURLs(ctx context.Context, store *postgresql.Store, urls []string) ([]string, error) {
+	var unknown []string
+	for _, url := range urls {
+		endpoints, err := store.GetFHIREndpointUsingURL(ctx, url)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to look up endpoint %s", url)
+		}
+		if len(endpoints) == 0 {
+			unknown = append(unknown, url)
+		}
+	}
+	return unknown, nil
+}
+
+// QueryEndpoints sends the given URLs to the given queue with a high priority as a new query cycle, waits for the
+// cycle to complete or for the timeout to pass, and returns the information that is stored for the endpoints.
+// The endpoints are queried by the capabilityquerier and stored by the capabilityreceiver in the same way as
+// scheduled queries, but the endpoints' schedules are not changed.
+func QueryEndpoints(
+	ctx context.Context,
+	store *postgresql.Store,
+	mq *lanternmq.MessageQueue,
+	channelID *lanternmq.ChannelID,
+	qName string,
+	urls []string,
+	timeout time.Duration) (*Response, error) {
+
+	if len(urls) == 0 {
+		return nil, errors.New("no endpoints to query")
+	}
+
+	var cycle endpointmanager.QueryCycle
+	err := store.AddQueryCycle(ctx, &cycle)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to start query cycle")
+	}
+
+	var msgs []string
+	for _, url := range urls {
+		msg, err := se.QueryMessage(url, cycle.ID, se.HighPriority)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	err = accessqueue.SendBatchToQueue(ctx, msgs, mq, channelID, qName, se.QueuePriority(se.HighPriority))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to send %d endpoints to queue %s", len(msgs), qName)
+	}
+	cycle.EndpointCount = len(msgs)
+	log.Infof("Sent %d endpoints to be queried in query cycle %d", cycle.EndpointCount, cycle.ID)
+
+	cycle.Status = endpointmanager.QueryCycleRunning
+	err = store.UpdateQueryCycle(ctx, &cycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to update query cycle %d", cycle.ID)
+	}
+
+	cycle.Status, err = waitForQueryCycle(ctx, store, cycle.ID, timeout)
+	if err != nil {
+		abandonQueryCycle(store, &cycle)
+		return nil, err
+	}
+	cycle.CompletedAt = time.Now()
+	err = store.UpdateQueryCycle(ctx, &cycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to update query cycle %d", cycle.ID)
+	}
+
+	results, err := GetResults(ctx, store, urls)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		CycleID: cycle.ID,
+		Status:  cycle.Status,
+		Results: results,
+	}, nil
+}
+
+// waitForQueryCycle waits until every message for the query cycle with the given ID has been received, and
+// returns the status the cycle should be given
+func waitForQueryCycle(ctx context.Context, store *postgresql.Store, cycleID int, timeout time.Duration) (string, error) {
+	deadline := time.After(timeout)
+	for {
+		cycle, err := store.GetQueryCycle(ctx, cycleID)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to get query cycle %d", cycleID)
+		}
+		if cycle.Done() {
+			return endpointmanager.QueryCycleCompleted, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrapf(ctx.Err(), "stopped waiting for query cycle %d", cycleID)
+		case <-deadline:
+			log.Warnf("Query cycle %d timed out after %s", cycleID, timeout)
+			return endpointmanager.QueryCycleTimedOut, nil
+		case <-time.After(checkInterval):
+			// check again
+		}
+	}
+}
+
+// abandonQueryCycle marks the given query cycle as timed out when it is no longer being waited for, so that it is
+// not left running. The given cycle's context may already be cancelled, so the update uses a new context.
+func abandonQueryCycle(store *postgresql.Store, cycle *endpointmanager.QueryCycle) {
+	ctx, cancel := context.WithTimeout(context.Background(), abandonTimeout)
+	defer cancel()
+
+	cycle.Status = endpointmanager.QueryCycleTimedOut
+	cycle.CompletedAt = time.Now()
+	err := store.UpdateQueryCycle(ctx, cycle)
+	if err != nil {
+		log.Warnf("unable to mark query cycle %d as timed out: %s", cycle.ID, err)
+	}
+}
+
+// GetResults returns the information that is stored for each requested FHIR version of the endpoints with the
+// given URLs
+func GetResults(ctx context.Context, store *postgresql.Store, urls []string) ([]*Result, error) {
+	var results []*Result
+	for _, url := range urls {
+		infos, err := store.GetFHIREndpointInfosUsingURL(ctx, url)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get the stored information for %s", url)
+		}
+		for _, info := range infos {
+			result := &Result{
+				URL:                   info.URL,
+				RequestedFhirVersion:  info.RequestedFhirVersion,
+				CapabilityFhirVersion: info.CapabilityFhirVersion,
+				TLSVersion:            info.TLSVersion,
+				MIMETypes:             info.MIMETypes,
+				UpdatedAt:             info.UpdatedAt,
+				Metadata:              info.Metadata,
+			}
+			if info.ValidationID != 0 {
+				result.Validation, err = store.GetFHIREndpointInfoValidation(ctx, info)
+				if err != nil {
+					return nil, errors.Wrapf(err, "unable to get the validation results for %s", url)
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Handler returns an HTTP handler that queries the endpoints given by the POST request's 'url' parameters and the
+// endpoints from the list source given by its 'list_source' parameter, and responds with the resulting Response
+// as JSON. The 'url' parameter can be repeated or given as a comma separated list. Only endpoints that are in the
+// fhir_endpoints table can be queried.
+func Handler(
+	store *postgresql.Store,
+	mq *lanternmq.MessageQueue,
+	channelID *lanternmq.ChannelID,
+	qName string,
+	timeout time.Duration) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "on demand queries must be POST requests", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		urls, listSource := requestedEndpoints(r)
+		if len(urls) == 0 && listSource == "" {
+			http.Error(w, "a 'url' or 'list_source' parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		if len(urls) > 0 {
+			unknown, err := UnknownURLs(ctx, store, urls)
+			if err != nil {
+				log.Warn(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(unknown) > 0 {
+				http.Error(w, fmt.Sprintf("unknown endpoints: %s", strings.Join(unknown, ", ")), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if listSource != "" {
+			sourceURLs, err := ListSourceURLs(ctx, store, listSource)
+			if err != nil {
+				log.Warn(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(sourceURLs) == 0 {
+				http.Error(w, fmt.Sprintf("no endpoints found for list source %s", listSource), http.StatusNotFound)
+				return
+			}
+			urls = MergeURLs(urls, sourceURLs)
+		}
+
+		response, err := QueryEndpoints(ctx, store, mq, channelID, qName, urls, timeout)
+		if err != nil {
+			log.Warn(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+}
+
+// requestedEndpoints returns the URLs and the list source requested by the given HTTP request, whose form must
+// already be parsed
+func requestedEndpoints(r *http.Request) ([]string, string) {
+	var urls []string
+	for _, param := range r.Form["url"] {
+		for _, url := range strings.Split(param, ",") {
+			url = strings.TrimSpace(url)
+			if url != "" {
+				urls = append(urls, url)
+			}
+		}
+	}
+	return urls, strings.TrimSpace(r.Form.Get("list_source"))
+}
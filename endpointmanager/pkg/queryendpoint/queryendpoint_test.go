@@ -0,0 +1,62 @@
+package queryendpoint
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_requestedEndpoints(t *testing.T) {
+	body := strings.NewReader("url=http://example.com/fhir,%20http://other.example.com/fhir&url=http://third.example.com/fhir")
+	req := httptest.NewRequest("POST", "/query", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := req.ParseForm()
+	th.Assert(t, err == nil, err)
+	urls, listSource := requestedEndpoints(req)
+	th.Assert(t, len(urls) == 3, fmt.Sprintf("expected 3 URLs, got %v", urls))
+	th.Assert(t, urls[1] == "http://other.example.com/fhir", fmt.Sprintf("expected the URL to be trimmed, got '%s'", urls[1]))
+	th.Assert(t, listSource == "", fmt.Sprintf("expected no list source, got %s", listSource))
+
+	req = httptest.NewRequest("POST", "/query?list_source=https://github.com/cerner/ignite-endpoints", nil)
+	err = req.ParseForm()
+	th.Assert(t, err == nil, err)
+	urls, listSource = requestedEndpoints(req)
+	th.Assert(t, len(urls) == 0, fmt.Sprintf("expected no URLs, got %v", urls))
+	th.Assert(t, listSource == "https://github.com/cerner/ignite-endpoints", fmt.Sprintf("expected the list source, got %s", listSource))
+}
+
+func Test_HandlerMissingParameters(t *testing.T) {
+	handler := Handler(nil, nil, nil, "queue", time.Minute)
+
+	req := httptest.NewRequest("POST", "/query", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	th.Assert(t, w.Code == http.StatusBadRequest, fmt.Sprintf("expected a bad request response, got %d", w.Code))
+}
+
+func Test_HandlerRequiresPost(t *testing.T) {
+	handler := Handler(nil, nil, nil, "queue", time.Minute)
+
+	req := httptest.NewRequest("GET", "/query?url=http://example.com/fhir", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	th.Assert(t, w.Code == http.StatusMethodNotAllowed, fmt.Sprintf("expected a method not allowed response, got %d", w.Code))
+	th.Assert(t, w.Header().Get("Allow") == "POST", fmt.Sprintf("expected POST to be allowed, got %s", w.Header().Get("Allow")))
+}
+
+func Test_MergeURLs(t *testing.T) {
+	urls := MergeURLs([]string{"http://example.com/fhir", "http://other.example.com/fhir"}, []string{"http://other.example.com/fhir", "http://third.example.com/fhir"})
+	expected := []string{"http://example.com/fhir", "http://other.example.com/fhir", "http://third.example.com/fhir"}
+	th.Assert(t, len(urls) == len(expected), fmt.Sprintf("expected %v, got %v", expected, urls))
+	for i := range expected {
+		th.Assert(t, urls[i] == expected[i], fmt.Sprintf("expected %v, got %v", expected, urls))
+	}
+
+	urls = MergeURLs(nil, []string{"http://example.com/fhir"})
+	th.Assert(t, len(urls) == 1 && urls[0] == "http://example.com/fhir", fmt.Sprintf("expected only the list source URL, got %v", urls))
+}
@@ -0,0 +1,97 @@
+package sendendpoints
+
+import (
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/spf13/viper"
+)
+
+// The names of the tiers an endpoint can be scheduled with
+const (
+	NewTier     = "new"
+	ChangedTier = "changed"
+	DefaultTier = "default"
+	DownTier    = "down"
+)
+
+// Schedule holds the query interval of each tier and the thresholds used to place endpoints in a tier.
+// Endpoints added within RecentThreshold are in the new tier and endpoints whose capability statement changed
+// within RecentThreshold are in the changed tier. Endpoints that have not had a successful response for
+// DownThreshold, or that never had one and were added more than DownThreshold ago, are in the down tier. All
+// other endpoints are in the default tier. CheckInterval is how long to wait between checks for endpoints
+// that are due to be queried, and CycleTimeout is how long to wait for a query cycle to complete.
+type Schedule struct {
+	Intervals       map[string]time.Duration
+	RecentThreshold time.Duration
+	DownThreshold   time.Duration
+	CheckInterval   time.Duration
+	CycleTimeout    time.Duration
+}
+
+// ScheduleFromConfig creates the schedule from the capquery_*_intvl (in minutes) and capquery_*_threshold
+// (in days) configuration values. The default tier uses the capquery_qryintvl value.
+func ScheduleFromConfig() Schedule {
+	return Schedule{
+		Intervals: map[string]time.Duration{
+			NewTier:     time.Duration(viper.GetInt("capquery_new_intvl")) * time.Minute,
+			ChangedTier: time.Duration(viper.GetInt("capquery_changed_intvl")) * time.Minute,
+			DefaultTier: time.Duration(viper.GetInt("capquery_qryintvl")) * time.Minute,
+			DownTier:    time.Duration(viper.GetInt("capquery_down_intvl")) * time.Minute,
+		},
+		RecentThreshold: time.Duration(viper.GetInt("capquery_recent_threshold")) * 24 * time.Hour,
+		DownThreshold:   time.Duration(viper.GetInt("capquery_down_threshold")) * 24 * time.Hour,
+		CheckInterval:   time.Duration(viper.GetInt("capquery_check_intvl")) * time.Minute,
+		CycleTimeout:    time.Duration(viper.GetInt("capquery_cycle_timeout")) * time.Minute,
+	}
+}
+
+// Tier returns the tier the given endpoint should be scheduled with at the given time
+func (s Schedule) Tier(info *endpointmanager.FHIREndpointScheduleInfo, now time.Time) string {
+	if now.Sub(info.CreatedAt) < s.RecentThreshold {
+		return NewTier
+	}
+	if !info.LastChangedAt.IsZero() && now.Sub(info.LastChangedAt) < s.RecentThreshold {
+		return ChangedTier
+	}
+	lastUp := info.LastSuccessfulAt
+	if lastUp.IsZero() {
+		lastUp = info.CreatedAt
+	}
+	if now.Sub(lastUp) >= s.DownThreshold {
+		return DownTier
+	}
+	return DefaultTier
+}
+
+// Due returns whether the given endpoint should be queried at the given time. Endpoints that have not been
+// scheduled yet are always due.
+func (s Schedule) Due(info *endpointmanager.FHIREndpointScheduleInfo, now time.Time) bool {
+	return info.Schedule == nil || !info.Schedule.NextQueryAt.After(now)
+}
+
+// Next returns the schedule for the given endpoint after it is queried at the given time
+func (s Schedule) Next(info *endpointmanager.FHIREndpointScheduleInfo, now time.Time) *endpointmanager.FHIREndpointSchedule {
+	tier := s.Tier(info, now)
+	return &endpointmanager.FHIREndpointSchedule{
+		URL:           info.URL,
+		Tier:          tier,
+		NextQueryAt:   now.Add(s.Intervals[tier]),
+		LastQueriedAt: now,
+	}
+}
+
+// QueriesPerInterval returns the number of queries that the given endpoints are expected to need within the
+// default tier's interval at the given time
+func (s Schedule) QueriesPerInterval(infos []*endpointmanager.FHIREndpointScheduleInfo, now time.Time) float64 {
+	var queries float64
+	defaultInterval := s.Intervals[DefaultTier]
+	for _, info := range infos {
+		interval := s.Intervals[s.Tier(info, now)]
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		queries += float64(defaultInterval) / float64(interval)
+	}
+	return queries
+}
@@ -0,0 +1,84 @@
+package sendendpoints
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+var testSchedule = Schedule{
+	Intervals: map[string]time.Duration{
+		NewTier:     6 * time.Hour,
+		ChangedTier: 6 * time.Hour,
+		DefaultTier: 24 * time.Hour,
+		DownTier:    7 * 24 * time.Hour,
+	},
+	RecentThreshold: 7 * 24 * time.Hour,
+	DownThreshold:   14 * 24 * time.Hour,
+	CheckInterval:   5 * time.Minute,
+}
+
+func Test_Tier(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	cases := []struct {
+		name     string
+		info     endpointmanager.FHIREndpointScheduleInfo
+		expected string
+	}{
+		{"added recently", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-2 * day)}, NewTier},
+		{"changed recently", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-100 * day), LastSuccessfulAt: now.Add(-1 * day), LastChangedAt: now.Add(-3 * day)}, ChangedTier},
+		{"changed a while ago", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-100 * day), LastSuccessfulAt: now.Add(-1 * day), LastChangedAt: now.Add(-30 * day)}, DefaultTier},
+		{"down for weeks", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-100 * day), LastSuccessfulAt: now.Add(-20 * day)}, DownTier},
+		{"never up", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-20 * day)}, DownTier},
+		{"not up yet", endpointmanager.FHIREndpointScheduleInfo{CreatedAt: now.Add(-10 * day)}, DefaultTier},
+	}
+
+	for _, c := range cases {
+		tier := testSchedule.Tier(&c.info, now)
+		th.Assert(t, tier == c.expected, fmt.Sprintf("%s: expected tier %s, got %s", c.name, c.expected, tier))
+	}
+}
+
+func Test_DueAndNext(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	info := &endpointmanager.FHIREndpointScheduleInfo{
+		URL:              "http://example.com/fhir",
+		CreatedAt:        now.Add(-100 * 24 * time.Hour),
+		LastSuccessfulAt: now.Add(-1 * time.Hour),
+	}
+
+	// endpoints that have not been scheduled are due
+	th.Assert(t, testSchedule.Due(info, now), "expected an endpoint without a schedule to be due")
+
+	next := testSchedule.Next(info, now)
+	th.Assert(t, next.URL == info.URL, fmt.Sprintf("expected schedule for %s, got %s", info.URL, next.URL))
+	th.Assert(t, next.Tier == DefaultTier, fmt.Sprintf("expected default tier, got %s", next.Tier))
+	th.Assert(t, next.NextQueryAt.Equal(now.Add(24*time.Hour)), fmt.Sprintf("expected next query a day later, got %s", next.NextQueryAt))
+	th.Assert(t, next.LastQueriedAt.Equal(now), fmt.Sprintf("expected last queried at %s, got %s", now, next.LastQueriedAt))
+
+	info.Schedule = next
+	th.Assert(t, !testSchedule.Due(info, now.Add(time.Hour)), "expected the endpoint not to be due before its next query time")
+	th.Assert(t, testSchedule.Due(info, now.Add(24*time.Hour)), "expected the endpoint to be due at its next query time")
+}
+
+func Test_QueriesPerInterval(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	infos := []*endpointmanager.FHIREndpointScheduleInfo{
+		// new endpoints are queried 4 times a day
+		{CreatedAt: now.Add(-1 * day)},
+		// default endpoints are queried once a day
+		{CreatedAt: now.Add(-100 * day), LastSuccessfulAt: now.Add(-1 * day)},
+		// down endpoints are queried once a week
+		{CreatedAt: now.Add(-100 * day), LastSuccessfulAt: now.Add(-30 * day)},
+	}
+
+	queries := testSchedule.QueriesPerInterval(infos, now)
+	expected := 4 + 1 + 1.0/7
+	th.Assert(t, queries == expected, fmt.Sprintf("expected %f queries, got %f", expected, queries))
+}
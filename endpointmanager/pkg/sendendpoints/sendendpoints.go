@@ -2,25 +2,32 @@ package sendendpoints
 
 import (
 	"context"
+	"encoding/json"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/historypruning"
 	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/jsonexport"
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-// GetEnptsAndSend gets the current list of endpoints from the database and sends each one to the given queue
-// it continues to repeat this action every time the given interval period has passed
+// GetEnptsAndSend gets the endpoints from the database that are due to be queried according to the given schedule
+// and sends each one to the given queue as part of a query cycle, storing when each endpoint is next due. It checks
+// for due endpoints every time the schedule's check interval has passed, while the cycles that have been sent are
+// waited on in the background. History pruning and the JSON export are run each time a cycle completes or times
+// out. It returns once the context ends.
 func GetEnptsAndSend(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	qName string,
-	qInterval int,
+	schedule Schedule,
 	store *postgresql.Store,
 	mq *lanternmq.MessageQueue,
 	channelID *lanternmq.ChannelID,
@@ -28,47 +35,267 @@ func GetEnptsAndSend(
 
 	defer wg.Done()
 
+	cyclesDone := make(chan struct{}, 1)
+	go runMaintenance(ctx, store, cyclesDone, errs)
+
 	for {
-		listOfEndpoints, err := store.GetAllDistinctFHIREndpoints(ctx)
+		infos, err := store.GetFHIREndpointScheduleInfos(ctx)
 		if err != nil {
 			errs <- err
 		}
 
+		now := time.Now()
+		var dueEndpoints []*endpointmanager.FHIREndpointScheduleInfo
+		for _, info := range infos {
+			if schedule.Due(info, now) {
+				dueEndpoints = append(dueEndpoints, info)
+			}
+		}
+
 		// Shuffle Endpoints So that We Are Not Querying As Rapidly
-		rand.Shuffle(len(listOfEndpoints), func(i, j int) {
-			listOfEndpoints[i], listOfEndpoints[j] = listOfEndpoints[j], listOfEndpoints[i]
+		rand.Shuffle(len(dueEndpoints), func(i, j int) {
+			dueEndpoints[i], dueEndpoints[j] = dueEndpoints[j], dueEndpoints[i]
 		})
 
-		for i, endpt := range listOfEndpoints {
-			if i%10 == 0 {
-				log.Infof("Processed %d/%d messages", i, len(listOfEndpoints))
-			}
-			// Add a short time buffer as we enqueue items
-			time.Sleep(time.Duration(500 * time.Millisecond))
-			err = accessqueue.SendToQueue(ctx, endpt.URL, mq, channelID, qName)
-			if err != nil {
-				errs <- err
+		log.Infof("%d/%d endpoints are due to be queried", len(dueEndpoints), len(infos))
+		if len(dueEndpoints) != 0 {
+			cycle := sendQueryCycle(ctx, dueEndpoints, qName, schedule, store, mq, channelID, errs)
+			if cycle != nil {
+				// the endpoints in the cycle are not due again until their next query time, so the cycle can be
+				// waited on while newly due endpoints are sent
+				go func() {
+					waitForQueryCycle(ctx, cycle, schedule.CycleTimeout, store, errs)
+					select {
+					case cyclesDone <- struct{}{}:
+					default:
+						// maintenance is already due to run
+					}
+				}()
 			}
 		}
 
-		if len(listOfEndpoints) != 0 {
-			err = accessqueue.SendToQueue(ctx, "FINISHED", mq, channelID, qName)
-			if err != nil {
-				errs <- err
-			}
+		log.Infof("Waiting %s to check for due endpoints", schedule.CheckInterval)
+		if !wait(ctx, schedule.CheckInterval) {
+			log.Info("Stopped checking for due endpoints")
+			return
+		}
+	}
+}
+
+// wait waits for the given duration to pass. It returns false if the context ended first.
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runMaintenance runs history pruning and the JSON export each time 'cyclesDone' receives, until the context
+// ends. Cycles that finish while the maintenance is running are covered by a single run afterwards.
+func runMaintenance(ctx context.Context, store *postgresql.Store, cyclesDone <-chan struct{}, errs chan<- error) {
+	for {
+		select {
+		case <-cyclesDone:
+		case <-ctx.Done():
+			return
 		}
-		log.Infof("Waiting %d seconds to start history pruning and json export", len(listOfEndpoints))
-		// Wait half a second for every endpoint to ensure querier is done before starting history pruning and json export
-		time.Sleep(time.Duration(time.Duration(len(listOfEndpoints)/2) * time.Second))
+
 		log.Info("Starting history pruning")
 		historypruning.PruneInfoHistory(ctx, store, true)
 		log.Info("Starting json export")
-		err = jsonexport.CreateJSONExport(ctx, store, "/etc/lantern/exportfolder/fhir_endpoints_fields.json", "30days")
+		err := jsonexport.CreateJSONExport(ctx, store, "/etc/lantern/exportfolder/fhir_endpoints_fields.json", "30days")
 		if err != nil {
 			errs <- err
 		}
+	}
+}
+
+// The priorities endpoint query messages are sent with. The priority is recorded in the message so that the
+// capability statement queries sent for the endpoint after its versions are queried have the same priority.
+const (
+	// RoutinePriority is the priority given to endpoint query messages sent on the endpoint's schedule
+	RoutinePriority = ""
+	// NewEndpointPriority is the priority given to endpoint query messages for endpoints that have not been
+	// scheduled yet, such as the endpoints from a newly added list source
+	NewEndpointPriority = "new"
+	// HighPriority is the priority given to endpoint query messages that were requested on demand rather than
+	// sent on the endpoint's schedule
+	HighPriority = "high"
+)
+
+// MaxQueuePriority is the maximum priority of the queues endpoint query messages are sent to, which are declared
+// as priority queues in lanternmq/definitions.json
+const MaxQueuePriority lanternmq.Priority = 2
+
+// QueuePriority returns the priority that endpoint query messages with the given priority are published to the
+// queue with, so that on demand queries are delivered first, then queries of new endpoints, then routine queries.
+func QueuePriority(priority string) lanternmq.Priority {
+	switch priority {
+	case HighPriority:
+		return MaxQueuePriority
+	case NewEndpointPriority:
+		return 1
+	}
+	return 0
+}
+
+// QueryMessage creates the message sent to the capabilityquerier to query the endpoint with the given URL as part
+// of the query cycle with the given ID. RoutinePriority is used for scheduled queries.
+func QueryMessage(url string, cycleID int, priority string) (string, error) {
+	msg := map[string]string{
+		"url":     url,
+		"cycleID": strconv.Itoa(cycleID),
+	}
+	if priority != "" {
+		msg["priority"] = priority
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create query message for %s", url)
+	}
+	return string(msgBytes), nil
+}
+
+// sendBatchSize is how many endpoint query messages are sent to the queue together. If publishes are confirmed,
+// the messages in a batch are confirmed together.
+var sendBatchSize = 10
+
+// cycleCheckInterval is how long to wait between checks of whether a query cycle has completed
+var cycleCheckInterval = 30 * time.Second
+
+// sendQueryCycle starts a new query cycle and sends the given endpoints to the given queue as part of it. The
+// returned cycle is nil if the cycle could not be started or the context ended before every endpoint was sent.
+func sendQueryCycle(
+	ctx context.Context,
+	endpoints []*endpointmanager.FHIREndpointScheduleInfo,
+	qName string,
+	schedule Schedule,
+	store *postgresql.Store,
+	mq *lanternmq.MessageQueue,
+	channelID *lanternmq.ChannelID,
+	errs chan<- error) *endpointmanager.QueryCycle {
+
+	var cycle endpointmanager.QueryCycle
+	err := store.AddQueryCycle(ctx, &cycle)
+	if err != nil {
+		errs <- errors.Wrap(err, "unable to start query cycle")
+		return nil
+	}
+
+	var batch []*endpointmanager.FHIREndpointScheduleInfo
+	var msgs []string
+	batchPriority := RoutinePriority
+	for i, info := range endpoints {
+		if i%sendBatchSize == 0 {
+			log.Infof("Processed %d/%d messages for query cycle %d", i, len(endpoints), cycle.ID)
+		}
+		priority := RoutinePriority
+		if info.Schedule == nil {
+			priority = NewEndpointPriority
+		}
+		msg, err := QueryMessage(info.URL, cycle.ID, priority)
+		if err != nil {
+			errs <- err
+			continue
+		}
+		// a batch is published with one priority
+		if priority != batchPriority {
+			cycle.EndpointCount += sendBatch(ctx, batch, msgs, batchPriority, qName, schedule, store, mq, channelID, errs)
+			batch, msgs = nil, nil
+			batchPriority = priority
+		}
+		// Add a short time buffer as we enqueue items
+		if !wait(ctx, 500*time.Millisecond) {
+			log.Infof("Stopped sending query cycle %d after %d/%d endpoints", cycle.ID, cycle.EndpointCount, len(endpoints))
+			return nil
+		}
+		batch = append(batch, info)
+		msgs = append(msgs, msg)
+		if len(msgs) == sendBatchSize {
+			cycle.EndpointCount += sendBatch(ctx, batch, msgs, batchPriority, qName, schedule, store, mq, channelID, errs)
+			batch, msgs = nil, nil
+		}
+	}
+	cycle.EndpointCount += sendBatch(ctx, batch, msgs, batchPriority, qName, schedule, store, mq, channelID, errs)
+
+	cycle.Status = endpointmanager.QueryCycleRunning
+	err = store.UpdateQueryCycle(ctx, &cycle)
+	if err != nil {
+		errs <- errors.Wrapf(err, "unable to update query cycle %d", cycle.ID)
+		return nil
+	}
+	return &cycle
+}
+
+// sendBatch sends the query messages for a batch of endpoints to the given queue together with the given
+// priority, and stores when each endpoint is next due if the batch was sent. It returns how many endpoints were
+// sent.
+func sendBatch(
+	ctx context.Context,
+	batch []*endpointmanager.FHIREndpointScheduleInfo,
+	msgs []string,
+	priority string,
+	qName string,
+	schedule Schedule,
+	store *postgresql.Store,
+	mq *lanternmq.MessageQueue,
+	channelID *lanternmq.ChannelID,
+	errs chan<- error) int {
+
+	if len(msgs) == 0 {
+		return 0
+	}
+
+	err := accessqueue.SendBatchToQueue(ctx, msgs, mq, channelID, qName, QueuePriority(priority))
+	if err != nil {
+		errs <- errors.Wrapf(err, "unable to send %d endpoints to queue %s", len(msgs), qName)
+		return 0
+	}
+	for _, info := range batch {
+		err = store.UpdateFHIREndpointSchedule(ctx, schedule.Next(info, time.Now()))
+		if err != nil {
+			errs <- err
+		}
+	}
+	return len(batch)
+}
+
+// waitForQueryCycle waits until the capability receiver has received all of the messages for the given query cycle,
+// or until the timeout has passed, and stores the cycle's final status
+func waitForQueryCycle(
+	ctx context.Context,
+	cycle *endpointmanager.QueryCycle,
+	timeout time.Duration,
+	store *postgresql.Store,
+	errs chan<- error) {
+
+	deadline := time.Now().Add(timeout)
+	for {
+		progress, err := store.GetQueryCycle(ctx, cycle.ID)
+		if err != nil {
+			errs <- errors.Wrapf(err, "unable to get query cycle %d", cycle.ID)
+		} else if progress.Done() {
+			cycle.Status = endpointmanager.QueryCycleCompleted
+			log.Infof("Query cycle %d completed: %d endpoints, %d capability statements stored, %d failed", cycle.ID, progress.EndpointCount, progress.Stored, progress.Failed)
+			break
+		}
+		if time.Now().After(deadline) {
+			cycle.Status = endpointmanager.QueryCycleTimedOut
+			log.Warnf("Query cycle %d timed out after %s", cycle.ID, timeout)
+			break
+		}
+		if !wait(ctx, cycleCheckInterval) {
+			return
+		}
+	}
 
-		log.Infof("Waiting %d minutes", qInterval)
-		time.Sleep(time.Duration(qInterval) * time.Minute)
+	cycle.CompletedAt = time.Now()
+	err := store.UpdateQueryCycle(ctx, cycle)
+	if err != nil {
+		errs <- errors.Wrapf(err, "unable to update query cycle %d", cycle.ID)
 	}
 }
@@ -0,0 +1,48 @@
+package sendendpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_QueryMessage(t *testing.T) {
+	var msgJSON map[string]string
+
+	msg, err := QueryMessage("http://example.com/fhir", 3, "")
+	th.Assert(t, err == nil, err)
+	err = json.Unmarshal([]byte(msg), &msgJSON)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, msgJSON["url"] == "http://example.com/fhir", fmt.Sprintf("expected the endpoint URL, got %s", msgJSON["url"]))
+	th.Assert(t, msgJSON["cycleID"] == "3", fmt.Sprintf("expected query cycle 3, got %s", msgJSON["cycleID"]))
+	_, ok := msgJSON["priority"]
+	th.Assert(t, !ok, "expected scheduled query messages to not have a priority")
+
+	msgJSON = nil
+	msg, err = QueryMessage("http://example.com/fhir", 4, HighPriority)
+	th.Assert(t, err == nil, err)
+	err = json.Unmarshal([]byte(msg), &msgJSON)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, msgJSON["priority"] == HighPriority, fmt.Sprintf("expected the high priority, got %s", msgJSON["priority"]))
+}
+
+func Test_QueuePriority(t *testing.T) {
+	th.Assert(t, QueuePriority(HighPriority) == MaxQueuePriority, "expected on demand queries to have the highest priority")
+	th.Assert(t, QueuePriority(NewEndpointPriority) == 1, fmt.Sprintf("expected new endpoint queries to have priority 1, got %d", QueuePriority(NewEndpointPriority)))
+	th.Assert(t, QueuePriority(RoutinePriority) == 0, fmt.Sprintf("expected scheduled queries to have priority 0, got %d", QueuePriority(RoutinePriority)))
+	th.Assert(t, QueuePriority("unknown") == 0, "expected unknown priorities to be treated as routine")
+}
+
+func Test_wait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	th.Assert(t, wait(ctx, time.Millisecond), "expected the wait to finish while the context is active")
+
+	cancel()
+	start := time.Now()
+	th.Assert(t, !wait(ctx, time.Hour), "expected the wait to stop when the context ended")
+	th.Assert(t, time.Since(start) < time.Second, "expected the wait to stop right away when the context ended")
+}
@@ -18,8 +18,8 @@ import (
 	aq "github.com/onc-healthit/lantern-back-end/lanternmq/pkg/accessqueue"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 	"github.com/spf13/viper"
-	"github.com/streadway/amqp"
 
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
 )
@@ -27,8 +27,8 @@ import (
 var store *postgresql.Store
 var mq *lanternmq.MessageQueue
 var chID *lanternmq.ChannelID
-var conn *amqp.Connection
-var channel *amqp.Channel
+var conn lanternmq.MessageQueue
+var channel lanternmq.ChannelID
 
 var endpts []*endpointmanager.FHIREndpoint = []*endpointmanager.FHIREndpoint{
 	&endpointmanager.FHIREndpoint{
@@ -78,9 +78,10 @@ func Test_GetEnptsAndSend(t *testing.T) {
 
 	queueName := viper.GetString("qname")
 	queueIsEmpty(t, queueName)
-	defer checkCleanQueue(t, queueName, channel)
+	defer checkCleanQueue(t, queueName)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	var err error
 
 	// populate fhir endpoints
@@ -92,25 +93,49 @@ func Test_GetEnptsAndSend(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(1)
 	errs := make(chan error)
-	go GetEnptsAndSend(ctx, &wg, queueName, 1, store, mq, chID, errs)
+	schedule := ScheduleFromConfig()
+	go GetEnptsAndSend(ctx, &wg, queueName, schedule, store, mq, chID, errs)
 
 	// need to pause to ensure all messages are on the queue before we count them
 	time.Sleep(10 * time.Second)
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
-	// Expect 4 messages: 3 endpoints and the "FINISHED" message
-	th.Assert(t, count == 4, fmt.Sprintf("expected there to be 4 messages in the queue, instead got %d", count))
-	wg.Done()
+	th.Assert(t, count == 3, fmt.Sprintf("expected there to be 3 messages in the queue, instead got %d", count))
+
+	// the endpoints are sent as part of a running query cycle
+	var cycleID int
+	err = store.DB.QueryRowContext(ctx, "SELECT MAX(id) FROM query_cycles").Scan(&cycleID)
+	th.Assert(t, err == nil, err)
+	cycle, err := store.GetQueryCycle(ctx, cycleID)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, cycle.Status == endpointmanager.QueryCycleRunning, fmt.Sprintf("expected the query cycle to be running, got %s", cycle.Status))
+	th.Assert(t, cycle.EndpointCount == 3, fmt.Sprintf("expected the query cycle to have 3 endpoints, got %d", cycle.EndpointCount))
+
+	// each endpoint that was sent is scheduled in the new tier since it was just added
+	for _, endpt := range endpts {
+		endptSchedule, err := store.GetFHIREndpointSchedule(ctx, endpt.URL)
+		th.Assert(t, err == nil, err)
+		th.Assert(t, endptSchedule.Tier == NewTier, fmt.Sprintf("expected %s to be in the new tier, got %s", endpt.URL, endptSchedule.Tier))
+		th.Assert(t, endptSchedule.NextQueryAt.After(time.Now()), fmt.Sprintf("expected %s to be scheduled in the future, got %s", endpt.URL, endptSchedule.NextQueryAt))
+	}
+
+	// GetEnptsAndSend returns once the context ends
+	go func() {
+		for range errs {
+		}
+	}()
+	cancel()
+	wg.Wait()
 }
 
 func queueIsEmpty(t *testing.T, queueName string) {
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 0, "should be no messages in queue.")
 }
 
-func checkCleanQueue(t *testing.T, queueName string, channel *amqp.Channel) {
-	err := aq.CleanQueue(queueName, channel)
+func checkCleanQueue(t *testing.T, queueName string) {
+	err := aq.CleanQueue(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 }
 
@@ -142,13 +167,13 @@ func setup() error {
 	}
 
 	// setup specific queue info so we can test what's in the queue
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
-	conn, err = amqp.Dial(s)
+	conn = &rabbitmq.MessageQueue{}
+	err = conn.Connect(qUser, qPassword, qHost, qPort)
 	if err != nil {
 		return err
 	}
 
-	channel, err = conn.Channel()
+	channel, err = conn.CreateChannel()
 	if err != nil {
 		return err
 	}
@@ -158,7 +183,6 @@ func setup() error {
 
 func teardown() {
 	(*mq).Close()
-	channel.Close()
 	conn.Close()
 	store.Close()
 }
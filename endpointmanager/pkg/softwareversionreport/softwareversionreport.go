@@ -0,0 +1,75 @@
+package softwareversionreport
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager/postgresql"
+	log "github.com/sirupsen/logrus"
+)
+
+// reportHeader is the header row of the software version report
+var reportHeader = []string{"vendor", "software_name", "software_version", "period", "endpoint_count", "percent_of_vendor_endpoints"}
+
+// CreateSoftwareVersionReport writes a CSV file showing the number of each vendor's endpoints that advertised each
+// software version during each period of the given interval ("day", "week" or "month") between the given start and
+// end times, along with the percentage of the vendor's endpoints in that period that advertised the version.
+func CreateSoftwareVersionReport(ctx context.Context, store *postgresql.Store, fileToWriteTo string, interval string, start time.Time, end time.Time) error {
+	adoption, err := store.GetSoftwareVersionAdoption(ctx, interval, start, end)
+	if err != nil {
+		return fmt.Errorf("getting software version adoption failed, %s", err)
+	}
+
+	file, err := os.Create(fileToWriteTo)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	err = writer.WriteAll(formatReport(adoption))
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Wrote software version adoption of %d periods to %s", len(adoption), fileToWriteTo)
+	return nil
+}
+
+// formatReport returns the rows of the software version report for the given adoption entries, including the
+// header row. The percentage of each entry is its endpoint count out of the total endpoint count of all the
+// entries with the same vendor and period.
+func formatReport(adoption []*endpointmanager.SoftwareVersionAdoption) [][]string {
+	type vendorPeriod struct {
+		vendor string
+		period time.Time
+	}
+
+	totals := make(map[vendorPeriod]int)
+	for _, entry := range adoption {
+		totals[vendorPeriod{entry.VendorName, entry.Period.UTC()}] += entry.EndpointCount
+	}
+
+	rows := [][]string{reportHeader}
+	for _, entry := range adoption {
+		total := totals[vendorPeriod{entry.VendorName, entry.Period.UTC()}]
+		percent := 0.0
+		if total > 0 {
+			percent = float64(entry.EndpointCount) * 100 / float64(total)
+		}
+		rows = append(rows, []string{
+			entry.VendorName,
+			entry.SoftwareName,
+			entry.SoftwareVersion,
+			entry.Period.UTC().Format("2006-01-02"),
+			strconv.Itoa(entry.EndpointCount),
+			strconv.FormatFloat(percent, 'f', 2, 64),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,37 @@
+package softwareversionreport
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/endpointmanager"
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_formatReport(t *testing.T) {
+	january := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	february := time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	adoption := []*endpointmanager.SoftwareVersionAdoption{
+		{VendorName: "Cerner Corporation", SoftwareName: "Millennium", SoftwareVersion: "1.0", Period: january, EndpointCount: 3},
+		{VendorName: "Cerner Corporation", SoftwareName: "Millennium", SoftwareVersion: "2.0", Period: january, EndpointCount: 1},
+		{VendorName: "Cerner Corporation", SoftwareName: "Millennium", SoftwareVersion: "2.0", Period: february, EndpointCount: 4},
+		{VendorName: "", SoftwareName: "EHR", SoftwareVersion: "", Period: january, EndpointCount: 2},
+	}
+
+	expected := [][]string{
+		reportHeader,
+		{"Cerner Corporation", "Millennium", "1.0", "2020-01-01", "3", "75.00"},
+		{"Cerner Corporation", "Millennium", "2.0", "2020-01-01", "1", "25.00"},
+		{"Cerner Corporation", "Millennium", "2.0", "2020-02-01", "4", "100.00"},
+		{"", "EHR", "", "2020-01-01", "2", "100.00"},
+	}
+
+	rows := formatReport(adoption)
+	th.Assert(t, reflect.DeepEqual(rows, expected), fmt.Sprintf("expected rows %v, got %v", expected, rows))
+
+	rows = formatReport(nil)
+	th.Assert(t, reflect.DeepEqual(rows, [][]string{reportHeader}), fmt.Sprintf("expected only the header row, got %v", rows))
+}
@@ -3,124 +3,282 @@ package workers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Job contains all of the information for a worker to execute the job.
 // A job contains a context and a duration. The job handler is provided a new context
 // for the job based off or the job's provided context and the given duration.
+// A job sets either Handler, or ValueHandler if the job produces a value that is read from its Result.
 type Job struct {
-	Context     context.Context
-	Duration    time.Duration
-	Handler     func(context.Context, *map[string]interface{}) error
-	HandlerArgs *map[string]interface{}
+	Context      context.Context
+	Duration     time.Duration
+	Handler      func(context.Context, *map[string]interface{}) error
+	ValueHandler func(context.Context, *map[string]interface{}) (interface{}, error)
+	HandlerArgs  *map[string]interface{}
+}
+
+// Result is the outcome of a job that was added to the workers. Done is closed once the job has run, or once
+// the job will no longer be run because the workers' context ended, and Err and Value then return the job's
+// error and value.
+type Result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// Done returns a channel that is closed once the job is finished.
+func (r *Result) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the error the job returned, or the workers' context's error if the job was not run. It returns
+// nil if the job is not finished yet.
+func (r *Result) Err() error {
+	select {
+	case <-r.done:
+		return r.err
+	default:
+		return nil
+	}
+}
+
+// Wait waits for the job to finish and returns its error. If 'ctx' ends first, Wait returns the context's error.
+func (r *Result) Wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Value returns the value the job's ValueHandler returned. It returns nil if the job is not finished yet, if
+// the job was not run, or if the job has no ValueHandler.
+func (r *Result) Value() interface{} {
+	select {
+	case <-r.done:
+		return r.value
+	default:
+		return nil
+	}
+}
+
+func (r *Result) finish(value interface{}, err error) {
+	r.value = value
+	r.err = err
+	close(r.done)
+}
+
+// PanicError is the error a job's panic is turned into, so that the panic doesn't take down the worker and the
+// rest of the process with it.
+type PanicError struct {
+	// Value is the value the job's handler panicked with.
+	Value interface{}
+	// Stack is the stack trace of the worker at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.Value)
+}
+
+// queuedJob is a job waiting for a worker, along with the result the worker finishes once the job has run.
+type queuedJob struct {
+	job    *Job
+	result *Result
 }
 
 // Workers handles the provided number of workers and allows jobs to be sent to the
-// workers and distributes those jobs to the workers.
+// workers and distributes those jobs to the workers. Jobs wait for a worker in a queue
+// that holds QueueSize jobs.
 type Workers struct {
-	jobs       chan *Job
-	kill       chan bool
+	mu         sync.Mutex
+	jobs       chan *queuedJob
+	stop       chan struct{}
+	stopping   bool
+	adding     sync.WaitGroup
 	numWorkers int
 	waitGroup  *sync.WaitGroup
 	ctx        context.Context
+	busy       int32
+
+	// QueueSize is how many jobs can wait for a worker before Add blocks. If it is 0, the queue holds as many
+	// jobs as there are workers. It must be set before the workers are started.
+	QueueSize int
+	// JobDone, if set, is called after each job is run with how long the job took and the error it returned,
+	// eg. to record metrics about the jobs.
+	JobDone func(time.Duration, error)
+	// JobPanicked, if set, is called with the job and the resulting PanicError when a job panics, eg. to keep
+	// the job's arguments for later inspection.
+	JobPanicked func(*Job, *PanicError)
 }
 
 // NewWorkers initializes a QueueWorkers structure.
 func NewWorkers() *Workers {
 	w := Workers{
-		jobs:       make(chan *Job),
-		kill:       make(chan bool),
 		numWorkers: 0,
 	}
 	return &w
 }
 
 // Start creates the number of workers provided. It also runs using a context. If the context ends,
-// a signal is sent to each worker to stop working after they have completed their latest job.
+// the workers finish their current jobs and the jobs left in the queue are not run.
+// The errors returned by jobs are sent on 'errs' if it is not nil, so 'errs' must be read from.
 // Start throws an error if Workers have already been started and has not been stopped.
 func (w *Workers) Start(ctx context.Context, numWorkers int, errs chan error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	if w.numWorkers > 0 {
 		return errors.New("workers have already started")
 	}
+	if numWorkers <= 0 {
+		return fmt.Errorf("the number of workers must be positive, got %d", numWorkers)
+	}
+	queueSize := w.QueueSize
+	if queueSize <= 0 {
+		queueSize = numWorkers
+	}
+
 	var wg sync.WaitGroup
 	w.waitGroup = &wg
+	w.jobs = make(chan *queuedJob, queueSize)
+	w.stop = make(chan struct{})
+	w.stopping = false
 	w.numWorkers = numWorkers
 	w.ctx = ctx
 	for i := 0; i < w.numWorkers; i++ {
 		wg.Add(1)
-		go worker(ctx, w.jobs, w.kill, w.waitGroup, errs)
+		go w.worker(ctx, w.jobs, errs)
 	}
 	return nil
 }
 
-// Add takes a Job as an argument and sends that job to the workers to be executed when a
-// worker is available.
-func (w *Workers) Add(job *Job) error { // this checks if the context has completed before we start up the process
+// Add takes a Job as an argument and queues that job to be executed when a worker is available.
+// If the queue is full, Add waits for room in the queue. Add throws an error if 'ctx' or the workers'
+// context ends first, or if the workers are stopped. The returned Result is finished once the job has run.
+func (w *Workers) Add(ctx context.Context, job *Job) (*Result, error) {
+	w.mu.Lock()
 	if w.numWorkers == 0 {
-		return errors.New("no workers are currently running")
+		w.mu.Unlock()
+		return nil, errors.New("no workers are currently running")
 	}
-	select {
-	case <-w.ctx.Done():
-		return w.ctx.Err()
-	default:
-		// ok
+	if w.stopping {
+		w.mu.Unlock()
+		return nil, errors.New("workers are stopping")
 	}
+	w.adding.Add(1)
+	defer w.adding.Done()
+	jobs, stop, workersCtx := w.jobs, w.stop, w.ctx
+	w.mu.Unlock()
 
-	w.jobs <- job
-	return nil
-}
-
-// Stop sends a stop signal to all of the workers to stop accepting jobs and to close.
-// Stop throws an error if QueueWorkers has already been stopped and has not been restarted.
-func (w *Workers) Stop() error {
-	if w.numWorkers == 0 {
-		return errors.New("no workers are currently running")
+	// this checks if either context has completed before we queue the job, since select picks
+	// at random between the cases that are ready
+	if err := workersCtx.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	result := &Result{done: make(chan struct{})}
 	select {
-	case <-w.ctx.Done():
-		// wait for all the canceled workers to stop
-		w.waitGroup.Wait()
-		w.numWorkers = 0
-		return nil
-	default:
-		// ok
+	case jobs <- &queuedJob{job: job, result: result}:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-workersCtx.Done():
+		return nil, workersCtx.Err()
+	case <-stop:
+		return nil, errors.New("workers are stopping")
 	}
+}
 
-	for i := 0; i < w.numWorkers; i++ {
-		w.kill <- true
+// Stop stops the workers from accepting jobs, waits for them to run the jobs that are in the queue, and
+// then closes the workers. If the workers' context has ended, the jobs in the queue are not run.
+// Stop throws an error if QueueWorkers has already been stopped and has not been restarted.
+func (w *Workers) Stop() error {
+	w.mu.Lock()
+	if w.numWorkers == 0 || w.stopping {
+		w.mu.Unlock()
+		return errors.New("no workers are currently running")
 	}
+	w.stopping = true
+	close(w.stop)
+	w.mu.Unlock()
 
+	// once no more jobs are being added, closing the queue ends the workers after they drain it
+	w.adding.Wait()
+	close(w.jobs)
 	w.waitGroup.Wait()
 
+	w.mu.Lock()
 	w.numWorkers = 0
+	w.mu.Unlock()
 	return nil
 }
 
-func jobHandler(job *Job) error {
+// NumWorkers returns the number of workers that are running.
+func (w *Workers) NumWorkers() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.numWorkers
+}
+
+// NumBusy returns the number of workers that are running a job.
+func (w *Workers) NumBusy() int {
+	return int(atomic.LoadInt32(&w.busy))
+}
+
+// jobHandler runs the job, and recovers from a panic in the job's handler. The panic is returned as the job's
+// error, a *PanicError.
+func jobHandler(job *Job) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
 	jobCtx, cancel := context.WithDeadline(job.Context, time.Now().Add(job.Duration))
 	defer cancel()
 
-	err := job.Handler(jobCtx, job.HandlerArgs)
-	return err
+	if job.ValueHandler != nil {
+		return job.ValueHandler(jobCtx, job.HandlerArgs)
+	}
+	err = job.Handler(jobCtx, job.HandlerArgs)
+	return nil, err
 }
 
-func worker(ctx context.Context, jobs chan *Job, kill chan bool, wg *sync.WaitGroup, errs chan<- error) {
-	for {
-		select {
-		case job := <-jobs:
-			err := jobHandler(job)
-			if err != nil {
-				errs <- err
+func (w *Workers) worker(ctx context.Context, jobs <-chan *queuedJob, errs chan<- error) {
+	defer w.waitGroup.Done()
+	for qj := range jobs {
+		// the remaining jobs are finished without being run once the context ends
+		if ctx.Err() != nil {
+			qj.result.finish(nil, ctx.Err())
+			continue
+		}
+
+		atomic.AddInt32(&w.busy, 1)
+		start := time.Now()
+		value, err := jobHandler(qj.job)
+		atomic.AddInt32(&w.busy, -1)
+		if panicErr, ok := err.(*PanicError); ok && w.JobPanicked != nil {
+			w.JobPanicked(qj.job, panicErr)
+		}
+		if w.JobDone != nil {
+			w.JobDone(time.Since(start), err)
+		}
+		qj.result.finish(value, err)
+		if err != nil && errs != nil {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
 			}
-		case <-ctx.Done():
-			wg.Done()
-			return
-		case <-kill:
-			wg.Done()
-			return
 		}
 	}
 }
@@ -3,6 +3,8 @@ package workers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -51,7 +53,7 @@ func Test_StartAddAndStop(t *testing.T) {
 	th.Assert(t, work.ctx == ctx, "queue workers context should be the same as the passed in context")
 
 	for i := 0; i < numWorkers*2; i++ {
-		err = work.Add(&job)
+		_, err = work.Add(ctx, &job)
 		th.Assert(t, err == nil, err)
 	}
 
@@ -91,7 +93,7 @@ func Test_StartAddAndStop(t *testing.T) {
 	th.Assert(t, err == nil, err)
 
 	// expect error when adding a new job
-	err = work.Add(&job)
+	_, err = work.Add(ctx, &job)
 	th.Assert(t, errors.Cause(err) == context.Canceled, "expected to error out due to context ending")
 	// shouldn't have anything new on the queue
 	th.Assert(t, numOnQueue == numWorkers*2, fmt.Sprintf("expected %d items to be on the queue. had %d.", 2*numWorkers, numOnQueue))
@@ -102,6 +104,241 @@ func Test_StartAddAndStop(t *testing.T) {
 	th.Assert(t, work.numWorkers == 0, "after stopping, there should be no workers")
 }
 
+func Test_NumBusyAndJobDone(t *testing.T) {
+	var err error
+
+	errs := make(chan error, 3)
+	done := make(chan error, 3)
+	release := make(chan bool)
+	started := make(chan bool, 3)
+
+	job := Job{
+		Context:  context.Background(),
+		Duration: 30 * time.Second,
+		Handler: func(ctx context.Context, args *map[string]interface{}) error {
+			started <- true
+			<-release
+			return fmt.Errorf("job failed")
+		},
+	}
+
+	work := NewWorkers()
+	work.JobDone = func(duration time.Duration, err error) {
+		done <- err
+	}
+	th.Assert(t, work.NumWorkers() == 0, fmt.Sprintf("expected no workers before starting, have %d", work.NumWorkers()))
+
+	err = work.Start(context.Background(), 3, errs)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, work.NumWorkers() == 3, fmt.Sprintf("expected 3 workers, have %d", work.NumWorkers()))
+	th.Assert(t, work.NumBusy() == 0, fmt.Sprintf("expected no busy workers, have %d", work.NumBusy()))
+
+	for i := 0; i < 2; i++ {
+		_, err = work.Add(context.Background(), &job)
+		th.Assert(t, err == nil, err)
+		<-started
+	}
+	th.Assert(t, work.NumBusy() == 2, fmt.Sprintf("expected 2 busy workers, have %d", work.NumBusy()))
+
+	release <- true
+	err = <-done
+	th.Assert(t, err != nil && err.Error() == "job failed", "expected JobDone to be called with the job's error")
+	th.Assert(t, work.NumBusy() == 1, fmt.Sprintf("expected 1 busy worker, have %d", work.NumBusy()))
+
+	release <- true
+	<-done
+	th.Assert(t, work.NumBusy() == 0, fmt.Sprintf("expected no busy workers, have %d", work.NumBusy()))
+
+	err = work.Stop()
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(errs) == 2, fmt.Sprintf("expected both job errors to be sent on the error channel, got %d", len(errs)))
+}
+
+func Test_AddBlocksWhenQueueIsFull(t *testing.T) {
+	var err error
+
+	release := make(chan bool)
+	job := Job{
+		Context:  context.Background(),
+		Duration: 30 * time.Second,
+		Handler: func(ctx context.Context, args *map[string]interface{}) error {
+			<-release
+			return nil
+		},
+	}
+
+	work := NewWorkers()
+	work.QueueSize = 1
+	err = work.Start(context.Background(), 1, nil)
+	th.Assert(t, err == nil, err)
+
+	// one job for the worker and one for the queue
+	var results []*Result
+	for i := 0; i < 2; i++ {
+		result, err := work.Add(context.Background(), &job)
+		th.Assert(t, err == nil, err)
+		results = append(results, result)
+	}
+
+	// the queue is full, so the job can't be added before the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := work.Add(ctx, &job)
+	th.Assert(t, errors.Cause(err) == context.DeadlineExceeded, fmt.Sprintf("expected adding to a full queue to time out, got %v", err))
+	th.Assert(t, result == nil, "expected no result for a job that wasn't added")
+
+	// Stop waits for the queued job to run
+	close(release)
+	err = work.Stop()
+	th.Assert(t, err == nil, err)
+	for _, result := range results {
+		th.Assert(t, result.Err() == nil, result.Err())
+		select {
+		case <-result.Done():
+		default:
+			t.Fatalf("expected the job to be done after stopping")
+		}
+	}
+
+	_, err = work.Add(context.Background(), &job)
+	th.Assert(t, err != nil, "expected an error adding a job to stopped workers")
+}
+
+func Test_Results(t *testing.T) {
+	var err error
+
+	handler := func(ctx context.Context, args *map[string]interface{}) error {
+		switch (*args)["outcome"] {
+		case "error":
+			return fmt.Errorf("job failed")
+		case "panic":
+			panic("job panicked")
+		}
+		return nil
+	}
+
+	errs := make(chan error, 3)
+	panicked := make(chan *Job, 1)
+	work := NewWorkers()
+	work.JobPanicked = func(job *Job, err *PanicError) {
+		panicked <- job
+	}
+	err = work.Start(context.Background(), 2, errs)
+	th.Assert(t, err == nil, err)
+
+	results := make(map[string]*Result)
+	for _, outcome := range []string{"ok", "error", "panic"} {
+		args := map[string]interface{}{"outcome": outcome}
+		job := Job{
+			Context:     context.Background(),
+			Duration:    30 * time.Second,
+			Handler:     handler,
+			HandlerArgs: &args,
+		}
+		results[outcome], err = work.Add(context.Background(), &job)
+		th.Assert(t, err == nil, err)
+	}
+
+	err = results["ok"].Wait(context.Background())
+	th.Assert(t, err == nil, err)
+	err = results["error"].Wait(context.Background())
+	th.Assert(t, err != nil && err.Error() == "job failed", fmt.Sprintf("expected the job's error, got %v", err))
+	err = results["panic"].Wait(context.Background())
+	th.Assert(t, err != nil && err.Error() == "job panicked: job panicked", fmt.Sprintf("expected the panic as the job's error, got %v", err))
+	panicErr, ok := err.(*PanicError)
+	th.Assert(t, ok && strings.Contains(string(panicErr.Stack), "Test_Results"), "expected the job's error to hold the stack trace of the panic")
+	job := <-panicked
+	th.Assert(t, (*job.HandlerArgs)["outcome"] == "panic", "expected JobPanicked to be called with the job that panicked")
+
+	// the workers keep running after a job panics
+	th.Assert(t, work.NumWorkers() == 2, fmt.Sprintf("expected 2 workers, have %d", work.NumWorkers()))
+	err = work.Stop()
+	th.Assert(t, err == nil, err)
+	th.Assert(t, len(errs) == 2, fmt.Sprintf("expected both job errors to be sent on the error channel, got %d", len(errs)))
+}
+
+func Test_ResultValues(t *testing.T) {
+	var err error
+
+	handler := func(ctx context.Context, args *map[string]interface{}) (interface{}, error) {
+		n := (*args)["n"].(int)
+		if n < 0 {
+			return nil, fmt.Errorf("negative number %d", n)
+		}
+		return n * n, nil
+	}
+
+	work := NewWorkers()
+	err = work.Start(context.Background(), 2, nil)
+	th.Assert(t, err == nil, err)
+
+	var results []*Result
+	for _, n := range []int{1, 2, 3, -1} {
+		args := map[string]interface{}{"n": n}
+		job := Job{
+			Context:      context.Background(),
+			Duration:     30 * time.Second,
+			ValueHandler: handler,
+			HandlerArgs:  &args,
+		}
+		result, err := work.Add(context.Background(), &job)
+		th.Assert(t, err == nil, err)
+		results = append(results, result)
+	}
+
+	for i, expected := range []int{1, 4, 9} {
+		err = results[i].Wait(context.Background())
+		th.Assert(t, err == nil, err)
+		th.Assert(t, results[i].Value() == expected, fmt.Sprintf("expected job %d to have the value %d, got %v", i, expected, results[i].Value()))
+	}
+	err = results[3].Wait(context.Background())
+	th.Assert(t, err != nil, "expected an error from the job with a negative number")
+	th.Assert(t, results[3].Value() == nil, fmt.Sprintf("expected no value from the failed job, got %v", results[3].Value()))
+
+	err = work.Stop()
+	th.Assert(t, err == nil, err)
+}
+
+func Test_CanceledQueuedJobs(t *testing.T) {
+	var err error
+
+	started := make(chan bool)
+	release := make(chan bool)
+	ran := int32(0)
+	job := Job{
+		Context:  context.Background(),
+		Duration: 30 * time.Second,
+		Handler: func(ctx context.Context, args *map[string]interface{}) error {
+			atomic.AddInt32(&ran, 1)
+			started <- true
+			<-release
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	work := NewWorkers()
+	err = work.Start(ctx, 1, nil)
+	th.Assert(t, err == nil, err)
+
+	running, err := work.Add(context.Background(), &job)
+	th.Assert(t, err == nil, err)
+	<-started
+	queued, err := work.Add(context.Background(), &job)
+	th.Assert(t, err == nil, err)
+
+	// once the context ends, the running job finishes and the queued job is not run
+	cancel()
+	close(release)
+	err = queued.Wait(context.Background())
+	th.Assert(t, errors.Cause(err) == context.Canceled, fmt.Sprintf("expected the queued job to be canceled, got %v", err))
+	th.Assert(t, running.Err() == nil, running.Err())
+	th.Assert(t, atomic.LoadInt32(&ran) == 1, fmt.Sprintf("expected only one job to run, %d ran", ran))
+
+	err = work.Stop()
+	th.Assert(t, err == nil, err)
+}
+
 // testfn is an example handler function for the Job to run that just sends a test string over a queue
 func testfn(ctx context.Context, args *map[string]interface{}) error {
 	mq, ok := (*args)["mq"].(lanternmq.MessageQueue)
@@ -116,7 +353,7 @@ func testfn(ctx context.Context, args *map[string]interface{}) error {
 	if !ok {
 		return fmt.Errorf("unable to cast queueName to string from arguments")
 	}
-	err := mq.PublishToQueue(ch, queueName, "test String")
+	err := mq.PublishToQueue(ch, queueName, "test String", 0)
 	if err != nil {
 		return err
 	}
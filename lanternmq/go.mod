@@ -3,8 +3,13 @@ module github.com/onc-healthit/lantern-back-end/lanternmq
 go 1.14
 
 require (
+	github.com/lib/pq v1.3.0
 	github.com/onc-healthit/lantern-back-end/endpointmanager v0.0.0-20221019221955-c3caa901f6a4
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.1
+	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/viper v1.10.1
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 )
+
+replace github.com/onc-healthit/lantern-back-end/endpointmanager => ../endpointmanager
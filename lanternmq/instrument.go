@@ -0,0 +1,108 @@
+package lanternmq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Hooks are the functions a MessageQueue wrapped with Instrument calls as messages are published and processed,
+// eg. to record metrics. Any of them can be nil.
+type Hooks struct {
+	// Published is called after 'count' messages are published to the queue or exchange 'target', with the
+	// error publishing returned.
+	Published func(target string, count int, err error)
+	// Handled is called after a message received from the queue 'qName' is processed by a MessageHandler in
+	// ProcessMessages, with how long the handler took and the error it returned.
+	Handled func(qName string, duration time.Duration, err error)
+	// Panicked is called when a MessageHandler panics while processing 'message' from the queue 'qName' in
+	// ProcessMessages, eg. to keep the message for later inspection. The message is then rejected.
+	Panicked func(qName string, message []byte, err *PanicError)
+}
+
+// instrumented is a MessageQueue that calls its hooks around the calls to the MessageQueue it wraps.
+type instrumented struct {
+	MessageQueue
+	hooks Hooks
+}
+
+// instrumentedMessages is the Messages returned by an instrumented MessageQueue, which keeps the name of the
+// queue the messages are received from so that it can be passed to the hooks.
+type instrumentedMessages struct {
+	msgs  Messages
+	qName string
+}
+
+// Instrument returns a MessageQueue that behaves like 'mq' and calls 'hooks' as messages are published and
+// processed. The Messages it returns must be processed with the returned MessageQueue's ProcessMessages.
+func Instrument(mq MessageQueue, hooks Hooks) MessageQueue {
+	return &instrumented{MessageQueue: mq, hooks: hooks}
+}
+
+func (mq *instrumented) published(target string, count int, err error) error {
+	if mq.hooks.Published != nil {
+		mq.hooks.Published(target, count, err)
+	}
+	return err
+}
+
+// PublishToQueue calls the wrapped MessageQueue's PublishToQueue and then the Published hook.
+func (mq *instrumented) PublishToQueue(chID ChannelID, qName string, message string, priority Priority) error {
+	return mq.published(qName, 1, mq.MessageQueue.PublishToQueue(chID, qName, message, priority))
+}
+
+// PublishBatchToQueue calls the wrapped MessageQueue's PublishBatchToQueue and then the Published hook.
+func (mq *instrumented) PublishBatchToQueue(chID ChannelID, qName string, messages []string, priority Priority) error {
+	return mq.published(qName, len(messages), mq.MessageQueue.PublishBatchToQueue(chID, qName, messages, priority))
+}
+
+// PublishToExchange calls the wrapped MessageQueue's PublishToExchange and then the Published hook.
+func (mq *instrumented) PublishToExchange(chID ChannelID, name string, routingKey string, message string) error {
+	return mq.published(name, 1, mq.MessageQueue.PublishToExchange(chID, name, routingKey, message))
+}
+
+// ConsumeFromQueue calls the wrapped MessageQueue's ConsumeFromQueue.
+func (mq *instrumented) ConsumeFromQueue(chID ChannelID, qName string) (Messages, error) {
+	msgs, err := mq.MessageQueue.ConsumeFromQueue(chID, qName)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedMessages{msgs: msgs, qName: qName}, nil
+}
+
+// ConsumeFromQueueWithRetry calls the wrapped MessageQueue's ConsumeFromQueueWithRetry.
+func (mq *instrumented) ConsumeFromQueueWithRetry(chID ChannelID, qName string, policy RetryPolicy) (Messages, error) {
+	msgs, err := mq.MessageQueue.ConsumeFromQueueWithRetry(chID, qName, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedMessages{msgs: msgs, qName: qName}, nil
+}
+
+// ProcessMessages calls the wrapped MessageQueue's ProcessMessages with a handler that calls the Handled hook
+// after 'handler' processes each message, and the Panicked hook if 'handler' panics.
+func (mq *instrumented) ProcessMessages(ctx context.Context, msgs Messages, handler MessageHandler, args *map[string]interface{}, errs chan<- error) {
+	im, ok := msgs.(*instrumentedMessages)
+	if !ok || (mq.hooks.Handled == nil && mq.hooks.Panicked == nil) {
+		if ok {
+			msgs = im.msgs
+		}
+		mq.MessageQueue.ProcessMessages(ctx, msgs, handler, args, errs)
+		return
+	}
+
+	timedHandler := func(message []byte, args *map[string]interface{}) error {
+		start := time.Now()
+		err := Handle(handler, message, args)
+		duration := time.Since(start)
+		var panicErr *PanicError
+		if mq.hooks.Panicked != nil && errors.As(err, &panicErr) {
+			mq.hooks.Panicked(im.qName, message, panicErr)
+		}
+		if mq.hooks.Handled != nil {
+			mq.hooks.Handled(im.qName, duration, err)
+		}
+		return err
+	}
+	mq.MessageQueue.ProcessMessages(ctx, im.msgs, timedHandler, args, errs)
+}
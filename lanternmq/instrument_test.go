@@ -0,0 +1,98 @@
+package lanternmq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/memory"
+)
+
+func Test_Instrument(t *testing.T) {
+	var published []int
+	var handled []error
+	var panicked []string
+	done := make(chan bool, 10)
+	hooks := lanternmq.Hooks{
+		Published: func(target string, count int, err error) {
+			if target != "instrumented" || err != nil {
+				t.Errorf("expected the messages to be published to queue instrumented without an error")
+			}
+			published = append(published, count)
+		},
+		Handled: func(qName string, duration time.Duration, err error) {
+			if qName != "instrumented" {
+				t.Errorf("expected the message to be handled from queue instrumented, got %s", qName)
+			}
+			handled = append(handled, err)
+			done <- true
+		},
+		Panicked: func(qName string, message []byte, err *lanternmq.PanicError) {
+			if qName != "instrumented" || len(err.Stack) == 0 {
+				t.Errorf("expected the panic to be reported with the queue name and a stack trace")
+			}
+			panicked = append(panicked, string(message))
+		},
+	}
+
+	mq := lanternmq.Instrument(memory.NewMessageQueue(), hooks)
+	err := mq.Connect("user", "password", "Test_Instrument", "5672")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mq.Close()
+	chID, err := mq.CreateChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mq.DeclareQueue(chID, "instrumented", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mq.PublishToQueue(chID, "instrumented", "ok", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mq.PublishBatchToQueue(chID, "instrumented", []string{"reject", "ok", "panic"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 2 || published[0] != 1 || published[1] != 3 {
+		t.Fatalf("expected the Published hook to be called with counts of 1 and 3, got %v", published)
+	}
+
+	msgs, err := mq.ConsumeFromQueue(chID, "instrumented")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(message []byte, args *map[string]interface{}) error {
+		if string(message) == "reject" {
+			return errors.New("malformed message")
+		}
+		if string(message) == "panic" {
+			panic("unexpected message")
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the messages to be handled")
+		}
+	}
+	if handled[0] != nil || handled[1] == nil || handled[2] != nil || handled[3] == nil {
+		t.Errorf("expected only the second and fourth messages to be handled with an error, got %v", handled)
+	}
+	if len(panicked) != 1 || panicked[0] != "panic" {
+		t.Errorf("expected the Panicked hook to be called with the message the handler panicked on, got %v", panicked)
+	}
+}
@@ -2,6 +2,11 @@ package lanternmq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
 )
 
 // MessageQueue is an interface for writing messages to either a basic queue or a topic. Below are
@@ -12,15 +17,15 @@ import (
 // mq := <implementation of MessageQueue
 // err := mq.Connect("guest", "guest", "localhost", "5672")
 // chID, err := mq.CreateChannel()
-// err = mq.DeclareQueue(chID, "queueName")
-// err = mq.PublishToQueue(chID, "queueName", "message")
+// err = mq.DeclareQueue(chID, "queueName", 0)
+// err = mq.PublishToQueue(chID, "queueName", "message", 0)
 //
 // Example: Read a message from a queue
 // --------
 // mq := <implementation of MessageQueue
 // err := mq.Connect("guest", "guest", "localhost", "5672")
 // chID, err := mq.CreateChannel()
-// err = mq.DeclareQueue(chID, "queueName")
+// err = mq.DeclareQueue(chID, "queueName", 0)
 // msgs, err := mq.ConsumeFromQueue(chID, "queueName")
 // forever := make(chan bool)
 // errs := make(chan error)
@@ -73,16 +78,38 @@ type MessageQueue interface {
 	// QueueExists checks whether or not a queue already exists
 	QueueExists(chID ChannelID, qName string) (bool, error)
 	// DeclareQueue creates a queue with the name 'qName' on the channel with ID 'chID' if one
-	// does not exist.
-	DeclareQueue(chID ChannelID, qName string) error
+	// does not exist. If 'maxPriority' is not 0, the queue is a priority queue that delivers messages
+	// with a higher priority, up to 'maxPriority', first.
+	DeclareQueue(chID ChannelID, qName string, maxPriority Priority) error
+	// CountMessages returns how many messages are waiting to be delivered from the queue with name
+	// 'qName'.
+	CountMessages(chID ChannelID, qName string) (int, error)
+	// PurgeQueue removes the messages that are waiting to be delivered from the queue with name 'qName'.
+	PurgeQueue(chID ChannelID, qName string) error
 	// PublishToQueue sends 'message' to the queue with name 'qName' over the channel with ID
-	// 'chID'.
-	PublishToQueue(chID ChannelID, qName string, message string) error
+	// 'chID' with the priority 'priority'. The priority is ignored if the queue is not a priority queue.
+	PublishToQueue(chID ChannelID, qName string, message string, priority Priority) error
+	// PublishBatchToQueue sends each of 'messages' to the queue with name 'qName' over the channel
+	// with ID 'chID' with the priority 'priority'. If the channel is in confirm mode, the messages are
+	// confirmed together rather than one at a time.
+	PublishBatchToQueue(chID ChannelID, qName string, messages []string, priority Priority) error
+	// ConfirmPublishes puts the channel with ID 'chID' in confirm mode. Publishing a message on the
+	// channel then waits up to 'timeout' for the queuing service to confirm that it received the
+	// message, and returns an error if the message is not confirmed, is rejected, or is published to
+	// a queue that it cannot be routed to.
+	ConfirmPublishes(chID ChannelID, timeout time.Duration) error
 	// ConsumeFromQueue returns an instance of Messages, which acts like the receiving channel
-	// for any messages that present on queue 'qName' on the channel with ID 'chID'.
+	// for any messages that present on queue 'qName' on the channel with ID 'chID'. The consumer has no
+	// retry policy, so messages whose handler returns an error wrapped with Retry are rejected.
 	ConsumeFromQueue(chID ChannelID, qName string) (Messages, error)
+	// ConsumeFromQueueWithRetry is ConsumeFromQueue for a consumer whose messages are retried or dead
+	// lettered according to 'policy' when they are processed with ProcessMessages.
+	ConsumeFromQueueWithRetry(chID ChannelID, qName string, policy RetryPolicy) (Messages, error)
 	// ProcessMessages applies the 'handler' MessageHandler with arguments 'args' to each
-	// message that is received through 'msgs'. Sends any errors to the 'errs' channel.
+	// message that is received through 'msgs'. Sends any errors to the 'errs' channel. A message is
+	// acknowledged if the handler succeeds, retried if the handler returns an error wrapped with Retry,
+	// and rejected if the handler returns any other error. A panic in the handler is recovered with
+	// Handle and the message is rejected with the resulting *PanicError.
 	ProcessMessages(ctx context.Context, msgs Messages, handler MessageHandler, args *map[string]interface{}, errs chan<- error)
 	// DeclareExchange creates an exchange with the name 'name' and type 'exchangeType' on the channel with
 	// ID 'chID' if one does not exist.
@@ -98,11 +125,114 @@ type MessageQueue interface {
 	Close()
 }
 
+// Priority is the priority of a message published to a priority queue. Messages with a higher priority are
+// delivered before messages with a lower priority, and messages with the same priority are delivered in the order
+// they were published. Priorities above the queue's maximum priority are treated as the maximum.
+type Priority uint8
+
 // Messages is the stream of messages that will be received from a queue.
 type Messages interface{}
 
 // ChannelID is the identifier for a channel.
 type ChannelID interface{}
 
-// MessageHandler is a function to process an individual message.
+// MessageHandler is a function to process an individual message. Returning nil means the message was
+// processed. Returning an error wrapped with Retry means the message could not be processed now, such as when
+// a service it depends on is unavailable, and should be processed again later. Returning any other error means
+// the message can't be processed and is rejected.
 type MessageHandler func([]byte, *map[string]interface{}) error
+
+// RetryPolicy describes how a consumer handles messages that could not be processed.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a message is processed before it is dead lettered rather than retried. If
+	// it is 0, messages are retried until they are processed.
+	MaxAttempts int
+	// Delay is how long to wait before a message is processed again. If it is 0, the message is put back on
+	// its queue right away.
+	Delay time.Duration
+	// RetryQueue is the queue that messages wait in until they are processed again. It is required if Delay is
+	// not 0, and must already exist.
+	RetryQueue string
+	// DeadLetterQueue is the queue that rejected messages, and messages that have used up their attempts, are
+	// sent to. If it is empty, those messages are discarded. It must already exist.
+	DeadLetterQueue string
+}
+
+// retryError marks an error returned by a MessageHandler as one that the message should be retried after.
+type retryError struct {
+	err error
+}
+
+func (e *retryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+// Retry wraps 'err' so that a MessageHandler returning it signals that the message should be processed again
+// later according to the consumer's RetryPolicy. Retry returns nil if 'err' is nil.
+func Retry(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryError{err: err}
+}
+
+// IsRetry returns whether 'err', or any error it wraps, was wrapped with Retry.
+func IsRetry(err error) bool {
+	var retryErr *retryError
+	return errors.As(err, &retryErr)
+}
+
+// PanicError is the error that a panic in a MessageHandler is turned into by Handle, so that the message is
+// rejected rather than the panic taking down the consumer.
+type PanicError struct {
+	// Value is the value the handler panicked with.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("message handler panicked: %v", e.Value)
+}
+
+// Handle calls 'handler' with 'message' and 'args' and returns its error. If the handler panics, the panic is
+// recovered and returned as a *PanicError. Implementations of MessageQueue call their handlers through Handle.
+func Handle(handler MessageHandler, message []byte, args *map[string]interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return handler(message, args)
+}
+
+// TopicMatches returns whether a message published to a 'topic' exchange with the routing key 'routingKey' is
+// routed to a queue bound with the binding key 'bindingKey'. Keys are lists of words separated by dots. In the
+// binding key, '*' matches exactly one word and '#' matches zero or more words. It is used by implementations
+// that route topic exchanges themselves.
+func TopicMatches(bindingKey string, routingKey string) bool {
+	return topicMatches(strings.Split(bindingKey, "."), strings.Split(routingKey, "."))
+}
+
+func topicMatches(pattern []string, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatches(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatches(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatches(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,88 @@
+package lanternmq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_Retry(t *testing.T) {
+	err := errors.New("database unavailable")
+
+	if Retry(nil) != nil {
+		t.Errorf("expected retrying a nil error to be nil")
+	}
+	if IsRetry(err) || IsRetry(nil) {
+		t.Errorf("expected errors not wrapped with Retry not to be retried")
+	}
+
+	retryErr := Retry(err)
+	if !IsRetry(retryErr) {
+		t.Errorf("expected an error wrapped with Retry to be retried")
+	}
+	if retryErr.Error() != err.Error() {
+		t.Errorf("expected the error message to be unchanged, got %s", retryErr.Error())
+	}
+	if !errors.Is(retryErr, err) {
+		t.Errorf("expected the retried error to wrap the original error")
+	}
+
+	wrapped := fmt.Errorf("storing message: %w", retryErr)
+	if !IsRetry(wrapped) {
+		t.Errorf("expected an error wrapping a retried error to be retried")
+	}
+}
+
+func Test_Handle(t *testing.T) {
+	err := Handle(func(message []byte, args *map[string]interface{}) error {
+		return errors.New("malformed message")
+	}, []byte("message"), nil)
+	if err == nil || err.Error() != "malformed message" {
+		t.Errorf("expected the handler's error, got %v", err)
+	}
+
+	err = Handle(func(message []byte, args *map[string]interface{}) error {
+		var capStat map[string]interface{}
+		_ = capStat["rest"].([]interface{})
+		return nil
+	}, []byte("message"), nil)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected the handler's panic to be returned as a PanicError, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "message handler panicked: interface conversion") {
+		t.Errorf("expected the error to hold the panic, got %s", err.Error())
+	}
+	if !strings.Contains(string(panicErr.Stack), "Test_Handle") {
+		t.Errorf("expected the stack trace of the panic, got %s", panicErr.Stack)
+	}
+	if IsRetry(err) {
+		t.Errorf("expected a message whose handler panicked not to be retried")
+	}
+}
+
+func Test_TopicMatches(t *testing.T) {
+	cases := []struct {
+		bindingKey string
+		routingKey string
+		matches    bool
+	}{
+		{"a.b.c", "a.b.c", true},
+		{"a.b.c", "a.b", false},
+		{"a.*.c", "a.b.c", true},
+		{"a.*.c", "a.c", false},
+		{"a.#", "a", true},
+		{"a.#", "a.b.c", true},
+		{"#.c", "a.b.c", true},
+		{"#", "a.b.c", true},
+		{"a.#.c", "a.c", true},
+		{"a.#.c", "a.b.d", false},
+		{"*", "a.b", false},
+	}
+	for _, tc := range cases {
+		if TopicMatches(tc.bindingKey, tc.routingKey) != tc.matches {
+			t.Errorf("expected matching routing key %s with binding key %s to be %t", tc.routingKey, tc.bindingKey, tc.matches)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+)
+
+// The exchange types supported by the in-memory broker
+const (
+	exchangeDirect = "direct"
+	exchangeFanout = "fanout"
+	exchangeTopic  = "topic"
+)
+
+// brokers holds the in-memory brokers that have been connected to, by address, so that every MessageQueue in the
+// process that connects to the same address shares the same queues and exchanges.
+var brokers = map[string]*broker{}
+var brokersMu sync.Mutex
+
+// broker holds the queues and exchanges shared by the MessageQueues connected to it. All of the state of the
+// broker, and of the consumers reading from it, is guarded by mu. cond is signalled whenever messages are added
+// to a queue or a consumer's state changes, so that waiting consumers can check whether they can receive a
+// message.
+type broker struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queues    map[string]*queue
+	exchanges map[string]*exchange
+}
+
+// queue holds the messages that are ready to be delivered, in the order they are delivered. If maxPriority is not
+// 0, messages with a higher priority come first; otherwise the oldest message comes first.
+type queue struct {
+	name        string
+	maxPriority lanternmq.Priority
+	ready       []*message
+}
+
+// message is a message waiting in a queue. attempts and reason are the equivalent of the headers RabbitMQ uses
+// to track retried and dead lettered messages.
+type message struct {
+	body        []byte
+	priority    lanternmq.Priority
+	attempts    int
+	reason      string
+	redelivered bool
+}
+
+// exchange routes messages to the queues bound to it according to its type.
+type exchange struct {
+	kind     string
+	bindings []binding
+}
+
+// binding routes the messages published to an exchange with a matching routing key to the queue qName.
+type binding struct {
+	qName string
+	key   string
+}
+
+// getBroker returns the broker for the given address, creating it if it doesn't exist.
+func getBroker(address string) *broker {
+	brokersMu.Lock()
+	defer brokersMu.Unlock()
+
+	b, ok := brokers[address]
+	if !ok {
+		b = &broker{
+			queues:    map[string]*queue{},
+			exchanges: map[string]*exchange{},
+		}
+		b.cond = sync.NewCond(&b.mu)
+		brokers[address] = b
+	}
+	return b
+}
+
+// declareQueue creates the queue with the given name and maximum priority if it doesn't exist. The caller must
+// hold the lock.
+func (b *broker) declareQueue(qName string, maxPriority lanternmq.Priority) *queue {
+	q, ok := b.queues[qName]
+	if !ok {
+		q = &queue{name: qName, maxPriority: maxPriority}
+		b.queues[qName] = q
+	}
+	return q
+}
+
+// deleteQueue removes the queue with the given name and its bindings. The caller must hold the lock.
+func (b *broker) deleteQueue(qName string) {
+	delete(b.queues, qName)
+	for _, e := range b.exchanges {
+		var bindings []binding
+		for _, bnd := range e.bindings {
+			if bnd.qName != qName {
+				bindings = append(bindings, bnd)
+			}
+		}
+		e.bindings = bindings
+	}
+	b.cond.Broadcast()
+}
+
+// enqueue adds the message to the queue with the given name, behind the messages with the same or a higher
+// priority, and returns whether the queue exists. The caller must hold the lock.
+func (b *broker) enqueue(qName string, m *message) bool {
+	q, ok := b.queues[qName]
+	if !ok {
+		return false
+	}
+	i := len(q.ready)
+	for i > 0 && q.priority(q.ready[i-1]) < q.priority(m) {
+		i--
+	}
+	q.insert(i, m)
+	b.cond.Broadcast()
+	return true
+}
+
+// requeue puts the message back in its queue in front of the messages with the same or a lower priority, so that
+// it is delivered again before them. The caller must hold the lock.
+func (b *broker) requeue(q *queue, m *message) {
+	m.redelivered = true
+	i := 0
+	for i < len(q.ready) && q.priority(q.ready[i]) > q.priority(m) {
+		i++
+	}
+	q.insert(i, m)
+	b.cond.Broadcast()
+}
+
+// priority returns the priority the message is delivered with from the queue. Priorities are ignored by queues
+// that aren't priority queues, and priorities above the queue's maximum are treated as the maximum.
+func (q *queue) priority(m *message) lanternmq.Priority {
+	if m.priority > q.maxPriority {
+		return q.maxPriority
+	}
+	return m.priority
+}
+
+// insert adds the message to the queue's ready messages at index 'i'.
+func (q *queue) insert(i int, m *message) {
+	q.ready = append(q.ready, nil)
+	copy(q.ready[i+1:], q.ready[i:])
+	q.ready[i] = m
+}
+
+// remove takes the message out of the queue with the given name, and returns whether it was there. The caller
+// must hold the lock.
+func (b *broker) remove(qName string, m *message) bool {
+	q, ok := b.queues[qName]
+	if !ok {
+		return false
+	}
+	for i, ready := range q.ready {
+		if ready == m {
+			q.ready = append(q.ready[:i], q.ready[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// route returns the names of the queues bound to the exchange that a message with the given routing key is sent
+// to. Each queue is only included once. The caller must hold the lock.
+func (e *exchange) route(routingKey string) []string {
+	var qNames []string
+	seen := map[string]bool{}
+	for _, bnd := range e.bindings {
+		if seen[bnd.qName] {
+			continue
+		}
+		var matches bool
+		switch e.kind {
+		case exchangeFanout:
+			matches = true
+		case exchangeDirect:
+			matches = bnd.key == routingKey
+		case exchangeTopic:
+			matches = lanternmq.TopicMatches(bnd.key, routingKey)
+		}
+		if matches {
+			seen[bnd.qName] = true
+			qNames = append(qNames, bnd.qName)
+		}
+	}
+	return qNames
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"testing"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_route(t *testing.T) {
+	e := &exchange{
+		kind: exchangeDirect,
+		bindings: []binding{
+			{qName: "q1", key: "versions"},
+			{qName: "q2", key: "capabilities"},
+			{qName: "q1", key: "capabilities"},
+		},
+	}
+
+	qNames := e.route("capabilities")
+	th.Assert(t, len(qNames) == 2 && qNames[0] == "q2" && qNames[1] == "q1", "expected the message to be routed to q2 and q1")
+
+	qNames = e.route("other")
+	th.Assert(t, len(qNames) == 0, "expected the message to not be routed to any queue")
+
+	// each queue only gets the message once
+	e.kind = exchangeFanout
+	qNames = e.route("other")
+	th.Assert(t, len(qNames) == 2, "expected the message to be routed to each bound queue once")
+
+	e.kind = exchangeTopic
+	e.bindings = []binding{{qName: "q1", key: "endpoints.*"}, {qName: "q2", key: "endpoints.#"}}
+	qNames = e.route("endpoints.new.epic")
+	th.Assert(t, len(qNames) == 1 && qNames[0] == "q2", "expected the message to only be routed to q2")
+}
+
+func Test_requeue(t *testing.T) {
+	resetBroker("Test_requeue")
+	b := getBroker("Test_requeue:5672")
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q := b.declareQueue("q", 0)
+	first := newMessage("first")
+	second := newMessage("second")
+
+	th.Assert(t, b.enqueue("q", second), "expected the message to be added to the queue")
+	th.Assert(t, !b.enqueue("missing", second), "expected the message to not be added to a queue that doesn't exist")
+
+	b.requeue(q, first)
+	th.Assert(t, len(q.ready) == 2 && q.ready[0] == first, "expected the requeued message to be at the front of the queue")
+	th.Assert(t, first.redelivered, "expected the requeued message to be marked as redelivered")
+
+	th.Assert(t, b.remove("q", first), "expected the message to be removed from the queue")
+	th.Assert(t, !b.remove("q", first), "expected the message to already be removed from the queue")
+	th.Assert(t, len(q.ready) == 1 && q.ready[0] == second, "expected only the second message to be in the queue")
+}
+
+func Test_requeuePriority(t *testing.T) {
+	resetBroker("Test_requeuePriority")
+	b := getBroker("Test_requeuePriority:5672")
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q := b.declareQueue("q", 2)
+	routine := newMessage("routine")
+	high := newMessage("high")
+	high.priority = 2
+	requeued := newMessage("requeued")
+	requeued.priority = 1
+
+	b.enqueue("q", routine)
+	b.enqueue("q", high)
+	th.Assert(t, q.ready[0] == high && q.ready[1] == routine, "expected the high priority message to be first")
+
+	// a requeued message goes in front of the messages with the same or a lower priority
+	b.requeue(q, requeued)
+	th.Assert(t, len(q.ready) == 3 && q.ready[1] == requeued, "expected the requeued message to be between the high priority and routine messages")
+}
@@ -0,0 +1,579 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+)
+
+// Ensure MessageQueue implements lanternmq.MessageQueue.
+var _ lanternmq.MessageQueue = &MessageQueue{}
+
+// Ensure Messages implements lanternmq.Messages.
+var _ lanternmq.Messages = &Messages{}
+
+// MessageQueue is an in-memory implementation of the lanternmq.MessageQueue interface that behaves like the
+// RabbitMQ implementation without needing a broker, for local runs and tests. Every MessageQueue in the process
+// that connects to the same host and port shares the same queues and exchanges, so services that would normally
+// talk through RabbitMQ can run in one process. It supports:
+// * durable queues. Messages published to a queue that doesn't exist are dropped, or cause an error if the
+// channel is in confirm mode.
+// * 'direct', 'fanout' and 'topic' exchanges, and queues bound to them with DeclareExchangeReceiveQueue. The
+// bound queues are deleted when the MessageQueue that declared them is closed.
+// * limiting how many unacknowledged messages each consumer on a channel has with NumConcurrentMsgs
+// * acknowledging, requeuing, retrying and dead lettering messages in ProcessMessages, in the same way as the
+// RabbitMQ implementation. Messages in a retry queue are moved back to their queue after the policy's delay.
+// Unacknowledged messages are requeued when the MessageQueue is closed.
+type MessageQueue struct {
+	broker    *broker
+	channels  []*channel
+	consumers []*Messages
+	exclusive []string
+	closed    bool
+	mu        sync.Mutex
+}
+
+// channel holds the settings of a channel. Its fields are guarded by the broker's lock.
+type channel struct {
+	prefetch int
+	confirm  bool
+}
+
+// Messages is the stream of messages delivered to a consumer of a queue. Its closed and unacked fields are
+// guarded by the broker's lock.
+type Messages struct {
+	broker     *broker
+	q          *queue
+	c          *channel
+	policy     *lanternmq.RetryPolicy
+	deliveries chan *delivery
+	done       chan struct{}
+	closed     bool
+	unacked    []*delivery
+}
+
+// delivery is a message that has been delivered to a consumer and not yet settled.
+type delivery struct {
+	msgs *Messages
+	msg  *message
+}
+
+// NewMessageQueue returns a MessageQueue that has not connected to a broker yet.
+func NewMessageQueue() lanternmq.MessageQueue {
+	return &MessageQueue{}
+}
+
+// getChannel returns the broker the MessageQueue is connected to and the channel with ID `id`.
+func (mq *MessageQueue) getChannel(id lanternmq.ChannelID) (*broker, *channel, error) {
+	idInt, ok := id.(int)
+	if !ok {
+		return nil, nil, errors.New("ChannelID not of correct type")
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if idInt < 0 || idInt >= len(mq.channels) {
+		return nil, nil, errors.New("no channel with the requested ID was found")
+	}
+	return mq.broker, mq.channels[idInt], nil
+}
+
+// Connect connects to the in-memory broker for the given host and port, creating it if no MessageQueue in the
+// process has connected to it yet. The username and password are not checked.
+func (mq *MessageQueue) Connect(username string, password string, host string, port string) error {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	mq.broker = getBroker(host + ":" + port)
+	mq.closed = false
+	return nil
+}
+
+// CreateChannel creates a channel to the broker that has already been connected to. If the broker has not been
+// connected to already, an error is thrown. The channel's ID is returned.
+func (mq *MessageQueue) CreateChannel() (lanternmq.ChannelID, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.broker == nil {
+		return "", errors.New("connection must exist before creating a channel")
+	}
+	mq.channels = append(mq.channels, &channel{})
+	return lanternmq.ChannelID(len(mq.channels) - 1), nil
+}
+
+// NumConcurrentMsgs defines how many unacknowledged messages each consumer on the channel can have at one time.
+// If 'num' is 0, there is no limit.
+func (mq *MessageQueue) NumConcurrentMsgs(chID lanternmq.ChannelID, num int) error {
+	b, c, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.prefetch = num
+	b.cond.Broadcast()
+	return nil
+}
+
+// QueueExists checks whether or not a queue already exists.
+func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (bool, error) {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.queues[qName]
+	return ok, nil
+}
+
+// DeclareQueue creates a queue with the given name and maximum priority if one does not exist. As with RabbitMQ,
+// it returns an error if the queue exists with a different maximum priority.
+func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, qName string, maxPriority lanternmq.Priority) error {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.declareQueue(qName, maxPriority)
+	if q.maxPriority != maxPriority {
+		return fmt.Errorf("unable to create queue: queue %s already exists with maximum priority %d", qName, q.maxPriority)
+	}
+	return nil
+}
+
+// PublishToQueue adds 'message' to the queue with name 'qName' with the priority 'priority'.
+func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+	return mq.PublishBatchToQueue(chID, qName, []string{message}, priority)
+}
+
+// PublishBatchToQueue adds each of 'messages' to the queue with name 'qName' with the priority 'priority'. If the
+// queue doesn't exist, the messages are dropped, or if the channel is in confirm mode, an error is returned.
+func (mq *MessageQueue) PublishBatchToQueue(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+	b, c, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[qName]; !ok {
+		if c.confirm {
+			return fmt.Errorf("%d published messages were returned as unroutable: %s NO_ROUTE", len(messages), qName)
+		}
+		return nil
+	}
+	for _, msg := range messages {
+		m := newMessage(msg)
+		m.priority = priority
+		b.enqueue(qName, m)
+	}
+	return nil
+}
+
+// ConfirmPublishes puts the channel in confirm mode, so that publishing to a queue that doesn't exist returns an
+// error. Messages are added to queues as they are published, so there is nothing to wait for.
+func (mq *MessageQueue) ConfirmPublishes(chID lanternmq.ChannelID, timeout time.Duration) error {
+	b, c, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.confirm = true
+	return nil
+}
+
+// ConsumeFromQueue starts a consumer that receives the messages in the queue with name 'qName'.
+func (mq *MessageQueue) ConsumeFromQueue(chID lanternmq.ChannelID, qName string) (lanternmq.Messages, error) {
+	return mq.consumeFromQueue(chID, qName, nil)
+}
+
+// ConsumeFromQueueWithRetry starts a consumer that receives the messages in the queue with name 'qName'. When
+// the messages are processed with ProcessMessages, they are retried or dead lettered according to 'policy'.
+func (mq *MessageQueue) ConsumeFromQueueWithRetry(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	if policy.Delay > 0 && policy.RetryQueue == "" {
+		return nil, errors.New("a retry queue is required to retry messages after a delay")
+	}
+	for _, name := range []string{policy.RetryQueue, policy.DeadLetterQueue} {
+		if name == "" {
+			continue
+		}
+		exists, err := mq.QueueExists(chID, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("queue %s does not exist", name)
+		}
+	}
+	return mq.consumeFromQueue(chID, qName, &policy)
+}
+
+// consumeFromQueue starts a consumer for the queue with name 'qName' whose messages are handled according to
+// 'policy', which is nil if the consumer has no retry policy.
+func (mq *MessageQueue) consumeFromQueue(chID lanternmq.ChannelID, qName string, policy *lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	b, c, err := mq.getChannel(chID)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	q, ok := b.queues[qName]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", qName)
+	}
+
+	msgs := &Messages{
+		broker:     b,
+		q:          q,
+		c:          c,
+		policy:     policy,
+		deliveries: make(chan *delivery),
+		done:       make(chan struct{}),
+	}
+
+	mq.mu.Lock()
+	mq.consumers = append(mq.consumers, msgs)
+	mq.mu.Unlock()
+
+	go b.dispatch(msgs)
+
+	return msgs, nil
+}
+
+// dispatch delivers messages from the consumer's queue to the consumer whenever the queue has a message and the
+// consumer has fewer unacknowledged messages than its channel allows, until the consumer is closed.
+func (b *broker) dispatch(msgs *Messages) {
+	for {
+		b.mu.Lock()
+		for !msgs.closed && !msgs.canReceive() {
+			b.cond.Wait()
+		}
+		if msgs.closed {
+			b.mu.Unlock()
+			return
+		}
+		d := &delivery{msgs: msgs, msg: msgs.q.ready[0]}
+		msgs.q.ready = msgs.q.ready[1:]
+		msgs.unacked = append(msgs.unacked, d)
+		b.mu.Unlock()
+
+		select {
+		case msgs.deliveries <- d:
+		case <-msgs.done:
+			// the delivery is requeued along with the other unacknowledged messages
+			return
+		}
+	}
+}
+
+// canReceive returns whether the consumer's queue has a message and the consumer can take another
+// unacknowledged message. The caller must hold the broker's lock.
+func (msgs *Messages) canReceive() bool {
+	return len(msgs.q.ready) > 0 && (msgs.c.prefetch <= 0 || len(msgs.unacked) < msgs.c.prefetch)
+}
+
+// ProcessMessages takes each message delivered through 'msgs' and provides it along with 'args' to the
+// lanternmq.MessageHandler 'handler'. The message is then acknowledged, retried or rejected according to the
+// error the handler returns, in the same way as the RabbitMQ implementation. Errors from the handler or from
+// settling the message are sent to the 'errs' channel. ProcessMessages returns when the context is done or the
+// MessageQueue is closed.
+func (mq *MessageQueue) ProcessMessages(ctx context.Context, msgs lanternmq.Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error) {
+	msgsd, ok := msgs.(*Messages)
+	if !ok {
+		errs <- errors.New("the messages are of the wrong type")
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-msgsd.done:
+			return
+		case d := <-msgsd.deliveries:
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				// ok
+			}
+			handlerErr := lanternmq.Handle(handler, d.msg.body, args)
+			if handlerErr != nil {
+				errs <- handlerErr
+			}
+			err := msgsd.settle(d, handlerErr)
+			if err != nil {
+				errs <- err
+			}
+		}
+	}
+}
+
+// settle acknowledges, retries or rejects the delivery given the error 'handlerErr' returned when it was
+// processed. See the RabbitMQ implementation's settle.
+func (msgs *Messages) settle(d *delivery, handlerErr error) error {
+	b := msgs.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if handlerErr == nil {
+		return b.settleDelivery(d, false)
+	}
+
+	policy := msgs.policy
+	attempts := d.msg.attempts + 1
+
+	if lanternmq.IsRetry(handlerErr) && policy != nil && (policy.MaxAttempts == 0 || attempts < policy.MaxAttempts) {
+		if policy.Delay == 0 && policy.MaxAttempts == 0 {
+			return b.settleDelivery(d, true)
+		}
+		m := &message{body: d.msg.body, priority: d.msg.priority, attempts: attempts}
+		if policy.Delay == 0 {
+			return b.forward(d, msgs.q.name, m)
+		}
+		err := b.forward(d, policy.RetryQueue, m)
+		if err != nil {
+			return err
+		}
+		qName := msgs.q.name
+		time.AfterFunc(policy.Delay, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			// the message expires and goes back to its queue, as it would be dead lettered by a RabbitMQ retry queue
+			if b.remove(policy.RetryQueue, m) {
+				b.enqueue(qName, m)
+			}
+		})
+		return nil
+	}
+
+	if policy == nil || policy.DeadLetterQueue == "" {
+		return b.settleDelivery(d, false)
+	}
+	return b.forward(d, policy.DeadLetterQueue, &message{body: d.msg.body, priority: d.msg.priority, attempts: attempts, reason: handlerErr.Error()})
+}
+
+// forward adds 'm' to the queue with name 'qName' in place of the delivery 'd' and acknowledges 'd'. If the queue
+// doesn't exist, 'd' is requeued instead so that it isn't lost. The caller must hold the lock.
+func (b *broker) forward(d *delivery, qName string, m *message) error {
+	err := d.check()
+	if err != nil {
+		return err
+	}
+	if !b.enqueue(qName, m) {
+		err = b.settleDelivery(d, true)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("unable to send message to queue %s, requeued it instead: queue does not exist", qName)
+	}
+	return b.settleDelivery(d, false)
+}
+
+// check returns an error if the delivery can't be settled because its consumer was closed or it has already been
+// settled. The caller must hold the broker's lock.
+func (d *delivery) check() error {
+	if d.msgs.closed {
+		return errors.New("channel is not open")
+	}
+	for _, unacked := range d.msgs.unacked {
+		if unacked == d {
+			return nil
+		}
+	}
+	return errors.New("delivery has already been acknowledged")
+}
+
+// settleDelivery removes the delivery from its consumer's unacknowledged messages, putting the message back on
+// its queue if 'requeue' is true. The caller must hold the lock.
+func (b *broker) settleDelivery(d *delivery, requeue bool) error {
+	err := d.check()
+	if err != nil {
+		return err
+	}
+
+	msgs := d.msgs
+	for i, unacked := range msgs.unacked {
+		if unacked == d {
+			msgs.unacked = append(msgs.unacked[:i], msgs.unacked[i+1:]...)
+			break
+		}
+	}
+	if requeue {
+		b.requeue(msgs.q, d.msg)
+	}
+	b.cond.Broadcast()
+	return nil
+}
+
+// DeclareExchange creates an exchange named 'name' of type 'exchangeType' if one does not exist. The supported
+// exchange types are 'direct', 'fanout' and 'topic'.
+func (mq *MessageQueue) DeclareExchange(chID lanternmq.ChannelID, name string, exchangeType string) error {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	switch exchangeType {
+	case exchangeDirect, exchangeFanout, exchangeTopic:
+		// ok
+	default:
+		return fmt.Errorf("unable to declare target: exchange type %s is not supported", exchangeType)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.exchanges[name]
+	if ok && e.kind != exchangeType {
+		return fmt.Errorf("unable to declare target: exchange %s already exists with type %s", name, e.kind)
+	}
+	if !ok {
+		b.exchanges[name] = &exchange{kind: exchangeType}
+	}
+	return nil
+}
+
+// PublishToExchange adds 'message' to each queue bound to the exchange 'name' whose binding matches
+// 'routingKey'. Messages that match no binding are dropped.
+func (mq *MessageQueue) PublishToExchange(chID lanternmq.ChannelID, name string, routingKey string, message string) error {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, ok := b.exchanges[name]
+	if !ok {
+		return fmt.Errorf("unable to publish to target %s with routing key %s", name, routingKey)
+	}
+	for _, qName := range e.route(routingKey) {
+		b.enqueue(qName, newMessage(message))
+	}
+	return nil
+}
+
+// newMessage returns a message holding 'body'.
+func newMessage(body string) *message {
+	return &message{body: []byte(body)}
+}
+
+// DeclareExchangeReceiveQueue creates a queue named 'qName' and binds it to the exchange named 'exchangeName'
+// with the routing key 'routingKey'. The queue is deleted when the MessageQueue is closed.
+func (mq *MessageQueue) DeclareExchangeReceiveQueue(chID lanternmq.ChannelID, exchangeName string, qName string, routingKey string) error {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	e, ok := b.exchanges[exchangeName]
+	if !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("unable to bind queue %s to target %s with routing key %s", qName, exchangeName, routingKey)
+	}
+	_, existed := b.queues[qName]
+	b.declareQueue(qName, 0)
+	bnd := binding{qName: qName, key: routingKey}
+	bound := false
+	for _, existing := range e.bindings {
+		if existing == bnd {
+			bound = true
+		}
+	}
+	if !bound {
+		e.bindings = append(e.bindings, bnd)
+	}
+	b.mu.Unlock()
+
+	if !existed {
+		mq.mu.Lock()
+		mq.exclusive = append(mq.exclusive, qName)
+		mq.mu.Unlock()
+	}
+	return nil
+}
+
+// CountMessages returns how many messages are waiting to be delivered from the queue with name 'qName'.
+func (mq *MessageQueue) CountMessages(chID lanternmq.ChannelID, qName string) (int, error) {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return -1, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[qName]
+	if !ok {
+		return -1, fmt.Errorf("queue %s does not exist", qName)
+	}
+	return len(q.ready), nil
+}
+
+// PurgeQueue removes the messages that are waiting to be delivered from the queue with name 'qName'.
+func (mq *MessageQueue) PurgeQueue(chID lanternmq.ChannelID, qName string) error {
+	b, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[qName]
+	if !ok {
+		return fmt.Errorf("queue %s does not exist", qName)
+	}
+	q.ready = nil
+	return nil
+}
+
+// Close stops the MessageQueue's consumers and requeues their unacknowledged messages, and deletes the queues
+// it declared with DeclareExchangeReceiveQueue. Any ProcessMessages calls return.
+func (mq *MessageQueue) Close() {
+	mq.mu.Lock()
+	if mq.closed || mq.broker == nil {
+		mq.closed = true
+		mq.mu.Unlock()
+		return
+	}
+	mq.closed = true
+	b := mq.broker
+	consumers := mq.consumers
+	exclusive := mq.exclusive
+	mq.consumers = nil
+	mq.exclusive = nil
+	mq.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, msgs := range consumers {
+		msgs.closed = true
+		close(msgs.done)
+		// requeue the newest message first so that the oldest ends up at the front of the queue
+		for i := len(msgs.unacked) - 1; i >= 0; i-- {
+			b.requeue(msgs.q, msgs.unacked[i].msg)
+		}
+		msgs.unacked = nil
+	}
+	for _, qName := range exclusive {
+		b.deleteQueue(qName)
+	}
+	b.cond.Broadcast()
+}
@@ -0,0 +1,521 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+)
+
+// setupQueue connects a new MessageQueue to the in-memory broker for 'host', creates a channel and declares the
+// given queues.
+func setupQueue(t *testing.T, host string, qNames ...string) (lanternmq.MessageQueue, lanternmq.ChannelID) {
+	mq := NewMessageQueue()
+	err := mq.Connect("user", "password", host, "5672")
+	th.Assert(t, err == nil, err)
+	chID, err := mq.CreateChannel()
+	th.Assert(t, err == nil, err)
+	for _, qName := range qNames {
+		err = mq.DeclareQueue(chID, qName, 0)
+		th.Assert(t, err == nil, err)
+	}
+	return mq, chID
+}
+
+// resetBroker removes the in-memory broker for 'host' so that a test starts without any queues or exchanges.
+func resetBroker(host string) {
+	brokersMu.Lock()
+	defer brokersMu.Unlock()
+	delete(brokers, host+":5672")
+}
+
+// receive returns the next message processed by 'handler' through 'received', or fails the test if there is no
+// message within a second.
+func receive(t *testing.T, received <-chan string) string {
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return ""
+}
+
+// expectNone fails the test if a message is processed through 'received' in the next 100 milliseconds.
+func expectNone(t *testing.T, received <-chan string) {
+	select {
+	case msg := <-received:
+		t.Fatalf("did not expect to receive a message, got %s", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func Test_CreateChannel(t *testing.T) {
+	mq := NewMessageQueue()
+
+	_, err := mq.CreateChannel()
+	th.Assert(t, err != nil, "expected an error creating a channel before connecting")
+
+	err = mq.NumConcurrentMsgs("0", 1)
+	th.Assert(t, err != nil && err.Error() == "ChannelID not of correct type", "expected an error for a channel ID of the wrong type")
+
+	err = mq.NumConcurrentMsgs(0, 1)
+	th.Assert(t, err != nil && err.Error() == "no channel with the requested ID was found", "expected an error for a channel that doesn't exist")
+}
+
+func Test_PublishAndConsume(t *testing.T) {
+	resetBroker("Test_PublishAndConsume")
+	mq, chID := setupQueue(t, "Test_PublishAndConsume", "q")
+	defer mq.Close()
+
+	exists, err := mq.QueueExists(chID, "q")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, exists, "expected the queue to exist")
+	exists, err = mq.QueueExists(chID, "other")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, !exists, "expected the queue to not exist")
+
+	err = mq.PublishToQueue(chID, "q", "one", 0)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishBatchToQueue(chID, "q", []string{"two", "three"}, 0)
+	th.Assert(t, err == nil, err)
+
+	count, err := mq.CountMessages(chID, "q")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 3, fmt.Sprintf("expected 3 messages in the queue, got %d", count))
+
+	// messages published to a queue that doesn't exist are dropped
+	err = mq.PublishToQueue(chID, "other", "dropped", 0)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, "q")
+	th.Assert(t, err == nil, err)
+	_, err = mq.ConsumeFromQueue(chID, "other")
+	th.Assert(t, err != nil, "expected an error consuming from a queue that doesn't exist")
+
+	received := make(chan string)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	for _, expected := range []string{"one", "two", "three"} {
+		msg := receive(t, received)
+		th.Assert(t, msg == expected, fmt.Sprintf("expected to receive %s, got %s", expected, msg))
+	}
+
+	err = mq.PublishToQueue(chID, "q", "four", 0)
+	th.Assert(t, err == nil, err)
+	msg := receive(t, received)
+	th.Assert(t, msg == "four", fmt.Sprintf("expected to receive four, got %s", msg))
+
+	th.Assert(t, len(errs) == 0, "expected no errors processing the messages")
+}
+
+func Test_Priorities(t *testing.T) {
+	resetBroker("Test_Priorities")
+	mq, chID := setupQueue(t, "Test_Priorities", "fifo")
+	defer mq.Close()
+
+	err := mq.DeclareQueue(chID, "priority", 2)
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareQueue(chID, "priority", 2)
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareQueue(chID, "priority", 5)
+	th.Assert(t, err != nil, "expected an error redeclaring a queue with a different maximum priority")
+
+	for _, qName := range []string{"priority", "fifo"} {
+		err = mq.PublishBatchToQueue(chID, qName, []string{"routine 1", "routine 2"}, 0)
+		th.Assert(t, err == nil, err)
+		err = mq.PublishToQueue(chID, qName, "new", 1)
+		th.Assert(t, err == nil, err)
+		err = mq.PublishToQueue(chID, qName, "on demand 1", 2)
+		th.Assert(t, err == nil, err)
+		// priorities above the maximum are treated as the maximum
+		err = mq.PublishToQueue(chID, qName, "on demand 2", 9)
+		th.Assert(t, err == nil, err)
+	}
+
+	expected := map[string][]string{
+		"priority": {"on demand 1", "on demand 2", "new", "routine 1", "routine 2"},
+		"fifo":     {"routine 1", "routine 2", "new", "on demand 1", "on demand 2"},
+	}
+	for qName, order := range expected {
+		msgs, err := mq.ConsumeFromQueue(chID, qName)
+		th.Assert(t, err == nil, err)
+		msgsd := msgs.(*Messages)
+		for _, body := range order {
+			d := <-msgsd.deliveries
+			th.Assert(t, string(d.msg.body) == body, fmt.Sprintf("expected to receive %s from queue %s, got %s", body, qName, string(d.msg.body)))
+			err = msgsd.settle(d, nil)
+			th.Assert(t, err == nil, err)
+		}
+	}
+}
+
+func Test_ConfirmPublishes(t *testing.T) {
+	resetBroker("Test_ConfirmPublishes")
+	mq, chID := setupQueue(t, "Test_ConfirmPublishes", "q")
+	defer mq.Close()
+
+	err := mq.ConfirmPublishes(chID, time.Second)
+	th.Assert(t, err == nil, err)
+
+	err = mq.PublishToQueue(chID, "q", "one", 0)
+	th.Assert(t, err == nil, err)
+
+	err = mq.PublishBatchToQueue(chID, "other", []string{"one", "two"}, 0)
+	th.Assert(t, err != nil, "expected an error publishing to a queue that doesn't exist in confirm mode")
+	th.Assert(t, err.Error() == "2 published messages were returned as unroutable: other NO_ROUTE", fmt.Sprintf("unexpected error %s", err.Error()))
+}
+
+func Test_NumConcurrentMsgs(t *testing.T) {
+	resetBroker("Test_NumConcurrentMsgs")
+	mq, chID := setupQueue(t, "Test_NumConcurrentMsgs", "q")
+	defer mq.Close()
+
+	err := mq.NumConcurrentMsgs(chID, 1)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishBatchToQueue(chID, "q", []string{"one", "two"}, 0)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, "q")
+	th.Assert(t, err == nil, err)
+	msgsd := msgs.(*Messages)
+
+	d := <-msgsd.deliveries
+	th.Assert(t, string(d.msg.body) == "one", "expected to receive the first message")
+
+	// the second message isn't delivered until the first is acknowledged
+	select {
+	case <-msgsd.deliveries:
+		t.Fatal("did not expect a second message before the first was acknowledged")
+	case <-time.After(100 * time.Millisecond):
+	}
+	count, err := mq.CountMessages(chID, "q")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 1, fmt.Sprintf("expected 1 message waiting in the queue, got %d", count))
+
+	err = msgsd.settle(d, nil)
+	th.Assert(t, err == nil, err)
+	err = msgsd.settle(d, nil)
+	th.Assert(t, err != nil, "expected an error acknowledging a message twice")
+
+	select {
+	case d = <-msgsd.deliveries:
+		th.Assert(t, string(d.msg.body) == "two", "expected to receive the second message")
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the second message")
+	}
+}
+
+func Test_CloseRequeues(t *testing.T) {
+	resetBroker("Test_CloseRequeues")
+	mq, chID := setupQueue(t, "Test_CloseRequeues", "q")
+
+	err := mq.PublishBatchToQueue(chID, "q", []string{"one", "two", "three"}, 0)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, "q")
+	th.Assert(t, err == nil, err)
+	msgsd := msgs.(*Messages)
+	<-msgsd.deliveries
+	<-msgsd.deliveries
+
+	mq.Close()
+
+	mq2, chID2 := setupQueue(t, "Test_CloseRequeues")
+	defer mq2.Close()
+
+	count, err := mq2.CountMessages(chID2, "q")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 3, fmt.Sprintf("expected the unacknowledged messages to be requeued, got %d messages in the queue", count))
+
+	msgs, err = mq2.ConsumeFromQueue(chID2, "q")
+	th.Assert(t, err == nil, err)
+	d := <-msgs.(*Messages).deliveries
+	th.Assert(t, string(d.msg.body) == "one", "expected the oldest message to be delivered first")
+	th.Assert(t, d.msg.redelivered, "expected the message to be marked as redelivered")
+
+	// ProcessMessages returns when the MessageQueue is closed
+	done := make(chan struct{})
+	go func() {
+		mq2.ProcessMessages(context.Background(), msgs, func([]byte, *map[string]interface{}) error { return nil }, nil, make(chan error, 10))
+		close(done)
+	}()
+	mq2.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected ProcessMessages to return when the MessageQueue was closed")
+	}
+}
+
+func Test_ProcessMessagesRetry(t *testing.T) {
+	resetBroker("Test_ProcessMessagesRetry")
+	mq, chID := setupQueue(t, "Test_ProcessMessagesRetry", "q", "q-retry", "q-dead")
+	defer mq.Close()
+
+	_, err := mq.ConsumeFromQueueWithRetry(chID, "q", lanternmq.RetryPolicy{Delay: time.Second})
+	th.Assert(t, err != nil, "expected an error for a delay without a retry queue")
+	_, err = mq.ConsumeFromQueueWithRetry(chID, "q", lanternmq.RetryPolicy{DeadLetterQueue: "missing"})
+	th.Assert(t, err != nil, "expected an error for a dead letter queue that doesn't exist")
+
+	policy := lanternmq.RetryPolicy{
+		MaxAttempts:     3,
+		Delay:           50 * time.Millisecond,
+		RetryQueue:      "q-retry",
+		DeadLetterQueue: "q-dead",
+	}
+	msgs, err := mq.ConsumeFromQueueWithRetry(chID, "q", policy)
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		switch string(message) {
+		case "retry":
+			return lanternmq.Retry(errors.New("database unavailable"))
+		case "reject":
+			return errors.New("malformed message")
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	// a rejected message goes straight to the dead letter queue
+	err = mq.PublishToQueue(chID, "reject", "reject", 0)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishToQueue(chID, "q", "reject", 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, receive(t, received) == "reject", "expected to receive the rejected message")
+	expectNone(t, received)
+
+	// a retried message is processed again after the delay until it runs out of attempts
+	err = mq.PublishToQueue(chID, "q", "retry", 0)
+	th.Assert(t, err == nil, err)
+	for i := 0; i < policy.MaxAttempts; i++ {
+		th.Assert(t, receive(t, received) == "retry", "expected to receive the retried message")
+	}
+	expectNone(t, received)
+
+	count, err := mq.CountMessages(chID, "q-dead")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 2, fmt.Sprintf("expected 2 messages in the dead letter queue, got %d", count))
+	count, err = mq.CountMessages(chID, "q-retry")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected no messages in the retry queue, got %d", count))
+
+	b := msgs.(*Messages).broker
+	b.mu.Lock()
+	dead := b.queues["q-dead"].ready
+	th.Assert(t, dead[0].reason == "malformed message" && dead[0].attempts == 1, "expected the rejected message to be dead lettered after one attempt")
+	th.Assert(t, dead[1].reason == "database unavailable" && dead[1].attempts == 3, "expected the retried message to be dead lettered after three attempts")
+	b.mu.Unlock()
+
+	th.Assert(t, len(errs) == 4, fmt.Sprintf("expected the handler errors to be sent to the errs channel, got %d errors", len(errs)))
+}
+
+func Test_ProcessMessagesPanic(t *testing.T) {
+	resetBroker("Test_ProcessMessagesPanic")
+	mq, chID := setupQueue(t, "Test_ProcessMessagesPanic", "q", "q-dead")
+	defer mq.Close()
+
+	msgs, err := mq.ConsumeFromQueueWithRetry(chID, "q", lanternmq.RetryPolicy{MaxAttempts: 3, DeadLetterQueue: "q-dead"})
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		if string(message) == "malformed" {
+			var capStat map[string]interface{}
+			_ = capStat["rest"].([]interface{})
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	// the message the handler panics on is dead lettered without being retried, and the next message is processed
+	err = mq.PublishBatchToQueue(chID, "q", []string{"malformed", "message"}, 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, receive(t, received) == "malformed", "expected to receive the malformed message")
+	th.Assert(t, receive(t, received) == "message", "expected to receive the message after the malformed one")
+	expectNone(t, received)
+
+	count, err := mq.CountMessages(chID, "q-dead")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 1, fmt.Sprintf("expected the malformed message in the dead letter queue, got %d messages", count))
+
+	var panicErr *lanternmq.PanicError
+	th.Assert(t, len(errs) == 1 && errors.As(<-errs, &panicErr), "expected the panic to be sent to the errs channel")
+}
+
+func Test_ProcessMessagesRequeue(t *testing.T) {
+	resetBroker("Test_ProcessMessagesRequeue")
+	mq, chID := setupQueue(t, "Test_ProcessMessagesRequeue", "q")
+	defer mq.Close()
+
+	msgs, err := mq.ConsumeFromQueueWithRetry(chID, "q", lanternmq.RetryPolicy{})
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string)
+	attempts := 0
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		attempts++
+		if attempts == 1 {
+			return lanternmq.Retry(errors.New("try again"))
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	// with a retry policy that has no delay or limit, a retried message is requeued straight away
+	err = mq.PublishToQueue(chID, "q", "message", 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, receive(t, received) == "message", "expected to receive the message")
+	th.Assert(t, receive(t, received) == "message", "expected to receive the requeued message")
+	expectNone(t, received)
+}
+
+func Test_ProcessMessagesWithoutPolicy(t *testing.T) {
+	resetBroker("Test_ProcessMessagesWithoutPolicy")
+	mq, chID := setupQueue(t, "Test_ProcessMessagesWithoutPolicy", "q")
+	defer mq.Close()
+
+	msgs, err := mq.ConsumeFromQueue(chID, "q")
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		return lanternmq.Retry(errors.New("try again"))
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	// without a retry policy, a retried message is rejected rather than redelivered over and over
+	err = mq.PublishToQueue(chID, "q", "message", 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, receive(t, received) == "message", "expected to receive the message")
+	expectNone(t, received)
+
+	count, err := mq.CountMessages(chID, "q")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected the message to be rejected, got %d messages", count))
+}
+
+func Test_Exchanges(t *testing.T) {
+	resetBroker("Test_Exchanges")
+	mq, chID := setupQueue(t, "Test_Exchanges")
+
+	err := mq.DeclareExchange(chID, "logs", "headers")
+	th.Assert(t, err != nil, "expected an error declaring an unsupported exchange type")
+	err = mq.DeclareExchange(chID, "logs", "topic")
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareExchange(chID, "logs", "fanout")
+	th.Assert(t, err != nil, "expected an error redeclaring an exchange with a different type")
+
+	err = mq.PublishToExchange(chID, "missing", "key", "message")
+	th.Assert(t, err != nil, "expected an error publishing to an exchange that doesn't exist")
+	err = mq.DeclareExchangeReceiveQueue(chID, "missing", "q", "key")
+	th.Assert(t, err != nil, "expected an error binding to an exchange that doesn't exist")
+
+	err = mq.DeclareExchangeReceiveQueue(chID, "logs", "errors", "*.error")
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareExchangeReceiveQueue(chID, "logs", "all", "#")
+	th.Assert(t, err == nil, err)
+
+	err = mq.PublishToExchange(chID, "logs", "querier.error", "failed")
+	th.Assert(t, err == nil, err)
+	err = mq.PublishToExchange(chID, "logs", "querier.info", "succeeded")
+	th.Assert(t, err == nil, err)
+
+	count, err := mq.CountMessages(chID, "errors")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 1, fmt.Sprintf("expected 1 message in the errors queue, got %d", count))
+	count, err = mq.CountMessages(chID, "all")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 2, fmt.Sprintf("expected 2 messages in the all queue, got %d", count))
+
+	err = mq.PurgeQueue(chID, "all")
+	th.Assert(t, err == nil, err)
+	count, err = mq.CountMessages(chID, "all")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected the all queue to be empty after purging, got %d", count))
+
+	// the bound queues are deleted when the MessageQueue is closed
+	mq.Close()
+	mq2, chID2 := setupQueue(t, "Test_Exchanges")
+	defer mq2.Close()
+	exists, err := mq2.QueueExists(chID2, "errors")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, !exists, "expected the bound queue to be deleted")
+}
+
+// Test_Pipeline runs messages through two services connected by queues and a topic exchange, as the lantern
+// services would be when run in one process.
+func Test_Pipeline(t *testing.T) {
+	host := "Test_Pipeline"
+	resetBroker(host)
+	producer, producerCh := setupQueue(t, host, "endpoints", "responses")
+	defer producer.Close()
+	querier, querierCh := setupQueue(t, host)
+	defer querier.Close()
+	receiver, receiverCh := setupQueue(t, host)
+	defer receiver.Close()
+
+	err := receiver.DeclareExchange(receiverCh, "results", "topic")
+	th.Assert(t, err == nil, err)
+	err = producer.DeclareExchangeReceiveQueue(producerCh, "results", "results", "results.#")
+	th.Assert(t, err == nil, err)
+
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	endpoints, err := querier.ConsumeFromQueue(querierCh, "endpoints")
+	th.Assert(t, err == nil, err)
+	go querier.ProcessMessages(ctx, endpoints, func(message []byte, args *map[string]interface{}) error {
+		return querier.PublishToQueue(querierCh, "responses", "response from "+string(message), 0)
+	}, nil, errs)
+
+	responses, err := receiver.ConsumeFromQueue(receiverCh, "responses")
+	th.Assert(t, err == nil, err)
+	go receiver.ProcessMessages(ctx, responses, func(message []byte, args *map[string]interface{}) error {
+		return receiver.PublishToExchange(receiverCh, "results", "results.stored", "stored "+string(message))
+	}, nil, errs)
+
+	results, err := producer.ConsumeFromQueue(producerCh, "results")
+	th.Assert(t, err == nil, err)
+	received := make(chan string)
+	go producer.ProcessMessages(ctx, results, func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		return nil
+	}, nil, errs)
+
+	err = producer.PublishToQueue(producerCh, "endpoints", "http://example.com/fhir", 0)
+	th.Assert(t, err == nil, err)
+	msg := receive(t, received)
+	th.Assert(t, msg == "stored response from http://example.com/fhir", fmt.Sprintf("unexpected result %s", msg))
+	th.Assert(t, len(errs) == 0, "expected no errors in the pipeline")
+}
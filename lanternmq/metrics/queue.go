@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	publishedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lanternmq_published_messages_total",
+		Help: "The number of messages published to each queue or exchange.",
+	}, []string{"target"})
+	publishErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lanternmq_publish_errors_total",
+		Help: "The number of messages that failed to be published to each queue or exchange.",
+	}, []string{"target"})
+	consumedMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lanternmq_consumed_messages_total",
+		Help: "The number of messages processed from each queue.",
+	}, []string{"queue"})
+	handlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lanternmq_handler_errors_total",
+		Help: "The number of messages from each queue whose handler returned an error, by whether the message was retried or rejected, or the handler panicked.",
+	}, []string{"queue", "action"})
+	handlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lanternmq_handler_duration_seconds",
+		Help:    "How long the handler took to process each message from each queue.",
+		Buckets: DefaultBuckets,
+	}, []string{"queue"})
+)
+
+func init() {
+	DefaultRegistry.MustRegister(publishedMessages, publishErrors, consumedMessages, handlerErrors, handlerDuration)
+}
+
+// queueCollector reports the number of messages waiting in a queue, counting them each time the metrics are
+// scraped.
+type queueCollector struct {
+	mq    lanternmq.MessageQueue
+	chID  lanternmq.ChannelID
+	qName string
+	desc  *prometheus.Desc
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	count, err := c.mq.CountMessages(c.chID, c.qName)
+	if err != nil {
+		log.Warnf("unable to count the messages in queue %s: %s", c.qName, err.Error())
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
+
+// QueueHooks returns the Hooks that record the lanternmq metrics in the DefaultRegistry. Pass them to
+// lanternmq.Instrument to record the metrics of a MessageQueue.
+func QueueHooks() lanternmq.Hooks {
+	return lanternmq.Hooks{
+		Published: func(target string, count int, err error) {
+			if err != nil {
+				publishErrors.WithLabelValues(target).Add(float64(count))
+				return
+			}
+			publishedMessages.WithLabelValues(target).Add(float64(count))
+		},
+		Handled: func(qName string, duration time.Duration, err error) {
+			consumedMessages.WithLabelValues(qName).Inc()
+			handlerDuration.WithLabelValues(qName).Observe(duration.Seconds())
+			if err != nil {
+				action := "reject"
+				var panicErr *lanternmq.PanicError
+				if lanternmq.IsRetry(err) {
+					action = "retry"
+				} else if errors.As(err, &panicErr) {
+					action = "panic"
+				}
+				handlerErrors.WithLabelValues(qName, action).Inc()
+			}
+		},
+	}
+}
+
+// WatchQueue reports the number of messages waiting in the queue 'qName' each time the DefaultRegistry's
+// metrics are scraped, and adds a health check that fails if the queue can't be found.
+func WatchQueue(mq lanternmq.MessageQueue, chID lanternmq.ChannelID, qName string) {
+	register(&queueCollector{
+		mq:    mq,
+		chID:  chID,
+		qName: qName,
+		desc: prometheus.NewDesc("lanternmq_queue_messages",
+			"The number of messages waiting in each queue.", nil, prometheus.Labels{"queue": qName}),
+	})
+
+	AddHealthCheck("queue "+qName, func() error {
+		exists, err := mq.QueueExists(chID, qName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("queue %s does not exist", qName)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of histograms that measure durations.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// DefaultRegistry is the registry that the metrics of the Lantern services are added to and served from. It
+// includes the Go runtime and process metrics.
+var DefaultRegistry = prometheus.NewRegistry()
+
+func init() {
+	DefaultRegistry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// register adds the collector 'c' to the DefaultRegistry, replacing the collector that reports the same metrics
+// if there is one, eg. when a queue is watched again after reconnecting.
+func register(c prometheus.Collector) {
+	err := DefaultRegistry.Register(c)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		DefaultRegistry.Unregister(registered.ExistingCollector)
+		err = DefaultRegistry.Register(c)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var healthMu sync.Mutex
+var healthChecks = map[string]func() error{}
+
+// AddHealthCheck adds a check, named 'name', that is run each time /healthz is requested. The service is
+// reported as unhealthy if the check returns an error.
+func AddHealthCheck(name string, check func() error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	healthChecks[name] = check
+}
+
+// healthHandler runs the health checks and responds with the result of each. The response status is 503 if any
+// of the checks fail.
+func healthHandler(w http.ResponseWriter, req *http.Request) {
+	healthMu.Lock()
+	names := make([]string, 0, len(healthChecks))
+	checks := make(map[string]func() error, len(healthChecks))
+	for name, check := range healthChecks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	healthMu.Unlock()
+	sort.Strings(names)
+
+	status := http.StatusOK
+	var sb strings.Builder
+	for _, name := range names {
+		err := checks[name]()
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			fmt.Fprintf(&sb, "%s: %s\n", name, err.Error())
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: ok\n", name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, sb.String())
+}
+
+// Handler returns a handler that serves the DefaultRegistry's metrics at /metrics and the result of the health
+// checks at /healthz.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(DefaultRegistry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", healthHandler)
+	return mux
+}
+
+// Serve serves /metrics and /healthz on the address 'addr', eg. ":8081". It only returns if the server fails.
+func Serve(addr string) error {
+	return http.ListenAndServe(addr, Handler())
+}
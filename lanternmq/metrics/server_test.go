@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/memory"
+)
+
+type testPool struct{}
+
+func (p testPool) NumWorkers() int { return 10 }
+func (p testPool) NumBusy() int    { return 4 }
+
+func Test_Handler(t *testing.T) {
+	reset()
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	hooks := QueueHooks()
+	hooks.Published("test-queue", 3, nil)
+	hooks.Handled("test-queue", 20*time.Millisecond, nil)
+	hooks.Handled("test-queue", time.Second, lanternmq.Retry(errors.New("database unavailable")))
+	WatchWorkers("test-pool", testPool{})
+
+	mq := memory.NewMessageQueue()
+	err := mq.Connect("user", "password", "Test_Handler", "5672")
+	th.Assert(t, err == nil, err)
+	defer mq.Close()
+	chID, err := mq.CreateChannel()
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareQueue(chID, "test-queue", 0)
+	th.Assert(t, err == nil, err)
+	err = mq.PurgeQueue(chID, "test-queue")
+	th.Assert(t, err == nil, err)
+	err = mq.PublishBatchToQueue(chID, "test-queue", []string{"one", "two"}, 0)
+	th.Assert(t, err == nil, err)
+	WatchQueue(mq, chID, "test-queue")
+	JobObserver("test-pool")(time.Second, errors.New("timed out"))
+
+	body, status := get(t, server.URL+"/metrics")
+	th.Assert(t, status == http.StatusOK, fmt.Sprintf("expected status 200, got %d", status))
+	for _, expected := range []string{
+		`lanternmq_published_messages_total{target="test-queue"} 3`,
+		`lanternmq_consumed_messages_total{queue="test-queue"} 2`,
+		`lanternmq_handler_errors_total{action="retry",queue="test-queue"} 1`,
+		`lanternmq_handler_duration_seconds_count{queue="test-queue"} 2`,
+		`lanternmq_queue_messages{queue="test-queue"} 2`,
+		`lantern_workers{pool="test-pool"} 10`,
+		`lantern_workers_busy{pool="test-pool"} 4`,
+		`lantern_worker_job_errors_total{pool="test-pool"} 1`,
+		`# TYPE go_goroutines gauge`,
+	} {
+		th.Assert(t, strings.Contains(body, expected+"\n"), fmt.Sprintf("expected the metrics to include %s", expected))
+	}
+
+	AddHealthCheck("database", func() error { return nil })
+	body, status = get(t, server.URL+"/healthz")
+	th.Assert(t, status == http.StatusOK, fmt.Sprintf("expected status 200, got %d", status))
+	th.Assert(t, body == "database: ok\nqueue test-queue: ok\n", fmt.Sprintf("unexpected health check response %s", body))
+
+	AddHealthCheck("workers", func() error { return errors.New("no workers are currently running") })
+	body, status = get(t, server.URL+"/healthz")
+	th.Assert(t, status == http.StatusServiceUnavailable, fmt.Sprintf("expected status 503, got %d", status))
+	th.Assert(t, body == "database: ok\nqueue test-queue: ok\nworkers: no workers are currently running\n", fmt.Sprintf("unexpected health check response %s", body))
+}
+
+func get(t *testing.T, url string) (string, int) {
+	resp, err := http.Get(url)
+	th.Assert(t, err == nil, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	th.Assert(t, err == nil, err)
+	return string(body), resp.StatusCode
+}
+
+// reset clears the recorded metrics and the health checks, so that the test starts without any recorded
+// metrics.
+func reset() {
+	publishedMessages.Reset()
+	publishErrors.Reset()
+	consumedMessages.Reset()
+	handlerErrors.Reset()
+	handlerDuration.Reset()
+	jobDuration.Reset()
+	jobErrors.Reset()
+
+	healthMu.Lock()
+	healthChecks = map[string]func() error{}
+	healthMu.Unlock()
+}
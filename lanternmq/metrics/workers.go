@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lantern_worker_job_duration_seconds",
+		Help:    "How long each job run by each worker pool took.",
+		Buckets: DefaultBuckets,
+	}, []string{"pool"})
+	jobErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lantern_worker_job_errors_total",
+		Help: "The number of jobs run by each worker pool that returned an error.",
+	}, []string{"pool"})
+)
+
+func init() {
+	DefaultRegistry.MustRegister(jobDuration, jobErrors)
+}
+
+// WorkerPool is a pool of workers, such as endpointmanager's workers.Workers, whose utilization can be watched.
+type WorkerPool interface {
+	NumWorkers() int
+	NumBusy() int
+}
+
+// WatchWorkers reports the number of running and busy workers in the worker pool 'w', named 'pool', each time
+// the DefaultRegistry's metrics are scraped.
+func WatchWorkers(pool string, w WorkerPool) {
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "lantern_workers",
+		Help:        "The number of workers running in each worker pool.",
+		ConstLabels: prometheus.Labels{"pool": pool},
+	}, func() float64 { return float64(w.NumWorkers()) }))
+	register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "lantern_workers_busy",
+		Help:        "The number of workers in each worker pool that are running a job.",
+		ConstLabels: prometheus.Labels{"pool": pool},
+	}, func() float64 { return float64(w.NumBusy()) }))
+}
+
+// JobObserver returns a function that records how long a job run by the worker pool 'pool' took and whether it
+// returned an error.
+func JobObserver(pool string) func(time.Duration, error) {
+	return func(duration time.Duration, err error) {
+		jobDuration.WithLabelValues(pool).Observe(duration.Seconds())
+		if err != nil {
+			jobErrors.WithLabelValues(pool).Inc()
+		}
+	}
+}
@@ -3,12 +3,13 @@ package mock
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 )
 
 // BasicMockMessageQueue is a basic implementation of a queue for byte arrays. It only sends and receives
-// on a single queue and ignores things like channel and queue name.
+// on a single queue and ignores things like channel, queue name, exchange name and routing key.
 // It can only handle 20 messages on the queue at a time.
 type BasicMockMessageQueue struct {
 	Queue chan []byte
@@ -16,7 +17,8 @@ type BasicMockMessageQueue struct {
 }
 
 // NewBasicMockMessageQueue initializes a BasicMockMessageQueue.
-// Currently does not initialize any topic related functions.
+// Messages published to an exchange are added to the same queue as messages published to a queue.
+// Currently does not initialize DeclareExchangeReceiveQueue.
 func NewBasicMockMessageQueue() lanternmq.MessageQueue {
 	mq := BasicMockMessageQueue{}
 	mq.Queue = make(chan []byte, 20)
@@ -37,11 +39,22 @@ func NewBasicMockMessageQueue() lanternmq.MessageQueue {
 		return true, nil
 	}
 
-	mq.DeclareQueueFn = func(chID lanternmq.ChannelID, name string) error {
+	mq.DeclareQueueFn = func(chID lanternmq.ChannelID, name string, maxPriority lanternmq.Priority) error {
 		return nil
 	}
 
-	mq.PublishToQueueFn = func(chID lanternmq.ChannelID, qName string, message string) error {
+	mq.CountMessagesFn = func(chID lanternmq.ChannelID, qName string) (int, error) {
+		return len(mq.Queue), nil
+	}
+
+	mq.PurgeQueueFn = func(chID lanternmq.ChannelID, qName string) error {
+		for len(mq.Queue) > 0 {
+			<-mq.Queue
+		}
+		return nil
+	}
+
+	mq.PublishToQueueFn = func(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
 		if len(mq.Queue) < 20 {
 			mq.Queue <- []byte(message)
 		} else {
@@ -50,13 +63,39 @@ func NewBasicMockMessageQueue() lanternmq.MessageQueue {
 		return nil
 	}
 
+	mq.PublishBatchToQueueFn = func(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+		for _, message := range messages {
+			err := mq.PublishToQueueFn(chID, qName, message, priority)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	mq.ConfirmPublishesFn = func(chID lanternmq.ChannelID, timeout time.Duration) error {
+		return nil
+	}
+
+	mq.DeclareExchangeFn = func(chID lanternmq.ChannelID, name string, exchangeType string) error {
+		return nil
+	}
+
+	mq.PublishToExchangeFn = func(chID lanternmq.ChannelID, name string, routingKey string, message string) error {
+		return mq.PublishToQueueFn(chID, name, message, 0)
+	}
+
 	mq.ConsumeFromQueueFn = func(chID lanternmq.ChannelID, qName string) (lanternmq.Messages, error) {
 		return nil, nil
 	}
 
+	mq.ConsumeFromQueueWithRetryFn = func(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+		return mq.ConsumeFromQueueFn(chID, qName)
+	}
+
 	mq.ProcessMessagesFn = func(ctx context.Context, msgs lanternmq.Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error) {
 		for msg := range mq.Queue {
-			err := handler(msg, args)
+			err := lanternmq.Handle(handler, msg, args)
 			if err != nil {
 				errs <- err
 			}
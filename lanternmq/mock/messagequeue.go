@@ -2,6 +2,8 @@ package mock
 
 import (
 	"context"
+	"time"
+
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 )
 
@@ -20,12 +22,22 @@ type MessageQueue struct {
 
 	QueueExistsFn func(chID lanternmq.ChannelID, qName string) (bool, error)
 
-	DeclareQueueFn func(chID lanternmq.ChannelID, name string) error
+	DeclareQueueFn func(chID lanternmq.ChannelID, name string, maxPriority lanternmq.Priority) error
+
+	CountMessagesFn func(chID lanternmq.ChannelID, qName string) (int, error)
+
+	PurgeQueueFn func(chID lanternmq.ChannelID, qName string) error
+
+	PublishToQueueFn func(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error
 
-	PublishToQueueFn func(chID lanternmq.ChannelID, qName string, message string) error
+	PublishBatchToQueueFn func(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error
+
+	ConfirmPublishesFn func(chID lanternmq.ChannelID, timeout time.Duration) error
 
 	ConsumeFromQueueFn func(chID lanternmq.ChannelID, qName string) (lanternmq.Messages, error)
 
+	ConsumeFromQueueWithRetryFn func(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error)
+
 	ProcessMessagesFn func(ctx context.Context, msgs lanternmq.Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error)
 
 	DeclareExchangeFn func(chID lanternmq.ChannelID, name string, exchangeType string) error
@@ -58,13 +70,33 @@ func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (boo
 }
 
 // DeclareQueue mocks lanternmq.DeclareQueue and calls mq.DeclareQueueFn with the given arguments.
-func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, name string) error {
-	return mq.DeclareQueueFn(chID, name)
+func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, name string, maxPriority lanternmq.Priority) error {
+	return mq.DeclareQueueFn(chID, name, maxPriority)
+}
+
+// CountMessages mocks lanternmq.CountMessages and calls mq.CountMessagesFn with the given arguments.
+func (mq *MessageQueue) CountMessages(chID lanternmq.ChannelID, qName string) (int, error) {
+	return mq.CountMessagesFn(chID, qName)
+}
+
+// PurgeQueue mocks lanternmq.PurgeQueue and calls mq.PurgeQueueFn with the given arguments.
+func (mq *MessageQueue) PurgeQueue(chID lanternmq.ChannelID, qName string) error {
+	return mq.PurgeQueueFn(chID, qName)
 }
 
 // PublishToQueue mocks lanternmq.PublishToQueue and calls mq.PublishToQueueFn with the given arguments.
-func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string) error {
-	return mq.PublishToQueueFn(chID, qName, message)
+func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+	return mq.PublishToQueueFn(chID, qName, message, priority)
+}
+
+// PublishBatchToQueue mocks lanternmq.PublishBatchToQueue and calls mq.PublishBatchToQueueFn with the given arguments.
+func (mq *MessageQueue) PublishBatchToQueue(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+	return mq.PublishBatchToQueueFn(chID, qName, messages, priority)
+}
+
+// ConfirmPublishes mocks lanternmq.ConfirmPublishes and calls mq.ConfirmPublishesFn with the given arguments.
+func (mq *MessageQueue) ConfirmPublishes(chID lanternmq.ChannelID, timeout time.Duration) error {
+	return mq.ConfirmPublishesFn(chID, timeout)
 }
 
 // ConsumeFromQueue mocks lanternmq.ConsumeFromQueue and calls mq.ConsumeFromQueueFn with the given arguments.
@@ -72,6 +104,11 @@ func (mq *MessageQueue) ConsumeFromQueue(chID lanternmq.ChannelID, qName string)
 	return mq.ConsumeFromQueueFn(chID, qName)
 }
 
+// ConsumeFromQueueWithRetry mocks lanternmq.ConsumeFromQueueWithRetry and calls mq.ConsumeFromQueueWithRetryFn with the given arguments.
+func (mq *MessageQueue) ConsumeFromQueueWithRetry(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	return mq.ConsumeFromQueueWithRetryFn(chID, qName, policy)
+}
+
 // ProcessMessages mocks lanternmq.ProcessMessages and sets mq.ProcessMessagesInvoked to true and calls mq.ProcessMessagesFn with the given arguments.
 func (mq *MessageQueue) ProcessMessages(ctx context.Context, msgs lanternmq.Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error) {
 	mq.ProcessMessagesFn(ctx, msgs, handler, args, errs)
@@ -3,18 +3,53 @@ package accessqueue
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/postgres"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 	"github.com/pkg/errors"
-	"github.com/streadway/amqp"
+	"github.com/spf13/viper"
 )
 
+// The message queue backends that ConnectToServerAndQueue can connect to. The backend is chosen with the
+// qbackend setting. The in-memory message queue isn't one of them, since it only connects code that runs in the
+// same process, and each service runs in its own process.
+const (
+	RabbitMQ = "rabbitmq"
+	Postgres = "postgres"
+)
+
+// NewMessageQueue returns a lanternmq.MessageQueue for the given backend that has not connected yet. The
+// Postgres backend uses the database named by the dbname setting, with the dbsslmode setting.
+func NewMessageQueue(backend string) (lanternmq.MessageQueue, error) {
+	switch backend {
+	case RabbitMQ, "":
+		return &rabbitmq.MessageQueue{}, nil
+	case Postgres:
+		return postgres.NewMessageQueue(viper.GetString("dbname"), viper.GetString("dbsslmode")), nil
+	}
+	return nil, fmt.Errorf("unknown message queue backend %s", backend)
+}
+
 // ConnectToServerAndQueue creates a connection to an exchange at the given location with the given credentials.
-// then connects to the queue with the given queue name
+// then connects to the queue with the given queue name. It connects to the backend chosen with the qbackend
+// setting, which is RabbitMQ by default. The Postgres backend keeps its queues in the Lantern database, so it
+// connects with the dbhost, dbport, dbuser and dbpassword settings instead of the given location and
+// credentials.
 func ConnectToServerAndQueue(qUser, qPassword, qHost, qPort, qName string) (lanternmq.MessageQueue, lanternmq.ChannelID, error) {
-	mq := &rabbitmq.MessageQueue{}
-	err := mq.Connect(qUser, qPassword, qHost, qPort)
+	backend := viper.GetString("qbackend")
+	mq, err := NewMessageQueue(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	if backend == Postgres {
+		qUser = viper.GetString("dbuser")
+		qPassword = viper.GetString("dbpassword")
+		qHost = viper.GetString("dbhost")
+		qPort = viper.GetString("dbport")
+	}
+	err = mq.Connect(qUser, qPassword, qHost, qPort)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -38,13 +73,14 @@ func ConnectToQueue(mq lanternmq.MessageQueue, ch lanternmq.ChannelID, qName str
 	return mq, ch, nil
 }
 
-// SendToQueue publishes a message to the given queue
+// SendToQueue publishes a message to the given queue with the given priority
 func SendToQueue(
 	ctx context.Context,
 	message string,
 	mq *lanternmq.MessageQueue,
 	ch *lanternmq.ChannelID,
-	queueName string) error {
+	queueName string,
+	priority lanternmq.Priority) error {
 
 	// don't send the message if the context is done
 	select {
@@ -54,7 +90,7 @@ func SendToQueue(
 		// ok
 	}
 
-	err := (*mq).PublishToQueue(*ch, queueName, message)
+	err := (*mq).PublishToQueue(*ch, queueName, message, priority)
 	if err != nil {
 		return err
 	}
@@ -62,15 +98,80 @@ func SendToQueue(
 	return nil
 }
 
-// CleanQueue purges the messages in the given channel and then counts to make
+// SendBatchToQueue publishes the given messages to the given queue together with the given priority, so that if
+// the channel is in confirm mode they are confirmed together rather than one at a time
+func SendBatchToQueue(
+	ctx context.Context,
+	messages []string,
+	mq *lanternmq.MessageQueue,
+	ch *lanternmq.ChannelID,
+	queueName string,
+	priority lanternmq.Priority) error {
+
+	// don't send the messages if the context is done
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "unable to send messages to queue - context ended")
+	default:
+		// ok
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err := (*mq).PublishBatchToQueue(*ch, queueName, messages, priority)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConfirmPublishes puts the given channel in confirm mode, so that publishing a message waits up to the given
+// number of seconds for the queuing service to confirm it. If the number of seconds is not positive, the channel
+// is left as it is and messages are published without waiting for confirmation.
+func ConfirmPublishes(mq lanternmq.MessageQueue, ch lanternmq.ChannelID, timeoutSeconds int) error {
+	if timeoutSeconds <= 0 {
+		return nil
+	}
+	return mq.ConfirmPublishes(ch, time.Duration(timeoutSeconds)*time.Second)
+}
+
+// SendToExchange publishes a message to the given exchange with the given routing key
+func SendToExchange(
+	ctx context.Context,
+	message string,
+	mq *lanternmq.MessageQueue,
+	ch *lanternmq.ChannelID,
+	exchangeName string,
+	routingKey string) error {
+
+	// don't send the message if the context is done
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "unable to send message to exchange - context ended")
+	default:
+		// ok
+	}
+
+	err := (*mq).PublishToExchange(*ch, exchangeName, routingKey, message)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CleanQueue purges the messages in the given queue and then counts to make
 // sure no messages are left
-func CleanQueue(queueName string, channel *amqp.Channel) error {
-	_, err := channel.QueuePurge(queueName, false)
+func CleanQueue(queueName string, mq lanternmq.MessageQueue, ch lanternmq.ChannelID) error {
+	err := mq.PurgeQueue(ch, queueName)
 	if err != nil {
 		return err
 	}
 
-	count, err := QueueCount(queueName, channel)
+	count, err := QueueCount(queueName, mq, ch)
 	if err != nil {
 		return err
 	}
@@ -82,11 +183,6 @@ func CleanQueue(queueName string, channel *amqp.Channel) error {
 }
 
 // QueueCount counts how many messages are currently in the queue
-func QueueCount(queueName string, channel *amqp.Channel) (int, error) {
-	queue, err := channel.QueueInspect(queueName)
-	if err != nil {
-		return -1, err
-	}
-
-	return queue.Messages, nil
+func QueueCount(queueName string, mq lanternmq.MessageQueue, ch lanternmq.ChannelID) (int, error) {
+	return mq.CountMessages(ch, queueName)
 }
@@ -15,14 +15,13 @@ import (
 	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
-	"github.com/streadway/amqp"
 )
 
 var qUser, qPassword, qHost, qPort, qName string
 
 var mq *lanternmq.MessageQueue
-var conn *amqp.Connection
-var channel *amqp.Channel
+var conn lanternmq.MessageQueue
+var channel lanternmq.ChannelID
 
 func TestMain(m *testing.M) {
 	var err error
@@ -51,7 +50,7 @@ func TestMain(m *testing.M) {
 
 func Test_ConnectToServerAndQueue(t *testing.T) {
 	queueIsEmpty(t, qName)
-	defer checkCleanQueue(t, qName, channel)
+	defer checkCleanQueue(t, qName)
 
 	var chID lanternmq.ChannelID
 	var err error
@@ -86,7 +85,7 @@ func Test_ConnectToServerAndQueue(t *testing.T) {
 
 func Test_ConnectToQueue(t *testing.T) {
 	queueIsEmpty(t, qName)
-	defer checkCleanQueue(t, qName, channel)
+	defer checkCleanQueue(t, qName)
 
 	var err error
 
@@ -114,7 +113,7 @@ func Test_ConnectToQueue(t *testing.T) {
 
 func Test_CleanQueue(t *testing.T) {
 	queueIsEmpty(t, qName)
-	defer checkCleanQueue(t, qName, channel)
+	defer checkCleanQueue(t, qName)
 
 	var err error
 
@@ -132,21 +131,16 @@ func Test_CleanQueue(t *testing.T) {
 
 	// add message to queue then clean
 	ctx := context.Background()
-	err = aq.SendToQueue(ctx, "clean queue message", mq, &ch, qName)
+	err = aq.SendToQueue(ctx, "clean queue message", mq, &ch, qName, 0)
 	th.Assert(t, err == nil, err)
 
-	// ack the message so it will get purged
-	_, _, err = channel.Get(qName, true)
-	th.Assert(t, err == nil, err)
-
-	err = aq.CleanQueue(qName, channel)
+	err = aq.CleanQueue(qName, conn, channel)
 	th.Assert(t, err == nil, err)
 }
 
-
 func Test_QueueCount(t *testing.T) {
 	queueIsEmpty(t, qName)
-	defer checkCleanQueue(t, qName, channel)
+	defer checkCleanQueue(t, qName)
 
 	var err error
 
@@ -163,36 +157,112 @@ func Test_QueueCount(t *testing.T) {
 	th.Assert(t, err == nil, err)
 
 	// base test
-	count, err := aq.QueueCount(qName, channel)
+	count, err := aq.QueueCount(qName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 0, "there should be no messages in the queue")
 
 	// add message to queue
 	ctx := context.Background()
-	err = aq.SendToQueue(ctx, "queue count message", mq, &ch, qName)
+	err = aq.SendToQueue(ctx, "queue count message", mq, &ch, qName, 0)
 	th.Assert(t, err == nil, err)
 
 	// Need to pause to ensure message is placed on the queue before calling QueueCount
 	time.Sleep(20 * time.Second)
 
-	count, err = aq.QueueCount(qName, channel)
+	count, err = aq.QueueCount(qName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 1, fmt.Sprintf("there should be one message in the queue, instead there are %d", count))
+}
+
+func Test_ChannelRecovery(t *testing.T) {
+	queueIsEmpty(t, qName)
+	defer checkCleanQueue(t, qName)
+
+	var err error
+
+	// set up
+	mq2 := &rabbitmq.MessageQueue{}
+	err = mq2.Connect(qUser, qPassword, qHost, qPort)
+	th.Assert(t, err == nil, "unable to connect to message queue server")
+	defer mq2.Close()
+	ch, err := mq2.CreateChannel()
+	th.Assert(t, err == nil, "unable to create channel to message queue server")
+
+	mq_, _, err := aq.ConnectToQueue(mq2, ch, qName)
+	mq = &mq_
+	th.Assert(t, err == nil, err)
+	ctx := context.Background()
+
+	// checking for a missing queue does not close the channel
+	exists, err := mq2.QueueExists(ch, "nonsense")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, !exists, "queue nonsense should not exist")
+	err = aq.SendToQueue(ctx, "queue exists message", mq, &ch, qName, 0)
+	th.Assert(t, err == nil, err)
+
+	// publishing to a missing exchange makes RabbitMQ close the channel, which is then reopened
+	err = aq.SendToExchange(ctx, "missing exchange message", mq, &ch, "nonsense", "nonsense")
+	th.Assert(t, err == nil, err)
+	time.Sleep(5 * time.Second)
+	err = aq.SendToQueue(ctx, "channel recovery message", mq, &ch, qName, 0)
+	th.Assert(t, err == nil, err)
+
+	// Need to pause to ensure messages are placed on the queue before calling QueueCount
+	time.Sleep(20 * time.Second)
+
+	count, err := aq.QueueCount(qName, conn, channel)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 2, fmt.Sprintf("there should be two messages in the queue, instead there are %d", count))
+}
+
+func Test_ConfirmPublishes(t *testing.T) {
+	queueIsEmpty(t, qName)
+	defer checkCleanQueue(t, qName)
+
+	var err error
+
+	// set up
+	mq2 := &rabbitmq.MessageQueue{}
+	err = mq2.Connect(qUser, qPassword, qHost, qPort)
+	th.Assert(t, err == nil, "unable to connect to message queue server")
+	defer mq2.Close()
+	ch, err := mq2.CreateChannel()
+	th.Assert(t, err == nil, "unable to create channel to message queue server")
+
+	mq_, _, err := aq.ConnectToQueue(mq2, ch, qName)
+	mq = &mq_
+	th.Assert(t, err == nil, err)
+	err = aq.ConfirmPublishes(mq_, ch, 10)
+	th.Assert(t, err == nil, err)
+	ctx := context.Background()
+
+	// confirmed messages are already on the queue when publishing returns
+	err = aq.SendToQueue(ctx, "confirmed message", mq, &ch, qName, 0)
+	th.Assert(t, err == nil, err)
+	err = aq.SendBatchToQueue(ctx, []string{"batch message 1", "batch message 2"}, mq, &ch, qName, 0)
+	th.Assert(t, err == nil, err)
+
+	count, err := aq.QueueCount(qName, conn, channel)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 3, fmt.Sprintf("there should be three messages in the queue, instead there are %d", count))
+
+	// messages to a missing queue are returned as unroutable
+	err = aq.SendToQueue(ctx, "unroutable message", mq, &ch, "nonsense", 0)
+	th.Assert(t, err != nil, "expected an error publishing to a missing queue")
 
-	// ack the message
-	_, _, err = channel.Get(qName, true)
+	// the channel can still be published to
+	err = aq.SendToQueue(ctx, "confirmed message", mq, &ch, qName, 0)
 	th.Assert(t, err == nil, err)
-		
 }
 
 func queueIsEmpty(t *testing.T, queueName string) {
-	count, err := aq.QueueCount(queueName, channel)
+	count, err := aq.QueueCount(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 	th.Assert(t, count == 0, "should be no messages in queue.")
 }
 
-func checkCleanQueue(t *testing.T, queueName string, channel *amqp.Channel) {
-	err := aq.CleanQueue(queueName, channel)
+func checkCleanQueue(t *testing.T, queueName string) {
+	err := aq.CleanQueue(queueName, conn, channel)
 	th.Assert(t, err == nil, err)
 }
 
@@ -205,15 +275,14 @@ func setup() error {
 	qPort = viper.GetString("qport")
 	qName = viper.GetString("qname")
 
-	fmt.Printf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
 	// setup specific queue info so we can test what's in the queue
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", qUser, qPassword, qHost, qPort)
-	conn, err = amqp.Dial(s)
+	conn = &rabbitmq.MessageQueue{}
+	err = conn.Connect(qUser, qPassword, qHost, qPort)
 	if err != nil {
 		return err
 	}
 
-	channel, err = conn.Channel()
+	channel, err = conn.CreateChannel()
 	if err != nil {
 		return err
 	}
@@ -275,6 +344,5 @@ func setupConfigForTests() error {
 
 func teardown() {
 	(*mq).Close()
-	channel.Close()
 	conn.Close()
 }
@@ -3,10 +3,13 @@ package accessqueue
 import (
 	"context"
 	"testing"
+	"time"
 
 	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	"github.com/onc-healthit/lantern-back-end/lanternmq/mock"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/postgres"
+	"github.com/onc-healthit/lantern-back-end/lanternmq/rabbitmq"
 	"github.com/pkg/errors"
 )
 
@@ -24,7 +27,7 @@ func Test_SendToQueue(t *testing.T) {
 
 	ctx = context.Background()
 
-	err = SendToQueue(ctx, message, &mq, &ch, queueName)
+	err = SendToQueue(ctx, message, &mq, &ch, queueName, 0)
 	th.Assert(t, err == nil, err)
 
 	th.Assert(t, len(mq.(*mock.BasicMockMessageQueue).Queue) == 1, "expected a message to be in the queue")
@@ -37,6 +40,146 @@ func Test_SendToQueue(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	err = SendToQueue(ctx, message, &mq, &ch, queueName)
+	err = SendToQueue(ctx, message, &mq, &ch, queueName, 0)
 	th.Assert(t, errors.Cause(err) == context.Canceled, "expected persistProducts to error out due to context ending")
 }
+
+func Test_SendBatchToQueue(t *testing.T) {
+	var ch lanternmq.ChannelID
+	var ctx context.Context
+	var err error
+
+	messages := []string{"message one", "message two"}
+	mq := mock.NewBasicMockMessageQueue()
+	ch = 1
+	queueName := "queue name"
+
+	// basic test
+
+	ctx = context.Background()
+
+	err = SendBatchToQueue(ctx, messages, &mq, &ch, queueName, 0)
+	th.Assert(t, err == nil, err)
+
+	th.Assert(t, len(mq.(*mock.BasicMockMessageQueue).Queue) == 2, "expected two messages to be in the queue")
+
+	for _, message := range messages {
+		rcvMsg := string(<-mq.(*mock.BasicMockMessageQueue).Queue)
+		th.Assert(t, rcvMsg == message, "expected the recieved messages to be the same as the sent messages, in order.")
+	}
+
+	// test publishing error
+
+	mq.(*mock.BasicMockMessageQueue).PublishBatchToQueueFn = func(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+		return errors.New("returned as unroutable")
+	}
+	err = SendBatchToQueue(ctx, messages, &mq, &ch, queueName, 0)
+	th.Assert(t, err != nil && err.Error() == "returned as unroutable", "expected the publishing error to be returned")
+
+	// test context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = SendBatchToQueue(ctx, messages, &mq, &ch, queueName, 0)
+	th.Assert(t, errors.Cause(err) == context.Canceled, "expected SendBatchToQueue to error out due to context ending")
+}
+
+func Test_ConfirmPublishes(t *testing.T) {
+	var ch lanternmq.ChannelID = 1
+	var timeout time.Duration
+
+	mq := mock.NewBasicMockMessageQueue()
+	mq.(*mock.BasicMockMessageQueue).ConfirmPublishesFn = func(chID lanternmq.ChannelID, t time.Duration) error {
+		timeout = t
+		return nil
+	}
+
+	// confirms are off
+	err := ConfirmPublishes(mq, ch, 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, timeout == 0, "expected the channel not to be put in confirm mode")
+
+	err = ConfirmPublishes(mq, ch, 5)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, timeout == 5*time.Second, "expected the channel to be put in confirm mode with a 5 second timeout")
+}
+
+func Test_SendToExchange(t *testing.T) {
+	var ch lanternmq.ChannelID
+	var ctx context.Context
+	var err error
+
+	message := "this is a message"
+	mq := mock.NewBasicMockMessageQueue()
+	ch = 1
+	exchangeName := "exchange name"
+	routingKey := "routing.key"
+
+	// basic test
+
+	ctx = context.Background()
+
+	err = SendToExchange(ctx, message, &mq, &ch, exchangeName, routingKey)
+	th.Assert(t, err == nil, err)
+
+	th.Assert(t, len(mq.(*mock.BasicMockMessageQueue).Queue) == 1, "expected a message to be in the queue")
+
+	bRcvMsg := <-mq.(*mock.BasicMockMessageQueue).Queue
+	rcvMsg := string(bRcvMsg)
+	th.Assert(t, rcvMsg == message, "expected the recieved message to be the same as the sent message.")
+
+	// test context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = SendToExchange(ctx, message, &mq, &ch, exchangeName, routingKey)
+	th.Assert(t, errors.Cause(err) == context.Canceled, "expected SendToExchange to error out due to context ending")
+}
+
+func Test_CleanQueue(t *testing.T) {
+	var ch lanternmq.ChannelID = 1
+	queueName := "queue name"
+
+	mq := mock.NewBasicMockMessageQueue()
+	err := mq.PublishToQueue(ch, queueName, "message", 0)
+	th.Assert(t, err == nil, err)
+
+	count, err := QueueCount(queueName, mq, ch)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 1, "expected there to be one message in the queue")
+
+	err = CleanQueue(queueName, mq, ch)
+	th.Assert(t, err == nil, err)
+
+	count, err = QueueCount(queueName, mq, ch)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, "expected the queue to be empty")
+
+	// messages left after purging
+	mq.(*mock.BasicMockMessageQueue).PurgeQueueFn = func(chID lanternmq.ChannelID, qName string) error {
+		return nil
+	}
+	err = mq.PublishToQueue(ch, queueName, "message", 0)
+	th.Assert(t, err == nil, err)
+	err = CleanQueue(queueName, mq, ch)
+	th.Assert(t, err != nil, "expected an error because a message was left in the queue")
+}
+
+func Test_NewMessageQueue(t *testing.T) {
+	mq, err := NewMessageQueue("")
+	th.Assert(t, err == nil, err)
+	_, ok := mq.(*rabbitmq.MessageQueue)
+	th.Assert(t, ok, "expected RabbitMQ to be the default backend")
+
+	mq, err = NewMessageQueue(Postgres)
+	th.Assert(t, err == nil, err)
+	_, ok = mq.(*postgres.MessageQueue)
+	th.Assert(t, ok, "expected a Postgres message queue")
+
+	_, err = NewMessageQueue("nats")
+	th.Assert(t, err != nil, "expected an error for an unknown backend")
+
+	// the in-memory message queue can't connect separate services
+	_, err = NewMessageQueue("memory")
+	th.Assert(t, err != nil, "expected an error for the in-memory backend")
+}
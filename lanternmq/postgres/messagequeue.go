@@ -0,0 +1,833 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+)
+
+// Ensure MessageQueue implements lanternmq.MessageQueue.
+var _ lanternmq.MessageQueue = &MessageQueue{}
+
+// Ensure Messages implements lanternmq.Messages.
+var _ lanternmq.Messages = &Messages{}
+
+// The exchange types supported by the Postgres implementation
+const (
+	exchangeDirect = "direct"
+	exchangeFanout = "fanout"
+	exchangeTopic  = "topic"
+)
+
+// notifyChannel is the Postgres notification channel that consumers are told about new messages on. The payload
+// of each notification is the name of the queue the messages were added to.
+const notifyChannel = "lanternmq_messages"
+
+// pollInterval is how often a consumer checks its queue when it hasn't been notified of new messages. This picks
+// up retried messages once their delay has passed, messages whose lease has expired, and messages whose
+// notification was missed while the listener was reconnecting.
+var pollInterval = 5 * time.Second
+
+// leaseDuration is how long a consumer holds a message it has taken before other consumers can take it. The
+// lease is renewed every third of leaseDuration while the message is processed, so it only expires if the
+// consumer stops.
+var leaseDuration = time.Minute
+
+// MessageQueue is an implementation of the lanternmq.MessageQueue interface that keeps its queues in tables in
+// a Postgres database, so that deployments can run without RabbitMQ. The tables are created by the database
+// migrations. Each message is a row in the lanternmq_messages table. A consumer takes the oldest available
+// message with the highest priority in its queue by setting the row's locked_until lease, and commits that
+// before processing the message, so that no transaction or row lock is held while the handler runs. Other
+// consumers skip the message until its lease expires. The row is deleted once the message is acknowledged. If
+// the consumer stops before finishing, the lease expires and the message is delivered again.
+//
+// It supports:
+// * queues and priority queues. Messages published to a queue that doesn't exist are dropped, or cause an
+// error if the channel is in confirm mode.
+// * 'direct', 'fanout' and 'topic' exchanges, and queues bound to them with DeclareExchangeReceiveQueue. The
+// bound queues are deleted when the MessageQueue that created them is closed.
+// * acknowledging, requeuing, retrying and dead lettering messages in ProcessMessages, in the same way as the
+// RabbitMQ implementation. Retried messages wait in the policy's retry queue until their delay has passed.
+//
+// Consumers are woken up by Postgres notifications when messages are published, and otherwise check their
+// queue every pollInterval.
+type MessageQueue struct {
+	DBName  string
+	SSLMode string
+
+	db        *sql.DB
+	listener  *pq.Listener
+	channels  []*channel
+	consumers []*Messages
+	exclusive []string
+	done      chan struct{}
+	mu        sync.Mutex
+}
+
+// channel holds the settings of a channel.
+type channel struct {
+	confirm bool
+}
+
+// Messages holds the queue a consumer receives messages from, the queue's maximum priority and how the messages
+// are handled. 'wake' is signalled when messages are published to the queue.
+type Messages struct {
+	qName       string
+	maxPriority lanternmq.Priority
+	c           *channel
+	policy      *lanternmq.RetryPolicy
+	wake        chan struct{}
+	done        chan struct{}
+}
+
+// NewMessageQueue returns a MessageQueue that connects to the database 'dbName' with the SSL mode 'sslMode'.
+func NewMessageQueue(dbName string, sslMode string) lanternmq.MessageQueue {
+	return &MessageQueue{DBName: dbName, SSLMode: sslMode}
+}
+
+// getChannel returns the database the MessageQueue is connected to and the channel with ID `id`.
+func (mq *MessageQueue) getChannel(id lanternmq.ChannelID) (*sql.DB, *channel, error) {
+	idInt, ok := id.(int)
+	if !ok {
+		return nil, nil, errors.New("ChannelID not of correct type")
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	if idInt < 0 || idInt >= len(mq.channels) {
+		return nil, nil, errors.New("no channel with the requested ID was found")
+	}
+	return mq.db, mq.channels[idInt], nil
+}
+
+// Connect connects to the Postgres server at the given location with the given credentials, and listens for
+// notifications of published messages.
+func (mq *MessageQueue) Connect(username string, password string, host string, port string) error {
+	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
+		"password=%s dbname=%s sslmode=%s",
+		host, port, username, password, mq.DBName, mq.SSLMode)
+
+	db, err := sql.Open("postgres", psqlInfo)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %s", err.Error())
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("unable to connect to database: %s", err.Error())
+	}
+
+	listener := pq.NewListener(psqlInfo, 500*time.Millisecond, 30*time.Second, nil)
+	err = listener.Listen(notifyChannel)
+	if err != nil {
+		listener.Close()
+		db.Close()
+		return fmt.Errorf("unable to listen for published messages: %s", err.Error())
+	}
+
+	done := make(chan struct{})
+
+	mq.mu.Lock()
+	mq.db = db
+	mq.listener = listener
+	mq.done = done
+	mq.mu.Unlock()
+
+	go mq.wakeConsumers(listener, done)
+
+	return nil
+}
+
+// wakeConsumers signals the consumers of each queue that messages are published to, until the MessageQueue is
+// closed. When the listener reconnects, every consumer is signalled in case notifications were missed.
+func (mq *MessageQueue) wakeConsumers(listener *pq.Listener, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case n := <-listener.Notify:
+			mq.mu.Lock()
+			for _, msgs := range mq.consumers {
+				if n != nil && n.Extra != msgs.qName {
+					continue
+				}
+				select {
+				case msgs.wake <- struct{}{}:
+				default:
+					// the consumer has already been signalled
+				}
+			}
+			mq.mu.Unlock()
+		}
+	}
+}
+
+// CreateChannel creates a channel to the database that has already been connected to. If the database has not
+// been connected to already, an error is thrown. The channel's ID is returned.
+func (mq *MessageQueue) CreateChannel() (lanternmq.ChannelID, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.db == nil {
+		return "", errors.New("connection must exist before creating a channel")
+	}
+	mq.channels = append(mq.channels, &channel{})
+	return lanternmq.ChannelID(len(mq.channels) - 1), nil
+}
+
+// NumConcurrentMsgs checks that the channel exists. A consumer only takes a message from the database once it
+// has finished processing the previous one, so there is nothing to limit.
+func (mq *MessageQueue) NumConcurrentMsgs(chID lanternmq.ChannelID, num int) error {
+	_, _, err := mq.getChannel(chID)
+	return err
+}
+
+// QueueExists checks whether or not a queue already exists.
+func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (bool, error) {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM lanternmq_queues WHERE name = $1)", qName).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("unable to check if queue %s exists: %s", qName, err.Error())
+	}
+	return exists, nil
+}
+
+// DeclareQueue creates a queue with the given name and maximum priority if one does not exist. As with RabbitMQ,
+// it returns an error if the queue exists with a different maximum priority.
+func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, qName string, maxPriority lanternmq.Priority) error {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO lanternmq_queues (name, max_priority) VALUES ($1, $2) ON CONFLICT DO NOTHING", qName, maxPriority)
+	if err != nil {
+		return fmt.Errorf("unable to declare queue %s: %s", qName, err.Error())
+	}
+
+	var existing lanternmq.Priority
+	err = db.QueryRow("SELECT max_priority FROM lanternmq_queues WHERE name = $1", qName).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("unable to declare queue %s: %s", qName, err.Error())
+	}
+	if existing != maxPriority {
+		return fmt.Errorf("unable to declare queue %s: queue already exists with maximum priority %d", qName, existing)
+	}
+	return nil
+}
+
+// CountMessages returns how many messages are in the queue with name 'qName', including messages that are being
+// processed.
+func (mq *MessageQueue) CountMessages(chID lanternmq.ChannelID, qName string) (int, error) {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return -1, err
+	}
+
+	var count int
+	err = db.QueryRow(`
+		SELECT COUNT(m.id) FROM lanternmq_queues q
+		LEFT JOIN lanternmq_messages m ON m.queue = q.name
+		WHERE q.name = $1
+		GROUP BY q.name`, qName).Scan(&count)
+	if err == sql.ErrNoRows {
+		return -1, fmt.Errorf("unable to count messages in queue %s: queue does not exist", qName)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("unable to count messages in queue %s: %s", qName, err.Error())
+	}
+	return count, nil
+}
+
+// PurgeQueue removes the messages in the queue with name 'qName' that are not being processed.
+func (mq *MessageQueue) PurgeQueue(chID lanternmq.ChannelID, qName string) error {
+	exists, err := mq.QueueExists(chID, qName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("unable to purge queue %s: queue does not exist", qName)
+	}
+
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		DELETE FROM lanternmq_messages WHERE id IN (
+			SELECT id FROM lanternmq_messages WHERE queue = $1 FOR UPDATE SKIP LOCKED
+		)`, qName)
+	if err != nil {
+		return fmt.Errorf("unable to purge queue %s: %s", qName, err.Error())
+	}
+	return nil
+}
+
+// PublishToQueue adds 'message' to the queue with name 'qName' with the priority 'priority'.
+func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+	return mq.PublishBatchToQueue(chID, qName, []string{message}, priority)
+}
+
+// PublishBatchToQueue adds each of 'messages' to the queue with name 'qName' with the priority 'priority' in one
+// transaction. If the queue doesn't exist, the messages are dropped, or if the channel is in confirm mode, an
+// error is returned.
+func (mq *MessageQueue) PublishBatchToQueue(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+	db, c, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to publish to queue %s: %s", qName, err.Error())
+	}
+	published, err := insertMessages(tx, qName, messages, priority)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("unable to publish to queue %s: %s", qName, err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to publish to queue %s: %s", qName, err.Error())
+	}
+
+	mq.mu.Lock()
+	confirm := c.confirm
+	mq.mu.Unlock()
+	if !published && confirm {
+		return fmt.Errorf("%d published messages were returned as unroutable: %s NO_ROUTE", len(messages), qName)
+	}
+	return nil
+}
+
+// insertMessages adds 'messages' with the priority 'priority' to the queue with name 'qName' in the transaction
+// 'tx' and notifies the queue's consumers once the transaction is committed. It returns false if the queue
+// doesn't exist.
+func insertMessages(tx *sql.Tx, qName string, messages []string, priority lanternmq.Priority) (bool, error) {
+	bodies := make(pq.ByteaArray, len(messages))
+	for i, message := range messages {
+		bodies[i] = []byte(message)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO lanternmq_messages (queue, body, priority)
+		SELECT q.name, m.body, $3 FROM lanternmq_queues q, unnest($2::bytea[]) WITH ORDINALITY AS m(body, n)
+		WHERE q.name = $1
+		ORDER BY m.n`, qName, bodies, priority)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec("SELECT pg_notify($1, $2)", notifyChannel, qName)
+	return true, err
+}
+
+// ConfirmPublishes puts the channel in confirm mode, so that publishing to a queue that doesn't exist returns an
+// error. Messages are in the database once they have been published, so there is nothing to wait for.
+func (mq *MessageQueue) ConfirmPublishes(chID lanternmq.ChannelID, timeout time.Duration) error {
+	_, c, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	c.confirm = true
+	return nil
+}
+
+// ConsumeFromQueue returns the Messages for receiving the messages in the queue with name 'qName'.
+func (mq *MessageQueue) ConsumeFromQueue(chID lanternmq.ChannelID, qName string) (lanternmq.Messages, error) {
+	return mq.consumeFromQueue(chID, qName, nil)
+}
+
+// ConsumeFromQueueWithRetry returns the Messages for receiving the messages in the queue with name 'qName'. When
+// the messages are processed with ProcessMessages, they are retried or dead lettered according to 'policy'.
+func (mq *MessageQueue) ConsumeFromQueueWithRetry(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	if policy.Delay > 0 && policy.RetryQueue == "" {
+		return nil, errors.New("a retry queue is required to retry messages after a delay")
+	}
+	for _, name := range []string{policy.RetryQueue, policy.DeadLetterQueue} {
+		if name == "" {
+			continue
+		}
+		exists, err := mq.QueueExists(chID, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("queue %s does not exist", name)
+		}
+	}
+	return mq.consumeFromQueue(chID, qName, &policy)
+}
+
+// consumeFromQueue returns the Messages for the queue with name 'qName', whose messages are handled according to
+// 'policy', which is nil if the consumer has no retry policy.
+func (mq *MessageQueue) consumeFromQueue(chID lanternmq.ChannelID, qName string, policy *lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	db, c, err := mq.getChannel(chID)
+	if err != nil {
+		return nil, err
+	}
+	var maxPriority lanternmq.Priority
+	err = db.QueryRow("SELECT max_priority FROM lanternmq_queues WHERE name = $1", qName).Scan(&maxPriority)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("queue %s does not exist", qName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	msgs := &Messages{
+		qName:       qName,
+		maxPriority: maxPriority,
+		c:           c,
+		policy:      policy,
+		wake:        make(chan struct{}, 1),
+		done:        mq.done,
+	}
+	mq.consumers = append(mq.consumers, msgs)
+
+	return msgs, nil
+}
+
+// ProcessMessages takes each message in the queue that 'msgs' receives from and provides it along with 'args' to
+// the lanternmq.MessageHandler 'handler'. The message is then acknowledged, retried or rejected according to the
+// error the handler returns, in the same way as the RabbitMQ implementation. Errors from the handler or from
+// receiving and settling the message are sent to the 'errs' channel. ProcessMessages returns when the context is
+// done or the MessageQueue is closed.
+func (mq *MessageQueue) ProcessMessages(ctx context.Context, msgs lanternmq.Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error) {
+	msgsd, ok := msgs.(*Messages)
+	if !ok {
+		errs <- errors.New("the messages are of the wrong type")
+		return
+	}
+
+	mq.mu.Lock()
+	db := mq.db
+	mq.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-msgsd.done:
+			return
+		default:
+			// ok
+		}
+
+		received, err := processNext(ctx, db, msgsd, handler, args, errs)
+		if err != nil {
+			errs <- err
+		}
+		if received && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-msgsd.done:
+			return
+		case <-msgsd.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// processNext takes the oldest available message with the highest priority in the queue that 'msgs' receives
+// from by giving it a lease, and processes it with 'handler'. Priorities above the queue's maximum priority are
+// treated as the maximum, so all of the messages in a queue that isn't a priority queue have the same
+// priority. The lease is committed before the handler runs and renewed until the message is settled. If the
+// lease was lost in the meantime, the message isn't settled, since it has been delivered again. It returns
+// false if there was no message to process.
+func processNext(ctx context.Context, db *sql.DB, msgs *Messages, handler lanternmq.MessageHandler, args *map[string]interface{}, errs chan<- error) (bool, error) {
+	err := returnRetries(db, msgs)
+	if err != nil {
+		return false, err
+	}
+
+	var id int64
+	var body []byte
+	var attempts int
+	var lockedUntil time.Time
+	err = db.QueryRow(`
+		UPDATE lanternmq_messages SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id = (
+			SELECT id FROM lanternmq_messages
+			WHERE queue = $1 AND available_at <= NOW() AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY LEAST(priority, $2) DESC, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, body, attempts, locked_until`,
+		msgs.qName, msgs.maxPriority, leaseDuration.Milliseconds()).Scan(&id, &body, &attempts, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to receive message from queue %s: %s", msgs.qName, err.Error())
+	}
+
+	select {
+	case <-ctx.Done():
+		// leave the message for the next consumer
+		return false, releaseLease(db, msgs, id, lockedUntil)
+	default:
+		// ok
+	}
+
+	stopRenewing := make(chan struct{})
+	renewed := make(chan time.Time, 1)
+	go func() {
+		renewed <- renewLease(db, id, lockedUntil, stopRenewing)
+	}()
+	handlerErr := lanternmq.Handle(handler, body, args)
+	close(stopRenewing)
+	lockedUntil = <-renewed
+	if handlerErr != nil {
+		errs <- handlerErr
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return true, fmt.Errorf("unable to settle message from queue %s: %s", msgs.qName, err.Error())
+	}
+	var leased bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM lanternmq_messages WHERE id = $1 AND locked_until = $2 FOR UPDATE)`,
+		id, lockedUntil).Scan(&leased)
+	if err == nil && !leased {
+		err = errors.New("its lease expired and it was delivered again")
+	}
+	if err == nil {
+		err = settle(tx, msgs, id, attempts, handlerErr)
+	}
+	if err != nil {
+		tx.Rollback()
+		return true, fmt.Errorf("unable to settle message from queue %s: %s", msgs.qName, err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return true, fmt.Errorf("unable to settle message from queue %s: %s", msgs.qName, err.Error())
+	}
+	return true, nil
+}
+
+// renewLease extends the lease on the message with ID 'id', which lasts until 'lockedUntil', every third of
+// leaseDuration until 'stop' is closed, and returns when the lease lasts until. Renewing stops if the lease was
+// lost.
+func renewLease(db *sql.DB, id int64, lockedUntil time.Time, stop <-chan struct{}) time.Time {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return lockedUntil
+		case <-ticker.C:
+			err := db.QueryRow(`
+				UPDATE lanternmq_messages SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+				WHERE id = $1 AND locked_until = $2
+				RETURNING locked_until`,
+				id, lockedUntil, leaseDuration.Milliseconds()).Scan(&lockedUntil)
+			if err == sql.ErrNoRows {
+				// the lease was lost, so the message won't be settled
+				<-stop
+				return lockedUntil
+			}
+			// other errors are retried on the next tick, and the lease is kept if it hasn't expired
+		}
+	}
+}
+
+// releaseLease ends the lease on the message with ID 'id' from the queue that 'msgs' receives from, which lasts
+// until 'lockedUntil', so that the message is available again straight away.
+func releaseLease(db *sql.DB, msgs *Messages, id int64, lockedUntil time.Time) error {
+	_, err := db.Exec("UPDATE lanternmq_messages SET locked_until = NULL WHERE id = $1 AND locked_until = $2", id, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("unable to return message to queue %s: %s", msgs.qName, err.Error())
+	}
+	return nil
+}
+
+// returnRetries moves the messages waiting in the consumer's retry queue whose delay has passed back to the
+// consumer's queue.
+func returnRetries(db *sql.DB, msgs *Messages) error {
+	if msgs.policy == nil || msgs.policy.RetryQueue == "" {
+		return nil
+	}
+
+	_, err := db.Exec(`
+		UPDATE lanternmq_messages SET queue = return_queue, return_queue = NULL
+		WHERE id IN (
+			SELECT id FROM lanternmq_messages
+			WHERE queue = $1 AND return_queue = $2 AND available_at <= NOW()
+			FOR UPDATE SKIP LOCKED
+		)`, msgs.policy.RetryQueue, msgs.qName)
+	if err != nil {
+		return fmt.Errorf("unable to return messages from queue %s to queue %s: %s", msgs.policy.RetryQueue, msgs.qName, err.Error())
+	}
+	return nil
+}
+
+// settle acknowledges, retries or rejects the message with ID 'id' in the transaction 'tx', given the error
+// 'handlerErr' returned when it was processed. See the RabbitMQ implementation's settle. A requeued message is
+// left where it is and its lease is released, so it is delivered again straight away. Retried and dead lettered
+// messages are moved to their new queue as a new row, so that they go to the back of it.
+func settle(tx *sql.Tx, msgs *Messages, id int64, attempts int, handlerErr error) error {
+	if handlerErr == nil {
+		return deleteMessage(tx, id)
+	}
+
+	policy := msgs.policy
+	attempts++
+
+	if lanternmq.IsRetry(handlerErr) && policy != nil && (policy.MaxAttempts == 0 || attempts < policy.MaxAttempts) {
+		if policy.Delay == 0 && policy.MaxAttempts == 0 {
+			_, err := tx.Exec("UPDATE lanternmq_messages SET locked_until = NULL WHERE id = $1", id)
+			return err
+		}
+		if policy.Delay == 0 {
+			return forward(tx, id, msgs.qName, attempts, "", "", 0)
+		}
+		return forward(tx, id, policy.RetryQueue, attempts, "", msgs.qName, policy.Delay)
+	}
+
+	if policy == nil || policy.DeadLetterQueue == "" {
+		return deleteMessage(tx, id)
+	}
+	return forward(tx, id, policy.DeadLetterQueue, attempts, handlerErr.Error(), "", 0)
+}
+
+// forward adds the message with ID 'id' to the queue with name 'qName' in place of the original, after it has
+// been processed 'attempts' times. If 'reason' is not empty, it is recorded as the error the message couldn't be
+// processed with. A message in a retry queue goes back to 'returnQueue' after 'delay'. If the queue doesn't
+// exist, an error is returned, and the transaction must be rolled back so that the message is requeued. The
+// message keeps its priority.
+func forward(tx *sql.Tx, id int64, qName string, attempts int, reason string, returnQueue string, delay time.Duration) error {
+	_, err := tx.Exec(`
+		INSERT INTO lanternmq_messages (queue, body, priority, attempts, error, return_queue, available_at)
+		SELECT $2, body, priority, $3, NULLIF($4, ''), NULLIF($5, ''), NOW() + $6 * INTERVAL '1 millisecond'
+		FROM lanternmq_messages WHERE id = $1`,
+		id, qName, attempts, reason, returnQueue, delay.Milliseconds())
+	if err == nil {
+		_, err = tx.Exec("SELECT pg_notify($1, $2)", notifyChannel, qName)
+	}
+	if err == nil {
+		err = deleteMessage(tx, id)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to send message to queue %s, requeued it instead: %s", qName, err.Error())
+	}
+	return nil
+}
+
+// deleteMessage removes the message with ID 'id' in the transaction 'tx'.
+func deleteMessage(tx *sql.Tx, id int64) error {
+	_, err := tx.Exec("DELETE FROM lanternmq_messages WHERE id = $1", id)
+	return err
+}
+
+// DeclareExchange creates an exchange named 'name' of type 'exchangeType' if one does not exist. The supported
+// exchange types are 'direct', 'fanout' and 'topic'.
+func (mq *MessageQueue) DeclareExchange(chID lanternmq.ChannelID, name string, exchangeType string) error {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	switch exchangeType {
+	case exchangeDirect, exchangeFanout, exchangeTopic:
+		// ok
+	default:
+		return fmt.Errorf("unable to declare target: exchange type %s is not supported", exchangeType)
+	}
+
+	_, err = db.Exec("INSERT INTO lanternmq_exchanges (name, type) VALUES ($1, $2) ON CONFLICT DO NOTHING", name, exchangeType)
+	if err != nil {
+		return fmt.Errorf("unable to declare target: %s", err.Error())
+	}
+	var existingType string
+	err = db.QueryRow("SELECT type FROM lanternmq_exchanges WHERE name = $1", name).Scan(&existingType)
+	if err != nil {
+		return fmt.Errorf("unable to declare target: %s", err.Error())
+	}
+	if existingType != exchangeType {
+		return fmt.Errorf("unable to declare target: exchange %s already exists with type %s", name, existingType)
+	}
+	return nil
+}
+
+// PublishToExchange adds 'message' to each queue bound to the exchange 'name' whose binding matches
+// 'routingKey'. Messages that match no binding are dropped.
+func (mq *MessageQueue) PublishToExchange(chID lanternmq.ChannelID, name string, routingKey string, message string) error {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to publish to target %s with routing key %s: %s", name, routingKey, err.Error())
+	}
+	err = publishToExchange(tx, name, routingKey, message)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("unable to publish to target %s with routing key %s: %s", name, routingKey, err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to publish to target %s with routing key %s: %s", name, routingKey, err.Error())
+	}
+	return nil
+}
+
+// publishToExchange adds 'message' to the queues bound to the exchange 'name' that 'routingKey' routes it to, in
+// the transaction 'tx'.
+func publishToExchange(tx *sql.Tx, name string, routingKey string, message string) error {
+	var exchangeType string
+	err := tx.QueryRow("SELECT type FROM lanternmq_exchanges WHERE name = $1", name).Scan(&exchangeType)
+	if err == sql.ErrNoRows {
+		return errors.New("exchange does not exist")
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT queue, routing_key FROM lanternmq_bindings WHERE exchange = $1 ORDER BY queue", name)
+	if err != nil {
+		return err
+	}
+	var qNames []string
+	for rows.Next() {
+		var qName, bindingKey string
+		err = rows.Scan(&qName, &bindingKey)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if routes(exchangeType, bindingKey, routingKey) && (len(qNames) == 0 || qNames[len(qNames)-1] != qName) {
+			qNames = append(qNames, qName)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	for _, qName := range qNames {
+		_, err = insertMessages(tx, qName, []string{message}, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routes returns whether an exchange of type 'exchangeType' routes a message with the routing key 'routingKey'
+// to a queue bound with 'bindingKey'.
+func routes(exchangeType string, bindingKey string, routingKey string) bool {
+	switch exchangeType {
+	case exchangeFanout:
+		return true
+	case exchangeDirect:
+		return bindingKey == routingKey
+	case exchangeTopic:
+		return lanternmq.TopicMatches(bindingKey, routingKey)
+	}
+	return false
+}
+
+// DeclareExchangeReceiveQueue creates a queue named 'qName' and binds it to the exchange named 'exchangeName'
+// with the routing key 'routingKey'. If the queue didn't exist, it is deleted when the MessageQueue is closed.
+func (mq *MessageQueue) DeclareExchangeReceiveQueue(chID lanternmq.ChannelID, exchangeName string, qName string, routingKey string) error {
+	db, _, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM lanternmq_exchanges WHERE name = $1)", exchangeName).Scan(&exists)
+	if err == nil && !exists {
+		err = errors.New("exchange does not exist")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to bind queue %s to target %s with routing key %s: %s", qName, exchangeName, routingKey, err.Error())
+	}
+
+	res, err := db.Exec("INSERT INTO lanternmq_queues (name) VALUES ($1) ON CONFLICT DO NOTHING", qName)
+	if err != nil {
+		return fmt.Errorf("unable to declare queue %s: %s", qName, err.Error())
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to declare queue %s: %s", qName, err.Error())
+	}
+	if created > 0 {
+		mq.mu.Lock()
+		mq.exclusive = append(mq.exclusive, qName)
+		mq.mu.Unlock()
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO lanternmq_bindings (exchange, queue, routing_key) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, exchangeName, qName, routingKey)
+	if err != nil {
+		return fmt.Errorf("unable to bind queue %s to target %s with routing key %s: %s", qName, exchangeName, routingKey, err.Error())
+	}
+	return nil
+}
+
+// Close stops the MessageQueue's consumers, deletes the queues it created with DeclareExchangeReceiveQueue along
+// with their messages, and closes the connection to the database. Any ProcessMessages calls return once they
+// have finished processing their current message.
+func (mq *MessageQueue) Close() {
+	mq.mu.Lock()
+	db := mq.db
+	listener := mq.listener
+	exclusive := mq.exclusive
+	if mq.done != nil {
+		close(mq.done)
+	}
+	mq.db = nil
+	mq.listener = nil
+	mq.done = nil
+	mq.channels = nil
+	mq.consumers = nil
+	mq.exclusive = nil
+	mq.mu.Unlock()
+
+	if db == nil {
+		return
+	}
+	for _, qName := range exclusive {
+		db.Exec("DELETE FROM lanternmq_queues WHERE name = $1", qName)
+	}
+	listener.Close()
+	db.Close()
+}
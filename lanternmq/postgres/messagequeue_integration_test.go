@@ -0,0 +1,413 @@
+// +build integration
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/spf13/viper"
+)
+
+var mq lanternmq.MessageQueue
+var chID lanternmq.ChannelID
+var testDB *sql.DB
+
+// the queues and exchange declared by the tests, which are deleted at the end
+var testQueues = []string{"test-postgres-queue", "test-postgres-queue-retry", "test-postgres-queue-dead"}
+var testExchange = "test-postgres-exchange"
+var testPriorityQueue = "test-postgres-priority"
+
+func TestMain(m *testing.M) {
+	err := setupConfigForTests()
+	if err != nil {
+		panic(err)
+	}
+
+	hap := th.HostAndPort{Host: viper.GetString("dbhost"), Port: viper.GetString("dbport")}
+	err = th.CheckResources(hap)
+	if err != nil {
+		panic(err)
+	}
+
+	err = setup()
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	teardown()
+	os.Exit(code)
+}
+
+func Test_PublishAndProcess(t *testing.T) {
+	qName := testQueues[0]
+	defer purgeTestQueues(t)
+
+	err := mq.PublishToQueue(chID, qName, "one", 0)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishBatchToQueue(chID, qName, []string{"two", "three"}, 0)
+	th.Assert(t, err == nil, err)
+
+	count, err := mq.CountMessages(chID, qName)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 3, fmt.Sprintf("expected 3 messages in the queue, got %d", count))
+
+	// unroutable messages are dropped unless the channel is in confirm mode
+	err = mq.PublishToQueue(chID, "test-postgres-missing", "dropped", 0)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, qName)
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string, 10)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	for _, expected := range []string{"one", "two", "three"} {
+		msg := receive(t, received)
+		th.Assert(t, msg == expected, fmt.Sprintf("expected to receive %s, got %s", expected, msg))
+	}
+
+	// messages published while the consumer is waiting are received
+	err = mq.PublishToQueue(chID, qName, "four", 0)
+	th.Assert(t, err == nil, err)
+	msg := receive(t, received)
+	th.Assert(t, msg == "four", fmt.Sprintf("expected to receive four, got %s", msg))
+
+	// wait for the last message to be acknowledged
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	count, err = mq.CountMessages(chID, qName)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected the processed messages to be deleted, got %d", count))
+	th.Assert(t, len(errs) == 0, "expected no errors processing the messages")
+}
+
+func Test_ConfirmPublishes(t *testing.T) {
+	mq2, chID2 := connect(t)
+	defer mq2.Close()
+
+	err := mq2.ConfirmPublishes(chID2, time.Second)
+	th.Assert(t, err == nil, err)
+
+	err = mq2.PublishBatchToQueue(chID2, "test-postgres-missing", []string{"one", "two"}, 0)
+	th.Assert(t, err != nil, "expected an error publishing to a queue that doesn't exist in confirm mode")
+	th.Assert(t, err.Error() == "2 published messages were returned as unroutable: test-postgres-missing NO_ROUTE", fmt.Sprintf("unexpected error %s", err.Error()))
+}
+
+func Test_ProcessMessagesRetry(t *testing.T) {
+	qName := testQueues[0]
+	defer purgeTestQueues(t)
+
+	prevPollInterval := pollInterval
+	pollInterval = 100 * time.Millisecond
+	defer func() { pollInterval = prevPollInterval }()
+
+	policy := lanternmq.RetryPolicy{
+		MaxAttempts:     3,
+		Delay:           200 * time.Millisecond,
+		RetryQueue:      testQueues[1],
+		DeadLetterQueue: testQueues[2],
+	}
+	msgs, err := mq.ConsumeFromQueueWithRetry(chID, qName, policy)
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string, 10)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		switch string(message) {
+		case "retry":
+			return lanternmq.Retry(errors.New("database unavailable"))
+		case "reject":
+			return errors.New("malformed message")
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	err = mq.PublishToQueue(chID, qName, "reject", 0)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, receive(t, received) == "reject", "expected to receive the rejected message")
+
+	err = mq.PublishToQueue(chID, qName, "retry", 0)
+	th.Assert(t, err == nil, err)
+	for i := 0; i < policy.MaxAttempts; i++ {
+		th.Assert(t, receive(t, received) == "retry", "expected to receive the retried message")
+	}
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	rows, err := testDB.Query("SELECT attempts, error FROM lanternmq_messages WHERE queue = $1 ORDER BY id", policy.DeadLetterQueue)
+	th.Assert(t, err == nil, err)
+	defer rows.Close()
+	var dead []string
+	for rows.Next() {
+		var attempts int
+		var reason string
+		err = rows.Scan(&attempts, &reason)
+		th.Assert(t, err == nil, err)
+		dead = append(dead, fmt.Sprintf("%d %s", attempts, reason))
+	}
+	th.Assert(t, len(dead) == 2, fmt.Sprintf("expected 2 messages in the dead letter queue, got %d", len(dead)))
+	th.Assert(t, dead[0] == "1 malformed message", fmt.Sprintf("expected the rejected message to be dead lettered after one attempt, got %s", dead[0]))
+	th.Assert(t, dead[1] == "3 database unavailable", fmt.Sprintf("expected the retried message to be dead lettered after three attempts, got %s", dead[1]))
+
+	count, err := mq.CountMessages(chID, policy.RetryQueue)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected no messages in the retry queue, got %d", count))
+}
+
+func Test_Priorities(t *testing.T) {
+	defer mq.PurgeQueue(chID, testPriorityQueue)
+
+	err := mq.DeclareQueue(chID, testPriorityQueue, 2)
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareQueue(chID, testPriorityQueue, 5)
+	th.Assert(t, err != nil, "expected an error redeclaring a queue with a different maximum priority")
+
+	err = mq.PublishBatchToQueue(chID, testPriorityQueue, []string{"routine 1", "routine 2"}, 0)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishToQueue(chID, testPriorityQueue, "new", 1)
+	th.Assert(t, err == nil, err)
+	err = mq.PublishToQueue(chID, testPriorityQueue, "on demand 1", 2)
+	th.Assert(t, err == nil, err)
+	// priorities above the maximum are treated as the maximum
+	err = mq.PublishToQueue(chID, testPriorityQueue, "on demand 2", 9)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, testPriorityQueue)
+	th.Assert(t, err == nil, err)
+	received := make(chan string, 10)
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+
+	for _, expected := range []string{"on demand 1", "on demand 2", "new", "routine 1", "routine 2"} {
+		msg := receive(t, received)
+		th.Assert(t, msg == expected, fmt.Sprintf("expected to receive %s, got %s", expected, msg))
+	}
+}
+
+func Test_ProcessMessagesLease(t *testing.T) {
+	qName := testQueues[0]
+	defer purgeTestQueues(t)
+
+	prevLeaseDuration := leaseDuration
+	defer func() { leaseDuration = prevLeaseDuration }()
+	leaseDuration = 300 * time.Millisecond
+
+	err := mq.PublishBatchToQueue(chID, qName, []string{"one", "two"}, 0)
+	th.Assert(t, err == nil, err)
+
+	msgs, err := mq.ConsumeFromQueue(chID, qName)
+	th.Assert(t, err == nil, err)
+
+	received := make(chan string, 10)
+	release := make(chan struct{})
+	handler := func(message []byte, args *map[string]interface{}) error {
+		received <- string(message)
+		if string(message) == "one" {
+			<-release
+		}
+		return nil
+	}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mq.ProcessMessages(ctx, msgs, handler, nil, errs)
+	th.Assert(t, receive(t, received) == "one", "expected to receive the first message")
+
+	// the lease is committed before the handler runs, so no transaction is left open while it does
+	var leased int
+	err = testDB.QueryRow("SELECT COUNT(*) FROM lanternmq_messages WHERE queue = $1 AND locked_until > NOW()", qName).Scan(&leased)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, leased == 1, fmt.Sprintf("expected the message being processed to be leased, got %d leased messages", leased))
+
+	// other consumers skip the leased message, even after the first lease would have expired, since it is renewed
+	mq2, chID2 := connect(t)
+	defer mq2.Close()
+	msgs2, err := mq2.ConsumeFromQueue(chID2, qName)
+	th.Assert(t, err == nil, err)
+	go mq2.ProcessMessages(ctx, msgs2, handler, nil, errs)
+	th.Assert(t, receive(t, received) == "two", "expected the second consumer to receive the second message")
+	select {
+	case msg := <-received:
+		t.Fatalf("expected the leased message not to be delivered again, got %s", msg)
+	case <-time.After(2 * leaseDuration):
+	}
+
+	close(release)
+	time.Sleep(100 * time.Millisecond)
+	count, err := mq.CountMessages(chID, qName)
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 0, fmt.Sprintf("expected the processed messages to be deleted, got %d", count))
+	th.Assert(t, len(errs) == 0, "expected no errors processing the messages")
+}
+
+func Test_Exchanges(t *testing.T) {
+	defer purgeTestQueues(t)
+
+	err := mq.DeclareExchange(chID, testExchange, "topic")
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareExchange(chID, testExchange, "fanout")
+	th.Assert(t, err != nil, "expected an error redeclaring an exchange with a different type")
+
+	mq2, chID2 := connect(t)
+	err = mq2.DeclareExchangeReceiveQueue(chID2, testExchange, "test-postgres-bound", "*.error")
+	th.Assert(t, err == nil, err)
+	err = mq.DeclareExchangeReceiveQueue(chID, testExchange, testQueues[0], "#")
+	th.Assert(t, err == nil, err)
+
+	err = mq.PublishToExchange(chID, testExchange, "querier.error", "failed")
+	th.Assert(t, err == nil, err)
+	err = mq.PublishToExchange(chID, testExchange, "querier.info", "succeeded")
+	th.Assert(t, err == nil, err)
+
+	count, err := mq.CountMessages(chID, "test-postgres-bound")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 1, fmt.Sprintf("expected 1 message in the bound queue, got %d", count))
+	count, err = mq.CountMessages(chID, testQueues[0])
+	th.Assert(t, err == nil, err)
+	th.Assert(t, count == 2, fmt.Sprintf("expected 2 messages in the queue bound to every routing key, got %d", count))
+
+	// the queue created by DeclareExchangeReceiveQueue is deleted when its MessageQueue is closed
+	mq2.Close()
+	exists, err := mq.QueueExists(chID, "test-postgres-bound")
+	th.Assert(t, err == nil, err)
+	th.Assert(t, !exists, "expected the bound queue to be deleted")
+}
+
+// receive returns the next message processed through 'received', or fails the test if there is no message
+// within five seconds.
+func receive(t *testing.T, received <-chan string) string {
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return ""
+}
+
+func purgeTestQueues(t *testing.T) {
+	for _, qName := range testQueues {
+		err := mq.PurgeQueue(chID, qName)
+		th.Assert(t, err == nil, err)
+	}
+}
+
+func connect(t *testing.T) (lanternmq.MessageQueue, lanternmq.ChannelID) {
+	mq2 := NewMessageQueue(viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	err := mq2.Connect(viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbhost"), viper.GetString("dbport"))
+	th.Assert(t, err == nil, err)
+	chID2, err := mq2.CreateChannel()
+	th.Assert(t, err == nil, err)
+	return mq2, chID2
+}
+
+func setup() error {
+	var err error
+
+	mq = NewMessageQueue(viper.GetString("dbname"), viper.GetString("dbsslmode"))
+	err = mq.Connect(viper.GetString("dbuser"), viper.GetString("dbpassword"), viper.GetString("dbhost"), viper.GetString("dbport"))
+	if err != nil {
+		return err
+	}
+	chID, err = mq.CreateChannel()
+	if err != nil {
+		return err
+	}
+	for _, qName := range testQueues {
+		err = mq.DeclareQueue(chID, qName, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = mq.DeclareQueue(chID, testPriorityQueue, 2)
+	if err != nil {
+		return err
+	}
+
+	testDB = mq.(*MessageQueue).db
+	return nil
+}
+
+func teardown() {
+	for _, qName := range testQueues {
+		testDB.Exec("DELETE FROM lanternmq_queues WHERE name = $1", qName)
+	}
+	testDB.Exec("DELETE FROM lanternmq_queues WHERE name = $1", testPriorityQueue)
+	testDB.Exec("DELETE FROM lanternmq_exchanges WHERE name = $1", testExchange)
+	mq.Close()
+}
+
+func setupConfigForTests() error {
+	var err error
+
+	viper.SetEnvPrefix("lantern")
+	viper.AutomaticEnv()
+
+	err = viper.BindEnv("dbhost")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("dbport")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("dbsslmode")
+	if err != nil {
+		return err
+	}
+
+	viper.SetDefault("dbhost", "localhost")
+	viper.SetDefault("dbport", "5432")
+	viper.SetDefault("dbsslmode", "disable")
+
+	viper.SetEnvPrefix("lantern_test")
+	viper.AutomaticEnv()
+
+	err = viper.BindEnv("dbuser")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("dbpassword")
+	if err != nil {
+		return err
+	}
+	err = viper.BindEnv("dbname")
+	if err != nil {
+		return err
+	}
+
+	viper.SetDefault("dbuser", "lantern")
+	viper.SetDefault("dbpassword", "postgrespassword")
+	viper.SetDefault("dbname", "lantern_test")
+
+	return nil
+}
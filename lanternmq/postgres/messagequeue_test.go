@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"testing"
+
+	th "github.com/onc-healthit/lantern-back-end/endpointmanager/pkg/testhelper"
+)
+
+func Test_getChannel(t *testing.T) {
+	mq := &MessageQueue{}
+
+	_, err := mq.CreateChannel()
+	th.Assert(t, err != nil, "expected an error creating a channel before connecting")
+
+	_, _, err = mq.getChannel("0")
+	th.Assert(t, err != nil && err.Error() == "ChannelID not of correct type", "expected an error for a channel ID of the wrong type")
+
+	_, _, err = mq.getChannel(0)
+	th.Assert(t, err != nil && err.Error() == "no channel with the requested ID was found", "expected an error for a channel that doesn't exist")
+
+	// closing a MessageQueue that never connected does nothing
+	mq.Close()
+}
+
+func Test_routes(t *testing.T) {
+	th.Assert(t, routes(exchangeFanout, "a", "b"), "expected a fanout exchange to route every message")
+	th.Assert(t, routes(exchangeDirect, "a.b", "a.b"), "expected a direct exchange to route a message with a matching key")
+	th.Assert(t, !routes(exchangeDirect, "a.*", "a.b"), "expected a direct exchange to only route messages with the same key")
+	th.Assert(t, routes(exchangeTopic, "a.*", "a.b"), "expected a topic exchange to route a message matching the binding key")
+	th.Assert(t, !routes(exchangeTopic, "a.*", "b.a"), "expected a topic exchange to not route a message that doesn't match the binding key")
+	th.Assert(t, !routes("headers", "a", "a"), "expected an unsupported exchange type to not route messages")
+}
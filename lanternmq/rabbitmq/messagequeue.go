@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/onc-healthit/lantern-back-end/lanternmq"
 	"github.com/streadway/amqp"
@@ -22,10 +25,28 @@ const globalFalse bool = false
 const immediateFalse bool = false
 const internalFalse bool = false
 const mandatoryFalse bool = false
+const mandatoryTrue bool = true
 const noWaitFalse bool = false
 const noLocalFalse bool = false
 const prefetchSize0 int = 0
 
+// attemptsHeader records how many times a retried message has been processed, and errorHeader records why a
+// dead lettered message could not be processed.
+const attemptsHeader = "x-lantern-attempts"
+const errorHeader = "x-lantern-error"
+
+// maxPriorityArg is the queue argument that makes a queue a priority queue
+const maxPriorityArg = "x-max-priority"
+
+// minReconnectDelay and maxReconnectDelay bound how long to wait between attempts to reconnect to RabbitMQ after
+// the connection is lost. The delay doubles after each failed attempt.
+var minReconnectDelay = 500 * time.Millisecond
+var maxReconnectDelay = 30 * time.Second
+
+// confirmBufferSize is how many confirmations and returned messages can be waiting to be read for a channel in
+// confirm mode. Messages are confirmed in batches of at most this size.
+var confirmBufferSize = 1024
+
 // Ensure MessageQueue implements lanternmq.MessageQueue.
 var _ lanternmq.MessageQueue = &MessageQueue{}
 
@@ -41,71 +62,340 @@ var _ lanternmq.Messages = &Messages{}
 // * declare a durable exchange, and send and receive from that exchange
 //   - potential exchange options are: 'direct', 'topic', 'headers', and 'fanout'
 //
+// * put a channel in confirm mode, so that publishing to a queue waits for RabbitMQ to confirm the messages
 // * close the MessageQueue, which includes closing all channels and the connection to the underlying service.
+//
+// If the connection to RabbitMQ or one of its channels closes unexpectedly, the MessageQueue reconnects and
+// recreates the channel with the same ID. The queues, exchanges and bindings declared on the channel are declared
+// again, and its consumers are resumed, so messages keep arriving on the lanternmq.Messages already returned by
+// ConsumeFromQueue. Errors hit while reconnecting are sent to the 'errs' channel of ProcessMessages.
 type MessageQueue struct {
+	url        string
 	connection *amqp.Connection
-	channels   []*amqp.Channel
+	channels   []*channel
+	closed     bool
+	mu         sync.RWMutex
+}
+
+// channel is a RabbitMQ channel along with the setup that has been done on it and the consumers reading from it,
+// so that it can be recreated the same way if it closes. If the channel is in confirm mode, confirmer tracks its
+// confirmations and publishMu keeps publishes from interleaving while they wait for them.
+type channel struct {
+	ch             *amqp.Channel
+	setup          []func(*amqp.Channel) error
+	consumers      []*Messages
+	confirmer      *confirmer
+	confirmTimeout time.Duration
+	publishMu      sync.Mutex
+}
+
+// confirmer receives the confirmations and returned messages for a channel in confirm mode. nextTag is the
+// delivery tag RabbitMQ gives the next message published on the channel.
+type confirmer struct {
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	nextTag  uint64
 }
 
-// Messages wraps the delivery channel.
+// Messages wraps the delivery channel. Deliveries from the RabbitMQ consumer are forwarded to the delivery
+// channel, which stays the same when the consumer is resumed after a reconnection. Messages that are retried or
+// dead lettered are published over the consumer's channel c according to policy.
 type Messages struct {
-	deliveryChannel <-chan amqp.Delivery
+	qName           string
+	deliveryChannel chan amqp.Delivery
+	notices         chan error
+	done            chan struct{}
+	c               *channel
+	policy          *lanternmq.RetryPolicy
 }
 
 // addChannel adds the given channel to the MessageQueue.channels array and returns the
 // index to that array casted to a lanternmq.ChannelID.
-func (mq *MessageQueue) addChannel(ch *amqp.Channel) (lanternmq.ChannelID, error) {
+func (mq *MessageQueue) addChannel(c *channel) (lanternmq.ChannelID, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
 	if mq.channels == nil {
-		mq.channels = []*amqp.Channel{}
+		mq.channels = []*channel{}
 	}
-	mq.channels = append(mq.channels, ch)
+	mq.channels = append(mq.channels, c)
 	index := len(mq.channels) - 1
 	id := lanternmq.ChannelID(index)
 
 	return id, nil
 }
 
-// getChannel retrieves the channel provided by `id` by casting `id` back to an integer and
+// getChannelState retrieves the channel provided by `id` by casting `id` back to an integer and
 // retrieving the channel at the corresponding index of MessageQueue.channels array.
-func (mq *MessageQueue) getChannel(id lanternmq.ChannelID) (*amqp.Channel, error) {
+func (mq *MessageQueue) getChannelState(id lanternmq.ChannelID) (*channel, error) {
 	idInt, ok := id.(int)
 	if !ok {
 		return nil, errors.New("ChannelID not of correct type")
 	}
-	if idInt >= len(mq.channels) {
+
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+	if idInt < 0 || idInt >= len(mq.channels) {
 		return nil, errors.New("no channel with the requested ID was found")
 	}
-	ch := mq.channels[idInt]
-	return ch, nil
+	return mq.channels[idInt], nil
+}
+
+// getChannel retrieves the current RabbitMQ channel for the channel with ID `id`.
+func (mq *MessageQueue) getChannel(id lanternmq.ChannelID) (*amqp.Channel, error) {
+	c, err := mq.getChannelState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+	return c.ch, nil
 }
 
-// Connect creates a connection to a RabbitMQ service.
+// addSetup records a step that was run on the channel with ID `id` so that it is run again when the channel is
+// recreated.
+func (mq *MessageQueue) addSetup(id lanternmq.ChannelID, setup func(*amqp.Channel) error) {
+	c, err := mq.getChannelState(id)
+	if err != nil {
+		return
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	c.setup = append(c.setup, setup)
+}
+
+// Connect creates a connection to a RabbitMQ service. The connection is watched so that it can be reestablished
+// if it closes unexpectedly.
 func (mq *MessageQueue) Connect(username string, password string, host string, port string) error {
-	s := fmt.Sprintf("amqp://%s:%s@%s:%s/", username, password, host, port)
-	conn, err := amqp.Dial(s)
+	mq.url = fmt.Sprintf("amqp://%s:%s@%s:%s/", username, password, host, port)
+	conn, err := amqp.Dial(mq.url)
 	if err != nil {
 		err = errors.New("unable to connect to message queue")
 	}
+
+	mq.mu.Lock()
 	mq.connection = conn
+	mq.closed = false
+	mq.mu.Unlock()
+
+	if conn != nil {
+		go mq.watchConnection(conn.NotifyClose(make(chan *amqp.Error, 1)))
+	}
 
 	return err
 }
 
+// reconnectDelay returns how long to wait before the given attempt to reconnect, starting from 0.
+func reconnectDelay(attempt int) time.Duration {
+	delay := minReconnectDelay
+	for i := 0; i < attempt && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxReconnectDelay {
+		delay = maxReconnectDelay
+	}
+	return delay
+}
+
+// isClosed returns whether Close has been called on the MessageQueue.
+func (mq *MessageQueue) isClosed() bool {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+	return mq.closed
+}
+
+// watchConnection waits for the connection to close and reconnects unless the MessageQueue was closed.
+func (mq *MessageQueue) watchConnection(closes chan *amqp.Error) {
+	closeErr := <-closes
+	if mq.isClosed() {
+		return
+	}
+
+	reason := "connection closed"
+	if closeErr != nil {
+		reason = closeErr.Error()
+	}
+	mq.notify(fmt.Errorf("lost connection to message queue, reconnecting: %s", reason))
+
+	for attempt := 0; ; attempt++ {
+		time.Sleep(reconnectDelay(attempt))
+		if mq.isClosed() {
+			return
+		}
+		err := mq.reconnect()
+		if err == nil {
+			return
+		}
+		mq.notify(fmt.Errorf("unable to reconnect to message queue: %s", err.Error()))
+	}
+}
+
+// reconnect dials RabbitMQ again and recreates every channel on the new connection.
+func (mq *MessageQueue) reconnect() error {
+	conn, err := amqp.Dial(mq.url)
+	if err != nil {
+		return err
+	}
+	closes := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.closed {
+		conn.Close()
+		return nil
+	}
+	for _, c := range mq.channels {
+		err = openChannel(mq, conn, c)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	mq.connection = conn
+	go mq.watchConnection(closes)
+
+	return nil
+}
+
+// openChannel opens a RabbitMQ channel on the given connection for c, reruns c's setup on it and resumes c's
+// consumers. The channel is watched so that it can be recreated if it closes unexpectedly. The caller must hold
+// the write lock.
+func openChannel(mq *MessageQueue, conn *amqp.Connection, c *channel) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	closes := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	for _, setup := range c.setup {
+		err = setup(ch)
+		if err != nil {
+			ch.Close()
+			return err
+		}
+	}
+	for _, msgs := range c.consumers {
+		err = consume(ch, msgs)
+		if err != nil {
+			ch.Close()
+			return err
+		}
+	}
+
+	c.ch = ch
+	go mq.watchChannel(c, ch, closes)
+
+	return nil
+}
+
+// watchChannel waits for the given RabbitMQ channel to close and recreates it if it closed on its own, such as
+// after a channel error. If the channel can't be recreated, it's tried again with the same delays as reconnecting
+// until it is recreated or the MessageQueue is closed. If the whole connection closed, the channel is recreated
+// when the connection is.
+func (mq *MessageQueue) watchChannel(c *channel, ch *amqp.Channel, closes chan *amqp.Error) {
+	closeErr := <-closes
+	if closeErr == nil {
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		if mq.reopenChannel(c, ch, closeErr) {
+			return
+		}
+		time.Sleep(reconnectDelay(attempt))
+	}
+}
+
+// reopenChannel recreates c after its RabbitMQ channel ch closed with closeErr. It returns false if the channel
+// should be recreated again later because it couldn't be this time, after telling c's consumers why.
+func (mq *MessageQueue) reopenChannel(c *channel, ch *amqp.Channel, closeErr *amqp.Error) bool {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	// the channel has already been recreated, or is recreated along with the connection
+	if mq.closed || c.ch != ch || mq.connection == nil || mq.connection.IsClosed() {
+		return true
+	}
+	err := openChannel(mq, mq.connection, c)
+	if err != nil {
+		notifyConsumers(c, fmt.Errorf("unable to reopen channel after '%s', retrying: %s", closeErr.Error(), err.Error()))
+		return false
+	}
+	return true
+}
+
+// notify sends the given error to the consumers of every channel.
+func (mq *MessageQueue) notify(err error) {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+	for _, c := range mq.channels {
+		notifyConsumers(c, err)
+	}
+}
+
+// notifyConsumers sends the given error to the consumers of the given channel without waiting for them to
+// receive it.
+func notifyConsumers(c *channel, err error) {
+	for _, msgs := range c.consumers {
+		select {
+		case msgs.notices <- err:
+		default:
+			// a notice is already waiting
+		}
+	}
+}
+
+// consume starts a RabbitMQ consumer for msgs on the given channel and forwards its deliveries to msgs.
+func consume(ch *amqp.Channel, msgs *Messages) error {
+	deliveries, err := ch.Consume(
+		msgs.qName,
+		"", // consumer
+		autoAckFalse,
+		exclusiveFalse,
+		noLocalFalse,
+		noWaitFalse,
+		nil, // args
+	)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for d := range deliveries {
+			select {
+			case msgs.deliveryChannel <- d:
+			case <-msgs.done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
 // CreateChannel creates a channel to the RabbitMQ service that has already been connected to.
 // If the RabbitMQ service has not been connected to already, an error is thrown.
 // The channel's ID is returned.
 func (mq *MessageQueue) CreateChannel() (lanternmq.ChannelID, error) {
 	var err error
-	if mq.connection == nil {
+	mq.mu.Lock()
+	conn := mq.connection
+	if conn == nil {
+		mq.mu.Unlock()
 		err = errors.New("connection must exist before creating a channel")
 		return "", err
 	}
-	ch, err := mq.connection.Channel()
+	c := &channel{}
+	err = openChannel(mq, conn, c)
+	mq.mu.Unlock()
 	if err != nil {
 		err = errors.New("unable to create channel")
 		return "", err
 	}
-	id, err := mq.addChannel(ch)
+	id, err := mq.addChannel(c)
 
 	return id, err
 }
@@ -117,34 +407,28 @@ func (mq *MessageQueue) NumConcurrentMsgs(chID lanternmq.ChannelID, num int) err
 		return err
 	}
 
-	err = ch.Qos(
-		num,
-		prefetchSize0,
-		globalFalse,
-	)
+	setup := func(ch *amqp.Channel) error {
+		return ch.Qos(
+			num,
+			prefetchSize0,
+			globalFalse,
+		)
+	}
+	err = setup(ch)
 	if err != nil {
 		err = errors.New("unable to set the number of concurrent messages that can be handled")
 		return err
 	}
+	mq.addSetup(chID, setup)
 	return err
 }
 
 // QueueExists checks whether or not a queue already exists. If so, it returns (true, nil). If not,
-// it returns (false, nil). If an error is encountered, it returns (false, err).
+// it returns (false, nil). If an error is encountered, it returns (false, err). RabbitMQ closes the channel
+// a missing queue is checked on, so the check is made on a temporary channel rather than the channel with
+// ID 'chID'.
 func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (bool, error) {
-	ch, err := mq.getChannel(chID)
-	if err != nil {
-		return false, err
-	}
-
-	_, err = ch.QueueDeclarePassive(
-		qName,
-		durableTrue,
-		deleteWhenUnusedFalse,
-		exclusiveFalse,
-		noWaitFalse,
-		nil, // args
-	)
+	_, err := mq.inspectQueue(chID, qName)
 	if err != nil {
 		amqperr, ok := err.(*amqp.Error)
 		if ok && amqperr.Code == 404 {
@@ -156,6 +440,69 @@ func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (boo
 	return true, err
 }
 
+// CountMessages returns how many messages are ready to be delivered from the queue with name 'qName'. The queue
+// is inspected on a temporary channel, since RabbitMQ closes the channel if the queue doesn't exist.
+func (mq *MessageQueue) CountMessages(chID lanternmq.ChannelID, qName string) (int, error) {
+	queue, err := mq.inspectQueue(chID, qName)
+	if err != nil {
+		return -1, fmt.Errorf("unable to count messages in queue %s: %s", qName, err.Error())
+	}
+	return queue.Messages, nil
+}
+
+// inspectQueue returns the state of the queue with name 'qName' using RabbitMQ's QueueDeclarePassive method on
+// a temporary channel.
+func (mq *MessageQueue) inspectQueue(chID lanternmq.ChannelID, qName string) (amqp.Queue, error) {
+	var queue amqp.Queue
+	err := mq.withTemporaryChannel(chID, func(ch *amqp.Channel) error {
+		var err error
+		queue, err = ch.QueueDeclarePassive(
+			qName,
+			durableTrue,
+			deleteWhenUnusedFalse,
+			exclusiveFalse,
+			noWaitFalse,
+			nil, // args
+		)
+		return err
+	})
+	return queue, err
+}
+
+// PurgeQueue removes the messages that are ready to be delivered from the queue with name 'qName' using
+// RabbitMQ's QueuePurge method on a temporary channel.
+func (mq *MessageQueue) PurgeQueue(chID lanternmq.ChannelID, qName string) error {
+	err := mq.withTemporaryChannel(chID, func(ch *amqp.Channel) error {
+		_, err := ch.QueuePurge(qName, noWaitFalse)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to purge queue %s: %s", qName, err.Error())
+	}
+	return nil
+}
+
+// withTemporaryChannel calls 'f' with a channel that is opened on the connection of the channel with ID 'chID'
+// and closed afterwards, so that errors that make RabbitMQ close the channel don't affect the channel with ID
+// 'chID'.
+func (mq *MessageQueue) withTemporaryChannel(chID lanternmq.ChannelID, f func(*amqp.Channel) error) error {
+	_, err := mq.getChannel(chID)
+	if err != nil {
+		return err
+	}
+
+	mq.mu.RLock()
+	conn := mq.connection
+	mq.mu.RUnlock()
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	return f(ch)
+}
+
 // DeclareQueue creates a queue with the given name on the given channel using RabbitMQ's
 // QueueDeclare method with the following arguments:
 // * name: qName
@@ -163,25 +510,36 @@ func (mq *MessageQueue) QueueExists(chID lanternmq.ChannelID, qName string) (boo
 // * autoDelete: false
 // * exclusive: false
 // * noWait: false
-// * args: nil
-func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, qName string) error {
+// * args: nil, or x-max-priority: maxPriority if maxPriority is not 0
+//
+// RabbitMQ returns an error if the queue already exists with a different maximum priority.
+func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, qName string, maxPriority lanternmq.Priority) error {
 	ch, err := mq.getChannel(chID)
 	if err != nil {
 		return err
 	}
 
-	_, err = ch.QueueDeclare(
-		qName,
-		durableTrue,
-		deleteWhenUnusedFalse,
-		exclusiveFalse,
-		noWaitFalse,
-		nil, // args
-	)
+	var args amqp.Table
+	if maxPriority != 0 {
+		args = amqp.Table{maxPriorityArg: int32(maxPriority)}
+	}
+	setup := func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(
+			qName,
+			durableTrue,
+			deleteWhenUnusedFalse,
+			exclusiveFalse,
+			noWaitFalse,
+			args,
+		)
+		return err
+	}
+	err = setup(ch)
 	if err != nil {
 		err = fmt.Errorf("unable to create queue: %s", err.Error())
 		return err
 	}
+	mq.addSetup(chID, setup)
 	return err
 }
 
@@ -189,29 +547,208 @@ func (mq *MessageQueue) DeclareQueue(chID lanternmq.ChannelID, qName string) err
 // by calling the RabbitMQ Publish method with the following arguments:
 // exchange: ""
 // key: qName
-// mandatory: false
+// mandatory: false, or true if the channel is in confirm mode
 // immediate: false
 // publishing:
 //
 //	DeliveryMode: amqp.Persistent
 //	ContentType: "text/plain"
+//	Priority: priority
 //	Body: []byte(message)
-func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string) error {
-	ch, err := mq.getChannel(chID)
+//
+// If the channel is in confirm mode, PublishToQueue waits for RabbitMQ to confirm the message.
+func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, message string, priority lanternmq.Priority) error {
+	return mq.publishToQueue(chID, qName, []string{message}, priority)
+}
+
+// PublishBatchToQueue publishes each of 'messages' on the queue with name 'qName' over the channel with ID 'chID'
+// in the same way as PublishToQueue. If the channel is in confirm mode, all of the messages are published before
+// waiting for RabbitMQ to confirm them.
+func (mq *MessageQueue) PublishBatchToQueue(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+	return mq.publishToQueue(chID, qName, messages, priority)
+}
+
+// publishToQueue publishes 'messages' with the priority 'priority' on the queue with name 'qName' over the channel
+// with ID 'chID', and waits for them to be confirmed if the channel is in confirm mode.
+func (mq *MessageQueue) publishToQueue(chID lanternmq.ChannelID, qName string, messages []string, priority lanternmq.Priority) error {
+	c, err := mq.getChannelState(chID)
 	if err != nil {
 		return err
 	}
 
-	err = ch.Publish(
-		"", // exchange
-		qName,
-		mandatoryFalse,
-		immediateFalse,
-		amqp.Publishing{
-			DeliveryMode: deliveryMode,
-			ContentType:  contentTypePlainText,
-			Body:         []byte(message),
-		})
+	publishings := make([]amqp.Publishing, len(messages))
+	for i, message := range messages {
+		publishings[i] = queuePublishing(message, priority)
+	}
+	return mq.publish(c, "", qName, mandatoryTrue, publishings)
+}
+
+// publish publishes 'publishings' to the exchange 'exchange' with routing key 'key' over the channel 'c', and
+// waits for them to be confirmed if the channel is in confirm mode. The default exchange "" routes to the queue
+// named by the routing key. In confirm mode, the messages are published as 'mandatory', and any that couldn't be
+// routed to a queue are returned as an error.
+func (mq *MessageQueue) publish(c *channel, exchange string, key string, mandatory bool, publishings []amqp.Publishing) error {
+	var err error
+
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+
+	mq.mu.RLock()
+	ch := c.ch
+	conf := c.confirmer
+	timeout := c.confirmTimeout
+	mq.mu.RUnlock()
+
+	if ch == nil {
+		return errors.New("channel is not open")
+	}
+
+	if conf == nil {
+		for _, publishing := range publishings {
+			err = ch.Publish(
+				exchange,
+				key,
+				mandatoryFalse,
+				immediateFalse,
+				publishing)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// RabbitMQ can't send more confirmations than fit in the buffer until they are read
+	for len(publishings) > 0 {
+		batch := publishings
+		if len(batch) > confirmBufferSize {
+			batch = batch[:confirmBufferSize]
+		}
+		publishings = publishings[len(batch):]
+
+		drainReturns(conf.returns)
+		firstTag := conf.nextTag
+		for _, publishing := range batch {
+			err = ch.Publish(
+				exchange,
+				key,
+				mandatory,
+				immediateFalse,
+				publishing)
+			if err != nil {
+				return err
+			}
+			conf.nextTag++
+		}
+		err = waitForConfirms(conf, firstTag, conf.nextTag, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queuePublishing returns the amqp.Publishing for sending 'message' to a queue with the priority 'priority'.
+func queuePublishing(message string, priority lanternmq.Priority) amqp.Publishing {
+	return amqp.Publishing{
+		DeliveryMode: deliveryMode,
+		ContentType:  contentTypePlainText,
+		Priority:     uint8(priority),
+		Body:         []byte(message),
+	}
+}
+
+// waitForConfirms waits up to 'timeout' for RabbitMQ to confirm the messages with delivery tags from 'firstTag' up
+// to but not including 'endTag'. It returns an error if the messages aren't confirmed in time, if any of them
+// couldn't be routed to a queue and were returned, or if RabbitMQ rejected any of them. Confirmations for earlier
+// messages, such as ones that timed out, are skipped.
+func waitForConfirms(conf *confirmer, firstTag uint64, endTag uint64, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	pending := endTag - firstTag
+	nacked := 0
+	for pending > 0 {
+		select {
+		case confirmation, ok := <-conf.confirms:
+			if !ok {
+				return fmt.Errorf("channel closed before %d published messages were confirmed", pending)
+			}
+			if confirmation.DeliveryTag < firstTag || confirmation.DeliveryTag >= endTag {
+				continue
+			}
+			pending--
+			if !confirmation.Ack {
+				nacked++
+			}
+		case <-deadline:
+			return fmt.Errorf("timed out after %s waiting for %d published messages to be confirmed", timeout, pending)
+		}
+	}
+
+	// RabbitMQ returns an unroutable message before confirming it, so any returns have already been received
+	returned := drainReturns(conf.returns)
+	if len(returned) > 0 {
+		return fmt.Errorf("%d published messages were returned as unroutable: %s %s",
+			len(returned), returned[0].RoutingKey, returned[0].ReplyText)
+	}
+	if nacked > 0 {
+		return fmt.Errorf("%d published messages were rejected by the message queue", nacked)
+	}
+	return nil
+}
+
+// drainReturns reads the returned messages that are waiting on 'returns' without waiting for more.
+func drainReturns(returns chan amqp.Return) []amqp.Return {
+	var returned []amqp.Return
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				return returned
+			}
+			returned = append(returned, r)
+		default:
+			return returned
+		}
+	}
+}
+
+// ConfirmPublishes puts the channel with ID 'chID' in confirm mode using RabbitMQ's Confirm method with noWait
+// false. Messages published to a queue over the channel are then published as mandatory, and publishing to a
+// queue or an exchange waits up to 'timeout' for RabbitMQ to confirm the messages. The channel is put in confirm
+// mode again if it is recreated.
+func (mq *MessageQueue) ConfirmPublishes(chID lanternmq.ChannelID, timeout time.Duration) error {
+	c, err := mq.getChannelState(chID)
+	if err != nil {
+		return err
+	}
+
+	// run while the write lock is held, including when the channel is recreated
+	setup := func(ch *amqp.Channel) error {
+		err := ch.Confirm(noWaitFalse)
+		if err != nil {
+			return err
+		}
+		c.confirmer = &confirmer{
+			confirms: ch.NotifyPublish(make(chan amqp.Confirmation, confirmBufferSize)),
+			returns:  ch.NotifyReturn(make(chan amqp.Return, confirmBufferSize)),
+			nextTag:  1,
+		}
+		return nil
+	}
+
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	err = setup(c.ch)
+	if err != nil {
+		err = fmt.Errorf("unable to put channel in confirm mode: %s", err.Error())
+		return err
+	}
+	c.confirmTimeout = timeout
+	c.setup = append(c.setup, setup)
+
 	return err
 }
 
@@ -225,30 +762,72 @@ func (mq *MessageQueue) PublishToQueue(chID lanternmq.ChannelID, qName string, m
 // noLocal: false
 // noWait: false
 // args: nil
+// The consumer is resumed with the same arguments if the channel is recreated.
 func (mq *MessageQueue) ConsumeFromQueue(chID lanternmq.ChannelID, qName string) (lanternmq.Messages, error) {
-	ch, err := mq.getChannel(chID)
+	return mq.consumeFromQueue(chID, qName, nil)
+}
+
+// ConsumeFromQueueWithRetry opens a receive channel for the queue with name 'qName' over the channel with ID
+// 'chID' in the same way as ConsumeFromQueue. When the messages are processed with ProcessMessages, they are
+// retried or dead lettered according to 'policy'. The policy's retry queue must dead letter expired messages
+// back to 'qName', eg. by being declared with the arguments
+// x-dead-letter-exchange: ""
+// x-dead-letter-routing-key: qName
+// Messages wait in the retry queue until they expire after the policy's delay.
+func (mq *MessageQueue) ConsumeFromQueueWithRetry(chID lanternmq.ChannelID, qName string, policy lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	if policy.Delay > 0 && policy.RetryQueue == "" {
+		return nil, errors.New("a retry queue is required to retry messages after a delay")
+	}
+	for _, name := range []string{policy.RetryQueue, policy.DeadLetterQueue} {
+		if name == "" {
+			continue
+		}
+		exists, err := mq.QueueExists(chID, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("queue %s does not exist", name)
+		}
+	}
+	return mq.consumeFromQueue(chID, qName, &policy)
+}
+
+// consumeFromQueue opens a receive channel for the queue with name 'qName' over the channel with ID 'chID' whose
+// messages are handled according to 'policy', which is nil if the consumer has no retry policy.
+func (mq *MessageQueue) consumeFromQueue(chID lanternmq.ChannelID, qName string, policy *lanternmq.RetryPolicy) (lanternmq.Messages, error) {
+	c, err := mq.getChannelState(chID)
 	if err != nil {
 		return nil, err
 	}
 
-	deliveryChannel, err := ch.Consume(
-		qName,
-		"", // consumer
-		autoAckFalse,
-		exclusiveFalse,
-		noLocalFalse,
-		noWaitFalse,
-		nil, // args
-	)
-	msgs := Messages{deliveryChannel: deliveryChannel}
+	msgs := &Messages{
+		qName:           qName,
+		deliveryChannel: make(chan amqp.Delivery),
+		notices:         make(chan error, 1),
+		done:            make(chan struct{}),
+		c:               c,
+		policy:          policy,
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	err = consume(c.ch, msgs)
+	if err != nil {
+		return msgs, err
+	}
+	c.consumers = append(c.consumers, msgs)
 
-	return &msgs, err
+	return msgs, err
 }
 
 // ProcessMessages takes 'msgs', which wraps a receive channel for amqp.Delivery objects, and processes each Delivery
 // object by retrieving the message from the Delivery object and providing that along with 'args' to the
-// lanternmq.MessageHandler 'handler'. An acknowledgement is sent to the sender after each message is processed.
-// If there's an error processing a message, the error is sent to the 'errs' channel.
+// lanternmq.MessageHandler 'handler'. An acknowledgement is sent to the sender after each message is processed
+// successfully. If the handler returns an error wrapped with lanternmq.Retry, the message is retried according to
+// the consumer's retry policy, and if it returns any other error the message is rejected. See settle. If there's
+// an error processing a message, or an error reconnecting to RabbitMQ, the error is sent to the 'errs' channel.
+// ProcessMessages returns when the context is done or the MessageQueue is closed.
 // ProcessMessages should be called as a goroutine. Example:
 //
 //	go mq.ProcessMessages(msgs, handler, nil, errs)
@@ -256,23 +835,121 @@ func (mq *MessageQueue) ProcessMessages(ctx context.Context, msgs lanternmq.Mess
 	msgsd, ok := msgs.(*Messages)
 	if !ok {
 		errs <- errors.New("the messages are of the wrong type")
+		return
 	}
 
-	for d := range msgsd.deliveryChannel {
+	for {
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			// ok
-		}
-		err := handler(d.Body, args)
-		if err != nil {
+		case <-msgsd.done:
+			return
+		case err := <-msgsd.notices:
 			errs <- err
+		case d := <-msgsd.deliveryChannel:
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				// ok
+			}
+			handlerErr := lanternmq.Handle(handler, d.Body, args)
+			if handlerErr != nil {
+				errs <- handlerErr
+			}
+			err := mq.settle(msgsd, d, handlerErr)
+			if err != nil {
+				errs <- err
+			}
 		}
-		err = d.Ack(false)
-		if err != nil {
-			errs <- err
+	}
+}
+
+// settle acknowledges, retries or rejects the delivery 'd' received through 'msgs', given the error 'handlerErr'
+// returned when it was processed:
+// * if 'handlerErr' is nil, the delivery is acknowledged.
+// * if 'handlerErr' was wrapped with lanternmq.Retry and the consumer has a retry policy with no delay and no
+// limit on attempts, the delivery is requeued with RabbitMQ's Nack method.
+// * if 'handlerErr' was wrapped with lanternmq.Retry and the delivery has attempts left under the consumer's
+// retry policy, it is published again with its attempts counted in a header, to the policy's retry queue with
+// an expiration of the policy's delay, or back to its own queue if there is no delay.
+// * otherwise the delivery is published to the policy's dead letter queue along with the error, or if there is
+// no dead letter queue, it is rejected without being requeued. Consumers without a retry policy never retry a
+// delivery, so that it isn't redelivered over and over with no delay.
+func (mq *MessageQueue) settle(msgs *Messages, d amqp.Delivery, handlerErr error) error {
+	if handlerErr == nil {
+		return d.Ack(false)
+	}
+
+	policy := msgs.policy
+	attempts := deliveryAttempts(d) + 1
+
+	if lanternmq.IsRetry(handlerErr) && policy != nil && (policy.MaxAttempts == 0 || attempts < policy.MaxAttempts) {
+		if policy.Delay == 0 && policy.MaxAttempts == 0 {
+			return d.Nack(false, true)
+		}
+		qName := msgs.qName
+		publishing := republishing(d, attempts, "")
+		if policy.Delay > 0 {
+			qName = policy.RetryQueue
+			publishing.Expiration = strconv.FormatInt(policy.Delay.Milliseconds(), 10)
+		}
+		return mq.forward(msgs, d, qName, publishing)
+	}
+
+	if policy == nil || policy.DeadLetterQueue == "" {
+		return d.Nack(false, false)
+	}
+	return mq.forward(msgs, d, policy.DeadLetterQueue, republishing(d, attempts, handlerErr.Error()))
+}
+
+// forward publishes 'publishing' to the queue with name 'qName' in place of the delivery 'd' and acknowledges
+// 'd'. If 'publishing' can't be published, 'd' is requeued instead so that it isn't lost.
+func (mq *MessageQueue) forward(msgs *Messages, d amqp.Delivery, qName string, publishing amqp.Publishing) error {
+	err := mq.publish(msgs.c, "", qName, mandatoryTrue, []amqp.Publishing{publishing})
+	if err != nil {
+		nackErr := d.Nack(false, true)
+		if nackErr != nil {
+			return nackErr
 		}
+		return fmt.Errorf("unable to send message to queue %s, requeued it instead: %s", qName, err.Error())
+	}
+	return d.Ack(false)
+}
+
+// deliveryAttempts returns how many times the delivery 'd' has already been processed, according to its
+// attempts header.
+func deliveryAttempts(d amqp.Delivery) int {
+	switch attempts := d.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(attempts)
+	case int64:
+		return int(attempts)
+	case int:
+		return attempts
+	}
+	return 0
+}
+
+// republishing returns the amqp.Publishing for sending the delivery 'd' to another queue after it has been
+// processed 'attempts' times. If 'reason' is not empty, it is recorded as the error the message couldn't be
+// processed with.
+func republishing(d amqp.Delivery, attempts int, reason string) amqp.Publishing {
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[attemptsHeader] = int32(attempts)
+	if reason != "" {
+		headers[errorHeader] = reason
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: d.DeliveryMode,
+		Priority:     d.Priority,
+		Body:         d.Body,
 	}
 }
 
@@ -291,18 +968,23 @@ func (mq *MessageQueue) DeclareExchange(chID lanternmq.ChannelID, name string, e
 		return err
 	}
 
-	err = ch.ExchangeDeclare(
-		name,
-		exchangeType,
-		durableTrue,
-		autoDeleteFalse,
-		internalFalse,
-		noWaitFalse,
-		nil, // args
-	)
+	setup := func(ch *amqp.Channel) error {
+		return ch.ExchangeDeclare(
+			name,
+			exchangeType,
+			durableTrue,
+			autoDeleteFalse,
+			internalFalse,
+			noWaitFalse,
+			nil, // args
+		)
+	}
+	err = setup(ch)
 	if err != nil {
 		err = errors.New("unable to declare target")
+		return err
 	}
+	mq.addSetup(chID, setup)
 
 	return err
 }
@@ -315,25 +997,26 @@ func (mq *MessageQueue) DeclareExchange(chID lanternmq.ChannelID, name string, e
 // immediate: false
 // publishing:
 //
+//	DeliveryMode: amqp.Persistent
 //	ContentType: "text/plain"
 //	Body: []byte(message)
+//
+// If the channel is in confirm mode, PublishToExchange waits for RabbitMQ to confirm the message in the same way
+// as PublishToQueue. The message isn't mandatory, since an exchange may have no queue bound for the routing key.
 func (mq *MessageQueue) PublishToExchange(chID lanternmq.ChannelID, name string, routingKey string, message string) error {
-	ch, err := mq.getChannel(chID)
+	c, err := mq.getChannelState(chID)
 	if err != nil {
 		return err
 	}
 
-	err = ch.Publish(
-		name,
-		routingKey,
-		mandatoryFalse,
-		immediateFalse,
-		amqp.Publishing{
-			ContentType: contentTypePlainText,
-			Body:        []byte(message),
-		})
+	publishing := amqp.Publishing{
+		DeliveryMode: deliveryMode,
+		ContentType:  contentTypePlainText,
+		Body:         []byte(message),
+	}
+	err = mq.publish(c, name, routingKey, mandatoryFalse, []amqp.Publishing{publishing})
 	if err != nil {
-		err = fmt.Errorf("unable to publish to target %s with routing key %s", name, routingKey)
+		err = fmt.Errorf("unable to publish to target %s with routing key %s: %s", name, routingKey, err.Error())
 	}
 
 	return err
@@ -361,40 +1044,62 @@ func (mq *MessageQueue) DeclareExchangeReceiveQueue(chID lanternmq.ChannelID, ex
 		return err
 	}
 
-	_, err = ch.QueueDeclare(
-		qName,
-		durableFalse,
-		deleteWhenUnusedFalse,
-		exclusiveTrue,
-		noWaitFalse,
-		nil, // args
-	)
+	declare := func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(
+			qName,
+			durableFalse,
+			deleteWhenUnusedFalse,
+			exclusiveTrue,
+			noWaitFalse,
+			nil, // args
+		)
+		return err
+	}
+	err = declare(ch)
 	if err != nil {
 		err = fmt.Errorf("unable to create queue: %s", err.Error())
 		return err
 	}
 
-	err = ch.QueueBind(
-		qName,
-		routingKey,
-		exchangeName,
-		noWaitFalse,
-		nil, // args
-	)
+	bind := func(ch *amqp.Channel) error {
+		return ch.QueueBind(
+			qName,
+			routingKey,
+			exchangeName,
+			noWaitFalse,
+			nil, // args
+		)
+	}
+	err = bind(ch)
 	if err != nil {
 		err = fmt.Errorf("unable to bind queue %s to target %s with routing key %s", qName, exchangeName, routingKey)
 		return err
 	}
 
+	// the queue is exclusive to the connection, so it is declared and bound again when the connection is
+	// reestablished
+	mq.addSetup(chID, declare)
+	mq.addSetup(chID, bind)
+
 	return err
 }
 
 // Close closes each channel that's been created, and then closes the connection to the underlying RabbitMQ
-// message service.
+// message service. Any ProcessMessages calls return and the MessageQueue no longer reconnects.
 func (mq *MessageQueue) Close() {
-	if mq.channels != nil {
-		for _, ch := range mq.channels {
-			ch.Close()
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.closed {
+		return
+	}
+	mq.closed = true
+	for _, c := range mq.channels {
+		for _, msgs := range c.consumers {
+			close(msgs.done)
+		}
+		if c.ch != nil {
+			c.ch.Close()
 		}
 	}
 	if mq.connection != nil {
@@ -0,0 +1,282 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onc-healthit/lantern-back-end/lanternmq"
+	"github.com/streadway/amqp"
+)
+
+func Test_reconnectDelay(t *testing.T) {
+	expected := []time.Duration{
+		500 * time.Millisecond,
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+		30 * time.Second,
+		30 * time.Second,
+	}
+	for attempt, delay := range expected {
+		if reconnectDelay(attempt) != delay {
+			t.Errorf("expected a delay of %s before attempt %d, got %s", delay, attempt, reconnectDelay(attempt))
+		}
+	}
+	if reconnectDelay(1000) != maxReconnectDelay {
+		t.Errorf("expected the delay to be capped at %s, got %s", maxReconnectDelay, reconnectDelay(1000))
+	}
+}
+
+func Test_getChannel(t *testing.T) {
+	mq := &MessageQueue{}
+
+	_, err := mq.getChannel("0")
+	if err == nil || err.Error() != "ChannelID not of correct type" {
+		t.Errorf("expected an error for a channel ID of the wrong type, got %v", err)
+	}
+
+	_, err = mq.getChannel(0)
+	if err == nil || err.Error() != "no channel with the requested ID was found" {
+		t.Errorf("expected an error for a missing channel, got %v", err)
+	}
+
+	_, err = mq.CreateChannel()
+	if err == nil || err.Error() != "connection must exist before creating a channel" {
+		t.Errorf("expected an error creating a channel without a connection, got %v", err)
+	}
+}
+
+func Test_ProcessMessagesNotices(t *testing.T) {
+	mq := &MessageQueue{}
+	msgs := &Messages{
+		deliveryChannel: make(chan amqp.Delivery),
+		notices:         make(chan error, 1),
+		done:            make(chan struct{}),
+	}
+	mq.channels = []*channel{{consumers: []*Messages{msgs}}}
+
+	errs := make(chan error)
+	finished := make(chan bool)
+	go func() {
+		mq.ProcessMessages(context.Background(), msgs, func(_ []byte, _ *map[string]interface{}) error { return nil }, nil, errs)
+		finished <- true
+	}()
+
+	// errors hit while reconnecting are passed on to the errs channel
+	mq.notify(errors.New("lost connection to message queue"))
+	select {
+	case err := <-errs:
+		if err.Error() != "lost connection to message queue" {
+			t.Errorf("expected the reconnection error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the reconnection error to be sent to the errs channel")
+	}
+
+	// closing the message queue stops processing
+	mq.Close()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Errorf("expected ProcessMessages to return after the message queue was closed")
+	}
+}
+
+func Test_waitForConfirms(t *testing.T) {
+	newConfirmer := func() *confirmer {
+		return &confirmer{
+			confirms: make(chan amqp.Confirmation, 10),
+			returns:  make(chan amqp.Return, 10),
+		}
+	}
+
+	// confirmations for earlier messages are skipped
+	conf := newConfirmer()
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+	err := waitForConfirms(conf, 2, 4, time.Second)
+	if err != nil {
+		t.Errorf("expected the messages to be confirmed, got %s", err.Error())
+	}
+
+	// rejected messages
+	conf = newConfirmer()
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	err = waitForConfirms(conf, 1, 3, time.Second)
+	if err == nil || err.Error() != "1 published messages were rejected by the message queue" {
+		t.Errorf("expected an error for a rejected message, got %v", err)
+	}
+
+	// returned messages
+	conf = newConfirmer()
+	conf.returns <- amqp.Return{RoutingKey: "missing-queue", ReplyText: "NO_ROUTE"}
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	err = waitForConfirms(conf, 1, 2, time.Second)
+	if err == nil || err.Error() != "1 published messages were returned as unroutable: missing-queue NO_ROUTE" {
+		t.Errorf("expected an error for a returned message, got %v", err)
+	}
+
+	// timeout
+	conf = newConfirmer()
+	conf.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	err = waitForConfirms(conf, 1, 3, 10*time.Millisecond)
+	if err == nil || err.Error() != "timed out after 10ms waiting for 1 published messages to be confirmed" {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+
+	// closed channel
+	conf = newConfirmer()
+	close(conf.confirms)
+	err = waitForConfirms(conf, 1, 2, time.Second)
+	if err == nil || err.Error() != "channel closed before 1 published messages were confirmed" {
+		t.Errorf("expected an error for a closed channel, got %v", err)
+	}
+}
+
+func Test_drainReturns(t *testing.T) {
+	returns := make(chan amqp.Return, 3)
+	if len(drainReturns(returns)) != 0 {
+		t.Errorf("expected no returned messages")
+	}
+
+	returns <- amqp.Return{RoutingKey: "a"}
+	returns <- amqp.Return{RoutingKey: "b"}
+	returned := drainReturns(returns)
+	if len(returned) != 2 || returned[0].RoutingKey != "a" || returned[1].RoutingKey != "b" {
+		t.Errorf("expected both returned messages, got %v", returned)
+	}
+	if len(returns) != 0 {
+		t.Errorf("expected the returned messages to be drained")
+	}
+}
+
+// acknowledger records how a delivery was settled
+type acknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func Test_settle(t *testing.T) {
+	mq := &MessageQueue{}
+	handlerErr := errors.New("unable to store message")
+
+	settle := func(policy *lanternmq.RetryPolicy, d amqp.Delivery, handlerErr error) (*acknowledger, error) {
+		ack := &acknowledger{}
+		d.Acknowledger = ack
+		// the channel isn't open, so messages that are forwarded to another queue are requeued instead
+		msgs := &Messages{qName: "queue", c: &channel{}, policy: policy}
+		err := mq.settle(msgs, d, handlerErr)
+		return ack, err
+	}
+
+	// processed messages are acknowledged
+	ack, err := settle(nil, amqp.Delivery{}, nil)
+	if err != nil || !ack.acked || ack.nacked {
+		t.Errorf("expected the message to be acknowledged, got %+v, %v", ack, err)
+	}
+
+	// without a retry policy, retried messages are rejected like other failures rather than redelivered straight
+	// away
+	ack, err = settle(nil, amqp.Delivery{}, lanternmq.Retry(handlerErr))
+	if err != nil || ack.acked || !ack.nacked || ack.requeue {
+		t.Errorf("expected the message to be rejected, got %+v, %v", ack, err)
+	}
+	ack, err = settle(nil, amqp.Delivery{}, handlerErr)
+	if err != nil || ack.acked || !ack.nacked || ack.requeue {
+		t.Errorf("expected the message to be rejected, got %+v, %v", ack, err)
+	}
+
+	// a policy with no delay or limit requeues retried messages
+	ack, err = settle(&lanternmq.RetryPolicy{}, amqp.Delivery{}, lanternmq.Retry(handlerErr))
+	if err != nil || !ack.nacked || !ack.requeue {
+		t.Errorf("expected the message to be requeued, got %+v, %v", ack, err)
+	}
+
+	// messages that have used up their attempts are rejected when there's no dead letter queue
+	policy := &lanternmq.RetryPolicy{MaxAttempts: 3, Delay: time.Minute, RetryQueue: "queue-retry"}
+	d := amqp.Delivery{Headers: amqp.Table{attemptsHeader: int32(2)}}
+	ack, err = settle(policy, d, lanternmq.Retry(handlerErr))
+	if err != nil || ack.acked || !ack.nacked || ack.requeue {
+		t.Errorf("expected the message to be rejected after 3 attempts, got %+v, %v", ack, err)
+	}
+
+	// messages that can't be sent to the retry queue are requeued rather than lost
+	d = amqp.Delivery{Headers: amqp.Table{attemptsHeader: int32(1)}}
+	ack, err = settle(policy, d, lanternmq.Retry(handlerErr))
+	if err == nil || err.Error() != "unable to send message to queue queue-retry, requeued it instead: channel is not open" {
+		t.Errorf("expected an error sending the message to the retry queue, got %v", err)
+	}
+	if ack.acked || !ack.nacked || !ack.requeue {
+		t.Errorf("expected the message to be requeued, got %+v", ack)
+	}
+
+	// as are messages that can't be sent to the dead letter queue
+	policy.DeadLetterQueue = "queue-dead"
+	ack, err = settle(policy, amqp.Delivery{}, handlerErr)
+	if err == nil || err.Error() != "unable to send message to queue queue-dead, requeued it instead: channel is not open" {
+		t.Errorf("expected an error sending the message to the dead letter queue, got %v", err)
+	}
+	if ack.acked || !ack.nacked || !ack.requeue {
+		t.Errorf("expected the message to be requeued, got %+v", ack)
+	}
+}
+
+func Test_republishing(t *testing.T) {
+	d := amqp.Delivery{
+		Headers:      amqp.Table{"other": "header", attemptsHeader: int64(1)},
+		ContentType:  contentTypePlainText,
+		DeliveryMode: deliveryMode,
+		Priority:     5,
+		Body:         []byte("message"),
+	}
+	if deliveryAttempts(d) != 1 {
+		t.Errorf("expected the delivery to have been processed once, got %d", deliveryAttempts(d))
+	}
+	if deliveryAttempts(amqp.Delivery{}) != 0 {
+		t.Errorf("expected a delivery without headers not to have been processed, got %d", deliveryAttempts(amqp.Delivery{}))
+	}
+
+	publishing := republishing(d, 2, "unable to store message")
+	if publishing.Headers[attemptsHeader] != int32(2) {
+		t.Errorf("expected the attempts header to be 2, got %v", publishing.Headers[attemptsHeader])
+	}
+	if publishing.Headers[errorHeader] != "unable to store message" {
+		t.Errorf("expected the error header to be set, got %v", publishing.Headers[errorHeader])
+	}
+	if publishing.Headers["other"] != "header" {
+		t.Errorf("expected the delivery's other headers to be kept")
+	}
+	if d.Headers[attemptsHeader] != int64(1) {
+		t.Errorf("expected the delivery's headers not to change")
+	}
+	if string(publishing.Body) != "message" || publishing.Priority != 5 || publishing.DeliveryMode != deliveryMode || publishing.ContentType != contentTypePlainText {
+		t.Errorf("expected the delivery's body and properties to be kept, got %+v", publishing)
+	}
+
+	publishing = republishing(d, 2, "")
+	if _, ok := publishing.Headers[errorHeader]; ok {
+		t.Errorf("expected no error header for a retried message")
+	}
+}
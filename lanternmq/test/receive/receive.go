@@ -46,7 +46,7 @@ func main() {
 	helpers.FailOnError("", err)
 
 	// Queue
-	err = mq.DeclareQueue(ch, "hello")
+	err = mq.DeclareQueue(ch, "hello", 0)
 	helpers.FailOnError("", err)
 	msgs, err := mq.ConsumeFromQueue(ch, "hello")
 	helpers.FailOnError("", err)
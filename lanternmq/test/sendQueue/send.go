@@ -34,11 +34,11 @@ func main() {
 	ch, err := mq.CreateChannel()
 	helpers.FailOnError("", err)
 
-	err = mq.DeclareQueue(ch, "hello")
+	err = mq.DeclareQueue(ch, "hello", 0)
 	helpers.FailOnError("", err)
 
 	body := bodyFrom(os.Args)
-	err = mq.PublishToQueue(ch, "hello", body)
+	err = mq.PublishToQueue(ch, "hello", body, 0)
 	log.Printf(" [x] Sent %s", body)
 	helpers.FailOnError("", err)
 }